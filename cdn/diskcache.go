@@ -0,0 +1,150 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cdn
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"bennypowers.dev/mappa/fs"
+)
+
+// DiskCacheFetcher wraps a Fetcher with an on-disk cache of successful responses.
+// Entries are keyed by URL and expire after the configured TTL, so repeated
+// invocations reuse npm metadata and CDN package.json files, and work offline
+// within the TTL window.
+type DiskCacheFetcher struct {
+	inner Fetcher
+	fs    fs.FileSystem
+	dir   string
+	ttl   time.Duration
+	now   func() time.Time
+}
+
+// diskCacheEntry is the on-disk representation of a cached response.
+type diskCacheEntry struct {
+	URL       string    `json:"url"`
+	FetchedAt time.Time `json:"fetchedAt"`
+	Body      []byte    `json:"body"`
+}
+
+// NewDiskCacheFetcher creates a Fetcher that caches responses from inner in dir.
+// Cached responses older than ttl are refetched.
+func NewDiskCacheFetcher(inner Fetcher, dir string, ttl time.Duration) *DiskCacheFetcher {
+	return &DiskCacheFetcher{
+		inner: inner,
+		fs:    fs.NewOSFileSystem(),
+		dir:   dir,
+		ttl:   ttl,
+		now:   time.Now,
+	}
+}
+
+// WithFileSystem returns a new DiskCacheFetcher that stores entries in the given filesystem.
+func (f *DiskCacheFetcher) WithFileSystem(fsys fs.FileSystem) *DiskCacheFetcher {
+	return &DiskCacheFetcher{
+		inner: f.inner,
+		fs:    fsys,
+		dir:   f.dir,
+		ttl:   f.ttl,
+		now:   f.now,
+	}
+}
+
+// DefaultCacheDir returns the default on-disk cache directory for mappa,
+// e.g. $XDG_CACHE_HOME/mappa on Linux.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "mappa"), nil
+}
+
+// NewCachedHTTPFetcher returns an HTTP fetcher with DefaultRetryOptions that
+// caches responses in DefaultCacheDir on fsys for ttl, as the CLI's --cdn
+// commands fetch. A ttl of zero or less disables the disk cache, as does a
+// missing cache directory.
+func NewCachedHTTPFetcher(fsys fs.FileSystem, ttl time.Duration) Fetcher {
+	var fetcher Fetcher = NewHTTPFetcherWithRetry(DefaultRetryOptions)
+	if ttl <= 0 {
+		return fetcher
+	}
+	if cacheDir, err := DefaultCacheDir(); err == nil {
+		fetcher = NewDiskCacheFetcher(fetcher, cacheDir, ttl).WithFileSystem(fsys)
+	}
+	return fetcher
+}
+
+// Fetch returns a cached response for url if one exists within the TTL,
+// otherwise fetches from the inner Fetcher and caches the result.
+// Cache read and write failures are not fatal; the inner Fetcher is used instead.
+func (f *DiskCacheFetcher) Fetch(ctx context.Context, url string) ([]byte, error) {
+	path := f.entryPath(url)
+
+	if body, ok := f.load(path, url); ok {
+		return body, nil
+	}
+
+	body, err := f.inner.Fetch(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	f.store(path, url, body)
+	return body, nil
+}
+
+// entryPath returns the cache file path for a URL.
+func (f *DiskCacheFetcher) entryPath(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(f.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// load reads a fresh cache entry, returning false if missing, stale, or unreadable.
+func (f *DiskCacheFetcher) load(path, url string) ([]byte, bool) {
+	data, err := f.fs.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var entry diskCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.URL != url {
+		return nil, false
+	}
+	if f.now().Sub(entry.FetchedAt) > f.ttl {
+		return nil, false
+	}
+	return entry.Body, true
+}
+
+// store writes a cache entry, ignoring errors.
+func (f *DiskCacheFetcher) store(path, url string, body []byte) {
+	data, err := json.Marshal(diskCacheEntry{URL: url, FetchedAt: f.now(), Body: body})
+	if err != nil {
+		return
+	}
+	if err := f.fs.MkdirAll(f.dir, 0755); err != nil {
+		return
+	}
+	_ = f.fs.WriteFile(path, data, 0644)
+}
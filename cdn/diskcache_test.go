@@ -0,0 +1,104 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cdn
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"bennypowers.dev/mappa/internal/mapfs"
+	"bennypowers.dev/mappa/testutil"
+)
+
+func TestDiskCacheFetcher(t *testing.T) {
+	const url = "https://registry.npmjs.org/lit"
+	data := testutil.LoadFixtureFile(t, "lit_registry.json")
+
+	inner := NewMapFetcher(nil)
+	inner.AddResponse(url, data)
+
+	mfs := mapfs.New()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	fetcher := NewDiskCacheFetcher(inner, "/cache/mappa", time.Hour).WithFileSystem(mfs)
+	fetcher.now = func() time.Time { return now }
+
+	ctx := context.Background()
+
+	// First fetch goes to the inner fetcher and populates the cache
+	body, err := fetcher.Fetch(ctx, url)
+	if err != nil {
+		t.Fatalf("Fetch error: %v", err)
+	}
+	if string(body) != string(data) {
+		t.Error("First fetch returned unexpected body")
+	}
+	if inner.Calls(url) != 1 {
+		t.Errorf("Expected 1 inner call, got %d", inner.Calls(url))
+	}
+
+	// Within the TTL, responses come from disk, even if the network fails
+	inner.AddError(url, errors.New("offline"))
+	now = now.Add(30 * time.Minute)
+	body, err = fetcher.Fetch(ctx, url)
+	if err != nil {
+		t.Fatalf("Cached fetch error: %v", err)
+	}
+	if string(body) != string(data) {
+		t.Error("Cached fetch returned unexpected body")
+	}
+	if inner.Calls(url) != 1 {
+		t.Errorf("Expected cached response, got %d inner calls", inner.Calls(url))
+	}
+
+	// A new fetcher sharing the same directory reuses the entry
+	other := NewDiskCacheFetcher(inner, "/cache/mappa", time.Hour).WithFileSystem(mfs)
+	other.now = fetcher.now
+	if _, err := other.Fetch(ctx, url); err != nil {
+		t.Fatalf("Fetch from shared cache error: %v", err)
+	}
+	if inner.Calls(url) != 1 {
+		t.Errorf("Expected shared cache hit, got %d inner calls", inner.Calls(url))
+	}
+
+	// After the TTL expires, the inner fetcher is consulted again
+	now = now.Add(2 * time.Hour)
+	if _, err := fetcher.Fetch(ctx, url); err == nil {
+		t.Error("Expected error from inner fetcher after TTL expiry")
+	}
+	if inner.Calls(url) != 2 {
+		t.Errorf("Expected 2 inner calls after expiry, got %d", inner.Calls(url))
+	}
+}
+
+func TestDiskCacheFetcherSkipsErrors(t *testing.T) {
+	const url = "https://registry.npmjs.org/missing"
+
+	inner := NewMapFetcher(nil)
+	fetcher := NewDiskCacheFetcher(inner, "/cache/mappa", time.Hour).WithFileSystem(mapfs.New())
+
+	for range 2 {
+		if _, err := fetcher.Fetch(context.Background(), url); err == nil {
+			t.Fatal("Expected error for missing package")
+		}
+	}
+	if inner.Calls(url) != 2 {
+		t.Errorf("Expected errors not to be cached, got %d inner calls", inner.Calls(url))
+	}
+}
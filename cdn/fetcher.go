@@ -20,7 +20,12 @@ package cdn
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/tinywasm/fetch"
 )
@@ -34,17 +39,66 @@ type Fetcher interface {
 
 // HTTPFetcher implements Fetcher using tinywasm/fetch.
 // Works in both native and WASM builds with minimal binary size.
-type HTTPFetcher struct{}
+type HTTPFetcher struct {
+	retry RetryOptions
+}
+
+// RetryOptions configures retries of failed GET requests.
+type RetryOptions struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	// Values <= 1 disable retries.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry. Each subsequent
+	// retry doubles the delay.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between attempts, including delays
+	// requested by Retry-After. 0 means no cap.
+	MaxDelay time.Duration
+}
+
+// DefaultRetryOptions retries up to 3 times with delays of 500ms, 1s, and 2s.
+var DefaultRetryOptions = RetryOptions{
+	MaxAttempts: 4,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+}
 
 // NewHTTPFetcher creates a new HTTP fetcher.
+// Failed requests are not retried; see NewHTTPFetcherWithRetry.
 func NewHTTPFetcher() *HTTPFetcher {
 	return &HTTPFetcher{}
 }
 
-// Fetch retrieves content from the given URL.
+// NewHTTPFetcherWithRetry creates an HTTP fetcher that retries network errors,
+// 5xx responses, and 429 responses with exponential backoff.
+// A Retry-After header on 429 and 503 responses overrides the backoff delay.
+func NewHTTPFetcherWithRetry(opts RetryOptions) *HTTPFetcher {
+	return &HTTPFetcher{retry: opts}
+}
+
+// Fetch retrieves content from the given URL, retrying transient failures
+// if configured. Context cancellation aborts the retry loop.
+func (f *HTTPFetcher) Fetch(ctx context.Context, url string) ([]byte, error) {
+	for attempt := 1; ; attempt++ {
+		body, err := f.fetchOnce(ctx, url)
+		if err == nil || attempt >= f.retry.MaxAttempts || ctx.Err() != nil || !isRetryable(err) {
+			return body, err
+		}
+
+		timer := time.NewTimer(f.retry.delay(attempt, err))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, &FetchError{URL: url, Message: ctx.Err().Error()}
+		}
+	}
+}
+
+// fetchOnce performs a single GET request.
 // Note: Context cancellation stops waiting for the response but does not
 // abort the underlying HTTP request due to the async callback pattern.
-func (f *HTTPFetcher) Fetch(ctx context.Context, url string) ([]byte, error) {
+func (f *HTTPFetcher) fetchOnce(ctx context.Context, url string) ([]byte, error) {
 	type result struct {
 		body []byte
 		err  error
@@ -57,11 +111,15 @@ func (f *HTTPFetcher) Fetch(ctx context.Context, url string) ([]byte, error) {
 			return
 		}
 		if resp.Status != 200 {
-			done <- result{nil, &FetchError{
+			fetchErr := &FetchError{
 				URL:        url,
 				StatusCode: resp.Status,
 				Message:    fmt.Sprintf("HTTP %d", resp.Status),
-			}}
+			}
+			if resp.Status == 429 || resp.Status == 503 {
+				fetchErr.RetryAfter = parseRetryAfter(resp.GetHeader("Retry-After"), time.Now())
+			}
+			done <- result{nil, fetchErr}
 			return
 		}
 		done <- result{resp.Body(), nil}
@@ -75,11 +133,65 @@ func (f *HTTPFetcher) Fetch(ctx context.Context, url string) ([]byte, error) {
 	}
 }
 
+// delay returns how long to wait before the next attempt.
+// attempt is the 1-indexed number of the attempt that just failed.
+func (o RetryOptions) delay(attempt int, err error) time.Duration {
+	// Double once per earlier attempt, stopping at the cap or before the
+	// delay would overflow
+	d := o.BaseDelay
+	for range attempt - 1 {
+		if d <= 0 || d > math.MaxInt64/2 || (o.MaxDelay > 0 && d >= o.MaxDelay) {
+			break
+		}
+		d *= 2
+	}
+	var fetchErr *FetchError
+	if errors.As(err, &fetchErr) && fetchErr.RetryAfter > 0 {
+		d = fetchErr.RetryAfter
+	}
+	if o.MaxDelay > 0 && d > o.MaxDelay {
+		d = o.MaxDelay
+	}
+	return d
+}
+
+// isRetryable returns true for network errors, 5xx responses, and 429 responses.
+func isRetryable(err error) bool {
+	var fetchErr *FetchError
+	if !errors.As(err, &fetchErr) {
+		return false
+	}
+	return fetchErr.StatusCode == 0 || fetchErr.StatusCode == 429 || fetchErr.StatusCode >= 500
+}
+
+// parseRetryAfter parses a Retry-After header value, which is either
+// a number of seconds or an HTTP date. Returns 0 if absent or invalid.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := time.Parse("Mon, 02 Jan 2006 15:04:05 GMT", value); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
 // FetchError represents an HTTP fetch error with status information.
 type FetchError struct {
 	URL        string
 	StatusCode int
 	Message    string
+	// RetryAfter is the delay requested by the server's Retry-After header, if any.
+	RetryAfter time.Duration
 }
 
 func (e *FetchError) Error() string {
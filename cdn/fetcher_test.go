@@ -0,0 +1,187 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cdn
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyServer fails the first n requests with the given status, then succeeds.
+func flakyServer(t *testing.T, n int32, status int, header map[string]string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) <= n {
+			for k, v := range header {
+				w.Header().Set(k, v)
+			}
+			w.WriteHeader(status)
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &hits
+}
+
+func TestHTTPFetcherRetry(t *testing.T) {
+	opts := RetryOptions{MaxAttempts: 3, BaseDelay: time.Millisecond}
+
+	t.Run("retries 5xx until success", func(t *testing.T) {
+		server, hits := flakyServer(t, 2, http.StatusServiceUnavailable, nil)
+		body, err := NewHTTPFetcherWithRetry(opts).Fetch(context.Background(), server.URL)
+		if err != nil {
+			t.Fatalf("Fetch error: %v", err)
+		}
+		if string(body) != `{"ok":true}` {
+			t.Errorf("Unexpected body: %s", body)
+		}
+		if hits.Load() != 3 {
+			t.Errorf("Expected 3 requests, got %d", hits.Load())
+		}
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		server, hits := flakyServer(t, 5, http.StatusBadGateway, nil)
+		_, err := NewHTTPFetcherWithRetry(opts).Fetch(context.Background(), server.URL)
+		var fetchErr *FetchError
+		if !errors.As(err, &fetchErr) || fetchErr.StatusCode != http.StatusBadGateway {
+			t.Fatalf("Expected 502 FetchError, got %v", err)
+		}
+		if hits.Load() != 3 {
+			t.Errorf("Expected 3 requests, got %d", hits.Load())
+		}
+	})
+
+	t.Run("does not retry 404", func(t *testing.T) {
+		server, hits := flakyServer(t, 5, http.StatusNotFound, nil)
+		if _, err := NewHTTPFetcherWithRetry(opts).Fetch(context.Background(), server.URL); err == nil {
+			t.Fatal("Expected error for 404")
+		}
+		if hits.Load() != 1 {
+			t.Errorf("Expected 1 request, got %d", hits.Load())
+		}
+	})
+
+	t.Run("retries 429 with Retry-After", func(t *testing.T) {
+		server, hits := flakyServer(t, 1, http.StatusTooManyRequests, map[string]string{"Retry-After": "0"})
+		if _, err := NewHTTPFetcherWithRetry(opts).Fetch(context.Background(), server.URL); err != nil {
+			t.Fatalf("Fetch error: %v", err)
+		}
+		if hits.Load() != 2 {
+			t.Errorf("Expected 2 requests, got %d", hits.Load())
+		}
+	})
+
+	t.Run("default fetcher does not retry", func(t *testing.T) {
+		server, hits := flakyServer(t, 1, http.StatusServiceUnavailable, nil)
+		if _, err := NewHTTPFetcher().Fetch(context.Background(), server.URL); err == nil {
+			t.Fatal("Expected error without retries")
+		}
+		if hits.Load() != 1 {
+			t.Errorf("Expected 1 request, got %d", hits.Load())
+		}
+	})
+
+	t.Run("context cancellation aborts backoff", func(t *testing.T) {
+		server, _ := flakyServer(t, 5, http.StatusServiceUnavailable, nil)
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := NewHTTPFetcherWithRetry(RetryOptions{MaxAttempts: 5, BaseDelay: 10 * time.Second}).Fetch(ctx, server.URL)
+		if err == nil {
+			t.Fatal("Expected error after cancellation")
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("Retry loop did not abort promptly: %v", elapsed)
+		}
+	})
+}
+
+func TestRetryDelay(t *testing.T) {
+	opts := RetryOptions{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	serverErr := &FetchError{StatusCode: 503}
+
+	tests := []struct {
+		name     string
+		attempt  int
+		err      error
+		expected time.Duration
+	}{
+		{"first retry", 1, serverErr, 100 * time.Millisecond},
+		{"second retry", 2, serverErr, 200 * time.Millisecond},
+		{"third retry", 3, serverErr, 400 * time.Millisecond},
+		{"capped", 6, serverErr, time.Second},
+		{"retry-after", 1, &FetchError{StatusCode: 429, RetryAfter: 700 * time.Millisecond}, 700 * time.Millisecond},
+		{"retry-after capped", 1, &FetchError{StatusCode: 429, RetryAfter: time.Minute}, time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := opts.delay(tt.attempt, tt.err); got != tt.expected {
+				t.Errorf("delay(%d) = %v, want %v", tt.attempt, got, tt.expected)
+			}
+		})
+	}
+
+	// Without a cap, delays keep growing until they would overflow, then hold
+	uncapped := RetryOptions{BaseDelay: 3 * time.Nanosecond}
+	prev := time.Duration(0)
+	for attempt := 1; attempt <= 100; attempt++ {
+		got := uncapped.delay(attempt, serverErr)
+		if got < prev {
+			t.Fatalf("uncapped delay(%d) = %v, less than delay(%d) = %v", attempt, got, attempt-1, prev)
+		}
+		prev = got
+	}
+	if prev < math.MaxInt64/2 {
+		t.Errorf("uncapped delay(100) = %v, want the largest delay before overflow", prev)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{"", 0},
+		{"5", 5 * time.Second},
+		{"-1", 0},
+		{"Thu, 01 Jan 2026 00:00:30 GMT", 30 * time.Second},
+		{"Wed, 31 Dec 2025 23:59:00 GMT", 0},
+		{"soon", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := parseRetryAfter(tt.value, now); got != tt.expected {
+				t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.expected)
+			}
+		})
+	}
+}
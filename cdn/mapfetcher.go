@@ -0,0 +1,89 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cdn
+
+import (
+	"context"
+	"maps"
+	"sync"
+)
+
+// MapFetcher is a Fetcher that serves responses from memory, for testing
+// CDN resolution deterministically without network access. URLs without a
+// response or error fail with a 404 FetchError. It is safe for concurrent
+// use.
+type MapFetcher struct {
+	mu        sync.Mutex
+	responses map[string][]byte
+	errors    map[string]error
+	calls     map[string]int
+}
+
+// NewMapFetcher creates a MapFetcher serving the given response body for
+// each URL. The map is copied, so later changes to it have no effect; use
+// AddResponse instead.
+func NewMapFetcher(responses map[string][]byte) *MapFetcher {
+	f := &MapFetcher{
+		responses: maps.Clone(responses),
+		errors:    make(map[string]error),
+		calls:     make(map[string]int),
+	}
+	if f.responses == nil {
+		f.responses = make(map[string][]byte)
+	}
+	return f
+}
+
+// AddResponse serves data for url.
+func (f *MapFetcher) AddResponse(url string, data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses[url] = data
+}
+
+// AddError makes fetching url fail with err, taking precedence over any
+// response for it, e.g. to simulate a registry outage with a FetchError.
+func (f *MapFetcher) AddError(url string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.errors[url] = err
+}
+
+// Calls returns how many times url was fetched.
+func (f *MapFetcher) Calls(url string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[url]
+}
+
+// Fetch returns the response or error added for url.
+func (f *MapFetcher) Fetch(ctx context.Context, url string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls[url]++
+	if err, ok := f.errors[url]; ok {
+		return nil, err
+	}
+	if data, ok := f.responses[url]; ok {
+		return data, nil
+	}
+	return nil, &FetchError{URL: url, StatusCode: 404, Message: "Not Found"}
+}
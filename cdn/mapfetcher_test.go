@@ -0,0 +1,72 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cdn
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMapFetcher(t *testing.T) {
+	const url = "https://registry.npmjs.org/lit"
+	responses := map[string][]byte{url: []byte(`{"name":"lit"}`)}
+	fetcher := NewMapFetcher(responses)
+
+	// Later changes to the map have no effect
+	delete(responses, url)
+
+	data, err := fetcher.Fetch(context.Background(), url)
+	if err != nil {
+		t.Fatalf("Fetch(%s) failed: %v", url, err)
+	}
+	if string(data) != `{"name":"lit"}` {
+		t.Errorf("Fetch(%s) = %s", url, data)
+	}
+
+	_, err = fetcher.Fetch(context.Background(), "https://registry.npmjs.org/missing")
+	var fetchErr *FetchError
+	if !errors.As(err, &fetchErr) || fetchErr.StatusCode != 404 {
+		t.Errorf("Fetch of unknown URL: expected 404 FetchError, got %v", err)
+	}
+
+	// Errors take precedence over responses
+	outage := &FetchError{URL: url, StatusCode: 503, Message: "Service Unavailable"}
+	fetcher.AddError(url, outage)
+	if _, err := fetcher.Fetch(context.Background(), url); !errors.Is(err, outage) {
+		t.Errorf("Fetch after AddError: expected %v, got %v", outage, err)
+	}
+
+	if calls := fetcher.Calls(url); calls != 2 {
+		t.Errorf("Calls(%s) = %d, want 2", url, calls)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := fetcher.Fetch(ctx, url); !errors.Is(err, context.Canceled) {
+		t.Errorf("Fetch with canceled context: expected context.Canceled, got %v", err)
+	}
+}
+
+func TestMapFetcherNil(t *testing.T) {
+	fetcher := NewMapFetcher(nil)
+	fetcher.AddResponse("https://esm.sh/lit@3.0.0/package.json", []byte(`{}`))
+	if _, err := fetcher.Fetch(context.Background(), "https://esm.sh/lit@3.0.0/package.json"); err != nil {
+		t.Errorf("Fetch after AddResponse failed: %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cdn
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"bennypowers.dev/mappa/fs"
+)
+
+// Npmrc holds the registry settings from an .npmrc file.
+// Authentication and other settings are ignored.
+type Npmrc struct {
+	// Registry is the default registry URL ("registry=...").
+	Registry string
+	// ScopeRegistries maps scopes such as "@myorg" to their registry URLs
+	// ("@myorg:registry=...").
+	ScopeRegistries map[string]string
+}
+
+// ParseNpmrc parses the registry settings from .npmrc file contents.
+// Lines are key=value pairs; blank lines and lines starting with # or ; are skipped.
+func ParseNpmrc(data []byte) *Npmrc {
+	rc := &Npmrc{ScopeRegistries: make(map[string]string)}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+
+		if key == "registry" {
+			rc.Registry = value
+		} else if scope, ok := strings.CutSuffix(key, ":registry"); ok && strings.HasPrefix(scope, "@") {
+			rc.ScopeRegistries[scope] = value
+		}
+	}
+
+	return rc
+}
+
+// LoadNpmrc reads and parses the .npmrc file at path or, if path is empty,
+// the .npmrc in dir if there is one. Returns nil when no .npmrc applies.
+func LoadNpmrc(fsys fs.FileSystem, path, dir string) (*Npmrc, error) {
+	if path == "" {
+		if path = filepath.Join(dir, ".npmrc"); !fsys.Exists(path) {
+			return nil, nil
+		}
+	}
+	data, err := fsys.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read .npmrc: %w", err)
+	}
+	return ParseNpmrc(data), nil
+}
@@ -17,20 +17,32 @@ along with this program. If not, see <http://www.gnu.org/licenses/>.
 
 package cdn
 
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
 // Provider represents a CDN provider with URL templates for package resolution.
 type Provider struct {
-	Name string
+	Name string `json:"name"`
 	// PackageJSONTemplate is the URL template for fetching package.json files.
 	// Variables: {package}, {version}
-	PackageJSONTemplate string
+	PackageJSONTemplate string `json:"packageJSONTemplate"`
 	// ModuleTemplate is the URL template for module URLs in the import map.
 	// Variables: {package}, {version}, {path}
-	ModuleTemplate string
+	ModuleTemplate string `json:"moduleTemplate"`
+	// SourceMapSuffix is appended to a module URL to form its source map URL,
+	// for providers that serve package files, and their maps, unmodified.
+	// Empty if the provider has no such convention.
+	SourceMapSuffix string `json:"sourceMapSuffix,omitempty"`
 }
 
 // Predefined CDN providers
 var (
-	// EsmSh is the esm.sh CDN provider.
+	// EsmSh is the esm.sh CDN provider. Its module URLs are build entry points
+	// rather than package files, so it has no source map convention.
 	EsmSh = Provider{
 		Name:                "esm.sh",
 		PackageJSONTemplate: "https://esm.sh/{package}@{version}/package.json",
@@ -42,6 +54,7 @@ var (
 		Name:                "unpkg",
 		PackageJSONTemplate: "https://unpkg.com/{package}@{version}/package.json",
 		ModuleTemplate:      "https://unpkg.com/{package}@{version}/{path}",
+		SourceMapSuffix:     ".map",
 	}
 
 	// Jsdelivr is the jsDelivr CDN provider.
@@ -49,15 +62,30 @@ var (
 		Name:                "jsdelivr",
 		PackageJSONTemplate: "https://cdn.jsdelivr.net/npm/{package}@{version}/package.json",
 		ModuleTemplate:      "https://cdn.jsdelivr.net/npm/{package}@{version}/{path}",
+		SourceMapSuffix:     ".map",
 	}
 )
 
 // DefaultProvider is the default CDN provider (esm.sh).
 var DefaultProvider = EsmSh
 
-// ProviderByName returns a CDN provider by name.
-// Returns nil if the provider name is not recognized.
-func ProviderByName(name string) *Provider {
+// ProviderByName returns a CDN provider by name, consulting custom
+// providers, such as those returned by LoadProviders, after the predefined
+// ones. Returns nil if the provider name is not recognized.
+func ProviderByName(name string, custom ...Provider) *Provider {
+	if provider := builtinProvider(name); provider != nil {
+		return provider
+	}
+	for i := range custom {
+		if custom[i].Name == name {
+			return &custom[i]
+		}
+	}
+	return nil
+}
+
+// builtinProvider returns a predefined CDN provider by name or alias.
+func builtinProvider(name string) *Provider {
 	switch name {
 	case "esm.sh", "esmsh", "esm":
 		return &EsmSh
@@ -70,13 +98,83 @@ func ProviderByName(name string) *Provider {
 	}
 }
 
-// ProviderNames returns a list of supported CDN provider names.
-func ProviderNames() []string {
-	return []string{"esm.sh", "unpkg", "jsdelivr"}
+// ProviderNames returns a list of supported CDN provider names: the
+// predefined providers, then the names of any custom providers in sorted
+// order.
+func ProviderNames(custom ...Provider) []string {
+	names := make([]string, 0, len(custom))
+	for _, provider := range custom {
+		names = append(names, provider.Name)
+	}
+	slices.Sort(names)
+	return append([]string{"esm.sh", "unpkg", "jsdelivr"}, names...)
+}
+
+// providerConfig is the JSON form of a provider config file.
+type providerConfig struct {
+	Providers []Provider `json:"providers"`
+}
+
+// LoadProviders parses a provider config file, returning its providers for
+// ProviderByName, for self-hosted CDNs such as an esm.sh mirror:
+//
+//	{
+//	  "providers": [{
+//	    "name": "mymirror",
+//	    "moduleTemplate": "https://esm.example.com/{package}@{version}/{path}",
+//	    "packageJSONTemplate": "https://esm.example.com/{package}@{version}/package.json"
+//	  }]
+//	}
+//
+// Both templates must contain {package} and {version}, and the module
+// template {path}. A provider may not take the name or alias of a predefined
+// one, nor share its name with another in the file.
+func LoadProviders(data []byte) ([]Provider, error) {
+	var config providerConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid provider config: %w", err)
+	}
+
+	seen := make(map[string]bool, len(config.Providers))
+	for _, provider := range config.Providers {
+		if err := validateProvider(provider); err != nil {
+			return nil, err
+		}
+		if seen[provider.Name] {
+			return nil, fmt.Errorf("provider %q is defined more than once", provider.Name)
+		}
+		seen[provider.Name] = true
+	}
+	return config.Providers, nil
+}
+
+// validateProvider checks that a configured provider has a free name and
+// usable templates.
+func validateProvider(provider Provider) error {
+	if provider.Name == "" {
+		return fmt.Errorf("provider config entry has no name")
+	}
+	if builtinProvider(provider.Name) != nil {
+		return fmt.Errorf("provider %q conflicts with a predefined provider", provider.Name)
+	}
+	for _, field := range []struct {
+		name, template string
+		variables      []string
+	}{
+		{"moduleTemplate", provider.ModuleTemplate, []string{"{package}", "{version}", "{path}"}},
+		{"packageJSONTemplate", provider.PackageJSONTemplate, []string{"{package}", "{version}"}},
+	} {
+		for _, variable := range field.variables {
+			if !strings.Contains(field.template, variable) {
+				return fmt.Errorf("provider %q: %s must contain %s", provider.Name, field.name, variable)
+			}
+		}
+	}
+	return nil
 }
 
 // IsValidProvider returns true if the provider name is recognized.
-// It honors the same aliases as ProviderByName.
-func IsValidProvider(name string) bool {
-	return ProviderByName(name) != nil
+// It honors the same aliases and custom providers as ProviderByName.
+func IsValidProvider(name string, custom ...Provider) bool {
+	return ProviderByName(name, custom...) != nil
 }
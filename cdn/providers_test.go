@@ -20,6 +20,8 @@ package cdn
 import (
 	"strings"
 	"testing"
+
+	"bennypowers.dev/mappa/testutil"
 )
 
 func TestProviderByName(t *testing.T) {
@@ -136,3 +138,66 @@ func expandTemplate(tmpl, pkg, version, path string) string {
 	result = strings.ReplaceAll(result, "{path}", path)
 	return result
 }
+
+func TestLoadProviders(t *testing.T) {
+	providers, err := LoadProviders(testutil.LoadFixtureFile(t, "providers.json"))
+	if err != nil {
+		t.Fatalf("LoadProviders failed: %v", err)
+	}
+	if len(providers) != 2 {
+		t.Fatalf("Expected 2 providers, got %d", len(providers))
+	}
+
+	if ProviderByName("mymirror") != nil {
+		t.Error("Expected loading providers not to register them globally")
+	}
+	mirror := ProviderByName("mymirror", providers...)
+	if mirror == nil {
+		t.Fatal("Expected mymirror to be found among the loaded providers")
+	}
+	if got := expandTemplate(mirror.ModuleTemplate, "lit", "3.0.0", "index.js"); got != "https://esm.internal.example.com/lit@3.0.0/index.js" {
+		t.Errorf("ModuleTemplate expansion = %q", got)
+	}
+	if got := ProviderByName("files-mirror", providers...); got == nil || got.SourceMapSuffix != ".map" {
+		t.Errorf("Expected files-mirror with source map suffix, got %+v", got)
+	}
+	if !IsValidProvider("mymirror", providers...) {
+		t.Error("Expected mymirror to be valid")
+	}
+	if got := ProviderByName("esm.sh", providers...); got.Name != "esm.sh" {
+		t.Errorf("Expected predefined esm.sh to be unaffected, got %q", got.Name)
+	}
+
+	names := ProviderNames(providers...)
+	want := []string{"esm.sh", "unpkg", "jsdelivr", "files-mirror", "mymirror"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("ProviderNames() = %v, want %v", names, want)
+	}
+}
+
+func TestLoadProvidersInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{"malformed", `{"providers": [`, "invalid provider config"},
+		{"missing name", `{"providers": [{"moduleTemplate": "https://x/{package}@{version}/{path}", "packageJSONTemplate": "https://x/{package}@{version}/package.json"}]}`, "no name"},
+		{"predefined alias", `{"providers": [{"name": "esm", "moduleTemplate": "https://x/{package}@{version}/{path}", "packageJSONTemplate": "https://x/{package}@{version}/package.json"}]}`, "conflicts with a predefined provider"},
+		{"module template without path", `{"providers": [{"name": "x", "moduleTemplate": "https://x/{package}@{version}", "packageJSONTemplate": "https://x/{package}@{version}/package.json"}]}`, "moduleTemplate must contain {path}"},
+		{"package.json template without version", `{"providers": [{"name": "x", "moduleTemplate": "https://x/{package}@{version}/{path}", "packageJSONTemplate": "https://x/{package}/package.json"}]}`, "packageJSONTemplate must contain {version}"},
+		{"duplicate", `{"providers": [{"name": "x", "moduleTemplate": "https://x/{package}@{version}/{path}", "packageJSONTemplate": "https://x/{package}@{version}/package.json"}, {"name": "x", "moduleTemplate": "https://y/{package}@{version}/{path}", "packageJSONTemplate": "https://y/{package}@{version}/package.json"}]}`, "defined more than once"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			providers, err := LoadProviders([]byte(tt.config))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+			if providers != nil {
+				t.Errorf("Expected no providers from an invalid config, got %v", providers)
+			}
+		})
+	}
+}
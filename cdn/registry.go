@@ -21,19 +21,32 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"regexp"
+	"maps"
 	"slices"
 	"sort"
-	"strconv"
 	"strings"
 	"sync"
+	"time"
+
+	"bennypowers.dev/mappa/internal/semver"
 )
 
 // Registry provides access to the npm registry for package metadata.
 type Registry struct {
-	fetcher      Fetcher
-	baseURL      string
-	versionCache *VersionCache
+	fetcher         Fetcher
+	baseURL         string
+	scopeRegistries map[string]string // "@scope" -> registry URL
+	stableOnly      bool              // Never resolve to prerelease versions
+	asOf            time.Time         // Only resolve to versions published by then, if set
+	versionCache    *VersionCache
+	dists           *sync.Map // name@version -> RegistryDist, for resolved versions
+	logger          Logger
+}
+
+// Logger receives warnings from the registry client, such as deprecated
+// versions being resolved. resolve.Logger satisfies it.
+type Logger interface {
+	Warning(format string, args ...any)
 }
 
 // RegistryPackage represents package metadata from the npm registry.
@@ -41,12 +54,29 @@ type RegistryPackage struct {
 	Name     string                    `json:"name"`
 	DistTags map[string]string         `json:"dist-tags"`
 	Versions map[string]RegistryVersion `json:"versions"`
+	// Time maps each version to its RFC 3339 publish time, alongside the
+	// "created" and "modified" times of the package.
+	Time map[string]string `json:"time,omitempty"`
 }
 
 // RegistryVersion represents a specific version's metadata.
 type RegistryVersion struct {
 	Version      string            `json:"version"`
 	Dependencies map[string]string `json:"dependencies"`
+	// Deprecated holds the deprecation message, if the version is deprecated.
+	Deprecated string `json:"deprecated,omitempty"`
+	// Dist describes the version's published tarball.
+	Dist RegistryDist `json:"dist"`
+}
+
+// RegistryDist describes a published package tarball.
+type RegistryDist struct {
+	// Tarball is the URL the tarball is downloaded from.
+	Tarball string `json:"tarball,omitempty"`
+	// Integrity is the tarball's subresource integrity hash, e.g. "sha512-...".
+	Integrity string `json:"integrity,omitempty"`
+	// Shasum is the tarball's hex SHA-1, published for older clients.
+	Shasum string `json:"shasum,omitempty"`
 }
 
 // VersionCache caches resolved versions to avoid repeated registry lookups.
@@ -64,6 +94,7 @@ func NewRegistry(fetcher Fetcher) *Registry {
 		fetcher:      fetcher,
 		baseURL:      "https://registry.npmjs.org",
 		versionCache: NewVersionCache(),
+		dists:        &sync.Map{},
 	}
 }
 
@@ -73,9 +104,139 @@ func NewRegistryWithURL(fetcher Fetcher, baseURL string) *Registry {
 		fetcher:      fetcher,
 		baseURL:      strings.TrimSuffix(baseURL, "/"),
 		versionCache: NewVersionCache(),
+		dists:        &sync.Map{},
+	}
+}
+
+// WithScopeRegistry returns a new Registry that fetches packages in scope
+// (e.g., "@myorg") from registryURL instead of the default registry.
+// The version cache is shared with the original Registry unless the scope's
+// registry changes, since another registry may publish different versions.
+func (r *Registry) WithScopeRegistry(scope, registryURL string) *Registry {
+	if !strings.HasPrefix(scope, "@") {
+		scope = "@" + scope
+	}
+	registryURL = strings.TrimSuffix(registryURL, "/")
+	versionCache, dists := r.versionCache, r.dists
+	if registryURL != r.registryURL(scope+"/") {
+		versionCache, dists = NewVersionCache(), &sync.Map{}
+	}
+	scopeRegistries := maps.Clone(r.scopeRegistries)
+	if scopeRegistries == nil {
+		scopeRegistries = make(map[string]string)
+	}
+	scopeRegistries[scope] = registryURL
+	return &Registry{
+		fetcher:         r.fetcher,
+		baseURL:         r.baseURL,
+		scopeRegistries: scopeRegistries,
+		stableOnly:      r.stableOnly,
+		asOf:            r.asOf,
+		versionCache:    versionCache,
+		dists:           dists,
+		logger:          r.logger,
+	}
+}
+
+// WithNpmrc returns a new Registry configured from parsed .npmrc settings:
+// the default registry, if set, replaces the base URL, and each scoped
+// registry is added as with WithScopeRegistry.
+func (r *Registry) WithNpmrc(rc *Npmrc) *Registry {
+	result := &Registry{
+		fetcher:         r.fetcher,
+		baseURL:         r.baseURL,
+		scopeRegistries: r.scopeRegistries,
+		stableOnly:      r.stableOnly,
+		asOf:            r.asOf,
+		versionCache:    r.versionCache,
+		dists:           r.dists,
+		logger:          r.logger,
+	}
+	if rc == nil {
+		return result
+	}
+	if registryURL := strings.TrimSuffix(rc.Registry, "/"); registryURL != "" && registryURL != result.baseURL {
+		result.baseURL = registryURL
+		result.versionCache = NewVersionCache()
+		result.dists = &sync.Map{}
+	}
+	for scope, registryURL := range rc.ScopeRegistries {
+		result = result.WithScopeRegistry(scope, registryURL)
+	}
+	return result
+}
+
+// WithStableOnly returns a new Registry that, when stableOnly is true, never
+// resolves to a prerelease version, even when a range or dist-tag permits one.
+// Resolution fails if only prerelease versions satisfy a range. Changing the
+// mode gives the new Registry its own version cache.
+func (r *Registry) WithStableOnly(stableOnly bool) *Registry {
+	versionCache := r.versionCache
+	if stableOnly != r.stableOnly {
+		versionCache = NewVersionCache()
+	}
+	return &Registry{
+		fetcher:         r.fetcher,
+		baseURL:         r.baseURL,
+		scopeRegistries: r.scopeRegistries,
+		stableOnly:      stableOnly,
+		asOf:            r.asOf,
+		versionCache:    versionCache,
+		dists:           r.dists,
+		logger:          r.logger,
+	}
+}
+
+// WithAsOf returns a new Registry that resolves each range to the highest
+// satisfying version published at or before asOf, using the registry's
+// per-version publish times, for reproducing historical builds. Dist-tags
+// pointing at later versions are ignored, so "latest" means the highest
+// release published by then. A zero asOf disables the cutoff. Changing the
+// cutoff gives the new Registry its own version cache.
+func (r *Registry) WithAsOf(asOf time.Time) *Registry {
+	versionCache := r.versionCache
+	if !asOf.Equal(r.asOf) {
+		versionCache = NewVersionCache()
+	}
+	return &Registry{
+		fetcher:         r.fetcher,
+		baseURL:         r.baseURL,
+		scopeRegistries: r.scopeRegistries,
+		stableOnly:      r.stableOnly,
+		asOf:            asOf,
+		versionCache:    versionCache,
+		dists:           r.dists,
+		logger:          r.logger,
+	}
+}
+
+// WithLogger returns a new Registry that warns through logger when a range
+// resolves to a deprecated version. The version cache is shared with the
+// original Registry, so each resolution is reported once.
+func (r *Registry) WithLogger(logger Logger) *Registry {
+	return &Registry{
+		fetcher:         r.fetcher,
+		baseURL:         r.baseURL,
+		scopeRegistries: r.scopeRegistries,
+		stableOnly:      r.stableOnly,
+		asOf:            r.asOf,
+		versionCache:    r.versionCache,
+		dists:           r.dists,
+		logger:          logger,
 	}
 }
 
+// registryURL returns the registry base URL for pkgName, honoring scope overrides.
+func (r *Registry) registryURL(pkgName string) string {
+	if strings.HasPrefix(pkgName, "@") {
+		scope, _, _ := strings.Cut(pkgName, "/")
+		if registryURL, ok := r.scopeRegistries[scope]; ok {
+			return registryURL
+		}
+	}
+	return r.baseURL
+}
+
 // NewVersionCache creates a new version cache with default max size.
 func NewVersionCache() *VersionCache {
 	return NewVersionCacheWithSize(1000)
@@ -133,6 +294,7 @@ func (c *VersionCache) Set(pkgName, versionRange, resolvedVersion string) {
 }
 
 // ResolveVersion resolves a semver range to a specific version.
+// If the resolved version is deprecated, its message is logged as a warning.
 func (r *Registry) ResolveVersion(ctx context.Context, pkgName, versionRange string) (string, error) {
 	// Check cache first
 	if cached, ok := r.versionCache.Get(pkgName, versionRange); ok {
@@ -140,7 +302,7 @@ func (r *Registry) ResolveVersion(ctx context.Context, pkgName, versionRange str
 	}
 
 	// Fetch package metadata from registry
-	url := fmt.Sprintf("%s/%s", r.baseURL, pkgName)
+	url := fmt.Sprintf("%s/%s", r.registryURL(pkgName), pkgName)
 	data, err := r.fetcher.Fetch(ctx, url)
 	if err != nil {
 		return "", fmt.Errorf("failed to fetch package %s: %w", pkgName, err)
@@ -152,11 +314,16 @@ func (r *Registry) ResolveVersion(ctx context.Context, pkgName, versionRange str
 	}
 
 	// Resolve the version
-	resolved, err := resolveVersionFromPackage(&pkg, versionRange)
+	resolved, err := resolveVersionFromPackage(&pkg, versionRange, r.stableOnly, r.asOf)
 	if err != nil {
 		return "", err
 	}
 
+	if deprecated := pkg.Versions[resolved].Deprecated; deprecated != "" && r.logger != nil {
+		r.logger.Warning("%s@%s is deprecated: %s", pkgName, resolved, deprecated)
+	}
+	r.recordDist(pkgName, resolved, pkg.Versions[resolved].Dist)
+
 	// Cache the result
 	r.versionCache.Set(pkgName, versionRange, resolved)
 	return resolved, nil
@@ -164,7 +331,7 @@ func (r *Registry) ResolveVersion(ctx context.Context, pkgName, versionRange str
 
 // Dependencies returns the dependencies for a specific version of a package.
 func (r *Registry) Dependencies(ctx context.Context, pkgName, version string) (map[string]string, error) {
-	url := fmt.Sprintf("%s/%s/%s", r.baseURL, pkgName, version)
+	url := fmt.Sprintf("%s/%s/%s", r.registryURL(pkgName), pkgName, version)
 	data, err := r.fetcher.Fetch(ctx, url)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch package %s@%s: %w", pkgName, version, err)
@@ -174,19 +341,58 @@ func (r *Registry) Dependencies(ctx context.Context, pkgName, version string) (m
 	if err := json.Unmarshal(data, &ver); err != nil {
 		return nil, fmt.Errorf("failed to parse version metadata: %w", err)
 	}
+	r.recordDist(pkgName, version, ver.Dist)
 
 	return ver.Dependencies, nil
 }
 
+// Dist returns the tarball metadata of pkgName@version, if that version was
+// resolved or its dependencies looked up through this Registry or one built
+// from it.
+func (r *Registry) Dist(pkgName, version string) (RegistryDist, bool) {
+	if r.dists == nil {
+		return RegistryDist{}, false
+	}
+	dist, ok := r.dists.Load(pkgName + "@" + version)
+	if !ok {
+		return RegistryDist{}, false
+	}
+	return dist.(RegistryDist), true
+}
+
+// recordDist remembers the tarball metadata of pkgName@version for Dist.
+func (r *Registry) recordDist(pkgName, version string, dist RegistryDist) {
+	if r.dists == nil || dist == (RegistryDist{}) {
+		return
+	}
+	r.dists.Store(pkgName+"@"+version, dist)
+}
+
 // resolveVersionFromPackage resolves a version range from package metadata.
-func resolveVersionFromPackage(pkg *RegistryPackage, versionRange string) (string, error) {
+// When stableOnly is true, prerelease versions are never returned. When asOf
+// is set, only versions published at or before it are considered.
+func resolveVersionFromPackage(pkg *RegistryPackage, versionRange string, stableOnly bool, asOf time.Time) (string, error) {
+	if !asOf.IsZero() {
+		published, err := pkg.publishedBy(asOf)
+		if err != nil {
+			return "", err
+		}
+		pkg = published
+	}
+
 	// Handle dist-tags (latest, next, etc.)
 	if tag, ok := pkg.DistTags[versionRange]; ok {
+		if stableOnly && semver.IsPrerelease(tag) {
+			return "", fmt.Errorf("dist-tag %q of package %s is prerelease %s, which stable-only mode forbids", versionRange, pkg.Name, tag)
+		}
 		return tag, nil
 	}
 
 	// Handle exact version
 	if _, ok := pkg.Versions[versionRange]; ok {
+		if stableOnly && semver.IsPrerelease(versionRange) {
+			return "", fmt.Errorf("version %s of package %s is a prerelease, which stable-only mode forbids", versionRange, pkg.Name)
+		}
 		return versionRange, nil
 	}
 
@@ -196,9 +402,20 @@ func resolveVersionFromPackage(pkg *RegistryPackage, versionRange string) (strin
 		versions = append(versions, v)
 	}
 	sort.Slice(versions, func(i, j int) bool {
-		return compareSemver(versions[i], versions[j]) < 0
+		return semver.Compare(versions[i], versions[j]) < 0
 	})
 
+	if stableOnly {
+		stable := slices.DeleteFunc(slices.Clone(versions), semver.IsPrerelease)
+		if matched := matchVersion(stable, versionRange); matched != "" {
+			return matched, nil
+		}
+		if prerelease := matchVersion(versions, versionRange); prerelease != "" {
+			return "", fmt.Errorf("only prerelease versions (e.g. %s) match %q for package %s, which stable-only mode forbids", prerelease, versionRange, pkg.Name)
+		}
+		return "", fmt.Errorf("no version matching %q found for package %s", versionRange, pkg.Name)
+	}
+
 	// Find the best matching version
 	matched := matchVersion(versions, versionRange)
 	if matched == "" {
@@ -208,139 +425,49 @@ func resolveVersionFromPackage(pkg *RegistryPackage, versionRange string) (strin
 	return matched, nil
 }
 
-// SemVer represents a parsed semantic version.
-type SemVer struct {
-	Major      int
-	Minor      int
-	Patch      int
-	Prerelease string
-}
-
-var semverPattern = regexp.MustCompile(`^v?(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:-(.+))?$`)
-
-// parseSemver parses a semantic version string.
-func parseSemver(version string) (*SemVer, error) {
-	matches := semverPattern.FindStringSubmatch(version)
-	if matches == nil {
-		return nil, fmt.Errorf("invalid semver: %s", version)
-	}
-
-	sv := &SemVer{}
-	sv.Major, _ = strconv.Atoi(matches[1])
-	if matches[2] != "" {
-		sv.Minor, _ = strconv.Atoi(matches[2])
-	}
-	if matches[3] != "" {
-		sv.Patch, _ = strconv.Atoi(matches[3])
-	}
-	sv.Prerelease = matches[4]
-
-	return sv, nil
-}
-
-// compareSemver compares two semver strings.
-// Returns -1 if a < b, 0 if a == b, 1 if a > b.
-// Returns 0 if either version cannot be parsed.
-func compareSemver(a, b string) int {
-	av, err := parseSemver(a)
-	if err != nil {
-		return 0
-	}
-	bv, err := parseSemver(b)
-	if err != nil {
-		return 0
+// publishedBy returns a copy of pkg holding only the versions published at or
+// before asOf, and the dist-tags pointing at them. Versions without a publish
+// time are left out, since they cannot be shown to predate asOf.
+func (pkg *RegistryPackage) publishedBy(asOf time.Time) (*RegistryPackage, error) {
+	if len(pkg.Time) == 0 {
+		return nil, fmt.Errorf("registry metadata for package %s has no publish times to resolve as of %s", pkg.Name, asOf.Format(time.RFC3339))
 	}
 
-	if av.Major != bv.Major {
-		if av.Major < bv.Major {
-			return -1
-		}
-		return 1
+	published := &RegistryPackage{
+		Name:     pkg.Name,
+		DistTags: make(map[string]string),
+		Versions: make(map[string]RegistryVersion),
+		Time:     pkg.Time,
 	}
-	if av.Minor != bv.Minor {
-		if av.Minor < bv.Minor {
-			return -1
+	for version, meta := range pkg.Versions {
+		publishedAt, err := time.Parse(time.RFC3339, pkg.Time[version])
+		if err != nil || publishedAt.After(asOf) {
+			continue
 		}
-		return 1
+		published.Versions[version] = meta
 	}
-	if av.Patch != bv.Patch {
-		if av.Patch < bv.Patch {
-			return -1
+	for tag, version := range pkg.DistTags {
+		if _, ok := published.Versions[version]; ok {
+			published.DistTags[tag] = version
 		}
-		return 1
-	}
-
-	// Prerelease versions are lower precedence than release versions
-	if av.Prerelease != "" && bv.Prerelease == "" {
-		return -1
 	}
-	if av.Prerelease == "" && bv.Prerelease != "" {
-		return 1
+	if len(published.Versions) == 0 {
+		return nil, fmt.Errorf("no version of package %s was published by %s", pkg.Name, asOf.Format(time.RFC3339))
 	}
-	if av.Prerelease != bv.Prerelease {
-		return comparePrereleases(av.Prerelease, bv.Prerelease)
-	}
-
-	return 0
+	return published, nil
 }
 
-// comparePrereleases compares two prerelease strings according to SemVer 2.0.0.
-// Identifiers are split by '.' and compared: numeric identifiers are compared as integers,
-// non-numeric identifiers are compared lexically, numeric has lower precedence than non-numeric,
-// and shorter identifier lists have lower precedence when all preceding identifiers are equal.
-func comparePrereleases(a, b string) int {
-	aParts := strings.Split(a, ".")
-	bParts := strings.Split(b, ".")
-
-	for i := 0; i < len(aParts) && i < len(bParts); i++ {
-		aPart := aParts[i]
-		bPart := bParts[i]
-
-		aNum, aIsNum := parseNum(aPart)
-		bNum, bIsNum := parseNum(bPart)
-
-		if aIsNum && bIsNum {
-			// Both numeric: compare as integers
-			if aNum != bNum {
-				if aNum < bNum {
-					return -1
-				}
-				return 1
-			}
-		} else if aIsNum {
-			// Numeric has lower precedence than non-numeric
-			return -1
-		} else if bIsNum {
-			return 1
-		} else {
-			// Both non-numeric: compare lexically
-			if aPart != bPart {
-				if aPart < bPart {
-					return -1
-				}
-				return 1
-			}
-		}
-	}
-
-	// Shorter list has lower precedence
-	if len(aParts) != len(bParts) {
-		if len(aParts) < len(bParts) {
-			return -1
-		}
-		return 1
+// CompareVersions compares two semver strings, such as release tags, which
+// may have a "v" prefix. Returns -1 if a < b, 0 if a == b, 1 if a > b, or an
+// error if either version cannot be parsed.
+func CompareVersions(a, b string) (int, error) {
+	if _, err := semver.Parse(a); err != nil {
+		return 0, err
 	}
-
-	return 0
-}
-
-// parseNum attempts to parse a string as a non-negative integer.
-func parseNum(s string) (int, bool) {
-	n, err := strconv.Atoi(s)
-	if err != nil || n < 0 {
-		return 0, false
+	if _, err := semver.Parse(b); err != nil {
+		return 0, err
 	}
-	return n, true
+	return semver.Compare(a, b), nil
 }
 
 // matchVersion finds the best version matching a semver range.
@@ -351,7 +478,7 @@ func matchVersion(versions []string, versionRange string) string {
 	if versionRange == "latest" || versionRange == "" || versionRange == "*" {
 		// Return highest non-prerelease version
 		for i := len(versions) - 1; i >= 0; i-- {
-			sv, err := parseSemver(versions[i])
+			sv, err := semver.Parse(versions[i])
 			if err == nil && sv.Prerelease == "" {
 				return versions[i]
 			}
@@ -367,49 +494,6 @@ func matchVersion(versions []string, versionRange string) string {
 		return matchOrRange(versions, versionRange)
 	}
 
-	// Handle caret ranges (^1.2.3)
-	if base, ok := strings.CutPrefix(versionRange, "^"); ok {
-		return matchCaretRange(versions, base)
-	}
-
-	// Handle tilde ranges (~1.2.3)
-	if base, ok := strings.CutPrefix(versionRange, "~"); ok {
-		return matchTildeRange(versions, base)
-	}
-
-	// Handle >= ranges
-	if base, ok := strings.CutPrefix(versionRange, ">="); ok {
-		return matchGteRange(versions, base)
-	}
-
-	// Handle > ranges
-	if base, ok := strings.CutPrefix(versionRange, ">"); ok {
-		return matchGtRange(versions, base)
-	}
-
-	// Handle <= ranges
-	if base, ok := strings.CutPrefix(versionRange, "<="); ok {
-		return matchLteRange(versions, base)
-	}
-
-	// Handle < ranges
-	if base, ok := strings.CutPrefix(versionRange, "<"); ok {
-		return matchLtRange(versions, base)
-	}
-
-	// Handle exact version with = prefix
-	if exact, ok := strings.CutPrefix(versionRange, "="); ok {
-		if slices.Contains(versions, exact) {
-			return exact
-		}
-		return ""
-	}
-
-	// Handle x-ranges (1.x, 1.2.x)
-	if strings.Contains(versionRange, "x") || strings.Contains(versionRange, "X") {
-		return matchXRange(versions, versionRange)
-	}
-
 	// Handle hyphen ranges (1.0.0 - 2.0.0)
 	// Use precise pattern: split and verify we get exactly 2 version-like parts
 	if strings.Contains(versionRange, " - ") {
@@ -426,225 +510,13 @@ func matchVersion(versions []string, versionRange string) string {
 		}
 	}
 
-	// Handle space-separated ranges (intersection)
-	if strings.Contains(versionRange, " ") && !strings.Contains(versionRange, "||") {
-		parts := strings.Fields(versionRange)
-		candidates := versions
-		for _, part := range parts {
-			var filtered []string
-			for _, v := range candidates {
-				if versionSatisfies(v, part) {
-					filtered = append(filtered, v)
-				}
-			}
-			candidates = filtered
-		}
-		if len(candidates) > 0 {
-			return candidates[len(candidates)-1]
-		}
-		return ""
-	}
-
-	// Treat as exact version
-	if slices.Contains(versions, versionRange) {
-		return versionRange
-	}
-
-	return ""
-}
-
-// matchCaretRange matches versions for ^major.minor.patch
-// Allows changes that do not modify the left-most non-zero element.
-func matchCaretRange(versions []string, baseVersion string) string {
-	base, err := parseSemver(baseVersion)
-	if err != nil {
-		return ""
-	}
-
-	var matches []string
-	for _, v := range versions {
-		sv, err := parseSemver(v)
-		if err != nil || sv.Prerelease != "" {
-			continue
-		}
-
-		// ^0.0.x - only matches 0.0.x
-		if base.Major == 0 && base.Minor == 0 {
-			if sv.Major == 0 && sv.Minor == 0 && sv.Patch >= base.Patch {
-				matches = append(matches, v)
-			}
-			continue
-		}
-
-		// ^0.x.y - allows 0.x.z where z >= y (same minor, patch can increase)
-		if base.Major == 0 {
-			if sv.Major == 0 && sv.Minor == base.Minor && sv.Patch >= base.Patch {
-				matches = append(matches, v)
-			}
-			continue
-		}
-
-		// ^x.y.z - allows x.*.* where major is same
-		if sv.Major == base.Major && compareSemver(v, baseVersion) >= 0 {
-			matches = append(matches, v)
-		}
-	}
-
-	if len(matches) > 0 {
-		return matches[len(matches)-1]
-	}
-	return ""
-}
-
-// matchTildeRange matches versions for ~major.minor.patch
-// Allows patch-level changes.
-func matchTildeRange(versions []string, baseVersion string) string {
-	base, err := parseSemver(baseVersion)
+	// Everything else is an intersection of one or more comparators,
+	// e.g. "^1.2.3", "1.x" or ">=1.2.0 <2.0.0 ^1.5.0"
+	c, err := semver.ParseConstraint(versionRange)
 	if err != nil {
 		return ""
 	}
-
-	var matches []string
-	for _, v := range versions {
-		sv, err := parseSemver(v)
-		if err != nil || sv.Prerelease != "" {
-			continue
-		}
-
-		// Must match major.minor, patch can be >=
-		if sv.Major == base.Major && sv.Minor == base.Minor && sv.Patch >= base.Patch {
-			matches = append(matches, v)
-		}
-	}
-
-	if len(matches) > 0 {
-		return matches[len(matches)-1]
-	}
-	return ""
-}
-
-// matchGteRange matches versions >= a given version.
-func matchGteRange(versions []string, baseVersion string) string {
-	baseVersion = strings.TrimSpace(baseVersion)
-	var matches []string
-	for _, v := range versions {
-		sv, err := parseSemver(v)
-		if err != nil || sv.Prerelease != "" {
-			continue
-		}
-		if compareSemver(v, baseVersion) >= 0 {
-			matches = append(matches, v)
-		}
-	}
-	if len(matches) > 0 {
-		return matches[len(matches)-1]
-	}
-	return ""
-}
-
-// matchGtRange matches versions > a given version.
-func matchGtRange(versions []string, baseVersion string) string {
-	baseVersion = strings.TrimSpace(baseVersion)
-	var matches []string
-	for _, v := range versions {
-		sv, err := parseSemver(v)
-		if err != nil || sv.Prerelease != "" {
-			continue
-		}
-		if compareSemver(v, baseVersion) > 0 {
-			matches = append(matches, v)
-		}
-	}
-	if len(matches) > 0 {
-		return matches[len(matches)-1]
-	}
-	return ""
-}
-
-// matchLteRange matches versions <= a given version.
-func matchLteRange(versions []string, baseVersion string) string {
-	baseVersion = strings.TrimSpace(baseVersion)
-	var matches []string
-	for _, v := range versions {
-		sv, err := parseSemver(v)
-		if err != nil || sv.Prerelease != "" {
-			continue
-		}
-		if compareSemver(v, baseVersion) <= 0 {
-			matches = append(matches, v)
-		}
-	}
-	if len(matches) > 0 {
-		return matches[len(matches)-1]
-	}
-	return ""
-}
-
-// matchLtRange matches versions < a given version.
-func matchLtRange(versions []string, baseVersion string) string {
-	baseVersion = strings.TrimSpace(baseVersion)
-	var matches []string
-	for _, v := range versions {
-		sv, err := parseSemver(v)
-		if err != nil || sv.Prerelease != "" {
-			continue
-		}
-		if compareSemver(v, baseVersion) < 0 {
-			matches = append(matches, v)
-		}
-	}
-	if len(matches) > 0 {
-		return matches[len(matches)-1]
-	}
-	return ""
-}
-
-// matchXRange matches x-range versions (1.x, 1.2.x)
-func matchXRange(versions []string, pattern string) string {
-	pattern = strings.ToLower(pattern)
-	parts := strings.Split(pattern, ".")
-
-	var matches []string
-	for _, v := range versions {
-		sv, err := parseSemver(v)
-		if err != nil || sv.Prerelease != "" {
-			continue
-		}
-
-		match := true
-		for i, part := range parts {
-			if part == "x" || part == "*" {
-				continue
-			}
-			val, err := strconv.Atoi(part)
-			if err != nil {
-				match = false
-				break
-			}
-			switch i {
-			case 0:
-				if sv.Major != val {
-					match = false
-				}
-			case 1:
-				if sv.Minor != val {
-					match = false
-				}
-			case 2:
-				if sv.Patch != val {
-					match = false
-				}
-			}
-		}
-		if match {
-			matches = append(matches, v)
-		}
-	}
-
-	if len(matches) > 0 {
-		return matches[len(matches)-1]
-	}
-	return ""
+	return c.Highest(versions)
 }
 
 // matchHyphenRange matches hyphen ranges (1.0.0 - 2.0.0)
@@ -653,23 +525,11 @@ func matchHyphenRange(versions []string, rangeStr string) string {
 	if len(parts) != 2 {
 		return ""
 	}
-	lower := strings.TrimSpace(parts[0])
-	upper := strings.TrimSpace(parts[1])
-
-	var matches []string
-	for _, v := range versions {
-		sv, err := parseSemver(v)
-		if err != nil || sv.Prerelease != "" {
-			continue
-		}
-		if compareSemver(v, lower) >= 0 && compareSemver(v, upper) <= 0 {
-			matches = append(matches, v)
-		}
-	}
-	if len(matches) > 0 {
-		return matches[len(matches)-1]
+	c, err := semver.ParseConstraint(">=" + strings.TrimSpace(parts[0]) + " <=" + strings.TrimSpace(parts[1]))
+	if err != nil {
+		return ""
 	}
-	return ""
+	return c.Highest(versions)
 }
 
 // matchOrRange matches || separated ranges
@@ -685,41 +545,9 @@ func matchOrRange(versions []string, rangeStr string) string {
 	if len(allMatches) > 0 {
 		// Return the highest matching version
 		sort.Slice(allMatches, func(i, j int) bool {
-			return compareSemver(allMatches[i], allMatches[j]) < 0
+			return semver.Compare(allMatches[i], allMatches[j]) < 0
 		})
 		return allMatches[len(allMatches)-1]
 	}
 	return ""
 }
-
-// versionSatisfies checks if a version satisfies a simple constraint.
-func versionSatisfies(version, constraint string) bool {
-	constraint = strings.TrimSpace(constraint)
-	if constraint == "" || constraint == "*" {
-		return true
-	}
-
-	if base, ok := strings.CutPrefix(constraint, ">="); ok {
-		return compareSemver(version, strings.TrimSpace(base)) >= 0
-	}
-	if base, ok := strings.CutPrefix(constraint, ">"); ok {
-		return compareSemver(version, strings.TrimSpace(base)) > 0
-	}
-	if base, ok := strings.CutPrefix(constraint, "<="); ok {
-		return compareSemver(version, strings.TrimSpace(base)) <= 0
-	}
-	if base, ok := strings.CutPrefix(constraint, "<"); ok {
-		return compareSemver(version, strings.TrimSpace(base)) < 0
-	}
-	if base, ok := strings.CutPrefix(constraint, "^"); ok {
-		return matchCaretRange([]string{version}, base) != ""
-	}
-	if base, ok := strings.CutPrefix(constraint, "~"); ok {
-		return matchTildeRange([]string{version}, base) != ""
-	}
-	if base, ok := strings.CutPrefix(constraint, "="); ok {
-		return version == strings.TrimSpace(base)
-	}
-
-	return version == constraint
-}
@@ -20,100 +20,24 @@ package cdn
 import (
 	"context"
 	"fmt"
+	"maps"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"bennypowers.dev/mappa/testutil"
 )
 
-// MockFetcher is a test implementation of the Fetcher interface.
-type MockFetcher struct {
-	responses map[string][]byte
-	errors    map[string]error
-}
-
-func NewMockFetcher() *MockFetcher {
-	return &MockFetcher{
-		responses: make(map[string][]byte),
-		errors:    make(map[string]error),
-	}
-}
-
-func (m *MockFetcher) AddResponse(url string, data []byte) {
-	m.responses[url] = data
-}
-
-func (m *MockFetcher) AddError(url string, err error) {
-	m.errors[url] = err
-}
-
-func (m *MockFetcher) Fetch(ctx context.Context, url string) ([]byte, error) {
-	if err, ok := m.errors[url]; ok {
-		return nil, err
-	}
-	if data, ok := m.responses[url]; ok {
-		return data, nil
-	}
-	return nil, &FetchError{URL: url, StatusCode: 404, Message: "Not Found"}
-}
-
-func TestParseSemver(t *testing.T) {
-	tests := []struct {
-		input   string
-		want    *SemVer
-		wantErr bool
-	}{
-		{"1.0.0", &SemVer{Major: 1, Minor: 0, Patch: 0}, false},
-		{"2.3.4", &SemVer{Major: 2, Minor: 3, Patch: 4}, false},
-		{"1.0.0-alpha", &SemVer{Major: 1, Minor: 0, Patch: 0, Prerelease: "alpha"}, false},
-		{"1.0.0-beta.1", &SemVer{Major: 1, Minor: 0, Patch: 0, Prerelease: "beta.1"}, false},
-		{"v1.0.0", &SemVer{Major: 1, Minor: 0, Patch: 0}, false},
-		{"1.0", &SemVer{Major: 1, Minor: 0, Patch: 0}, false},
-		{"1", &SemVer{Major: 1, Minor: 0, Patch: 0}, false},
-		{"invalid", nil, true},
-		{"", nil, true},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.input, func(t *testing.T) {
-			got, err := parseSemver(tt.input)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("parseSemver(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
-				return
-			}
-			if tt.want != nil {
-				if got.Major != tt.want.Major || got.Minor != tt.want.Minor ||
-					got.Patch != tt.want.Patch || got.Prerelease != tt.want.Prerelease {
-					t.Errorf("parseSemver(%q) = %+v, want %+v", tt.input, got, tt.want)
-				}
-			}
-		})
+func TestCompareVersions(t *testing.T) {
+	if got, err := CompareVersions("v0.3.0", "v0.4.1"); err != nil || got != -1 {
+		t.Errorf("CompareVersions(v0.3.0, v0.4.1) = %d, %v, want -1", got, err)
 	}
-}
-
-func TestCompareSemver(t *testing.T) {
-	tests := []struct {
-		a, b string
-		want int
-	}{
-		{"1.0.0", "1.0.0", 0},
-		{"1.0.0", "2.0.0", -1},
-		{"2.0.0", "1.0.0", 1},
-		{"1.0.0", "1.1.0", -1},
-		{"1.1.0", "1.0.0", 1},
-		{"1.0.0", "1.0.1", -1},
-		{"1.0.1", "1.0.0", 1},
-		{"1.0.0-alpha", "1.0.0", -1},
-		{"1.0.0", "1.0.0-alpha", 1},
-		{"1.0.0-alpha", "1.0.0-beta", -1},
+	if got, err := CompareVersions("v0.4.1", "0.4.1"); err != nil || got != 0 {
+		t.Errorf("CompareVersions(v0.4.1, 0.4.1) = %d, %v, want 0", got, err)
 	}
-
-	for _, tt := range tests {
-		t.Run(fmt.Sprintf("%s vs %s", tt.a, tt.b), func(t *testing.T) {
-			got := compareSemver(tt.a, tt.b)
-			if got != tt.want {
-				t.Errorf("compareSemver(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
-			}
-		})
+	if _, err := CompareVersions("dev", "v0.4.1"); err == nil {
+		t.Error("Expected an error for an unparseable version")
 	}
 }
 
@@ -141,6 +65,9 @@ func TestMatchVersion(t *testing.T) {
 		{"x-range minor", "1.0.x", "1.0.1"},
 		{"hyphen range", "1.0.0 - 1.1.0", "1.1.0"},
 		{"or range", "^1.0.0 || ^2.0.0", "2.1.0"},
+		{"tilde partial major", "~1", "1.2.0"},
+		{"tilde partial minor", "~1.1", "1.1.0"},
+		{"caret partial major", "^2", "2.1.0"},
 	}
 
 	for _, tt := range tests {
@@ -153,75 +80,102 @@ func TestMatchVersion(t *testing.T) {
 	}
 }
 
-func TestMatchCaretRange(t *testing.T) {
+func TestMatchVersionCaretAndTilde(t *testing.T) {
 	tests := []struct {
-		name     string
-		versions []string
-		base     string
-		want     string
+		name         string
+		versions     []string
+		versionRange string
+		want         string
 	}{
-		{
-			"major version constraint",
-			[]string{"1.0.0", "1.1.0", "1.2.0", "2.0.0"},
-			"1.0.0",
-			"1.2.0",
-		},
-		{
-			"zero major",
-			[]string{"0.1.0", "0.1.1", "0.2.0"},
-			"0.1.0",
-			"0.1.1",
-		},
-		{
-			"zero major zero minor",
-			[]string{"0.0.1", "0.0.2", "0.1.0"},
-			"0.0.1",
-			"0.0.2",
-		},
-		{
-			"zero major zero minor higher base",
-			[]string{"0.0.1", "0.0.2", "0.0.3", "0.1.0"},
-			"0.0.2",
-			"0.0.3",
-		},
+		{"caret major", []string{"1.0.0", "1.1.0", "1.2.0", "2.0.0"}, "^1.0.0", "1.2.0"},
+		{"caret zero major", []string{"0.1.0", "0.1.1", "0.2.0"}, "^0.1.0", "0.1.1"},
+		{"caret zero major zero minor", []string{"0.0.1", "0.0.2", "0.1.0"}, "^0.0.1", "0.0.2"},
+		{"caret zero major zero minor higher base", []string{"0.0.1", "0.0.2", "0.0.3", "0.1.0"}, "^0.0.2", "0.0.3"},
+		{"caret partial zero major", []string{"0.0.1", "0.3.0", "1.0.0"}, "^0", "0.3.0"},
+		{"caret partial zero minor", []string{"0.0.1", "0.0.4", "0.1.0"}, "^0.0", "0.0.4"},
+		{"tilde patch updates only", []string{"1.0.0", "1.0.1", "1.0.2", "1.1.0"}, "~1.0.0", "1.0.2"},
+		{"tilde no match", []string{"1.1.0", "1.2.0"}, "~1.0.0", ""},
+		{"tilde partial major allows minor updates", []string{"1.0.0", "1.4.2", "2.0.0"}, "~1", "1.4.2"},
+		{"tilde partial minor", []string{"1.2.0", "1.2.7", "1.3.0"}, "~1.2", "1.2.7"},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := matchCaretRange(tt.versions, tt.base)
-			if got != tt.want {
-				t.Errorf("matchCaretRange(%v, %q) = %q, want %q", tt.versions, tt.base, got, tt.want)
+			if got := matchVersion(tt.versions, tt.versionRange); got != tt.want {
+				t.Errorf("matchVersion(%v, %q) = %q, want %q", tt.versions, tt.versionRange, got, tt.want)
 			}
 		})
 	}
 }
 
-func TestMatchTildeRange(t *testing.T) {
+func TestMatchVersionCompoundRanges(t *testing.T) {
+	versions := []string{
+		"1.0.0", "1.2.0", "1.4.9", "1.5.0", "1.6.3", "1.9.0",
+		"2.0.0-rc.1", "2.0.0", "2.3.0", "3.0.0",
+	}
+
 	tests := []struct {
-		name     string
-		versions []string
-		base     string
-		want     string
+		name         string
+		versionRange string
+		want         string
 	}{
-		{
-			"patch updates only",
-			[]string{"1.0.0", "1.0.1", "1.0.2", "1.1.0"},
-			"1.0.0",
-			"1.0.2",
-		},
-		{
-			"no match",
-			[]string{"1.1.0", "1.2.0"},
-			"1.0.0",
-			"",
-		},
+		{"comparator pair", ">=1.2.0 <2.0.0", "1.9.0"},
+		{"comparators and caret", ">=1.2.0 <2.0.0 ^1.5.0", "1.9.0"},
+		{"caret narrowed by upper bound", "^1.5.0 <1.7.0", "1.6.3"},
+		{"caret narrowed by lower bound", "^1.0.0 >1.6.3", "1.9.0"},
+		{"tilde and lower bound", "~1.6.0 >=1.6.1", "1.6.3"},
+		{"x-range and upper bound", "1.x <1.5.0", "1.4.9"},
+		{"exclusive bounds", ">1.4.9 <1.6.3", "1.5.0"},
+		{"inclusive bounds", ">=1.4.9 <=1.6.3", "1.6.3"},
+		{"operators separated from versions", ">= 1.2.0 < 1.5.0", "1.4.9"},
+		{"upper bound excludes later majors", ">=1.5.0 <3.0.0", "2.3.0"},
+		{"prerelease allowed by comparator on its tuple", ">=2.0.0-rc.1 <2.0.0", "2.0.0-rc.1"},
+		{"prerelease excluded by release comparators", ">=1.9.0 <2.0.0", "1.9.0"},
+		{"disjoint comparators", ">=2.0.0 <1.0.0", ""},
+		{"caret and disjoint tilde", "^1.0.0 ~2.0.0", ""},
+		{"exact within bounds", "1.5.0 >=1.0.0", "1.5.0"},
+		{"exact outside bounds", "1.5.0 >=1.6.0", ""},
+		{"union of intersections", ">=1.0.0 <1.5.0 || >=2.0.0 <3.0.0", "2.3.0"},
+		{"malformed comparator", ">=1.2.0 <banana", ""},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := matchTildeRange(tt.versions, tt.base)
-			if got != tt.want {
-				t.Errorf("matchTildeRange(%v, %q) = %q, want %q", tt.versions, tt.base, got, tt.want)
+			if got := matchVersion(versions, tt.versionRange); got != tt.want {
+				t.Errorf("matchVersion(%q) = %q, want %q", tt.versionRange, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchVersionPrereleaseRanges(t *testing.T) {
+	versions := []string{
+		"0.3.0-alpha.1", "0.3.0-alpha.2",
+		"1.0.0-alpha.1", "1.0.0-beta.1", "1.0.0-beta.2", "1.0.0-rc.1",
+		"1.1.0-beta.1", "2.0.0-beta.1",
+	}
+
+	tests := []struct {
+		name         string
+		versionRange string
+		want         string
+	}{
+		{"caret beta", "^1.0.0-beta", "1.0.0-rc.1"},
+		{"caret rc", "^1.0.0-rc.1", "1.0.0-rc.1"},
+		{"caret later than every prerelease", "^1.0.0-rc.2", ""},
+		{"tilde beta", "~1.0.0-beta.1", "1.0.0-rc.1"},
+		{"caret zero minor alpha", "^0.3.0-alpha", "0.3.0-alpha.2"},
+		{"bounded beta", ">=1.0.0-beta <1.0.0-rc", "1.0.0-beta.2"},
+		{"hyphen range", "1.0.0-alpha.1 - 1.0.0-beta.2", "1.0.0-beta.2"},
+		{"union with prerelease", "^2.0.0-beta || ^1.0.0-beta", "2.0.0-beta.1"},
+		{"release range skips prereleases", "^1.0.0", ""},
+		{"prerelease of another tuple", "^1.1.0-alpha", "1.1.0-beta.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchVersion(versions, tt.versionRange); got != tt.want {
+				t.Errorf("matchVersion(%q) = %q, want %q", tt.versionRange, got, tt.want)
 			}
 		})
 	}
@@ -230,7 +184,7 @@ func TestMatchTildeRange(t *testing.T) {
 func TestRegistryResolveVersion(t *testing.T) {
 	litRegistry := testutil.LoadFixtureFile(t, "lit_registry.json")
 
-	mockFetcher := NewMockFetcher()
+	mockFetcher := NewMapFetcher(nil)
 	mockFetcher.AddResponse("https://registry.npmjs.org/lit", litRegistry)
 
 	registry := NewRegistry(mockFetcher)
@@ -262,6 +216,291 @@ func TestRegistryResolveVersion(t *testing.T) {
 	}
 }
 
+func TestRegistryScopeRegistry(t *testing.T) {
+	litRegistry := testutil.LoadFixtureFile(t, "lit_registry.json")
+	myorgRegistry := testutil.LoadFixtureFile(t, "myorg_registry.json")
+
+	mockFetcher := NewMapFetcher(nil)
+	mockFetcher.AddResponse("https://registry.npmjs.org/lit", litRegistry)
+	mockFetcher.AddResponse("https://npm.myorg.com/@myorg/ui", myorgRegistry)
+	mockFetcher.AddResponse("https://npm.myorg.com/@myorg/ui/1.2.0", []byte(`{"version":"1.2.0","dependencies":{"lit":"^3.0.0"}}`))
+
+	registry := NewRegistry(mockFetcher).WithScopeRegistry("@myorg", "https://npm.myorg.com/")
+	ctx := context.Background()
+
+	version, err := registry.ResolveVersion(ctx, "@myorg/ui", "^1.0.0")
+	if err != nil {
+		t.Fatalf("ResolveVersion(@myorg/ui) failed: %v", err)
+	}
+	if version != "1.2.0" {
+		t.Errorf("ResolveVersion(@myorg/ui) = %q, want %q", version, "1.2.0")
+	}
+
+	deps, err := registry.Dependencies(ctx, "@myorg/ui", "1.2.0")
+	if err != nil {
+		t.Fatalf("Dependencies(@myorg/ui) failed: %v", err)
+	}
+	if deps["lit"] != "^3.0.0" {
+		t.Errorf("Dependencies(@myorg/ui) = %v", deps)
+	}
+
+	// Unscoped packages still use the default registry
+	if _, err := registry.ResolveVersion(ctx, "lit", "latest"); err != nil {
+		t.Errorf("ResolveVersion(lit) failed: %v", err)
+	}
+
+	// Scopes may be given without the @ prefix
+	if got := NewRegistry(mockFetcher).WithScopeRegistry("myorg", "https://npm.myorg.com").registryURL("@myorg/ui"); got != "https://npm.myorg.com" {
+		t.Errorf("registryURL(@myorg/ui) = %q", got)
+	}
+}
+
+func TestRegistryScopeRegistryVersionCache(t *testing.T) {
+	mockFetcher := NewMapFetcher(nil)
+	mockFetcher.AddResponse("https://registry.npmjs.org/@myorg/ui", []byte(`{"name":"@myorg/ui","dist-tags":{"latest":"1.0.0"},"versions":{"1.0.0":{"version":"1.0.0"}}}`))
+	mockFetcher.AddResponse("https://npm.myorg.com/@myorg/ui", testutil.LoadFixtureFile(t, "myorg_registry.json"))
+	ctx := context.Background()
+
+	registry := NewRegistry(mockFetcher)
+	if version, err := registry.ResolveVersion(ctx, "@myorg/ui", "^1.0.0"); err != nil || version != "1.0.0" {
+		t.Fatalf("ResolveVersion(@myorg/ui) = %q, %v, want 1.0.0", version, err)
+	}
+
+	// Versions resolved against the default registry don't leak into one
+	// that fetches the scope elsewhere, whether set directly or by .npmrc
+	derived := map[string]*Registry{
+		"WithScopeRegistry": registry.WithScopeRegistry("@myorg", "https://npm.myorg.com"),
+		"WithNpmrc":         registry.WithNpmrc(&Npmrc{ScopeRegistries: map[string]string{"@myorg": "https://npm.myorg.com"}}),
+	}
+	for name, scoped := range derived {
+		if version, err := scoped.ResolveVersion(ctx, "@myorg/ui", "^1.0.0"); err != nil || version != "1.2.0" {
+			t.Errorf("%s: ResolveVersion(@myorg/ui) = %q, %v, want 1.2.0", name, version, err)
+		}
+	}
+}
+
+func TestParseNpmrc(t *testing.T) {
+	rc := ParseNpmrc(testutil.LoadFixtureFile(t, "npmrc"))
+
+	if rc.Registry != "https://registry.example.com/" {
+		t.Errorf("Registry = %q", rc.Registry)
+	}
+	expected := map[string]string{
+		"@myorg":  "https://npm.myorg.com/",
+		"@quoted": "https://npm.quoted.com",
+	}
+	if !maps.Equal(rc.ScopeRegistries, expected) {
+		t.Errorf("ScopeRegistries = %v, want %v", rc.ScopeRegistries, expected)
+	}
+
+	registry := NewRegistry(NewMapFetcher(nil)).WithNpmrc(rc)
+	tests := []struct {
+		pkgName string
+		want    string
+	}{
+		{"lit", "https://registry.example.com"},
+		{"@myorg/ui", "https://npm.myorg.com"},
+		{"@quoted/pkg", "https://npm.quoted.com"},
+		{"@other/pkg", "https://registry.example.com"},
+	}
+	for _, tt := range tests {
+		if got := registry.registryURL(tt.pkgName); got != tt.want {
+			t.Errorf("registryURL(%q) = %q, want %q", tt.pkgName, got, tt.want)
+		}
+	}
+}
+
+func TestRegistryStableOnly(t *testing.T) {
+	prereleaseRegistry := testutil.LoadFixtureFile(t, "prerelease_registry.json")
+
+	mockFetcher := NewMapFetcher(nil)
+	mockFetcher.AddResponse("https://registry.npmjs.org/next-gen", prereleaseRegistry)
+
+	ctx := context.Background()
+	registry := NewRegistry(mockFetcher)
+	stable := registry.WithStableOnly(true)
+
+	tests := []struct {
+		name         string
+		versionRange string
+		want         string
+		wantStable   string // "" means stable-only resolution fails
+	}{
+		{"stable range", "^1.0.0", "1.0.0", "1.0.0"},
+		{"any version", "*", "1.0.0", "1.0.0"},
+		{"prerelease dist-tag", "next", "2.0.0-rc.1", ""},
+		{"exact prerelease", "2.0.0-beta.1", "2.0.0-beta.1", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := registry.ResolveVersion(ctx, "next-gen", tt.versionRange)
+			if err != nil || got != tt.want {
+				t.Errorf("ResolveVersion() = %q, %v; want %q", got, err, tt.want)
+			}
+
+			got, err = stable.ResolveVersion(ctx, "next-gen", tt.versionRange)
+			if tt.wantStable == "" {
+				if err == nil {
+					t.Fatalf("Expected stable-only error, got %q", got)
+				}
+				if !strings.Contains(err.Error(), "stable-only") {
+					t.Errorf("Expected error to mention stable-only mode, got %q", err)
+				}
+				return
+			}
+			if err != nil || got != tt.wantStable {
+				t.Errorf("stable ResolveVersion() = %q, %v; want %q", got, err, tt.wantStable)
+			}
+		})
+	}
+
+	t.Run("only prereleases published", func(t *testing.T) {
+		mockFetcher.AddResponse("https://registry.npmjs.org/beta-only", testutil.LoadFixtureFile(t, "beta_only_registry.json"))
+
+		if got, err := registry.ResolveVersion(ctx, "beta-only", "*"); err != nil || got != "0.1.0-beta.2" {
+			t.Errorf("ResolveVersion() = %q, %v; want %q", got, err, "0.1.0-beta.2")
+		}
+
+		got, err := stable.ResolveVersion(ctx, "beta-only", "*")
+		if err == nil {
+			t.Fatalf("Expected stable-only error, got %q", got)
+		}
+		if !strings.Contains(err.Error(), "only prerelease versions") {
+			t.Errorf("Expected prerelease-only error, got %q", err)
+		}
+	})
+}
+
+// warningLogger records warnings logged by a Registry.
+type warningLogger struct {
+	mu       sync.Mutex
+	warnings []string
+}
+
+func (l *warningLogger) Warning(format string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.warnings = append(l.warnings, fmt.Sprintf(format, args...))
+}
+
+func TestRegistryAsOf(t *testing.T) {
+	mockFetcher := NewMapFetcher(nil)
+	mockFetcher.AddResponse("https://registry.npmjs.org/lit", testutil.LoadFixtureFile(t, "timed_registry.json"))
+
+	ctx := context.Background()
+	registry := NewRegistry(mockFetcher)
+	newYear := time.Date(2024, 1, 1, 23, 59, 59, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		asOf         time.Time
+		versionRange string
+		want         string // "" means resolution fails
+	}{
+		{"caret range today", time.Time{}, "^3.0.0", "3.2.0"},
+		{"caret range as of new year", newYear, "^3.0.0", "3.1.0"},
+		{"latest as of new year", newYear, "latest", "3.1.0"},
+		{"compound range as of new year", newYear, ">=2.0.0 <4.0.0", "3.1.0"},
+		{"exact version published later", newYear, "3.2.0", ""},
+		{"dist-tag published later", newYear, "next", ""},
+		{"publish time is inclusive", time.Date(2024, 1, 9, 21, 4, 22, 450_000_000, time.UTC), "^3.0.0", "3.1.1"},
+		{"before any release", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), "^3.0.0", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := registry.WithAsOf(tt.asOf).ResolveVersion(ctx, "lit", tt.versionRange)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("Expected resolution to fail, got %q", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("ResolveVersion(%q) = %q, %v; want %q", tt.versionRange, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistryAsOfWithoutPublishTimes(t *testing.T) {
+	mockFetcher := NewMapFetcher(nil)
+	mockFetcher.AddResponse("https://registry.npmjs.org/lit", testutil.LoadFixtureFile(t, "lit_registry.json"))
+
+	registry := NewRegistry(mockFetcher).WithAsOf(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	_, err := registry.ResolveVersion(context.Background(), "lit", "^3.0.0")
+	if err == nil || !strings.Contains(err.Error(), "no publish times") {
+		t.Errorf("Expected missing publish times error, got %v", err)
+	}
+}
+
+func TestRegistryDeprecatedVersion(t *testing.T) {
+	mockFetcher := NewMapFetcher(nil)
+	mockFetcher.AddResponse("https://registry.npmjs.org/old-lib", testutil.LoadFixtureFile(t, "deprecated_registry.json"))
+
+	logger := &warningLogger{}
+	registry := NewRegistry(mockFetcher).WithLogger(logger)
+	ctx := context.Background()
+
+	if _, err := registry.ResolveVersion(ctx, "old-lib", "^2.0.0"); err != nil {
+		t.Fatalf("ResolveVersion(^2.0.0) failed: %v", err)
+	}
+	if len(logger.warnings) != 0 {
+		t.Errorf("Expected no warnings for a maintained version, got %v", logger.warnings)
+	}
+
+	version, err := registry.ResolveVersion(ctx, "old-lib", "^1.0.0")
+	if err != nil {
+		t.Fatalf("ResolveVersion(^1.0.0) failed: %v", err)
+	}
+	if version != "1.0.0" {
+		t.Errorf("ResolveVersion(^1.0.0) = %q, want %q", version, "1.0.0")
+	}
+	if len(logger.warnings) != 1 || !strings.Contains(logger.warnings[0], "old-lib@1.0.0 is deprecated: old-lib 1.x is no longer maintained") {
+		t.Errorf("Expected deprecation warning, got %v", logger.warnings)
+	}
+
+	// Cached resolutions are not reported again
+	if _, err := registry.ResolveVersion(ctx, "old-lib", "^1.0.0"); err != nil {
+		t.Fatalf("ResolveVersion(^1.0.0) failed: %v", err)
+	}
+	if len(logger.warnings) != 1 {
+		t.Errorf("Expected one warning after a cached resolution, got %v", logger.warnings)
+	}
+}
+
+func TestRegistryDist(t *testing.T) {
+	mockFetcher := NewMapFetcher(nil)
+	mockFetcher.AddResponse("https://registry.npmjs.org/lit", testutil.LoadFixtureFile(t, "dist_registry.json"))
+
+	registry := NewRegistry(mockFetcher)
+	ctx := context.Background()
+
+	if _, ok := registry.Dist("lit", "2.0.0"); ok {
+		t.Error("Expected no dist before lit is resolved")
+	}
+
+	if _, err := registry.ResolveVersion(ctx, "lit", "^2.0.0"); err != nil {
+		t.Fatalf("ResolveVersion(^2.0.0) failed: %v", err)
+	}
+
+	// Registries derived afterwards share what was recorded
+	dist, ok := registry.WithStableOnly(true).Dist("lit", "2.0.0")
+	if !ok {
+		t.Fatal("Expected dist for lit@2.0.0 after resolving it")
+	}
+	if dist.Tarball != "https://registry.npmjs.org/lit/-/lit-2.0.0.tgz" {
+		t.Errorf("Tarball = %q", dist.Tarball)
+	}
+	if !strings.HasPrefix(dist.Integrity, "sha512-pqi5O") {
+		t.Errorf("Integrity = %q", dist.Integrity)
+	}
+	if _, ok := registry.Dist("lit", "3.0.0"); ok {
+		t.Error("Expected no dist for lit@3.0.0, which was never resolved")
+	}
+}
+
 func TestVersionCache(t *testing.T) {
 	cache := NewVersionCache()
 
@@ -0,0 +1,126 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+// Package doctor provides the doctor command for mappa.
+package doctor
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"bennypowers.dev/mappa/fs"
+	"bennypowers.dev/mappa/internal/output"
+	"bennypowers.dev/mappa/resolve/local"
+)
+
+// Cmd is the doctor cobra command that checks whether a project is ready to
+// generate an import map.
+var Cmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose a project's import map readiness",
+	Long: `Check that a project can generate an import map, and explain how to fix
+what stops it.
+
+The checks are: package.json can be parsed, node_modules exists, each
+dependency is installed with a readable package.json, and each dependency has
+an exports or main field mapping its bare specifier. Exits non-zero if any
+dependency is missing or unreadable; packages that will have no bare-specifier
+mapping, or whose main field names a missing file, are reported as warnings.`,
+	Example: `  # Check the current project
+  mappa doctor
+
+  # Check another package with production export conditions
+  mappa doctor -p packages/app --conditions production,browser,import,default
+
+  # Machine-readable results
+  mappa doctor --format json`,
+	RunE: run,
+}
+
+func init() {
+	Cmd.Flags().StringP("format", "f", "text", "Output format (text, json)")
+	Cmd.Flags().StringSlice("conditions", nil, "Export condition priority (e.g., production,browser,import,default); prefix with ! to never match a condition (e.g., !node)")
+	Cmd.Flags().Bool("allow-subpath-only", false, "Don't warn about packages that export subpaths but no main entry")
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	osfs := fs.NewOSFileSystem()
+
+	absRoot, err := filepath.Abs(viper.GetString("package"))
+	if err != nil {
+		return fmt.Errorf("invalid package directory: %w", err)
+	}
+
+	format, _ := cmd.Flags().GetString("format")
+	if format != "text" && format != "json" {
+		return fmt.Errorf("invalid format %q: must be 'text' or 'json'", format)
+	}
+
+	resolver := local.New(osfs, nil)
+	if conditions, _ := cmd.Flags().GetStringSlice("conditions"); len(conditions) > 0 {
+		resolver = resolver.WithConditions(conditions)
+	}
+	allowSubpathOnly, _ := cmd.Flags().GetBool("allow-subpath-only")
+	resolver = resolver.WithAllowSubpathOnly(allowSubpathOnly)
+
+	diagnosis := resolver.Diagnose(absRoot)
+
+	var out string
+	if format == "json" {
+		data, err := json.MarshalIndent(diagnosis, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal diagnosis: %w", err)
+		}
+		out = string(data)
+	} else {
+		out = formatText(diagnosis)
+	}
+
+	if err := output.Write(osfs, out); err != nil {
+		return err
+	}
+
+	if errs := diagnosis.Count(local.CheckError); errs > 0 {
+		// The report already explains each problem
+		cmd.SilenceUsage = true
+		return fmt.Errorf("found %d %s to fix before generating an import map", errs, plural(errs, "problem"))
+	}
+	return nil
+}
+
+// formatText renders one line per check, then a summary line.
+func formatText(d *local.Diagnosis) string {
+	var b strings.Builder
+	for _, c := range d.Checks {
+		fmt.Fprintf(&b, "%-7s %s: %s\n", c.Severity, c.Subject, c.Message)
+	}
+	errs, warnings := d.Count(local.CheckError), d.Count(local.CheckWarning)
+	fmt.Fprintf(&b, "\n%d %s, %d %s", errs, plural(errs, "error"), warnings, plural(warnings, "warning"))
+	return b.String()
+}
+
+// plural returns word, with an s appended unless n is 1.
+func plural(n int, word string) string {
+	if n == 1 {
+		return word
+	}
+	return word + "s"
+}
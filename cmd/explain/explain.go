@@ -0,0 +1,139 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+// Package explain provides the explain command for mappa.
+package explain
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"bennypowers.dev/mappa/fs"
+	"bennypowers.dev/mappa/internal/output"
+	"bennypowers.dev/mappa/resolve/local"
+)
+
+// Cmd is the explain cobra command that shows how a bare specifier resolves.
+var Cmd = &cobra.Command{
+	Use:   "explain <specifier>",
+	Short: "Show how a bare specifier resolves",
+	Long: `Show each step of resolving a bare specifier against the installed
+packages: the package.json consulted, the exports key or pattern and the
+export conditions that matched, any fallback taken, and the resulting file and
+import map URL.
+
+When the specifier doesn't resolve to a file, explain says why, for example
+that the package isn't installed, that no export condition matched, or that
+the file doesn't exist, and exits non-zero.`,
+	Example: `  # Explain a subpath import
+  mappa explain lit/decorators.js
+
+  # Explain with production export conditions
+  mappa explain @myorg/core --conditions production,browser,import,default
+
+  # Machine-readable steps
+  mappa explain lit --format json`,
+	Args: cobra.ExactArgs(1),
+	RunE: run,
+}
+
+func init() {
+	Cmd.Flags().StringP("format", "f", "text", "Output format (text, json)")
+	Cmd.Flags().StringSlice("conditions", nil, "Export condition priority (e.g., production,browser,import,default); prefix with ! to never match a condition (e.g., !node)")
+	Cmd.Flags().String("template", "", "URL template (default: /node_modules/{package}/{path})")
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	osfs := fs.NewOSFileSystem()
+
+	absRoot, err := filepath.Abs(viper.GetString("package"))
+	if err != nil {
+		return fmt.Errorf("invalid package directory: %w", err)
+	}
+
+	format, _ := cmd.Flags().GetString("format")
+	if format != "text" && format != "json" {
+		return fmt.Errorf("invalid format %q: must be 'text' or 'json'", format)
+	}
+
+	resolver := local.New(osfs, nil)
+	if conditions, _ := cmd.Flags().GetStringSlice("conditions"); len(conditions) > 0 {
+		resolver = resolver.WithConditions(conditions)
+	}
+	if template, _ := cmd.Flags().GetString("template"); template != "" {
+		resolver, err = resolver.WithTemplate(template)
+		if err != nil {
+			return fmt.Errorf("invalid template: %w", err)
+		}
+	}
+
+	explanation := resolver.Explain(absRoot, args[0])
+
+	var out string
+	if format == "json" {
+		data, err := json.MarshalIndent(explanation, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal explanation: %w", err)
+		}
+		out = string(data)
+	} else {
+		out = formatText(explanation)
+	}
+
+	if err := output.Write(osfs, out); err != nil {
+		return err
+	}
+
+	if !explanation.Resolved() {
+		// The explanation already says what went wrong
+		cmd.SilenceUsage = true
+		return fmt.Errorf("%s: %s", explanation.Specifier, explanation.Error)
+	}
+	return nil
+}
+
+// formatText renders the explanation as labelled lines, with the resolution
+// steps numbered in order.
+func formatText(e *local.Explanation) string {
+	var b strings.Builder
+	fmt.Fprintln(&b, e.Specifier)
+	field := func(label, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "  %-13s %s\n", label+":", value)
+		}
+	}
+	pkg := e.Package
+	if e.Version != "" {
+		pkg += "@" + e.Version
+	}
+	field("package", pkg)
+	field("package.json", e.PackageJSON)
+	field("subpath", e.Subpath)
+	field("conditions", strings.Join(e.Conditions, ", "))
+	for i, step := range e.Steps {
+		fmt.Fprintf(&b, "  %d. %s\n", i+1, step)
+	}
+	field("target", e.Target)
+	field("file", e.File)
+	field("url", e.URL)
+	field("error", e.Error)
+	return strings.TrimSuffix(b.String(), "\n")
+}
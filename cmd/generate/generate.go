@@ -19,16 +19,25 @@ along with this program. If not, see <http://www.gnu.org/licenses/>.
 package generate
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"bennypowers.dev/mappa/cdn"
 	"bennypowers.dev/mappa/fs"
 	"bennypowers.dev/mappa/importmap"
 	"bennypowers.dev/mappa/internal/output"
+	"bennypowers.dev/mappa/internal/semver"
+	"bennypowers.dev/mappa/packagejson"
 	"bennypowers.dev/mappa/resolve"
+	cdnresolver "bennypowers.dev/mappa/resolve/cdn"
 	"bennypowers.dev/mappa/resolve/local"
 )
 
@@ -38,36 +47,143 @@ var Cmd = &cobra.Command{
 	Short: "Generate import map from package.json",
 	Long: `Generate an import map from package.json dependencies.
 
-By default, generates local /node_modules paths. Use --template for custom paths.`,
+By default, generates local /node_modules paths. Use --template for custom paths.
+Use --cdn to resolve dependency versions from the npm registry and map them to a CDN.`,
 	Example: `  # Generate import map with local paths (default)
   mappa generate
 
   # Custom local paths
   mappa generate --template "/assets/packages/{package}/{path}"
 
+  # Site deployed under a subpath
+  mappa generate --base-href /app/
+
+  # Versioned local paths (versions read from each package.json)
+  mappa generate --template "/assets/{package}@{version}/{path}"
+
   # Include additional packages (e.g., devDependencies)
   mappa generate --include-package fuse.js
 
   # Merge with an existing import map (input map takes precedence)
   mappa generate --input-map manual-imports.json
 
+  # Prefer development export targets
+  mappa generate --development
+
+  # Resolve dependencies from the npm registry to esm.sh URLs
+  mappa generate --cdn esm.sh
+
+  # Resolve to a self-hosted esm.sh mirror defined in a provider config file
+  mappa generate --cdn-config providers.json --cdn mymirror
+
+  # Resolve private scoped packages through the registries in an .npmrc
+  mappa generate --cdn esm.sh --npmrc ~/.npmrc
+
+  # Record where each CDN module's source map should be
+  mappa generate --cdn unpkg --source-map-manifest sourcemaps.json
+
+  # Reproduce a historical build with the versions current at the start of 2024
+  mappa generate --cdn esm.sh --as-of 2024-01-01
+
+  # Scope dependencies per importing module, where several versions coexist
+  mappa generate --cdn esm.sh --scope-strategy per-importer
+
+  # Record each resolved package's tarball integrity for later verification
+  mappa generate --cdn esm.sh --lockfile mappa-lock.json
+
+  # Print the dependency graph the CDN resolver would use
+  mappa generate --cdn esm.sh --graph-only
+
+  # Only map @patternfly packages (and the scopes they need)
+  mappa generate --only-scope @patternfly
+
+  # Only top-level imports, for a fully hoisted node_modules
+  mappa generate --no-scopes
+
+  # Limit parallel package resolution on a constrained CI machine
+  mappa generate --concurrency 2
+
+  # Don't warn about packages that deliberately have no main export
+  mappa generate --allow-subpath-only
+
+  # Fail if a package's exports point outside its own directory
+  mappa generate --strict-paths
+
+  # Warn about installed packages that don't support Node.js 18
+  mappa generate --node-version 18.19.0
+
+  # Include resolution warnings (missing deps, packages without exports) in the output
+  mappa generate --warnings
+
   # Output as HTML script tag
-  mappa generate --format html`,
+  mappa generate --format html
+
+  # Output a plain {specifier: url} object without the import map envelope
+  mappa generate --format flat
+
+  # Output an ES module whose default export is the import map
+  mappa generate --format esm`,
 	RunE: run,
 }
 
 func init() {
-	Cmd.Flags().StringP("format", "f", "json", "Output format (json, html)")
+	Cmd.Flags().StringP("format", "f", "json", "Output format (json, html, flat, esm)")
 	Cmd.Flags().String("input-map", "", "Import map file to merge with generated output")
 	Cmd.Flags().StringArray("include-package", nil, "Additional packages to include (can be repeated)")
 	Cmd.Flags().String("template", "", "URL template (default: /node_modules/{package}/{path})")
-	Cmd.Flags().StringSlice("conditions", nil, "Export condition priority (e.g., production,browser,import,default)")
+	Cmd.Flags().StringSlice("conditions", nil, "Export condition priority (e.g., production,browser,import,default); prefix with ! to never match a condition (e.g., !node)")
+	Cmd.Flags().Bool("development", false, "Prepend the development condition to the condition list")
+	Cmd.Flags().String("cdn", "", "Resolve from the npm registry to a CDN provider ("+strings.Join(cdn.ProviderNames(), ", ")+")")
+	Cmd.Flags().String("cdn-config", "", "JSON file defining additional CDN providers to select with --cdn, e.g. a self-hosted mirror")
+	Cmd.Flags().Bool("graph-only", false, "With --cdn, output the resolved dependency graph as JSON instead of an import map")
+	Cmd.Flags().String("npmrc", "", "With --cdn, .npmrc file for default and scoped registries (default: .npmrc in the package directory, if present)")
+	Cmd.Flags().Bool("stable-only", false, "With --cdn, never resolve to prerelease versions, failing if only prereleases match")
+	Cmd.Flags().String("as-of", "", "With --cdn, resolve each range to the highest version published on or before this date (YYYY-MM-DD or RFC 3339 time)")
+	Cmd.Flags().String("source-map-manifest", "", "With --cdn, write a JSON map of module URL to source map URL to this file (unpkg, jsdelivr)")
+	Cmd.Flags().String("lockfile", "", "With --cdn, write each resolved package's version, tarball URL and integrity to this JSON file")
+	Cmd.Flags().String("scope-strategy", "", "With --cdn, key dependency scopes by each package's versioned URL (per-version, default) or by each module it exports (per-importer)")
+	Cmd.Flags().Duration("cache-ttl", time.Hour, "With --cdn, how long to reuse cached registry responses (0 disables the disk cache)")
+	Cmd.Flags().Int("inline-below", 0, "Inline resolved JavaScript modules without imports smaller than this many bytes as data: URLs")
+	Cmd.Flags().Int("concurrency", local.DefaultConcurrency, "Maximum number of packages resolved in parallel")
+	Cmd.Flags().Bool("detect-cycles", false, "Warn about dependency cycles among node_modules packages")
+	Cmd.Flags().Bool("dedupe-report", false, "Warn about packages installed at more than one version, with the paths of each")
+	Cmd.Flags().Bool("allow-subpath-only", false, "Don't warn about packages that export subpaths but no main entry")
+	Cmd.Flags().Bool("strict-paths", false, "Fail instead of warning when an export target escapes its package directory")
+	Cmd.Flags().String("node-version", "", "Warn about packages whose engines.node range excludes this Node.js version (e.g., 18.19.0)")
+	Cmd.Flags().StringArray("only-scope", nil, "Only include top-level imports for packages in this npm scope, e.g. @patternfly (can be repeated)")
+	Cmd.Flags().Bool("no-scopes", false, "Drop scopes, keeping only top-level imports, and warn about scoped specifiers they don't map")
+	Cmd.Flags().String("base-href", "", "Prefix root-relative URLs and scope keys with a base path (e.g., /app/)")
+	Cmd.Flags().Bool("stats", false, "Print the import map's entry counts and raw and gzipped JSON size to stderr")
+	Cmd.Flags().Bool("warnings", false, "Include resolution warnings as a \"warnings\" array in JSON output instead of printing them to stderr")
 
 	_ = viper.BindPFlag("format", Cmd.Flags().Lookup("format"))
 	_ = viper.BindPFlag("input-map", Cmd.Flags().Lookup("input-map"))
 	_ = viper.BindPFlag("include-package", Cmd.Flags().Lookup("include-package"))
 	_ = viper.BindPFlag("template", Cmd.Flags().Lookup("template"))
 	_ = viper.BindPFlag("conditions", Cmd.Flags().Lookup("conditions"))
+	_ = viper.BindPFlag("development", Cmd.Flags().Lookup("development"))
+	_ = viper.BindPFlag("cdn", Cmd.Flags().Lookup("cdn"))
+	_ = viper.BindPFlag("cdn-config", Cmd.Flags().Lookup("cdn-config"))
+	_ = viper.BindPFlag("graph-only", Cmd.Flags().Lookup("graph-only"))
+	_ = viper.BindPFlag("npmrc", Cmd.Flags().Lookup("npmrc"))
+	_ = viper.BindPFlag("stable-only", Cmd.Flags().Lookup("stable-only"))
+	_ = viper.BindPFlag("as-of", Cmd.Flags().Lookup("as-of"))
+	_ = viper.BindPFlag("source-map-manifest", Cmd.Flags().Lookup("source-map-manifest"))
+	_ = viper.BindPFlag("lockfile", Cmd.Flags().Lookup("lockfile"))
+	_ = viper.BindPFlag("scope-strategy", Cmd.Flags().Lookup("scope-strategy"))
+	_ = viper.BindPFlag("cache-ttl", Cmd.Flags().Lookup("cache-ttl"))
+	_ = viper.BindPFlag("inline-below", Cmd.Flags().Lookup("inline-below"))
+	_ = viper.BindPFlag("concurrency", Cmd.Flags().Lookup("concurrency"))
+	_ = viper.BindPFlag("detect-cycles", Cmd.Flags().Lookup("detect-cycles"))
+	_ = viper.BindPFlag("dedupe-report", Cmd.Flags().Lookup("dedupe-report"))
+	_ = viper.BindPFlag("allow-subpath-only", Cmd.Flags().Lookup("allow-subpath-only"))
+	_ = viper.BindPFlag("strict-paths", Cmd.Flags().Lookup("strict-paths"))
+	_ = viper.BindPFlag("node-version", Cmd.Flags().Lookup("node-version"))
+	_ = viper.BindPFlag("only-scope", Cmd.Flags().Lookup("only-scope"))
+	_ = viper.BindPFlag("no-scopes", Cmd.Flags().Lookup("no-scopes"))
+	_ = viper.BindPFlag("base-href", Cmd.Flags().Lookup("base-href"))
+	_ = viper.BindPFlag("warnings", Cmd.Flags().Lookup("warnings"))
+	_ = viper.BindPFlag("stats", Cmd.Flags().Lookup("stats"))
 }
 
 func run(cmd *cobra.Command, args []string) error {
@@ -79,8 +195,19 @@ func run(cmd *cobra.Command, args []string) error {
 
 	// Validate format flag
 	format := viper.GetString("format")
-	if format != "json" && format != "html" {
-		return fmt.Errorf("invalid format %q: must be 'json' or 'html'", format)
+	if format != "json" && format != "html" && format != "flat" && format != "esm" {
+		return fmt.Errorf("invalid format %q: must be 'json', 'html', 'flat' or 'esm'", format)
+	}
+	if viper.GetBool("warnings") && format != "json" {
+		return fmt.Errorf("--warnings requires --format json")
+	}
+	if viper.GetInt("concurrency") < 1 {
+		return fmt.Errorf("invalid --concurrency %d: must be at least 1", viper.GetInt("concurrency"))
+	}
+	for _, scope := range viper.GetStringSlice("only-scope") {
+		if !strings.HasPrefix(scope, "@") || strings.Contains(strings.TrimSuffix(scope, "/"), "/") {
+			return fmt.Errorf("invalid --only-scope %q: must be an npm scope like @patternfly", scope)
+		}
 	}
 
 	// Get additional packages
@@ -105,8 +232,76 @@ func run(cmd *cobra.Command, args []string) error {
 		templateArg = resolve.DefaultLocalTemplate
 	}
 
+	conditions := viper.GetStringSlice("conditions")
+	if viper.GetBool("development") {
+		conditions = packagejson.DevelopmentConditions(conditions)
+	}
+
+	if providerName := viper.GetString("cdn"); providerName != "" {
+		if viper.GetString("node-version") != "" {
+			return fmt.Errorf("--node-version cannot be combined with --cdn")
+		}
+		if viper.GetInt("inline-below") > 0 {
+			return fmt.Errorf("--inline-below cannot be combined with --cdn")
+		}
+		if viper.GetBool("detect-cycles") {
+			return fmt.Errorf("--detect-cycles cannot be combined with --cdn")
+		}
+		if viper.GetBool("allow-subpath-only") {
+			return fmt.Errorf("--allow-subpath-only cannot be combined with --cdn")
+		}
+		if cmd.Flags().Changed("concurrency") {
+			return fmt.Errorf("--concurrency cannot be combined with --cdn")
+		}
+		if viper.GetBool("strict-paths") {
+			return fmt.Errorf("--strict-paths cannot be combined with --cdn")
+		}
+		if viper.GetBool("dedupe-report") {
+			return fmt.Errorf("--dedupe-report cannot be combined with --cdn")
+		}
+		var providers []cdn.Provider
+		if configPath := viper.GetString("cdn-config"); configPath != "" {
+			data, err := osfs.ReadFile(configPath)
+			if err != nil {
+				return fmt.Errorf("failed to read CDN provider config: %w", err)
+			}
+			if providers, err = cdn.LoadProviders(data); err != nil {
+				return err
+			}
+		}
+		return runCDN(osfs, absRoot, providerName, providers, format, inputMap, conditions)
+	}
+	if viper.GetString("cdn-config") != "" {
+		return fmt.Errorf("--cdn-config requires --cdn")
+	}
+	if viper.GetBool("graph-only") {
+		return fmt.Errorf("--graph-only requires --cdn")
+	}
+	if viper.GetString("source-map-manifest") != "" {
+		return fmt.Errorf("--source-map-manifest requires --cdn")
+	}
+	if viper.GetString("lockfile") != "" {
+		return fmt.Errorf("--lockfile requires --cdn")
+	}
+	if viper.GetString("as-of") != "" {
+		return fmt.Errorf("--as-of requires --cdn")
+	}
+	if viper.GetString("scope-strategy") != "" {
+		return fmt.Errorf("--scope-strategy requires --cdn")
+	}
+	if viper.GetBool("stable-only") {
+		return fmt.Errorf("--stable-only requires --cdn")
+	}
+	if cmd.Flags().Changed("cache-ttl") {
+		return fmt.Errorf("--cache-ttl requires --cdn")
+	}
+	if viper.GetString("npmrc") != "" {
+		return fmt.Errorf("--npmrc requires --cdn")
+	}
+
 	// Build resolver
-	resolver := local.New(osfs, nil)
+	logger := resolve.NewCollectingLogger()
+	resolver := local.New(osfs, logger)
 	if len(includePackages) > 0 {
 		resolver = resolver.WithPackages(includePackages)
 	}
@@ -117,9 +312,31 @@ func run(cmd *cobra.Command, args []string) error {
 	if inputMap != nil {
 		resolver = resolver.WithInputMap(inputMap)
 	}
-	if conditions := viper.GetStringSlice("conditions"); len(conditions) > 0 {
+	if len(conditions) > 0 {
 		resolver = resolver.WithConditions(conditions)
 	}
+	if inlineBelow := viper.GetInt("inline-below"); inlineBelow > 0 {
+		resolver = resolver.WithInlineBelow(inlineBelow)
+	}
+	if viper.GetBool("detect-cycles") {
+		resolver = resolver.WithDetectCycles(true)
+	}
+	if viper.GetBool("dedupe-report") {
+		resolver = resolver.WithDedupeReport(true)
+	}
+	resolver = resolver.WithConcurrency(viper.GetInt("concurrency"))
+	if viper.GetBool("allow-subpath-only") {
+		resolver = resolver.WithAllowSubpathOnly(true)
+	}
+	if viper.GetBool("strict-paths") {
+		resolver = resolver.WithStrictPaths(true)
+	}
+	if nodeVersion := viper.GetString("node-version"); nodeVersion != "" {
+		if _, err := semver.Parse(nodeVersion); err != nil {
+			return fmt.Errorf("invalid --node-version %q: %w", nodeVersion, err)
+		}
+		resolver = resolver.WithEngineCheck(nodeVersion)
+	}
 
 	generatedMap, err := resolver.Resolve(absRoot)
 	if err != nil {
@@ -129,5 +346,187 @@ func run(cmd *cobra.Command, args []string) error {
 	// Simplify the import map to remove entries covered by trailing-slash keys
 	simplifiedMap := generatedMap.Simplify()
 
-	return output.ImportMap(osfs, simplifiedMap, format)
+	return writeResult(osfs, simplifiedMap, format, logger)
+}
+
+// writeResult outputs the generated import map along with any resolution warnings,
+// either embedded in the JSON output (--warnings) or printed to stderr.
+// The map is limited to --only-scope packages, stripped of scopes with
+// --no-scopes, and URLs are rebased onto --base-href when those flags are
+// set, and its size is printed to stderr with --stats.
+func writeResult(osfs fs.FileSystem, im *importmap.ImportMap, format string, logger *resolve.CollectingLogger) error {
+	if scopes := viper.GetStringSlice("only-scope"); len(scopes) > 0 {
+		im = onlyScopes(im, scopes)
+	}
+	if viper.GetBool("no-scopes") {
+		var lost []string
+		if im, lost = im.WithoutScopes(); len(lost) > 0 {
+			logger.Warning("--no-scopes leaves specifiers that only scopes mapped unresolvable: %s", strings.Join(lost, ", "))
+		}
+	}
+	if baseHref := viper.GetString("base-href"); baseHref != "" {
+		im = im.Rebase(baseHref)
+	}
+	if viper.GetBool("stats") {
+		fmt.Fprint(os.Stderr, im.Stats())
+	}
+	warnings := logger.Warnings()
+	if viper.GetBool("warnings") {
+		return output.ImportMapWithWarnings(osfs, im, warnings)
+	}
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", w.Message)
+	}
+	return output.ImportMap(osfs, im, format)
+}
+
+// onlyScopes keeps the top-level imports of packages in the given npm scopes,
+// along with the scopes those packages need.
+func onlyScopes(im *importmap.ImportMap, scopes []string) *importmap.ImportMap {
+	prefixes := make([]string, len(scopes))
+	for i, scope := range scopes {
+		prefixes[i] = strings.TrimSuffix(scope, "/") + "/"
+	}
+	return im.Filter(func(key string) bool {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// runCDN generates an import map (or dependency graph) by resolving package.json
+// dependencies against the npm registry and mapping them to a CDN provider,
+// either predefined or one of the providers loaded from --cdn-config.
+func runCDN(osfs fs.FileSystem, absRoot, providerName string, providers []cdn.Provider, format string, inputMap *importmap.ImportMap, conditions []string) error {
+	provider := cdn.ProviderByName(providerName, providers...)
+	if provider == nil {
+		return fmt.Errorf("invalid CDN provider %q: must be one of %s", providerName, strings.Join(cdn.ProviderNames(providers...), ", "))
+	}
+
+	pkg, err := packagejson.ParseFile(osfs, filepath.Join(absRoot, "package.json"))
+	if err != nil {
+		return fmt.Errorf("failed to read package.json: %w", err)
+	}
+
+	// Cache registry and package.json responses on disk across invocations
+	fetcher := cdn.NewCachedHTTPFetcher(osfs, viper.GetDuration("cache-ttl"))
+
+	registry := cdn.NewRegistry(fetcher)
+	npmrc, err := cdn.LoadNpmrc(osfs, viper.GetString("npmrc"), absRoot)
+	if err != nil {
+		return err
+	}
+	if npmrc != nil {
+		registry = registry.WithNpmrc(npmrc)
+	}
+	if viper.GetBool("stable-only") {
+		registry = registry.WithStableOnly(true)
+	}
+	if asOfArg := viper.GetString("as-of"); asOfArg != "" {
+		asOf, err := parseAsOf(asOfArg)
+		if err != nil {
+			return err
+		}
+		registry = registry.WithAsOf(asOf)
+	}
+
+	logger := resolve.NewCollectingLogger()
+	resolver := cdnresolver.New(fetcher).WithProvider(*provider).WithRegistry(registry).WithLogger(logger)
+	if templateArg := viper.GetString("template"); templateArg != "" {
+		resolver, err = resolver.WithTemplate(templateArg)
+		if err != nil {
+			return fmt.Errorf("invalid template: %w", err)
+		}
+	}
+	if len(conditions) > 0 {
+		resolver = resolver.WithConditions(conditions)
+	}
+	if strategyArg := viper.GetString("scope-strategy"); strategyArg != "" {
+		strategy, err := cdnresolver.ParseScopeStrategy(strategyArg)
+		if err != nil {
+			return err
+		}
+		resolver = resolver.WithScopeStrategy(strategy)
+	}
+
+	ctx := context.Background()
+
+	if viper.GetBool("graph-only") {
+		graph, err := resolver.ResolveGraph(ctx, pkg)
+		if err != nil {
+			return fmt.Errorf("failed to resolve: %w", err)
+		}
+		out, err := json.MarshalIndent(graph, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal dependency graph: %w", err)
+		}
+		return output.Write(osfs, string(out))
+	}
+
+	var generatedMap *importmap.ImportMap
+	if lockfilePath := viper.GetString("lockfile"); lockfilePath != "" {
+		var graph *cdnresolver.ResolutionGraph
+		generatedMap, graph, err = resolver.ResolvePackageJSONWithGraph(ctx, pkg)
+		if err != nil {
+			return fmt.Errorf("failed to resolve: %w", err)
+		}
+		out, err := json.MarshalIndent(graph, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal lockfile: %w", err)
+		}
+		if err := osfs.WriteFile(lockfilePath, append(out, '\n'), 0644); err != nil {
+			return fmt.Errorf("failed to write lockfile: %w", err)
+		}
+	} else {
+		generatedMap, err = resolver.ResolvePackageJSON(ctx, pkg)
+		if err != nil {
+			return fmt.Errorf("failed to resolve: %w", err)
+		}
+	}
+	if manifestPath := viper.GetString("source-map-manifest"); manifestPath != "" {
+		if err := writeSourceMapManifest(osfs, manifestPath, generatedMap, *provider, logger); err != nil {
+			return err
+		}
+	}
+	if inputMap != nil {
+		var conflicts []importmap.Conflict
+		generatedMap, conflicts = generatedMap.MergeWithConflicts(inputMap)
+		for _, c := range conflicts {
+			logger.Warning("Input map overrides %s", c)
+		}
+	}
+
+	return writeResult(osfs, generatedMap.Simplify(), format, logger)
+}
+
+// parseAsOf parses an --as-of cutoff. A date means the end of that day in UTC,
+// so versions published during it are included.
+func parseAsOf(value string) (time.Time, error) {
+	if date, err := time.Parse(time.DateOnly, value); err == nil {
+		return date.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+	}
+	asOf, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --as-of %q: must be a YYYY-MM-DD date or RFC 3339 time", value)
+	}
+	return asOf, nil
+}
+
+// writeSourceMapManifest writes a JSON object mapping each CDN module URL in
+// im to its source map URL, for tools that preload or verify source maps.
+func writeSourceMapManifest(osfs fs.FileSystem, path string, im *importmap.ImportMap, provider cdn.Provider, logger *resolve.CollectingLogger) error {
+	if provider.SourceMapSuffix == "" {
+		logger.Warning("CDN provider %s has no source map convention; source map manifest is empty", provider.Name)
+	}
+	out, err := json.MarshalIndent(cdnresolver.SourceMapURLs(im, provider), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal source map manifest: %w", err)
+	}
+	if err := osfs.WriteFile(path, append(out, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write source map manifest: %w", err)
+	}
+	return nil
 }
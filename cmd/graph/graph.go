@@ -0,0 +1,184 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// Package graph provides the graph command for mappa.
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"bennypowers.dev/mappa/fs"
+	"bennypowers.dev/mappa/internal/output"
+	"bennypowers.dev/mappa/packagejson"
+	"bennypowers.dev/mappa/resolve"
+	"bennypowers.dev/mappa/resolve/local"
+)
+
+// Cmd is the graph cobra command that prints the package dependency graph
+// built while resolving an import map.
+var Cmd = &cobra.Command{
+	Use:   "graph",
+	Short: "Print the package dependency graph",
+	Long: `Resolve the package's dependencies and print the dependency graph used to
+build its import map, as Graphviz DOT or JSON.
+
+Nodes are packages and edges point from a package to its dependencies. Workspace
+packages are drawn as boxes and node_modules packages as ellipses; packages whose
+dependencies are scoped in the import map are labelled with their scope key.`,
+	Example: `  # Render the graph with Graphviz
+  mappa graph | dot -Tsvg -o deps.svg
+
+  # Output the graph as JSON
+  mappa graph --format json
+
+  # Use production export conditions and a custom template for scope keys
+  mappa graph --conditions production,default --template "/assets/{package}/{path}"`,
+	RunE: run,
+}
+
+func init() {
+	Cmd.Flags().StringP("format", "f", "dot", "Output format (dot, json)")
+	Cmd.Flags().String("template", "", "URL template (default: /node_modules/{package}/{path})")
+	Cmd.Flags().StringSlice("conditions", nil, "Export condition priority (e.g., production,browser,import,default); prefix with ! to never match a condition (e.g., !node)")
+}
+
+// graphJSON is the JSON output of the graph command.
+type graphJSON struct {
+	// Root is the name of the resolved package.
+	Root string `json:"root,omitempty"`
+	// Dependencies lists the root package's direct dependencies, sorted.
+	Dependencies []string `json:"dependencies"`
+	// Packages describes every package in the graph, sorted by name.
+	Packages []resolve.GraphNode `json:"packages"`
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	osfs := fs.NewOSFileSystem()
+
+	absRoot, err := filepath.Abs(viper.GetString("package"))
+	if err != nil {
+		return fmt.Errorf("invalid package directory: %w", err)
+	}
+
+	format, _ := cmd.Flags().GetString("format")
+	if format != "dot" && format != "json" {
+		return fmt.Errorf("invalid format %q: must be 'dot' or 'json'", format)
+	}
+
+	resolver := local.New(osfs, nil)
+	if templateArg, _ := cmd.Flags().GetString("template"); templateArg != "" {
+		resolver, err = resolver.WithTemplate(templateArg)
+		if err != nil {
+			return fmt.Errorf("invalid template: %w", err)
+		}
+	}
+	if conditions, _ := cmd.Flags().GetStringSlice("conditions"); len(conditions) > 0 {
+		resolver = resolver.WithConditions(conditions)
+	}
+
+	result, err := resolver.ResolveWithGraph(absRoot)
+	if err != nil {
+		return fmt.Errorf("failed to resolve: %w", err)
+	}
+
+	doc := buildGraph(osfs, absRoot, result.DependencyGraph)
+
+	var out []byte
+	if format == "json" {
+		out, err = json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal dependency graph: %w", err)
+		}
+	} else {
+		out = []byte(formatDOT(doc))
+	}
+
+	return output.Write(osfs, string(out))
+}
+
+// buildGraph describes the dependency graph for the package at absRoot.
+// Package paths are made relative to absRoot so the output is portable.
+func buildGraph(osfs fs.FileSystem, absRoot string, graph *resolve.DependencyGraph) graphJSON {
+	doc := graphJSON{
+		Dependencies: []string{},
+		Packages:     graph.Nodes(),
+	}
+
+	if pkg, err := packagejson.ParseFile(osfs, filepath.Join(absRoot, "package.json")); err == nil {
+		doc.Root = pkg.Name
+		known := graph.Packages()
+		for dep := range pkg.Dependencies {
+			if slices.Contains(known, dep) {
+				doc.Dependencies = append(doc.Dependencies, dep)
+			}
+		}
+		slices.Sort(doc.Dependencies)
+	}
+
+	for i, node := range doc.Packages {
+		if node.Path == "" {
+			continue
+		}
+		if rel, err := filepath.Rel(absRoot, node.Path); err == nil {
+			doc.Packages[i].Path = filepath.ToSlash(rel)
+		}
+	}
+
+	return doc
+}
+
+// formatDOT renders the dependency graph in Graphviz DOT.
+// Edges point from each package to its dependencies.
+func formatDOT(doc graphJSON) string {
+	var b strings.Builder
+	b.WriteString("digraph dependencies {\n")
+	b.WriteString("  rankdir=LR;\n")
+
+	if doc.Root != "" {
+		fmt.Fprintf(&b, "  %q [shape=doubleoctagon];\n", doc.Root)
+	}
+	for _, node := range doc.Packages {
+		shape := "ellipse"
+		if node.Workspace {
+			shape = "box"
+		}
+		label := node.Name
+		if node.ScopeKey != "" {
+			label += "\n" + node.ScopeKey
+		}
+		fmt.Fprintf(&b, "  %q [shape=%s, label=%q];\n", node.Name, shape, label)
+	}
+
+	for _, dep := range doc.Dependencies {
+		fmt.Fprintf(&b, "  %q -> %q;\n", doc.Root, dep)
+	}
+	for _, node := range doc.Packages {
+		for _, dependent := range node.Dependents {
+			fmt.Fprintf(&b, "  %q -> %q;\n", dependent, node.Name)
+		}
+	}
+
+	b.WriteString("}")
+	return b.String()
+}
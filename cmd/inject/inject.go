@@ -41,8 +41,12 @@ var Cmd = &cobra.Command{
 	Long: `Trace HTML files and update their import map script tags in-place.
 
 For each file, traces module imports to generate a minimal import map,
-merges with any existing manual imports (traced imports take precedence),
-and writes the result back to the file.`,
+merges with any existing manual imports (traced imports take precedence,
+and with --prefer-existing the page's scope entries win over traced ones),
+and writes the result back to the file. A page with several import map tags
+has them consolidated into the first, with a warning, or fails with --strict.
+
+With --remove, deletes import map script tags instead, without tracing.`,
 	Example: `  # Inject import maps into all HTML files
   mappa inject --glob "_site/**/*.html"
 
@@ -53,7 +57,28 @@ and writes the result back to the file.`,
   mappa inject --glob "_site/**/*.html" -j 8
 
   # Dry run to see what would change
-  mappa inject --glob "_site/**/*.html" --dry-run`,
+  mappa inject --glob "_site/**/*.html" --dry-run
+
+  # Write importmap-shim tags for es-module-shims
+  mappa inject --glob "_site/**/*.html" --shim
+
+  # Also preload the traced modules, shallowest dependencies first
+  mappa inject --glob "_site/**/*.html" --preload
+
+  # Remove stale import maps before regenerating
+  mappa inject --glob "_site/**/*.html" --remove
+
+  # Keep hand-tuned scope entries instead of overwriting them with traced ones
+  mappa inject --glob "_site/**/*.html" --prefer-existing
+
+  # Replace an <!-- importmap --> comment in each page with the import map
+  mappa inject --glob "_site/**/*.html" --marker "<!-- importmap -->"
+
+  # Fail pages that have more than one import map tag
+  mappa inject --glob "_site/**/*.html" --strict --fail-on-error
+
+  # Exit non-zero if any file fails (e.g., malformed import map)
+  mappa inject --glob "_site/**/*.html" --fail-on-error`,
 	RunE: run,
 }
 
@@ -61,10 +86,17 @@ func init() {
 	Cmd.Flags().String("glob", "", "Glob pattern to match HTML files (required)")
 	_ = Cmd.MarkFlagRequired("glob")
 	Cmd.Flags().String("template", "", "URL template (default: /node_modules/{package}/{path})")
-	Cmd.Flags().StringSlice("conditions", nil, "Export condition priority (e.g., production,browser,import,default)")
+	Cmd.Flags().StringSlice("conditions", nil, "Export condition priority (e.g., production,browser,import,default); prefix with ! to never match a condition (e.g., !node)")
 	Cmd.Flags().IntP("jobs", "j", 0, "Number of parallel workers (default: number of CPUs)")
 	Cmd.Flags().Bool("dry-run", false, "Show what would change without modifying files")
 	Cmd.Flags().StringP("format", "f", "text", "Output format (text, json)")
+	Cmd.Flags().Bool("fail-on-error", false, "Exit with non-zero status if any file fails")
+	Cmd.Flags().Bool("shim", false, "Write <script type=\"importmap-shim\"> tags for es-module-shims")
+	Cmd.Flags().Bool("preload", false, "Insert <link rel=\"modulepreload\"> tags for the traced modules after the import map")
+	Cmd.Flags().Bool("strict", false, "Fail pages with more than one import map tag instead of consolidating them into the first")
+	Cmd.Flags().Bool("prefer-existing", false, "Keep the page's existing scope entries where they conflict with traced ones")
+	Cmd.Flags().String("marker", "", "Comment to replace with a new import map tag, e.g. \"<!-- importmap -->\" (default: before the first script in <head>)")
+	Cmd.Flags().Bool("remove", false, "Delete import map tags instead of injecting them (with --shim, importmap-shim tags too)")
 }
 
 func run(cmd *cobra.Command, args []string) error {
@@ -107,44 +139,74 @@ func run(cmd *cobra.Command, args []string) error {
 	conditions, _ := cmd.Flags().GetStringSlice("conditions")
 	parallel, _ := cmd.Flags().GetInt("jobs")
 	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	failOnError, _ := cmd.Flags().GetBool("fail-on-error")
+	shim, _ := cmd.Flags().GetBool("shim")
+	remove, _ := cmd.Flags().GetBool("remove")
+	preload, _ := cmd.Flags().GetBool("preload")
+	strict, _ := cmd.Flags().GetBool("strict")
+	marker, _ := cmd.Flags().GetString("marker")
+	preferExisting, _ := cmd.Flags().GetBool("prefer-existing")
 	format, _ := cmd.Flags().GetString("format")
 	if format != "text" && format != "json" {
 		return fmt.Errorf("invalid format %q: must be 'text' or 'json'", format)
 	}
 
 	opts := inject.Options{
-		Template:   templateArg,
-		Conditions: conditions,
-		Parallel:   parallel,
-		DryRun:     dryRun,
+		Template:       templateArg,
+		Conditions:     conditions,
+		Parallel:       parallel,
+		DryRun:         dryRun,
+		Shim:           shim,
+		Preload:        preload,
+		Strict:         strict,
+		Marker:         marker,
+		PreferExisting: preferExisting,
 	}
 
 	// Run inject
 	start := time.Now()
-	results := inject.InjectBatch(osfs, files, absRoot, opts)
+	var results <-chan inject.Result
+	if remove {
+		results = inject.RemoveBatch(osfs, files, opts)
+	} else {
+		results = inject.InjectBatch(osfs, files, absRoot, opts)
+	}
 
 	// Collect results
 	var stats inject.Stats
 	stats.Total = len(files)
 
+	// In JSON mode, every file gets one Result line, including errors and unchanged files
 	encoder := json.NewEncoder(os.Stdout)
 	for result := range results {
+		if format == "json" {
+			_ = encoder.Encode(result)
+		} else {
+			for _, warning := range result.Warnings {
+				fmt.Fprintf(os.Stderr, "Warning: %s: %s\n", result.File, warning)
+			}
+		}
 		if result.Error != "" {
 			stats.Errors++
-			if format == "json" {
-				_ = encoder.Encode(result)
-			} else {
+			if format == "text" {
 				fmt.Fprintf(os.Stderr, "Error: %s: %s\n", result.File, result.Error)
 			}
+		} else if result.Removed {
+			stats.Removed++
+			if format == "text" {
+				action := "removed from"
+				if dryRun {
+					action = "would remove from"
+				}
+				fmt.Printf("%s %s\n", action, result.File)
+			}
 		} else if result.Modified {
 			if result.Inserted {
 				stats.Inserted++
 			} else {
 				stats.Updated++
 			}
-			if format == "json" {
-				_ = encoder.Encode(result)
-			} else {
+			if format == "text" {
 				action := "updated"
 				if dryRun {
 					action = "would update"
@@ -164,7 +226,15 @@ func run(cmd *cobra.Command, args []string) error {
 	stats.Duration = time.Since(start).Milliseconds()
 
 	// Output summary
-	if format == "text" {
+	if format == "text" && remove {
+		if dryRun {
+			fmt.Printf("\nDry run: import maps would be removed from %d files, %d unchanged, %d errors\n",
+				stats.Removed, stats.Skipped, stats.Errors)
+		} else {
+			fmt.Printf("Removed: import maps removed from %d files, %d unchanged, %d errors\n",
+				stats.Removed, stats.Skipped, stats.Errors)
+		}
+	} else if format == "text" {
 		if dryRun {
 			fmt.Printf("\nDry run: %d files would be modified (%d updated, %d new), %d unchanged, %d errors\n",
 				stats.Updated+stats.Inserted, stats.Updated, stats.Inserted, stats.Skipped, stats.Errors)
@@ -180,6 +250,9 @@ func run(cmd *cobra.Command, args []string) error {
 	if stats.Errors == stats.Total {
 		return fmt.Errorf("all %d files failed", stats.Errors)
 	}
+	if failOnError && stats.Errors > 0 {
+		return fmt.Errorf("%d of %d files failed", stats.Errors, stats.Total)
+	}
 
 	return nil
 }
@@ -0,0 +1,128 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// Package prune provides the prune command for mappa.
+package prune
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"bennypowers.dev/mappa/fs"
+	"bennypowers.dev/mappa/importmap"
+	"bennypowers.dev/mappa/internal/output"
+	"bennypowers.dev/mappa/trace"
+)
+
+// Cmd is the prune cobra command that trims an existing import map
+// to only the specifiers used by a set of HTML files.
+var Cmd = &cobra.Command{
+	Use:   "prune <importmap.json>",
+	Short: "Remove unused entries from an existing import map",
+	Long: `Trim an existing import map to only the specifiers your pages use.
+
+Traces all HTML files matching --glob to collect the bare specifiers they import,
+then outputs the input map filtered to those specifiers. Scopes covering retained
+URLs are preserved. The number of removed entries is reported to stderr.`,
+	Example: `  # Prune an import map against all site pages
+  mappa prune importmap.json --glob "_site/**/*.html"
+
+  # Write the pruned map to a file
+  mappa prune importmap.json --glob "_site/**/*.html" -o importmap.pruned.json
+
+  # Output as HTML script tag
+  mappa prune importmap.json --glob "_site/**/*.html" --format html`,
+	Args: cobra.ExactArgs(1),
+	RunE: run,
+}
+
+func init() {
+	Cmd.Flags().String("glob", "", "Glob pattern to match HTML files (required)")
+	_ = Cmd.MarkFlagRequired("glob")
+	Cmd.Flags().StringP("format", "f", "json", "Output format (json, html)")
+	Cmd.Flags().IntP("jobs", "j", 0, "Number of parallel workers (default: number of CPUs)")
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	osfs := fs.NewOSFileSystem()
+
+	absRoot, err := filepath.Abs(viper.GetString("package"))
+	if err != nil {
+		return fmt.Errorf("invalid package directory: %w", err)
+	}
+
+	format, _ := cmd.Flags().GetString("format")
+	if format != "json" && format != "html" {
+		return fmt.Errorf("invalid format %q: must be 'json' or 'html'", format)
+	}
+
+	// Parse the input map
+	inputData, err := osfs.ReadFile(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to read import map: %w", err)
+	}
+	inputMap, err := importmap.Parse(inputData)
+	if err != nil {
+		return fmt.Errorf("failed to parse import map: %w", err)
+	}
+
+	// Collect files from glob pattern
+	globPattern, _ := cmd.Flags().GetString("glob")
+	matches, err := doublestar.FilepathGlob(globPattern)
+	if err != nil {
+		return fmt.Errorf("invalid glob pattern: %w", err)
+	}
+	if len(matches) == 0 {
+		return fmt.Errorf("no files matched the glob pattern %q", globPattern)
+	}
+
+	// Deduplicate by absolute path
+	seen := make(map[string]struct{})
+	var files []string
+	for _, match := range matches {
+		absPath, err := filepath.Abs(match)
+		if err != nil {
+			return fmt.Errorf("invalid file path %q: %w", match, err)
+		}
+		if _, exists := seen[absPath]; !exists {
+			seen[absPath] = struct{}{}
+			files = append(files, absPath)
+		}
+	}
+
+	parallel, _ := cmd.Flags().GetInt("jobs")
+
+	used, errs := trace.CollectSpecifiers(osfs, files, absRoot, trace.Options{Parallel: parallel})
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+	if len(errs) == len(files) {
+		return fmt.Errorf("all %d files failed to trace", len(errs))
+	}
+
+	pruned := inputMap.Subset(used)
+
+	before := inputMap.Len()
+	fmt.Fprintf(os.Stderr, "Removed %d of %d entries\n", before-pruned.Len(), before)
+
+	return output.ImportMap(osfs, pruned, format)
+}
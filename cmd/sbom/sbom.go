@@ -0,0 +1,189 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+// Package sbom provides the sbom command for mappa.
+package sbom
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"bennypowers.dev/mappa/cdn"
+	"bennypowers.dev/mappa/fs"
+	"bennypowers.dev/mappa/internal/output"
+	"bennypowers.dev/mappa/packagejson"
+	"bennypowers.dev/mappa/resolve"
+	cdnresolver "bennypowers.dev/mappa/resolve/cdn"
+	"bennypowers.dev/mappa/resolve/local"
+	"bennypowers.dev/mappa/sbom"
+)
+
+// Cmd is the sbom cobra command that lists the packages behind an import map
+// as a CycloneDX software bill of materials.
+var Cmd = &cobra.Command{
+	Use:   "sbom",
+	Short: "Print a CycloneDX SBOM of resolved packages",
+	Long: `Resolve the package's dependencies and print a CycloneDX JSON software bill
+of materials listing each resolved package, its version, and the URL it is
+served from.
+
+By default, packages and versions are read from node_modules and URLs follow
+the local template. Use --cdn to resolve versions from the npm registry and
+list CDN URLs instead.`,
+	Example: `  # SBOM of installed packages
+  mappa sbom -o sbom.json
+
+  # SBOM of packages served from esm.sh
+  mappa sbom --cdn esm.sh
+
+  # SBOM of packages served from a self-hosted mirror
+  mappa sbom --cdn-config providers.json --cdn mymirror
+
+  # Local packages served from a custom asset path
+  mappa sbom --template "/assets/{package}@{version}/{path}"`,
+	RunE: run,
+}
+
+func init() {
+	Cmd.Flags().String("cdn", "", "Resolve from the npm registry to a CDN provider ("+strings.Join(cdn.ProviderNames(), ", ")+")")
+	Cmd.Flags().String("cdn-config", "", "JSON file defining additional CDN providers to select with --cdn")
+	Cmd.Flags().String("npmrc", "", "With --cdn, .npmrc file for default and scoped registries (default: .npmrc in the package directory, if present)")
+	Cmd.Flags().Duration("cache-ttl", time.Hour, "With --cdn, how long to reuse cached registry responses (0 disables the disk cache)")
+	Cmd.Flags().String("template", "", "URL template (default: /node_modules/{package}/{path}, or the CDN's module URL)")
+	Cmd.Flags().StringSlice("conditions", nil, "Export condition priority (e.g., production,browser,import,default); prefix with ! to never match a condition (e.g., !node)")
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	osfs := fs.NewOSFileSystem()
+
+	absRoot, err := filepath.Abs(viper.GetString("package"))
+	if err != nil {
+		return fmt.Errorf("invalid package directory: %w", err)
+	}
+
+	pkg, err := packagejson.ParseFile(osfs, filepath.Join(absRoot, "package.json"))
+	if err != nil {
+		return fmt.Errorf("failed to read package.json: %w", err)
+	}
+
+	templateArg, _ := cmd.Flags().GetString("template")
+	conditions, _ := cmd.Flags().GetStringSlice("conditions")
+
+	var providers []cdn.Provider
+	if configPath, _ := cmd.Flags().GetString("cdn-config"); configPath != "" {
+		data, err := osfs.ReadFile(configPath)
+		if err != nil {
+			return fmt.Errorf("failed to read CDN provider config: %w", err)
+		}
+		if providers, err = cdn.LoadProviders(data); err != nil {
+			return err
+		}
+	}
+
+	var bom *sbom.BOM
+	if providerName, _ := cmd.Flags().GetString("cdn"); providerName != "" {
+		npmrcPath, _ := cmd.Flags().GetString("npmrc")
+		cacheTTL, _ := cmd.Flags().GetDuration("cache-ttl")
+		bom, err = cdnBOM(osfs, absRoot, pkg, providerName, providers, templateArg, conditions, npmrcPath, cacheTTL)
+	} else if cmd.Flags().Changed("npmrc") {
+		return fmt.Errorf("--npmrc requires --cdn")
+	} else if cmd.Flags().Changed("cache-ttl") {
+		return fmt.Errorf("--cache-ttl requires --cdn")
+	} else {
+		bom, err = localBOM(osfs, absRoot, pkg, templateArg, conditions)
+	}
+	if err != nil {
+		return err
+	}
+
+	out, err := json.MarshalIndent(bom, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal SBOM: %w", err)
+	}
+	return output.Write(osfs, string(out))
+}
+
+// localBOM lists the packages resolved from node_modules.
+func localBOM(osfs fs.FileSystem, absRoot string, pkg *packagejson.PackageJSON, templateArg string, conditions []string) (*sbom.BOM, error) {
+	if templateArg == "" {
+		templateArg = resolve.DefaultLocalTemplate
+	}
+	tmpl, err := resolve.ParseTemplate(templateArg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+
+	resolver, err := local.New(osfs, nil).WithTemplate(templateArg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	if len(conditions) > 0 {
+		resolver = resolver.WithConditions(conditions)
+	}
+
+	result, err := resolver.ResolveWithGraph(absRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve: %w", err)
+	}
+	return sbom.FromDependencyGraph(osfs, absRoot, pkg, result.DependencyGraph, tmpl), nil
+}
+
+// cdnBOM lists the packages resolved from the npm registry for a CDN provider,
+// either predefined or one of the providers loaded from --cdn-config, with
+// the registries from npmrcPath, or the package's .npmrc, and responses
+// cached for cacheTTL as generate --cdn does.
+func cdnBOM(osfs fs.FileSystem, absRoot string, pkg *packagejson.PackageJSON, providerName string, providers []cdn.Provider, templateArg string, conditions []string, npmrcPath string, cacheTTL time.Duration) (*sbom.BOM, error) {
+	provider := cdn.ProviderByName(providerName, providers...)
+	if provider == nil {
+		return nil, fmt.Errorf("invalid CDN provider %q: must be one of %s", providerName, strings.Join(cdn.ProviderNames(providers...), ", "))
+	}
+	if templateArg == "" {
+		templateArg = provider.ModuleTemplate
+	}
+	tmpl, err := resolve.ParseTemplate(templateArg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+
+	fetcher := cdn.NewCachedHTTPFetcher(osfs, cacheTTL)
+
+	registry := cdn.NewRegistry(fetcher)
+	npmrc, err := cdn.LoadNpmrc(osfs, npmrcPath, absRoot)
+	if err != nil {
+		return nil, err
+	}
+	if npmrc != nil {
+		registry = registry.WithNpmrc(npmrc)
+	}
+
+	resolver := cdnresolver.New(fetcher).WithProvider(*provider).WithRegistry(registry)
+	if len(conditions) > 0 {
+		resolver = resolver.WithConditions(conditions)
+	}
+
+	graph, err := resolver.ResolveGraph(context.Background(), pkg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve: %w", err)
+	}
+	return sbom.FromResolutionGraph(pkg, graph, tmpl), nil
+}
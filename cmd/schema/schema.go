@@ -0,0 +1,98 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// Package schema provides the schema command for mappa.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"bennypowers.dev/mappa/fs"
+	"bennypowers.dev/mappa/importmap"
+	"bennypowers.dev/mappa/internal/jsonschema"
+	"bennypowers.dev/mappa/internal/output"
+	"bennypowers.dev/mappa/resolve"
+	"bennypowers.dev/mappa/trace"
+)
+
+// Schemas maps each schema name to the schema of the JSON output it
+// describes.
+var Schemas = map[string]func() *jsonschema.Schema{
+	"importmap": func() *jsonschema.Schema {
+		schema := jsonschema.For[importmap.ImportMap]()
+		schema.Title = "Import map"
+		schema.Description = "An import map, as written by mappa generate and mappa trace."
+		// generate --warnings adds the resolution warnings beside the map
+		warnings := jsonschema.For[[]resolve.LogEntry]()
+		warnings.Schema = ""
+		warnings.Description = "Resolution warnings, written by mappa generate --warnings. Browsers ignore this key."
+		schema.Properties["warnings"] = warnings
+		return schema
+	},
+	"trace-result": func() *jsonschema.Schema {
+		schema := jsonschema.For[trace.BatchResult]()
+		schema.Title = "Trace result"
+		schema.Description = "The result of tracing one file, written as one line of mappa trace's batch output."
+		return schema
+	},
+}
+
+// Cmd is the schema cobra command that prints the JSON Schema of mappa's
+// JSON output.
+var Cmd = &cobra.Command{
+	Use:   "schema <" + strings.Join(names(), "|") + ">",
+	Short: "Print the JSON Schema of mappa's JSON output",
+	Long: `Print a JSON Schema describing one of mappa's JSON outputs, for
+validating it or generating types from it in other languages.
+
+The schemas are:
+  importmap     an import map, as written by generate and trace
+  trace-result  one line of trace's batch (NDJSON) output`,
+	Example: `  # Schema for import maps
+  mappa schema importmap
+
+  # Generate TypeScript types for batch trace results
+  mappa schema trace-result -o trace-result.schema.json
+  npx json-schema-to-typescript trace-result.schema.json`,
+	ValidArgs: names(),
+	Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	RunE:      run,
+}
+
+func names() []string {
+	return slices.Sorted(func(yield func(string) bool) {
+		for name := range Schemas {
+			if !yield(name) {
+				return
+			}
+		}
+	})
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	data, err := json.MarshalIndent(Schemas[args[0]](), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal schema: %w", err)
+	}
+
+	return output.Write(fs.NewOSFileSystem(), string(data))
+}
@@ -23,25 +23,34 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/bmatcuk/doublestar/v4"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"bennypowers.dev/mappa/cdn"
 	"bennypowers.dev/mappa/fs"
+	"bennypowers.dev/mappa/importmap"
 	"bennypowers.dev/mappa/internal/output"
+	"bennypowers.dev/mappa/packagejson"
 	"bennypowers.dev/mappa/trace"
+	"bennypowers.dev/mappa/tsconfig"
 )
 
 // Cmd is the trace cobra command that analyzes HTML files to find ES module
 // imports and generates minimal import maps containing only the specifiers used.
 var Cmd = &cobra.Command{
-	Use:   "trace [file.html...]",
+	Use:   "trace [file.html... | URL]",
 	Short: "Trace HTML files and generate minimal import maps",
 	Long: `Trace HTML files to find all ES module imports and generate import maps.
 
 For a single file, outputs an import map containing only the specifiers actually used.
-For multiple files (via arguments or --glob), outputs NDJSON with one import map per line.
+For multiple files (via arguments or --glob), outputs NDJSON with one import map per line,
+or a single import map shared by all pages with --merge.
+An http(s) URL traces a deployed page, fetching its modules relative to the page URL
+and resolving bare specifiers from the local package.
 Use --format specifiers for debugging to see the raw trace output.`,
 	Example: `  # Trace a single HTML file
   mappa trace index.html
@@ -52,23 +61,93 @@ Use --format specifiers for debugging to see the raw trace output.`,
   # Trace files matching a glob pattern
   mappa trace --glob "_site/**/*.html"
 
+  # One import map for every page on the site
+  mappa trace --glob "_site/**/*.html" --merge -o importmap.json
+
+  # Write each page's import map to dist/maps/<page>.importmap.json
+  mappa trace --glob "_site/**/*.html" --output-dir dist/maps
+
   # Parallel processing with custom worker count
   mappa trace --glob "_site/**/*.html" -j 8
 
   # Custom URL template for resolved paths
   mappa trace index.html --template "/assets/{package}/{path}"
 
+  # Trace under several named condition sets (NDJSON tagged with variant)
+  mappa trace index.html --conditions-matrix "dev=development,default prod=production,default"
+
+  # Exclude lazily-loaded dynamic imports from the map
+  mappa trace index.html --static-only
+
+  # Leave scripts inside <template> elements out of the map
+  mappa trace index.html --skip-templates
+
+  # Hoist scoped entries into top-level imports (lossy)
+  mappa trace index.html --flatten-scopes
+
+  # Keep only top-level imports, warning about what scopes alone mapped
+  mappa trace index.html --no-scopes
+
+  # Resolve from declared package.json versions without node_modules
+  mappa trace index.html --assume-installed --template "https://esm.sh/{package}@{version}/{path}"
+
+  # Best-effort batch: exit zero, with failures in the NDJSON error field
+  mappa trace --glob "_site/**/*.html" --ignore-errors
+
+  # Find where a slow batch trace spends its time
+  mappa trace --glob "_site/**/*.html" --timings --cpuprofile trace.prof
+
+  # Site deployed under a subpath
+  mappa trace index.html --base-href /app/
+
+  # Fail CI if any bare specifier doesn't resolve to an installed file
+  mappa trace --glob "_site/**/*.html" --strict
+
+  # Seed the traced map with entries tracing can't discover
+  mappa trace index.html --input-map manual-imports.json
+
+  # Map tsconfig path aliases (e.g. @app/* -> src/*) to local files
+  mappa trace index.html --tsconfig tsconfig.json
+
+  # Trace a deployed page
+  mappa trace https://example.com/page.html
+
   # Output as HTML script tag (single file only)
-  mappa trace index.html --format html`,
+  mappa trace index.html --format html
+
+  # Output a plain {specifier: url} object (add --flatten-scopes to keep transitive entries)
+  mappa trace index.html --format flat
+
+  # Output <link rel="modulepreload"> hints for the traced modules (single file only)
+  mappa trace index.html --format preload`,
 	RunE: run,
 }
 
 func init() {
-	Cmd.Flags().StringP("format", "f", "json", "Output format (json, html, specifiers)")
+	Cmd.Flags().StringP("format", "f", "json", "Output format (json, html, specifiers, preload, flat, esm)")
 	Cmd.Flags().String("template", "", "URL template (default: /node_modules/{package}/{path})")
-	Cmd.Flags().StringSlice("conditions", nil, "Export condition priority (e.g., production,browser,import,default)")
+	Cmd.Flags().StringSlice("conditions", nil, "Export condition priority (e.g., production,browser,import,default); prefix with ! to never match a condition (e.g., !node)")
+	Cmd.Flags().StringArray("conditions-matrix", nil, "Named condition sets to trace under, as name=cond,... (space-separated or repeated); outputs NDJSON tagged with variant")
 	Cmd.Flags().String("glob", "", "Glob pattern to match HTML files (e.g., \"_site/**/*.html\")")
+	Cmd.Flags().Bool("ignore-errors", false, "Exit zero even if files fail to trace, reporting the failure count to stderr")
+	Cmd.Flags().Bool("merge", false, "Output a single import map covering every traced file instead of NDJSON")
+	Cmd.Flags().String("output-dir", "", "Write one import map per traced file to this directory instead of NDJSON, named by the file's path relative to the inputs' common directory")
+	Cmd.Flags().String("output-suffix", ".importmap.json", "With --output-dir, suffix replacing each HTML file's extension")
+	Cmd.Flags().Bool("dedupe", false, "Instead of NDJSON, output one JSON document holding each distinct import map once and the map ID of every traced file")
 	Cmd.Flags().IntP("jobs", "j", 0, "Number of parallel workers (default: number of CPUs)")
+	Cmd.Flags().Bool("development", false, "Prepend the development condition to the condition list")
+	Cmd.Flags().Bool("static-only", false, "Skip dynamic import() specifiers when tracing")
+	Cmd.Flags().Bool("skip-templates", false, "Skip module scripts inside <template> elements when tracing")
+	Cmd.Flags().Bool("assume-installed", false, "Resolve specifiers by template expansion using package.json versions, without node_modules")
+	Cmd.Flags().Bool("flatten-scopes", false, "Flatten scopes into top-level imports (lossy)")
+	Cmd.Flags().Bool("no-scopes", false, "Drop scopes, keeping only top-level imports, and warn about scoped specifiers they don't map")
+	Cmd.Flags().Int("inline-below", 0, "Inline resolved JavaScript modules without imports smaller than this many bytes as data: URLs")
+	Cmd.Flags().Bool("stats", false, "Print the import map's entry counts and raw and gzipped JSON size to stderr")
+	Cmd.Flags().Bool("timings", false, "Print time spent parsing HTML, extracting imports, and resolving to stderr")
+	Cmd.Flags().String("base-href", "", "Prefix root-relative URLs and scope keys with a base path (e.g., /app/)")
+	Cmd.Flags().Bool("strict", false, "Fail if a traced bare specifier doesn't resolve to an existing file under node_modules")
+	Cmd.Flags().String("input-map", "", "Import map file to merge into each traced map (input map takes precedence)")
+	Cmd.Flags().String("tsconfig", "", "tsconfig.json whose compilerOptions.paths aliases resolve to local files, traced like relative imports")
 }
 
 func run(cmd *cobra.Command, args []string) error {
@@ -82,8 +161,13 @@ func run(cmd *cobra.Command, args []string) error {
 	// Collect files from args and glob pattern, deduplicating by absolute path
 	seen := make(map[string]struct{})
 	var files []string
+	var pageURLs []string
 
 	for _, arg := range args {
+		if trace.IsPageURL(arg) {
+			pageURLs = append(pageURLs, arg)
+			continue
+		}
 		absPath, err := filepath.Abs(arg)
 		if err != nil {
 			return fmt.Errorf("invalid file path %q: %w", arg, err)
@@ -113,7 +197,7 @@ func run(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	if len(files) == 0 {
+	if len(files) == 0 && len(pageURLs) == 0 {
 		return fmt.Errorf("no files to trace: provide file arguments or use --glob")
 	}
 
@@ -121,36 +205,151 @@ func run(cmd *cobra.Command, args []string) error {
 
 	// Validate format flag
 	switch format {
-	case "json", "html", "specifiers":
+	case "json", "html", "specifiers", "preload", "flat", "esm":
 		// valid
 	default:
-		return fmt.Errorf("invalid format %q: must be one of json, html, specifiers", format)
+		return fmt.Errorf("invalid format %q: must be one of json, html, specifiers, preload, flat, esm", format)
 	}
 
 	// Build trace options from flags
 	templateArg, _ := cmd.Flags().GetString("template")
 	conditions, _ := cmd.Flags().GetStringSlice("conditions")
+	if development, _ := cmd.Flags().GetBool("development"); development {
+		conditions = packagejson.DevelopmentConditions(conditions)
+	}
 	parallel, _ := cmd.Flags().GetInt("jobs")
+	staticOnly, _ := cmd.Flags().GetBool("static-only")
+	skipTemplates, _ := cmd.Flags().GetBool("skip-templates")
+	flattenScopes, _ := cmd.Flags().GetBool("flatten-scopes")
+	noScopes, _ := cmd.Flags().GetBool("no-scopes")
+	assumeInstalled, _ := cmd.Flags().GetBool("assume-installed")
+	baseHref, _ := cmd.Flags().GetString("base-href")
+	inlineBelow, _ := cmd.Flags().GetInt("inline-below")
+	strict, _ := cmd.Flags().GetBool("strict")
 
 	opts := trace.Options{
-		Template:   templateArg,
-		Conditions: conditions,
-		Parallel:   parallel,
+		Template:        templateArg,
+		Conditions:      conditions,
+		Parallel:        parallel,
+		StaticOnly:      staticOnly,
+		SkipTemplates:   skipTemplates,
+		FlattenScopes:   flattenScopes,
+		AssumeInstalled: assumeInstalled,
+		BaseHref:        baseHref,
+		InlineBelow:     inlineBelow,
+		Strict:          strict,
+	}
+
+	if inputMapPath, _ := cmd.Flags().GetString("input-map"); inputMapPath != "" {
+		inputMapData, err := osfs.ReadFile(inputMapPath)
+		if err != nil {
+			return fmt.Errorf("failed to read input map: %w", err)
+		}
+		opts.InputMap, err = importmap.Parse(inputMapData)
+		if err != nil {
+			return fmt.Errorf("failed to parse input map: %w", err)
+		}
+	}
+
+	if tsconfigPath, _ := cmd.Flags().GetString("tsconfig"); tsconfigPath != "" {
+		absTSConfig, err := filepath.Abs(tsconfigPath)
+		if err != nil {
+			return fmt.Errorf("invalid tsconfig path: %w", err)
+		}
+		opts.TSConfig, err = tsconfig.ParseFile(osfs, absTSConfig)
+		if err != nil {
+			return fmt.Errorf("failed to read tsconfig: %w", err)
+		}
+	}
+
+	if timings, _ := cmd.Flags().GetBool("timings"); timings {
+		opts.Timings = &trace.Timings{}
+		start := time.Now()
+		defer func() {
+			fmt.Fprint(os.Stderr, opts.Timings.Summary(time.Since(start)))
+		}()
+	}
+
+	// Condition matrix mode
+	matrix, _ := cmd.Flags().GetStringArray("conditions-matrix")
+	merge, _ := cmd.Flags().GetBool("merge")
+	ignoreErrors, _ := cmd.Flags().GetBool("ignore-errors")
+	dedupe, _ := cmd.Flags().GetBool("dedupe")
+	if strict && (ignoreErrors || assumeInstalled || format == "specifiers") {
+		return fmt.Errorf("--strict cannot be combined with --ignore-errors, --assume-installed or --format specifiers")
+	}
+	if noScopes && (flattenScopes || format == "specifiers" || format == "preload") {
+		return fmt.Errorf("--no-scopes cannot be combined with --flatten-scopes, --format specifiers or --format preload")
+	}
+	outputDir, _ := cmd.Flags().GetString("output-dir")
+	stats, _ := cmd.Flags().GetBool("stats")
+	if stats && (format == "specifiers" || format == "preload" || outputDir != "" || len(matrix) > 0 || dedupe ||
+		(!merge && len(files)+len(pageURLs) > 1)) {
+		return fmt.Errorf("--stats needs a single import map: trace one file or URL, or use --merge, with an import map format")
+	}
+	mapOpts := mapOutput{stats: stats, noScopes: noScopes}
+	if dedupe && (merge || outputDir != "" || len(pageURLs) > 0) {
+		return fmt.Errorf("--dedupe cannot be combined with --merge, --output-dir or a URL")
+	}
+	if outputDir != "" {
+		if len(matrix) > 0 || merge || len(pageURLs) > 0 {
+			return fmt.Errorf("--output-dir cannot be combined with --conditions-matrix, --merge or a URL")
+		}
+		if format != "json" {
+			return fmt.Errorf("--format %s is not supported with --output-dir", format)
+		}
+		suffix, _ := cmd.Flags().GetString("output-suffix")
+		return runOutputDir(osfs, files, absRoot, outputDir, suffix, opts, ignoreErrors, noScopes)
+	}
+	if len(matrix) > 0 {
+		if merge {
+			return fmt.Errorf("--merge cannot be combined with --conditions-matrix")
+		}
+		if cmd.Flags().Changed("conditions") || cmd.Flags().Changed("development") {
+			return fmt.Errorf("--conditions-matrix cannot be combined with --conditions or --development")
+		}
+		variants, err := trace.ParseConditionsMatrix(matrix)
+		if err != nil {
+			return err
+		}
+		if len(pageURLs) > 0 {
+			return fmt.Errorf("--conditions-matrix is not supported when tracing a URL")
+		}
+		if format != "json" {
+			return fmt.Errorf("--format %s is not supported with --conditions-matrix", format)
+		}
+		return writeBatch(batchScopes(trace.TraceMatrix(osfs, files, absRoot, opts, variants), noScopes), ignoreErrors, dedupe, strict)
+	}
+
+	// Remote page mode
+	if len(pageURLs) > 0 {
+		if len(pageURLs) > 1 || len(files) > 0 {
+			return fmt.Errorf("a URL must be the only page to trace")
+		}
+		if merge {
+			return fmt.Errorf("--merge is not supported when tracing a URL")
+		}
+		return runURL(osfs, pageURLs[0], absRoot, format, opts, mapOpts)
+	}
+
+	// Merged mode
+	if merge {
+		return runMerged(osfs, files, absRoot, format, opts, ignoreErrors, mapOpts)
 	}
 
 	// Single file mode
-	if len(files) == 1 {
-		return runSingle(osfs, files[0], absRoot, format, opts)
+	if len(files) == 1 && !dedupe {
+		return runSingle(osfs, files[0], absRoot, format, opts, mapOpts)
 	}
 
 	// Batch mode
-	return runBatch(osfs, files, absRoot, format, opts)
+	return runBatch(osfs, files, absRoot, format, opts, ignoreErrors, dedupe, noScopes)
 }
 
-func runSingle(osfs fs.FileSystem, file, absRoot, format string, opts trace.Options) error {
+func runSingle(osfs fs.FileSystem, file, absRoot, format string, opts trace.Options, mapOpts mapOutput) error {
 	// Handle specifiers format separately
 	if format == "specifiers" {
-		result, issues, err := trace.TraceSpecifiers(osfs, file, absRoot)
+		result, issues, err := trace.TraceSpecifiers(osfs, file, absRoot, opts)
 		if err != nil {
 			return fmt.Errorf("failed to trace: %w", err)
 		}
@@ -165,11 +364,7 @@ func runSingle(osfs fs.FileSystem, file, absRoot, format string, opts trace.Opti
 		if err != nil {
 			return fmt.Errorf("failed to marshal specifiers result: %w", err)
 		}
-		if output := viper.GetString("output"); output != "" {
-			return osfs.WriteFile(output, append(out, '\n'), 0644)
-		}
-		fmt.Println(string(out))
-		return nil
+		return output.Write(osfs, string(out))
 	}
 
 	result, err := trace.TraceSingle(osfs, file, absRoot, opts)
@@ -183,20 +378,219 @@ func runSingle(osfs fs.FileSystem, file, absRoot, format string, opts trace.Opti
 		fmt.Fprintf(os.Stderr, "  Import %q references %s %q\n", issue.Specifier, issue.IssueType, issue.Package)
 	}
 
-	return output.ImportMap(osfs, result.ImportMap, format)
+	if format == "preload" {
+		return writePreload(osfs, result.Preload, result.CrossOrigin)
+	}
+	return writeImportMap(osfs, result.ImportMap, format, mapOpts)
 }
 
-func runBatch(osfs fs.FileSystem, files []string, absRoot, format string, opts trace.Options) error {
-	// html format doesn't make sense for batch mode
-	if format == "html" {
-		return fmt.Errorf("--format html is not supported for batch mode (multiple files)")
+func runURL(osfs fs.FileSystem, pageURL, absRoot, format string, opts trace.Options, mapOpts mapOutput) error {
+	if format == "specifiers" {
+		return fmt.Errorf("--format specifiers is not supported when tracing a URL")
+	}
+
+	fetcher := cdn.NewHTTPFetcherWithRetry(cdn.DefaultRetryOptions)
+	result, err := trace.TraceURL(osfs, fetcher, pageURL, absRoot, opts)
+	if err != nil {
+		return fmt.Errorf("failed to trace: %w", err)
 	}
 
-	// Run batch trace
-	results := trace.TraceBatch(osfs, files, absRoot, opts)
+	if format == "preload" {
+		return writePreload(osfs, result.Preload, result.CrossOrigin)
+	}
+	return writeImportMap(osfs, result.ImportMap, format, mapOpts)
+}
 
-	// Collect results and output NDJSON
-	encoder := json.NewEncoder(os.Stdout)
+// writePreload writes one <link rel="modulepreload"> tag per URL to stdout,
+// or to the --output file, with the page's crossorigin setting.
+func writePreload(osfs fs.FileSystem, urls []string, crossOrigin string) error {
+	var out strings.Builder
+	for _, url := range urls {
+		out.WriteString(trace.PreloadLink(url, crossOrigin))
+		out.WriteByte('\n')
+	}
+	if outputPath := viper.GetString("output"); outputPath != "" {
+		return osfs.WriteFile(outputPath, []byte(out.String()), 0644)
+	}
+	fmt.Print(out.String())
+	return nil
+}
+
+func runMerged(osfs fs.FileSystem, files []string, absRoot, format string, opts trace.Options, ignoreErrors bool, mapOpts mapOutput) error {
+	if format == "specifiers" || format == "preload" {
+		return fmt.Errorf("--format %s is not supported with --merge", format)
+	}
+
+	im, errs, err := trace.TraceMerged(osfs, files, absRoot, opts)
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+	if ignoreErrors {
+		fmt.Fprintf(os.Stderr, "%d of %d files failed to trace\n", len(errs), len(files))
+	} else if len(errs) == len(files) {
+		return fmt.Errorf("all %d files failed to trace", len(errs))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to resolve: %w", err)
+	}
+
+	return writeImportMap(osfs, im, format, mapOpts)
+}
+
+// mapOutput holds the flags that apply when writing a single import map.
+type mapOutput struct {
+	// stats prints the map's size to stderr.
+	stats bool
+	// noScopes drops the map's scopes.
+	noScopes bool
+}
+
+// writeImportMap outputs im in format, first dropping its scopes and
+// printing its size to stderr as mapOpts requests.
+func writeImportMap(osfs fs.FileSystem, im *importmap.ImportMap, format string, mapOpts mapOutput) error {
+	if mapOpts.noScopes {
+		var lost []string
+		im, lost = im.WithoutScopes()
+		warnLostSpecifiers("", lost)
+	}
+	if mapOpts.stats {
+		fmt.Fprint(os.Stderr, im.Stats())
+	}
+	return output.ImportMap(osfs, im, format)
+}
+
+// batchScopes drops the scopes of each batch result when noScopes is set,
+// warning about the specifiers only they mapped.
+func batchScopes(results <-chan trace.BatchResult, noScopes bool) <-chan trace.BatchResult {
+	if !noScopes {
+		return results
+	}
+	out := make(chan trace.BatchResult)
+	go func() {
+		defer close(out)
+		for result := range results {
+			if result.Scopes != nil {
+				im := &importmap.ImportMap{Imports: result.Imports, Scopes: result.Scopes}
+				_, lost := im.WithoutScopes()
+				warnLostSpecifiers(result.File, lost)
+				result.Scopes = nil
+			}
+			out <- result
+		}
+	}()
+	return out
+}
+
+// warnLostSpecifiers prints a warning to stderr listing the specifiers that
+// --no-scopes left unmapped, prefixed with file in batch mode.
+func warnLostSpecifiers(file string, lost []string) {
+	if len(lost) == 0 {
+		return
+	}
+	prefix := ""
+	if file != "" {
+		prefix = file + ": "
+	}
+	fmt.Fprintf(os.Stderr, "Warning: %s--no-scopes leaves specifiers that only scopes mapped unresolvable: %s\n", prefix, strings.Join(lost, ", "))
+}
+
+func runBatch(osfs fs.FileSystem, files []string, absRoot, format string, opts trace.Options, ignoreErrors, dedupe, noScopes bool) error {
+	// Batch mode always outputs NDJSON import maps
+	if format == "html" || format == "flat" || format == "esm" || format == "preload" || (dedupe && format != "json") {
+		return fmt.Errorf("--format %s is not supported for batch mode (multiple files)", format)
+	}
+
+	return writeBatch(batchScopes(trace.TraceBatch(osfs, files, absRoot, opts), noScopes), ignoreErrors, dedupe, opts.Strict)
+}
+
+// runOutputDir traces files in batch mode, writing each file's import map to
+// its own file under outputDir rather than NDJSON to stdout.
+func runOutputDir(osfs fs.FileSystem, files []string, absRoot, outputDir, suffix string, opts trace.Options, ignoreErrors, noScopes bool) error {
+	targets, err := outputPaths(files, outputDir, suffix)
+	if err != nil {
+		return err
+	}
+
+	return writeResults(batchScopes(trace.TraceBatch(osfs, files, absRoot, opts), noScopes), ignoreErrors, opts.Strict, func(result trace.BatchResult) error {
+		if result.Error != "" {
+			fmt.Fprintf(os.Stderr, "Error: %s: %s\n", result.File, result.Error)
+			return nil
+		}
+		target := targets[result.File]
+		if err := osfs.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		im := &importmap.ImportMap{Imports: result.Imports, Scopes: result.Scopes}
+		return osfs.WriteFile(target, []byte(im.Format("json")+"\n"), 0644)
+	})
+}
+
+// outputPaths maps each file to its import map path under outputDir: the
+// file's path relative to the directory containing every file, with its
+// extension replaced by suffix. Two files mapping to the same path, such as
+// page.html and page.htm, are an error.
+func outputPaths(files []string, outputDir, suffix string) (map[string]string, error) {
+	base := filepath.Dir(files[0])
+	for _, file := range files {
+		for !strings.HasPrefix(file, base+string(filepath.Separator)) && filepath.Dir(base) != base {
+			base = filepath.Dir(base)
+		}
+	}
+
+	targets := make(map[string]string, len(files))
+	sources := make(map[string]string, len(files))
+	for _, file := range files {
+		rel, err := filepath.Rel(base, file)
+		if err != nil {
+			return nil, fmt.Errorf("invalid file path %q: %w", file, err)
+		}
+		target := filepath.Join(outputDir, strings.TrimSuffix(rel, filepath.Ext(rel))+suffix)
+		if other, exists := sources[target]; exists {
+			return nil, fmt.Errorf("%s and %s would both be written to %s", other, file, target)
+		}
+		sources[target] = file
+		targets[file] = target
+	}
+	return targets, nil
+}
+
+// writeBatch writes batch results to stdout as NDJSON, printing warnings to stderr.
+// With dedupe, it instead writes a single trace.DedupedBatch once every result
+// is in. Returns an error if every result failed, or with strict if any did,
+// unless ignoreErrors is set, in which case the failure count is printed to
+// stderr instead.
+func writeBatch(results <-chan trace.BatchResult, ignoreErrors, dedupe, strict bool) error {
+	if !dedupe {
+		encoder := json.NewEncoder(os.Stdout)
+		return writeResults(results, ignoreErrors, strict, func(result trace.BatchResult) error {
+			return encoder.Encode(result)
+		})
+	}
+
+	var collected []trace.BatchResult
+	err := writeResults(results, ignoreErrors, strict, func(result trace.BatchResult) error {
+		collected = append(collected, result)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	batch, err := trace.DedupeResults(collected)
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(batch, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal deduplicated results: %w", err)
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+// writeResults passes each batch result to emit, with its warnings removed and
+// printed to stderr once every result is written. Error handling follows
+// writeBatch.
+func writeResults(results <-chan trace.BatchResult, ignoreErrors, strict bool, emit func(trace.BatchResult) error) error {
 	var allWarnings []trace.Warning
 	var errorCount int
 	var totalCount int
@@ -210,8 +604,8 @@ func runBatch(osfs fs.FileSystem, files []string, absRoot, format string, opts t
 		allWarnings = append(allWarnings, result.Warnings...)
 		// Clear warnings from JSON output (they go to stderr)
 		result.Warnings = nil
-		if err := encoder.Encode(result); err != nil {
-			fmt.Fprintf(os.Stderr, "Error encoding result for %s: %v\n", result.File, err)
+		if err := emit(result); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing result for %s: %v\n", result.File, err)
 		}
 	}
 
@@ -221,8 +615,15 @@ func runBatch(osfs fs.FileSystem, files []string, absRoot, format string, opts t
 		fmt.Fprintf(os.Stderr, "  Import %q references %s %q\n", w.Specifier, w.IssueType, w.Package)
 	}
 
+	if ignoreErrors {
+		fmt.Fprintf(os.Stderr, "%d of %d files failed to trace\n", errorCount, totalCount)
+		return nil
+	}
 	if errorCount == totalCount {
 		return fmt.Errorf("all %d files failed to trace", errorCount)
 	}
+	if strict && errorCount > 0 {
+		return fmt.Errorf("%d of %d files failed to trace", errorCount, totalCount)
+	}
 	return nil
 }
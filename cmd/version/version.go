@@ -19,24 +19,56 @@ along with this program. If not, see <http://www.gnu.org/licenses/>.
 package version
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/spf13/cobra"
 
+	"bennypowers.dev/mappa/cdn"
 	"bennypowers.dev/mappa/internal/version"
 )
 
+// ExitOutdated is the exit status of version --check when a newer release
+// is available.
+const ExitOutdated = 2
+
+// checkTimeout bounds the request for the latest release.
+const checkTimeout = 10 * time.Second
+
 // Cmd is the version cobra command that prints version and build information.
 var Cmd = &cobra.Command{
 	Use:   "version",
 	Short: "Print version information",
-	Long:  `Print version information for mappa.`,
-	RunE:  run,
+	Long: `Print version information for mappa.
+
+With --check, also fetches the latest release from GitHub and exits with
+status 2 if this build is behind it.`,
+	Example: `  # Check whether a newer release is available
+  mappa version --check`,
+	RunE: run,
+}
+
+// OutdatedError reports that a newer release is available. Its exit code
+// distinguishes being out of date from other failures.
+type OutdatedError struct {
+	Current string
+	Latest  string
+}
+
+func (e *OutdatedError) Error() string {
+	return fmt.Sprintf("mappa %s is behind the latest release %s", e.Current, e.Latest)
+}
+
+// ExitCode returns ExitOutdated.
+func (e *OutdatedError) ExitCode() int {
+	return ExitOutdated
 }
 
 func init() {
 	Cmd.Flags().StringP("format", "f", "text", "Output format (text, json)")
+	Cmd.Flags().Bool("check", false, "Compare with the latest GitHub release (requires network access)")
 }
 
 func run(cmd *cobra.Command, args []string) error {
@@ -44,16 +76,41 @@ func run(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("error reading format flag: %w", err)
 	}
+	check, _ := cmd.Flags().GetBool("check")
+
+	var update *version.Update
+	if check {
+		ctx, cancel := context.WithTimeout(cmd.Context(), checkTimeout)
+		defer cancel()
+		update, err = version.CheckForUpdate(ctx, cdn.NewHTTPFetcher(), version.GetVersion())
+		if err != nil {
+			return err
+		}
+	}
+
 	switch format {
 	case "json":
 		buildInfo := version.GetBuildInfo()
+		if update != nil {
+			buildInfo["latest"] = update.Latest
+			buildInfo["behind"] = fmt.Sprint(update.Behind)
+		}
 		out, err := json.MarshalIndent(buildInfo, "", "  ")
 		if err != nil {
 			return fmt.Errorf("error marshaling version info: %w", err)
 		}
 		fmt.Println(string(out))
 	default:
-		fmt.Printf("mappa %s\n", version.GetVersion())
+		if update != nil {
+			fmt.Printf("mappa %s (latest release: %s)\n", update.Current, update.Latest)
+		} else {
+			fmt.Printf("mappa %s\n", version.GetVersion())
+		}
+	}
+
+	if update != nil && update.Behind {
+		cmd.SilenceUsage = true
+		return &OutdatedError{Current: update.Current, Latest: update.Latest}
 	}
 	return nil
 }
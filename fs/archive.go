@@ -0,0 +1,390 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package fs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ArchiveFileSystem is a read-only FileSystem serving the contents of a zip or
+// tar archive (optionally gzip-compressed) as if extracted to a root directory.
+// It lets a node_modules cached as a single CI artifact be resolved without
+// unpacking it. Writes fail with fs.ErrPermission.
+type ArchiveFileSystem struct {
+	root  string
+	files archiveFiles
+}
+
+// OpenArchive reads the archive at archivePath and mounts it at root.
+func OpenArchive(archivePath, root string) (*ArchiveFileSystem, error) {
+	data, err := os.ReadFile(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	return NewArchiveFileSystem(data, root)
+}
+
+// NewArchiveFileSystem mounts the archive in data at root, so that the entry
+// "node_modules/lit/package.json" is read as root/node_modules/lit/package.json.
+// The format is detected from the data: zip, tar, or gzip-compressed tar.
+// Symlinks are followed when their target is also in the archive, including
+// chains of links such as pnpm's node_modules/x -> .pnpm/x@1.0.0/node_modules/x.
+func NewArchiveFileSystem(data []byte, root string) (*ArchiveFileSystem, error) {
+	var files archiveFiles
+	var err error
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")), bytes.HasPrefix(data, []byte("PK\x05\x06")):
+		files, err = readZip(data)
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		var gz *gzip.Reader
+		gz, err = gzip.NewReader(bytes.NewReader(data))
+		if err == nil {
+			files, err = readTar(gz)
+		}
+	case len(data) > 262 && string(data[257:262]) == "ustar":
+		files, err = readTar(bytes.NewReader(data))
+	default:
+		return nil, errors.New("unsupported archive format: expected zip, tar, or tar.gz")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	return &ArchiveFileSystem{
+		root:  filepath.ToSlash(filepath.Clean(root)),
+		files: files,
+	}, nil
+}
+
+// readZip loads the files and directories of a zip archive, then copies
+// symlink targets into place. A zip symlink is an entry with ModeSymlink whose
+// content is the link target.
+func readZip(data []byte) (archiveFiles, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	files := make(archiveFiles)
+	links := make(map[string]string)
+	for _, f := range zr.File {
+		name, ok := archiveEntryName(f.Name)
+		if !ok {
+			continue
+		}
+		if f.FileInfo().IsDir() {
+			files[name] = &archiveFile{mode: fs.ModeDir | 0755, modTime: f.Modified}
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		content, err := io.ReadAll(rc)
+		_ = rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		if f.Mode()&fs.ModeSymlink != 0 {
+			if target, ok := archiveEntryName(path.Join(path.Dir(name), string(content))); ok {
+				links[name] = target
+			}
+			continue
+		}
+		files[name] = &archiveFile{data: content, mode: f.Mode().Perm(), modTime: f.Modified}
+	}
+	if err := resolveArchiveLinks(files, links); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// readTar loads the files and directories of a tar stream, then copies
+// symlink targets into place.
+func readTar(r io.Reader) (archiveFiles, error) {
+	files := make(archiveFiles)
+	links := make(map[string]string)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name, ok := archiveEntryName(hdr.Name)
+		if !ok {
+			continue
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			files[name] = &archiveFile{mode: fs.ModeDir | 0755, modTime: hdr.ModTime}
+		case tar.TypeReg:
+			content, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			files[name] = &archiveFile{data: content, mode: fs.FileMode(hdr.Mode).Perm(), modTime: hdr.ModTime}
+		case tar.TypeSymlink, tar.TypeLink:
+			target := hdr.Linkname
+			if hdr.Typeflag == tar.TypeSymlink {
+				target = path.Join(path.Dir(name), target)
+			}
+			if target, ok := archiveEntryName(target); ok {
+				links[name] = target
+			}
+		}
+	}
+
+	if err := resolveArchiveLinks(files, links); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// resolveArchiveLinks copies the targets of links into place. A link may
+// point to another link, or into a directory reached through one, so passes
+// repeat until nothing new is copied. Each pass resolves at least one more
+// link in every chain, so len(links) passes suffice. Links that lead back to
+// their own ancestors would be copied into themselves forever, so they are
+// rejected first.
+func resolveArchiveLinks(files archiveFiles, links map[string]string) error {
+	if err := checkArchiveLinkCycles(links); err != nil {
+		return err
+	}
+	names := slices.Sorted(maps.Keys(links))
+	for range len(links) {
+		copied := false
+		for _, link := range names {
+			if copyArchiveEntries(files, link, links[link]) {
+				copied = true
+			}
+		}
+		if !copied {
+			break
+		}
+	}
+	return nil
+}
+
+// checkArchiveLinkCycles returns an error if copying a link's target would
+// copy the link itself, directly or through other links. Copying a link
+// copies every link inside its target, so the links form a graph that must
+// be acyclic. Paths are compared after resolving the links along them.
+func checkArchiveLinkCycles(links map[string]string) error {
+	names := slices.Sorted(maps.Keys(links))
+	locations := make(map[string]string, len(links))
+	targets := make(map[string]string, len(links))
+	for _, name := range names {
+		parent, err := realArchivePath(path.Dir(name), links)
+		if err != nil {
+			return err
+		}
+		target, err := realArchivePath(links[name], links)
+		if err != nil {
+			return err
+		}
+		locations[name] = path.Join(parent, path.Base(name))
+		targets[name] = target
+	}
+
+	const (
+		inProgress = 1
+		done       = 2
+	)
+	state := make(map[string]int, len(links))
+	var visit func(name string) error
+	visit = func(name string) error {
+		state[name] = inProgress
+		for _, other := range names {
+			location := locations[other]
+			if location != targets[name] && !strings.HasPrefix(location, targets[name]+"/") {
+				continue
+			}
+			switch state[other] {
+			case inProgress:
+				return fmt.Errorf("symlink cycle: %s -> %s leads back to %s", name, links[name], other)
+			case 0:
+				if err := visit(other); err != nil {
+					return err
+				}
+			}
+		}
+		state[name] = done
+		return nil
+	}
+	for _, name := range names {
+		if state[name] == 0 {
+			if err := visit(name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// realArchivePath resolves the links along name, returning the path of the
+// entry as stored in the archive. Links that resolve through themselves are
+// an error.
+func realArchivePath(name string, links map[string]string) (string, error) {
+	visited := make(map[string]bool)
+	for {
+		resolved := false
+		for p := name; p != "."; p = path.Dir(p) {
+			target, ok := links[p]
+			if !ok {
+				continue
+			}
+			if visited[p] {
+				return "", fmt.Errorf("symlink cycle: %s -> %s", p, target)
+			}
+			visited[p] = true
+			name = path.Join(target, strings.TrimPrefix(name, p))
+			resolved = true
+			break
+		}
+		if !resolved {
+			return name, nil
+		}
+	}
+}
+
+// copyArchiveEntries copies the file or directory tree at target to link,
+// reporting whether any entry was new.
+func copyArchiveEntries(files archiveFiles, link, target string) bool {
+	if f, ok := files[target]; ok && !f.mode.IsDir() {
+		_, exists := files[link]
+		files[link] = f
+		return !exists
+	}
+	prefix := target + "/"
+	var copies []string
+	for name := range files {
+		if strings.HasPrefix(name, prefix) {
+			copies = append(copies, name)
+		}
+	}
+	copied := false
+	for _, name := range copies {
+		dest := link + "/" + strings.TrimPrefix(name, prefix)
+		if _, exists := files[dest]; !exists {
+			files[dest] = files[name]
+			copied = true
+		}
+	}
+	return copied
+}
+
+// archiveEntryName normalizes an archive entry name to a relative slash path,
+// rejecting names that escape the archive root.
+func archiveEntryName(name string) (string, bool) {
+	name = path.Clean(strings.TrimPrefix(strings.TrimLeft(name, "/"), "./"))
+	if name == "." || name == ".." || strings.HasPrefix(name, "../") {
+		return "", false
+	}
+	return name, true
+}
+
+// archivePath maps an absolute path to its name within the archive.
+func (a *ArchiveFileSystem) archivePath(op, name string) (string, error) {
+	slashed := filepath.ToSlash(filepath.Clean(name))
+	if slashed == a.root {
+		return ".", nil
+	}
+	prefix := strings.TrimSuffix(a.root, "/") + "/"
+	if !strings.HasPrefix(slashed, prefix) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return strings.TrimPrefix(slashed, prefix), nil
+}
+
+// WriteFile fails; the archive is read-only.
+func (a *ArchiveFileSystem) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return &fs.PathError{Op: "write", Path: name, Err: fs.ErrPermission}
+}
+
+// ReadFile reads the entire contents of a file in the archive.
+func (a *ArchiveFileSystem) ReadFile(name string) ([]byte, error) {
+	p, err := a.archivePath("read", name)
+	if err != nil {
+		return nil, err
+	}
+	return a.files.readFile(p)
+}
+
+// Remove fails; the archive is read-only.
+func (a *ArchiveFileSystem) Remove(name string) error {
+	return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrPermission}
+}
+
+// MkdirAll fails; the archive is read-only.
+func (a *ArchiveFileSystem) MkdirAll(path string, perm fs.FileMode) error {
+	return &fs.PathError{Op: "mkdir", Path: path, Err: fs.ErrPermission}
+}
+
+// ReadDir reads the named directory in the archive.
+func (a *ArchiveFileSystem) ReadDir(name string) ([]fs.DirEntry, error) {
+	p, err := a.archivePath("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	return a.files.readDir(p)
+}
+
+// TempDir returns the default directory for temporary files.
+func (a *ArchiveFileSystem) TempDir() string {
+	return os.TempDir()
+}
+
+// Stat returns file information for a file or directory in the archive.
+// The root and directories implied by file paths always exist.
+func (a *ArchiveFileSystem) Stat(name string) (fs.FileInfo, error) {
+	p, err := a.archivePath("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return a.files.stat(p)
+}
+
+// Exists returns true if the path exists in the archive.
+func (a *ArchiveFileSystem) Exists(path string) bool {
+	_, err := a.Stat(path)
+	return err == nil
+}
+
+// Open opens the named file in the archive for reading.
+func (a *ArchiveFileSystem) Open(name string) (fs.File, error) {
+	p, err := a.archivePath("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return a.files.open(p)
+}
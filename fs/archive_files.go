@@ -0,0 +1,172 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package fs
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// archiveFile is a file or directory read from an archive.
+type archiveFile struct {
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+// archiveFiles holds an archive's entries by slash-separated name relative
+// to the archive root. Directories are implied by the names of the entries
+// they contain, so an archive needn't list them.
+type archiveFiles map[string]*archiveFile
+
+// stat returns information about the named file or directory.
+func (files archiveFiles) stat(name string) (fs.FileInfo, error) {
+	if f, ok := files[name]; ok {
+		return &archiveFileInfo{name: path.Base(name), file: f}, nil
+	}
+	if files.isDir(name) {
+		return &archiveFileInfo{name: path.Base(name), file: &archiveFile{mode: fs.ModeDir | 0755}}, nil
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+// isDir reports whether name is the root or contains any entries.
+func (files archiveFiles) isDir(name string) bool {
+	if name == "." {
+		return true
+	}
+	prefix := name + "/"
+	for entry := range files {
+		if strings.HasPrefix(entry, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// readFile returns the contents of the named file.
+func (files archiveFiles) readFile(name string) ([]byte, error) {
+	f, ok := files[name]
+	if !ok || f.mode.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	return slices.Clone(f.data), nil
+}
+
+// readDir returns the entries of the named directory, sorted by name.
+func (files archiveFiles) readDir(name string) ([]fs.DirEntry, error) {
+	f, explicit := files[name]
+	if explicit && !f.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+	children := make(map[string]fs.DirEntry)
+	for entry := range files {
+		rest, ok := strings.CutPrefix(entry, prefix)
+		if !ok || rest == "" {
+			continue
+		}
+		child, _, _ := strings.Cut(rest, "/")
+		if _, seen := children[child]; seen {
+			continue
+		}
+		info, _ := files.stat(prefix + child)
+		children[child] = fs.FileInfoToDirEntry(info)
+	}
+	if len(children) == 0 && !explicit && name != "." {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	entries := make([]fs.DirEntry, 0, len(children))
+	for _, child := range slices.Sorted(maps.Keys(children)) {
+		entries = append(entries, children[child])
+	}
+	return entries, nil
+}
+
+// open opens the named file or directory for reading.
+func (files archiveFiles) open(name string) (fs.File, error) {
+	info, err := files.stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if !info.IsDir() {
+		return &openArchiveFile{info: info, Reader: bytes.NewReader(files[name].data)}, nil
+	}
+	entries, err := files.readDir(name)
+	if err != nil {
+		return nil, err
+	}
+	return &openArchiveDir{info: info, entries: entries}, nil
+}
+
+// archiveFileInfo describes an archiveFile.
+type archiveFileInfo struct {
+	name string
+	file *archiveFile
+}
+
+func (i *archiveFileInfo) Name() string       { return i.name }
+func (i *archiveFileInfo) Size() int64        { return int64(len(i.file.data)) }
+func (i *archiveFileInfo) Mode() fs.FileMode  { return i.file.mode }
+func (i *archiveFileInfo) ModTime() time.Time { return i.file.modTime }
+func (i *archiveFileInfo) IsDir() bool        { return i.file.mode.IsDir() }
+func (i *archiveFileInfo) Sys() any           { return nil }
+
+// openArchiveFile is an archive file opened for reading.
+type openArchiveFile struct {
+	info fs.FileInfo
+	*bytes.Reader
+}
+
+func (f *openArchiveFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *openArchiveFile) Close() error               { return nil }
+
+// openArchiveDir is an archive directory opened for reading.
+type openArchiveDir struct {
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *openArchiveDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *openArchiveDir) Close() error               { return nil }
+
+func (d *openArchiveDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.Name(), Err: fs.ErrInvalid}
+}
+
+// ReadDir implements fs.ReadDirFile.
+func (d *openArchiveDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n > 0 && len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > 0 && n < len(rest) {
+		rest = rest[:n]
+	}
+	d.offset += len(rest)
+	return rest, nil
+}
@@ -0,0 +1,267 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package fs_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"bennypowers.dev/mappa/fs"
+	"bennypowers.dev/mappa/resolve/local"
+)
+
+var _ fs.FileSystem = (*fs.ArchiveFileSystem)(nil)
+
+// fixtureFiles reads every file under a testdata directory, keyed by slash path.
+func fixtureFiles(t *testing.T, dir string) map[string][]byte {
+	t.Helper()
+	root := filepath.Join("..", "testdata", dir)
+	files := make(map[string][]byte)
+	err := filepath.WalkDir(root, func(p string, d iofs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		files[filepath.ToSlash(rel)] = data
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to read fixture %s: %v", dir, err)
+	}
+	return files
+}
+
+func zipArchive(t *testing.T, files map[string][]byte, symlinks map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, target := range symlinks {
+		hdr := &zip.FileHeader{Name: name}
+		hdr.SetMode(iofs.ModeSymlink | 0777)
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(target)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func tarGzArchive(t *testing.T, files map[string][]byte, symlinks map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, data := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: "./" + name, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, target := range symlinks {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Linkname: target, Typeflag: tar.TypeSymlink}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestArchiveFileSystemResolve(t *testing.T) {
+	files := fixtureFiles(t, "resolve/simple-pkg")
+	expected := map[string]string{
+		"lit":               "/node_modules/lit/index.js",
+		"lit/decorators.js": "/node_modules/lit/decorators.js",
+		"lit/decorators/":   "/node_modules/lit/decorators/",
+	}
+
+	archives := map[string][]byte{
+		"zip":    zipArchive(t, files, nil),
+		"tar.gz": tarGzArchive(t, files, nil),
+	}
+	for format, data := range archives {
+		t.Run(format, func(t *testing.T) {
+			afs, err := fs.NewArchiveFileSystem(data, "/project")
+			if err != nil {
+				t.Fatalf("NewArchiveFileSystem failed: %v", err)
+			}
+
+			result, err := local.New(afs, nil).Resolve("/project")
+			if err != nil {
+				t.Fatalf("Resolve failed: %v", err)
+			}
+			if !reflect.DeepEqual(result.Simplify().Imports, expected) {
+				t.Errorf("Imports = %v, want %v", result.Simplify().Imports, expected)
+			}
+		})
+	}
+}
+
+func TestArchiveFileSystem(t *testing.T) {
+	files := map[string][]byte{
+		"package.json":                  []byte(`{"name": "app"}`),
+		"packages/ui/package.json":      []byte(`{"name": "ui"}`),
+		"node_modules/lit/package.json": []byte(`{"name": "lit"}`),
+	}
+	data := tarGzArchive(t, files, map[string]string{"node_modules/ui": "../packages/ui"})
+
+	afs, err := fs.NewArchiveFileSystem(data, "/project")
+	if err != nil {
+		t.Fatalf("NewArchiveFileSystem failed: %v", err)
+	}
+
+	content, err := afs.ReadFile("/project/node_modules/lit/package.json")
+	if err != nil || string(content) != `{"name": "lit"}` {
+		t.Errorf("ReadFile = %q, %v", content, err)
+	}
+
+	// Symlinked directories are followed
+	content, err = afs.ReadFile("/project/node_modules/ui/package.json")
+	if err != nil || string(content) != `{"name": "ui"}` {
+		t.Errorf("ReadFile through symlink = %q, %v", content, err)
+	}
+
+	if stat, err := afs.Stat("/project/node_modules"); err != nil || !stat.IsDir() {
+		t.Errorf("Expected /project/node_modules to be a directory, got %v, %v", stat, err)
+	}
+	if !afs.Exists("/project") {
+		t.Error("Expected archive root to exist")
+	}
+	if afs.Exists("/project/missing.json") || afs.Exists("/elsewhere/package.json") {
+		t.Error("Expected paths missing from the archive not to exist")
+	}
+
+	entries, err := afs.ReadDir("/project/node_modules")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if !reflect.DeepEqual(names, []string{"lit", "ui"}) {
+		t.Errorf("ReadDir = %v, want [lit ui]", names)
+	}
+
+	var walked []string
+	err = iofs.WalkDir(afs, "/project/packages", func(p string, d iofs.DirEntry, err error) error {
+		walked = append(walked, p)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WalkDir failed: %v", err)
+	}
+	if want := []string{"/project/packages", "/project/packages/ui", "/project/packages/ui/package.json"}; !reflect.DeepEqual(walked, want) {
+		t.Errorf("WalkDir = %v, want %v", walked, want)
+	}
+
+	if err := afs.WriteFile("/project/out.json", nil, 0644); !errors.Is(err, iofs.ErrPermission) {
+		t.Errorf("Expected WriteFile to fail with ErrPermission, got %v", err)
+	}
+	if err := afs.MkdirAll("/project/dist", 0755); !errors.Is(err, iofs.ErrPermission) {
+		t.Errorf("Expected MkdirAll to fail with ErrPermission, got %v", err)
+	}
+}
+
+func TestArchiveFileSystemChainedSymlinks(t *testing.T) {
+	// pnpm-style chain: node_modules/b -> store/b -> store/a. The outer link
+	// sorts before the one it points through, so resolution must revisit it.
+	files := map[string][]byte{"store/a/index.js": []byte("export {};")}
+	symlinks := map[string]string{
+		"store/b":        "a",
+		"node_modules/b": "../store/b",
+	}
+	archives := map[string][]byte{
+		"zip":    zipArchive(t, files, symlinks),
+		"tar.gz": tarGzArchive(t, files, symlinks),
+	}
+	for format, data := range archives {
+		t.Run(format, func(t *testing.T) {
+			afs, err := fs.NewArchiveFileSystem(data, "/r")
+			if err != nil {
+				t.Fatalf("NewArchiveFileSystem failed: %v", err)
+			}
+			for _, p := range []string{"/r/store/b/index.js", "/r/node_modules/b/index.js"} {
+				if !afs.Exists(p) {
+					t.Errorf("Expected %s to exist", p)
+				}
+			}
+		})
+	}
+}
+
+func TestArchiveFileSystemSymlinkCycle(t *testing.T) {
+	// Each link points to an ancestor of itself, so copying either target
+	// copies the link again, and each copy contains the other link.
+	files := map[string][]byte{"a/index.js": []byte("export {};")}
+	symlinks := map[string]string{
+		"a/loop":   ".",
+		"a/b/loop": "..",
+	}
+	archives := map[string][]byte{
+		"zip":    zipArchive(t, files, symlinks),
+		"tar.gz": tarGzArchive(t, files, symlinks),
+	}
+	for format, data := range archives {
+		t.Run(format, func(t *testing.T) {
+			if _, err := fs.NewArchiveFileSystem(data, "/r"); err == nil || !strings.Contains(err.Error(), "symlink cycle") {
+				t.Errorf("Expected a symlink cycle error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestNewArchiveFileSystemUnsupported(t *testing.T) {
+	if _, err := fs.NewArchiveFileSystem([]byte("not an archive"), "/project"); err == nil {
+		t.Error("Expected error for unsupported archive format")
+	}
+}
@@ -21,6 +21,7 @@ package fs
 import (
 	"io/fs"
 	"os"
+	"path/filepath"
 )
 
 // FileSystem provides an abstraction over filesystem operations.
@@ -45,6 +46,28 @@ type FileSystem interface {
 	Open(name string) (fs.File, error)
 }
 
+// LinkTarget returns the directory or file the symbolic link at name points
+// to, resolved against the link's directory. Returns false when name isn't a
+// symbolic link or fsys can't report links (see io/fs.ReadLinkFS).
+func LinkTarget(fsys FileSystem, name string) (string, bool) {
+	links, ok := fsys.(fs.ReadLinkFS)
+	if !ok {
+		return "", false
+	}
+	info, err := links.Lstat(name)
+	if err != nil || info.Mode()&fs.ModeSymlink == 0 {
+		return "", false
+	}
+	target, err := links.ReadLink(name)
+	if err != nil {
+		return "", false
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(name), target)
+	}
+	return filepath.Clean(target), true
+}
+
 // OSFileSystem implements FileSystem using the standard os package.
 type OSFileSystem struct{}
 
@@ -83,6 +106,17 @@ func (f *OSFileSystem) Stat(name string) (fs.FileInfo, error) {
 	return os.Stat(name)
 }
 
+// Lstat returns file information for the named file without following
+// symbolic links.
+func (f *OSFileSystem) Lstat(name string) (fs.FileInfo, error) {
+	return os.Lstat(name)
+}
+
+// ReadLink returns the destination of the named symbolic link.
+func (f *OSFileSystem) ReadLink(name string) (string, error) {
+	return os.Readlink(name)
+}
+
 // Exists returns true if the path exists.
 func (f *OSFileSystem) Exists(path string) bool {
 	_, err := os.Stat(path)
@@ -0,0 +1,180 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package importmap
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Difference describes a specifier whose normalized target differs between
+// two import maps. An empty Left or Right means the specifier is missing
+// from that map.
+type Difference struct {
+	// Scope is the normalized scope, or "" for top-level imports.
+	Scope     string `json:"scope,omitempty"`
+	Specifier string `json:"specifier"`
+	Left      string `json:"left,omitempty"`
+	Right     string `json:"right,omitempty"`
+}
+
+// CompareNormalized diffs two import maps after reducing every URL to its
+// package name and subpath, so that maps generated for the same project
+// against different hosts compare equal. For example,
+// "/node_modules/lit/index.js" and "https://esm.sh/lit@3.1.0/index.js" both
+// normalize to "lit/index.js". Scope keys are normalized the same way and
+// integrity is ignored. Returns nil when the maps are equivalent.
+func CompareNormalized(left, right *ImportMap) []Difference {
+	if left == nil {
+		left = &ImportMap{}
+	}
+	if right == nil {
+		right = &ImportMap{}
+	}
+
+	diffs := compareImports("", normalizeImports(left.Imports), normalizeImports(right.Imports))
+
+	leftScopes := normalizeScopes(left.Scopes)
+	rightScopes := normalizeScopes(right.Scopes)
+	allScopes := maps.Clone(leftScopes)
+	maps.Copy(allScopes, rightScopes)
+	for _, scope := range slices.Sorted(maps.Keys(allScopes)) {
+		diffs = append(diffs, compareImports(scope, leftScopes[scope], rightScopes[scope])...)
+	}
+
+	return diffs
+}
+
+// NormalizeURL reduces an import map URL to a package name and subpath,
+// dropping the origin, any path prefix before the package, and the version.
+// The specifier, when it is a bare specifier, is used as a hint to locate the
+// package within the URL; pass "" when there is none (e.g., for scope keys).
+// URLs that contain no recognizable package are returned as paths without
+// origin or leading slash.
+func NormalizeURL(url, specifier string) string {
+	p := url
+	if i := strings.Index(p, "://"); i >= 0 {
+		p = p[i+3:]
+		if j := strings.Index(p, "/"); j >= 0 {
+			p = p[j:]
+		} else {
+			p = "/"
+		}
+	}
+	if i := strings.IndexAny(p, "?#"); i >= 0 {
+		p = p[:i]
+	}
+
+	if i := strings.LastIndex(p, "/node_modules/"); i >= 0 {
+		return p[i+len("/node_modules/"):]
+	}
+
+	segments := strings.Split(strings.TrimPrefix(p, "/"), "/")
+	if start, ok := findPackageSegment(segments, packageName(specifier)); ok {
+		return strings.Join(segments[start:], "/")
+	}
+	return strings.TrimPrefix(p, "/")
+}
+
+// findPackageSegment finds where a package begins in a URL's path segments.
+// With a package name it matches the first occurrence of that name, with or
+// without an "@version" suffix; otherwise it matches the first "name@version"
+// segment. The version is stripped from segments in place. Returns the index
+// of the package's first segment (its scope, for scoped packages).
+func findPackageSegment(segments []string, name string) (int, bool) {
+	if name != "" {
+		want := strings.Split(name, "/")
+		n := len(want)
+		for i := 0; i+n <= len(segments); i++ {
+			if !slices.Equal(segments[i:i+n-1], want[:n-1]) {
+				continue
+			}
+			last := segments[i+n-1]
+			if last == want[n-1] || strings.HasPrefix(last, want[n-1]+"@") {
+				segments[i+n-1] = want[n-1]
+				return i, true
+			}
+		}
+		return 0, false
+	}
+
+	for i, seg := range segments {
+		at := strings.LastIndex(seg, "@")
+		if at <= 0 {
+			continue
+		}
+		segments[i] = seg[:at]
+		if i > 0 && strings.HasPrefix(segments[i-1], "@") {
+			return i - 1, true
+		}
+		return i, true
+	}
+	return 0, false
+}
+
+// packageName returns the package name of a bare specifier, or "" if the
+// specifier is not bare.
+func packageName(specifier string) string {
+	if specifier == "" || strings.HasPrefix(specifier, ".") || strings.HasPrefix(specifier, "/") || strings.Contains(specifier, "://") {
+		return ""
+	}
+	parts := strings.SplitN(specifier, "/", 3)
+	if strings.HasPrefix(specifier, "@") && len(parts) >= 2 {
+		return parts[0] + "/" + parts[1]
+	}
+	return parts[0]
+}
+
+// normalizeImports normalizes the targets of an imports map.
+func normalizeImports(imports map[string]string) map[string]string {
+	result := make(map[string]string, len(imports))
+	for key, value := range imports {
+		result[key] = NormalizeURL(value, key)
+	}
+	return result
+}
+
+// normalizeScopes normalizes scope keys and their targets. Scopes that
+// normalize to the same key are merged.
+func normalizeScopes(scopes map[string]map[string]string) map[string]map[string]string {
+	result := make(map[string]map[string]string, len(scopes))
+	for scope, imports := range scopes {
+		key := NormalizeURL(scope, "")
+		if result[key] == nil {
+			result[key] = make(map[string]string, len(imports))
+		}
+		maps.Copy(result[key], normalizeImports(imports))
+	}
+	return result
+}
+
+// compareImports reports differences between two normalized imports maps, sorted by specifier.
+func compareImports(scope string, left, right map[string]string) []Difference {
+	var diffs []Difference
+	for _, key := range slices.Sorted(maps.Keys(left)) {
+		if left[key] != right[key] {
+			diffs = append(diffs, Difference{Scope: scope, Specifier: key, Left: left[key], Right: right[key]})
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(right)) {
+		if _, ok := left[key]; !ok {
+			diffs = append(diffs, Difference{Scope: scope, Specifier: key, Right: right[key]})
+		}
+	}
+	return diffs
+}
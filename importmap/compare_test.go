@@ -0,0 +1,92 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package importmap_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"bennypowers.dev/mappa/importmap"
+	"bennypowers.dev/mappa/testutil"
+)
+
+func TestCompareNormalized(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "importmap/compare-normalized", "/test")
+
+	load := func(name string) *importmap.ImportMap {
+		t.Helper()
+		data, err := mfs.ReadFile("/test/" + name)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", name, err)
+		}
+		im, err := importmap.Parse(data)
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", name, err)
+		}
+		return im
+	}
+
+	local := load("local.json")
+	cdn := load("cdn.json")
+
+	t.Run("equivalent modulo host", func(t *testing.T) {
+		if diffs := importmap.CompareNormalized(local, cdn); diffs != nil {
+			t.Errorf("Expected no differences, got %+v", diffs)
+		}
+	})
+
+	t.Run("reports differences", func(t *testing.T) {
+		expectedData, err := mfs.ReadFile("/test/expected.json")
+		if err != nil {
+			t.Fatalf("Failed to read expected.json: %v", err)
+		}
+		var expected []importmap.Difference
+		if err := json.Unmarshal(expectedData, &expected); err != nil {
+			t.Fatalf("Failed to parse expected.json: %v", err)
+		}
+
+		diffs := importmap.CompareNormalized(local, load("jsdelivr-partial.json"))
+		if !reflect.DeepEqual(diffs, expected) {
+			t.Errorf("Differences mismatch:\n  got:      %+v\n  expected: %+v", diffs, expected)
+		}
+	})
+}
+
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct {
+		url       string
+		specifier string
+		expected  string
+	}{
+		{"/node_modules/lit/index.js", "lit", "lit/index.js"},
+		{"https://esm.sh/lit@3.1.0/index.js", "lit", "lit/index.js"},
+		{"https://cdn.jsdelivr.net/npm/@lit/context@1.1.0/", "@lit/context/", "@lit/context/"},
+		{"/assets/packages/lit/decorators.js", "lit/decorators.js", "lit/decorators.js"},
+		{"https://unpkg.com/lit-html@3.1.0/lit-html.js?module", "", "lit-html/lit-html.js"},
+		{"https://esm.sh/@lit/reactive-element@2.0.4/", "", "@lit/reactive-element/"},
+		{"/src/index.js", "my-app", "src/index.js"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			if got := importmap.NormalizeURL(tt.url, tt.specifier); got != tt.expected {
+				t.Errorf("NormalizeURL(%q, %q) = %q, want %q", tt.url, tt.specifier, got, tt.expected)
+			}
+		})
+	}
+}
@@ -19,9 +19,15 @@ along with this program. If not, see <http://www.gnu.org/licenses/>.
 package importmap
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"maps"
+	"net/url"
+	"slices"
 	"strings"
+
+	"golang.org/x/net/html"
 )
 
 // ImportMap represents an ES module import map.
@@ -35,8 +41,27 @@ type ImportMap struct {
 
 	// Integrity maps module URLs to their expected subresource integrity values.
 	Integrity map[string]string `json:"integrity,omitempty"`
+
+	// Provenance maps top-level import specifiers to the source that produced
+	// them, such as ProvenanceDependency or ProvenanceInputMap. It is populated
+	// during resolution for debugging and is never serialized.
+	Provenance map[string]string `json:"-"`
 }
 
+// Provenance sources recorded for top-level imports.
+const (
+	// ProvenanceDependency marks entries resolved from package.json dependencies.
+	ProvenanceDependency = "dependency"
+	// ProvenanceRoot marks entries for the root package's own exports.
+	ProvenanceRoot = "root"
+	// ProvenanceWorkspace marks entries for workspace packages.
+	ProvenanceWorkspace = "workspace"
+	// ProvenanceTraced marks entries for specifiers discovered by tracing.
+	ProvenanceTraced = "traced"
+	// ProvenanceInputMap marks entries taken from a user-supplied input map.
+	ProvenanceInputMap = "input-map"
+)
+
 // Parse parses JSON data into an ImportMap.
 func Parse(data []byte) (*ImportMap, error) {
 	var im ImportMap
@@ -46,19 +71,104 @@ func Parse(data []byte) (*ImportMap, error) {
 	return &im, nil
 }
 
+// FromHTML parses the import map in the first <script type="importmap"> tag of
+// HTML content. found is false if the content has no import map tag; err is
+// set if it has one whose JSON can't be parsed.
+func FromHTML(content []byte) (im *ImportMap, found bool, err error) {
+	tokenizer := html.NewTokenizer(bytes.NewReader(content))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return nil, false, nil
+		case html.StartTagToken:
+			name, hasAttr := tokenizer.TagName()
+			if string(name) != "script" || !hasAttr || !isImportMapScript(tokenizer) {
+				continue
+			}
+			var data []byte
+			if tokenizer.Next() == html.TextToken {
+				data = tokenizer.Raw()
+			}
+			im, err := Parse(data)
+			if err != nil {
+				return nil, true, fmt.Errorf("parsing import map: %w", err)
+			}
+			return im, true, nil
+		}
+	}
+}
+
+// isImportMapScript reports whether the script start tag the tokenizer is on
+// has type="importmap".
+func isImportMapScript(tokenizer *html.Tokenizer) bool {
+	for {
+		key, val, more := tokenizer.TagAttr()
+		if string(key) == "type" && string(val) == "importmap" {
+			return true
+		}
+		if !more {
+			return false
+		}
+	}
+}
+
+// Conflict records an entry that a merge overrode with a different URL.
+type Conflict struct {
+	Key      string // The specifier
+	Scope    string // The scope prefix, or "" for a top-level import
+	Base     string // The URL in the base map, which was overridden
+	Override string // The URL in the overriding map, which won
+}
+
+// String describes the conflict, e.g. "lit" (/node_modules/lit/index.js -> /vendor/lit.js).
+func (c Conflict) String() string {
+	if c.Scope == "" {
+		return fmt.Sprintf("%q (%s -> %s)", c.Key, c.Base, c.Override)
+	}
+	return fmt.Sprintf("%q in scope %q (%s -> %s)", c.Key, c.Scope, c.Base, c.Override)
+}
+
 // Merge combines this import map with another, with the other taking precedence.
 // The result is a new ImportMap; neither input is modified.
 func (im *ImportMap) Merge(other *ImportMap) *ImportMap {
+	result, _ := im.MergeWithConflicts(other)
+	return result
+}
+
+// MergeWithConflicts merges like Merge and also reports each top-level or
+// scoped import that other overrode with a different URL, sorted by scope and
+// then key. Entries both maps agree on are not conflicts.
+func (im *ImportMap) MergeWithConflicts(other *ImportMap) (*ImportMap, []Conflict) {
 	if im == nil {
 		if other == nil {
-			return &ImportMap{}
+			return &ImportMap{}, nil
 		}
-		return other.Clone()
+		return other.Clone(), nil
 	}
 	if other == nil {
-		return im.Clone()
+		return im.Clone(), nil
 	}
 
+	var conflicts []Conflict
+	for key, override := range other.Imports {
+		if base, ok := im.Imports[key]; ok && base != override {
+			conflicts = append(conflicts, Conflict{Key: key, Base: base, Override: override})
+		}
+	}
+	for scope, imports := range other.Scopes {
+		for key, override := range imports {
+			if base, ok := im.Scopes[scope][key]; ok && base != override {
+				conflicts = append(conflicts, Conflict{Key: key, Scope: scope, Base: base, Override: override})
+			}
+		}
+	}
+	slices.SortFunc(conflicts, func(a, b Conflict) int {
+		if c := strings.Compare(a.Scope, b.Scope); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Key, b.Key)
+	})
+
 	result := &ImportMap{
 		Imports:   make(map[string]string),
 		Scopes:    make(map[string]map[string]string),
@@ -69,6 +179,21 @@ func (im *ImportMap) Merge(other *ImportMap) *ImportMap {
 	maps.Copy(result.Imports, im.Imports)
 	maps.Copy(result.Imports, other.Imports)
 
+	// Overridden entries take the other map's provenance, or none if it has none
+	if im.Provenance != nil || other.Provenance != nil {
+		result.Provenance = maps.Clone(im.Provenance)
+		if result.Provenance == nil {
+			result.Provenance = make(map[string]string)
+		}
+		for key := range other.Imports {
+			if source, ok := other.Provenance[key]; ok {
+				result.Provenance[key] = source
+			} else {
+				delete(result.Provenance, key)
+			}
+		}
+	}
+
 	// Copy base scopes
 	for scope, imports := range im.Scopes {
 		result.Scopes[scope] = make(map[string]string, len(imports))
@@ -96,8 +221,11 @@ func (im *ImportMap) Merge(other *ImportMap) *ImportMap {
 	if len(result.Integrity) == 0 {
 		result.Integrity = nil
 	}
+	if len(result.Provenance) == 0 {
+		result.Provenance = nil
+	}
 
-	return result
+	return result, conflicts
 }
 
 // Clone creates a deep copy of the import map.
@@ -126,6 +254,26 @@ func (im *ImportMap) Clone() *ImportMap {
 		maps.Copy(result.Integrity, im.Integrity)
 	}
 
+	result.Provenance = maps.Clone(im.Provenance)
+
+	return result
+}
+
+// WithProvenance returns a copy of the import map with every top-level
+// import attributed to source, replacing any existing provenance.
+func (im *ImportMap) WithProvenance(source string) *ImportMap {
+	if im == nil {
+		return nil
+	}
+
+	result := im.Clone()
+	result.Provenance = nil
+	if len(im.Imports) > 0 {
+		result.Provenance = make(map[string]string, len(im.Imports))
+		for key := range im.Imports {
+			result.Provenance[key] = source
+		}
+	}
 	return result
 }
 
@@ -173,6 +321,23 @@ func (im *ImportMap) Simplify() *ImportMap {
 		result.Integrity = nil
 	}
 
+	result.Provenance = retainProvenance(im.Provenance, result.Imports)
+
+	return result
+}
+
+// retainProvenance returns the provenance entries for keys still present in imports.
+func retainProvenance(provenance, imports map[string]string) map[string]string {
+	var result map[string]string
+	for key, source := range provenance {
+		if _, ok := imports[key]; !ok {
+			continue
+		}
+		if result == nil {
+			result = make(map[string]string)
+		}
+		result[key] = source
+	}
 	return result
 }
 
@@ -220,6 +385,366 @@ func simplifyImports(imports map[string]string) map[string]string {
 	return result
 }
 
+// FlattenScopes hoists scoped entries into the top-level imports and drops all scopes.
+// Existing top-level entries take precedence; when scopes disagree on a specifier,
+// the scope that sorts first wins. This is lossy: modules that relied on a scope
+// to load a different version will receive the top-level mapping instead.
+// Returns a new ImportMap; the original is not modified.
+func (im *ImportMap) FlattenScopes() *ImportMap {
+	if im == nil {
+		return nil
+	}
+
+	result := im.Clone()
+	result.Scopes = nil
+
+	for _, scope := range slices.Sorted(maps.Keys(im.Scopes)) {
+		for key, value := range im.Scopes[scope] {
+			if _, exists := result.Imports[key]; exists {
+				continue
+			}
+			if result.Imports == nil {
+				result.Imports = make(map[string]string)
+			}
+			result.Imports[key] = value
+		}
+	}
+
+	return result
+}
+
+// WithoutScopes drops all scopes, keeping only the top-level imports, for flat
+// node_modules layouts where every module resolves dependencies the same way.
+// Unlike FlattenScopes, no scoped entries are hoisted. It also returns the
+// scoped specifiers the top-level imports don't map, sorted, since modules
+// importing them would fail to resolve.
+// Returns a new ImportMap; the original is not modified.
+func (im *ImportMap) WithoutScopes() (*ImportMap, []string) {
+	if im == nil {
+		return nil, nil
+	}
+
+	result := im.Clone()
+	result.Scopes = nil
+
+	var lost []string
+	for _, imports := range im.Scopes {
+		for key := range imports {
+			if _, matched := resolveImportsMatch(key, im.Imports); !matched && !slices.Contains(lost, key) {
+				lost = append(lost, key)
+			}
+		}
+	}
+	slices.Sort(lost)
+
+	return result, lost
+}
+
+// Subset returns a new ImportMap containing only the entries needed by the given specifiers.
+// Top-level imports are kept when their key matches a specifier exactly, or when
+// a trailing-slash key is a prefix of a specifier. Scopes are kept when they cover
+// a retained URL, including URLs mapped by other retained scopes. Integrity
+// entries are kept for retained URLs. The original is not modified.
+func (im *ImportMap) Subset(specifiers []string) *ImportMap {
+	return im.Filter(func(key string) bool {
+		return subsetMatches(key, specifiers)
+	})
+}
+
+// Filter returns a new ImportMap containing only the top-level imports whose keys
+// satisfy keep, along with the scopes reachable from them. Scopes are kept when
+// they cover a retained URL, including URLs mapped by other retained scopes.
+// Integrity entries are kept for retained URLs. The original is not modified.
+func (im *ImportMap) Filter(keep func(key string) bool) *ImportMap {
+	if im == nil {
+		return nil
+	}
+
+	result := &ImportMap{}
+	retained := make(map[string]bool)
+
+	for key, value := range im.Imports {
+		if !keep(key) {
+			continue
+		}
+		if result.Imports == nil {
+			result.Imports = make(map[string]string)
+		}
+		result.Imports[key] = value
+		retained[value] = true
+	}
+
+	// Keep scopes covering retained URLs until no new scopes are added,
+	// since scoped dependencies may themselves need their own scopes
+	for changed := true; changed; {
+		changed = false
+		for scope, imports := range im.Scopes {
+			if _, kept := result.Scopes[scope]; kept || !coversAny(scope, retained) {
+				continue
+			}
+			if result.Scopes == nil {
+				result.Scopes = make(map[string]map[string]string)
+			}
+			result.Scopes[scope] = maps.Clone(imports)
+			for _, value := range imports {
+				retained[value] = true
+			}
+			changed = true
+		}
+	}
+
+	for url, hash := range im.Integrity {
+		if !retained[url] {
+			continue
+		}
+		if result.Integrity == nil {
+			result.Integrity = make(map[string]string)
+		}
+		result.Integrity[url] = hash
+	}
+
+	result.Provenance = retainProvenance(im.Provenance, result.Imports)
+
+	return result
+}
+
+// subsetMatches reports whether an import key is needed by any of the specifiers.
+func subsetMatches(key string, specifiers []string) bool {
+	for _, spec := range specifiers {
+		if key == spec || (strings.HasSuffix(key, "/") && strings.HasPrefix(spec, key)) {
+			return true
+		}
+	}
+	return false
+}
+
+// coversAny reports whether a scope prefix covers any of the URLs.
+func coversAny(scope string, urls map[string]bool) bool {
+	for url := range urls {
+		if strings.HasPrefix(url, scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// Rebase prefixes every root-relative URL in the import map with prefix, for sites
+// deployed under a subpath. Import and scope values, scope keys, and integrity keys
+// are rewritten, so "/node_modules/lit/index.js" rebased onto "/app/" becomes
+// "/app/node_modules/lit/index.js". Absolute URLs, protocol-relative URLs, and
+// relative paths are left untouched. Returns a new ImportMap; the original is not modified.
+func (im *ImportMap) Rebase(prefix string) *ImportMap {
+	if im == nil {
+		return nil
+	}
+
+	base := strings.TrimSuffix(prefix, "/")
+	if base != "" && !strings.HasPrefix(base, "/") && !strings.Contains(base, "://") {
+		base = "/" + base
+	}
+	rebase := func(url string) string {
+		if base == "" || !strings.HasPrefix(url, "/") || strings.HasPrefix(url, "//") {
+			return url
+		}
+		return base + url
+	}
+	rebaseValues := func(m map[string]string) map[string]string {
+		result := make(map[string]string, len(m))
+		for key, url := range m {
+			result[key] = rebase(url)
+		}
+		return result
+	}
+
+	result := &ImportMap{Provenance: maps.Clone(im.Provenance)}
+
+	if im.Imports != nil {
+		result.Imports = rebaseValues(im.Imports)
+	}
+
+	if im.Scopes != nil {
+		result.Scopes = make(map[string]map[string]string, len(im.Scopes))
+		for scope, imports := range im.Scopes {
+			result.Scopes[rebase(scope)] = rebaseValues(imports)
+		}
+	}
+
+	if im.Integrity != nil {
+		result.Integrity = make(map[string]string, len(im.Integrity))
+		for url, hash := range im.Integrity {
+			result.Integrity[rebase(url)] = hash
+		}
+	}
+
+	return result
+}
+
+// ResolveURLs resolves the import map's relative URLs against baseURL, as a
+// browser does when parsing the map, so that scope keys match absolute referrer
+// URLs. Scope keys are always resolved; import values, URL-like specifier keys,
+// and integrity keys are resolved when they start with "/", "./", or "../".
+// Bare specifiers and absolute URLs are left untouched. Returns an error if
+// baseURL is not an absolute URL. The original is not modified.
+func (im *ImportMap) ResolveURLs(baseURL string) (*ImportMap, error) {
+	if im == nil {
+		return nil, nil
+	}
+
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL %q: %w", baseURL, err)
+	}
+	if !base.IsAbs() {
+		return nil, fmt.Errorf("base URL %q is not absolute", baseURL)
+	}
+
+	resolveURL := func(raw string) string {
+		ref, err := url.Parse(raw)
+		if err != nil {
+			return raw
+		}
+		return base.ResolveReference(ref).String()
+	}
+	resolveURLLike := func(raw string) string {
+		if strings.HasPrefix(raw, "/") || strings.HasPrefix(raw, "./") || strings.HasPrefix(raw, "../") {
+			return resolveURL(raw)
+		}
+		return raw
+	}
+	resolveImports := func(m map[string]string) map[string]string {
+		result := make(map[string]string, len(m))
+		for key, value := range m {
+			result[resolveURLLike(key)] = resolveURLLike(value)
+		}
+		return result
+	}
+
+	result := &ImportMap{}
+	if im.Provenance != nil {
+		result.Provenance = make(map[string]string, len(im.Provenance))
+		for key, source := range im.Provenance {
+			result.Provenance[resolveURLLike(key)] = source
+		}
+	}
+
+	if im.Imports != nil {
+		result.Imports = resolveImports(im.Imports)
+	}
+
+	if im.Scopes != nil {
+		result.Scopes = make(map[string]map[string]string, len(im.Scopes))
+		for scope, imports := range im.Scopes {
+			result.Scopes[resolveURL(scope)] = resolveImports(imports)
+		}
+	}
+
+	if im.Integrity != nil {
+		result.Integrity = make(map[string]string, len(im.Integrity))
+		for key, hash := range im.Integrity {
+			result.Integrity[resolveURLLike(key)] = hash
+		}
+	}
+
+	return result, nil
+}
+
+// Len returns the total number of specifier mappings in imports and all scopes.
+func (im *ImportMap) Len() int {
+	if im == nil {
+		return 0
+	}
+	n := len(im.Imports)
+	for _, imports := range im.Scopes {
+		n += len(imports)
+	}
+	return n
+}
+
+// VerifyReachable calls fetch once for each distinct URL mapped by the import map's
+// imports and scopes, and returns the sorted URLs for which fetch returned an error.
+// Trailing-slash mappings are skipped, since their targets are path prefixes rather
+// than modules. Returns nil when every URL is reachable.
+func (im *ImportMap) VerifyReachable(fetch func(url string) error) []string {
+	if im == nil {
+		return nil
+	}
+
+	urls := make(map[string]bool)
+	collect := func(imports map[string]string) {
+		for key, url := range imports {
+			if !strings.HasSuffix(key, "/") {
+				urls[url] = true
+			}
+		}
+	}
+	collect(im.Imports)
+	for _, imports := range im.Scopes {
+		collect(imports)
+	}
+
+	var dead []string
+	for _, url := range slices.Sorted(maps.Keys(urls)) {
+		if err := fetch(url); err != nil {
+			dead = append(dead, url)
+		}
+	}
+	return dead
+}
+
+// ResolveSpecifier resolves a specifier the way a browser would, following the
+// HTML import map resolution algorithm. Scopes that apply to referrerURL are tried
+// from most to least specific, then the top-level imports. Within each, an exact
+// key match wins over the longest trailing-slash prefix match. Returns false when
+// the specifier is unmapped, or when a prefix match maps to a URL without a
+// trailing slash, which browsers reject. Scope keys are compared with referrerURL
+// as strings, so both must be in the same form (e.g., root-relative).
+func (im *ImportMap) ResolveSpecifier(specifier, referrerURL string) (string, bool) {
+	if im == nil {
+		return "", false
+	}
+
+	scopes := slices.SortedFunc(maps.Keys(im.Scopes), func(a, b string) int {
+		return len(b) - len(a)
+	})
+	for _, scope := range scopes {
+		if scope != referrerURL && !(strings.HasSuffix(scope, "/") && strings.HasPrefix(referrerURL, scope)) {
+			continue
+		}
+		if url, matched := resolveImportsMatch(specifier, im.Scopes[scope]); matched {
+			return url, url != ""
+		}
+	}
+
+	if url, matched := resolveImportsMatch(specifier, im.Imports); matched {
+		return url, url != ""
+	}
+	return "", false
+}
+
+// resolveImportsMatch looks a specifier up in a specifier map: an exact key
+// first, then the longest trailing-slash key prefixing it. matched reports
+// whether any key applied; url is empty when the match is invalid.
+func resolveImportsMatch(specifier string, imports map[string]string) (url string, matched bool) {
+	if url, ok := imports[specifier]; ok {
+		return url, true
+	}
+
+	var best string
+	for key := range imports {
+		if strings.HasSuffix(key, "/") && strings.HasPrefix(specifier, key) && len(key) > len(best) {
+			best = key
+		}
+	}
+	if best == "" {
+		return "", false
+	}
+	target := imports[best]
+	if !strings.HasSuffix(target, "/") {
+		return "", true
+	}
+	return target + strings.TrimPrefix(specifier, best), true
+}
+
 // ToJSON converts the import map to an indented JSON string.
 // Returns an empty string if the import map is nil or entirely empty.
 func (im *ImportMap) ToJSON() string {
@@ -227,12 +752,114 @@ func (im *ImportMap) ToJSON() string {
 		return ""
 	}
 
-	bytes, err := json.MarshalIndent(im, "", "  ")
+	data, err := im.SortedMarshal()
 	if err != nil {
 		return ""
 	}
 
-	return string(bytes)
+	return string(data)
+}
+
+// SortedMarshal encodes the import map as indented JSON in a fully deterministic
+// order: imports, scopes, then integrity, with scopes sorted by scope key and every
+// map sorted by key. Empty sections are omitted, as with ToJSON.
+func (im *ImportMap) SortedMarshal() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+
+	var sections int
+	writeSection := func(name string, write func(indent string) error) error {
+		if sections > 0 {
+			buf.WriteByte(',')
+		}
+		sections++
+		buf.WriteString("\n  ")
+		if err := writeJSONString(&buf, name); err != nil {
+			return err
+		}
+		buf.WriteString(": ")
+		return write("  ")
+	}
+
+	if im != nil && len(im.Imports) > 0 {
+		if err := writeSection("imports", func(indent string) error {
+			return writeSortedObject(&buf, im.Imports, indent)
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if im != nil && len(im.Scopes) > 0 {
+		if err := writeSection("scopes", func(indent string) error {
+			buf.WriteByte('{')
+			for i, scope := range slices.Sorted(maps.Keys(im.Scopes)) {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				buf.WriteString("\n" + indent + "  ")
+				if err := writeJSONString(&buf, scope); err != nil {
+					return err
+				}
+				buf.WriteString(": ")
+				if err := writeSortedObject(&buf, im.Scopes[scope], indent+"  "); err != nil {
+					return err
+				}
+			}
+			buf.WriteString("\n" + indent + "}")
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if im != nil && len(im.Integrity) > 0 {
+		if err := writeSection("integrity", func(indent string) error {
+			return writeSortedObject(&buf, im.Integrity, indent)
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if sections > 0 {
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// writeSortedObject writes a string map as an indented JSON object with sorted keys.
+// indent is the indentation of the line the object starts on.
+func writeSortedObject(buf *bytes.Buffer, m map[string]string, indent string) error {
+	if len(m) == 0 {
+		buf.WriteString("{}")
+		return nil
+	}
+	buf.WriteByte('{')
+	for i, key := range slices.Sorted(maps.Keys(m)) {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString("\n" + indent + "  ")
+		if err := writeJSONString(buf, key); err != nil {
+			return err
+		}
+		buf.WriteString(": ")
+		if err := writeJSONString(buf, m[key]); err != nil {
+			return err
+		}
+	}
+	buf.WriteString("\n" + indent + "}")
+	return nil
+}
+
+// writeJSONString writes s as a JSON string literal, escaped as encoding/json does.
+func writeJSONString(buf *bytes.Buffer, s string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	buf.Write(data)
+	return nil
 }
 
 // MarshalJSON implements json.Marshaler.
@@ -241,6 +868,22 @@ func (im *ImportMap) MarshalJSON() ([]byte, error) {
 	return json.Marshal((*alias)(im))
 }
 
+// ToFlatJSON encodes only the top-level imports, as an indented JSON object
+// mapping each specifier directly to its URL with sorted keys, for scripts that
+// don't understand import maps. Scopes and integrity are dropped.
+// Returns "{}" if there are no imports.
+func (im *ImportMap) ToFlatJSON() string {
+	var imports map[string]string
+	if im != nil {
+		imports = im.Imports
+	}
+	var buf bytes.Buffer
+	if err := writeSortedObject(&buf, imports, ""); err != nil {
+		return "{}"
+	}
+	return buf.String()
+}
+
 // ToHTML wraps the import map JSON in an HTML script tag.
 func (im *ImportMap) ToHTML() string {
 	jsonStr := im.ToJSON()
@@ -250,13 +893,27 @@ func (im *ImportMap) ToHTML() string {
 	return "<script type=\"importmap\">\n" + jsonStr + "\n</script>"
 }
 
+// ToESM wraps the import map JSON in an ES module whose default export is the
+// import map object, for bundlers and scripts that import the map as data.
+func (im *ImportMap) ToESM() string {
+	jsonStr := im.ToJSON()
+	if jsonStr == "" {
+		jsonStr = "{}"
+	}
+	return "export default " + jsonStr + ";"
+}
+
 // Format returns the import map in the specified format.
-// Supported formats: "json" (default), "html".
+// Supported formats: "json" (default), "html", "flat", "esm".
 // Returns empty JSON object "{}" if the import map is empty.
 func (im *ImportMap) Format(format string) string {
 	switch format {
 	case "html":
 		return im.ToHTML()
+	case "flat":
+		return im.ToFlatJSON()
+	case "esm":
+		return im.ToESM()
 	default:
 		jsonStr := im.ToJSON()
 		if jsonStr == "" {
@@ -265,3 +922,53 @@ func (im *ImportMap) Format(format string) string {
 		return jsonStr
 	}
 }
+
+// String returns a readable, sorted text summary of the import map for
+// debugging: top-level imports, then each scope with its entries, then
+// integrity values. Import provenance is shown in parentheses when recorded.
+//
+//	imports:
+//	  lit  -> /node_modules/lit/index.js (dependency)
+//	scopes:
+//	  /node_modules/lit/:
+//	    lit-html  -> /node_modules/lit-html/lit-html.js
+func (im *ImportMap) String() string {
+	if im == nil || (len(im.Imports) == 0 && len(im.Scopes) == 0 && len(im.Integrity) == 0) {
+		return "(empty import map)\n"
+	}
+
+	var b strings.Builder
+	if len(im.Imports) > 0 {
+		b.WriteString("imports:\n")
+		writeSummaryEntries(&b, im.Imports, im.Provenance, "  ")
+	}
+	if len(im.Scopes) > 0 {
+		b.WriteString("scopes:\n")
+		for _, scope := range slices.Sorted(maps.Keys(im.Scopes)) {
+			b.WriteString("  " + scope + ":\n")
+			writeSummaryEntries(&b, im.Scopes[scope], nil, "    ")
+		}
+	}
+	if len(im.Integrity) > 0 {
+		b.WriteString("integrity:\n")
+		writeSummaryEntries(&b, im.Integrity, nil, "  ")
+	}
+	return b.String()
+}
+
+// writeSummaryEntries writes one sorted "key -> value" line per entry, with
+// keys padded so the arrows line up.
+func writeSummaryEntries(b *strings.Builder, entries, provenance map[string]string, indent string) {
+	keys := slices.Sorted(maps.Keys(entries))
+	width := 0
+	for _, key := range keys {
+		width = max(width, len(key))
+	}
+	for _, key := range keys {
+		b.WriteString(indent + key + strings.Repeat(" ", width-len(key)) + "  -> " + entries[key])
+		if source := provenance[key]; source != "" {
+			b.WriteString(" (" + source + ")")
+		}
+		b.WriteByte('\n')
+	}
+}
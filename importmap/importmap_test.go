@@ -18,7 +18,9 @@ package importmap_test
 
 import (
 	"encoding/json"
+	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 
 	"bennypowers.dev/mappa/importmap"
@@ -71,6 +73,64 @@ func TestParse(t *testing.T) {
 	}
 }
 
+func TestFromHTML(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "importmap/from-html", "/test")
+
+	t.Run("first import map", func(t *testing.T) {
+		content, err := mfs.ReadFile("/test/page.html")
+		if err != nil {
+			t.Fatalf("Failed to read page.html: %v", err)
+		}
+		expectedBytes, err := mfs.ReadFile("/test/expected.json")
+		if err != nil {
+			t.Fatalf("Failed to read expected.json: %v", err)
+		}
+		expected, err := importmap.Parse(expectedBytes)
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+
+		im, found, err := importmap.FromHTML(content)
+		if err != nil {
+			t.Fatalf("FromHTML failed: %v", err)
+		}
+		if !found {
+			t.Fatal("Expected an import map to be found")
+		}
+		if !reflect.DeepEqual(im, expected) {
+			t.Errorf("Expected %s, got %s", expected.ToJSON(), im.ToJSON())
+		}
+	})
+
+	t.Run("no import map", func(t *testing.T) {
+		content, err := mfs.ReadFile("/test/no-map.html")
+		if err != nil {
+			t.Fatalf("Failed to read no-map.html: %v", err)
+		}
+		im, found, err := importmap.FromHTML(content)
+		if err != nil {
+			t.Fatalf("FromHTML failed: %v", err)
+		}
+		if found || im != nil {
+			t.Errorf("Expected no import map, got found=%v %v", found, im)
+		}
+	})
+
+	t.Run("invalid JSON", func(t *testing.T) {
+		content, err := mfs.ReadFile("/test/invalid.html")
+		if err != nil {
+			t.Fatalf("Failed to read invalid.html: %v", err)
+		}
+		_, found, err := importmap.FromHTML(content)
+		if err == nil {
+			t.Fatal("Expected an error for invalid import map JSON")
+		}
+		if !found {
+			t.Error("Expected found=true for an import map tag with invalid JSON")
+		}
+	})
+}
+
 func TestMerge(t *testing.T) {
 	tests := []struct {
 		name string
@@ -127,6 +187,102 @@ func TestMerge(t *testing.T) {
 	}
 }
 
+func TestMergeWithConflicts(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "importmap/merge-conflicts", "/test")
+
+	baseData, err := mfs.ReadFile("/test/base.json")
+	if err != nil {
+		t.Fatalf("Failed to read base.json: %v", err)
+	}
+	overrideData, err := mfs.ReadFile("/test/override.json")
+	if err != nil {
+		t.Fatalf("Failed to read override.json: %v", err)
+	}
+	base, err := importmap.Parse(baseData)
+	if err != nil {
+		t.Fatalf("Failed to parse base: %v", err)
+	}
+	override, err := importmap.Parse(overrideData)
+	if err != nil {
+		t.Fatalf("Failed to parse override: %v", err)
+	}
+
+	expectedData, err := mfs.ReadFile("/test/expected.json")
+	if err != nil {
+		t.Fatalf("Failed to read expected.json: %v", err)
+	}
+	var expected struct {
+		Conflicts []struct {
+			Key      string `json:"key"`
+			Scope    string `json:"scope"`
+			Base     string `json:"base"`
+			Override string `json:"override"`
+		} `json:"conflicts"`
+	}
+	if err := json.Unmarshal(expectedData, &expected); err != nil {
+		t.Fatalf("Failed to parse expected: %v", err)
+	}
+
+	result, conflicts := base.MergeWithConflicts(override)
+
+	if len(conflicts) != len(expected.Conflicts) {
+		t.Fatalf("Expected %d conflicts, got %d: %v", len(expected.Conflicts), len(conflicts), conflicts)
+	}
+	for i, want := range expected.Conflicts {
+		got := conflicts[i]
+		if got.Key != want.Key || got.Scope != want.Scope || got.Base != want.Base || got.Override != want.Override {
+			t.Errorf("Conflict %d: expected %+v, got %+v", i, want, got)
+		}
+	}
+
+	// The result matches a plain Merge
+	if merged := base.Merge(override); !reflect.DeepEqual(result, merged) {
+		t.Errorf("Expected MergeWithConflicts to merge like Merge:\n  got:      %v\n  expected: %v", result, merged)
+	}
+
+	if got := conflicts[1].String(); got != `"lit" in scope "/node_modules/my-component/" (/node_modules/my-component/node_modules/lit/index.js -> /vendor/lit.js)` {
+		t.Errorf("Unexpected conflict description: %s", got)
+	}
+
+	var empty *importmap.ImportMap
+	if _, conflicts := empty.MergeWithConflicts(override); conflicts != nil {
+		t.Errorf("Expected no conflicts merging into a nil map, got %v", conflicts)
+	}
+}
+
+func TestMergeProvenance(t *testing.T) {
+	base := (&importmap.ImportMap{
+		Imports: map[string]string{
+			"lit":    "/node_modules/lit/index.js",
+			"slidem": "/node_modules/slidem/slidem.js",
+		},
+	}).WithProvenance(importmap.ProvenanceDependency)
+	override := (&importmap.ImportMap{
+		Imports: map[string]string{"slidem": "/vendor/slidem.js"},
+	}).WithProvenance(importmap.ProvenanceInputMap)
+
+	result := base.Merge(override)
+
+	expected := map[string]string{
+		"lit":    importmap.ProvenanceDependency,
+		"slidem": importmap.ProvenanceInputMap,
+	}
+	if !reflect.DeepEqual(result.Provenance, expected) {
+		t.Errorf("Provenance mismatch:\n  got:      %v\n  expected: %v", result.Provenance, expected)
+	}
+
+	// Overriding with an unattributed map clears provenance for its keys
+	result = base.Merge(&importmap.ImportMap{
+		Imports: map[string]string{"lit": "/vendor/lit.js"},
+	})
+	if _, ok := result.Provenance["lit"]; ok {
+		t.Errorf("Expected no provenance for overridden lit, got %q", result.Provenance["lit"])
+	}
+	if result.Provenance["slidem"] != importmap.ProvenanceDependency {
+		t.Errorf("Expected slidem provenance %q, got %q", importmap.ProvenanceDependency, result.Provenance["slidem"])
+	}
+}
+
 func TestToJSON(t *testing.T) {
 	im := &importmap.ImportMap{
 		Imports: map[string]string{
@@ -146,6 +302,108 @@ func TestToJSON(t *testing.T) {
 	}
 }
 
+func TestSortedMarshal(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "importmap/sorted-marshal", "/test")
+
+	inputData, err := mfs.ReadFile("/test/input.json")
+	if err != nil {
+		t.Fatalf("Failed to read input.json: %v", err)
+	}
+	expectedData, err := mfs.ReadFile("/test/expected.json")
+	if err != nil {
+		t.Fatalf("Failed to read expected.json: %v", err)
+	}
+
+	im, err := importmap.Parse(inputData)
+	if err != nil {
+		t.Fatalf("Failed to parse input: %v", err)
+	}
+
+	got, err := im.SortedMarshal()
+	if err != nil {
+		t.Fatalf("SortedMarshal failed: %v", err)
+	}
+	expected := strings.TrimSuffix(string(expectedData), "\n")
+	if string(got) != expected {
+		t.Errorf("SortedMarshal mismatch:\n  got:\n%s\n  expected:\n%s", got, expected)
+	}
+
+	// Output must match encoding/json so existing goldens stay valid
+	indented, err := json.MarshalIndent(im, "", "  ")
+	if err != nil {
+		t.Fatalf("MarshalIndent failed: %v", err)
+	}
+	if string(got) != string(indented) {
+		t.Errorf("SortedMarshal differs from MarshalIndent:\n%s\n---\n%s", got, indented)
+	}
+
+	if im.ToJSON() != expected {
+		t.Errorf("ToJSON does not use sorted output:\n%s", im.ToJSON())
+	}
+}
+
+func TestSortedMarshalEmpty(t *testing.T) {
+	var nilMap *importmap.ImportMap
+	for _, im := range []*importmap.ImportMap{nilMap, {}} {
+		got, err := im.SortedMarshal()
+		if err != nil {
+			t.Fatalf("SortedMarshal failed: %v", err)
+		}
+		if string(got) != "{}" {
+			t.Errorf("Expected {}, got %s", got)
+		}
+	}
+}
+
+func TestToFlatJSON(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "importmap/sorted-marshal", "/test")
+
+	inputData, err := mfs.ReadFile("/test/input.json")
+	if err != nil {
+		t.Fatalf("Failed to read input.json: %v", err)
+	}
+	expectedData, err := mfs.ReadFile("/test/expected-flat.json")
+	if err != nil {
+		t.Fatalf("Failed to read expected-flat.json: %v", err)
+	}
+
+	im, err := importmap.Parse(inputData)
+	if err != nil {
+		t.Fatalf("Failed to parse input: %v", err)
+	}
+
+	expected := strings.TrimSuffix(string(expectedData), "\n")
+	if got := im.Format("flat"); got != expected {
+		t.Errorf("Format(flat) mismatch:\n  got:\n%s\n  expected:\n%s", got, expected)
+	}
+
+	var empty *importmap.ImportMap
+	if got := empty.ToFlatJSON(); got != "{}" {
+		t.Errorf("Expected {} for nil import map, got %s", got)
+	}
+}
+
+func TestToESM(t *testing.T) {
+	im := &importmap.ImportMap{
+		Imports: map[string]string{"lit": "/node_modules/lit/index.js"},
+	}
+
+	got := im.Format("esm")
+	if !strings.HasPrefix(got, "export default {") || !strings.HasSuffix(got, "};") {
+		t.Fatalf("Expected an export default statement, got:\n%s", got)
+	}
+
+	body := strings.TrimSuffix(strings.TrimPrefix(got, "export default "), ";")
+	if body != im.ToJSON() {
+		t.Errorf("Expected the embedded object to match ToJSON:\n  got:\n%s\n  expected:\n%s", body, im.ToJSON())
+	}
+
+	empty := &importmap.ImportMap{}
+	if got := empty.ToESM(); got != "export default {};" {
+		t.Errorf("Expected empty module for empty import map, got %s", got)
+	}
+}
+
 func TestToJSONEmpty(t *testing.T) {
 	im := &importmap.ImportMap{}
 	jsonStr := im.ToJSON()
@@ -208,3 +466,371 @@ func TestSimplify(t *testing.T) {
 		})
 	}
 }
+
+func TestFlattenScopes(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "importmap/flatten-scopes", "/test")
+
+	inputData, err := mfs.ReadFile("/test/input.json")
+	if err != nil {
+		t.Fatalf("Failed to read input.json: %v", err)
+	}
+
+	expectedData, err := mfs.ReadFile("/test/expected.json")
+	if err != nil {
+		t.Fatalf("Failed to read expected.json: %v", err)
+	}
+
+	input, err := importmap.Parse(inputData)
+	if err != nil {
+		t.Fatalf("Failed to parse input: %v", err)
+	}
+
+	var expected importmap.ImportMap
+	if err := json.Unmarshal(expectedData, &expected); err != nil {
+		t.Fatalf("Failed to parse expected: %v", err)
+	}
+
+	result := input.FlattenScopes()
+
+	if !reflect.DeepEqual(result.Imports, expected.Imports) {
+		t.Errorf("Imports mismatch:\n  got:      %v\n  expected: %v", result.Imports, expected.Imports)
+	}
+
+	if result.Scopes != nil {
+		t.Errorf("Expected no scopes, got: %v", result.Scopes)
+	}
+
+	// Original should not be modified
+	if len(input.Scopes) != 2 {
+		t.Errorf("Original scopes were modified: %v", input.Scopes)
+	}
+}
+
+func TestWithoutScopes(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "importmap/without-scopes", "/test")
+
+	inputData, err := mfs.ReadFile("/test/input.json")
+	if err != nil {
+		t.Fatalf("Failed to read input.json: %v", err)
+	}
+
+	expectedData, err := mfs.ReadFile("/test/expected.json")
+	if err != nil {
+		t.Fatalf("Failed to read expected.json: %v", err)
+	}
+
+	input, err := importmap.Parse(inputData)
+	if err != nil {
+		t.Fatalf("Failed to parse input: %v", err)
+	}
+
+	var expected importmap.ImportMap
+	if err := json.Unmarshal(expectedData, &expected); err != nil {
+		t.Fatalf("Failed to parse expected: %v", err)
+	}
+
+	result, lost := input.WithoutScopes()
+
+	if !reflect.DeepEqual(result.Imports, expected.Imports) {
+		t.Errorf("Imports mismatch:\n  got:      %v\n  expected: %v", result.Imports, expected.Imports)
+	}
+
+	if result.Scopes != nil {
+		t.Errorf("Expected no scopes, got: %v", result.Scopes)
+	}
+
+	// lit and lit/decorators.js resolve, if differently, through the
+	// top-level imports; the others don't resolve at all
+	wantLost := []string{"@example/icon/", "tslib"}
+	if !reflect.DeepEqual(lost, wantLost) {
+		t.Errorf("lost = %v, want %v", lost, wantLost)
+	}
+
+	// Original should not be modified
+	if len(input.Scopes) != 3 {
+		t.Errorf("Original scopes were modified: %v", input.Scopes)
+	}
+}
+
+func TestRebase(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "importmap/rebase", "/test")
+
+	inputData, err := mfs.ReadFile("/test/input.json")
+	if err != nil {
+		t.Fatalf("Failed to read input.json: %v", err)
+	}
+
+	expectedData, err := mfs.ReadFile("/test/expected.json")
+	if err != nil {
+		t.Fatalf("Failed to read expected.json: %v", err)
+	}
+
+	input, err := importmap.Parse(inputData)
+	if err != nil {
+		t.Fatalf("Failed to parse input: %v", err)
+	}
+
+	var expected importmap.ImportMap
+	if err := json.Unmarshal(expectedData, &expected); err != nil {
+		t.Fatalf("Failed to parse expected: %v", err)
+	}
+
+	// Leading and trailing slashes on the prefix are optional
+	for _, prefix := range []string{"/app/", "/app", "app/"} {
+		t.Run(prefix, func(t *testing.T) {
+			result := input.Rebase(prefix)
+
+			if !reflect.DeepEqual(result.Imports, expected.Imports) {
+				t.Errorf("Imports mismatch:\n  got:      %v\n  expected: %v", result.Imports, expected.Imports)
+			}
+			if !reflect.DeepEqual(result.Scopes, expected.Scopes) {
+				t.Errorf("Scopes mismatch:\n  got:      %v\n  expected: %v", result.Scopes, expected.Scopes)
+			}
+			if !reflect.DeepEqual(result.Integrity, expected.Integrity) {
+				t.Errorf("Integrity mismatch:\n  got:      %v\n  expected: %v", result.Integrity, expected.Integrity)
+			}
+		})
+	}
+
+	// Original should not be modified
+	if input.Imports["lit"] != "/node_modules/lit/index.js" {
+		t.Errorf("Original imports were modified: %v", input.Imports)
+	}
+
+	// An empty or root prefix is a no-op
+	if result := input.Rebase("/"); !reflect.DeepEqual(result.Imports, input.Imports) {
+		t.Errorf("Rebase(\"/\") changed imports: %v", result.Imports)
+	}
+}
+
+func TestResolveURLs(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "importmap/resolve-urls", "/test")
+
+	inputData, err := mfs.ReadFile("/test/input.json")
+	if err != nil {
+		t.Fatalf("Failed to read input.json: %v", err)
+	}
+
+	expectedData, err := mfs.ReadFile("/test/expected.json")
+	if err != nil {
+		t.Fatalf("Failed to read expected.json: %v", err)
+	}
+
+	input, err := importmap.Parse(inputData)
+	if err != nil {
+		t.Fatalf("Failed to parse input: %v", err)
+	}
+
+	var expected importmap.ImportMap
+	if err := json.Unmarshal(expectedData, &expected); err != nil {
+		t.Fatalf("Failed to parse expected: %v", err)
+	}
+
+	result, err := input.ResolveURLs("https://site/pages/index.html")
+	if err != nil {
+		t.Fatalf("ResolveURLs failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(result.Imports, expected.Imports) {
+		t.Errorf("Imports mismatch:\n  got:      %v\n  expected: %v", result.Imports, expected.Imports)
+	}
+	if !reflect.DeepEqual(result.Scopes, expected.Scopes) {
+		t.Errorf("Scopes mismatch:\n  got:      %v\n  expected: %v", result.Scopes, expected.Scopes)
+	}
+	if !reflect.DeepEqual(result.Integrity, expected.Integrity) {
+		t.Errorf("Integrity mismatch:\n  got:      %v\n  expected: %v", result.Integrity, expected.Integrity)
+	}
+
+	// Absolute scope keys now match absolute referrers
+	resolved, ok := result.ResolveSpecifier("lit-html", "https://site/node_modules/lit/index.js")
+	if !ok || resolved != "https://site/node_modules/lit/node_modules/lit-html/lit-html.js" {
+		t.Errorf("ResolveSpecifier through absolute scope = %q, %v", resolved, ok)
+	}
+
+	// Original should not be modified
+	if input.Imports["lit"] != "/node_modules/lit/index.js" {
+		t.Errorf("Original imports were modified: %v", input.Imports)
+	}
+
+	for _, base := range []string{"/app/", "site/", "://bad"} {
+		if _, err := input.ResolveURLs(base); err == nil {
+			t.Errorf("Expected error for non-absolute base URL %q", base)
+		}
+	}
+}
+
+func TestVerifyReachable(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "importmap/verify-reachable", "/test")
+
+	inputData, err := mfs.ReadFile("/test/input.json")
+	if err != nil {
+		t.Fatalf("Failed to read input.json: %v", err)
+	}
+
+	expectedData, err := mfs.ReadFile("/test/expected.json")
+	if err != nil {
+		t.Fatalf("Failed to read expected.json: %v", err)
+	}
+
+	input, err := importmap.Parse(inputData)
+	if err != nil {
+		t.Fatalf("Failed to parse input: %v", err)
+	}
+
+	var expected []string
+	if err := json.Unmarshal(expectedData, &expected); err != nil {
+		t.Fatalf("Failed to parse expected: %v", err)
+	}
+
+	live := map[string]bool{
+		"https://cdn.example.com/lit@3.1.0/index.js":         true,
+		"https://cdn.example.com/lit@3.1.0/decorators.js":    true,
+		"https://cdn.example.com/lit-html@3.1.0/lit-html.js": true,
+	}
+	fetched := make(map[string]int)
+	dead := input.VerifyReachable(func(url string) error {
+		fetched[url]++
+		if !live[url] {
+			return fmt.Errorf("404 Not Found: %s", url)
+		}
+		return nil
+	})
+
+	if !reflect.DeepEqual(dead, expected) {
+		t.Errorf("Dead URLs mismatch:\n  got:      %v\n  expected: %v", dead, expected)
+	}
+	if _, ok := fetched["https://cdn.example.com/lit@3.1.0/"]; ok {
+		t.Error("Trailing-slash target should not be fetched")
+	}
+	for url, n := range fetched {
+		if n != 1 {
+			t.Errorf("Fetched %s %d times, want 1", url, n)
+		}
+	}
+
+	if dead := input.VerifyReachable(func(string) error { return nil }); dead != nil {
+		t.Errorf("Expected no dead URLs, got %v", dead)
+	}
+}
+
+func TestSubset(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "importmap/subset", "/test")
+
+	inputData, err := mfs.ReadFile("/test/input.json")
+	if err != nil {
+		t.Fatalf("Failed to read input.json: %v", err)
+	}
+
+	specifiersData, err := mfs.ReadFile("/test/specifiers.json")
+	if err != nil {
+		t.Fatalf("Failed to read specifiers.json: %v", err)
+	}
+
+	expectedData, err := mfs.ReadFile("/test/expected.json")
+	if err != nil {
+		t.Fatalf("Failed to read expected.json: %v", err)
+	}
+
+	input, err := importmap.Parse(inputData)
+	if err != nil {
+		t.Fatalf("Failed to parse input: %v", err)
+	}
+
+	var specifiers []string
+	if err := json.Unmarshal(specifiersData, &specifiers); err != nil {
+		t.Fatalf("Failed to parse specifiers: %v", err)
+	}
+
+	var expected importmap.ImportMap
+	if err := json.Unmarshal(expectedData, &expected); err != nil {
+		t.Fatalf("Failed to parse expected: %v", err)
+	}
+
+	result := input.Subset(specifiers)
+
+	if !reflect.DeepEqual(result.Imports, expected.Imports) {
+		t.Errorf("Imports mismatch:\n  got:      %v\n  expected: %v", result.Imports, expected.Imports)
+	}
+
+	if !reflect.DeepEqual(result.Scopes, expected.Scopes) {
+		t.Errorf("Scopes mismatch:\n  got:      %v\n  expected: %v", result.Scopes, expected.Scopes)
+	}
+
+	if !reflect.DeepEqual(result.Integrity, expected.Integrity) {
+		t.Errorf("Integrity mismatch:\n  got:      %v\n  expected: %v", result.Integrity, expected.Integrity)
+	}
+
+	if removed := input.Len() - result.Len(); removed != 3 {
+		t.Errorf("Expected 3 entries removed, got %d", removed)
+	}
+}
+
+func TestString(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "importmap/summary", "/test")
+
+	inputData, err := mfs.ReadFile("/test/input.json")
+	if err != nil {
+		t.Fatalf("Failed to read input.json: %v", err)
+	}
+	expectedData, err := mfs.ReadFile("/test/expected.txt")
+	if err != nil {
+		t.Fatalf("Failed to read expected.txt: %v", err)
+	}
+
+	im, err := importmap.Parse(inputData)
+	if err != nil {
+		t.Fatalf("Failed to parse input: %v", err)
+	}
+	im.Provenance = map[string]string{
+		"lit":               importmap.ProvenanceInputMap,
+		"lit/decorators.js": importmap.ProvenanceInputMap,
+	}
+
+	if got := fmt.Sprint(im); got != string(expectedData) {
+		t.Errorf("String mismatch:\n  got:\n%s\n  expected:\n%s", got, expectedData)
+	}
+
+	var empty *importmap.ImportMap
+	if got := empty.String(); got != "(empty import map)\n" {
+		t.Errorf("String() of nil map = %q", got)
+	}
+}
+
+func TestResolveSpecifier(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "importmap/resolve-specifier", "/test")
+
+	inputData, err := mfs.ReadFile("/test/input.json")
+	if err != nil {
+		t.Fatalf("Failed to read input.json: %v", err)
+	}
+	casesData, err := mfs.ReadFile("/test/cases.json")
+	if err != nil {
+		t.Fatalf("Failed to read cases.json: %v", err)
+	}
+
+	im, err := importmap.Parse(inputData)
+	if err != nil {
+		t.Fatalf("Failed to parse input: %v", err)
+	}
+
+	var cases []struct {
+		Name      string `json:"name"`
+		Specifier string `json:"specifier"`
+		Referrer  string `json:"referrer"`
+		Expected  string `json:"expected"`
+		OK        bool   `json:"ok"`
+	}
+	if err := json.Unmarshal(casesData, &cases); err != nil {
+		t.Fatalf("Failed to parse cases: %v", err)
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			got, ok := im.ResolveSpecifier(tc.Specifier, tc.Referrer)
+			if got != tc.Expected || ok != tc.OK {
+				t.Errorf("ResolveSpecifier(%q, %q) = %q, %v; want %q, %v",
+					tc.Specifier, tc.Referrer, got, ok, tc.Expected, tc.OK)
+			}
+		})
+	}
+}
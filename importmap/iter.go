@@ -0,0 +1,73 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package importmap
+
+import (
+	"iter"
+	"maps"
+	"slices"
+)
+
+// ScopeEntry is a single specifier mapping within a scope.
+type ScopeEntry struct {
+	// Scope is the scope key, a URL prefix.
+	Scope     string
+	Specifier string
+	URL       string
+}
+
+// Entries returns an iterator over the top-level specifier/URL pairs,
+// in specifier order.
+//
+//	for specifier, url := range im.Entries() {
+//		...
+//	}
+func (im *ImportMap) Entries() iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		if im == nil {
+			return
+		}
+		for _, specifier := range slices.Sorted(maps.Keys(im.Imports)) {
+			if !yield(specifier, im.Imports[specifier]) {
+				return
+			}
+		}
+	}
+}
+
+// ScopeEntries returns an iterator over every mapping in every scope,
+// ordered by scope key and then by specifier. Range-over-func yields at most
+// two values, so each (scope, specifier, URL) triple is a ScopeEntry.
+//
+//	for entry := range im.ScopeEntries() {
+//		fmt.Println(entry.Scope, entry.Specifier, entry.URL)
+//	}
+func (im *ImportMap) ScopeEntries() iter.Seq[ScopeEntry] {
+	return func(yield func(ScopeEntry) bool) {
+		if im == nil {
+			return
+		}
+		for _, scope := range slices.Sorted(maps.Keys(im.Scopes)) {
+			imports := im.Scopes[scope]
+			for _, specifier := range slices.Sorted(maps.Keys(imports)) {
+				if !yield(ScopeEntry{Scope: scope, Specifier: specifier, URL: imports[specifier]}) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package importmap_test
+
+import (
+	"slices"
+	"testing"
+
+	"bennypowers.dev/mappa/importmap"
+)
+
+func TestEntries(t *testing.T) {
+	im := &importmap.ImportMap{
+		Imports: map[string]string{
+			"lit":      "/node_modules/lit/index.js",
+			"lit/":     "/node_modules/lit/",
+			"@lit/ctx": "/node_modules/@lit/ctx/index.js",
+		},
+		Scopes: map[string]map[string]string{
+			"/node_modules/lit/": {
+				"lit-html":              "/node_modules/lit-html/lit-html.js",
+				"@lit/reactive-element": "/node_modules/@lit/reactive-element/reactive-element.js",
+			},
+			"/node_modules/@lit/ctx/": {
+				"lit": "/node_modules/lit/index.js",
+			},
+		},
+	}
+
+	var specifiers []string
+	for specifier, url := range im.Entries() {
+		if im.Imports[specifier] != url {
+			t.Errorf("Entries() yielded %q => %q, want %q", specifier, url, im.Imports[specifier])
+		}
+		specifiers = append(specifiers, specifier)
+	}
+	if want := []string{"@lit/ctx", "lit", "lit/"}; !slices.Equal(specifiers, want) {
+		t.Errorf("Entries() specifiers = %v, want %v", specifiers, want)
+	}
+
+	got := slices.Collect(im.ScopeEntries())
+	want := []importmap.ScopeEntry{
+		{Scope: "/node_modules/@lit/ctx/", Specifier: "lit", URL: "/node_modules/lit/index.js"},
+		{Scope: "/node_modules/lit/", Specifier: "@lit/reactive-element", URL: "/node_modules/@lit/reactive-element/reactive-element.js"},
+		{Scope: "/node_modules/lit/", Specifier: "lit-html", URL: "/node_modules/lit-html/lit-html.js"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("ScopeEntries() = %v, want %v", got, want)
+	}
+
+	// Breaking out of the loop stops iteration
+	var n int
+	for range im.ScopeEntries() {
+		n++
+		break
+	}
+	if n != 1 {
+		t.Errorf("Expected iteration to stop after break, got %d entries", n)
+	}
+
+	var nilMap *importmap.ImportMap
+	for range nilMap.Entries() {
+		t.Error("Expected no entries from a nil import map")
+	}
+	for range nilMap.ScopeEntries() {
+		t.Error("Expected no scope entries from a nil import map")
+	}
+}
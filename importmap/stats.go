@@ -0,0 +1,75 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package importmap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"strings"
+)
+
+// Stats summarizes the size of an import map.
+type Stats struct {
+	// Imports is the number of top-level imports.
+	Imports int
+	// Scopes is the number of scopes.
+	Scopes int
+	// ScopedImports is the number of imports across all scopes.
+	ScopedImports int
+	// Bytes is the size of the map's JSON, as ToJSON formats it.
+	Bytes int
+	// GzipBytes is the size of the JSON compressed with gzip at the default
+	// level, an estimate of its size over the wire.
+	GzipBytes int
+}
+
+// Stats counts im's entries and measures the size of its JSON, before and
+// after gzip compression.
+func (im *ImportMap) Stats() Stats {
+	var s Stats
+	if im == nil {
+		return s
+	}
+	s.Imports = len(im.Imports)
+	s.Scopes = len(im.Scopes)
+	for _, scope := range im.Scopes {
+		s.ScopedImports += len(scope)
+	}
+
+	data := im.ToJSON()
+	if data == "" {
+		return s
+	}
+	s.Bytes = len(data)
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, _ = gz.Write([]byte(data))
+	_ = gz.Close()
+	s.GzipBytes = compressed.Len()
+	return s
+}
+
+// String formats the stats as one labelled line per figure.
+func (s Stats) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "imports: %d\n", s.Imports)
+	fmt.Fprintf(&b, "scopes:  %d (%d imports)\n", s.Scopes, s.ScopedImports)
+	fmt.Fprintf(&b, "size:    %d bytes\n", s.Bytes)
+	fmt.Fprintf(&b, "gzipped: %d bytes\n", s.GzipBytes)
+	return b.String()
+}
@@ -0,0 +1,70 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package importmap_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"bennypowers.dev/mappa/importmap"
+)
+
+func TestStats(t *testing.T) {
+	im := &importmap.ImportMap{
+		Imports: map[string]string{
+			"lit":      "/node_modules/lit/index.js",
+			"lit/":     "/node_modules/lit/",
+			"lit-html": "/node_modules/lit-html/lit-html.js",
+		},
+		Scopes: map[string]map[string]string{
+			"/node_modules/lit/": {
+				"lit-html":  "/node_modules/lit-html/lit-html.js",
+				"lit-html/": "/node_modules/lit-html/",
+			},
+		},
+	}
+
+	stats := im.Stats()
+	if stats.Imports != 3 || stats.Scopes != 1 || stats.ScopedImports != 2 {
+		t.Errorf("Stats() counts = %d imports, %d scopes, %d scoped imports; want 3, 1, 2",
+			stats.Imports, stats.Scopes, stats.ScopedImports)
+	}
+	if want := len(im.ToJSON()); stats.Bytes != want {
+		t.Errorf("Stats().Bytes = %d, want %d", stats.Bytes, want)
+	}
+
+	// The estimate should be the size of a real gzip stream of the JSON
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := io.WriteString(gz, im.ToJSON()); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if stats.GzipBytes != compressed.Len() {
+		t.Errorf("Stats().GzipBytes = %d, want %d", stats.GzipBytes, compressed.Len())
+	}
+}
+
+func TestStatsEmpty(t *testing.T) {
+	if stats := (&importmap.ImportMap{}).Stats(); stats != (importmap.Stats{}) {
+		t.Errorf("Stats() of an empty map = %+v, want zero", stats)
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 	"sync"
 
@@ -50,14 +51,34 @@ type Options struct {
 	Parallel int
 	// DryRun prevents writing files when true.
 	DryRun bool
+	// Shim updates <script type="importmap-shim"> tags as well as native ones,
+	// and inserts importmap-shim tags, for pages using es-module-shims.
+	Shim bool
+	// Preload inserts a <link rel="modulepreload"> tag after the import map for
+	// each traced module URL the page does not already preload.
+	Preload bool
+	// Strict fails pages with more than one import map tag. Otherwise their
+	// maps are consolidated into the first tag, the rest are removed, and a
+	// warning is reported.
+	Strict bool
+	// PreferExisting gives the page's existing scope entries precedence over
+	// traced ones, so hand-tuned scope overrides survive repeated injection.
+	// Traced entries are still added where the page has none.
+	PreferExisting bool
+	// Marker is a comment, such as <!-- importmap -->, that a new import map
+	// tag replaces. Pages without the marker get the tag at the default
+	// insertion point, with a warning.
+	Marker string
 }
 
 // Result holds the result of injecting into a single file.
 type Result struct {
-	File     string `json:"file"`
-	Modified bool   `json:"modified"`
-	Inserted bool   `json:"inserted,omitempty"` // true if new import map, false if replaced
-	Error    string `json:"error,omitempty"`
+	File     string   `json:"file"`
+	Modified bool     `json:"modified"`
+	Inserted bool     `json:"inserted,omitempty"` // true if new import map, false if replaced
+	Removed  bool     `json:"removed,omitempty"`  // true if import map tags were deleted
+	Warnings []string `json:"warnings,omitempty"` // non-fatal problems with the page, e.g. integrity without crossorigin
+	Error    string   `json:"error,omitempty"`
 }
 
 // Stats holds aggregate statistics from an inject operation.
@@ -65,6 +86,7 @@ type Stats struct {
 	Total    int   `json:"total"`
 	Updated  int   `json:"updated"`
 	Inserted int   `json:"inserted"`
+	Removed  int   `json:"removed,omitempty"`
 	Skipped  int   `json:"skipped"`
 	Errors   int   `json:"errors"`
 	Duration int64 `json:"duration_ms"`
@@ -89,7 +111,6 @@ func InjectBatch(osfs fs.FileSystem, files []string, absRoot string, opts Option
 
 		// Find workspace root once for all files
 		workspaceRoot := resolve.FindWorkspaceRoot(osfs, absRoot)
-		nodeModulesPath := filepath.Join(workspaceRoot, "node_modules")
 
 		// Parse package.json once
 		pkgPath := filepath.Join(absRoot, "package.json")
@@ -100,7 +121,7 @@ func InjectBatch(osfs fs.FileSystem, files []string, absRoot string, opts Option
 		}
 
 		// Create shared tracer
-		tracer := trace.NewTracer(osfs, absRoot).WithNodeModules(nodeModulesPath)
+		tracer := trace.NewTracer(osfs, absRoot).WithNodeModulesPaths(resolve.NodeModulesPaths(osfs, absRoot))
 		if pkg != nil && pkg.Name != "" {
 			tracer = tracer.WithSelfPackage(pkg, absRoot)
 		}
@@ -127,7 +148,7 @@ func InjectBatch(osfs fs.FileSystem, files []string, absRoot string, opts Option
 		for range parallel {
 			wg.Go(func() {
 				for htmlFile := range jobs {
-					result := injectFile(osfs, tracer, htmlFile, workspaceRoot, baseResolver, pkg, opts.DryRun)
+					result := injectFile(osfs, tracer, htmlFile, workspaceRoot, baseResolver, pkg, opts)
 					results <- result
 				}
 			})
@@ -145,8 +166,106 @@ func InjectBatch(osfs fs.FileSystem, files []string, absRoot string, opts Option
 	return results
 }
 
+// RemoveBatch removes import map tags from multiple HTML files in parallel.
+// Files without an import map are left unchanged. Files are not traced, so
+// only the Parallel, DryRun, and Shim options apply.
+func RemoveBatch(osfs fs.FileSystem, files []string, opts Options) <-chan Result {
+	results := make(chan Result, len(files))
+
+	go func() {
+		defer close(results)
+
+		parallel := opts.Parallel
+		if parallel <= 0 {
+			parallel = runtime.NumCPU()
+		}
+
+		jobs := make(chan string, len(files))
+
+		var wg sync.WaitGroup
+		for range parallel {
+			wg.Go(func() {
+				for htmlFile := range jobs {
+					results <- removeFile(osfs, htmlFile, opts)
+				}
+			})
+		}
+
+		for _, file := range files {
+			jobs <- file
+		}
+		close(jobs)
+
+		wg.Wait()
+	}()
+
+	return results
+}
+
+// removeFile deletes the import map tags from a single HTML file.
+func removeFile(osfs fs.FileSystem, htmlFile string, opts Options) Result {
+	result := Result{File: htmlFile}
+
+	content, err := osfs.ReadFile(htmlFile)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	newContent := removeImportMaps(content, opts.Shim)
+	if len(newContent) == len(content) {
+		return result // No import map to remove
+	}
+
+	result.Modified = true
+	result.Removed = true
+
+	if !opts.DryRun {
+		if err := osfs.WriteFile(htmlFile, newContent, 0644); err != nil {
+			result.Error = err.Error()
+			return result
+		}
+	}
+
+	return result
+}
+
+// removeImportMaps deletes every import map tag from content, including
+// importmap-shim tags when shim is set.
+func removeImportMaps(content []byte, shim bool) []byte {
+	locs := trace.FindImportMapTags(content)
+	if shim {
+		locs = trace.FindShimImportMapTags(content)
+	}
+	// Remove from the end, so earlier offsets stay valid
+	for i := len(locs) - 1; i >= 0; i-- {
+		content = removeTag(content, locs[i])
+	}
+	return content
+}
+
+// removeTag deletes the tag at loc from content. A tag on a line of its own
+// is removed along with the line, so no blank line is left behind.
+func removeTag(content []byte, loc trace.ImportMapLocation) []byte {
+	start, end := loc.TagStart, loc.TagEnd
+	lineStart := start
+	for lineStart > 0 && (content[lineStart-1] == ' ' || content[lineStart-1] == '\t') {
+		lineStart--
+	}
+	lineEnd := end
+	for lineEnd < len(content) && (content[lineEnd] == ' ' || content[lineEnd] == '\t' || content[lineEnd] == '\r') {
+		lineEnd++
+	}
+	if (lineStart == 0 || content[lineStart-1] == '\n') && (lineEnd == len(content) || content[lineEnd] == '\n') {
+		start = lineStart
+		end = min(lineEnd+1, len(content))
+	}
+
+	return append(content[:start:start], content[end:]...)
+}
+
 // injectFile processes a single HTML file and injects/updates its import map.
-func injectFile(osfs fs.FileSystem, tracer *trace.Tracer, htmlFile, workspaceRoot string, baseResolver *local.Resolver, pkg *packagejson.PackageJSON, dryRun bool) Result {
+func injectFile(osfs fs.FileSystem, tracer *trace.Tracer, htmlFile, workspaceRoot string, baseResolver *local.Resolver, pkg *packagejson.PackageJSON, opts Options) Result {
 	result := Result{File: htmlFile}
 
 	// Read HTML content
@@ -157,26 +276,58 @@ func injectFile(osfs fs.FileSystem, tracer *trace.Tracer, htmlFile, workspaceRoo
 	}
 
 	// Trace the file to get its import map
-	tracedMap, err := traceForInjection(tracer, htmlFile, workspaceRoot, baseResolver, pkg)
+	tracedMap, graph, err := traceForInjection(tracer, htmlFile, workspaceRoot, baseResolver, pkg)
 	if err != nil {
 		result.Error = err.Error()
 		return result
 	}
 
-	// Find existing import map tag
-	loc := trace.FindImportMapTag(content)
+	// Find existing import map tags
+	locs := trace.FindImportMapTags(content)
+	tagType := "importmap"
+	if opts.Shim {
+		locs = trace.FindShimImportMapTags(content)
+		tagType = trace.ImportMapShimType
+	}
+
+	page := content
+	if len(locs) > 1 {
+		lines := make([]string, len(locs))
+		for i, loc := range locs {
+			lines[i] = fmt.Sprint(loc.Line)
+		}
+		if opts.Strict {
+			result.Error = fmt.Sprintf("found %d import map tags (lines %s); a page may have only one", len(locs), strings.Join(lines, ", "))
+			return result
+		}
+		result.Warnings = append(result.Warnings, fmt.Sprintf(
+			"found %d import map tags (lines %s); consolidated them into the first", len(locs), strings.Join(lines, ", ")))
+	}
 
+	// Parse existing import maps for merging, with earlier tags taking
+	// precedence over later ones as browsers merging several maps do
 	var existingMap *importmap.ImportMap
-	if loc.Found {
-		// Parse existing import map for merging
+	for i := len(locs) - 1; i >= 0; i-- {
+		loc := locs[i]
 		existingJSON := content[loc.ContentStart:loc.ContentEnd]
-		if len(strings.TrimSpace(string(existingJSON))) > 0 {
-			existingMap = &importmap.ImportMap{}
-			if err := json.Unmarshal(existingJSON, existingMap); err != nil {
-				// Warn and skip on parse error
-				result.Error = fmt.Sprintf("failed to parse existing import map at line %d: %v", loc.Line, err)
-				return result
-			}
+		if len(strings.TrimSpace(string(existingJSON))) == 0 {
+			continue
+		}
+		tagMap := &importmap.ImportMap{}
+		if err := json.Unmarshal(existingJSON, tagMap); err != nil {
+			// Warn and skip on parse error
+			result.Error = fmt.Sprintf("failed to parse existing import map at line %d: %v", loc.Line, err)
+			return result
+		}
+		existingMap = existingMap.Merge(tagMap)
+	}
+
+	// Remove extra tags from the end, so earlier offsets stay valid
+	loc := trace.ImportMapLocation{Found: false}
+	if len(locs) > 0 {
+		loc = locs[0]
+		for i := len(locs) - 1; i > 0; i-- {
+			page = removeTag(page, locs[i])
 		}
 	}
 
@@ -184,6 +335,9 @@ func injectFile(osfs fs.FileSystem, tracer *trace.Tracer, htmlFile, workspaceRoo
 	var mergedMap *importmap.ImportMap
 	if existingMap != nil {
 		mergedMap = existingMap.Merge(tracedMap)
+		if opts.PreferExisting {
+			mergedMap = mergedMap.Merge(&importmap.ImportMap{Scopes: existingMap.Scopes})
+		}
 	} else {
 		mergedMap = tracedMap
 	}
@@ -191,13 +345,39 @@ func injectFile(osfs fs.FileSystem, tracer *trace.Tracer, htmlFile, workspaceRoo
 	// Simplify the merged import map
 	mergedMap = mergedMap.Simplify()
 
+	// Integrity entries apply to module fetches made in CORS mode, which the
+	// page's module scripts request with the crossorigin attribute
+	if len(mergedMap.Integrity) > 0 && len(graph.MissingCrossOrigin) > 0 {
+		result.Warnings = append(result.Warnings, fmt.Sprintf(
+			"import map has integrity entries but module scripts lack crossorigin: %s",
+			strings.Join(graph.MissingCrossOrigin, ", ")))
+	}
+
+	// Choose where a new tag goes, preferring the page's marker comment
+	var insertPoint trace.InsertPoint
+	if !loc.Found {
+		insertPoint = trace.FindInsertPoint(page)
+		if opts.Marker != "" {
+			if markerPoint := trace.FindMarker(page, opts.Marker); markerPoint.Found {
+				insertPoint = markerPoint
+			} else {
+				result.Warnings = append(result.Warnings, fmt.Sprintf(
+					"marker %q not found; inserted the import map at the default position", opts.Marker))
+			}
+		}
+	}
+
 	// Generate new HTML content
-	newContent, inserted, err := buildNewContent(content, loc, mergedMap)
+	newContent, inserted, err := buildNewContent(page, loc, insertPoint, mergedMap, tagType)
 	if err != nil {
 		result.Error = err.Error()
 		return result
 	}
 
+	if opts.Preload {
+		newContent = insertPreloads(newContent, trace.PreloadURLs(mergedMap, graph), graph.CrossOrigin, opts.Shim)
+	}
+
 	// Check if content actually changed
 	if string(newContent) == string(content) {
 		return result // No changes needed
@@ -207,7 +387,7 @@ func injectFile(osfs fs.FileSystem, tracer *trace.Tracer, htmlFile, workspaceRoo
 	result.Inserted = inserted
 
 	// Write file if not dry-run
-	if !dryRun {
+	if !opts.DryRun {
 		if err := osfs.WriteFile(htmlFile, newContent, 0644); err != nil {
 			result.Error = err.Error()
 			return result
@@ -217,11 +397,11 @@ func injectFile(osfs fs.FileSystem, tracer *trace.Tracer, htmlFile, workspaceRoo
 	return result
 }
 
-// traceForInjection traces an HTML file and returns its import map.
-func traceForInjection(tracer *trace.Tracer, htmlFile, workspaceRoot string, baseResolver *local.Resolver, pkg *packagejson.PackageJSON) (*importmap.ImportMap, error) {
+// traceForInjection traces an HTML file and returns its import map and module graph.
+func traceForInjection(tracer *trace.Tracer, htmlFile, workspaceRoot string, baseResolver *local.Resolver, pkg *packagejson.PackageJSON) (*importmap.ImportMap, *trace.ModuleGraph, error) {
 	graph, err := tracer.TraceHTML(htmlFile)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Get bare specifiers
@@ -230,7 +410,7 @@ func traceForInjection(tracer *trace.Tracer, htmlFile, workspaceRoot string, bas
 		// No bare imports to resolve
 		return &importmap.ImportMap{
 			Imports: make(map[string]string),
-		}, nil
+		}, graph, nil
 	}
 
 	// Build resolver with traced packages for this file
@@ -249,7 +429,7 @@ func traceForInjection(tracer *trace.Tracer, htmlFile, workspaceRoot string, bas
 	// Generate full import map for scopes and trailing-slash keys
 	generatedMap, err := resolver.Resolve(workspaceRoot)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Resolve traced specifiers
@@ -266,7 +446,7 @@ func traceForInjection(tracer *trace.Tracer, htmlFile, workspaceRoot string, bas
 	return &importmap.ImportMap{
 		Imports: tracedImports,
 		Scopes:  generatedMap.Scopes,
-	}, nil
+	}, graph, nil
 }
 
 // indentLines prefixes each non-empty line in s with the given indent.
@@ -281,7 +461,8 @@ func indentLines(s, indent string) string {
 }
 
 // buildNewContent generates new HTML content with the import map inserted or replaced.
-func buildNewContent(content []byte, loc trace.ImportMapLocation, im *importmap.ImportMap) ([]byte, bool, error) {
+// Existing tags keep their type; inserted tags use tagType and go at insertPoint.
+func buildNewContent(content []byte, loc trace.ImportMapLocation, insertPoint trace.InsertPoint, im *importmap.ImportMap, tagType string) ([]byte, bool, error) {
 	importMapJSON := im.ToJSON()
 
 	if loc.Found {
@@ -300,7 +481,6 @@ func buildNewContent(content []byte, loc trace.ImportMapLocation, im *importmap.
 	}
 
 	// Insert new import map
-	insertPoint := trace.FindInsertPoint(content)
 	if !insertPoint.Found {
 		return nil, false, fmt.Errorf("could not find insertion point (no <head> tag)")
 	}
@@ -308,25 +488,63 @@ func buildNewContent(content []byte, loc trace.ImportMapLocation, im *importmap.
 	// Indent JSON to match the tag indentation
 	indentedJSON := indentLines(importMapJSON, insertPoint.Indent)
 
-	// Build the import map tag with proper indentation
+	// Build the import map tag with proper indentation. The insertion point
+	// is already indented, so only following lines need the indent.
 	var tag strings.Builder
-	tag.WriteString(insertPoint.Indent)
-	tag.WriteString("<script type=\"importmap\">\n")
+	fmt.Fprintf(&tag, "<script type=%q>\n", tagType)
 	tag.WriteString(indentedJSON)
 	tag.WriteString("\n")
 	tag.WriteString(insertPoint.Indent)
-	tag.WriteString("</script>\n")
-	tag.WriteString(insertPoint.Indent)
+	tag.WriteString("</script>")
+	if insertPoint.Length == 0 {
+		// Inserting before an element, which moves to the next line
+		tag.WriteString("\n")
+		tag.WriteString(insertPoint.Indent)
+	}
 
-	// Insert at the found position
+	// Insert at the found position, replacing any marker
 	var newContent []byte
 	newContent = append(newContent, content[:insertPoint.Offset]...)
 	newContent = append(newContent, tag.String()...)
-	newContent = append(newContent, content[insertPoint.Offset:]...)
+	newContent = append(newContent, content[insertPoint.Offset+insertPoint.Length:]...)
 
 	return newContent, true, nil
 }
 
+// insertPreloads adds a <link rel="modulepreload"> tag for each of urls that
+// content does not already preload, directly after its import map tag,
+// mirroring the crossorigin setting of the page's module scripts.
+func insertPreloads(content []byte, urls []string, crossOrigin string, shim bool) []byte {
+	loc := trace.FindImportMapTag(content)
+	if shim {
+		loc = trace.FindShimImportMapTag(content)
+	}
+	if !loc.Found {
+		return content
+	}
+
+	existing := trace.FindModulePreloads(content)
+	indent := extractIndent(content, loc.TagEnd)
+	var links strings.Builder
+	for _, url := range urls {
+		if slices.Contains(existing, url) {
+			continue
+		}
+		links.WriteString("\n")
+		links.WriteString(indent)
+		links.WriteString(trace.PreloadLink(url, crossOrigin))
+	}
+	if links.Len() == 0 {
+		return content
+	}
+
+	var newContent []byte
+	newContent = append(newContent, content[:loc.TagEnd]...)
+	newContent = append(newContent, links.String()...)
+	newContent = append(newContent, content[loc.TagEnd:]...)
+	return newContent
+}
+
 // extractIndent extracts the leading whitespace from the line containing the given offset.
 func extractIndent(content []byte, offset int) string {
 	// Find the start of the line
@@ -0,0 +1,216 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// Package jsonschema generates JSON Schemas for mappa's JSON output from the
+// Go structs that produce it, and validates decoded JSON against them.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Draft is the JSON Schema dialect of generated schemas.
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is the subset of JSON Schema needed to describe mappa's output.
+type Schema struct {
+	Schema      string             `json:"$schema,omitempty"`
+	Title       string             `json:"title,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Type        Types              `json:"type,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	// AdditionalProperties is false for structs, which reject unknown keys,
+	// or the schema of every value of a map.
+	AdditionalProperties any `json:"additionalProperties,omitempty"`
+}
+
+// Types lists the JSON types a value may have. A single type is encoded as a
+// string.
+type Types []string
+
+// MarshalJSON implements json.Marshaler.
+func (t Types) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+// For returns the schema of the JSON encoding of T. Struct fields follow
+// their json tags: fields tagged "-" are left out, and fields without
+// omitempty are required. Required maps, slices and pointers may be null,
+// since encoding/json encodes their nil values as null.
+func For[T any]() *Schema {
+	schema := forType(reflect.TypeFor[T]())
+	schema.Schema = Draft
+	return schema
+}
+
+func forType(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: Types{"string"}}
+	case reflect.Bool:
+		return &Schema{Type: Types{"boolean"}}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: Types{"integer"}}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: Types{"number"}}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: Types{"array"}, Items: forType(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: Types{"object"}, AdditionalProperties: forType(t.Elem())}
+	case reflect.Struct:
+		return forStruct(t)
+	}
+	panic(fmt.Sprintf("jsonschema: unsupported type %s", t))
+}
+
+func forStruct(t reflect.Type) *Schema {
+	schema := &Schema{
+		Type:                 Types{"object"},
+		Properties:           make(map[string]*Schema),
+		AdditionalProperties: false,
+	}
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+		property := forType(field.Type)
+		if !slices.Contains(strings.Split(options, ","), "omitempty") {
+			schema.Required = append(schema.Required, name)
+			switch field.Type.Kind() {
+			case reflect.Map, reflect.Slice, reflect.Pointer:
+				property.Type = append(property.Type, "null")
+			}
+		}
+		schema.Properties[name] = property
+	}
+	return schema
+}
+
+// Validate reports the first way data, as decoded by encoding/json into an
+// any, fails to match the schema.
+func (s *Schema) Validate(data any) error {
+	return s.validate("", data)
+}
+
+func (s *Schema) validate(path string, data any) error {
+	if !slices.ContainsFunc(s.Type, func(typ string) bool { return matchesType(typ, data) }) {
+		return fmt.Errorf("%s: expected %s, got %s", pointer(path), strings.Join(s.Type, " or "), jsonType(data))
+	}
+	switch value := data.(type) {
+	case []any:
+		for i, item := range value {
+			if err := s.Items.validate(fmt.Sprintf("%s/%d", path, i), item); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := value[name]; !ok {
+				return fmt.Errorf("%s: missing required property %q", pointer(path), name)
+			}
+		}
+		for name, item := range value {
+			property, ok := s.Properties[name]
+			if !ok {
+				additional, isSchema := s.AdditionalProperties.(*Schema)
+				if !isSchema {
+					return fmt.Errorf("%s: unexpected property %q", pointer(path), name)
+				}
+				property = additional
+			}
+			if err := property.validate(path+"/"+name, item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func matchesType(typ string, data any) bool {
+	switch typ {
+	case "null":
+		return data == nil
+	case "string":
+		_, ok := data.(string)
+		return ok
+	case "boolean":
+		_, ok := data.(bool)
+		return ok
+	case "number":
+		_, ok := data.(float64)
+		return ok
+	case "integer":
+		n, ok := data.(float64)
+		return ok && n == float64(int64(n))
+	case "array":
+		_, ok := data.([]any)
+		return ok
+	case "object":
+		_, ok := data.(map[string]any)
+		return ok
+	}
+	return false
+}
+
+// jsonType names the JSON type of decoded data for error messages.
+func jsonType(data any) string {
+	switch data.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", data)
+}
+
+// pointer formats path as a JSON pointer for error messages.
+func pointer(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
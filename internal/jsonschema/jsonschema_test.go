@@ -0,0 +1,168 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package jsonschema
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+type item struct {
+	Name    string            `json:"name"`
+	Count   int               `json:"count,omitempty"`
+	Tags    []string          `json:"tags"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Ignored string            `json:"-"`
+	hidden  string
+}
+
+type list struct {
+	Items []item `json:"items,omitempty"`
+	Ratio float64
+}
+
+func TestFor(t *testing.T) {
+	schema := For[list]()
+	if schema.Schema != Draft {
+		t.Errorf("$schema = %q, want %q", schema.Schema, Draft)
+	}
+	if !slices.Equal(schema.Required, []string{"Ratio"}) {
+		t.Errorf("Required = %v, want [Ratio]", schema.Required)
+	}
+	if got := schema.Properties["Ratio"].Type; !slices.Equal(got, Types{"number"}) {
+		t.Errorf("Ratio type = %v, want number", got)
+	}
+
+	items := schema.Properties["items"]
+	if !slices.Equal(items.Type, Types{"array"}) {
+		t.Errorf("items type = %v, want array", items.Type)
+	}
+	element := items.Items
+	if element.AdditionalProperties != false {
+		t.Errorf("item additionalProperties = %v, want false", element.AdditionalProperties)
+	}
+	if !slices.Equal(element.Required, []string{"name", "tags"}) {
+		t.Errorf("item Required = %v, want [name tags]", element.Required)
+	}
+	for _, name := range []string{"Ignored", "hidden"} {
+		if _, ok := element.Properties[name]; ok {
+			t.Errorf("item has property %q, want it left out", name)
+		}
+	}
+	// Required slices may be null, omitted ones can't be
+	if got := element.Properties["tags"].Type; !slices.Equal(got, Types{"array", "null"}) {
+		t.Errorf("tags type = %v, want [array null]", got)
+	}
+	if got := element.Properties["labels"].Type; !slices.Equal(got, Types{"object"}) {
+		t.Errorf("labels type = %v, want object", got)
+	}
+}
+
+func TestTypesMarshalJSON(t *testing.T) {
+	for _, tt := range []struct {
+		types Types
+		want  string
+	}{
+		{Types{"string"}, `"string"`},
+		{Types{"object", "null"}, `["object","null"]`},
+	} {
+		data, err := json.Marshal(tt.types)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("Marshal(%v) = %s, want %s", tt.types, data, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	schema := For[list]()
+
+	tests := []struct {
+		name    string
+		data    list
+		json    string
+		wantErr string
+	}{
+		{
+			name: "round trip",
+			data: list{
+				Ratio: 0.5,
+				Items: []item{
+					{Name: "a", Count: 2, Tags: []string{"x"}, Labels: map[string]string{"k": "v"}},
+					{Name: "b", Ignored: "y", hidden: "z"},
+				},
+			},
+		},
+		{
+			name:    "missing required property",
+			json:    `{"items": [{"tags": []}], "Ratio": 1}`,
+			wantErr: `/items/0: missing required property "name"`,
+		},
+		{
+			name:    "unexpected property",
+			json:    `{"Ratio": 1, "extra": true}`,
+			wantErr: `/: unexpected property "extra"`,
+		},
+		{
+			name:    "wrong type",
+			json:    `{"items": [{"name": "a", "tags": null, "labels": {"k": 1}}], "Ratio": 1}`,
+			wantErr: `/items/0/labels/k: expected string, got number`,
+		},
+		{
+			name:    "fractional integer",
+			json:    `{"items": [{"name": "a", "tags": null, "count": 1.5}], "Ratio": 1}`,
+			wantErr: `/items/0/count: expected integer, got number`,
+		},
+		{
+			name:    "null where not allowed",
+			json:    `{"items": null, "Ratio": 1}`,
+			wantErr: `/items: expected array, got null`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := []byte(tt.json)
+			if tt.json == "" {
+				var err error
+				if raw, err = json.Marshal(tt.data); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var data any
+			if err := json.Unmarshal(raw, &data); err != nil {
+				t.Fatal(err)
+			}
+
+			err := schema.Validate(data)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -58,6 +58,20 @@ func (mfs *MapFileSystem) AddFile(path string, content string, mode fs.FileMode)
 	}
 }
 
+// AddSymlink adds a symbolic link to the in-memory filesystem. Reads through
+// the link follow it, so target must be relative to the link's directory.
+func (mfs *MapFileSystem) AddSymlink(path string, target string) {
+	mfs.mu.Lock()
+	defer mfs.mu.Unlock()
+
+	path = mfs.cleanPath(path)
+	mfs.mapFS[path] = &fstest.MapFile{
+		Data:    []byte(target),
+		Mode:    fs.ModeSymlink | 0777,
+		ModTime: mfs.modTime,
+	}
+}
+
 // AddDir adds a directory to the in-memory filesystem.
 func (mfs *MapFileSystem) AddDir(path string, mode fs.FileMode) {
 	mfs.mu.Lock()
@@ -143,6 +157,13 @@ func (mfs *MapFileSystem) TempDir() string {
 	return mfs.tempDir
 }
 
+// SetModTime sets the modification time of files added or written from now on.
+func (mfs *MapFileSystem) SetModTime(modTime time.Time) {
+	mfs.mu.Lock()
+	defer mfs.mu.Unlock()
+	mfs.modTime = modTime
+}
+
 // SetTempDir sets the temp directory path.
 func (mfs *MapFileSystem) SetTempDir(dir string) {
 	mfs.mu.Lock()
@@ -158,6 +179,22 @@ func (mfs *MapFileSystem) Stat(name string) (fs.FileInfo, error) {
 	return fs.Stat(mfs.mapFS, mfs.cleanPath(name))
 }
 
+// Lstat implements io/fs.ReadLinkFS.
+func (mfs *MapFileSystem) Lstat(name string) (fs.FileInfo, error) {
+	mfs.mu.RLock()
+	defer mfs.mu.RUnlock()
+
+	return mfs.mapFS.Lstat(mfs.cleanPath(name))
+}
+
+// ReadLink implements io/fs.ReadLinkFS.
+func (mfs *MapFileSystem) ReadLink(name string) (string, error) {
+	mfs.mu.RLock()
+	defer mfs.mu.RUnlock()
+
+	return mfs.mapFS.ReadLink(mfs.cleanPath(name))
+}
+
 // Exists implements FileSystem.
 func (mfs *MapFileSystem) Exists(path string) bool {
 	mfs.mu.RLock()
@@ -176,7 +213,9 @@ func (mfs *MapFileSystem) Exists(path string) bool {
 		}
 	}
 
-	return false
+	// Paths beneath a symbolic link only exist once the link is followed
+	_, err := fs.Stat(mfs.mapFS, path)
+	return err == nil
 }
 
 // ReadDir implements FileSystem.
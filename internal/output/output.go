@@ -19,22 +19,50 @@ along with this program. If not, see <http://www.gnu.org/licenses/>.
 package output
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"github.com/spf13/viper"
 
 	"bennypowers.dev/mappa/fs"
 	"bennypowers.dev/mappa/importmap"
+	"bennypowers.dev/mappa/resolve"
 )
 
-// ImportMap formats and outputs an import map to stdout or a file.
+// Write outputs out, followed by a newline, to stdout or a file.
 // If viper's "output" flag is set, writes to that file; otherwise prints to stdout.
-func ImportMap(osfs fs.FileSystem, im *importmap.ImportMap, format string) error {
-	output := im.Format(format)
-
+func Write(osfs fs.FileSystem, out string) error {
 	if outputPath := viper.GetString("output"); outputPath != "" {
-		return osfs.WriteFile(outputPath, []byte(output+"\n"), 0644)
+		return osfs.WriteFile(outputPath, []byte(out+"\n"), 0644)
 	}
-	fmt.Println(output)
+	fmt.Println(out)
 	return nil
 }
+
+// ImportMap formats and outputs an import map to stdout or a file,
+// as Write does.
+func ImportMap(osfs fs.FileSystem, im *importmap.ImportMap, format string) error {
+	return Write(osfs, im.Format(format))
+}
+
+// ImportMapWithWarnings outputs an import map as JSON with an additional
+// top-level "warnings" array holding the given resolution warnings.
+// Like ImportMap, it honours viper's "output" flag.
+func ImportMapWithWarnings(osfs fs.FileSystem, im *importmap.ImportMap, warnings []resolve.LogEntry) error {
+	doc := make(map[string]any)
+	if data := im.ToJSON(); data != "" {
+		if err := json.Unmarshal([]byte(data), &doc); err != nil {
+			return fmt.Errorf("failed to encode import map: %w", err)
+		}
+	}
+	if warnings == nil {
+		warnings = []resolve.LogEntry{}
+	}
+	doc["warnings"] = warnings
+
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode import map: %w", err)
+	}
+	return Write(osfs, string(out))
+}
@@ -0,0 +1,294 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package semver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// comparator reports whether a version satisfies one comparator of a range,
+// such as ">=1.2.0" or "^1.5.0". sv is the parsed form of version.
+type comparator func(sv *Version, version string) bool
+
+// Constraint is an intersection of comparators, as written with whitespace
+// between them in a range like ">=1.2.0 <2.0.0 ^1.5.0". A release version
+// satisfies the constraint if it satisfies every comparator. As in npm, a
+// prerelease version must also share its major.minor.patch with a comparator
+// version that has a prerelease tag, so "^1.0.0-beta" matches "1.0.0-rc.1"
+// but not "1.1.0-beta".
+type Constraint struct {
+	comparators []comparator
+	prereleases []Version // comparator versions with prerelease tags
+}
+
+// ParseConstraint parses a whitespace-separated intersection of comparators.
+// An operator may be separated from its version by whitespace, as in
+// ">= 1.2.0". Returns an error if any comparator is malformed.
+func ParseConstraint(rangeStr string) (Constraint, error) {
+	var c Constraint
+	fields := strings.Fields(rangeStr)
+	for i := 0; i < len(fields); i++ {
+		token := fields[i]
+		if isOperator(token) && i+1 < len(fields) {
+			i++
+			token += fields[i]
+		}
+		cmp, err := parseComparator(token)
+		if err != nil {
+			return Constraint{}, err
+		}
+		c.comparators = append(c.comparators, cmp)
+		if sv, err := Parse(strings.TrimLeft(token, "^~<>=")); err == nil && sv.Prerelease != "" {
+			c.prereleases = append(c.prereleases, *sv)
+		}
+	}
+	return c, nil
+}
+
+// isOperator reports whether token is a bare comparison operator.
+func isOperator(token string) bool {
+	switch token {
+	case "^", "~", ">=", ">", "<=", "<", "=":
+		return true
+	}
+	return false
+}
+
+// parseComparator parses a single comparator: a caret, tilde, comparison or
+// equality operator followed by a version, an x-range like "1.x", or an
+// exact version. As in npm, a partial version is an x-range, so "18" and
+// "=18" mean "18.x". Comparators order prereleases by semver precedence;
+// whether a prerelease may match at all is decided by the constraint.
+func parseComparator(token string) (comparator, error) {
+	switch token {
+	case "", "*", "x", "X":
+		return anyVersion, nil
+	}
+
+	if base, ok := strings.CutPrefix(token, "^"); ok {
+		return caretComparator(base)
+	}
+	if base, ok := strings.CutPrefix(token, "~"); ok {
+		return tildeComparator(base)
+	}
+	for _, op := range []string{">=", "<=", ">", "<"} {
+		if base, ok := strings.CutPrefix(token, op); ok {
+			return relationComparator(op, base)
+		}
+	}
+	if exact, ok := strings.CutPrefix(token, "="); ok {
+		return exactComparator(exact)
+	}
+	if strings.ContainsAny(token, "xX*") {
+		return xRangeComparator(token)
+	}
+	return exactComparator(token)
+}
+
+// anyVersion matches every version.
+func anyVersion(_ *Version, _ string) bool {
+	return true
+}
+
+// caretComparator allows changes that do not modify the left-most non-zero
+// element of base. A partial base only fixes the elements it gives, so "^0"
+// means "<1.0.0" and "^0.0" means "<0.1.0".
+func caretComparator(base string) (comparator, error) {
+	bv, parts, err := parsePartial(base)
+	if err != nil {
+		return nil, err
+	}
+	return func(sv *Version, version string) bool {
+		if Compare(version, base) < 0 {
+			return false
+		}
+		switch {
+		// ^x.y.z and ^x - allows x.*.* where major is same
+		case bv.Major != 0 || parts == 1:
+			return sv.Major == bv.Major
+		// ^0.x.y and ^0.x - allows 0.x.z where z >= y (same minor, patch can increase)
+		case bv.Minor != 0 || parts == 2:
+			return sv.Major == 0 && sv.Minor == bv.Minor
+		// ^0.0.x - only matches 0.0.x
+		default:
+			return sv.Major == 0 && sv.Minor == 0
+		}
+	}, nil
+}
+
+// tildeComparator allows patch-level changes from base, or minor-level
+// changes when base only gives a major version, so "~1" means "<2.0.0".
+func tildeComparator(base string) (comparator, error) {
+	bv, parts, err := parsePartial(base)
+	if err != nil {
+		return nil, err
+	}
+	return func(sv *Version, version string) bool {
+		if sv.Major != bv.Major || Compare(version, base) < 0 {
+			return false
+		}
+		return parts == 1 || sv.Minor == bv.Minor
+	}, nil
+}
+
+// relationComparator compares versions against base with op, one of ">=",
+// "<=", ">" or "<". A partial base stands for every version it prefixes, so
+// ">1" means ">=2.0.0" and "<=1.2" means "<1.3.0".
+func relationComparator(op, base string) (comparator, error) {
+	bv, parts, err := parsePartial(base)
+	if err != nil {
+		return nil, err
+	}
+	return func(sv *Version, version string) bool {
+		c := Compare(version, base)
+		if parts < 3 && hasPrefix(sv, bv, parts) {
+			c = 0
+		}
+		switch op {
+		case ">=":
+			return c >= 0
+		case "<=":
+			return c <= 0
+		case ">":
+			return c > 0
+		default:
+			return c < 0
+		}
+	}, nil
+}
+
+// exactComparator matches only version itself, or every version a partial
+// version prefixes.
+func exactComparator(exact string) (comparator, error) {
+	_, parts, err := parsePartial(exact)
+	if err != nil {
+		return nil, err
+	}
+	if parts < 3 {
+		return xRangeComparator(strings.TrimPrefix(exact, "v"))
+	}
+	return func(_ *Version, version string) bool {
+		return version == exact
+	}, nil
+}
+
+// xRangeComparator matches versions against a pattern like "1.x" or
+// "1.2.*", where x, X and * match any number.
+func xRangeComparator(pattern string) (comparator, error) {
+	parts := strings.Split(strings.ToLower(pattern), ".")
+	if len(parts) > 3 {
+		return nil, fmt.Errorf("invalid x-range: %s", pattern)
+	}
+	want := make([]int, len(parts))
+	for i, part := range parts {
+		if part == "x" || part == "*" {
+			want[i] = -1
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x-range: %s", pattern)
+		}
+		want[i] = n
+	}
+	return func(sv *Version, _ string) bool {
+		for i, got := range []int{sv.Major, sv.Minor, sv.Patch}[:len(want)] {
+			if want[i] >= 0 && got != want[i] {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// hasPrefix reports whether the first parts of major, minor and patch in sv
+// equal those in prefix.
+func hasPrefix(sv, prefix *Version, parts int) bool {
+	want := []int{prefix.Major, prefix.Minor, prefix.Patch}
+	for i, got := range []int{sv.Major, sv.Minor, sv.Patch}[:parts] {
+		if got != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Check reports whether version satisfies every comparator and, if it
+// is a prerelease, shares its major.minor.patch with a prerelease comparator.
+func (c Constraint) Check(version string) bool {
+	sv, err := Parse(version)
+	if err != nil {
+		return false
+	}
+	if sv.Prerelease != "" && !c.allowsPrerelease(sv) {
+		return false
+	}
+	for _, cmp := range c.comparators {
+		if !cmp(sv, version) {
+			return false
+		}
+	}
+	return true
+}
+
+// allowsPrerelease reports whether a comparator version has a prerelease tag
+// and the same major.minor.patch as sv.
+func (c Constraint) allowsPrerelease(sv *Version) bool {
+	for _, pv := range c.prereleases {
+		if pv.Major == sv.Major && pv.Minor == sv.Minor && pv.Patch == sv.Patch {
+			return true
+		}
+	}
+	return false
+}
+
+// Highest returns the highest of versions, which must be sorted in ascending
+// semver order, that satisfies the constraint, or "" if none does.
+func (c Constraint) Highest(versions []string) string {
+	for i := len(versions) - 1; i >= 0; i-- {
+		if c.Check(versions[i]) {
+			return versions[i]
+		}
+	}
+	return ""
+}
+
+// Satisfies reports whether version satisfies versionRange, using the range
+// syntax of registry resolution: unions with ||, hyphen ranges, and
+// intersections of comparators. Returns an error if either is malformed.
+func Satisfies(version, versionRange string) (bool, error) {
+	if _, err := Parse(version); err != nil {
+		return false, err
+	}
+	for part := range strings.SplitSeq(versionRange, "||") {
+		part = strings.TrimSpace(part)
+		if lower, upper, ok := strings.Cut(part, " - "); ok {
+			part = ">=" + strings.TrimSpace(lower) + " <=" + strings.TrimSpace(upper)
+		}
+		c, err := ParseConstraint(part)
+		if err != nil {
+			return false, fmt.Errorf("invalid version range %q: %w", versionRange, err)
+		}
+		if c.Check(version) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,111 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package semver
+
+import "testing"
+
+func TestParseConstraint(t *testing.T) {
+	tests := []struct {
+		rangeStr string
+		version  string
+		want     bool
+	}{
+		{">=1.2.0 <2.0.0", "1.2.0", true},
+		{">=1.2.0 <2.0.0", "2.0.0", false},
+		{"^1.5.0 <=1.5.0", "1.5.0", true},
+		{"^0.2.0 >0.2.1", "0.2.5", true},
+		{"^0.2.0 >0.2.1", "0.3.0", false},
+		{"~1.2.3", "1.2.9", true},
+		{"~1", "1.9.0", true},
+		{"~1", "2.0.0", false},
+		{"~1.2", "1.3.0", false},
+		{"^0", "0.9.0", true},
+		{"=1.0.0-beta.1", "1.0.0-beta.1", true},
+		{"1.2.x", "1.2.7", true},
+		{"1.2.x", "1.3.0", false},
+		{"*", "1.0.0-beta.1", false},
+		{"^1.0.0-beta", "1.0.0-beta.3", true},
+		{"^1.0.0-beta", "1.2.0", true},
+		{"^1.0.0-beta", "1.2.0-beta", false},
+		{"~2.1.0-rc.1", "2.1.0-rc.0", false},
+		{"<2.0.0", "2.0.0-rc.1", false},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseConstraint(tt.rangeStr)
+		if err != nil {
+			t.Errorf("ParseConstraint(%q) failed: %v", tt.rangeStr, err)
+			continue
+		}
+		if got := c.Check(tt.version); got != tt.want {
+			t.Errorf("ParseConstraint(%q).Check(%q) = %v, want %v", tt.rangeStr, tt.version, got, tt.want)
+		}
+	}
+
+	for _, malformed := range []string{"^banana", ">=", "1.2.3.4.x", "<=one"} {
+		if _, err := ParseConstraint(malformed); err == nil {
+			t.Errorf("ParseConstraint(%q) should fail", malformed)
+		}
+	}
+}
+
+func TestSatisfies(t *testing.T) {
+	tests := []struct {
+		version      string
+		versionRange string
+		want         bool
+	}{
+		{"18.19.0", ">=18", true},
+		{"18.19.0", ">=20", false},
+		{"16.20.2", "^14 || ^16", true},
+		{"18.0.0", "^14 || ^16", false},
+		{"20.11.0", ">=18.0.0 <21", true},
+		{"v20.11.0", ">= 20.0.0", true},
+		{"12.22.0", "10.0.0 - 12.22.0", true},
+		{"22.0.0", "*", true},
+		{"22.0.0", "", true},
+		{"18.19.0", "18", true},
+		{"19.0.0", "18", false},
+		{"18.19.0", "=18.19", true},
+		{"v20.1.0", "v20", true},
+		{"20.11.0", ">18", true},
+		{"18.19.0", ">18", false},
+		{"18.19.0", "<=18", true},
+		{"19.0.0", "<=18", false},
+		{"18.19.0", "<18", false},
+		{"20.11.0", "16 - 20", true},
+	}
+
+	for _, tt := range tests {
+		got, err := Satisfies(tt.version, tt.versionRange)
+		if err != nil {
+			t.Errorf("Satisfies(%q, %q) failed: %v", tt.version, tt.versionRange, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Satisfies(%q, %q) = %v, want %v", tt.version, tt.versionRange, got, tt.want)
+		}
+	}
+
+	if _, err := Satisfies("18.19.0", "node >= 4"); err == nil {
+		t.Error("Expected an error for a malformed range")
+	}
+	if _, err := Satisfies("latest", ">=18"); err == nil {
+		t.Error("Expected an error for a malformed version")
+	}
+}
@@ -0,0 +1,179 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// Package semver parses and compares semantic versions and evaluates npm
+// version ranges, for registry resolution and package.json engines checks.
+package semver
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Version represents a parsed semantic version.
+type Version struct {
+	Major      int
+	Minor      int
+	Patch      int
+	Prerelease string
+}
+
+var pattern = regexp.MustCompile(`^v?(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:-(.+))?$`)
+
+// Parse parses a semantic version string. Missing minor and patch numbers,
+// as in "1" or "1.2", are zero.
+func Parse(version string) (*Version, error) {
+	v, _, err := parsePartial(version)
+	return v, err
+}
+
+// parsePartial parses a version like Parse, also returning how many of
+// major, minor and patch it gives.
+func parsePartial(version string) (*Version, int, error) {
+	matches := pattern.FindStringSubmatch(version)
+	if matches == nil {
+		return nil, 0, fmt.Errorf("invalid semver: %s", version)
+	}
+
+	v := &Version{}
+	parts := 1
+	v.Major, _ = strconv.Atoi(matches[1])
+	if matches[2] != "" {
+		v.Minor, _ = strconv.Atoi(matches[2])
+		parts++
+	}
+	if matches[3] != "" {
+		v.Patch, _ = strconv.Atoi(matches[3])
+		parts++
+	}
+	v.Prerelease = matches[4]
+
+	return v, parts, nil
+}
+
+// IsPrerelease reports whether version has a prerelease tag (e.g., "2.0.0-rc.1").
+func IsPrerelease(version string) bool {
+	v, err := Parse(version)
+	return err == nil && v.Prerelease != ""
+}
+
+// Compare compares two semver strings.
+// Returns -1 if a < b, 0 if a == b, 1 if a > b.
+// Returns 0 if either version cannot be parsed.
+func Compare(a, b string) int {
+	av, err := Parse(a)
+	if err != nil {
+		return 0
+	}
+	bv, err := Parse(b)
+	if err != nil {
+		return 0
+	}
+
+	if av.Major != bv.Major {
+		if av.Major < bv.Major {
+			return -1
+		}
+		return 1
+	}
+	if av.Minor != bv.Minor {
+		if av.Minor < bv.Minor {
+			return -1
+		}
+		return 1
+	}
+	if av.Patch != bv.Patch {
+		if av.Patch < bv.Patch {
+			return -1
+		}
+		return 1
+	}
+
+	// Prerelease versions are lower precedence than release versions
+	if av.Prerelease != "" && bv.Prerelease == "" {
+		return -1
+	}
+	if av.Prerelease == "" && bv.Prerelease != "" {
+		return 1
+	}
+	if av.Prerelease != bv.Prerelease {
+		return comparePrereleases(av.Prerelease, bv.Prerelease)
+	}
+
+	return 0
+}
+
+// comparePrereleases compares two prerelease strings according to SemVer 2.0.0.
+// Identifiers are split by '.' and compared: numeric identifiers are compared as integers,
+// non-numeric identifiers are compared lexically, numeric has lower precedence than non-numeric,
+// and shorter identifier lists have lower precedence when all preceding identifiers are equal.
+func comparePrereleases(a, b string) int {
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+
+	for i := 0; i < len(aParts) && i < len(bParts); i++ {
+		aPart := aParts[i]
+		bPart := bParts[i]
+
+		aNum, aIsNum := parseNum(aPart)
+		bNum, bIsNum := parseNum(bPart)
+
+		if aIsNum && bIsNum {
+			// Both numeric: compare as integers
+			if aNum != bNum {
+				if aNum < bNum {
+					return -1
+				}
+				return 1
+			}
+		} else if aIsNum {
+			// Numeric has lower precedence than non-numeric
+			return -1
+		} else if bIsNum {
+			return 1
+		} else {
+			// Both non-numeric: compare lexically
+			if aPart != bPart {
+				if aPart < bPart {
+					return -1
+				}
+				return 1
+			}
+		}
+	}
+
+	// Shorter list has lower precedence
+	if len(aParts) != len(bParts) {
+		if len(aParts) < len(bParts) {
+			return -1
+		}
+		return 1
+	}
+
+	return 0
+}
+
+// parseNum attempts to parse a string as a non-negative integer.
+func parseNum(s string) (int, bool) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,84 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package semver
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseSemver(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    *Version
+		wantErr bool
+	}{
+		{"1.0.0", &Version{Major: 1, Minor: 0, Patch: 0}, false},
+		{"2.3.4", &Version{Major: 2, Minor: 3, Patch: 4}, false},
+		{"1.0.0-alpha", &Version{Major: 1, Minor: 0, Patch: 0, Prerelease: "alpha"}, false},
+		{"1.0.0-beta.1", &Version{Major: 1, Minor: 0, Patch: 0, Prerelease: "beta.1"}, false},
+		{"v1.0.0", &Version{Major: 1, Minor: 0, Patch: 0}, false},
+		{"1.0", &Version{Major: 1, Minor: 0, Patch: 0}, false},
+		{"1", &Version{Major: 1, Minor: 0, Patch: 0}, false},
+		{"invalid", nil, true},
+		{"", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+				return
+			}
+			if tt.want != nil {
+				if got.Major != tt.want.Major || got.Minor != tt.want.Minor ||
+					got.Patch != tt.want.Patch || got.Prerelease != tt.want.Prerelease {
+					t.Errorf("Parse(%q) = %+v, want %+v", tt.input, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCompareSemver(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"1.0.0", "2.0.0", -1},
+		{"2.0.0", "1.0.0", 1},
+		{"1.0.0", "1.1.0", -1},
+		{"1.1.0", "1.0.0", 1},
+		{"1.0.0", "1.0.1", -1},
+		{"1.0.1", "1.0.0", 1},
+		{"1.0.0-alpha", "1.0.0", -1},
+		{"1.0.0", "1.0.0-alpha", 1},
+		{"1.0.0-alpha", "1.0.0-beta", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s vs %s", tt.a, tt.b), func(t *testing.T) {
+			got := Compare(tt.a, tt.b)
+			if got != tt.want {
+				t.Errorf("Compare(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
@@ -19,11 +19,18 @@ along with this program. If not, see <http://www.gnu.org/licenses/>.
 package version
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"runtime/debug"
 	"strings"
+
+	"bennypowers.dev/mappa/internal/semver"
 )
 
+// LatestReleaseURL is the GitHub API endpoint describing mappa's latest release.
+const LatestReleaseURL = "https://api.github.com/repos/bennypowers/mappa/releases/latest"
+
 var (
 	// Version information, set at build time via ldflags
 	Version   = "dev"     // Version string (e.g., "v0.3.0")
@@ -89,3 +96,54 @@ func GetBuildInfo() map[string]string {
 		"gitDirty":  GitDirty,
 	}
 }
+
+// Update compares the running version with the latest release.
+type Update struct {
+	Current string `json:"current"`
+	Latest  string `json:"latest"`
+	Behind  bool   `json:"behind"`
+}
+
+// Fetcher retrieves the contents of a URL, as cdn.Fetcher does.
+type Fetcher interface {
+	Fetch(ctx context.Context, url string) ([]byte, error)
+}
+
+// CheckForUpdate fetches the latest release tag from LatestReleaseURL and
+// compares current with it. Only core versions are compared, since builds
+// append the commit and "-dirty" to their tag, e.g. "v0.4.0-abc1234-dirty".
+// Development builds without a version cannot be compared and return an error.
+func CheckForUpdate(ctx context.Context, fetcher Fetcher, current string) (*Update, error) {
+	data, err := fetcher.Fetch(ctx, LatestReleaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("fetching latest release: %w", err)
+	}
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.Unmarshal(data, &release); err != nil {
+		return nil, fmt.Errorf("parsing latest release: %w", err)
+	}
+	if release.TagName == "" {
+		return nil, fmt.Errorf("latest release has no tag")
+	}
+
+	currentVersion, err := semver.Parse(current)
+	if err != nil {
+		return nil, fmt.Errorf("cannot compare version %q with latest release %s: %w", current, release.TagName, err)
+	}
+	latestVersion, err := semver.Parse(release.TagName)
+	if err != nil {
+		return nil, fmt.Errorf("cannot compare version %q with latest release %s: %w", current, release.TagName, err)
+	}
+	return &Update{
+		Current: current,
+		Latest:  release.TagName,
+		Behind:  semver.Compare(coreVersion(currentVersion), coreVersion(latestVersion)) < 0,
+	}, nil
+}
+
+// coreVersion returns v's major.minor.patch, without its prerelease.
+func coreVersion(v *semver.Version) string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
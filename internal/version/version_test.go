@@ -0,0 +1,88 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package version
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// releaseFetcher serves a canned GitHub release response.
+type releaseFetcher struct {
+	body string
+	err  error
+}
+
+func (f releaseFetcher) Fetch(_ context.Context, url string) ([]byte, error) {
+	if url != LatestReleaseURL {
+		return nil, fmt.Errorf("unexpected URL %s", url)
+	}
+	return []byte(f.body), f.err
+}
+
+func TestCheckForUpdate(t *testing.T) {
+	tests := []struct {
+		name    string
+		current string
+		body    string
+		behind  bool
+	}{
+		{"behind", "v0.3.0", `{"tag_name": "v0.4.0"}`, true},
+		{"current", "v0.4.0", `{"tag_name": "v0.4.0"}`, false},
+		{"ahead of release", "v0.4.1-0.20260101000000-abcdef123456", `{"tag_name": "v0.4.0"}`, false},
+		{"dirty build of older tag", "v0.3.0-abc1234-dirty", `{"tag_name": "v0.4.0"}`, true},
+		{"build of latest tag", "v0.4.0-abc1234", `{"tag_name": "v0.4.0"}`, false},
+		{"dirty build of latest tag", "v0.4.0-dirty", `{"tag_name": "v0.4.0"}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			update, err := CheckForUpdate(context.Background(), releaseFetcher{body: tt.body}, tt.current)
+			if err != nil {
+				t.Fatalf("CheckForUpdate failed: %v", err)
+			}
+			if update.Current != tt.current || update.Latest != "v0.4.0" {
+				t.Errorf("Expected %s vs v0.4.0, got %s vs %s", tt.current, update.Current, update.Latest)
+			}
+			if update.Behind != tt.behind {
+				t.Errorf("Expected behind=%v, got %v", tt.behind, update.Behind)
+			}
+		})
+	}
+}
+
+func TestCheckForUpdateErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		current string
+		fetcher releaseFetcher
+	}{
+		{"fetch failure", "v0.3.0", releaseFetcher{err: fmt.Errorf("offline")}},
+		{"invalid response", "v0.3.0", releaseFetcher{body: "not json"}},
+		{"missing tag", "v0.3.0", releaseFetcher{body: `{}`}},
+		{"development build", "dev", releaseFetcher{body: `{"tag_name": "v0.4.0"}`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := CheckForUpdate(context.Background(), tt.fetcher, tt.current); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
@@ -27,8 +27,14 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"bennypowers.dev/mappa/cmd/doctor"
+	"bennypowers.dev/mappa/cmd/explain"
 	"bennypowers.dev/mappa/cmd/generate"
+	"bennypowers.dev/mappa/cmd/graph"
 	"bennypowers.dev/mappa/cmd/inject"
+	"bennypowers.dev/mappa/cmd/prune"
+	"bennypowers.dev/mappa/cmd/sbom"
+	"bennypowers.dev/mappa/cmd/schema"
 	"bennypowers.dev/mappa/cmd/trace"
 	"bennypowers.dev/mappa/cmd/version"
 )
@@ -79,14 +85,25 @@ func init() {
 	_ = viper.BindPFlag("output", rootCmd.PersistentFlags().Lookup("output"))
 
 	// Add commands (alphabetized)
+	rootCmd.AddCommand(doctor.Cmd)
+	rootCmd.AddCommand(explain.Cmd)
 	rootCmd.AddCommand(generate.Cmd)
+	rootCmd.AddCommand(graph.Cmd)
 	rootCmd.AddCommand(inject.Cmd)
+	rootCmd.AddCommand(prune.Cmd)
+	rootCmd.AddCommand(sbom.Cmd)
+	rootCmd.AddCommand(schema.Cmd)
 	rootCmd.AddCommand(trace.Cmd)
 	rootCmd.AddCommand(version.Cmd)
 }
 
 func main() {
 	if err := rootCmd.Execute(); err != nil {
+		// Some errors, like an outdated version --check, have their own status
+		var coded interface{ ExitCode() int }
+		if errors.As(err, &coded) {
+			os.Exit(coded.ExitCode())
+		}
 		os.Exit(1)
 	}
 }
@@ -20,11 +20,18 @@ import (
 	"bytes"
 	"encoding/json"
 	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+
+	"bennypowers.dev/mappa/cmd/schema"
+	"bennypowers.dev/mappa/importmap"
 )
 
 var update = flag.Bool("update", false, "update golden files")
@@ -126,318 +133,1364 @@ func TestGenerateLocal(t *testing.T) {
 	}
 }
 
-func TestGenerateHTMLFormat(t *testing.T) {
+func TestGenerateTemplateTransform(t *testing.T) {
 	fixtureDir := filepath.Join("testdata", "resolve", "simple-pkg")
 
-	stdout, stderr, code := runCLI(t, "generate", "--package", fixtureDir, "--format", "html")
+	stdout, stderr, code := runCLI(t, "generate", "--package", fixtureDir, "--template", "/assets/{package:upper}/{path:noext}.min.js")
 	if code != 0 {
 		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
 	}
-
-	if !strings.HasPrefix(stdout, "<script type=\"importmap\">") {
-		t.Errorf("Expected HTML script tag prefix, got: %s", stdout[:min(50, len(stdout))])
+	if !strings.Contains(stdout, `"lit/decorators.js": "/assets/LIT/decorators.min.js"`) {
+		t.Errorf("Expected transformed URLs, got:\n%s", stdout)
 	}
 
-	if !strings.Contains(stdout, "</script>") {
-		t.Error("Expected closing script tag")
+	_, stderr, code = runCLI(t, "generate", "--package", fixtureDir, "--template", "/assets/{package}/{path:hash}")
+	if code == 0 {
+		t.Fatal("Expected non-zero exit code for an unknown transform")
 	}
-
-	// Extract JSON from script tag and verify it's valid
-	jsonStart := strings.Index(stdout, "\n") + 1
-	jsonEnd := strings.LastIndex(stdout, "\n</script>")
-	if jsonStart > 0 && jsonEnd > jsonStart {
-		jsonStr := stdout[jsonStart:jsonEnd]
-		var result map[string]any
-		if err := json.Unmarshal([]byte(jsonStr), &result); err != nil {
-			t.Fatalf("Failed to parse embedded JSON: %v", err)
-		}
+	if !strings.Contains(stderr, `unknown template transform "hash"`) {
+		t.Errorf("Expected unknown transform error, got: %s", stderr)
 	}
 }
 
-func TestGenerateOutputFile(t *testing.T) {
-	fixtureDir := filepath.Join("testdata", "resolve", "simple-pkg")
-	tmpFile := filepath.Join(t.TempDir(), "importmap.json")
+// TestGenerateBaseHref verifies that --base-href prefixes generated URLs and scope keys.
+func TestGenerateBaseHref(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "resolve", "with-scopes")
 
-	stdout, stderr, code := runCLI(t, "generate", "--package", fixtureDir, "--output", tmpFile)
+	stdout, stderr, code := runCLI(t, "generate", "--package", fixtureDir, "--base-href", "/app/")
 	if code != 0 {
 		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
 	}
 
-	if stdout != "" {
-		t.Errorf("Expected no stdout when writing to file, got: %s", stdout)
-	}
-
-	content, err := os.ReadFile(tmpFile)
-	if err != nil {
-		t.Fatalf("Failed to read output file: %v", err)
-	}
-
-	var result map[string]any
-	if err := json.Unmarshal(content, &result); err != nil {
-		t.Fatalf("Failed to parse output file JSON: %v", err)
+	var result importmap.ImportMap
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v\nstdout: %s", err, stdout)
 	}
 
-	val, exists := result["imports"]
-	if !exists || val == nil {
-		t.Fatalf("Expected imports object, got nil")
+	if result.Imports["lit"] != "/app/node_modules/lit/index.js" {
+		t.Errorf("Expected rebased lit import, got %v", result.Imports["lit"])
 	}
-	imports, ok := val.(map[string]any)
+	scope, ok := result.Scopes["/app/node_modules/lit/"]
 	if !ok {
-		t.Fatalf("Expected imports object, got %T", val)
+		t.Fatalf("Expected rebased scope key, got %v", result.Scopes)
 	}
-	if imports["lit"] == nil {
-		t.Error("Expected lit import in output file")
+	if scope["lit-html"] != "/app/node_modules/lit-html/lit-html.js" {
+		t.Errorf("Expected rebased scoped lit-html, got %v", scope["lit-html"])
 	}
 }
 
-func TestTrace(t *testing.T) {
-	fixtureDir := filepath.Join("testdata", "trace", "with-deps")
-	htmlFile := filepath.Join(fixtureDir, "index.html")
-	goldenFile := filepath.Join(fixtureDir, "expected.json")
+// TestGenerateInlineBelow verifies that --inline-below inlines small modules
+// as data: URLs while larger modules keep path URLs.
+func TestGenerateInlineBelow(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "resolve", "inline-modules")
 
-	// Default format (json) outputs import map
-	stdout, stderr, code := runCLI(t, "trace", htmlFile, "--package", fixtureDir)
+	stdout, stderr, code := runCLI(t, "generate", "--package", fixtureDir, "--inline-below", "512")
 	if code != 0 {
 		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
 	}
 
-	compareOrUpdateGolden(t, goldenFile, stdout)
+	compareOrUpdateGolden(t, filepath.Join(fixtureDir, "expected.json"), stdout)
 }
 
-func TestTraceSpecifiersFormat(t *testing.T) {
-	fixtureDir := filepath.Join("testdata", "trace", "with-deps")
-	htmlFile := filepath.Join(fixtureDir, "index.html")
-	goldenFile := filepath.Join(fixtureDir, "expected-specifiers.json")
+// TestGenerateOnlyScope verifies that --only-scope keeps only the scope's
+// packages at top level, along with the scopes they reach.
+func TestGenerateOnlyScope(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "resolve", "only-scope")
 
-	stdout, stderr, code := runCLI(t, "trace", htmlFile, "--package", fixtureDir, "--format", "specifiers")
+	stdout, stderr, code := runCLI(t, "generate", "--package", fixtureDir, "--only-scope", "@patternfly")
 	if code != 0 {
 		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
 	}
 
-	compareOrUpdateGolden(t, goldenFile, stdout)
+	compareOrUpdateGolden(t, filepath.Join(fixtureDir, "expected.json"), stdout)
+
+	_, stderr, code = runCLI(t, "generate", "--package", fixtureDir, "--only-scope", "patternfly")
+	if code == 0 {
+		t.Fatal("Expected non-zero exit code for a scope without @")
+	}
+	if !strings.Contains(stderr, "must be an npm scope") {
+		t.Errorf("Expected scope validation error, got stderr: %s", stderr)
+	}
 }
 
-func TestTraceHTMLFormat(t *testing.T) {
-	fixtureDir := filepath.Join("testdata", "trace", "with-deps")
-	htmlFile := filepath.Join(fixtureDir, "index.html")
+// TestGenerateConcurrency verifies that --concurrency doesn't change the output
+// and rejects limits below one.
+func TestGenerateConcurrency(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "resolve", "with-scopes")
 
-	stdout, stderr, code := runCLI(t, "trace", htmlFile, "--package", fixtureDir, "--format", "html")
+	stdout, stderr, code := runCLI(t, "generate", "--package", fixtureDir, "--concurrency", "1")
 	if code != 0 {
 		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
 	}
+	compareOrUpdateGolden(t, filepath.Join(fixtureDir, "expected.json"), stdout)
 
-	if !strings.HasPrefix(stdout, "<script type=\"importmap\">") {
-		t.Errorf("Expected HTML script tag prefix, got: %s", stdout[:min(50, len(stdout))])
+	_, stderr, code = runCLI(t, "generate", "--package", fixtureDir, "--concurrency", "0")
+	if code == 0 {
+		t.Error("Expected non-zero exit code for --concurrency 0")
 	}
+	if !strings.Contains(stderr, "must be at least 1") {
+		t.Errorf("Expected concurrency validation error, got: %s", stderr)
+	}
+}
 
-	if !strings.Contains(stdout, "</script>") {
-		t.Error("Expected closing script tag")
+// TestGenerateAllowSubpathOnly verifies that --allow-subpath-only maps a package
+// without a main export and suppresses the missing root warning.
+func TestGenerateNodeVersion(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "resolve", "engines")
+
+	_, stderr, code := runCLI(t, "generate", "--package", fixtureDir, "--node-version", "18.19.0")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+	if !strings.Contains(stderr, "Package 'modern-pkg' requires node >=20, which excludes 18.19.0") {
+		t.Errorf("Expected engines warning, got stderr: %s", stderr)
 	}
 
-	// Check that lit is in the import map
-	if !strings.Contains(stdout, "\"lit\"") {
-		t.Error("Expected 'lit' in import map")
+	_, stderr, code = runCLI(t, "generate", "--package", fixtureDir, "--node-version", "eighteen")
+	if code == 0 {
+		t.Error("Expected non-zero exit code for an invalid --node-version")
+	}
+	if !strings.Contains(stderr, "invalid --node-version") {
+		t.Errorf("Expected invalid version error, got: %s", stderr)
 	}
 }
 
-func TestTraceWithTemplate(t *testing.T) {
-	fixtureDir := filepath.Join("testdata", "trace", "with-deps")
-	htmlFile := filepath.Join(fixtureDir, "index.html")
-	goldenFile := filepath.Join(fixtureDir, "expected-template.json")
+func TestGenerateAllowSubpathOnly(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "resolve", "subpath-only")
 
-	stdout, stderr, code := runCLI(t, "trace", htmlFile, "--package", fixtureDir, "--template", "/assets/{package}/{path}")
+	stdout, stderr, code := runCLI(t, "generate", "--package", fixtureDir, "--allow-subpath-only")
 	if code != 0 {
 		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
 	}
+	if strings.Contains(stderr, "Warning:") {
+		t.Errorf("Expected no warnings, got stderr: %s", stderr)
+	}
 
-	compareOrUpdateGolden(t, goldenFile, stdout)
+	compareOrUpdateGolden(t, filepath.Join(fixtureDir, "expected.json"), stdout)
 }
 
-func TestTraceMissingFile(t *testing.T) {
-	_, stderr, code := runCLI(t, "trace", "nonexistent.html")
-	if code == 0 {
-		t.Error("Expected non-zero exit code for missing file")
-	}
+// TestGenerateStrictPaths verifies that export targets escaping their package
+// are warned about by default and fail generation with --strict-paths.
+func TestGenerateStrictPaths(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "resolve", "escaping-exports")
 
-	if !strings.Contains(stderr, "Error") {
-		t.Errorf("Expected error message, got: %s", stderr)
+	_, stderr, code := runCLI(t, "generate", "--package", fixtureDir)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+	if !strings.Contains(stderr, "escapes the package directory") {
+		t.Errorf("Expected escaping export warning, got stderr: %s", stderr)
 	}
-}
 
-func TestTraceMissingArg(t *testing.T) {
-	_, stderr, code := runCLI(t, "trace")
+	_, stderr, code = runCLI(t, "generate", "--package", fixtureDir, "--strict-paths")
 	if code == 0 {
-		t.Error("Expected non-zero exit code for missing argument")
+		t.Error("Expected non-zero exit code with --strict-paths")
 	}
-
-	if !strings.Contains(stderr, "no files to trace") {
-		t.Errorf("Expected 'no files to trace' error, got: %s", stderr)
+	if !strings.Contains(stderr, "evil-pkg/secret") {
+		t.Errorf("Expected error naming the escaping export, got stderr: %s", stderr)
 	}
 }
 
-func TestTraceInvalidFormat(t *testing.T) {
-	fixtureDir := filepath.Join("testdata", "trace", "with-deps")
-	htmlFile := filepath.Join(fixtureDir, "index.html")
+// TestSBOM verifies that sbom lists installed packages as CycloneDX components.
+func TestSBOM(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "resolve", "with-scopes")
 
-	_, stderr, code := runCLI(t, "trace", htmlFile, "--package", fixtureDir, "--format", "invalid")
-	if code == 0 {
-		t.Error("Expected non-zero exit code for invalid format")
+	stdout, stderr, code := runCLI(t, "sbom", "--package", fixtureDir)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
 	}
 
-	if !strings.Contains(stderr, "invalid format") {
-		t.Errorf("Expected 'invalid format' error, got: %s", stderr)
-	}
+	compareOrUpdateGolden(t, filepath.Join("testdata", "sbom", "local", "expected.json"), stdout)
 
-	if !strings.Contains(stderr, "json, html, specifiers") {
-		t.Errorf("Expected allowed formats in error, got: %s", stderr)
+	_, stderr, code = runCLI(t, "sbom", "--package", fixtureDir, "--cdn", "nope")
+	if code == 0 {
+		t.Error("Expected non-zero exit code for an unknown CDN provider")
+	}
+	if !strings.Contains(stderr, "invalid CDN provider") {
+		t.Errorf("Expected provider error, got stderr: %s", stderr)
 	}
 }
 
-func TestTraceBatchMultipleArgs(t *testing.T) {
-	fixtureDir := filepath.Join("testdata", "trace", "batch")
-	file1 := filepath.Join(fixtureDir, "page1.html")
-	file2 := filepath.Join(fixtureDir, "page2.html")
+// TestSBOMCDN verifies that sbom --cdn resolves versions from the registry
+// named in --npmrc and lists the provider's URLs. A test server stands in for
+// the registry and the CDN, serving the fixture's registry and cdn directories.
+func TestSBOMCDN(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "sbom", "cdn-registry")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, filepath.Join(fixtureDir, filepath.FromSlash(r.URL.Path)+".json"))
+	}))
+	defer server.Close()
 
-	stdout, stderr, code := runCLI(t, "trace", file1, file2, "--package", fixtureDir)
+	dir := t.TempDir()
+	npmrc := filepath.Join(dir, ".npmrc")
+	if err := os.WriteFile(npmrc, []byte("registry="+server.URL+"/registry\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	providers := filepath.Join(dir, "providers.json")
+	config := fmt.Sprintf(`{"providers": [{"name": "test-cdn", "packageJSONTemplate": "%[1]s/cdn/{package}@{version}", "moduleTemplate": "%[1]s/cdn/{package}@{version}/{path}"}]}`, server.URL)
+	if err := os.WriteFile(providers, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, stderr, code := runCLI(t, "sbom", "--package", fixtureDir, "--cdn-config", providers, "--cdn", "test-cdn", "--npmrc", npmrc, "--cache-ttl", "0")
 	if code != 0 {
 		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
 	}
 
-	// Should output NDJSON (one JSON object per line)
-	lines := strings.Split(strings.TrimSpace(stdout), "\n")
-	if len(lines) != 2 {
-		t.Fatalf("Expected 2 NDJSON lines, got %d: %s", len(lines), stdout)
-	}
+	stdout = strings.ReplaceAll(stdout, server.URL, "https://cdn.test")
+	compareOrUpdateGolden(t, filepath.Join(fixtureDir, "expected.json"), stdout)
+}
 
-	// Parse each line as JSON
-	for i, line := range lines {
-		var result map[string]any
-		if err := json.Unmarshal([]byte(line), &result); err != nil {
-			t.Fatalf("Failed to parse NDJSON line %d: %v\nline: %s", i, err, line)
-		}
+func TestSBOMRegistryFlagsRequireCDN(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "resolve", "with-scopes")
 
-		// Each result should have "file" and "imports" keys
-		if result["file"] == nil {
-			t.Errorf("Line %d: expected 'file' key", i)
+	for _, args := range [][]string{{"--npmrc", ".npmrc"}, {"--cache-ttl", "0"}} {
+		_, stderr, code := runCLI(t, append([]string{"sbom", "--package", fixtureDir}, args...)...)
+		if code == 0 {
+			t.Errorf("Expected non-zero exit code for %s without --cdn", args[0])
 		}
-		if result["imports"] == nil {
-			t.Errorf("Line %d: expected 'imports' key", i)
+		if !strings.Contains(stderr, args[0]+" requires --cdn") {
+			t.Errorf("Expected error about --cdn, got: %s", stderr)
 		}
 	}
 }
 
-func TestTraceBatchGlob(t *testing.T) {
-	fixtureDir := filepath.Join("testdata", "trace", "batch")
-	globPattern := filepath.Join(fixtureDir, "**", "*.html")
+// TestGenerateDetectCycles verifies that --detect-cycles reports dependency cycles as warnings.
+func TestGenerateDetectCycles(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "resolve", "dependency-cycle")
 
-	stdout, stderr, code := runCLI(t, "trace", "--glob", globPattern, "--package", fixtureDir)
+	_, stderr, code := runCLI(t, "generate", "--package", fixtureDir, "--detect-cycles")
 	if code != 0 {
 		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
 	}
+	if !strings.Contains(stderr, "cycle: pkg-a -> pkg-b -> pkg-a") {
+		t.Errorf("Expected cycle warning, got stderr: %s", stderr)
+	}
+}
 
-	// Should find all 3 HTML files
-	lines := strings.Split(strings.TrimSpace(stdout), "\n")
-	if len(lines) != 3 {
-		t.Fatalf("Expected 3 NDJSON lines (page1.html, page2.html, subdir/page3.html), got %d: %s", len(lines), stdout)
+// TestGenerateDedupeReport verifies that --dedupe-report lists packages installed at several versions.
+func TestGenerateDedupeReport(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "resolve", "duplicate-versions")
+
+	_, stderr, code := runCLI(t, "generate", "--package", fixtureDir, "--dedupe-report")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+	if !strings.Contains(stderr, "duplicate: lit-html has 2 versions: 2.8.0") {
+		t.Errorf("Expected duplicate warning, got stderr: %s", stderr)
+	}
+	if strings.Contains(stderr, "duplicate: lit ") {
+		t.Errorf("Expected no warning for single-version lit, got stderr: %s", stderr)
 	}
+}
 
-	// Parse each line and track found files
-	foundFiles := make(map[string]bool)
-	for i, line := range lines {
-		var result map[string]any
-		if err := json.Unmarshal([]byte(line), &result); err != nil {
-			t.Fatalf("Failed to parse NDJSON line %d: %v", i, err)
-		}
+func TestGenerateDevelopment(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "resolve", "development-condition")
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{"default", nil, "/node_modules/dev-lib/index.js"},
+		{"development", []string{"--development"}, "/node_modules/dev-lib/dev/index.js"},
+		{"development with conditions", []string{"--development", "--conditions", "browser,default"}, "/node_modules/dev-lib/dev/index.js"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"generate", "--package", fixtureDir}, tt.args...)
+			stdout, stderr, code := runCLI(t, args...)
+			if code != 0 {
+				t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+			}
 
-		// Track found files
-		if file, ok := result["file"].(string); ok {
-			foundFiles[filepath.Base(file)] = true
-		}
+			var result struct {
+				Imports map[string]string `json:"imports"`
+			}
+			if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+				t.Fatalf("Failed to parse JSON output: %v\nstdout: %s", err, stdout)
+			}
 
-		// Verify imports contain "lit"
-		imports, ok := result["imports"].(map[string]any)
-		if !ok {
-			t.Errorf("Line %d: expected imports object", i)
-			continue
-		}
-		if imports["lit"] == nil {
-			t.Errorf("Line %d: expected 'lit' in imports", i)
-		}
+			if result.Imports["dev-lib"] != tt.expected {
+				t.Errorf("Expected dev-lib -> %q, got %q", tt.expected, result.Imports["dev-lib"])
+			}
+		})
 	}
+}
 
-	// Verify all expected files were processed
-	expectedFiles := []string{"page1.html", "page2.html", "page3.html"}
-	for _, f := range expectedFiles {
-		if !foundFiles[f] {
-			t.Errorf("Expected file %q not found in output", f)
-		}
+func TestGenerateGraphOnlyRequiresCDN(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "resolve", "simple-pkg")
+
+	_, stderr, code := runCLI(t, "generate", "--package", fixtureDir, "--graph-only")
+	if code == 0 {
+		t.Error("Expected non-zero exit code for --graph-only without --cdn")
+	}
+	if !strings.Contains(stderr, "--graph-only requires --cdn") {
+		t.Errorf("Expected error about --cdn, got: %s", stderr)
 	}
 }
 
-func TestTraceBatchJobs(t *testing.T) {
-	fixtureDir := filepath.Join("testdata", "trace", "batch")
-	file1 := filepath.Join(fixtureDir, "page1.html")
-	file2 := filepath.Join(fixtureDir, "page2.html")
+func TestGenerateSourceMapManifestRequiresCDN(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "resolve", "simple-pkg")
+	manifest := filepath.Join(t.TempDir(), "sourcemaps.json")
 
-	// Test with explicit jobs flag
-	stdout, stderr, code := runCLI(t, "trace", file1, file2, "--package", fixtureDir, "-j", "2")
-	if code != 0 {
-		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	_, stderr, code := runCLI(t, "generate", "--package", fixtureDir, "--source-map-manifest", manifest)
+	if code == 0 {
+		t.Error("Expected non-zero exit code for --source-map-manifest without --cdn")
 	}
-
-	lines := strings.Split(strings.TrimSpace(stdout), "\n")
-	if len(lines) != 2 {
-		t.Fatalf("Expected 2 NDJSON lines, got %d", len(lines))
+	if !strings.Contains(stderr, "--source-map-manifest requires --cdn") {
+		t.Errorf("Expected error about --cdn, got: %s", stderr)
 	}
 }
 
-func TestTraceBatchHTMLFormatError(t *testing.T) {
-	fixtureDir := filepath.Join("testdata", "trace", "batch")
-	file1 := filepath.Join(fixtureDir, "page1.html")
-	file2 := filepath.Join(fixtureDir, "page2.html")
+func TestGenerateNpmrcRequiresCDN(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "resolve", "simple-pkg")
+	npmrc := filepath.Join(t.TempDir(), ".npmrc")
 
-	// HTML format should fail for batch mode
-	_, stderr, code := runCLI(t, "trace", file1, file2, "--package", fixtureDir, "--format", "html")
+	_, stderr, code := runCLI(t, "generate", "--package", fixtureDir, "--npmrc", npmrc)
 	if code == 0 {
-		t.Error("Expected non-zero exit code for html format in batch mode")
+		t.Error("Expected non-zero exit code for --npmrc without --cdn")
 	}
-
-	if !strings.Contains(stderr, "not supported for batch mode") {
-		t.Errorf("Expected 'not supported for batch mode' error, got: %s", stderr)
+	if !strings.Contains(stderr, "--npmrc requires --cdn") {
+		t.Errorf("Expected error about --cdn, got: %s", stderr)
 	}
 }
 
-// TestTraceDeepImportNotInExports verifies that traced bare specifiers that
-// are deep imports (not listed in package exports) are still resolved.
-// This is a regression test for https://github.com/bennypowers/mappa/issues/15
-func TestTraceDeepImportNotInExports(t *testing.T) {
-	fixtureDir := filepath.Join("testdata", "trace", "unresolvable")
-	file := filepath.Join(fixtureDir, "page.html")
+func TestGenerateCacheTTLRequiresCDN(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "resolve", "simple-pkg")
 
-	stdout, stderr, code := runCLI(t, "trace", file, "--package", fixtureDir)
-	if code != 0 {
-		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	_, stderr, code := runCLI(t, "generate", "--package", fixtureDir, "--cache-ttl", "0")
+	if code == 0 {
+		t.Error("Expected non-zero exit code for --cache-ttl without --cdn")
 	}
-
-	// Parse output
-	var result map[string]any
-	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
-		t.Fatalf("Failed to parse JSON output: %v\nstdout: %s", err, stdout)
+	if !strings.Contains(stderr, "--cache-ttl requires --cdn") {
+		t.Errorf("Expected error about --cdn, got: %s", stderr)
 	}
+}
 
-	// The traced specifier @example/core/button/button.js should be in imports
-	// even though it's not in the package's exports field
-	imports, ok := result["imports"].(map[string]any)
-	if !ok {
-		t.Fatalf("Expected 'imports' key in output, got keys: %v", result)
+func TestGenerateStableOnlyRequiresCDN(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "resolve", "simple-pkg")
+
+	_, stderr, code := runCLI(t, "generate", "--package", fixtureDir, "--stable-only")
+	if code == 0 {
+		t.Error("Expected non-zero exit code for --stable-only without --cdn")
 	}
+	if !strings.Contains(stderr, "--stable-only requires --cdn") {
+		t.Errorf("Expected error about --cdn, got: %s", stderr)
+	}
+}
 
-	if imports["@example/core/button/button.js"] == nil {
-		t.Errorf("Expected '@example/core/button/button.js' in imports, got: %v", imports)
+// expectCDNConflict runs generate with --cdn and a local-only flag, which
+// must fail before anything is fetched.
+func expectCDNConflict(t *testing.T, flag string, args ...string) {
+	t.Helper()
+	fixtureDir := filepath.Join("testdata", "resolve", "simple-pkg")
+
+	_, stderr, code := runCLI(t, append([]string{"generate", "--package", fixtureDir, "--cdn", "esm.sh", "--cache-ttl", "0", flag}, args...)...)
+	if code == 0 {
+		t.Errorf("Expected non-zero exit code for %s with --cdn", flag)
+	}
+	if !strings.Contains(stderr, flag+" cannot be combined with --cdn") {
+		t.Errorf("Expected error about --cdn, got: %s", stderr)
 	}
 }
 
-// TestTraceBatchDeepImportNotInExports verifies that batch mode correctly resolves
+func TestGenerateInlineBelowWithCDN(t *testing.T) {
+	expectCDNConflict(t, "--inline-below", "1024")
+}
+
+func TestGenerateDetectCyclesWithCDN(t *testing.T) {
+	expectCDNConflict(t, "--detect-cycles")
+}
+
+func TestGenerateAllowSubpathOnlyWithCDN(t *testing.T) {
+	expectCDNConflict(t, "--allow-subpath-only")
+}
+
+func TestGenerateConcurrencyWithCDN(t *testing.T) {
+	expectCDNConflict(t, "--concurrency", "2")
+}
+
+func TestGenerateStrictPathsWithCDN(t *testing.T) {
+	expectCDNConflict(t, "--strict-paths")
+}
+
+func TestGenerateDedupeReportWithCDN(t *testing.T) {
+	expectCDNConflict(t, "--dedupe-report")
+}
+
+func TestGenerateAsOfRequiresCDN(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "resolve", "simple-pkg")
+
+	_, stderr, code := runCLI(t, "generate", "--package", fixtureDir, "--as-of", "2024-01-01")
+	if code == 0 {
+		t.Error("Expected non-zero exit code for --as-of without --cdn")
+	}
+	if !strings.Contains(stderr, "--as-of requires --cdn") {
+		t.Errorf("Expected error about --cdn, got: %s", stderr)
+	}
+}
+
+func TestGenerateScopeStrategy(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "resolve", "simple-pkg")
+
+	_, stderr, code := runCLI(t, "generate", "--package", fixtureDir, "--scope-strategy", "per-importer")
+	if code == 0 {
+		t.Error("Expected non-zero exit code for --scope-strategy without --cdn")
+	}
+	if !strings.Contains(stderr, "--scope-strategy requires --cdn") {
+		t.Errorf("Expected error about --cdn, got: %s", stderr)
+	}
+
+	_, stderr, code = runCLI(t, "generate", "--package", fixtureDir, "--cdn", "esm.sh", "--cache-ttl", "0", "--scope-strategy", "per-package")
+	if code == 0 {
+		t.Error("Expected non-zero exit code for an unknown scope strategy")
+	}
+	if !strings.Contains(stderr, `unknown scope strategy "per-package"`) {
+		t.Errorf("Expected unknown strategy error, got: %s", stderr)
+	}
+}
+
+func TestGenerateWarnings(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "resolve", "no-exports-pkg")
+	expectedWarning := "Package 'broken-lib' has no root export or main field; only subpath imports will work"
+
+	t.Run("stderr by default", func(t *testing.T) {
+		stdout, stderr, code := runCLI(t, "generate", "--package", fixtureDir)
+		if code != 0 {
+			t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+		}
+		if !strings.Contains(stderr, "Warning: "+expectedWarning) {
+			t.Errorf("Expected warning on stderr, got: %s", stderr)
+		}
+		if strings.Contains(stdout, "warnings") {
+			t.Errorf("Expected no warnings in output, got: %s", stdout)
+		}
+	})
+
+	t.Run("json output", func(t *testing.T) {
+		stdout, stderr, code := runCLI(t, "generate", "--package", fixtureDir, "--warnings")
+		if code != 0 {
+			t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+		}
+
+		var result struct {
+			Imports  map[string]string `json:"imports"`
+			Warnings []struct {
+				Severity string `json:"severity"`
+				Message  string `json:"message"`
+			} `json:"warnings"`
+		}
+		if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+			t.Fatalf("Failed to parse JSON output: %v\nstdout: %s", err, stdout)
+		}
+		if result.Imports["broken-lib/"] == "" {
+			t.Errorf("Expected broken-lib/ import, got %v", result.Imports)
+		}
+		if len(result.Warnings) != 1 || result.Warnings[0].Message != expectedWarning || result.Warnings[0].Severity != "warning" {
+			t.Errorf("Unexpected warnings: %+v", result.Warnings)
+		}
+	})
+
+	t.Run("requires json format", func(t *testing.T) {
+		_, stderr, code := runCLI(t, "generate", "--package", fixtureDir, "--warnings", "--format", "html")
+		if code == 0 {
+			t.Error("Expected non-zero exit code for --warnings with html format")
+		}
+		if !strings.Contains(stderr, "--warnings requires --format json") {
+			t.Errorf("Expected error about format, got: %s", stderr)
+		}
+	})
+}
+
+func TestGenerateInvalidCDN(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "resolve", "simple-pkg")
+
+	_, stderr, code := runCLI(t, "generate", "--package", fixtureDir, "--cdn", "nope")
+	if code == 0 {
+		t.Error("Expected non-zero exit code for invalid CDN provider")
+	}
+	if !strings.Contains(stderr, "invalid CDN provider") {
+		t.Errorf("Expected invalid provider error, got: %s", stderr)
+	}
+}
+
+func TestGenerateCDNConfig(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "resolve", "simple-pkg")
+	config := filepath.Join("cdn", "testdata", "providers.json")
+
+	// Loaded providers are offered alongside the predefined ones
+	_, stderr, code := runCLI(t, "generate", "--package", fixtureDir, "--cdn-config", config, "--cdn", "nope")
+	if code == 0 {
+		t.Error("Expected non-zero exit code for invalid CDN provider")
+	}
+	if !strings.Contains(stderr, "mymirror") {
+		t.Errorf("Expected configured providers in the error, got: %s", stderr)
+	}
+
+	_, stderr, code = runCLI(t, "generate", "--package", fixtureDir, "--cdn-config", config)
+	if code == 0 {
+		t.Error("Expected non-zero exit code for --cdn-config without --cdn")
+	}
+	if !strings.Contains(stderr, "--cdn-config requires --cdn") {
+		t.Errorf("Expected error about --cdn, got: %s", stderr)
+	}
+
+	invalid := filepath.Join(t.TempDir(), "providers.json")
+	if err := os.WriteFile(invalid, []byte(`{"providers": [{"name": "unpkg"}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, stderr, code = runCLI(t, "generate", "--package", fixtureDir, "--cdn-config", invalid, "--cdn", "unpkg")
+	if code == 0 {
+		t.Error("Expected non-zero exit code for an invalid provider config")
+	}
+	if !strings.Contains(stderr, "conflicts with a predefined provider") {
+		t.Errorf("Expected provider config error, got: %s", stderr)
+	}
+}
+
+func TestGenerateHTMLFormat(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "resolve", "simple-pkg")
+
+	stdout, stderr, code := runCLI(t, "generate", "--package", fixtureDir, "--format", "html")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+
+	if !strings.HasPrefix(stdout, "<script type=\"importmap\">") {
+		t.Errorf("Expected HTML script tag prefix, got: %s", stdout[:min(50, len(stdout))])
+	}
+
+	if !strings.Contains(stdout, "</script>") {
+		t.Error("Expected closing script tag")
+	}
+
+	// Extract JSON from script tag and verify it's valid
+	jsonStart := strings.Index(stdout, "\n") + 1
+	jsonEnd := strings.LastIndex(stdout, "\n</script>")
+	if jsonStart > 0 && jsonEnd > jsonStart {
+		jsonStr := stdout[jsonStart:jsonEnd]
+		var result map[string]any
+		if err := json.Unmarshal([]byte(jsonStr), &result); err != nil {
+			t.Fatalf("Failed to parse embedded JSON: %v", err)
+		}
+	}
+}
+
+func TestGenerateESMFormat(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "resolve", "simple-pkg")
+
+	stdout, stderr, code := runCLI(t, "generate", "--package", fixtureDir, "--format", "esm")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+
+	out := strings.TrimSpace(stdout)
+	if !strings.HasPrefix(out, "export default ") || !strings.HasSuffix(out, ";") {
+		t.Fatalf("Expected an export default statement, got: %s", out)
+	}
+
+	// The exported object literal is the import map JSON
+	var result map[string]any
+	jsonStr := strings.TrimSuffix(strings.TrimPrefix(out, "export default "), ";")
+	if err := json.Unmarshal([]byte(jsonStr), &result); err != nil {
+		t.Fatalf("Failed to parse embedded JSON: %v", err)
+	}
+	if _, ok := result["imports"]; !ok {
+		t.Errorf("Expected imports in exported object, got: %v", result)
+	}
+}
+
+func TestGenerateStats(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "resolve", "linked-workspace")
+
+	stdout, stderr, code := runCLI(t, "generate", "--package", fixtureDir, "--stats")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+
+	// Stats go to stderr, leaving the import map on stdout intact
+	var result map[string]any
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v\nstdout: %s", err, stdout)
+	}
+	for _, want := range []string{"imports: 4", "scopes:  1 (2 imports)", "size:    ", "gzipped: "} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("Expected stderr to contain %q, got: %s", want, stderr)
+		}
+	}
+}
+
+func TestGenerateOutputFile(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "resolve", "simple-pkg")
+	tmpFile := filepath.Join(t.TempDir(), "importmap.json")
+
+	stdout, stderr, code := runCLI(t, "generate", "--package", fixtureDir, "--output", tmpFile)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+
+	if stdout != "" {
+		t.Errorf("Expected no stdout when writing to file, got: %s", stdout)
+	}
+
+	content, err := os.ReadFile(tmpFile)
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+
+	var result map[string]any
+	if err := json.Unmarshal(content, &result); err != nil {
+		t.Fatalf("Failed to parse output file JSON: %v", err)
+	}
+
+	val, exists := result["imports"]
+	if !exists || val == nil {
+		t.Fatalf("Expected imports object, got nil")
+	}
+	imports, ok := val.(map[string]any)
+	if !ok {
+		t.Fatalf("Expected imports object, got %T", val)
+	}
+	if imports["lit"] == nil {
+		t.Error("Expected lit import in output file")
+	}
+}
+
+func TestTrace(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "trace", "with-deps")
+	htmlFile := filepath.Join(fixtureDir, "index.html")
+	goldenFile := filepath.Join(fixtureDir, "expected.json")
+
+	// Default format (json) outputs import map
+	stdout, stderr, code := runCLI(t, "trace", htmlFile, "--package", fixtureDir)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+
+	compareOrUpdateGolden(t, goldenFile, stdout)
+}
+
+func TestTraceSpecifiersFormat(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "trace", "with-deps")
+	htmlFile := filepath.Join(fixtureDir, "index.html")
+	goldenFile := filepath.Join(fixtureDir, "expected-specifiers.json")
+
+	stdout, stderr, code := runCLI(t, "trace", htmlFile, "--package", fixtureDir, "--format", "specifiers")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+
+	compareOrUpdateGolden(t, goldenFile, stdout)
+}
+
+func TestTraceHTMLFormat(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "trace", "with-deps")
+	htmlFile := filepath.Join(fixtureDir, "index.html")
+
+	stdout, stderr, code := runCLI(t, "trace", htmlFile, "--package", fixtureDir, "--format", "html")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+
+	if !strings.HasPrefix(stdout, "<script type=\"importmap\">") {
+		t.Errorf("Expected HTML script tag prefix, got: %s", stdout[:min(50, len(stdout))])
+	}
+
+	if !strings.Contains(stdout, "</script>") {
+		t.Error("Expected closing script tag")
+	}
+
+	// Check that lit is in the import map
+	if !strings.Contains(stdout, "\"lit\"") {
+		t.Error("Expected 'lit' in import map")
+	}
+}
+
+func TestTraceFlatFormat(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "trace", "transitive")
+	htmlFile := filepath.Join(fixtureDir, "index.html")
+
+	stdout, stderr, code := runCLI(t, "trace", htmlFile, "--package", fixtureDir, "--format", "flat")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+
+	var flat map[string]string
+	if err := json.Unmarshal([]byte(stdout), &flat); err != nil {
+		t.Fatalf("Failed to parse flat output as a string map: %v\nstdout: %s", err, stdout)
+	}
+	if flat["lit"] != "/node_modules/lit/index.js" {
+		t.Errorf("Expected lit at the top level, got: %v", flat)
+	}
+	if _, ok := flat["imports"]; ok {
+		t.Errorf("Expected no imports envelope, got: %v", flat)
+	}
+}
+
+func TestTracePreloadFormat(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "trace", "preload")
+	htmlFile := filepath.Join(fixtureDir, "index.html")
+
+	stdout, stderr, code := runCLI(t, "trace", htmlFile, "--package", fixtureDir, "--format", "preload")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+
+	expected, err := os.ReadFile(filepath.Join(fixtureDir, "expected-preload.html"))
+	if err != nil {
+		t.Fatalf("Failed to read expected output: %v", err)
+	}
+	if stdout != string(expected) {
+		t.Errorf("Preload hints mismatch:\ngot:\n%s\nwant:\n%s", stdout, expected)
+	}
+}
+
+// TestTracePreloadFormatCrossOrigin verifies that preload links mirror the
+// crossorigin setting of the page's module scripts, and that classic
+// nomodule scripts are not traced.
+func TestTracePreloadFormatCrossOrigin(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "trace", "nomodule")
+	htmlFile := filepath.Join(fixtureDir, "index.html")
+
+	stdout, stderr, code := runCLI(t, "trace", htmlFile, "--package", fixtureDir, "--format", "preload")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+
+	compareOrUpdateGolden(t, filepath.Join(fixtureDir, "expected-preload.html"), stdout)
+}
+
+func TestTracePreloadFormatBatch(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "trace", "preload")
+	htmlFile := filepath.Join(fixtureDir, "index.html")
+
+	_, stderr, code := runCLI(t, "trace", htmlFile, htmlFile+"x", "--package", fixtureDir, "--format", "preload")
+	if code == 0 {
+		t.Fatal("Expected non-zero exit code for --format preload in batch mode")
+	}
+	if !strings.Contains(stderr, "--format preload is not supported") {
+		t.Errorf("Expected unsupported format error, got: %s", stderr)
+	}
+}
+
+func TestTraceWithTemplate(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "trace", "with-deps")
+	htmlFile := filepath.Join(fixtureDir, "index.html")
+	goldenFile := filepath.Join(fixtureDir, "expected-template.json")
+
+	stdout, stderr, code := runCLI(t, "trace", htmlFile, "--package", fixtureDir, "--template", "/assets/{package}/{path}")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+
+	compareOrUpdateGolden(t, goldenFile, stdout)
+}
+
+func TestTraceMissingFile(t *testing.T) {
+	_, stderr, code := runCLI(t, "trace", "nonexistent.html")
+	if code == 0 {
+		t.Error("Expected non-zero exit code for missing file")
+	}
+
+	if !strings.Contains(stderr, "Error") {
+		t.Errorf("Expected error message, got: %s", stderr)
+	}
+}
+
+func TestTraceMissingArg(t *testing.T) {
+	_, stderr, code := runCLI(t, "trace")
+	if code == 0 {
+		t.Error("Expected non-zero exit code for missing argument")
+	}
+
+	if !strings.Contains(stderr, "no files to trace") {
+		t.Errorf("Expected 'no files to trace' error, got: %s", stderr)
+	}
+}
+
+func TestTraceInvalidFormat(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "trace", "with-deps")
+	htmlFile := filepath.Join(fixtureDir, "index.html")
+
+	_, stderr, code := runCLI(t, "trace", htmlFile, "--package", fixtureDir, "--format", "invalid")
+	if code == 0 {
+		t.Error("Expected non-zero exit code for invalid format")
+	}
+
+	if !strings.Contains(stderr, "invalid format") {
+		t.Errorf("Expected 'invalid format' error, got: %s", stderr)
+	}
+
+	if !strings.Contains(stderr, "json, html, specifiers") {
+		t.Errorf("Expected allowed formats in error, got: %s", stderr)
+	}
+}
+
+func TestTraceBatchMultipleArgs(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "trace", "batch")
+	file1 := filepath.Join(fixtureDir, "page1.html")
+	file2 := filepath.Join(fixtureDir, "page2.html")
+
+	stdout, stderr, code := runCLI(t, "trace", file1, file2, "--package", fixtureDir)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+
+	// Should output NDJSON (one JSON object per line)
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 NDJSON lines, got %d: %s", len(lines), stdout)
+	}
+
+	// Parse each line as JSON
+	for i, line := range lines {
+		var result map[string]any
+		if err := json.Unmarshal([]byte(line), &result); err != nil {
+			t.Fatalf("Failed to parse NDJSON line %d: %v\nline: %s", i, err, line)
+		}
+
+		// Each result should have "file" and "imports" keys
+		if result["file"] == nil {
+			t.Errorf("Line %d: expected 'file' key", i)
+		}
+		if result["imports"] == nil {
+			t.Errorf("Line %d: expected 'imports' key", i)
+		}
+	}
+}
+
+// TestTraceIgnoreErrors verifies that --ignore-errors exits zero when files fail
+// to trace, reporting the failures in the NDJSON and a count on stderr.
+func TestTraceIgnoreErrors(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "trace", "batch")
+	page := filepath.Join(fixtureDir, "page1.html")
+	missing := filepath.Join(fixtureDir, "missing.html")
+	alsoMissing := filepath.Join(fixtureDir, "also-missing.html")
+
+	stdout, stderr, code := runCLI(t, "trace", page, missing, "--package", fixtureDir, "--ignore-errors")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+	if !strings.Contains(stderr, "1 of 2 files failed to trace") {
+		t.Errorf("Expected failure count on stderr, got: %s", stderr)
+	}
+
+	var failed int
+	for line := range strings.SplitSeq(strings.TrimSpace(stdout), "\n") {
+		var result struct {
+			File  string `json:"file"`
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(line), &result); err != nil {
+			t.Fatalf("Failed to parse NDJSON line: %v\nline: %s", err, line)
+		}
+		if result.Error != "" {
+			failed++
+			if !strings.HasSuffix(result.File, "missing.html") {
+				t.Errorf("Unexpected failure for %s: %s", result.File, result.Error)
+			}
+		}
+	}
+	if failed != 1 {
+		t.Errorf("Expected 1 failed result in NDJSON, got %d:\n%s", failed, stdout)
+	}
+
+	// Even when every file fails
+	_, stderr, code = runCLI(t, "trace", missing, alsoMissing, "--package", fixtureDir, "--ignore-errors")
+	if code != 0 {
+		t.Errorf("Expected exit code 0 when all files fail, got %d\nstderr: %s", code, stderr)
+	}
+	_, _, code = runCLI(t, "trace", missing, alsoMissing, "--package", fixtureDir)
+	if code == 0 {
+		t.Error("Expected non-zero exit code when all files fail without --ignore-errors")
+	}
+}
+
+// TestTraceTSConfigPaths verifies that --tsconfig maps path aliases to the
+// local files they resolve to, traces through them, and doesn't report them
+// as uninstalled packages.
+func TestTraceTSConfigPaths(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "trace", "tsconfig-paths")
+
+	stdout, stderr, code := runCLI(t, "trace", filepath.Join(fixtureDir, "index.html"),
+		"--package", fixtureDir, "--tsconfig", filepath.Join(fixtureDir, "tsconfig.json"))
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+	if strings.Contains(stderr, "@app/") {
+		t.Errorf("Expected no warnings for aliased specifiers, got stderr: %s", stderr)
+	}
+
+	compareOrUpdateGolden(t, filepath.Join(fixtureDir, "expected.json"), stdout)
+}
+
+// TestTraceStopsAtWorkspaceRoot verifies that the trace doesn't follow bare
+// specifiers into a node_modules directory above the project.
+func TestTraceStopsAtWorkspaceRoot(t *testing.T) {
+	dir := t.TempDir()
+	project := filepath.Join(dir, "project")
+	for path, content := range map[string]string{
+		filepath.Join(dir, "node_modules", "stray", "package.json"):   `{"name": "stray", "version": "1.0.0", "main": "index.js"}`,
+		filepath.Join(dir, "node_modules", "stray", "index.js"):       `export default 1;`,
+		filepath.Join(project, "package.json"):                        `{"name": "app", "version": "1.0.0", "dependencies": {"lit": "^3.0.0"}}`,
+		filepath.Join(project, "node_modules", "lit", "package.json"): `{"name": "lit", "version": "3.0.0", "main": "index.js"}`,
+		filepath.Join(project, "node_modules", "lit", "index.js"):     `export {};`,
+		filepath.Join(project, "index.html"):                          `<script type="module">import 'lit'; import 'stray';</script>`,
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stdout, stderr, code := runCLI(t, "trace", filepath.Join(project, "index.html"), "--package", project, "--format", "specifiers")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+
+	var result struct {
+		Modules []string `json:"modules"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("Failed to parse output: %v\n%s", err, stdout)
+	}
+	if want := []string{"node_modules/lit/index.js"}; !slices.Equal(result.Modules, want) {
+		t.Errorf("Expected modules %v, got %v", want, result.Modules)
+	}
+}
+
+// TestTraceMerge verifies that --merge outputs one import map covering the
+// bare specifiers of every traced page.
+func TestTraceMerge(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "trace", "merge")
+	globPattern := filepath.Join(fixtureDir, "**", "*.html")
+
+	stdout, stderr, code := runCLI(t, "trace", "--glob", globPattern, "--merge", "--package", fixtureDir)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+
+	compareOrUpdateGolden(t, filepath.Join(fixtureDir, "expected.json"), stdout)
+
+	_, stderr, code = runCLI(t, "trace", "--glob", globPattern, "--merge", "--package", fixtureDir, "--format", "specifiers")
+	if code == 0 {
+		t.Error("Expected non-zero exit code for --merge with specifiers format")
+	}
+	if !strings.Contains(stderr, "not supported with --merge") {
+		t.Errorf("Expected format error, got stderr: %s", stderr)
+	}
+}
+
+// TestTraceProfiling verifies that a batch trace can write a CPU profile and
+// print a per-phase timing breakdown.
+func TestTraceProfiling(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "trace", "batch")
+	globPattern := filepath.Join(fixtureDir, "**", "*.html")
+	profile := filepath.Join(t.TempDir(), "trace.prof")
+
+	_, stderr, code := runCLI(t, "trace", "--glob", globPattern, "--package", fixtureDir, "--timings", "--cpuprofile", profile)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+
+	for _, phase := range []string{"html parse:", "import extraction:", "resolution:", "wall:"} {
+		if !strings.Contains(stderr, phase) {
+			t.Errorf("Expected %q in timings, got stderr: %s", phase, stderr)
+		}
+	}
+
+	// pprof profiles are gzip-compressed protobufs
+	data, err := os.ReadFile(profile)
+	if err != nil {
+		t.Fatalf("Expected CPU profile to be written: %v", err)
+	}
+	if len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+		t.Errorf("Expected gzip-compressed pprof data, got %d bytes", len(data))
+	}
+}
+
+func TestTraceInputMap(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "trace", "batch")
+	inputMap := filepath.Join("testdata", "trace", "input-map", "manual.json")
+	file1 := filepath.Join(fixtureDir, "page1.html")
+	file2 := filepath.Join(fixtureDir, "page2.html")
+
+	check := func(t *testing.T, imports map[string]string) {
+		t.Helper()
+		if imports["lit"] != "/vendor/lit.js" {
+			t.Errorf("Expected input map to take precedence for lit, got: %v", imports)
+		}
+		if imports["widgets/"] != "/widgets/" {
+			t.Errorf("Expected untraced input map entry to be merged, got: %v", imports)
+		}
+	}
+
+	t.Run("single file", func(t *testing.T) {
+		stdout, stderr, code := runCLI(t, "trace", file1, "--package", fixtureDir, "--input-map", inputMap)
+		if code != 0 {
+			t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+		}
+		var result importmap.ImportMap
+		if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+			t.Fatalf("Failed to parse JSON output: %v\nstdout: %s", err, stdout)
+		}
+		check(t, result.Imports)
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		stdout, stderr, code := runCLI(t, "trace", file1, file2, "--package", fixtureDir, "--input-map", inputMap)
+		if code != 0 {
+			t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+		}
+		lines := strings.Split(strings.TrimSpace(stdout), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("Expected 2 NDJSON lines, got %d: %s", len(lines), stdout)
+		}
+		for _, line := range lines {
+			var result struct {
+				Imports map[string]string `json:"imports"`
+			}
+			if err := json.Unmarshal([]byte(line), &result); err != nil {
+				t.Fatalf("Failed to parse NDJSON line: %v\nline: %s", err, line)
+			}
+			check(t, result.Imports)
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, stderr, code := runCLI(t, "trace", file1, "--package", fixtureDir, "--input-map", filepath.Join(t.TempDir(), "missing.json"))
+		if code == 0 {
+			t.Fatal("Expected non-zero exit code for a missing input map")
+		}
+		if !strings.Contains(stderr, "failed to read input map") {
+			t.Errorf("Expected read error, got stderr: %s", stderr)
+		}
+	})
+}
+
+func TestTraceBatchGlob(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "trace", "batch")
+	globPattern := filepath.Join(fixtureDir, "**", "*.html")
+
+	stdout, stderr, code := runCLI(t, "trace", "--glob", globPattern, "--package", fixtureDir)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+
+	// Should find all 3 HTML files
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 NDJSON lines (page1.html, page2.html, subdir/page3.html), got %d: %s", len(lines), stdout)
+	}
+
+	// Parse each line and track found files
+	foundFiles := make(map[string]bool)
+	for i, line := range lines {
+		var result map[string]any
+		if err := json.Unmarshal([]byte(line), &result); err != nil {
+			t.Fatalf("Failed to parse NDJSON line %d: %v", i, err)
+		}
+
+		// Track found files
+		if file, ok := result["file"].(string); ok {
+			foundFiles[filepath.Base(file)] = true
+		}
+
+		// Verify imports contain "lit"
+		imports, ok := result["imports"].(map[string]any)
+		if !ok {
+			t.Errorf("Line %d: expected imports object", i)
+			continue
+		}
+		if imports["lit"] == nil {
+			t.Errorf("Line %d: expected 'lit' in imports", i)
+		}
+	}
+
+	// Verify all expected files were processed
+	expectedFiles := []string{"page1.html", "page2.html", "page3.html"}
+	for _, f := range expectedFiles {
+		if !foundFiles[f] {
+			t.Errorf("Expected file %q not found in output", f)
+		}
+	}
+}
+
+func TestTraceOutputDir(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "trace", "batch")
+	globPattern := filepath.Join(fixtureDir, "**", "*.html")
+	outputDir := filepath.Join(t.TempDir(), "maps")
+
+	stdout, stderr, code := runCLI(t, "trace", "--glob", globPattern, "--package", fixtureDir, "--output-dir", outputDir)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+	if stdout != "" {
+		t.Errorf("Expected no NDJSON on stdout with --output-dir, got: %s", stdout)
+	}
+
+	for _, name := range []string{"page1.importmap.json", "page2.importmap.json", filepath.Join("subdir", "page3.importmap.json")} {
+		data, err := os.ReadFile(filepath.Join(outputDir, name))
+		if err != nil {
+			t.Errorf("Expected %s to be written: %v", name, err)
+			continue
+		}
+		var im map[string]any
+		if err := json.Unmarshal(data, &im); err != nil {
+			t.Errorf("Failed to parse %s: %v", name, err)
+		}
+	}
+
+	// A custom suffix replaces the default
+	suffixDir := filepath.Join(t.TempDir(), "maps")
+	if _, stderr, code := runCLI(t, "trace", "--glob", globPattern, "--package", fixtureDir, "--output-dir", suffixDir, "--output-suffix", ".map.json"); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+	if _, err := os.Stat(filepath.Join(suffixDir, "subdir", "page3.map.json")); err != nil {
+		t.Errorf("Expected page3.map.json to be written: %v", err)
+	}
+}
+
+func TestTraceOutputDirCollision(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "trace", "batch")
+
+	_, stderr, code := runCLI(t, "trace",
+		filepath.Join(fixtureDir, "page1.html"), filepath.Join(fixtureDir, "page1.htm"),
+		"--package", fixtureDir, "--output-dir", t.TempDir())
+	if code == 0 {
+		t.Fatal("Expected non-zero exit code when two files map to one output path")
+	}
+	if !strings.Contains(stderr, "would both be written to") {
+		t.Errorf("Expected collision error, got: %s", stderr)
+	}
+}
+
+func TestTraceDedupe(t *testing.T) {
+	siteDir := t.TempDir()
+	copyDir(t, filepath.Join("testdata", "trace", "batch"), siteDir)
+	page, err := os.ReadFile(filepath.Join(siteDir, "page1.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pagesDir := filepath.Join(siteDir, "pages")
+	if err := os.MkdirAll(pagesDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 100 {
+		if err := os.WriteFile(filepath.Join(pagesDir, fmt.Sprintf("page%03d.html", i)), page, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stdout, stderr, code := runCLI(t, "trace", "--glob", filepath.Join(pagesDir, "*.html"), "--package", siteDir, "--dedupe")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+
+	var batch struct {
+		Maps  map[string]map[string]any `json:"maps"`
+		Pages []struct {
+			File string `json:"file"`
+			Map  string `json:"map"`
+		} `json:"pages"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &batch); err != nil {
+		t.Fatalf("Failed to parse deduplicated output: %v\n%s", err, stdout)
+	}
+	if len(batch.Maps) != 1 {
+		t.Fatalf("Expected 1 map definition, got %d", len(batch.Maps))
+	}
+	if len(batch.Pages) != 100 {
+		t.Fatalf("Expected 100 page references, got %d", len(batch.Pages))
+	}
+	for _, p := range batch.Pages {
+		if _, ok := batch.Maps[p.Map]; !ok {
+			t.Errorf("%s references unknown map %q", p.File, p.Map)
+		}
+	}
+	if !strings.HasSuffix(batch.Pages[0].File, "page000.html") {
+		t.Errorf("Expected pages sorted by file, got %s first", batch.Pages[0].File)
+	}
+}
+
+func TestTraceDedupeWithMerge(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "trace", "batch")
+
+	_, stderr, code := runCLI(t, "trace", "--glob", filepath.Join(fixtureDir, "*.html"), "--package", fixtureDir, "--dedupe", "--merge")
+	if code == 0 {
+		t.Fatal("Expected non-zero exit code for --dedupe with --merge")
+	}
+	if !strings.Contains(stderr, "--dedupe cannot be combined") {
+		t.Errorf("Expected incompatible flags error, got: %s", stderr)
+	}
+}
+
+func TestTraceBatchJobs(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "trace", "batch")
+	file1 := filepath.Join(fixtureDir, "page1.html")
+	file2 := filepath.Join(fixtureDir, "page2.html")
+
+	// Test with explicit jobs flag
+	stdout, stderr, code := runCLI(t, "trace", file1, file2, "--package", fixtureDir, "-j", "2")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 NDJSON lines, got %d", len(lines))
+	}
+}
+
+func TestTraceBatchHTMLFormatError(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "trace", "batch")
+	file1 := filepath.Join(fixtureDir, "page1.html")
+	file2 := filepath.Join(fixtureDir, "page2.html")
+
+	// HTML format should fail for batch mode
+	_, stderr, code := runCLI(t, "trace", file1, file2, "--package", fixtureDir, "--format", "html")
+	if code == 0 {
+		t.Error("Expected non-zero exit code for html format in batch mode")
+	}
+
+	if !strings.Contains(stderr, "not supported for batch mode") {
+		t.Errorf("Expected 'not supported for batch mode' error, got: %s", stderr)
+	}
+}
+
+// TestTraceDeepImportNotInExports verifies that traced bare specifiers that
+// are deep imports (not listed in package exports) are still resolved.
+// This is a regression test for https://github.com/bennypowers/mappa/issues/15
+func TestTraceDeepImportNotInExports(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "trace", "unresolvable")
+	file := filepath.Join(fixtureDir, "page.html")
+
+	stdout, stderr, code := runCLI(t, "trace", file, "--package", fixtureDir)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+
+	// Parse output
+	var result map[string]any
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v\nstdout: %s", err, stdout)
+	}
+
+	// The traced specifier @example/core/button/button.js should be in imports
+	// even though it's not in the package's exports field
+	imports, ok := result["imports"].(map[string]any)
+	if !ok {
+		t.Fatalf("Expected 'imports' key in output, got keys: %v", result)
+	}
+
+	if imports["@example/core/button/button.js"] == nil {
+		t.Errorf("Expected '@example/core/button/button.js' in imports, got: %v", imports)
+	}
+}
+
+// TestTraceStrict verifies that --strict fails when bare specifiers cannot be
+// resolved, and reports each of them.
+func TestTraceStrict(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "trace", "strict")
+	okFile := filepath.Join(fixtureDir, "ok.html")
+	typoFile := filepath.Join(fixtureDir, "typo.html")
+
+	t.Run("resolvable", func(t *testing.T) {
+		_, stderr, code := runCLI(t, "trace", okFile, "--package", fixtureDir, "--strict")
+		if code != 0 {
+			t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+		}
+	})
+
+	t.Run("unresolvable", func(t *testing.T) {
+		_, stderr, code := runCLI(t, "trace", typoFile, "--package", fixtureDir, "--strict")
+		if code == 0 {
+			t.Fatal("Expected non-zero exit code")
+		}
+		for _, want := range []string{
+			"lit/decoratorz.js",
+			"legacy/utilz.js",
+			"package missing-pkg is not installed",
+		} {
+			if !strings.Contains(stderr, want) {
+				t.Errorf("Expected stderr to contain %q, got: %s", want, stderr)
+			}
+		}
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		_, stderr, code := runCLI(t, "trace", okFile, typoFile, "--package", fixtureDir, "--strict")
+		if code == 0 {
+			t.Fatal("Expected non-zero exit code")
+		}
+		if !strings.Contains(stderr, "1 of 2 files failed to trace") {
+			t.Errorf("Expected failure summary in stderr, got: %s", stderr)
+		}
+	})
+
+	t.Run("without strict", func(t *testing.T) {
+		_, stderr, code := runCLI(t, "trace", typoFile, "--package", fixtureDir)
+		if code != 0 {
+			t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+		}
+	})
+
+	t.Run("conflicting flags", func(t *testing.T) {
+		_, stderr, code := runCLI(t, "trace", okFile, "--package", fixtureDir, "--strict", "--ignore-errors")
+		if code == 0 {
+			t.Fatal("Expected non-zero exit code")
+		}
+		if !strings.Contains(stderr, "--strict cannot be combined") {
+			t.Errorf("Expected flag conflict error, got: %s", stderr)
+		}
+	})
+}
+
+func TestTraceStats(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "trace", "strict")
+	okFile := filepath.Join(fixtureDir, "ok.html")
+	typoFile := filepath.Join(fixtureDir, "typo.html")
+
+	t.Run("single file", func(t *testing.T) {
+		stdout, stderr, code := runCLI(t, "trace", okFile, "--package", fixtureDir, "--stats")
+		if code != 0 {
+			t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+		}
+		var result map[string]any
+		if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+			t.Fatalf("Failed to parse JSON output: %v\nstdout: %s", err, stdout)
+		}
+		if !strings.Contains(stderr, "imports: ") || !strings.Contains(stderr, "gzipped: ") {
+			t.Errorf("Expected stats in stderr, got: %s", stderr)
+		}
+	})
+
+	t.Run("merged", func(t *testing.T) {
+		_, stderr, code := runCLI(t, "trace", okFile, typoFile, "--package", fixtureDir, "--merge", "--stats")
+		if code != 0 {
+			t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+		}
+		if !strings.Contains(stderr, "gzipped: ") {
+			t.Errorf("Expected stats in stderr, got: %s", stderr)
+		}
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		_, stderr, code := runCLI(t, "trace", okFile, typoFile, "--package", fixtureDir, "--stats")
+		if code == 0 {
+			t.Fatal("Expected non-zero exit code")
+		}
+		if !strings.Contains(stderr, "--stats needs a single import map") {
+			t.Errorf("Expected --stats error, got: %s", stderr)
+		}
+	})
+}
+
+// TestTraceBatchDeepImportNotInExports verifies that batch mode correctly resolves
 // traced bare specifiers that are deep imports (not listed in package exports).
 // This is a regression test for https://github.com/bennypowers/mappa/issues/20
 func TestTraceBatchDeepImportNotInExports(t *testing.T) {
@@ -515,55 +1568,436 @@ func TestTraceTransitiveDependencies(t *testing.T) {
 	}
 }
 
-// TestTraceTransitiveDependenciesSpecifiers verifies that the specifiers format
-// includes all transitive bare specifiers.
-func TestTraceTransitiveDependenciesSpecifiers(t *testing.T) {
-	fixtureDir := filepath.Join("testdata", "trace", "transitive")
-	file := filepath.Join(fixtureDir, "index.html")
+// TestTraceFlattenScopes verifies that --flatten-scopes hoists transitive
+// scoped entries into top-level imports and emits no scopes.
+func TestTraceFlattenScopes(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "trace", "transitive")
+	file := filepath.Join(fixtureDir, "index.html")
+	goldenFile := filepath.Join(fixtureDir, "expected-flatten-scopes.json")
+
+	stdout, stderr, code := runCLI(t, "trace", file, "--package", fixtureDir, "--flatten-scopes")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+
+	var result map[string]any
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v\nstdout: %s", err, stdout)
+	}
+	if _, hasScopes := result["scopes"]; hasScopes {
+		t.Errorf("Expected no scopes with --flatten-scopes, got: %v", result["scopes"])
+	}
+
+	compareOrUpdateGolden(t, goldenFile, stdout)
+}
+
+// TestTraceNoScopes verifies that --no-scopes drops scopes without hoisting
+// their entries, warning about the specifiers only scopes mapped.
+func TestTraceNoScopes(t *testing.T) {
+	t.Run("single file", func(t *testing.T) {
+		fixtureDir := filepath.Join("testdata", "trace", "transitive")
+		file := filepath.Join(fixtureDir, "index.html")
+
+		stdout, stderr, code := runCLI(t, "trace", file, "--package", fixtureDir, "--no-scopes")
+		if code != 0 {
+			t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+		}
+		if !strings.Contains(stderr, "unresolvable: lit/html.js\n") {
+			t.Errorf("Expected warning about lit/html.js, got: %s", stderr)
+		}
+
+		compareOrUpdateGolden(t, filepath.Join(fixtureDir, "expected-no-scopes.json"), stdout)
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		fixtureDir := filepath.Join("testdata", "trace", "batch")
+		file1 := filepath.Join(fixtureDir, "page1.html")
+		file2 := filepath.Join(fixtureDir, "page2.html")
+
+		stdout, stderr, code := runCLI(t, "trace", file1, file2, "--package", fixtureDir, "--no-scopes")
+		if code != 0 {
+			t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+		}
+		for line := range strings.SplitSeq(strings.TrimSpace(stdout), "\n") {
+			var result map[string]any
+			if err := json.Unmarshal([]byte(line), &result); err != nil {
+				t.Fatalf("Failed to parse NDJSON line: %v\nline: %s", err, line)
+			}
+			if _, hasScopes := result["scopes"]; hasScopes {
+				t.Errorf("Expected no scopes with --no-scopes, got: %s", line)
+			}
+		}
+		if !strings.Contains(stderr, "page1.html: --no-scopes") || !strings.Contains(stderr, "@lit/reactive-element") {
+			t.Errorf("Expected per-file warning about @lit/reactive-element, got: %s", stderr)
+		}
+	})
+
+	t.Run("with flatten-scopes", func(t *testing.T) {
+		fixtureDir := filepath.Join("testdata", "trace", "transitive")
+		file := filepath.Join(fixtureDir, "index.html")
+
+		_, stderr, code := runCLI(t, "trace", file, "--package", fixtureDir, "--no-scopes", "--flatten-scopes")
+		if code == 0 {
+			t.Fatal("Expected non-zero exit code for --no-scopes with --flatten-scopes")
+		}
+		if !strings.Contains(stderr, "--no-scopes cannot be combined") {
+			t.Errorf("Expected conflicting flags error, got: %s", stderr)
+		}
+	})
+}
+
+// TestTraceConditionsMatrix verifies that --conditions-matrix emits one
+// NDJSON line per page and variant, each resolved under its own conditions.
+func TestTraceConditionsMatrix(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "trace", "conditions-matrix")
+	file := filepath.Join(fixtureDir, "index.html")
+
+	stdout, stderr, code := runCLI(t, "trace", file, "--package", fixtureDir,
+		"--conditions-matrix", "dev=development,default prod=production,default")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 NDJSON lines, got %d: %s", len(lines), stdout)
+	}
+
+	got := make(map[string]string)
+	for i, line := range lines {
+		var result struct {
+			Variant string            `json:"variant"`
+			Imports map[string]string `json:"imports"`
+		}
+		if err := json.Unmarshal([]byte(line), &result); err != nil {
+			t.Fatalf("Failed to parse NDJSON line %d: %v\nline: %s", i, err, line)
+		}
+		got[result.Variant] = result.Imports["lib"]
+	}
+
+	expected := map[string]string{
+		"dev":  "/node_modules/lib/dev.js",
+		"prod": "/node_modules/lib/prod.js",
+	}
+	for variant, url := range expected {
+		if got[variant] != url {
+			t.Errorf("Variant %s: expected lib -> %s, got %q", variant, url, got[variant])
+		}
+	}
+}
+
+// TestTraceConditionsMatrixConflict verifies that --conditions-matrix
+// rejects a separate --conditions list.
+func TestTraceConditionsMatrixConflict(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "trace", "conditions-matrix")
+	file := filepath.Join(fixtureDir, "index.html")
+
+	_, stderr, code := runCLI(t, "trace", file, "--package", fixtureDir,
+		"--conditions-matrix", "dev=development", "--conditions", "production")
+	if code == 0 {
+		t.Fatal("Expected non-zero exit code")
+	}
+	if !strings.Contains(stderr, "--conditions-matrix") {
+		t.Errorf("Expected conflict error, got: %s", stderr)
+	}
+}
+
+// TestTraceAssumeInstalled verifies that --assume-installed resolves traced
+// specifiers from declared package.json versions when node_modules is absent.
+func TestTraceAssumeInstalled(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "trace", "assume-installed")
+	file := filepath.Join(fixtureDir, "index.html")
+	goldenFile := filepath.Join(fixtureDir, "expected.json")
+
+	stdout, stderr, code := runCLI(t, "trace", file, "--package", fixtureDir,
+		"--assume-installed", "--template", "https://esm.sh/{package}@{version}/{path}")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+
+	compareOrUpdateGolden(t, goldenFile, stdout)
+}
+
+// TestTraceTransitiveDependenciesSpecifiers verifies that the specifiers format
+// includes all transitive bare specifiers.
+func TestTraceTransitiveDependenciesSpecifiers(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "trace", "transitive")
+	file := filepath.Join(fixtureDir, "index.html")
+
+	stdout, stderr, code := runCLI(t, "trace", file, "--package", fixtureDir, "-f", "specifiers")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+
+	// Parse output
+	var result struct {
+		BareSpecifiers []string `json:"bare_specifiers"`
+		Packages       []string `json:"packages"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v\nstdout: %s", err, stdout)
+	}
+
+	// Should have transitive specifiers
+	expectedSpecifiers := []string{
+		"@example/button/button.js",
+		"lit",
+		"lit/decorators.js",
+	}
+
+	specSet := make(map[string]bool)
+	for _, s := range result.BareSpecifiers {
+		specSet[s] = true
+	}
+
+	for _, spec := range expectedSpecifiers {
+		if !specSet[spec] {
+			t.Errorf("Expected %q in bare_specifiers, got: %v", spec, result.BareSpecifiers)
+		}
+	}
+
+	// Should have both packages
+	pkgSet := make(map[string]bool)
+	for _, p := range result.Packages {
+		pkgSet[p] = true
+	}
+
+	if !pkgSet["@example/button"] {
+		t.Errorf("Expected '@example/button' in packages, got: %v", result.Packages)
+	}
+	if !pkgSet["lit"] {
+		t.Errorf("Expected 'lit' in packages, got: %v", result.Packages)
+	}
+}
+
+// TestGraph verifies the graph command's DOT and JSON output against golden files.
+func TestGraph(t *testing.T) {
+	tests := []struct {
+		name       string
+		fixtureDir string
+		format     string
+		golden     string
+	}{
+		{"dot", filepath.Join("testdata", "resolve", "with-scopes"), "dot", "expected-graph.dot"},
+		{"json", filepath.Join("testdata", "resolve", "with-scopes"), "json", "expected-graph.json"},
+		{"workspace", filepath.Join("testdata", "workspace"), "dot", "expected-graph.dot"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr, code := runCLI(t, "graph", "--package", tt.fixtureDir, "--format", tt.format)
+			if code != 0 {
+				t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+			}
+			compareOrUpdateGolden(t, filepath.Join(tt.fixtureDir, tt.golden), stdout)
+		})
+	}
+}
+
+func TestGraphInvalidFormat(t *testing.T) {
+	_, stderr, code := runCLI(t, "graph", "--package", filepath.Join("testdata", "resolve", "with-scopes"), "--format", "html")
+	if code == 0 {
+		t.Fatal("Expected non-zero exit code")
+	}
+	if !strings.Contains(stderr, "dot") {
+		t.Errorf("Expected allowed formats in error, got: %s", stderr)
+	}
+}
+
+func TestDoctor(t *testing.T) {
+	stdout, stderr, code := runCLI(t, "doctor", "--package", filepath.Join("testdata", "doctor", "project"))
+	if code == 0 {
+		t.Fatalf("Expected non-zero exit code for missing dependencies\nstdout: %s", stdout)
+	}
+	for _, want := range []string{
+		"ok      lit: 3.0.0\n",
+		"error   missing-pkg: not installed in node_modules",
+		"error   broken: cannot read package.json",
+		"warning icon-set: no root export or main field",
+		"2 errors, 2 warnings",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected %q in output, got:\n%s", want, stdout)
+		}
+	}
+	if !strings.Contains(stderr, "found 2 problems") || strings.Contains(stderr, "Usage:") {
+		t.Errorf("Expected only the problem count on stderr, got: %s", stderr)
+	}
+}
+
+func TestDoctorHealthy(t *testing.T) {
+	stdout, stderr, code := runCLI(t, "doctor", "--package", filepath.Join("testdata", "resolve", "simple-pkg"), "--format", "json")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+	var diagnosis struct {
+		Checks []struct {
+			Severity string `json:"severity"`
+		} `json:"checks"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &diagnosis); err != nil {
+		t.Fatalf("Failed to parse output: %v\n%s", err, stdout)
+	}
+	for _, c := range diagnosis.Checks {
+		if c.Severity != "ok" {
+			t.Errorf("Expected every check to pass, got:\n%s", stdout)
+			break
+		}
+	}
+}
+
+func TestExplain(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "explain")
+
+	tests := []struct {
+		name      string
+		args      []string
+		wantError bool
+	}{
+		{name: "conditions", args: []string{"kit"}},
+		{name: "custom-conditions", args: []string{"kit", "--conditions", "!browser"}},
+		{name: "pattern", args: []string{"kit/button"}},
+		{name: "excluded", args: []string{"kit/internal/secret"}, wantError: true},
+		{name: "condition-mismatch", args: []string{"node-only"}, wantError: true},
+		{name: "not-installed", args: []string{"missing/index.js"}, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"explain", "--package", fixtureDir}, tt.args...)
+			stdout, stderr, code := runCLI(t, args...)
+			if tt.wantError && code == 0 {
+				t.Fatalf("Expected non-zero exit code\nstdout: %s", stdout)
+			}
+			if !tt.wantError && code != 0 {
+				t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+			}
+			if tt.wantError && strings.Contains(stderr, "Usage:") {
+				t.Errorf("Expected no usage text for a resolution failure, got: %s", stderr)
+			}
+			compareOrUpdateGolden(t, filepath.Join(fixtureDir, tt.name+".txt"), stdout)
+		})
+	}
+}
+
+func TestExplainJSONFormat(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "explain")
 
-	stdout, stderr, code := runCLI(t, "trace", file, "--package", fixtureDir, "-f", "specifiers")
+	stdout, stderr, code := runCLI(t, "explain", "kit/button", "--package", fixtureDir, "--format", "json")
 	if code != 0 {
 		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
 	}
 
-	// Parse output
-	var result struct {
-		BareSpecifiers []string `json:"bare_specifiers"`
-		Packages       []string `json:"packages"`
-	}
+	var result map[string]any
 	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
 		t.Fatalf("Failed to parse JSON output: %v\nstdout: %s", err, stdout)
 	}
+	if result["url"] != "/node_modules/kit/lib/button.js" {
+		t.Errorf("Expected url /node_modules/kit/lib/button.js, got %v", result["url"])
+	}
+	if _, ok := result["error"]; ok {
+		t.Errorf("Expected no error, got %v", result["error"])
+	}
+}
 
-	// Should have transitive specifiers
-	expectedSpecifiers := []string{
-		"@example/button/button.js",
-		"lit",
-		"lit/decorators.js",
+func TestSchema(t *testing.T) {
+	for _, name := range []string{"importmap", "trace-result"} {
+		t.Run(name, func(t *testing.T) {
+			stdout, stderr, code := runCLI(t, "schema", name)
+			if code != 0 {
+				t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+			}
+			compareOrUpdateGolden(t, filepath.Join("testdata", "schema", name+".json"), stdout)
+		})
 	}
 
-	specSet := make(map[string]bool)
-	for _, s := range result.BareSpecifiers {
-		specSet[s] = true
+	t.Run("unknown schema", func(t *testing.T) {
+		_, stderr, code := runCLI(t, "schema", "lockfile")
+		if code == 0 {
+			t.Fatal("Expected non-zero exit code for unknown schema")
+		}
+		if !strings.Contains(stderr, `invalid argument "lockfile"`) {
+			t.Errorf("Expected invalid argument error, got: %s", stderr)
+		}
+	})
+}
+
+// TestSchemaRoundTrip checks real output against the schemas, so that they
+// stay in sync with the structs that produce it.
+func TestSchemaRoundTrip(t *testing.T) {
+	validate := func(t *testing.T, name, output string) {
+		t.Helper()
+		var data any
+		if err := json.Unmarshal([]byte(output), &data); err != nil {
+			t.Fatalf("Failed to parse output: %v\n%s", err, output)
+		}
+		if err := schema.Schemas[name]().Validate(data); err != nil {
+			t.Errorf("Output doesn't match the %s schema: %v\n%s", name, err, output)
+		}
 	}
 
-	for _, spec := range expectedSpecifiers {
-		if !specSet[spec] {
-			t.Errorf("Expected %q in bare_specifiers, got: %v", spec, result.BareSpecifiers)
+	t.Run("importmap", func(t *testing.T) {
+		fixtureDir := filepath.Join("testdata", "trace", "batch")
+		stdout, stderr, code := runCLI(t, "generate", "--package", fixtureDir)
+		if code != 0 {
+			t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+		}
+		validate(t, "importmap", stdout)
+
+		stdout, stderr, code = runCLI(t, "generate", "--package", fixtureDir, "--warnings")
+		if code != 0 {
+			t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+		}
+		validate(t, "importmap", stdout)
+	})
+
+	t.Run("trace-result", func(t *testing.T) {
+		fixtureDir := filepath.Join("testdata", "trace", "batch")
+		stdout, stderr, code := runCLI(t, "trace",
+			filepath.Join(fixtureDir, "page1.html"),
+			filepath.Join(fixtureDir, "missing.html"),
+			"--package", fixtureDir)
+		if code != 0 {
+			t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
 		}
+		lines := strings.Split(strings.TrimSpace(stdout), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("Expected 2 NDJSON lines, got %d: %s", len(lines), stdout)
+		}
+		for _, line := range lines {
+			validate(t, "trace-result", line)
+		}
+	})
+}
+
+func TestPrune(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "prune", "basic")
+	mapFile := filepath.Join(fixtureDir, "importmap.json")
+	goldenFile := filepath.Join(fixtureDir, "expected.json")
+	glob := filepath.Join(fixtureDir, "pages", "*.html")
+
+	stdout, stderr, code := runCLI(t, "prune", mapFile, "--glob", glob, "--package", fixtureDir)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
 	}
 
-	// Should have both packages
-	pkgSet := make(map[string]bool)
-	for _, p := range result.Packages {
-		pkgSet[p] = true
+	if !strings.Contains(stderr, "Removed 3 of 6 entries") {
+		t.Errorf("Expected removal report in stderr, got: %s", stderr)
 	}
 
-	if !pkgSet["@example/button"] {
-		t.Errorf("Expected '@example/button' in packages, got: %v", result.Packages)
+	compareOrUpdateGolden(t, goldenFile, stdout)
+}
+
+func TestPruneMissingGlob(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "prune", "basic")
+	mapFile := filepath.Join(fixtureDir, "importmap.json")
+
+	_, stderr, code := runCLI(t, "prune", mapFile)
+	if code == 0 {
+		t.Error("Expected non-zero exit code when --glob is missing")
 	}
-	if !pkgSet["lit"] {
-		t.Errorf("Expected 'lit' in packages, got: %v", result.Packages)
+	if !strings.Contains(stderr, "glob") {
+		t.Errorf("Expected error mentioning glob, got: %s", stderr)
 	}
 }
 
@@ -651,6 +2085,39 @@ func TestGenerateInvalidTemplate(t *testing.T) {
 	}
 }
 
+func TestGenerateTemplateWithoutPath(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "resolve", "simple-pkg")
+
+	_, stderr, code := runCLI(t, "generate", "--package", fixtureDir, "--template", "/assets/{package}.js")
+	if code == 0 {
+		t.Error("Expected non-zero exit code for template without {path}")
+	}
+
+	if !strings.Contains(stderr, "has no {path} variable") {
+		t.Errorf("Expected missing {path} error, got: %s", stderr)
+	}
+}
+
+func TestGenerateNoScopes(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "trace", "batch")
+
+	stdout, stderr, code := runCLI(t, "generate", "--package", fixtureDir, "--no-scopes")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+
+	var result map[string]any
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v\nstdout: %s", err, stdout)
+	}
+	if _, hasScopes := result["scopes"]; hasScopes {
+		t.Errorf("Expected no scopes with --no-scopes, got: %v", result["scopes"])
+	}
+	if !strings.Contains(stderr, "Warning: --no-scopes leaves specifiers that only scopes mapped unresolvable: @lit/reactive-element") {
+		t.Errorf("Expected warning about @lit/reactive-element, got: %s", stderr)
+	}
+}
+
 func TestGenerateEmptyProject(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -802,6 +2269,236 @@ func TestInjectInsertNew(t *testing.T) {
 	}
 }
 
+func TestInjectPreload(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "inject", "no-importmap")
+	tmpDir := t.TempDir()
+
+	copyFile(t, filepath.Join(fixtureDir, "index.html"), filepath.Join(tmpDir, "index.html"))
+	copyFile(t, filepath.Join(fixtureDir, "package.json"), filepath.Join(tmpDir, "package.json"))
+	copyDir(t, filepath.Join(fixtureDir, "node_modules"), filepath.Join(tmpDir, "node_modules"))
+
+	globPattern := filepath.Join(tmpDir, "*.html")
+
+	// Injecting twice must not duplicate the preload hints
+	for range 2 {
+		if _, stderr, code := runCLI(t, "inject", "--glob", globPattern, "--package", tmpDir, "--preload"); code != 0 {
+			t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+		}
+	}
+
+	content, err := os.ReadFile(filepath.Join(tmpDir, "index.html"))
+	if err != nil {
+		t.Fatalf("Failed to read modified file: %v", err)
+	}
+
+	link := `</script>
+  <link rel="modulepreload" href="/node_modules/lit/index.js">`
+	if !strings.Contains(string(content), link) {
+		t.Errorf("Expected preload link after the import map, got:\n%s", content)
+	}
+	if n := strings.Count(string(content), "modulepreload"); n != 1 {
+		t.Errorf("Expected one preload link, got %d:\n%s", n, content)
+	}
+}
+
+func TestInjectMarker(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "inject", "marker")
+	tmpDir := t.TempDir()
+
+	copyFile(t, filepath.Join(fixtureDir, "index.html"), filepath.Join(tmpDir, "index.html"))
+	copyFile(t, filepath.Join(fixtureDir, "missing.html"), filepath.Join(tmpDir, "missing.html"))
+	copyFile(t, filepath.Join(fixtureDir, "package.json"), filepath.Join(tmpDir, "package.json"))
+	copyDir(t, filepath.Join(fixtureDir, "node_modules"), filepath.Join(tmpDir, "node_modules"))
+
+	globPattern := filepath.Join(tmpDir, "*.html")
+
+	_, stderr, code := runCLI(t, "inject", "--glob", globPattern, "--package", tmpDir, "--marker", "<!-- importmap -->")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+
+	// The marker is replaced by the import map tag
+	content, err := os.ReadFile(filepath.Join(tmpDir, "index.html"))
+	if err != nil {
+		t.Fatalf("Failed to read modified file: %v", err)
+	}
+	compareOrUpdateGolden(t, filepath.Join(fixtureDir, "expected.html"), string(content))
+
+	// A page without the marker falls back to the default insertion point
+	if !strings.Contains(stderr, `missing.html: marker "<!-- importmap -->" not found`) {
+		t.Errorf("Expected missing marker warning, got stderr: %s", stderr)
+	}
+	content, err = os.ReadFile(filepath.Join(tmpDir, "missing.html"))
+	if err != nil {
+		t.Fatalf("Failed to read modified file: %v", err)
+	}
+	if !strings.Contains(string(content), "</title>\n  <script type=\"importmap\">") {
+		t.Errorf("Expected import map before the first script in <head>, got:\n%s", content)
+	}
+}
+
+func TestInjectPreferExisting(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "inject", "prefer-existing")
+	tmpDir := t.TempDir()
+
+	copyFile(t, filepath.Join(fixtureDir, "index.html"), filepath.Join(tmpDir, "index.html"))
+	copyFile(t, filepath.Join(fixtureDir, "package.json"), filepath.Join(tmpDir, "package.json"))
+	copyDir(t, filepath.Join(fixtureDir, "node_modules"), filepath.Join(tmpDir, "node_modules"))
+
+	globPattern := filepath.Join(tmpDir, "*.html")
+
+	// Injecting twice keeps the hand-tuned scope entry and adds the traced one
+	for range 2 {
+		if _, stderr, code := runCLI(t, "inject", "--glob", globPattern, "--package", tmpDir, "--prefer-existing"); code != 0 {
+			t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+		}
+	}
+
+	content, err := os.ReadFile(filepath.Join(tmpDir, "index.html"))
+	if err != nil {
+		t.Fatalf("Failed to read modified file: %v", err)
+	}
+	compareOrUpdateGolden(t, filepath.Join(fixtureDir, "expected.html"), string(content))
+
+	// Without the flag, the traced scope entry wins
+	if _, stderr, code := runCLI(t, "inject", "--glob", globPattern, "--package", tmpDir); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+	content, err = os.ReadFile(filepath.Join(tmpDir, "index.html"))
+	if err != nil {
+		t.Fatalf("Failed to read modified file: %v", err)
+	}
+	if strings.Contains(string(content), "lit-html-patched") {
+		t.Errorf("Expected traced scope entry to replace the existing one, got:\n%s", content)
+	}
+}
+
+func TestInjectShim(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "inject", "no-importmap")
+	tmpDir := t.TempDir()
+
+	copyFile(t, filepath.Join(fixtureDir, "index.html"), filepath.Join(tmpDir, "index.html"))
+	copyFile(t, filepath.Join(fixtureDir, "package.json"), filepath.Join(tmpDir, "package.json"))
+	copyDir(t, filepath.Join(fixtureDir, "node_modules"), filepath.Join(tmpDir, "node_modules"))
+
+	globPattern := filepath.Join(tmpDir, "*.html")
+
+	_, stderr, code := runCLI(t, "inject", "--glob", globPattern, "--package", tmpDir, "--shim")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tmpDir, "index.html"))
+	if err != nil {
+		t.Fatalf("Failed to read modified file: %v", err)
+	}
+
+	if !strings.Contains(string(content), `<script type="importmap-shim">`) {
+		t.Errorf("Expected importmap-shim tag to be inserted, got:\n%s", content)
+	}
+
+	// A second run updates the shim tag instead of inserting another
+	stdout, stderr, code := runCLI(t, "inject", "--glob", globPattern, "--package", tmpDir, "--shim")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+	if strings.Contains(stdout, "inserted into") {
+		t.Errorf("Expected existing importmap-shim tag to be updated, got: %s", stdout)
+	}
+
+	content, err = os.ReadFile(filepath.Join(tmpDir, "index.html"))
+	if err != nil {
+		t.Fatalf("Failed to read modified file: %v", err)
+	}
+	if n := strings.Count(string(content), "importmap-shim"); n != 1 {
+		t.Errorf("Expected exactly one importmap-shim tag, got %d", n)
+	}
+}
+
+func TestInjectRemove(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "inject", "remove")
+	tmpDir := t.TempDir()
+
+	copyFile(t, filepath.Join(fixtureDir, "index.html"), filepath.Join(tmpDir, "index.html"))
+	copyFile(t, filepath.Join(fixtureDir, "no-importmap.html"), filepath.Join(tmpDir, "no-importmap.html"))
+
+	globPattern := filepath.Join(tmpDir, "*.html")
+
+	// Dry run reports the file without modifying it
+	stdout, stderr, code := runCLI(t, "inject", "--glob", globPattern, "--package", tmpDir, "--remove", "--dry-run")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+	if !strings.Contains(stdout, "would remove from "+filepath.Join(tmpDir, "index.html")) {
+		t.Errorf("Expected index.html to be reported, got: %s", stdout)
+	}
+	if strings.Contains(stdout, "no-importmap.html") {
+		t.Errorf("Expected file without an import map to be unchanged, got: %s", stdout)
+	}
+	original, _ := os.ReadFile(filepath.Join(fixtureDir, "index.html"))
+	content, _ := os.ReadFile(filepath.Join(tmpDir, "index.html"))
+	if string(content) != string(original) {
+		t.Error("Expected dry run not to modify the file")
+	}
+
+	stdout, stderr, code = runCLI(t, "inject", "--glob", globPattern, "--package", tmpDir, "--remove")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+	if !strings.Contains(stdout, "removed from 1 files, 1 unchanged") {
+		t.Errorf("Expected removal summary, got: %s", stdout)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tmpDir, "index.html"))
+	if err != nil {
+		t.Fatalf("Failed to read modified file: %v", err)
+	}
+	compareOrUpdateGolden(t, filepath.Join(fixtureDir, "expected.html"), string(content))
+
+	noMap, _ := os.ReadFile(filepath.Join(fixtureDir, "no-importmap.html"))
+	content, _ = os.ReadFile(filepath.Join(tmpDir, "no-importmap.html"))
+	if string(content) != string(noMap) {
+		t.Error("Expected file without an import map to be unchanged")
+	}
+}
+
+func TestInjectMultipleImportMaps(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "inject", "multiple")
+	tmpDir := t.TempDir()
+	copyFile(t, filepath.Join(fixtureDir, "index.html"), filepath.Join(tmpDir, "index.html"))
+	copyFile(t, filepath.Join(fixtureDir, "package.json"), filepath.Join(tmpDir, "package.json"))
+	copyDir(t, filepath.Join(fixtureDir, "node_modules"), filepath.Join(tmpDir, "node_modules"))
+	globPattern := filepath.Join(tmpDir, "*.html")
+
+	// Strict mode fails the page and leaves it untouched
+	_, stderr, code := runCLI(t, "inject", "--glob", globPattern, "--package", tmpDir, "--strict", "--fail-on-error")
+	if code == 0 {
+		t.Error("Expected non-zero exit code with --strict")
+	}
+	if !strings.Contains(stderr, "found 2 import map tags (lines 5, 12)") {
+		t.Errorf("Expected multiple tags error, got: %s", stderr)
+	}
+	original, _ := os.ReadFile(filepath.Join(fixtureDir, "index.html"))
+	content, _ := os.ReadFile(filepath.Join(tmpDir, "index.html"))
+	if string(content) != string(original) {
+		t.Error("Expected strict mode not to modify the file")
+	}
+
+	// Otherwise the maps are consolidated into the first tag, which wins conflicts
+	_, stderr, code = runCLI(t, "inject", "--glob", globPattern, "--package", tmpDir)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+	}
+	if !strings.Contains(stderr, "consolidated them into the first") {
+		t.Errorf("Expected consolidation warning, got: %s", stderr)
+	}
+	content, err := os.ReadFile(filepath.Join(tmpDir, "index.html"))
+	if err != nil {
+		t.Fatalf("Failed to read modified file: %v", err)
+	}
+	compareOrUpdateGolden(t, filepath.Join(fixtureDir, "expected.html"), string(content))
+}
+
 func TestInjectJSONFormat(t *testing.T) {
 	fixtureDir := filepath.Join("testdata", "inject", "with-existing")
 	globPattern := filepath.Join(fixtureDir, "*.html")
@@ -825,6 +2522,78 @@ func TestInjectJSONFormat(t *testing.T) {
 	}
 }
 
+func TestInjectIntegrityWithoutCrossOrigin(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "inject", "integrity")
+
+	tests := []struct {
+		file        string
+		wantWarning bool
+	}{
+		{"index.html", true},
+		{"crossorigin.html", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			globPattern := filepath.Join(fixtureDir, tt.file)
+			_, stderr, code := runCLI(t, "inject", "--glob", globPattern, "--package", fixtureDir, "--dry-run")
+			if code != 0 {
+				t.Fatalf("Expected exit code 0, got %d\nstderr: %s", code, stderr)
+			}
+
+			warned := strings.Contains(stderr, "integrity entries but module scripts lack crossorigin: ./app.js")
+			if warned != tt.wantWarning {
+				t.Errorf("Expected crossorigin warning: %v, got stderr: %s", tt.wantWarning, stderr)
+			}
+		})
+	}
+}
+
+func TestInjectFailOnError(t *testing.T) {
+	fixtureDir := filepath.Join("testdata", "inject", "malformed")
+	globPattern := filepath.Join(fixtureDir, "*.html")
+
+	// Without --fail-on-error, a partial failure still exits zero
+	_, stderr, code := runCLI(t, "inject", "--glob", globPattern, "--package", fixtureDir, "--dry-run")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0 without --fail-on-error, got %d\nstderr: %s", code, stderr)
+	}
+	if !strings.Contains(stderr, "bad.html") {
+		t.Errorf("Expected error for bad.html on stderr, got: %s", stderr)
+	}
+
+	// With --fail-on-error, any failure exits non-zero
+	stdout, _, code := runCLI(t, "inject", "--glob", globPattern, "--package", fixtureDir, "--dry-run", "--fail-on-error", "--format", "json")
+	if code == 0 {
+		t.Error("Expected non-zero exit code with --fail-on-error")
+	}
+
+	// JSON output has one Result line per file, followed by stats
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 2 result lines and 1 stats line, got %d:\n%s", len(lines), stdout)
+	}
+	var errored int
+	for _, line := range lines[:2] {
+		var result struct {
+			File  string `json:"file"`
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(line), &result); err != nil {
+			t.Fatalf("Failed to parse JSON line: %v\nline: %s", err, line)
+		}
+		if result.Error != "" {
+			errored++
+			if filepath.Base(result.File) != "bad.html" {
+				t.Errorf("Expected error only for bad.html, got %s", result.File)
+			}
+		}
+	}
+	if errored != 1 {
+		t.Errorf("Expected 1 errored result, got %d", errored)
+	}
+}
+
 // Helper functions for copying files/dirs
 func copyFile(t *testing.T, src, dst string) {
 	t.Helper()
@@ -16,7 +16,11 @@ along with this program. If not, see <http://www.gnu.org/licenses/>.
 */
 package packagejson
 
-import "sync"
+import (
+	"crypto/sha256"
+	"sync"
+	"time"
+)
 
 // Cache provides a caching interface for parsed package.json files.
 // This allows callers to reuse parsed data across multiple resolution calls,
@@ -37,6 +41,25 @@ type Cache interface {
 	GetOrLoad(path string, loader func() (*PackageJSON, error)) (*PackageJSON, error)
 }
 
+// ChangeAwareCache is a Cache that remembers the modification time and content
+// hash of the files it parsed, so that a file whose modification time changed
+// but whose content didn't is not parsed again. MemoryCache implements it.
+type ChangeAwareCache interface {
+	Cache
+
+	// GetOrLoadIfChanged returns the cached package.json at path if mtime is
+	// the one recorded when it was parsed. Otherwise it reads the file with
+	// read and parses it, unless its content hash matches the recorded one.
+	// changed reports whether the file was parsed.
+	GetOrLoadIfChanged(path string, mtime time.Time, read func() ([]byte, error)) (pkg *PackageJSON, changed bool, err error)
+}
+
+// fileStamp identifies the version of a file a cached value was parsed from.
+type fileStamp struct {
+	mtime time.Time
+	hash  [sha256.Size]byte
+}
+
 // cacheEntry holds a cached value and coordinates concurrent loading.
 type cacheEntry struct {
 	pkg  *PackageJSON
@@ -48,13 +71,15 @@ type cacheEntry struct {
 type MemoryCache struct {
 	mu      sync.RWMutex
 	cache   map[string]*PackageJSON
-	loading sync.Map // map[string]*cacheEntry for in-flight loads
+	stamps  map[string]fileStamp // recorded by GetOrLoadIfChanged
+	loading sync.Map             // map[string]*cacheEntry for in-flight loads
 }
 
 // NewMemoryCache creates a new in-memory cache for package.json files.
 func NewMemoryCache() *MemoryCache {
 	return &MemoryCache{
-		cache: make(map[string]*PackageJSON),
+		cache:  make(map[string]*PackageJSON),
+		stamps: make(map[string]fileStamp),
 	}
 }
 
@@ -71,12 +96,14 @@ func (c *MemoryCache) Set(path string, pkg *PackageJSON) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.cache[path] = pkg
+	delete(c.stamps, path)
 }
 
 // Invalidate removes a cached entry and any in-flight loading state.
 func (c *MemoryCache) Invalidate(path string) {
 	c.mu.Lock()
 	delete(c.cache, path)
+	delete(c.stamps, path)
 	c.mu.Unlock()
 	c.loading.Delete(path)
 }
@@ -113,3 +140,46 @@ func (c *MemoryCache) GetOrLoad(path string, loader func() (*PackageJSON, error)
 
 	return entry.pkg, entry.err
 }
+
+// GetOrLoadIfChanged returns the cached package.json at path if mtime matches
+// the one recorded when it was parsed, without calling read. Otherwise it
+// reads the file and compares its SHA-256 hash with the recorded one: an
+// unchanged file only has its mtime updated, while new content is parsed and
+// replaces the cached value. changed reports whether the file was parsed.
+// Values loaded with GetOrLoad or Set have no recorded stamp, so the first
+// call for their path always parses.
+func (c *MemoryCache) GetOrLoadIfChanged(path string, mtime time.Time, read func() ([]byte, error)) (*PackageJSON, bool, error) {
+	c.mu.RLock()
+	cached, ok := c.cache[path]
+	stamp, stamped := c.stamps[path]
+	c.mu.RUnlock()
+	stamped = ok && stamped
+	if stamped && stamp.mtime.Equal(mtime) {
+		return cached, false, nil
+	}
+
+	data, err := read()
+	if err != nil {
+		return nil, false, err
+	}
+	hash := sha256.Sum256(data)
+	if stamped && stamp.hash == hash {
+		c.mu.Lock()
+		c.stamps[path] = fileStamp{mtime: mtime, hash: hash}
+		c.mu.Unlock()
+		return cached, false, nil
+	}
+
+	pkg, err := Parse(data)
+	if err != nil {
+		c.Invalidate(path)
+		return nil, true, err
+	}
+	c.mu.Lock()
+	c.cache[path] = pkg
+	c.stamps[path] = fileStamp{mtime: mtime, hash: hash}
+	c.mu.Unlock()
+	// Drop any finished GetOrLoad for the old content
+	c.loading.Delete(path)
+	return pkg, true, nil
+}
@@ -17,9 +17,11 @@ along with this program. If not, see <http://www.gnu.org/licenses/>.
 package packagejson_test
 
 import (
+	"errors"
 	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"bennypowers.dev/mappa/packagejson"
 )
@@ -212,3 +214,76 @@ func TestMemoryCacheInvalidateAllowsReload(t *testing.T) {
 		t.Errorf("Expected 2 loads after invalidate, got %d", loadCount.Load())
 	}
 }
+
+func TestMemoryCacheGetOrLoadIfChanged(t *testing.T) {
+	cache := packagejson.NewMemoryCache()
+	const path = "/pkg/package.json"
+	var reads int
+	content := `{"name": "test-pkg", "version": "1.0.0"}`
+	read := func() ([]byte, error) {
+		reads++
+		return []byte(content), nil
+	}
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	first, changed, err := cache.GetOrLoadIfChanged(path, t0, read)
+	if err != nil || !changed || first.Version != "1.0.0" {
+		t.Fatalf("First load = %v, %v, %v; want parsed 1.0.0", first, changed, err)
+	}
+
+	// Same mtime: the file isn't read
+	pkg, changed, err := cache.GetOrLoadIfChanged(path, t0, read)
+	if err != nil || changed || pkg != first || reads != 1 {
+		t.Errorf("Same mtime: changed=%v, same=%v, reads=%d, err=%v", changed, pkg == first, reads, err)
+	}
+
+	// Touched file: read and hashed, but not parsed again
+	pkg, changed, err = cache.GetOrLoadIfChanged(path, t0.Add(time.Second), read)
+	if err != nil || changed || pkg != first || reads != 2 {
+		t.Errorf("Touched file: changed=%v, same=%v, reads=%d, err=%v", changed, pkg == first, reads, err)
+	}
+	pkg, _, _ = cache.GetOrLoadIfChanged(path, t0.Add(time.Second), read)
+	if reads != 2 || pkg != first {
+		t.Errorf("Expected the touched mtime to be recorded, got %d reads", reads)
+	}
+
+	// Edited file: parsed again and cached
+	content = `{"name": "test-pkg", "version": "1.1.0"}`
+	pkg, changed, err = cache.GetOrLoadIfChanged(path, t0.Add(2*time.Second), read)
+	if err != nil || !changed || pkg.Version != "1.1.0" {
+		t.Fatalf("Edited file = %v, %v, %v; want parsed 1.1.0", pkg, changed, err)
+	}
+	if cached, _ := cache.Get(path); cached != pkg {
+		t.Error("Expected the edited package.json to be cached")
+	}
+
+	// Broken edit: the stale value is dropped
+	content = `{"name": `
+	if _, _, err := cache.GetOrLoadIfChanged(path, t0.Add(3*time.Second), read); err == nil {
+		t.Error("Expected a parse error")
+	}
+	if _, ok := cache.Get(path); ok {
+		t.Error("Expected the cache entry to be dropped after a parse error")
+	}
+}
+
+func TestMemoryCacheGetOrLoadIfChangedUnstamped(t *testing.T) {
+	cache := packagejson.NewMemoryCache()
+	const path = "/pkg/package.json"
+	cache.Set(path, &packagejson.PackageJSON{Name: "test-pkg", Version: "0.1.0"})
+
+	// A value without a recorded stamp is always parsed
+	pkg, changed, err := cache.GetOrLoadIfChanged(path, time.Now(), func() ([]byte, error) {
+		return []byte(`{"name": "test-pkg", "version": "1.0.0"}`), nil
+	})
+	if err != nil || !changed || pkg.Version != "1.0.0" {
+		t.Errorf("Unstamped entry = %v, %v, %v; want parsed 1.0.0", pkg, changed, err)
+	}
+
+	// Read errors are returned as-is
+	readErr := errors.New("permission denied")
+	cache.Invalidate(path)
+	if _, _, err := cache.GetOrLoadIfChanged(path, time.Now(), func() ([]byte, error) { return nil, readErr }); !errors.Is(err, readErr) {
+		t.Errorf("Expected read error, got %v", err)
+	}
+}
@@ -20,6 +20,9 @@ package packagejson
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"maps"
+	"slices"
 	"sort"
 	"strings"
 
@@ -38,11 +41,69 @@ var ErrNotExported = errors.New("not exported by package.json")
 // DefaultConditions is the default export condition priority for browser environments.
 var DefaultConditions = []string{"browser", "import", "default"}
 
+// defaultCondition is the export condition that matches in every environment.
+const defaultCondition = "default"
+
+// DevelopmentCondition is the export condition libraries use for development builds.
+const DevelopmentCondition = "development"
+
+// DevelopmentConditions returns the condition list with "development" prepended,
+// so that development export targets take priority. If conditions has no
+// positive entries, DefaultConditions is used as the base, keeping any negations.
+func DevelopmentConditions(conditions []string) []string {
+	if positive, _ := splitConditions(conditions); len(positive) == 0 {
+		conditions = append(slices.Clone(DefaultConditions), conditions...)
+	}
+	result := make([]string, 0, len(conditions)+1)
+	result = append(result, DevelopmentCondition)
+	for _, c := range conditions {
+		if c != DevelopmentCondition {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// NegatedConditionPrefix marks a condition as blocked, e.g. "!node".
+const NegatedConditionPrefix = "!"
+
 // ResolveOptions configures how conditional exports are resolved.
 type ResolveOptions struct {
 	// Conditions is the ordered list of conditions to try when resolving exports.
-	// If nil, defaults to DefaultConditions.
+	// Entries prefixed with "!" (e.g. "!node") are negated: a negated condition
+	// never matches, at any nesting depth, even if it also appears un-negated.
+	// If there are no positive entries, DefaultConditions is used, minus any
+	// negated conditions. If nil, defaults to DefaultConditions. As in Node.js,
+	// "default" always matches unless negated, and is tried after every other
+	// condition wherever it appears in the list.
 	Conditions []string
+
+	// steps, when set, collects a description of each resolution decision
+	// for ExplainExport.
+	steps *[]string
+}
+
+// note records a resolution step when opts is collecting them.
+func (opts *ResolveOptions) note(format string, args ...any) {
+	if opts != nil && opts.steps != nil {
+		*opts.steps = append(*opts.steps, fmt.Sprintf(format, args...))
+	}
+}
+
+// splitConditions separates a condition list into its positive entries,
+// in order, and the set of negated conditions.
+func splitConditions(conditions []string) (positive []string, blocked map[string]bool) {
+	for _, cond := range conditions {
+		if name, ok := strings.CutPrefix(cond, NegatedConditionPrefix); ok {
+			if blocked == nil {
+				blocked = make(map[string]bool)
+			}
+			blocked[name] = true
+			continue
+		}
+		positive = append(positive, cond)
+	}
+	return positive, blocked
 }
 
 // PackageJSON represents the subset of package.json relevant for import maps.
@@ -51,6 +112,8 @@ type PackageJSON struct {
 	Name string `json:"name"`
 	// Version is the package version (e.g., "1.0.0").
 	Version string `json:"version"`
+	// Type is the module format of .js files ("module" or "commonjs").
+	Type string `json:"type,omitempty"`
 	// Main is the legacy main entry point (e.g., "index.js").
 	Main string `json:"main,omitempty"`
 	// Module is the ESM entry point (e.g., "dist/index.mjs").
@@ -63,9 +126,110 @@ type PackageJSON struct {
 	Dependencies map[string]string `json:"dependencies,omitempty"`
 	// DevDependencies maps dev package names to version specifiers.
 	DevDependencies map[string]string `json:"devDependencies,omitempty"`
+	// PeerDependencies maps peer package names to version specifiers.
+	PeerDependencies map[string]string `json:"peerDependencies,omitempty"`
+	// PeerDependenciesMeta holds per-peer metadata, such as whether a peer is optional.
+	PeerDependenciesMeta map[string]PeerDependencyMeta `json:"peerDependenciesMeta,omitempty"`
+	// RawOverrides holds the raw JSON for npm's overrides field, which may nest.
+	// Use VersionOverrides() to extract flat overrides.
+	RawOverrides json.RawMessage `json:"overrides,omitempty"`
+	// Resolutions maps package names (or yarn glob paths) to forced versions.
+	Resolutions map[string]string `json:"resolutions,omitempty"`
 	// RawWorkspaces holds the raw JSON for the workspaces field.
 	// Use WorkspacePatterns() to extract the patterns.
 	RawWorkspaces json.RawMessage `json:"workspaces,omitempty"`
+	// Engines maps runtimes to the version ranges the package supports
+	// (e.g., "node": ">=18").
+	Engines Engines `json:"engines,omitempty"`
+}
+
+// Engines maps runtime names to supported version ranges.
+type Engines map[string]string
+
+// UnmarshalJSON implements json.Unmarshaler. Old packages sometimes declare
+// engines as an array of strings, which npm ignores; such values decode to
+// nil rather than failing the whole package.json.
+func (e *Engines) UnmarshalJSON(data []byte) error {
+	var engines map[string]any
+	if err := json.Unmarshal(data, &engines); err != nil {
+		*e = nil
+		return nil
+	}
+	*e = make(Engines, len(engines))
+	for name, value := range engines {
+		if versionRange, ok := value.(string); ok {
+			(*e)[name] = versionRange
+		}
+	}
+	return nil
+}
+
+// VersionOverrides returns the flat version overrides from npm's overrides and
+// yarn's resolutions fields, mapping package names to the version range that
+// replaces any declared range for them. Overrides win over resolutions.
+// Yarn resolutions are matched by name, so "**/foo" overrides foo. npm's
+// "$foo" references resolve to the root's own dependency range for foo, and an
+// object's "." key overrides the package itself. Overrides scoped to a parent
+// package (nested objects and "parent/foo" resolutions) are ignored.
+func (pkg *PackageJSON) VersionOverrides() map[string]string {
+	overrides := make(map[string]string)
+
+	for key, version := range pkg.Resolutions {
+		name := strings.TrimPrefix(key, "**/")
+		if name == parseOverrideName(name) {
+			overrides[name] = version
+		}
+	}
+
+	var raw map[string]any
+	if len(pkg.RawOverrides) > 0 && json.Unmarshal(pkg.RawOverrides, &raw) == nil {
+		for name, value := range raw {
+			var version string
+			switch v := value.(type) {
+			case string:
+				version = v
+			case map[string]any:
+				version, _ = v["."].(string)
+			}
+			if ref, ok := strings.CutPrefix(version, "$"); ok {
+				version = pkg.Dependencies[ref]
+			}
+			if version != "" {
+				overrides[name] = version
+			}
+		}
+	}
+
+	return overrides
+}
+
+// parseOverrideName returns the package name at the start of an override key,
+// e.g. "foo" for "foo/bar" and "@scope/foo" for "@scope/foo/bar".
+func parseOverrideName(key string) string {
+	parts := strings.SplitN(key, "/", 3)
+	if strings.HasPrefix(key, "@") && len(parts) > 1 {
+		return parts[0] + "/" + parts[1]
+	}
+	return parts[0]
+}
+
+// PeerDependencyMeta describes a single entry in peerDependenciesMeta.
+type PeerDependencyMeta struct {
+	// Optional marks the peer as not required for the package to work.
+	Optional bool `json:"optional,omitempty"`
+}
+
+// Peers returns the package's peer dependencies.
+// When includeOptional is false, peers marked optional in peerDependenciesMeta are omitted.
+func (pkg *PackageJSON) Peers(includeOptional bool) map[string]string {
+	peers := make(map[string]string, len(pkg.PeerDependencies))
+	for name, version := range pkg.PeerDependencies {
+		if !includeOptional && pkg.PeerDependenciesMeta[name].Optional {
+			continue
+		}
+		peers[name] = version
+	}
+	return peers
 }
 
 // WorkspacePatterns returns the workspace glob patterns from the workspaces field.
@@ -95,6 +259,81 @@ func (pkg *PackageJSON) HasWorkspaces() bool {
 	return len(pkg.WorkspacePatterns()) > 0
 }
 
+// IsESMOnly returns true if the package ships ES modules without any
+// CommonJS entry point.
+func (pkg *PackageJSON) IsESMOnly() bool {
+	esm, cjs := pkg.moduleFormats()
+	return esm && !cjs
+}
+
+// IsDual returns true if the package ships both ES module and CommonJS
+// entry points (e.g., "import" and "require" export conditions).
+func (pkg *PackageJSON) IsDual() bool {
+	esm, cjs := pkg.moduleFormats()
+	return esm && cjs
+}
+
+// moduleFormats reports whether the package provides ES module and CommonJS
+// entry points, inspecting the type, main, module, and exports fields.
+func (pkg *PackageJSON) moduleFormats() (esm, cjs bool) {
+	classify := func(target, condition string) {
+		switch condition {
+		case "types":
+			return
+		case "import", "module":
+			esm = true
+			return
+		case "require":
+			cjs = true
+			return
+		}
+		switch {
+		case strings.HasSuffix(target, ".d.ts"):
+		case strings.HasSuffix(target, ".mjs"):
+			esm = true
+		case strings.HasSuffix(target, ".cjs"):
+			cjs = true
+		case pkg.Type == "module":
+			esm = true
+		default:
+			cjs = true
+		}
+	}
+
+	if pkg.Module != "" {
+		esm = true
+	}
+	if pkg.Main != "" {
+		classify(pkg.Main, "")
+	}
+	walkExportTargets(pkg.Exports, "", classify)
+
+	return esm, cjs
+}
+
+// walkExportTargets calls fn for every target string in an exports value,
+// along with the nearest enclosing format condition ("import", "require",
+// "module", or "types"), or "" if there is none.
+func walkExportTargets(value any, condition string, fn func(target, condition string)) {
+	switch v := value.(type) {
+	case string:
+		fn(v, condition)
+	case []any:
+		for _, item := range v {
+			walkExportTargets(item, condition, fn)
+		}
+	case map[string]any:
+		for key, item := range v {
+			switch key {
+			case "import", "require", "module", "types":
+				walkExportTargets(item, key, fn)
+			default:
+				walkExportTargets(item, condition, fn)
+			}
+		}
+	}
+}
+
 // ExportEntry represents a single export from a package.
 type ExportEntry struct {
 	Subpath string // The export subpath (e.g., ".", "./button")
@@ -105,6 +344,49 @@ type ExportEntry struct {
 type WildcardExport struct {
 	Pattern string // The pattern (e.g., "./*")
 	Target  string // The target prefix (e.g., "dist/")
+	Suffix  string // The target suffix after the wildcard (e.g., "/index.js")
+}
+
+// ImportPrefix returns the part of the pattern before the wildcard, without
+// the leading "./" (e.g., "lib/" for "./lib/*.js").
+func (w WildcardExport) ImportPrefix() string {
+	prefix, _, _ := strings.Cut(trimDotSlash(w.Pattern), "*")
+	return prefix
+}
+
+// patternSuffix returns the part of the pattern after the wildcard.
+func (w WildcardExport) patternSuffix() string {
+	_, suffix, _ := strings.Cut(w.Pattern, "*")
+	return suffix
+}
+
+// IsPrefixMapping reports whether the wildcard can be expressed as a single
+// trailing-slash import map entry mapping ImportPrefix() to Target. This holds
+// when both prefixes end at a path boundary and the text after the wildcard is
+// the same in the pattern and the target, as in "./*.js" -> "./dist/*.js".
+// Patterns such as "./*" -> "./*/index.js" are not prefix mappings; each
+// matching specifier needs its own entry.
+func (w WildcardExport) IsPrefixMapping() bool {
+	atBoundary := func(prefix string) bool {
+		return prefix == "" || strings.HasSuffix(prefix, "/")
+	}
+	return atBoundary(w.ImportPrefix()) && atBoundary(w.Target) && w.patternSuffix() == w.Suffix
+}
+
+// Match matches a target path, relative to the package root, against the
+// wildcard's target and returns the import subpath it is exported as
+// (without the leading "./"). For "./*" -> "./*/index.js", the target
+// "foo/index.js" matches as "foo".
+func (w WildcardExport) Match(target string) (string, bool) {
+	target = trimDotSlash(target)
+	if !strings.HasPrefix(target, w.Target) || !strings.HasSuffix(target, w.Suffix) {
+		return "", false
+	}
+	if len(target) <= len(w.Target)+len(w.Suffix) {
+		return "", false
+	}
+	captured := target[len(w.Target) : len(target)-len(w.Suffix)]
+	return w.ImportPrefix() + captured + w.patternSuffix(), true
 }
 
 // Parse parses package.json data.
@@ -127,91 +409,176 @@ func ParseFile(fs fs.FileSystem, path string) (*PackageJSON, error) {
 
 // ResolveExport resolves a subpath export to its target file path.
 // The subpath should be "." for the main export or "./subpath" for subpath exports.
+// Subpaths without an exact key match the most specific "*" pattern, such as
+// "./features/*.js", and the captured text replaces each "*" in its target.
 // Returns the resolved path without leading "./".
 // Pass nil for opts to use DefaultConditions.
 func (pkg *PackageJSON) ResolveExport(subpath string, opts *ResolveOptions) (string, error) {
+	return pkg.newExportIndex().resolve(subpath, opts)
+}
+
+// ExplainExport resolves subpath like ResolveExport, and also returns a
+// description of each step taken: which field of package.json was used, which
+// exports key or pattern and which conditions matched, and, on failure, why
+// nothing did.
+func (pkg *PackageJSON) ExplainExport(subpath string, opts *ResolveOptions) (string, []string, error) {
+	var explained ResolveOptions
+	if opts != nil {
+		explained = *opts
+	}
+	var steps []string
+	explained.steps = &steps
+	target, err := pkg.ResolveExport(subpath, &explained)
+	return target, steps, err
+}
+
+// ResolveExports resolves several subpath exports of the package at once, as
+// ResolveExport would, returning each exported subpath's target. Subpaths that
+// are not exported are absent from the result. The exports map is scanned and
+// its wildcard patterns sorted once for all subpaths, rather than per subpath.
+func (pkg *PackageJSON) ResolveExports(subpaths []string, opts *ResolveOptions) map[string]string {
+	index := pkg.newExportIndex()
+	targets := make(map[string]string, len(subpaths))
+	for _, subpath := range subpaths {
+		if target, err := index.resolve(subpath, opts); err == nil {
+			targets[subpath] = target
+		}
+	}
+	return targets
+}
+
+// exportIndex holds what resolving a subpath needs from a package's exports
+// field, computed once so that several subpaths can share it.
+type exportIndex struct {
+	pkg         *PackageJSON
+	exportsMap  map[string]any // exports object, if exports is an object
+	hasSubpaths bool           // whether exportsMap has "." keys rather than only conditions
+	patterns    []string       // wildcard keys of exportsMap, most specific first
+}
+
+// newExportIndex indexes the package's exports field.
+func (pkg *PackageJSON) newExportIndex() *exportIndex {
+	index := &exportIndex{pkg: pkg}
+	exportsMap, ok := pkg.Exports.(map[string]any)
+	if !ok {
+		return index
+	}
+	index.exportsMap = exportsMap
+
+	// Check if this is a condition-only export (no subpaths)
+	for key := range exportsMap {
+		if strings.HasPrefix(key, ".") {
+			index.hasSubpaths = true
+			break
+		}
+	}
+
+	// Collect and sort patterns by specificity
+	for pattern := range exportsMap {
+		if strings.Contains(pattern, "*") {
+			index.patterns = append(index.patterns, pattern)
+		}
+	}
+	sort.Slice(index.patterns, func(i, j int) bool {
+		return comparePatternKeys(index.patterns[i], index.patterns[j]) < 0
+	})
+	return index
+}
+
+// comparePatternKeys orders wildcard export keys as Node does: the key with
+// the longer prefix before its "*" is more specific, and for equal prefixes
+// the longer key is. Returns a negative number if a is more specific than b.
+func comparePatternKeys(a, b string) int {
+	aBase, bBase := strings.Index(a, "*"), strings.Index(b, "*")
+	if aBase != bBase {
+		return bBase - aBase
+	}
+	return len(b) - len(a)
+}
+
+// resolve resolves one subpath export, as documented on ResolveExport.
+func (index *exportIndex) resolve(subpath string, opts *ResolveOptions) (string, error) {
+	pkg := index.pkg
 	if pkg.Exports == nil {
 		// Fall back to main field
 		if pkg.Main != "" {
 			if subpath == "." {
+				opts.note("no exports field; using main %q", pkg.Main)
 				return trimDotSlash(pkg.Main), nil
 			}
+			opts.note("no exports field; main %q only maps the package root", pkg.Main)
 			return "", ErrNotExported
 		}
+		opts.note("no exports or main field")
 		return "", ErrNotExported
 	}
 
 	// Handle string export (simple case)
 	if exportStr, ok := pkg.Exports.(string); ok {
 		if subpath == "." {
+			opts.note("exports is the string %q", exportStr)
 			return trimDotSlash(exportStr), nil
 		}
+		opts.note("exports is a string, which only maps the package root")
 		return "", ErrNotExported
 	}
 
-	// Handle exports map
-	exportsMap, ok := pkg.Exports.(map[string]any)
-	if !ok {
+	// Handle fallback array export for the main entry
+	if exportArr, ok := pkg.Exports.([]any); ok {
+		if subpath == "." {
+			opts.note("exports is a fallback array")
+			return resolveExportValueWithOpts(exportArr, opts)
+		}
+		opts.note("exports is a fallback array, which only maps the package root")
 		return "", ErrNotExported
 	}
 
-	// Check if this is a condition-only export (no subpaths)
-	hasSubpaths := false
-	for key := range exportsMap {
-		if strings.HasPrefix(key, ".") {
-			hasSubpaths = true
-			break
-		}
+	// Handle exports map
+	exportsMap := index.exportsMap
+	if exportsMap == nil {
+		opts.note("exports is not a string, array or object")
+		return "", ErrNotExported
 	}
 
-	if !hasSubpaths {
+	if !index.hasSubpaths {
 		// This is a condition-only export for the main entry
 		if subpath == "." {
+			opts.note("exports is a condition map for the package root")
 			return resolveConditionsWithOpts(exportsMap, opts)
 		}
+		opts.note("exports is a condition map, which only maps the package root")
 		return "", ErrNotExported
 	}
 
-	// Look up the subpath directly first
+	// Look up the subpath directly first. Its value may itself be a condition
+	// map, e.g. {".": {"browser": "./b.js"}, "./x": "./x.js"}
 	exportValue, ok := exportsMap[subpath]
 	if ok {
+		opts.note("exports key %q matches", subpath)
 		return resolveExportValueWithOpts(exportValue, opts)
 	}
 
-	// Try wildcard pattern matching (e.g., "./*" -> "./elements/*")
-	// Collect and sort patterns by specificity (longer prefix = higher priority)
-	var patterns []string
-	for pattern := range exportsMap {
-		if strings.Contains(pattern, "*") {
-			patterns = append(patterns, pattern)
-		}
-	}
-	// Sort by length descending (more specific patterns first)
-	sort.Slice(patterns, func(i, j int) bool {
-		return len(patterns[i]) > len(patterns[j])
-	})
-
-	for _, pattern := range patterns {
-		value := exportsMap[pattern]
-		// Match pattern like "./*" or "./*.js"
+	// Try wildcard pattern matching (e.g., "./*" -> "./elements/*"). Only the
+	// most specific matching pattern applies, so a null target such as
+	// "./internal/*": null excludes subpaths a broader pattern would export.
+	for _, pattern := range index.patterns {
+		// Match pattern like "./*" or "./features/*.js"
 		matched, captured := matchExportPattern(pattern, subpath)
 		if !matched {
 			continue
 		}
+		opts.note("exports pattern %q matches, with * = %q", pattern, captured)
 
-		// Resolve the target value
-		target, err := resolveExportValueWithOpts(value, opts)
+		target, err := resolveExportValueWithOpts(exportsMap[pattern], opts)
 		if err != nil {
-			continue
+			return "", err
 		}
 
-		// Replace * in target with captured portion
-		if strings.Contains(target, "*") {
-			return strings.Replace(target, "*", captured, 1), nil
-		}
-		return target, nil
+		// Replace every * in target with the captured portion
+		return strings.ReplaceAll(target, "*", captured), nil
 	}
 
+	opts.note("no exports key or pattern matches %q", subpath)
 	return "", ErrNotExported
 }
 
@@ -240,6 +607,17 @@ func (pkg *PackageJSON) ExportEntries(opts *ResolveOptions) []ExportEntry {
 		return entries
 	}
 
+	// Handle fallback array export
+	if exportArr, ok := pkg.Exports.([]any); ok {
+		if resolved, err := resolveExportValueWithOpts(exportArr, opts); err == nil {
+			entries = append(entries, ExportEntry{
+				Subpath: ".",
+				Target:  resolved,
+			})
+		}
+		return entries
+	}
+
 	// Handle exports map
 	exportsMap, ok := pkg.Exports.(map[string]any)
 	if !ok {
@@ -308,14 +686,13 @@ func (pkg *PackageJSON) WildcardExports(opts *ResolveOptions) []WildcardExport {
 			continue
 		}
 
-		// Extract the prefix before the wildcard
-		target := trimDotSlash(targetStr)
-		wildcardIdx := strings.Index(target, "*")
-		targetPrefix := target[:wildcardIdx]
+		// Extract the prefix and suffix around the wildcard
+		targetPrefix, targetSuffix, _ := strings.Cut(trimDotSlash(targetStr), "*")
 
 		wildcards = append(wildcards, WildcardExport{
 			Pattern: pattern,
 			Target:  targetPrefix,
+			Suffix:  targetSuffix,
 		})
 	}
 
@@ -357,36 +734,73 @@ func (pkg *PackageJSON) HasTrailingSlashExport(opts *ResolveOptions) bool {
 }
 
 // resolveExportValueWithOpts resolves an export value with custom conditions.
+// Fallback arrays resolve to their first element that resolves.
 func resolveExportValueWithOpts(value any, opts *ResolveOptions) (string, error) {
 	switch v := value.(type) {
 	case string:
 		return trimDotSlash(v), nil
 	case map[string]any:
 		return resolveConditionsWithOpts(v, opts)
+	case []any:
+		for _, item := range v {
+			if result, err := resolveExportValueWithOpts(item, opts); err == nil {
+				return result, nil
+			}
+		}
+	case nil:
+		opts.note("target is null, which excludes it from exports")
 	}
 	return "", ErrNotExported
 }
 
 // resolveConditionsWithOpts resolves a conditional export map to a path.
 // Tries each condition in opts.Conditions order, recursing into nested maps.
+// The order of keys in the map is irrelevant: conditions missing from the list,
+// such as "types", are never matched even when listed first in package.json.
+// Negated conditions are never matched, so their targets are skipped at every level.
+// "default" is the fallback at each level, so a nested map under it such as
+// {"default": {"browser": ..., "default": ...}} is still resolved against the
+// more specific conditions. This holds for an explicit opts.Conditions list
+// that omits "default" too, as Node.js always matches it; negate it with
+// "!default" to resolve strictly against the list.
 func resolveConditionsWithOpts(conditions map[string]any, opts *ResolveOptions) (string, error) {
-	conditionList := DefaultConditions
-	if opts != nil && len(opts.Conditions) > 0 {
-		conditionList = opts.Conditions
+	var conditionList []string
+	var blocked map[string]bool
+	if opts != nil {
+		conditionList, blocked = splitConditions(opts.Conditions)
+	}
+	if len(conditionList) == 0 {
+		conditionList = DefaultConditions
 	}
 
 	for _, cond := range conditionList {
+		if blocked[cond] || cond == defaultCondition {
+			continue
+		}
 		if value, ok := conditions[cond]; ok {
-			if valueMap, ok := value.(map[string]any); ok {
-				if result, err := resolveConditionsWithOpts(valueMap, opts); err == nil {
-					return result, nil
-				}
-			} else if valueStr, ok := value.(string); ok {
-				return trimDotSlash(valueStr), nil
+			opts.note("condition %q matches", cond)
+			if result, err := resolveExportValueWithOpts(value, opts); err == nil {
+				return result, nil
 			}
 		}
 	}
 
+	if value, ok := conditions[defaultCondition]; ok && !blocked[defaultCondition] {
+		opts.note("condition %q matches", defaultCondition)
+		return resolveExportValueWithOpts(value, opts)
+	}
+
+	if opts != nil && opts.steps != nil {
+		offered := slices.Sorted(maps.Keys(conditions))
+		tried := slices.DeleteFunc(slices.Clone(conditionList), func(cond string) bool {
+			return cond == defaultCondition
+		})
+		if !blocked[defaultCondition] {
+			tried = append(tried, defaultCondition)
+		}
+		opts.note("no condition matches: tried %s; package.json offers %s",
+			strings.Join(tried, ", "), strings.Join(offered, ", "))
+	}
 	return "", ErrNotExported
 }
 
@@ -18,6 +18,11 @@ package packagejson_test
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
 	"testing"
 
 	"bennypowers.dev/mappa/packagejson"
@@ -205,6 +210,62 @@ func TestResolveExport(t *testing.T) {
 	})
 }
 
+func TestResolveExportArrays(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "packagejson/array-exports", "/test")
+
+	pkg, err := packagejson.ParseFile(mfs, "/test/package.json")
+	if err != nil {
+		t.Fatalf("ParseFile failed: %v", err)
+	}
+
+	expectedBytes, err := mfs.ReadFile("/test/expected.json")
+	if err != nil {
+		t.Fatalf("Failed to read expected.json: %v", err)
+	}
+
+	var expected struct {
+		Exports map[string]string `json:"exports"`
+	}
+	if err := json.Unmarshal(expectedBytes, &expected); err != nil {
+		t.Fatalf("Failed to parse expected.json: %v", err)
+	}
+
+	for subpath, expectedResolved := range expected.Exports {
+		resolved, err := pkg.ResolveExport(subpath, nil)
+		if err != nil {
+			t.Errorf("ResolveExport(%q) failed: %v", subpath, err)
+			continue
+		}
+		if resolved != expectedResolved {
+			t.Errorf("ResolveExport(%q): expected %q, got %q", subpath, expectedResolved, resolved)
+		}
+	}
+
+	entries := pkg.ExportEntries(nil)
+	if len(entries) != len(expected.Exports) {
+		t.Errorf("Expected %d export entries, got %d", len(expected.Exports), len(entries))
+	}
+	for _, e := range entries {
+		if e.Target != expected.Exports[e.Subpath] {
+			t.Errorf("ExportEntries %q: expected %q, got %q", e.Subpath, expected.Exports[e.Subpath], e.Target)
+		}
+	}
+
+	t.Run("top-level array", func(t *testing.T) {
+		pkg, err := packagejson.Parse([]byte(`{"name": "top", "exports": ["./main.js", "./main.cjs"]}`))
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		resolved, err := pkg.ResolveExport(".", nil)
+		if err != nil {
+			t.Fatalf("ResolveExport failed: %v", err)
+		}
+		if resolved != "main.js" {
+			t.Errorf("Expected %q, got %q", "main.js", resolved)
+		}
+	})
+}
+
 func TestExportEntries(t *testing.T) {
 	t.Run("subpath exports enumeration", func(t *testing.T) {
 		mfs := testutil.NewFixtureFS(t, "packagejson/subpath-exports", "/test")
@@ -261,6 +322,7 @@ func TestWildcardExports(t *testing.T) {
 		Wildcard struct {
 			Pattern string `json:"pattern"`
 			Target  string `json:"target"`
+			Suffix  string `json:"suffix"`
 		} `json:"wildcard"`
 	}
 	if err := json.Unmarshal(expectedBytes, &expected); err != nil {
@@ -279,6 +341,40 @@ func TestWildcardExports(t *testing.T) {
 	if w.Target != expected.Wildcard.Target {
 		t.Errorf("Expected target %q, got %q", expected.Wildcard.Target, w.Target)
 	}
+	if w.Suffix != expected.Wildcard.Suffix {
+		t.Errorf("Expected suffix %q, got %q", expected.Wildcard.Suffix, w.Suffix)
+	}
+}
+
+func TestWildcardExportMapping(t *testing.T) {
+	tests := []struct {
+		name          string
+		wildcard      packagejson.WildcardExport
+		prefixMapping bool
+		target        string
+		subpath       string
+		matched       bool
+	}{
+		{"directory", packagejson.WildcardExport{Pattern: "./*", Target: "dist/"}, true, "dist/a/b.js", "a/b.js", true},
+		{"same suffix", packagejson.WildcardExport{Pattern: "./lib/*.js", Target: "dist/", Suffix: ".js"}, true, "dist/button.js", "lib/button.js", true},
+		{"index suffix", packagejson.WildcardExport{Pattern: "./*", Target: "", Suffix: "/index.js"}, false, "foo/index.js", "foo", true},
+		{"extension suffix", packagejson.WildcardExport{Pattern: "./*", Target: "dist/", Suffix: ".js"}, false, "dist/foo.js", "foo", true},
+		{"partial segment", packagejson.WildcardExport{Pattern: "./icon-*", Target: "icons/"}, false, "icons/star", "icon-star", true},
+		{"suffix mismatch", packagejson.WildcardExport{Pattern: "./*", Target: "", Suffix: "/index.js"}, false, "foo/other.js", "", false},
+		{"empty capture", packagejson.WildcardExport{Pattern: "./*", Target: "", Suffix: "/index.js"}, false, "/index.js", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.wildcard.IsPrefixMapping(); got != tt.prefixMapping {
+				t.Errorf("IsPrefixMapping() = %v, want %v", got, tt.prefixMapping)
+			}
+			subpath, ok := tt.wildcard.Match(tt.target)
+			if ok != tt.matched || subpath != tt.subpath {
+				t.Errorf("Match(%q) = (%q, %v), want (%q, %v)", tt.target, subpath, ok, tt.subpath, tt.matched)
+			}
+		})
+	}
 }
 
 func TestResolveExportWildcard(t *testing.T) {
@@ -315,6 +411,48 @@ func TestResolveExportWildcard(t *testing.T) {
 	}
 }
 
+func TestResolveExportPatterns(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "packagejson/export-patterns", "/test")
+
+	pkg, err := packagejson.ParseFile(mfs, "/test/package.json")
+	if err != nil {
+		t.Fatalf("ParseFile failed: %v", err)
+	}
+
+	expectedData, err := mfs.ReadFile("/test/expected.json")
+	if err != nil {
+		t.Fatalf("ReadFile expected.json failed: %v", err)
+	}
+
+	var expected struct {
+		Resolutions map[string]string `json:"resolutions"`
+		NotExported []string          `json:"not_exported"`
+	}
+	if err := json.Unmarshal(expectedData, &expected); err != nil {
+		t.Fatalf("Unmarshal expected.json failed: %v", err)
+	}
+
+	for subpath, want := range expected.Resolutions {
+		t.Run(subpath, func(t *testing.T) {
+			resolved, err := pkg.ResolveExport(subpath, nil)
+			if err != nil {
+				t.Fatalf("ResolveExport(%q) failed: %v", subpath, err)
+			}
+			if resolved != want {
+				t.Errorf("ResolveExport(%q) = %q, want %q", subpath, resolved, want)
+			}
+		})
+	}
+
+	for _, subpath := range expected.NotExported {
+		t.Run(subpath, func(t *testing.T) {
+			if resolved, err := pkg.ResolveExport(subpath, nil); !errors.Is(err, packagejson.ErrNotExported) {
+				t.Errorf("ResolveExport(%q) = %q, %v, want ErrNotExported", subpath, resolved, err)
+			}
+		})
+	}
+}
+
 func TestHasTrailingSlashExport(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -343,6 +481,38 @@ func TestHasTrailingSlashExport(t *testing.T) {
 	}
 }
 
+func TestModuleFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		dir     string
+		esmOnly bool
+		dual    bool
+	}{
+		{"esm only", "esm-only", true, false},
+		{"dual import/require", "conditional-exports", false, true},
+		{"commonjs main", "main-fallback", false, false},
+		{"no entry points", "no-exports", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mfs := testutil.NewFixtureFS(t, "packagejson/"+tt.dir, "/test")
+
+			pkg, err := packagejson.ParseFile(mfs, "/test/package.json")
+			if err != nil {
+				t.Fatalf("ParseFile failed: %v", err)
+			}
+
+			if got := pkg.IsESMOnly(); got != tt.esmOnly {
+				t.Errorf("IsESMOnly() = %v, want %v", got, tt.esmOnly)
+			}
+			if got := pkg.IsDual(); got != tt.dual {
+				t.Errorf("IsDual() = %v, want %v", got, tt.dual)
+			}
+		})
+	}
+}
+
 func TestCustomConditions(t *testing.T) {
 	mfs := testutil.NewFixtureFS(t, "packagejson/production-condition", "/test")
 
@@ -381,6 +551,107 @@ func TestCustomConditions(t *testing.T) {
 	}
 }
 
+func TestNegatedConditions(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "packagejson/negated-conditions", "/test")
+
+	pkg, err := packagejson.ParseFile(mfs, "/test/package.json")
+	if err != nil {
+		t.Fatalf("ParseFile failed: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		conditions []string
+		expected   string
+	}{
+		{"default conditions", nil, "dist/browser.js"},
+		{"node allowed", []string{"node", "browser", "default"}, "dist/node.js"},
+		{"negation wins over inclusion", []string{"node", "browser", "!node", "default"}, "dist/browser.js"},
+		{"negation applies when nested", []string{"browser", "node", "!node", "default"}, "dist/browser.js"},
+		{"module-sync without node", []string{"module-sync", "!node", "default"}, "dist/sync.mjs"},
+		{"negations only filter defaults", []string{"!browser"}, "dist/index.js"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts *packagejson.ResolveOptions
+			if tt.conditions != nil {
+				opts = &packagejson.ResolveOptions{Conditions: tt.conditions}
+			}
+
+			resolved, err := pkg.ResolveExport(".", opts)
+			if err != nil {
+				t.Fatalf("ResolveExport failed: %v", err)
+			}
+			if resolved != tt.expected {
+				t.Errorf("ResolveExport(\".\", %v) = %q, want %q", tt.conditions, resolved, tt.expected)
+			}
+		})
+	}
+}
+
+// resolveExportCase is a table row for runResolveExportCases.
+type resolveExportCase struct {
+	name       string
+	subpath    string
+	conditions []string
+	expected   string
+	wantErr    bool
+}
+
+// runResolveExportCases resolves each case's subpath under its conditions,
+// or the default conditions when nil.
+func runResolveExportCases(t *testing.T, pkg *packagejson.PackageJSON, tests []resolveExportCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts *packagejson.ResolveOptions
+			if tt.conditions != nil {
+				opts = &packagejson.ResolveOptions{Conditions: tt.conditions}
+			}
+
+			resolved, err := pkg.ResolveExport(tt.subpath, opts)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ResolveExport(%q, %v) = %q, want error", tt.subpath, tt.conditions, resolved)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveExport failed: %v", err)
+			}
+			if resolved != tt.expected {
+				t.Errorf("ResolveExport(%q, %v) = %q, want %q", tt.subpath, tt.conditions, resolved, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTypesConditionFirst(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "packagejson/types-first", "/test")
+
+	pkg, err := packagejson.ParseFile(mfs, "/test/package.json")
+	if err != nil {
+		t.Fatalf("ParseFile failed: %v", err)
+	}
+
+	runResolveExportCases(t, pkg, []resolveExportCase{
+		{"root skips types", ".", nil, "dist/index.js", false},
+		{"subpath skips types", "./button", nil, "dist/button.js", false},
+		{"types-only subpath is not exported", "./types-only", nil, "", true},
+		{"no fallthrough to unlisted conditions", ".", []string{"browser", "default"}, "", true},
+		{"types when requested", ".", []string{"types", "import"}, "dist/index.d.ts", false},
+	})
+
+	// Entries never point at declaration files
+	for _, entry := range pkg.ExportEntries(nil) {
+		if strings.HasSuffix(entry.Target, ".d.ts") {
+			t.Errorf("ExportEntries included declaration file %s for %s", entry.Target, entry.Subpath)
+		}
+	}
+}
+
 func TestExportEntriesWithConditions(t *testing.T) {
 	mfs := testutil.NewFixtureFS(t, "packagejson/production-condition", "/test")
 
@@ -505,3 +776,234 @@ func TestHasWorkspaces(t *testing.T) {
 		})
 	}
 }
+
+func TestDevelopmentConditions(t *testing.T) {
+	tests := []struct {
+		name       string
+		conditions []string
+		expected   []string
+	}{
+		{"defaults", nil, []string{"development", "browser", "import", "default"}},
+		{"custom", []string{"production", "default"}, []string{"development", "production", "default"}},
+		{"already present", []string{"browser", "development", "default"}, []string{"development", "browser", "default"}},
+		{"negations only", []string{"!node"}, []string{"development", "browser", "import", "default", "!node"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := packagejson.DevelopmentConditions(tt.conditions)
+			if !slices.Equal(got, tt.expected) {
+				t.Errorf("DevelopmentConditions(%v) = %v, want %v", tt.conditions, got, tt.expected)
+			}
+		})
+	}
+
+	// DefaultConditions must not be modified
+	if !slices.Equal(packagejson.DefaultConditions, []string{"browser", "import", "default"}) {
+		t.Errorf("DefaultConditions was modified: %v", packagejson.DefaultConditions)
+	}
+}
+
+func TestNestedDefaultConditions(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "packagejson/nested-default", "/test")
+
+	pkg, err := packagejson.ParseFile(mfs, "/test/package.json")
+	if err != nil {
+		t.Fatalf("ParseFile failed: %v", err)
+	}
+
+	runResolveExportCases(t, pkg, []resolveExportCase{
+		{"nested browser under default", ".", nil, "b.js", false},
+		{"nested default under default", ".", []string{"node", "import"}, "d.js", false},
+		{"default listed before browser", ".", []string{"default", "browser"}, "b.js", false},
+		{"default not listed", ".", []string{"browser"}, "b.js", false},
+		{"default negated", ".", []string{"browser", "!default"}, "", true},
+		{"explicit list without default", "./fallback", []string{"node", "import"}, "fallback.js", false},
+		{"explicit list with default negated", "./fallback", []string{"node", "import", "!default"}, "", true},
+		{"nested default without a match", "./worker", nil, "", true},
+		{"nested condition without default", "./worker", []string{"node"}, "worker-node.js", false},
+		{"wildcard with nested default", "./elements/card", nil, "elements/card.browser.js", false},
+	})
+}
+
+func TestMixedRootConditions(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "packagejson/mixed-root-conditions", "/test")
+
+	pkg, err := packagejson.ParseFile(mfs, "/test/package.json")
+	if err != nil {
+		t.Fatalf("ParseFile failed: %v", err)
+	}
+
+	runResolveExportCases(t, pkg, []resolveExportCase{
+		{"root resolves conditions beside subpaths", ".", nil, "b.js", false},
+		{"sibling subpath", "./x", nil, "x.js", false},
+		{"root honors condition order", ".", []string{"node", "browser"}, "n.js", false},
+		{"root without matching condition", ".", []string{"import", "default"}, "", true},
+		{"sibling subpath without matching root", "./x", []string{"import", "default"}, "x.js", false},
+	})
+
+	entries := make(map[string]string)
+	for _, entry := range pkg.ExportEntries(nil) {
+		entries[entry.Subpath] = entry.Target
+	}
+	if expected := map[string]string{".": "b.js", "./x": "x.js"}; !maps.Equal(entries, expected) {
+		t.Errorf("ExportEntries() = %v, want %v", entries, expected)
+	}
+}
+
+func TestVersionOverrides(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "packagejson/overrides", "/test")
+
+	pkg, err := packagejson.ParseFile(mfs, "/test/package.json")
+	if err != nil {
+		t.Fatalf("ParseFile failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"scheduler":             "0.22.0",
+		"lit":                   "^3.0.0",
+		"@lit/reactive-element": "2.0.4",
+		"tslib":                 "2.6.0",
+	}
+	if got := pkg.VersionOverrides(); !maps.Equal(got, expected) {
+		t.Errorf("VersionOverrides() = %v, want %v", got, expected)
+	}
+}
+
+func TestEngines(t *testing.T) {
+	pkg, err := packagejson.Parse([]byte(`{"name": "a", "engines": {"node": ">=18", "npm": ">=9", "vscode": 1}}`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	expected := packagejson.Engines{"node": ">=18", "npm": ">=9"}
+	if !maps.Equal(pkg.Engines, expected) {
+		t.Errorf("Engines = %v, want %v", pkg.Engines, expected)
+	}
+
+	// npm ignores the legacy array form, and so does parsing
+	pkg, err = packagejson.Parse([]byte(`{"name": "b", "engines": ["node >= 0.4"]}`))
+	if err != nil {
+		t.Fatalf("Parse failed for array engines: %v", err)
+	}
+	if pkg.Engines != nil {
+		t.Errorf("Expected nil Engines for array form, got %v", pkg.Engines)
+	}
+}
+
+func TestResolveExports(t *testing.T) {
+	for _, dir := range []string{"wildcard-exports", "subpath-exports"} {
+		t.Run(dir, func(t *testing.T) {
+			mfs := testutil.NewFixtureFS(t, "packagejson/"+dir, "/test")
+
+			pkg, err := packagejson.ParseFile(mfs, "/test/package.json")
+			if err != nil {
+				t.Fatalf("ParseFile failed: %v", err)
+			}
+
+			subpaths := []string{".", "./button", "./foo", "./bar/baz", "./missing/nested"}
+			expected := make(map[string]string)
+			for _, subpath := range subpaths {
+				if target, err := pkg.ResolveExport(subpath, nil); err == nil {
+					expected[subpath] = target
+				}
+			}
+
+			if got := pkg.ResolveExports(subpaths, nil); !maps.Equal(got, expected) {
+				t.Errorf("ResolveExports() = %v, want %v", got, expected)
+			}
+		})
+	}
+}
+
+// benchmarkExportsPackage returns a package whose exports have many subpaths
+// and wildcard patterns, and subpaths of it that resolve through the patterns.
+func benchmarkExportsPackage() (*packagejson.PackageJSON, []string) {
+	exports := map[string]any{".": "./index.js"}
+	for i := range 50 {
+		exports[fmt.Sprintf("./entry-%d.js", i)] = fmt.Sprintf("./dist/entry-%d.js", i)
+	}
+	for i := range 10 {
+		exports[fmt.Sprintf("./group-%d/*", i)] = map[string]any{
+			"browser": fmt.Sprintf("./dist/group-%d/*.js", i),
+			"default": fmt.Sprintf("./lib/group-%d/*.js", i),
+		}
+	}
+	subpaths := []string{".", "./group-0/a", "./group-3/b", "./group-6/c", "./group-9/d"}
+	return &packagejson.PackageJSON{Name: "bench", Exports: exports}, subpaths
+}
+
+// BenchmarkResolveExportPerSubpath resolves subpaths of one package one at a
+// time, re-indexing its exports for each.
+func BenchmarkResolveExportPerSubpath(b *testing.B) {
+	pkg, subpaths := benchmarkExportsPackage()
+	for b.Loop() {
+		for _, subpath := range subpaths {
+			_, _ = pkg.ResolveExport(subpath, nil)
+		}
+	}
+}
+
+// BenchmarkResolveExports resolves the same subpaths in one call.
+func BenchmarkResolveExports(b *testing.B) {
+	pkg, subpaths := benchmarkExportsPackage()
+	for b.Loop() {
+		_ = pkg.ResolveExports(subpaths, nil)
+	}
+}
+
+func TestExplainExport(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "explain/node_modules/kit", "/test")
+
+	pkg, err := packagejson.ParseFile(mfs, "/test/package.json")
+	if err != nil {
+		t.Fatalf("ParseFile failed: %v", err)
+	}
+
+	tests := []struct {
+		subpath    string
+		conditions []string
+		target     string
+		steps      []string
+	}{
+		{
+			subpath: ".",
+			target:  "browser.js",
+			steps:   []string{`exports key "." matches`, `condition "browser" matches`},
+		},
+		{
+			subpath: "./button",
+			target:  "lib/button.js",
+			steps:   []string{`exports pattern "./*" matches, with * = "button"`},
+		},
+		{
+			subpath: "./internal/secret",
+			steps: []string{
+				`exports pattern "./internal/*" matches, with * = "secret"`,
+				"target is null, which excludes it from exports",
+			},
+		},
+		{
+			subpath:    ".",
+			conditions: []string{"node", "!default"},
+			steps: []string{
+				`exports key "." matches`,
+				"no condition matches: tried node; package.json offers browser, default, types",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.subpath, func(t *testing.T) {
+			target, steps, err := pkg.ExplainExport(tt.subpath, &packagejson.ResolveOptions{Conditions: tt.conditions})
+			if tt.target == "" && !errors.Is(err, packagejson.ErrNotExported) {
+				t.Errorf("ExplainExport(%q) error = %v, want ErrNotExported", tt.subpath, err)
+			}
+			if target != tt.target {
+				t.Errorf("ExplainExport(%q) target = %q, want %q", tt.subpath, target, tt.target)
+			}
+			if !slices.Equal(steps, tt.steps) {
+				t.Errorf("ExplainExport(%q) steps:\n  got:  %q\n  want: %q", tt.subpath, steps, tt.steps)
+			}
+		})
+	}
+}
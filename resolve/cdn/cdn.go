@@ -20,9 +20,12 @@ package cdn
 
 import (
 	"context"
+	"fmt"
 	"maps"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	mappacdn "bennypowers.dev/mappa/cdn"
 	"bennypowers.dev/mappa/importmap"
@@ -32,16 +35,49 @@ import (
 
 // Resolver generates import maps pointing to CDN-hosted packages.
 type Resolver struct {
-	fetcher      mappacdn.Fetcher
-	provider     mappacdn.Provider
-	registry     *mappacdn.Registry
-	template     *resolve.Template
-	cache        *mappacdn.PackageCache
-	logger       resolve.Logger
-	conditions   []string
-	includeDev   bool
-	maxDepth     int  // Maximum dependency depth (0 = unlimited)
-	resolveScope bool // Whether to resolve transitive dependencies as scopes
+	fetcher        mappacdn.Fetcher
+	provider       mappacdn.Provider
+	registry       *mappacdn.Registry
+	template       *resolve.Template
+	cache          *mappacdn.PackageCache
+	logger         resolve.Logger
+	conditions     []string
+	includeDev     bool
+	maxDepth       int      // Maximum dependency depth (0 = unlimited)
+	resolveScope   bool     // Whether to resolve transitive dependencies as scopes
+	includePeers   bool     // Whether to resolve peerDependencies alongside dependencies
+	skipOptional   bool     // Whether to skip peers marked optional in peerDependenciesMeta
+	scopedPackages []string // Packages whose subtrees get scopes even when resolveScope is off
+	scopeStrategy  ScopeStrategy
+}
+
+// ScopeStrategy selects the keys of the scopes holding a package's dependencies.
+type ScopeStrategy string
+
+const (
+	// ScopePerVersion keys each package's scope by its versioned base URL,
+	// e.g. "https://esm.sh/lit@3.0.0/". The scope covers every module the
+	// package loads, including internal modules the import map doesn't list.
+	// This is the default.
+	ScopePerVersion ScopeStrategy = "per-version"
+
+	// ScopePerImporter keys scopes by the URL of each module the package
+	// exports, e.g. "https://esm.sh/lit@3.0.0/index.js", so a dependency is
+	// chosen per importing module rather than per URL prefix. Exported
+	// directories still get prefix scopes. Modules reached only through
+	// relative imports fall outside the scopes and see the top-level imports,
+	// so this suits CDNs that bundle each export into one module, like
+	// esm.sh, and yields larger maps.
+	ScopePerImporter ScopeStrategy = "per-importer"
+)
+
+// ParseScopeStrategy returns the ScopeStrategy named s.
+func ParseScopeStrategy(s string) (ScopeStrategy, error) {
+	switch strategy := ScopeStrategy(s); strategy {
+	case ScopePerVersion, ScopePerImporter:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("unknown scope strategy %q: must be %q or %q", s, ScopePerVersion, ScopePerImporter)
 }
 
 // New creates a new CDN resolver with default settings.
@@ -61,19 +97,60 @@ func New(fetcher mappacdn.Fetcher) *Resolver {
 func (r *Resolver) WithProvider(provider mappacdn.Provider) *Resolver {
 	tmpl, _ := resolve.ParseTemplate(provider.ModuleTemplate)
 	return &Resolver{
-		fetcher:      r.fetcher,
-		provider:     provider,
-		registry:     r.registry,
-		template:     tmpl,
-		cache:        r.cache,
-		logger:       r.logger,
-		conditions:   r.conditions,
-		includeDev:   r.includeDev,
-		maxDepth:     r.maxDepth,
-		resolveScope: r.resolveScope,
+		fetcher:        r.fetcher,
+		provider:       provider,
+		registry:       r.registry,
+		template:       tmpl,
+		cache:          r.cache,
+		logger:         r.logger,
+		conditions:     r.conditions,
+		includeDev:     r.includeDev,
+		maxDepth:       r.maxDepth,
+		resolveScope:   r.resolveScope,
+		includePeers:   r.includePeers,
+		skipOptional:   r.skipOptional,
+		scopedPackages: r.scopedPackages,
+		scopeStrategy:  r.scopeStrategy,
 	}
 }
 
+// WithRegistry returns a new Resolver that resolves versions and dependencies
+// through the given registry client, e.g. one configured with scoped registries.
+// If the Resolver has a logger, the registry reports deprecations through it.
+func (r *Resolver) WithRegistry(registry *mappacdn.Registry) *Resolver {
+	if r.logger != nil {
+		registry = registry.WithLogger(r.logger)
+	}
+	return &Resolver{
+		fetcher:        r.fetcher,
+		provider:       r.provider,
+		registry:       registry,
+		template:       r.template,
+		cache:          r.cache,
+		logger:         r.logger,
+		conditions:     r.conditions,
+		includeDev:     r.includeDev,
+		maxDepth:       r.maxDepth,
+		resolveScope:   r.resolveScope,
+		includePeers:   r.includePeers,
+		skipOptional:   r.skipOptional,
+		scopedPackages: r.scopedPackages,
+		scopeStrategy:  r.scopeStrategy,
+	}
+}
+
+// WithStableOnly returns a new Resolver whose registry never resolves
+// dependencies to prerelease versions. See Registry.WithStableOnly.
+func (r *Resolver) WithStableOnly(stableOnly bool) *Resolver {
+	return r.WithRegistry(r.registry.WithStableOnly(stableOnly))
+}
+
+// WithAsOf returns a new Resolver whose registry resolves dependencies to the
+// versions that were current at asOf. See Registry.WithAsOf.
+func (r *Resolver) WithAsOf(asOf time.Time) *Resolver {
+	return r.WithRegistry(r.registry.WithAsOf(asOf))
+}
+
 // WithTemplate returns a new Resolver using a custom URL template.
 func (r *Resolver) WithTemplate(pattern string) (*Resolver, error) {
 	tmpl, err := resolve.ParseTemplate(pattern)
@@ -81,64 +158,81 @@ func (r *Resolver) WithTemplate(pattern string) (*Resolver, error) {
 		return nil, err
 	}
 	return &Resolver{
-		fetcher:      r.fetcher,
-		provider:     r.provider,
-		registry:     r.registry,
-		template:     tmpl,
-		cache:        r.cache,
-		logger:       r.logger,
-		conditions:   r.conditions,
-		includeDev:   r.includeDev,
-		maxDepth:     r.maxDepth,
-		resolveScope: r.resolveScope,
+		fetcher:        r.fetcher,
+		provider:       r.provider,
+		registry:       r.registry,
+		template:       tmpl,
+		cache:          r.cache,
+		logger:         r.logger,
+		conditions:     r.conditions,
+		includeDev:     r.includeDev,
+		maxDepth:       r.maxDepth,
+		resolveScope:   r.resolveScope,
+		includePeers:   r.includePeers,
+		skipOptional:   r.skipOptional,
+		scopedPackages: r.scopedPackages,
+		scopeStrategy:  r.scopeStrategy,
 	}, nil
 }
 
 // WithLogger returns a new Resolver with the specified logger.
+// The logger also receives warnings for deprecated versions from the registry.
 func (r *Resolver) WithLogger(logger resolve.Logger) *Resolver {
 	return &Resolver{
-		fetcher:      r.fetcher,
-		provider:     r.provider,
-		registry:     r.registry,
-		template:     r.template,
-		cache:        r.cache,
-		logger:       logger,
-		conditions:   r.conditions,
-		includeDev:   r.includeDev,
-		maxDepth:     r.maxDepth,
-		resolveScope: r.resolveScope,
+		fetcher:        r.fetcher,
+		provider:       r.provider,
+		registry:       r.registry.WithLogger(logger),
+		template:       r.template,
+		cache:          r.cache,
+		logger:         logger,
+		conditions:     r.conditions,
+		includeDev:     r.includeDev,
+		maxDepth:       r.maxDepth,
+		resolveScope:   r.resolveScope,
+		includePeers:   r.includePeers,
+		skipOptional:   r.skipOptional,
+		scopedPackages: r.scopedPackages,
+		scopeStrategy:  r.scopeStrategy,
 	}
 }
 
 // WithConditions returns a new Resolver with the specified export conditions.
 func (r *Resolver) WithConditions(conditions []string) *Resolver {
 	return &Resolver{
-		fetcher:      r.fetcher,
-		provider:     r.provider,
-		registry:     r.registry,
-		template:     r.template,
-		cache:        r.cache,
-		logger:       r.logger,
-		conditions:   conditions,
-		includeDev:   r.includeDev,
-		maxDepth:     r.maxDepth,
-		resolveScope: r.resolveScope,
+		fetcher:        r.fetcher,
+		provider:       r.provider,
+		registry:       r.registry,
+		template:       r.template,
+		cache:          r.cache,
+		logger:         r.logger,
+		conditions:     conditions,
+		includeDev:     r.includeDev,
+		maxDepth:       r.maxDepth,
+		resolveScope:   r.resolveScope,
+		includePeers:   r.includePeers,
+		skipOptional:   r.skipOptional,
+		scopedPackages: r.scopedPackages,
+		scopeStrategy:  r.scopeStrategy,
 	}
 }
 
 // WithIncludeDev returns a new Resolver that includes devDependencies.
 func (r *Resolver) WithIncludeDev(include bool) *Resolver {
 	return &Resolver{
-		fetcher:      r.fetcher,
-		provider:     r.provider,
-		registry:     r.registry,
-		template:     r.template,
-		cache:        r.cache,
-		logger:       r.logger,
-		conditions:   r.conditions,
-		includeDev:   include,
-		maxDepth:     r.maxDepth,
-		resolveScope: r.resolveScope,
+		fetcher:        r.fetcher,
+		provider:       r.provider,
+		registry:       r.registry,
+		template:       r.template,
+		cache:          r.cache,
+		logger:         r.logger,
+		conditions:     r.conditions,
+		includeDev:     include,
+		maxDepth:       r.maxDepth,
+		resolveScope:   r.resolveScope,
+		includePeers:   r.includePeers,
+		skipOptional:   r.skipOptional,
+		scopedPackages: r.scopedPackages,
+		scopeStrategy:  r.scopeStrategy,
 	}
 }
 
@@ -146,35 +240,172 @@ func (r *Resolver) WithIncludeDev(include bool) *Resolver {
 // 0 means unlimited (default), 1 means direct dependencies only.
 func (r *Resolver) WithMaxDepth(depth int) *Resolver {
 	return &Resolver{
-		fetcher:      r.fetcher,
-		provider:     r.provider,
-		registry:     r.registry,
-		template:     r.template,
-		cache:        r.cache,
-		logger:       r.logger,
-		conditions:   r.conditions,
-		includeDev:   r.includeDev,
-		maxDepth:     depth,
-		resolveScope: r.resolveScope,
+		fetcher:        r.fetcher,
+		provider:       r.provider,
+		registry:       r.registry,
+		template:       r.template,
+		cache:          r.cache,
+		logger:         r.logger,
+		conditions:     r.conditions,
+		includeDev:     r.includeDev,
+		maxDepth:       depth,
+		resolveScope:   r.resolveScope,
+		includePeers:   r.includePeers,
+		skipOptional:   r.skipOptional,
+		scopedPackages: r.scopedPackages,
+		scopeStrategy:  r.scopeStrategy,
 	}
 }
 
 // WithResolveScope controls whether to generate scopes for transitive dependencies.
 func (r *Resolver) WithResolveScope(resolveScope bool) *Resolver {
 	return &Resolver{
-		fetcher:      r.fetcher,
-		provider:     r.provider,
-		registry:     r.registry,
-		template:     r.template,
-		cache:        r.cache,
-		logger:       r.logger,
-		conditions:   r.conditions,
-		includeDev:   r.includeDev,
-		maxDepth:     r.maxDepth,
-		resolveScope: resolveScope,
+		fetcher:        r.fetcher,
+		provider:       r.provider,
+		registry:       r.registry,
+		template:       r.template,
+		cache:          r.cache,
+		logger:         r.logger,
+		conditions:     r.conditions,
+		includeDev:     r.includeDev,
+		maxDepth:       r.maxDepth,
+		resolveScope:   resolveScope,
+		includePeers:   r.includePeers,
+		skipOptional:   r.skipOptional,
+		scopedPackages: r.scopedPackages,
+		scopeStrategy:  r.scopeStrategy,
+	}
+}
+
+// WithScopedPackages returns a new Resolver that generates scopes for the
+// subtrees of the given packages even when scope resolution is turned off
+// with WithResolveScope(false), so one problematic package can be isolated
+// while the rest of the map stays flat.
+func (r *Resolver) WithScopedPackages(packages []string) *Resolver {
+	return &Resolver{
+		fetcher:        r.fetcher,
+		provider:       r.provider,
+		registry:       r.registry,
+		template:       r.template,
+		cache:          r.cache,
+		logger:         r.logger,
+		conditions:     r.conditions,
+		includeDev:     r.includeDev,
+		maxDepth:       r.maxDepth,
+		resolveScope:   r.resolveScope,
+		includePeers:   r.includePeers,
+		skipOptional:   r.skipOptional,
+		scopedPackages: packages,
+		scopeStrategy:  r.scopeStrategy,
+	}
+}
+
+// WithIncludePeers controls whether peerDependencies are resolved alongside
+// dependencies, so that packages which rely on a host package find it in their scope.
+func (r *Resolver) WithIncludePeers(include bool) *Resolver {
+	return &Resolver{
+		fetcher:        r.fetcher,
+		provider:       r.provider,
+		registry:       r.registry,
+		template:       r.template,
+		cache:          r.cache,
+		logger:         r.logger,
+		conditions:     r.conditions,
+		includeDev:     r.includeDev,
+		maxDepth:       r.maxDepth,
+		resolveScope:   r.resolveScope,
+		includePeers:   include,
+		skipOptional:   r.skipOptional,
+		scopedPackages: r.scopedPackages,
+		scopeStrategy:  r.scopeStrategy,
+	}
+}
+
+// WithSkipOptionalPeers controls whether peers marked optional in
+// peerDependenciesMeta are skipped when peers are included.
+func (r *Resolver) WithSkipOptionalPeers(skip bool) *Resolver {
+	return &Resolver{
+		fetcher:        r.fetcher,
+		provider:       r.provider,
+		registry:       r.registry,
+		template:       r.template,
+		cache:          r.cache,
+		logger:         r.logger,
+		conditions:     r.conditions,
+		includeDev:     r.includeDev,
+		maxDepth:       r.maxDepth,
+		resolveScope:   r.resolveScope,
+		includePeers:   r.includePeers,
+		skipOptional:   skip,
+		scopedPackages: r.scopedPackages,
+		scopeStrategy:  r.scopeStrategy,
+	}
+}
+
+// WithScopeStrategy returns a new Resolver that keys the scopes of
+// transitive dependencies according to strategy. See ScopeStrategy for the
+// tradeoffs.
+func (r *Resolver) WithScopeStrategy(strategy ScopeStrategy) *Resolver {
+	return &Resolver{
+		fetcher:        r.fetcher,
+		provider:       r.provider,
+		registry:       r.registry,
+		template:       r.template,
+		cache:          r.cache,
+		logger:         r.logger,
+		conditions:     r.conditions,
+		includeDev:     r.includeDev,
+		maxDepth:       r.maxDepth,
+		resolveScope:   r.resolveScope,
+		includePeers:   r.includePeers,
+		skipOptional:   r.skipOptional,
+		scopedPackages: r.scopedPackages,
+		scopeStrategy:  strategy,
 	}
 }
 
+// scopeKeys returns the keys of the scopes holding the dependencies of
+// pkgName at version, following the scope strategy.
+func (r *Resolver) scopeKeys(pkgName, version string, pkg *packagejson.PackageJSON) []string {
+	base := r.template.Expand(pkgName, version, "")
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+	if r.scopeStrategy != ScopePerImporter {
+		return []string{base}
+	}
+
+	var keys []string
+	for _, url := range r.buildPackageImports(pkgName, version, pkg) {
+		if !slices.Contains(keys, url) {
+			keys = append(keys, url)
+		}
+	}
+	if len(keys) == 0 {
+		return []string{base}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// dependencies returns the runtime dependencies of a package: its
+// dependencies plus, when enabled, its peers. Regular dependencies take
+// precedence over peers of the same name.
+func (r *Resolver) dependencies(pkg *packagejson.PackageJSON) map[string]string {
+	deps := maps.Clone(pkg.Dependencies)
+	if deps == nil {
+		deps = make(map[string]string)
+	}
+	if r.includePeers {
+		for name, version := range pkg.Peers(!r.skipOptional) {
+			if _, exists := deps[name]; !exists {
+				deps[name] = version
+			}
+		}
+	}
+	return deps
+}
+
 // resolveOpts returns ResolveOptions for the configured conditions.
 func (r *Resolver) resolveOpts() *packagejson.ResolveOptions {
 	if len(r.conditions) == 0 {
@@ -190,11 +421,22 @@ func (r *Resolver) ResolvePackageJSON(ctx context.Context, pkg *packagejson.Pack
 		Scopes:  make(map[string]map[string]string),
 	}
 
-	// Collect dependencies to process
-	deps := maps.Clone(pkg.Dependencies)
-	if deps == nil {
-		deps = make(map[string]string)
+	r.resolveDependencies(ctx, pkg, result, nil)
+
+	// Clean up empty scopes
+	if len(result.Scopes) == 0 {
+		result.Scopes = nil
 	}
+
+	return result, nil
+}
+
+// resolveDependencies resolves a package.json's dependencies in parallel.
+// Resolved entries are added to im when non-nil, and packages and edges
+// are recorded in graph when non-nil.
+func (r *Resolver) resolveDependencies(ctx context.Context, pkg *packagejson.PackageJSON, im *importmap.ImportMap, graph *graphBuilder) {
+	// Collect dependencies to process
+	deps := r.dependencies(pkg)
 	if r.includeDev {
 		for name, version := range pkg.DevDependencies {
 			if _, exists := deps[name]; !exists {
@@ -203,6 +445,10 @@ func (r *Resolver) ResolvePackageJSON(ctx context.Context, pkg *packagejson.Pack
 		}
 	}
 
+	// Transitive dependencies honor the root's overrides and resolutions,
+	// so a forced version is shared instead of duplicated
+	overrides := pkg.VersionOverrides()
+
 	// Resolve each dependency
 	var wg sync.WaitGroup
 	var mu sync.Mutex
@@ -216,7 +462,7 @@ func (r *Resolver) ResolvePackageJSON(ctx context.Context, pkg *packagejson.Pack
 			sem <- struct{}{}
 			defer func() { <-sem }()
 
-			if err := r.resolvePackage(ctx, result, &mu, &visited, pkgName, verRange, 0); err != nil {
+			if err := r.resolvePackage(ctx, im, graph, &mu, &visited, overrides, pkgName, verRange, 0, false); err != nil {
 				if r.logger != nil {
 					r.logger.Warning("Failed to resolve %s@%s: %v", pkgName, verRange, err)
 				}
@@ -224,23 +470,22 @@ func (r *Resolver) ResolvePackageJSON(ctx context.Context, pkg *packagejson.Pack
 		}(name, versionRange)
 	}
 	wg.Wait()
-
-	// Clean up empty scopes
-	if len(result.Scopes) == 0 {
-		result.Scopes = nil
-	}
-
-	return result, nil
 }
 
 // resolvePackage resolves a single package and its dependencies.
+// overrides maps package names to version ranges that replace the ranges
+// transitive dependencies declare for them, from the root package.json.
+// scoped is true when an ancestor was listed in WithScopedPackages.
 func (r *Resolver) resolvePackage(
 	ctx context.Context,
 	im *importmap.ImportMap,
+	graph *graphBuilder,
 	mu *sync.Mutex,
 	visited *sync.Map,
+	overrides map[string]string,
 	pkgName, versionRange string,
 	depth int,
+	scoped bool,
 ) error {
 	// Check max depth
 	if r.maxDepth > 0 && depth >= r.maxDepth {
@@ -253,8 +498,21 @@ func (r *Resolver) resolvePackage(
 		return err
 	}
 
-	// Check if already visited at this or higher version
+	if graph != nil && depth == 0 {
+		graph.addRoot(pkgName, version)
+	}
+
+	// Scopes are generated for every package, or only within the subtrees
+	// of packages listed in WithScopedPackages
+	scoped = scoped || r.resolveScope || slices.Contains(r.scopedPackages, pkgName)
+
+	// Check if already visited at this or higher version. A package first
+	// reached outside a scoped subtree is visited again inside one, so that
+	// its own dependencies get scopes there.
 	cacheKey := pkgName + "@" + version
+	if scoped {
+		cacheKey += " scoped"
+	}
 	if _, loaded := visited.LoadOrStore(cacheKey, true); loaded {
 		return nil
 	}
@@ -265,29 +523,37 @@ func (r *Resolver) resolvePackage(
 		return err
 	}
 
+	if graph != nil {
+		dist, _ := r.registry.Dist(pkgName, version)
+		graph.addPackage(pkgName, version, dist)
+	}
+
 	// Add to imports
-	r.addPackageImports(im, mu, pkgName, version, pkg)
+	if im != nil {
+		r.addPackageImports(im, mu, pkgName, version, pkg)
+	}
 
 	// Resolve transitive dependencies if enabled
-	if r.resolveScope && (r.maxDepth == 0 || depth < r.maxDepth) && len(pkg.Dependencies) > 0 {
-		scopeKey := r.template.Expand(pkgName, version, "")
-		if !strings.HasSuffix(scopeKey, "/") {
-			scopeKey += "/"
-		}
+	deps := r.dependencies(pkg)
+	if scoped && (r.maxDepth == 0 || depth < r.maxDepth) && len(deps) > 0 {
+		scopeKeys := r.scopeKeys(pkgName, version, pkg)
 
 		scopeEntries := make(map[string]string)
 		var wg sync.WaitGroup
 		var scopeMu sync.Mutex
 		sem := make(chan struct{}, 10)
 
-		for depName, depVer := range pkg.Dependencies {
+		for depName, depVer := range deps {
 			wg.Add(1)
 			go func(name, ver string) {
 				defer wg.Done()
 				sem <- struct{}{}
 				defer func() { <-sem }()
 
-				// Resolve transitive dependency version
+				// Resolve transitive dependency version, unless overridden
+				if override, ok := overrides[name]; ok {
+					ver = override
+				}
 				resolvedVer, err := r.registry.ResolveVersion(ctx, name, ver)
 				if err != nil {
 					if r.logger != nil {
@@ -296,23 +562,29 @@ func (r *Resolver) resolvePackage(
 					return
 				}
 
-				// Fetch package.json
-				depPkg, err := r.fetchPackageJSON(ctx, name, resolvedVer)
-				if err != nil {
-					if r.logger != nil {
-						r.logger.Warning("Failed to fetch %s@%s: %v", name, resolvedVer, err)
-					}
-					return
+				if graph != nil {
+					graph.addEdge(pkgName, version, name, resolvedVer)
 				}
 
-				// Build scope entries
-				entries := r.buildPackageImports(name, resolvedVer, depPkg)
-				scopeMu.Lock()
-				maps.Copy(scopeEntries, entries)
-				scopeMu.Unlock()
+				if im != nil {
+					// Fetch package.json
+					depPkg, err := r.fetchPackageJSON(ctx, name, resolvedVer)
+					if err != nil {
+						if r.logger != nil {
+							r.logger.Warning("Failed to fetch %s@%s: %v", name, resolvedVer, err)
+						}
+						return
+					}
+
+					// Build scope entries
+					entries := r.buildPackageImports(name, resolvedVer, depPkg)
+					scopeMu.Lock()
+					maps.Copy(scopeEntries, entries)
+					scopeMu.Unlock()
+				}
 
 				// Recursively resolve deeper dependencies using resolved version
-				if err := r.resolvePackage(ctx, im, mu, visited, name, resolvedVer, depth+1); err != nil {
+				if err := r.resolvePackage(ctx, im, graph, mu, visited, overrides, name, resolvedVer, depth+1, scoped); err != nil {
 					if r.logger != nil {
 						r.logger.Warning("Failed to resolve transitive dep %s: %v", name, err)
 					}
@@ -326,10 +598,12 @@ func (r *Resolver) resolvePackage(
 			if im.Scopes == nil {
 				im.Scopes = make(map[string]map[string]string)
 			}
-			if im.Scopes[scopeKey] == nil {
-				im.Scopes[scopeKey] = make(map[string]string)
+			for _, scopeKey := range scopeKeys {
+				if im.Scopes[scopeKey] == nil {
+					im.Scopes[scopeKey] = make(map[string]string)
+				}
+				maps.Copy(im.Scopes[scopeKey], scopeEntries)
 			}
-			maps.Copy(im.Scopes[scopeKey], scopeEntries)
 			mu.Unlock()
 		}
 	}
@@ -386,8 +660,15 @@ func (r *Resolver) buildPackageImports(pkgName, version string, pkg *packagejson
 	// Handle wildcard exports
 	wildcards := pkg.WildcardExports(opts)
 	for _, w := range wildcards {
-		patternPrefix := strings.TrimSuffix(strings.TrimPrefix(w.Pattern, "./"), "*")
-		importKey := pkgName + "/" + patternPrefix
+		// Patterns with a suffix after the wildcard (e.g. "./*" -> "./*/index.js")
+		// need one entry per file, which can't be listed from the registry
+		if !w.IsPrefixMapping() {
+			if r.logger != nil {
+				r.logger.Warning("Package '%s': wildcard export %s is not mapped, since it is not expressible as a trailing-slash mapping; add its specifiers with --input-map", pkgName, w.Pattern)
+			}
+			continue
+		}
+		importKey := pkgName + "/" + w.ImportPrefix()
 		imports[importKey] = r.template.Expand(pkgName, version, w.Target)
 	}
 
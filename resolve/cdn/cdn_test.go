@@ -19,46 +19,20 @@ package cdn
 
 import (
 	"context"
+	"maps"
+	"slices"
+	"strings"
 	"testing"
 
 	mappacdn "bennypowers.dev/mappa/cdn"
+	"bennypowers.dev/mappa/importmap"
 	"bennypowers.dev/mappa/packagejson"
+	"bennypowers.dev/mappa/resolve"
 	"bennypowers.dev/mappa/testutil"
 )
 
-// MockFetcher is a test implementation of the Fetcher interface.
-type MockFetcher struct {
-	responses map[string][]byte
-	errors    map[string]error
-}
-
-func NewMockFetcher() *MockFetcher {
-	return &MockFetcher{
-		responses: make(map[string][]byte),
-		errors:    make(map[string]error),
-	}
-}
-
-func (m *MockFetcher) AddResponse(url string, data []byte) {
-	m.responses[url] = data
-}
-
-func (m *MockFetcher) AddError(url string, err error) {
-	m.errors[url] = err
-}
-
-func (m *MockFetcher) Fetch(ctx context.Context, url string) ([]byte, error) {
-	if err, ok := m.errors[url]; ok {
-		return nil, err
-	}
-	if data, ok := m.responses[url]; ok {
-		return data, nil
-	}
-	return nil, &mappacdn.FetchError{URL: url, StatusCode: 404, Message: "Not Found"}
-}
-
 func TestResolverResolvePackageJSON(t *testing.T) {
-	mockFetcher := NewMockFetcher()
+	mockFetcher := mappacdn.NewMapFetcher(nil)
 
 	// Load fixtures using testutil
 	litRegistry := testutil.LoadFixtureFile(t, "lit-registry/response.json")
@@ -96,7 +70,7 @@ func TestResolverResolvePackageJSON(t *testing.T) {
 }
 
 func TestResolverWithProvider(t *testing.T) {
-	mockFetcher := NewMockFetcher()
+	mockFetcher := mappacdn.NewMapFetcher(nil)
 
 	// Load fixtures for unpkg
 	preactRegistry := testutil.LoadFixtureFile(t, "preact-registry/response.json")
@@ -129,7 +103,7 @@ func TestResolverWithProvider(t *testing.T) {
 }
 
 func TestResolverWithConditions(t *testing.T) {
-	mockFetcher := NewMockFetcher()
+	mockFetcher := mappacdn.NewMapFetcher(nil)
 
 	// Load fixtures
 	testPkgRegistry := testutil.LoadFixtureFile(t, "test-pkg-registry/response.json")
@@ -162,7 +136,7 @@ func TestResolverWithConditions(t *testing.T) {
 }
 
 func TestResolverWithIncludeDev(t *testing.T) {
-	mockFetcher := NewMockFetcher()
+	mockFetcher := mappacdn.NewMapFetcher(nil)
 
 	// Load fixtures
 	prodPkgRegistry := testutil.LoadFixtureFile(t, "prod-pkg-registry/response.json")
@@ -212,7 +186,7 @@ func TestResolverWithIncludeDev(t *testing.T) {
 }
 
 func TestBuildPackageImports(t *testing.T) {
-	mockFetcher := NewMockFetcher()
+	mockFetcher := mappacdn.NewMapFetcher(nil)
 	resolver := New(mockFetcher)
 
 	pkg := &packagejson.PackageJSON{
@@ -235,7 +209,7 @@ func TestBuildPackageImports(t *testing.T) {
 }
 
 func TestBuildPackageImportsMainFallback(t *testing.T) {
-	mockFetcher := NewMockFetcher()
+	mockFetcher := mappacdn.NewMapFetcher(nil)
 	resolver := New(mockFetcher)
 
 	pkg := &packagejson.PackageJSON{
@@ -250,3 +224,403 @@ func TestBuildPackageImportsMainFallback(t *testing.T) {
 		t.Errorf("Unexpected main fallback: %s", imports["legacy"])
 	}
 }
+
+func TestBuildPackageImportsWildcardSuffix(t *testing.T) {
+	logger := resolve.NewCollectingLogger()
+	resolver := New(mappacdn.NewMapFetcher(nil)).WithLogger(logger)
+
+	pkg := &packagejson.PackageJSON{
+		Name:    "icons",
+		Version: "1.0.0",
+		Exports: map[string]any{
+			".":       "./index.js",
+			"./*":     "./*/index.js",
+			"./lib/*": "./lib/*",
+		},
+	}
+
+	imports := resolver.buildPackageImports("icons", "1.0.0", pkg)
+
+	if imports["icons/lib/"] != "https://esm.sh/icons@1.0.0/lib/" {
+		t.Errorf("Unexpected prefix mapping: %s", imports["icons/lib/"])
+	}
+	if _, ok := imports["icons/"]; ok {
+		t.Errorf("Expected no trailing-slash entry for a suffixed wildcard, got %v", imports)
+	}
+	expected := "Package 'icons': wildcard export ./* is not mapped, since it is not expressible as a trailing-slash mapping; add its specifiers with --input-map"
+	warnings := logger.Warnings()
+	if len(warnings) != 1 || warnings[0].Message != expected {
+		t.Errorf("Expected warning %q, got %v", expected, warnings)
+	}
+}
+
+func TestResolvePreload(t *testing.T) {
+	mockFetcher := mappacdn.NewMapFetcher(nil)
+
+	appRegistry := testutil.LoadFixtureFile(t, "app-pkg-registry/response.json")
+	appPackage := testutil.LoadFixtureFile(t, "app-pkg-package/package.json")
+	litRegistry := testutil.LoadFixtureFile(t, "lit-registry/response.json")
+	litPackage := testutil.LoadFixtureFile(t, "lit-package/package.json")
+
+	mockFetcher.AddResponse("https://registry.npmjs.org/app-pkg", appRegistry)
+	mockFetcher.AddResponse("https://esm.sh/app-pkg@2.0.0/package.json", appPackage)
+	mockFetcher.AddResponse("https://registry.npmjs.org/lit", litRegistry)
+	mockFetcher.AddResponse("https://esm.sh/lit@3.0.0/package.json", litPackage)
+
+	pkg := &packagejson.PackageJSON{
+		Dependencies: map[string]string{
+			"app-pkg": "^2.0.0",
+		},
+	}
+
+	result, err := New(mockFetcher).ResolvePreload(context.Background(), pkg)
+	if err != nil {
+		t.Fatalf("ResolvePreload error: %v", err)
+	}
+
+	expected := []string{
+		"https://esm.sh/app-pkg@2.0.0/app.js",
+		"https://esm.sh/lit@3.0.0/index.js",
+	}
+	if !slices.Equal(result.Preload, expected) {
+		t.Errorf("Preload = %v, want %v", result.Preload, expected)
+	}
+	if result.ImportMap.Imports["app-pkg"] != expected[0] {
+		t.Errorf("Unexpected app-pkg import: %s", result.ImportMap.Imports["app-pkg"])
+	}
+}
+
+func TestResolveSourceMaps(t *testing.T) {
+	mockFetcher := mappacdn.NewMapFetcher(nil)
+	mockFetcher.AddResponse("https://registry.npmjs.org/preact", testutil.LoadFixtureFile(t, "preact-registry/response.json"))
+	mockFetcher.AddResponse("https://unpkg.com/preact@10.0.0/package.json", testutil.LoadFixtureFile(t, "preact-package/package.json"))
+
+	pkg := &packagejson.PackageJSON{
+		Dependencies: map[string]string{
+			"preact": "^10.0.0",
+		},
+	}
+
+	result, err := New(mockFetcher).WithProvider(mappacdn.Unpkg).WithMaxDepth(1).ResolveSourceMaps(context.Background(), pkg)
+	if err != nil {
+		t.Fatalf("ResolveSourceMaps error: %v", err)
+	}
+
+	module := "https://unpkg.com/preact@10.0.0/dist/preact.mjs"
+	if result.ImportMap.Imports["preact"] != module {
+		t.Errorf("Expected %s, got %s", module, result.ImportMap.Imports["preact"])
+	}
+	if got := result.SourceMaps[module]; got != module+".map" {
+		t.Errorf("Expected source map %s.map, got %q (manifest %v)", module, got, result.SourceMaps)
+	}
+}
+
+func TestSourceMapURLs(t *testing.T) {
+	im := &importmap.ImportMap{
+		Imports: map[string]string{
+			"lit":      "https://cdn.jsdelivr.net/npm/lit@3.0.0/index.js",
+			"lit/":     "https://cdn.jsdelivr.net/npm/lit@3.0.0/",
+			"tiny":     "data:text/javascript,export%20default%201",
+			"lit/x.js": "https://cdn.jsdelivr.net/npm/lit@3.0.0/x.js",
+		},
+		Scopes: map[string]map[string]string{
+			"https://cdn.jsdelivr.net/npm/lit@3.0.0/": {
+				"lit-html": "https://cdn.jsdelivr.net/npm/lit-html@3.0.0/lit-html.js",
+			},
+		},
+	}
+
+	expected := map[string]string{
+		"https://cdn.jsdelivr.net/npm/lit@3.0.0/index.js":         "https://cdn.jsdelivr.net/npm/lit@3.0.0/index.js.map",
+		"https://cdn.jsdelivr.net/npm/lit@3.0.0/x.js":             "https://cdn.jsdelivr.net/npm/lit@3.0.0/x.js.map",
+		"https://cdn.jsdelivr.net/npm/lit-html@3.0.0/lit-html.js": "https://cdn.jsdelivr.net/npm/lit-html@3.0.0/lit-html.js.map",
+	}
+	if got := SourceMapURLs(im, mappacdn.Jsdelivr); !maps.Equal(got, expected) {
+		t.Errorf("SourceMapURLs() = %v, want %v", got, expected)
+	}
+
+	// esm.sh serves build entry points, not package files
+	if got := SourceMapURLs(im, mappacdn.EsmSh); len(got) != 0 {
+		t.Errorf("Expected no source maps for esm.sh, got %v", got)
+	}
+}
+
+func TestResolverIncludePeers(t *testing.T) {
+	mockFetcher := mappacdn.NewMapFetcher(nil)
+
+	pluginRegistry := testutil.LoadFixtureFile(t, "plugin-pkg-registry/response.json")
+	pluginPackage := testutil.LoadFixtureFile(t, "plugin-pkg-package/package.json")
+	litRegistry := testutil.LoadFixtureFile(t, "lit-registry/response.json")
+	litPackage := testutil.LoadFixtureFile(t, "lit-package/package.json")
+	preactRegistry := testutil.LoadFixtureFile(t, "preact-registry/response.json")
+	preactPackage := testutil.LoadFixtureFile(t, "preact-package/package.json")
+
+	mockFetcher.AddResponse("https://registry.npmjs.org/plugin-pkg", pluginRegistry)
+	mockFetcher.AddResponse("https://esm.sh/plugin-pkg@1.0.0/package.json", pluginPackage)
+	mockFetcher.AddResponse("https://registry.npmjs.org/lit", litRegistry)
+	mockFetcher.AddResponse("https://esm.sh/lit@3.0.0/package.json", litPackage)
+	mockFetcher.AddResponse("https://registry.npmjs.org/preact", preactRegistry)
+	mockFetcher.AddResponse("https://esm.sh/preact@10.0.0/package.json", preactPackage)
+
+	pkg := &packagejson.PackageJSON{
+		Dependencies: map[string]string{
+			"plugin-pkg": "^1.0.0",
+		},
+	}
+	scopeKey := "https://esm.sh/plugin-pkg@1.0.0/"
+
+	tests := []struct {
+		name         string
+		resolver     *Resolver
+		expectLit    bool
+		expectPreact bool
+	}{
+		{"peers excluded by default", New(mockFetcher), false, false},
+		{"peers included", New(mockFetcher).WithIncludePeers(true), true, true},
+		{"optional peers skipped", New(mockFetcher).WithIncludePeers(true).WithSkipOptionalPeers(true), true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.resolver.ResolvePackageJSON(context.Background(), pkg)
+			if err != nil {
+				t.Fatalf("ResolvePackageJSON error: %v", err)
+			}
+
+			scope := result.Scopes[scopeKey]
+			if _, ok := scope["lit"]; ok != tt.expectLit {
+				t.Errorf("lit in scope = %v, want %v (scopes: %v)", ok, tt.expectLit, result.Scopes)
+			}
+			if _, ok := scope["preact"]; ok != tt.expectPreact {
+				t.Errorf("preact in scope = %v, want %v (scopes: %v)", ok, tt.expectPreact, result.Scopes)
+			}
+		})
+	}
+}
+
+func TestResolverScopedPackages(t *testing.T) {
+	mockFetcher := mappacdn.NewMapFetcher(nil)
+	for _, fixture := range []struct{ name, version string }{
+		{"react", "18.2.0"},
+		{"scheduler", "0.23.0"},
+		{"loose-envify", "1.4.0"},
+		{"app-pkg", "2.0.0"},
+		{"lit", "3.0.0"},
+	} {
+		mockFetcher.AddResponse("https://registry.npmjs.org/"+fixture.name,
+			testutil.LoadFixtureFile(t, fixture.name+"-registry/response.json"))
+		mockFetcher.AddResponse("https://esm.sh/"+fixture.name+"@"+fixture.version+"/package.json",
+			testutil.LoadFixtureFile(t, fixture.name+"-package/package.json"))
+	}
+
+	pkg := &packagejson.PackageJSON{
+		Dependencies: map[string]string{
+			"react":   "^18.0.0",
+			"app-pkg": "^2.0.0",
+		},
+	}
+
+	resolver := New(mockFetcher).WithResolveScope(false).WithScopedPackages([]string{"react"})
+	result, err := resolver.ResolvePackageJSON(context.Background(), pkg)
+	if err != nil {
+		t.Fatalf("ResolvePackageJSON error: %v", err)
+	}
+
+	expected := map[string]string{
+		"https://esm.sh/react@18.2.0/":     "scheduler",
+		"https://esm.sh/scheduler@0.23.0/": "loose-envify",
+	}
+	if len(result.Scopes) != len(expected) {
+		t.Errorf("Expected scopes only for react's subtree, got %v", result.Scopes)
+	}
+	for scopeKey, dep := range expected {
+		if _, ok := result.Scopes[scopeKey][dep]; !ok {
+			t.Errorf("Expected %s in scope %s, got %v", dep, scopeKey, result.Scopes)
+		}
+	}
+	if _, ok := result.Scopes["https://esm.sh/app-pkg@2.0.0/"]; ok {
+		t.Errorf("app-pkg should not be scoped when scopes are off, got %v", result.Scopes)
+	}
+	for _, spec := range []string{"react", "app-pkg"} {
+		if _, ok := result.Imports[spec]; !ok {
+			t.Errorf("Expected %s in imports, got %v", spec, result.Imports)
+		}
+	}
+}
+
+func TestResolverScopeStrategy(t *testing.T) {
+	mockFetcher := mappacdn.NewMapFetcher(nil)
+	for _, fixture := range []struct{ name, version string }{
+		{"app-pkg", "2.0.0"},
+		{"lit", "3.0.0"},
+	} {
+		mockFetcher.AddResponse("https://registry.npmjs.org/"+fixture.name,
+			testutil.LoadFixtureFile(t, fixture.name+"-registry/response.json"))
+		mockFetcher.AddResponse("https://esm.sh/"+fixture.name+"@"+fixture.version+"/package.json",
+			testutil.LoadFixtureFile(t, fixture.name+"-package/package.json"))
+	}
+
+	pkg := &packagejson.PackageJSON{
+		Dependencies: map[string]string{"app-pkg": "^2.0.0"},
+	}
+	litEntries := map[string]string{
+		"lit":               "https://esm.sh/lit@3.0.0/index.js",
+		"lit/decorators.js": "https://esm.sh/lit@3.0.0/decorators.js",
+	}
+
+	tests := []struct {
+		name     string
+		strategy ScopeStrategy
+		scopes   []string
+	}{
+		{"default", "", []string{"https://esm.sh/app-pkg@2.0.0/"}},
+		{"per version", ScopePerVersion, []string{"https://esm.sh/app-pkg@2.0.0/"}},
+		{"per importer", ScopePerImporter, []string{
+			"https://esm.sh/app-pkg@2.0.0/app.js",
+			"https://esm.sh/app-pkg@2.0.0/utils.js",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := New(mockFetcher).WithScopeStrategy(tt.strategy).ResolvePackageJSON(context.Background(), pkg)
+			if err != nil {
+				t.Fatalf("ResolvePackageJSON error: %v", err)
+			}
+			if got := slices.Sorted(maps.Keys(result.Scopes)); !slices.Equal(got, tt.scopes) {
+				t.Errorf("Scope keys = %v, want %v", got, tt.scopes)
+			}
+			for _, key := range tt.scopes {
+				if !maps.Equal(result.Scopes[key], litEntries) {
+					t.Errorf("Scope %s = %v, want %v", key, result.Scopes[key], litEntries)
+				}
+			}
+		})
+	}
+}
+
+func TestParseScopeStrategy(t *testing.T) {
+	for _, s := range []string{"per-version", "per-importer"} {
+		if strategy, err := ParseScopeStrategy(s); err != nil || string(strategy) != s {
+			t.Errorf("ParseScopeStrategy(%q) = %q, %v", s, strategy, err)
+		}
+	}
+	if _, err := ParseScopeStrategy("perPackage"); err == nil {
+		t.Error("Expected an error for an unknown strategy")
+	}
+}
+
+func TestResolveGraph(t *testing.T) {
+	mockFetcher := mappacdn.NewMapFetcher(nil)
+
+	appRegistry := testutil.LoadFixtureFile(t, "app-pkg-registry/response.json")
+	appPackage := testutil.LoadFixtureFile(t, "app-pkg-package/package.json")
+	litRegistry := testutil.LoadFixtureFile(t, "lit-registry/response.json")
+	litPackage := testutil.LoadFixtureFile(t, "lit-package/package.json")
+
+	mockFetcher.AddResponse("https://registry.npmjs.org/app-pkg", appRegistry)
+	mockFetcher.AddResponse("https://esm.sh/app-pkg@2.0.0/package.json", appPackage)
+	mockFetcher.AddResponse("https://registry.npmjs.org/lit", litRegistry)
+	mockFetcher.AddResponse("https://esm.sh/lit@3.0.0/package.json", litPackage)
+
+	pkg := &packagejson.PackageJSON{
+		Dependencies: map[string]string{
+			"app-pkg": "^2.0.0",
+		},
+	}
+
+	graph, err := New(mockFetcher).ResolveGraph(context.Background(), pkg)
+	if err != nil {
+		t.Fatalf("ResolveGraph error: %v", err)
+	}
+
+	if !slices.Equal(graph.Roots, []string{"app-pkg@2.0.0"}) {
+		t.Errorf("Roots = %v, want [app-pkg@2.0.0]", graph.Roots)
+	}
+
+	if len(graph.Packages) != 2 {
+		t.Fatalf("Expected 2 packages, got %d: %+v", len(graph.Packages), graph.Packages)
+	}
+
+	app := graph.Packages[0]
+	if app.Name != "app-pkg" || app.Version != "2.0.0" {
+		t.Errorf("Unexpected first package: %+v", app)
+	}
+	if app.Dependencies["lit"] != "3.0.0" {
+		t.Errorf("Expected app-pkg -> lit@3.0.0 edge, got %v", app.Dependencies)
+	}
+
+	lit := graph.Packages[1]
+	if lit.Name != "lit" || lit.Version != "3.0.0" || len(lit.Dependencies) != 0 {
+		t.Errorf("Unexpected second package: %+v", lit)
+	}
+	if lit.Resolved != "https://registry.npmjs.org/lit/-/lit-3.0.0.tgz" {
+		t.Errorf("lit Resolved = %q", lit.Resolved)
+	}
+	if !strings.HasPrefix(lit.Integrity, "sha512-") {
+		t.Errorf("lit Integrity = %q, want a sha512 hash", lit.Integrity)
+	}
+	if app.Integrity != "" {
+		t.Errorf("app-pkg has no published dist, got Integrity %q", app.Integrity)
+	}
+
+	// Edges only name packages in the graph, even when the max depth stops
+	// the walk before reaching them
+	shallow, err := New(mockFetcher).WithMaxDepth(1).ResolveGraph(context.Background(), pkg)
+	if err != nil {
+		t.Fatalf("ResolveGraph error: %v", err)
+	}
+	if len(shallow.Packages) != 1 || shallow.Packages[0].Dependencies != nil {
+		t.Errorf("Expected app-pkg alone without edges at max depth 1, got %+v", shallow.Packages)
+	}
+}
+
+func TestResolvePackageJSONWithGraph(t *testing.T) {
+	mockFetcher := mappacdn.NewMapFetcher(nil)
+	mockFetcher.AddResponse("https://registry.npmjs.org/lit", testutil.LoadFixtureFile(t, "lit-registry/response.json"))
+	mockFetcher.AddResponse("https://esm.sh/lit@3.0.0/package.json", testutil.LoadFixtureFile(t, "lit-package/package.json"))
+
+	pkg := &packagejson.PackageJSON{
+		Dependencies: map[string]string{"lit": "^3.0.0"},
+	}
+
+	result, graph, err := New(mockFetcher).ResolvePackageJSONWithGraph(context.Background(), pkg)
+	if err != nil {
+		t.Fatalf("ResolvePackageJSONWithGraph error: %v", err)
+	}
+	if result.Imports["lit"] == "" {
+		t.Errorf("Expected lit in imports, got %v", result.Imports)
+	}
+	if len(graph.Packages) != 1 || graph.Packages[0].Integrity == "" {
+		t.Errorf("Expected lit with integrity in graph, got %+v", graph.Packages)
+	}
+}
+
+func TestResolverOverrides(t *testing.T) {
+	mockFetcher := mappacdn.NewMapFetcher(nil)
+	for _, fixture := range []struct{ name, version string }{
+		{"react", "18.2.0"},
+		{"scheduler", "0.22.0"},
+		{"loose-envify", "1.4.0"},
+	} {
+		mockFetcher.AddResponse("https://registry.npmjs.org/"+fixture.name,
+			testutil.LoadFixtureFile(t, fixture.name+"-registry/response.json"))
+		mockFetcher.AddResponse("https://esm.sh/"+fixture.name+"@"+fixture.version+"/package.json",
+			testutil.LoadFixtureFile(t, fixture.name+"-package/package.json"))
+	}
+
+	pkg := &packagejson.PackageJSON{
+		Dependencies: map[string]string{"react": "^18.0.0"},
+		RawOverrides: []byte(`{"scheduler": "0.22.0"}`),
+	}
+
+	result, err := New(mockFetcher).ResolvePackageJSON(context.Background(), pkg)
+	if err != nil {
+		t.Fatalf("ResolvePackageJSON error: %v", err)
+	}
+
+	scope := result.Scopes["https://esm.sh/react@18.2.0/"]
+	if got := scope["scheduler"]; !strings.Contains(got, "scheduler@0.22.0") {
+		t.Errorf("Expected overridden scheduler@0.22.0 in react's scope, got %q (scopes: %v)", got, result.Scopes)
+	}
+}
@@ -0,0 +1,164 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cdn
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"sync"
+
+	mappacdn "bennypowers.dev/mappa/cdn"
+	"bennypowers.dev/mappa/importmap"
+	"bennypowers.dev/mappa/packagejson"
+)
+
+// ResolutionGraph describes the packages and versions a CDN resolution would include.
+type ResolutionGraph struct {
+	// Roots lists the requested (direct) dependencies as name@version, sorted.
+	Roots []string `json:"roots"`
+	// Packages lists each resolved package, sorted by name and version.
+	Packages []GraphPackage `json:"packages"`
+}
+
+// GraphPackage is a resolved package and its dependency edges.
+type GraphPackage struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// Resolved is the URL of the package's registry tarball.
+	Resolved string `json:"resolved,omitempty"`
+	// Integrity is the registry's integrity hash of the tarball, for
+	// verifying installs against the resolution.
+	Integrity string `json:"integrity,omitempty"`
+	// Dependencies maps each dependency name to its resolved version.
+	Dependencies map[string]string `json:"dependencies,omitempty"`
+}
+
+// ResolveGraph resolves a parsed package.json's dependency graph without
+// building an import map. It follows the same traversal as ResolvePackageJSON,
+// honoring max depth, devDependencies, and scope resolution settings.
+func (r *Resolver) ResolveGraph(ctx context.Context, pkg *packagejson.PackageJSON) (*ResolutionGraph, error) {
+	graph := newGraphBuilder()
+	r.resolveDependencies(ctx, pkg, nil, graph)
+	return graph.build(), nil
+}
+
+// ResolvePackageJSONWithGraph generates an ImportMap from a parsed
+// package.json, as ResolvePackageJSON does, and returns the dependency graph
+// recorded in the same pass, with each package's tarball integrity.
+func (r *Resolver) ResolvePackageJSONWithGraph(ctx context.Context, pkg *packagejson.PackageJSON) (*importmap.ImportMap, *ResolutionGraph, error) {
+	result := &importmap.ImportMap{
+		Imports: make(map[string]string),
+		Scopes:  make(map[string]map[string]string),
+	}
+
+	graph := newGraphBuilder()
+	r.resolveDependencies(ctx, pkg, result, graph)
+
+	if len(result.Scopes) == 0 {
+		result.Scopes = nil
+	}
+
+	return result, graph.build(), nil
+}
+
+// graphBuilder collects packages and edges concurrently during resolution.
+type graphBuilder struct {
+	mu       sync.Mutex
+	roots    map[string]bool
+	packages map[string]*GraphPackage // keyed by name@version
+}
+
+func newGraphBuilder() *graphBuilder {
+	return &graphBuilder{
+		roots:    make(map[string]bool),
+		packages: make(map[string]*GraphPackage),
+	}
+}
+
+// addRoot records a direct dependency.
+func (g *graphBuilder) addRoot(name, version string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.roots[name+"@"+version] = true
+}
+
+// addPackage records a resolved package and its tarball metadata.
+func (g *graphBuilder) addPackage(name, version string, dist mappacdn.RegistryDist) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	node := g.node(name, version)
+	node.Resolved = dist.Tarball
+	node.Integrity = dist.Integrity
+}
+
+// addEdge records that name@version depends on depName@depVersion.
+func (g *graphBuilder) addEdge(name, version, depName, depVersion string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	node := g.node(name, version)
+	if node.Dependencies == nil {
+		node.Dependencies = make(map[string]string)
+	}
+	node.Dependencies[depName] = depVersion
+}
+
+// node returns the package for name@version, creating it if needed.
+// Callers must hold g.mu.
+func (g *graphBuilder) node(name, version string) *GraphPackage {
+	key := name + "@" + version
+	if node, ok := g.packages[key]; ok {
+		return node
+	}
+	node := &GraphPackage{Name: name, Version: version}
+	g.packages[key] = node
+	return node
+}
+
+// build returns the collected graph with deterministic ordering. Edges to
+// packages the walk never reached, such as those beyond the max depth, are
+// dropped, so that every edge names a package in the graph.
+func (g *graphBuilder) build() *ResolutionGraph {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	result := &ResolutionGraph{
+		Roots:    slices.Sorted(maps.Keys(g.roots)),
+		Packages: make([]GraphPackage, 0, len(g.packages)),
+	}
+	for _, node := range g.packages {
+		pkg := *node
+		pkg.Dependencies = maps.Clone(node.Dependencies)
+		maps.DeleteFunc(pkg.Dependencies, func(name, version string) bool {
+			_, ok := g.packages[name+"@"+version]
+			return !ok
+		})
+		if len(pkg.Dependencies) == 0 {
+			pkg.Dependencies = nil
+		}
+		result.Packages = append(result.Packages, pkg)
+	}
+	slices.SortFunc(result.Packages, func(a, b GraphPackage) int {
+		return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.Version, b.Version))
+	})
+	if result.Roots == nil {
+		result.Roots = []string{}
+	}
+	return result
+}
@@ -0,0 +1,116 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cdn
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"bennypowers.dev/mappa/importmap"
+	"bennypowers.dev/mappa/packagejson"
+)
+
+// PreloadResult contains an import map and the module URLs to preload.
+type PreloadResult struct {
+	// ImportMap is the resolved import map.
+	ImportMap *importmap.ImportMap
+
+	// Preload lists module URLs for <link rel="modulepreload">, sorted and deduplicated.
+	// Covers the entry modules of the requested packages and of their direct dependencies.
+	Preload []string
+}
+
+// ResolvePreload generates an ImportMap from a parsed package.json along with
+// a preload manifest for the requested packages' immediate module graph.
+func (r *Resolver) ResolvePreload(ctx context.Context, pkg *packagejson.PackageJSON) (*PreloadResult, error) {
+	im, err := r.ResolvePackageJSON(ctx, pkg)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(pkg.Dependencies))
+	for name := range pkg.Dependencies {
+		names = append(names, name)
+	}
+	if r.includeDev {
+		for name := range pkg.DevDependencies {
+			names = append(names, name)
+		}
+	}
+
+	return &PreloadResult{
+		ImportMap: im,
+		Preload:   PreloadURLs(im, names),
+	}, nil
+}
+
+// PreloadURLs returns the entry module URLs for the given packages and for
+// the dependencies declared in each package's scope.
+// The result is sorted and deduplicated.
+func PreloadURLs(im *importmap.ImportMap, packages []string) []string {
+	if im == nil {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range packages {
+		url, ok := im.Imports[name]
+		if !ok {
+			continue
+		}
+		seen[url] = true
+
+		// The package's own scope maps its dependencies to their resolved versions
+		for key, depURL := range packageScope(im, url) {
+			if isPackageRoot(key) {
+				seen[depURL] = true
+			}
+		}
+	}
+
+	urls := make([]string, 0, len(seen))
+	for url := range seen {
+		urls = append(urls, url)
+	}
+	slices.Sort(urls)
+	return urls
+}
+
+// packageScope returns the most specific scope whose prefix matches url.
+func packageScope(im *importmap.ImportMap, url string) map[string]string {
+	var best string
+	for scopeKey := range im.Scopes {
+		if strings.HasPrefix(url, scopeKey) && len(scopeKey) > len(best) {
+			best = scopeKey
+		}
+	}
+	if best == "" {
+		return nil
+	}
+	return im.Scopes[best]
+}
+
+// isPackageRoot returns true if the import key is a bare package name
+// without a subpath (e.g., "lit" or "@scope/pkg", but not "lit/" or "lit/html.js").
+func isPackageRoot(key string) bool {
+	if strings.HasPrefix(key, "@") {
+		return strings.Count(key, "/") == 1 && !strings.HasSuffix(key, "/")
+	}
+	return !strings.Contains(key, "/")
+}
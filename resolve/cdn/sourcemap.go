@@ -0,0 +1,80 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cdn
+
+import (
+	"context"
+	"strings"
+
+	mappacdn "bennypowers.dev/mappa/cdn"
+	"bennypowers.dev/mappa/importmap"
+	"bennypowers.dev/mappa/packagejson"
+)
+
+// SourceMapResult contains an import map and the likely source map URL of
+// each module it maps.
+type SourceMapResult struct {
+	// ImportMap is the resolved import map.
+	ImportMap *importmap.ImportMap
+
+	// SourceMaps maps module URLs to their source map URLs.
+	// Empty if the provider has no source map convention.
+	SourceMaps map[string]string
+}
+
+// ResolveSourceMaps generates an ImportMap from a parsed package.json along
+// with a manifest of source map URLs for its modules, following the provider's
+// convention. Source maps are not fetched, so a package that doesn't publish
+// them will have URLs that 404.
+func (r *Resolver) ResolveSourceMaps(ctx context.Context, pkg *packagejson.PackageJSON) (*SourceMapResult, error) {
+	im, err := r.ResolvePackageJSON(ctx, pkg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SourceMapResult{
+		ImportMap:  im,
+		SourceMaps: SourceMapURLs(im, r.provider),
+	}, nil
+}
+
+// SourceMapURLs maps each module URL in the import map, in imports and scopes,
+// to its source map URL under the provider's convention. Trailing-slash entries
+// map directories rather than modules and are skipped. Returns an empty map if
+// the provider has no source map convention.
+func SourceMapURLs(im *importmap.ImportMap, provider mappacdn.Provider) map[string]string {
+	sourceMaps := make(map[string]string)
+	if im == nil || provider.SourceMapSuffix == "" {
+		return sourceMaps
+	}
+
+	add := func(imports map[string]string) {
+		for key, url := range imports {
+			if strings.HasSuffix(key, "/") || strings.HasPrefix(url, "data:") {
+				continue
+			}
+			sourceMaps[url] = url + provider.SourceMapSuffix
+		}
+	}
+
+	add(im.Imports)
+	for _, scope := range im.Scopes {
+		add(scope)
+	}
+	return sourceMaps
+}
@@ -133,6 +133,58 @@ func (g *DependencyGraph) Dependents(pkg string) []string {
 	return result
 }
 
+// Packages returns the names of all packages known to the graph, sorted.
+func (g *DependencyGraph) Packages() []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	names := make(map[string]bool)
+	for _, m := range []map[string]map[string]bool{g.dependsOn, g.dependents} {
+		for pkg := range m {
+			names[pkg] = true
+		}
+	}
+	for pkg := range g.packagePaths {
+		names[pkg] = true
+	}
+	for pkg := range g.scopeKeys {
+		names[pkg] = true
+	}
+	for pkg := range g.workspacePackages {
+		names[pkg] = true
+	}
+	return slices.Sorted(maps.Keys(names))
+}
+
+// GraphNode describes a package in the dependency graph.
+type GraphNode struct {
+	Name string `json:"name"`
+	// Path is the package's filesystem path, if known.
+	Path string `json:"path,omitempty"`
+	// ScopeKey is the import map scope holding the package's dependencies, if any.
+	ScopeKey string `json:"scope_key,omitempty"`
+	// Workspace is true for workspace packages, false for node_modules packages.
+	Workspace bool `json:"workspace"`
+	// Dependents lists the packages that directly depend on this one, sorted.
+	Dependents []string `json:"dependents,omitempty"`
+}
+
+// Nodes returns a description of every package in the graph, sorted by name.
+func (g *DependencyGraph) Nodes() []GraphNode {
+	packages := g.Packages()
+	nodes := make([]GraphNode, 0, len(packages))
+	for _, pkg := range packages {
+		nodes = append(nodes, GraphNode{
+			Name:       pkg,
+			Path:       g.PackagePath(pkg),
+			ScopeKey:   g.ScopeKey(pkg),
+			Workspace:  g.IsWorkspacePackage(pkg),
+			Dependents: g.Dependents(pkg),
+		})
+	}
+	return nodes
+}
+
 // TransitiveDependents returns all packages that directly or indirectly depend on pkg.
 // Uses breadth-first traversal to find all dependents.
 func (g *DependencyGraph) TransitiveDependents(pkg string) []string {
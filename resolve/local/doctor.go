@@ -0,0 +1,160 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package local
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"bennypowers.dev/mappa/packagejson"
+	"bennypowers.dev/mappa/resolve"
+)
+
+// Check severities reported by Diagnose.
+const (
+	CheckOK      = "ok"
+	CheckWarning = "warning"
+	CheckError   = "error"
+)
+
+// Check is the outcome of one readiness check run by Diagnose.
+type Check struct {
+	// Subject is what was checked: "package.json", "node_modules", or the
+	// name of a dependency.
+	Subject string `json:"subject"`
+	// Severity is CheckOK, CheckWarning or CheckError.
+	Severity string `json:"severity"`
+	// Message describes the result and, for problems, how to fix them.
+	Message string `json:"message"`
+}
+
+// Diagnosis lists the checks run by Diagnose, in order.
+type Diagnosis struct {
+	Checks []Check `json:"checks"`
+}
+
+// Count returns the number of checks with the given severity.
+func (d *Diagnosis) Count(severity string) int {
+	n := 0
+	for _, c := range d.Checks {
+		if c.Severity == severity {
+			n++
+		}
+	}
+	return n
+}
+
+func (d *Diagnosis) add(subject, severity, format string, args ...any) {
+	d.Checks = append(d.Checks, Check{Subject: subject, Severity: severity, Message: fmt.Sprintf(format, args...)})
+}
+
+// Diagnose checks whether the project at rootDir is ready to generate an
+// import map: that its package.json parses, that node_modules exists, and
+// that each dependency is installed, has a parseable package.json, and maps
+// its bare specifier. Missing and unparseable dependencies are errors;
+// dependencies that won't map their bare specifier, or only map it by falling
+// back from a main field naming a missing file, are warnings. Each dependency
+// is mapped as Resolve maps it.
+func (r *Resolver) Diagnose(rootDir string) *Diagnosis {
+	d := &Diagnosis{}
+
+	if absRoot, err := filepath.Abs(rootDir); err == nil {
+		rootDir = absRoot
+	}
+
+	rootPkg, err := r.parsePackageJSON(filepath.Join(rootDir, "package.json"))
+	if err != nil {
+		d.add("package.json", CheckError, "cannot read %s: %v", filepath.Join(rootDir, "package.json"), err)
+		return d
+	}
+	d.add("package.json", CheckOK, "parsed")
+
+	workspaceRoot := resolve.FindWorkspaceRoot(r.fs, rootDir)
+	r = r.withPnP(workspaceRoot).withPnpm(workspaceRoot)
+	nodeModulesPath := filepath.Join(workspaceRoot, "node_modules")
+
+	switch _, pnp := r.fs.(*resolve.PnPFileSystem); {
+	case pnp:
+		d.add("node_modules", CheckOK, "using Yarn Plug'n'Play data in %s", workspaceRoot)
+	case r.fs.Exists(nodeModulesPath):
+		d.add("node_modules", CheckOK, "found %s", nodeModulesPath)
+	default:
+		d.add("node_modules", CheckError, "%s does not exist; install dependencies, e.g. with npm install", nodeModulesPath)
+	}
+
+	names := make([]string, 0, len(rootPkg.Dependencies))
+	for name := range rootPkg.Dependencies {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	opts := r.resolveOpts()
+	for _, name := range names {
+		r.diagnoseDependency(d, name, filepath.Join(nodeModulesPath, name), opts)
+	}
+
+	return d
+}
+
+// diagnoseDependency adds the check for one direct dependency installed at pkgPath.
+func (r *Resolver) diagnoseDependency(d *Diagnosis, name, pkgPath string, opts *packagejson.ResolveOptions) {
+	if !r.fs.Exists(pkgPath) {
+		d.add(name, CheckError, "not installed in node_modules; install dependencies, e.g. with npm install")
+		return
+	}
+
+	pkg, err := r.parsePackageJSON(filepath.Join(pkgPath, "package.json"))
+	if err != nil {
+		d.add(name, CheckError, "cannot read package.json: %v; reinstall the package", err)
+		return
+	}
+
+	// Map the package as Resolve does, keeping what it reports about it,
+	// such as a main field naming a missing file
+	logger := resolve.NewCollectingLogger()
+	mapper := *r
+	mapper.logger = logger
+	imports, err := mapper.packageImports(name, pkgPath, pkg, pkg.Version, opts)
+	if err != nil {
+		d.add(name, CheckError, "%v", err)
+		return
+	}
+	var problems []string
+	for _, w := range logger.Warnings() {
+		problems = append(problems, strings.TrimPrefix(w.Message, fmt.Sprintf("Package '%s': ", name)))
+	}
+	_, mapped := imports[name]
+	entries := pkg.ExportEntries(opts)
+	wildcards := pkg.WildcardExports(opts)
+
+	switch {
+	case mapped && len(problems) > 0:
+		d.add(name, CheckWarning, "%s; reinstall the package, or ask it to fix its package.json", strings.Join(problems, "; "))
+	case mapped:
+		d.add(name, CheckOK, "%s", pkg.Version)
+	case exportsSubpaths(pkg, entries, wildcards) && r.allowSubpathOnly:
+		d.add(name, CheckOK, "%s, subpath exports only", pkg.Version)
+	case exportsSubpaths(pkg, entries, wildcards):
+		d.add(name, CheckWarning, "%s; import one of its subpaths, or pass --allow-subpath-only if that is intended", noRootExportMessage)
+	case pkg.Exports != nil:
+		d.add(name, CheckWarning, "exports nothing under the active export conditions; it will have no import map entries unless you change --conditions")
+	default:
+		d.add(name, CheckWarning, "%s; import '%s/<file>' by path, or ask the package to add an exports field", noRootExportMessage, name)
+	}
+}
@@ -0,0 +1,171 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package local
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"bennypowers.dev/mappa/packagejson"
+	"bennypowers.dev/mappa/resolve"
+)
+
+// Explanation describes how Explain resolved a bare specifier, step by step.
+type Explanation struct {
+	// Specifier is the bare specifier explained, e.g. "lit/decorators.js".
+	Specifier string `json:"specifier"`
+	// Package is the package name parsed from the specifier.
+	Package string `json:"package"`
+	// Version is the installed package's version, if known.
+	Version string `json:"version,omitempty"`
+	// PackageJSON is the package.json consulted, relative to the root directory.
+	PackageJSON string `json:"packageJson,omitempty"`
+	// Subpath is the export subpath looked up, "." for the package root.
+	Subpath string `json:"subpath"`
+	// Conditions is the export condition priority used.
+	Conditions []string `json:"conditions"`
+	// Steps describe each resolution decision, in order.
+	Steps []string `json:"steps"`
+	// Target is the resolved path within the package.
+	Target string `json:"target,omitempty"`
+	// File is the resolved file, relative to the root directory.
+	File string `json:"file,omitempty"`
+	// URL is the import map URL generated for the specifier.
+	URL string `json:"url,omitempty"`
+	// Error explains why the specifier doesn't resolve to a file, if it doesn't.
+	Error string `json:"error,omitempty"`
+}
+
+// Resolved reports whether the specifier resolved to an existing file.
+func (e *Explanation) Resolved() bool {
+	return e.Error == ""
+}
+
+func (e *Explanation) step(format string, args ...any) {
+	e.Steps = append(e.Steps, fmt.Sprintf(format, args...))
+}
+
+func (e *Explanation) fail(format string, args ...any) *Explanation {
+	e.Error = fmt.Sprintf(format, args...)
+	return e
+}
+
+// Explain resolves a single bare specifier from the project at rootDir the
+// way ResolveSpecifiers does, recording the package.json consulted, the
+// exports key or pattern and conditions that matched, the fallbacks taken,
+// and the final URL. When resolution fails, the explanation says why: the
+// package isn't installed, the subpath isn't exported, no condition matched,
+// or the resolved file doesn't exist.
+func (r *Resolver) Explain(rootDir, specifier string) *Explanation {
+	if absRoot, err := filepath.Abs(rootDir); err == nil {
+		rootDir = absRoot
+	}
+
+	e := &Explanation{Specifier: specifier, Conditions: packagejson.DefaultConditions}
+	if len(r.conditions) > 0 {
+		e.Conditions = r.conditions
+	}
+	if specifier == "" || strings.HasPrefix(specifier, ".") || strings.HasPrefix(specifier, "/") || strings.Contains(specifier, ":") {
+		return e.fail("%q is not a bare specifier", specifier)
+	}
+	e.Package = parsePackageName(specifier)
+	e.Subpath = "."
+	if rest := strings.TrimPrefix(specifier, e.Package); rest != "" {
+		e.Subpath = "." + rest
+	}
+
+	workspaceRoot := resolve.FindWorkspaceRoot(r.fs, rootDir)
+	r = r.withPnP(workspaceRoot).withPnpm(workspaceRoot)
+	pkgPath := filepath.Join(workspaceRoot, "node_modules", e.Package)
+	pkgJSONPath := filepath.Join(pkgPath, "package.json")
+	e.PackageJSON = displayPath(rootDir, pkgJSONPath)
+
+	if !r.fs.Exists(pkgJSONPath) {
+		return e.fail("package %s is not installed", e.Package)
+	}
+	pkg, err := r.parsePackageJSON(pkgJSONPath)
+	if err != nil {
+		return e.fail("could not parse %s: %v", e.PackageJSON, err)
+	}
+	e.Version = pkg.Version
+
+	realPath, webPath, linked := r.linkedPackage(pkgPath, workspaceRoot)
+	if linked {
+		pkgPath = realPath
+		e.step("%s links to %s, so it is served from there", displayPath(rootDir, filepath.Join(workspaceRoot, "node_modules", e.Package)), displayPath(rootDir, realPath))
+	}
+
+	// Fall back as ResolveSpecifiers does: the package root to its main
+	// module, and unexported subpaths to the file at the same path
+	target, steps, err := pkg.ExplainExport(e.Subpath, r.resolveOpts())
+	e.Steps = append(e.Steps, steps...)
+	// The last step says why an exports field didn't export the subpath,
+	// e.g. no condition matched or the target is null
+	var notExported string
+	if err != nil && pkg.Exports != nil && len(steps) > 0 {
+		notExported = steps[len(steps)-1]
+	}
+	switch {
+	case err == nil && (pkg.Exports != nil || e.Subpath != "."):
+	case e.Subpath == "." && pkg.Main != "":
+		target = r.mainTarget(e.Package, pkgPath, pkg)
+		if target != strings.TrimPrefix(pkg.Main, "./") {
+			e.step("main %q resolves to %s", pkg.Main, target)
+		}
+	case e.Subpath == ".":
+		target = "index.js"
+		e.step("falling back to index.js")
+	default:
+		target = strings.TrimPrefix(e.Subpath, "./")
+		e.step("falling back to the file at %s, which mappa maps directly", target)
+	}
+	e.Target = target
+
+	if err := r.checkTarget(e.Package, specifier, target); err != nil {
+		return e.fail("%v", err)
+	}
+
+	file := filepath.Join(pkgPath, filepath.FromSlash(target))
+	e.File = displayPath(rootDir, file)
+	if linked {
+		e.URL = r.inlineURL(pkgPath, target, webPath+"/"+target)
+	} else {
+		version, err := r.packageVersion(e.Package, pkg)
+		if err != nil {
+			return e.fail("%v", err)
+		}
+		e.URL = r.moduleURL(e.Package, version, pkgPath, target)
+	}
+
+	if !r.isFile(file) {
+		if notExported != "" {
+			return e.fail("%s is not exported: %s", specifier, notExported)
+		}
+		return e.fail("%s does not exist in %s", target, e.Package)
+	}
+	return e
+}
+
+// displayPath returns path relative to rootDir, with forward slashes.
+func displayPath(rootDir, path string) string {
+	rel, err := filepath.Rel(rootDir, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
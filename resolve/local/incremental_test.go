@@ -19,8 +19,11 @@ package local_test
 import (
 	"reflect"
 	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"bennypowers.dev/mappa/packagejson"
 	"bennypowers.dev/mappa/resolve"
 	"bennypowers.dev/mappa/resolve/local"
 	"bennypowers.dev/mappa/testutil"
@@ -112,6 +115,72 @@ func TestResolveIncrementalChangedPackage(t *testing.T) {
 	}
 }
 
+func TestResolveIncrementalUnchangedContent(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "resolve/with-scopes", "/test")
+	logger := &mockLogger{}
+	resolver := local.New(mfs, logger).WithPackageCache(packagejson.NewMemoryCache())
+
+	result, err := resolver.ResolveWithGraph("/test")
+	if err != nil {
+		t.Fatalf("Initial ResolveWithGraph failed: %v", err)
+	}
+	const litJSON = "/test/node_modules/lit/package.json"
+	original, err := mfs.ReadFile(litJSON)
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	update := func(content string, mtime time.Time) {
+		t.Helper()
+		mfs.SetModTime(mtime)
+		if err := mfs.WriteFile(litJSON, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write package.json: %v", err)
+		}
+		result, err = resolver.ResolveIncremental("/test", resolve.IncrementalUpdate{
+			ChangedPackages: []string{"lit"},
+			PreviousMap:     result.ImportMap,
+			PreviousGraph:   result.DependencyGraph,
+		})
+		if err != nil {
+			t.Fatalf("ResolveIncremental failed: %v", err)
+		}
+	}
+	skipped := func() int {
+		n := 0
+		for _, msg := range logger.debugs {
+			if strings.Contains(msg, "package.json is unchanged") {
+				n++
+			}
+		}
+		return n
+	}
+
+	// The first change records the file's stamp
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	update(string(original), t0)
+	if skipped() != 0 {
+		t.Errorf("Expected lit to be re-resolved on its first change, got %v", logger.debugs)
+	}
+
+	// An editor touching the file doesn't re-resolve lit
+	update(string(original), t0.Add(time.Second))
+	if skipped() != 1 {
+		t.Errorf("Expected lit to be skipped when only its mtime changed, got %v", logger.debugs)
+	}
+	if result.ImportMap.Imports["lit"] != "/node_modules/lit/index.js" {
+		t.Errorf("Expected lit import to be kept, got %v", result.ImportMap.Imports)
+	}
+
+	// A real edit is picked up
+	update(strings.Replace(string(original), "./index.js", "./lit.js", 1), t0.Add(2*time.Second))
+	if skipped() != 1 {
+		t.Errorf("Expected edited lit to be re-resolved, got %v", logger.debugs)
+	}
+	if result.ImportMap.Imports["lit"] != "/node_modules/lit/lit.js" {
+		t.Errorf("Expected edited lit export, got %v", result.ImportMap.Imports)
+	}
+}
+
 func TestResolveIncrementalEmptyChanges(t *testing.T) {
 	mfs := testutil.NewFixtureFS(t, "resolve/with-scopes", "/test")
 
@@ -152,6 +221,27 @@ func TestDependencyGraphDependents(t *testing.T) {
 	}
 }
 
+func TestDependencyGraphNodes(t *testing.T) {
+	graph := resolve.NewDependencyGraph()
+
+	// Workspace package "app" depends on "lit", which depends on "lit-html"
+	graph.AddWorkspacePackage("app")
+	graph.SetPackagePath("app", "/repo/packages/app")
+	graph.AddDependency("app", "lit")
+	graph.SetPackagePath("lit", "/repo/node_modules/lit")
+	graph.SetScopeKey("lit", "/node_modules/lit/")
+	graph.AddDependency("lit", "lit-html")
+
+	expected := []resolve.GraphNode{
+		{Name: "app", Path: "/repo/packages/app", Workspace: true},
+		{Name: "lit", Path: "/repo/node_modules/lit", ScopeKey: "/node_modules/lit/", Dependents: []string{"app"}},
+		{Name: "lit-html", Dependents: []string{"lit"}},
+	}
+	if nodes := graph.Nodes(); !reflect.DeepEqual(nodes, expected) {
+		t.Errorf("Nodes() mismatch:\n  got:      %+v\n  expected: %+v", nodes, expected)
+	}
+}
+
 func TestDependencyGraphTransitiveDependents(t *testing.T) {
 	graph := resolve.NewDependencyGraph()
 
@@ -230,3 +320,58 @@ func TestDependencyGraphRemovePackage(t *testing.T) {
 		t.Errorf("Expected C to no longer have B as dependent, got %v", deps)
 	}
 }
+
+func TestResolveIncrementalWorkspacePackagePnP(t *testing.T) {
+	// Yarn Plug'n'Play data lives at the workspace root, above the package
+	mfs := testutil.NewFixtureFS(t, "resolve/yarn-pnp", "/test")
+	mfs.AddFile("/test/packages/app/package.json", `{"name": "app", "version": "1.0.0", "dependencies": {"lit": "^3.0.0"}}`, 0644)
+
+	resolver := local.New(mfs, nil)
+
+	initial, err := resolver.ResolveWithGraph("/test/packages/app")
+	if err != nil {
+		t.Fatalf("Initial ResolveWithGraph failed: %v", err)
+	}
+	if initial.ImportMap.Imports["lit"] != "/node_modules/lit/index.js" {
+		t.Fatalf("Expected lit import, got %v", initial.ImportMap.Imports)
+	}
+
+	result, err := resolver.ResolveIncremental("/test/packages/app", resolve.IncrementalUpdate{
+		ChangedPackages: []string{"lit"},
+		PreviousMap:     initial.ImportMap,
+		PreviousGraph:   initial.DependencyGraph,
+	})
+	if err != nil {
+		t.Fatalf("ResolveIncremental failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(result.ImportMap, initial.ImportMap) {
+		t.Errorf("Incremental map differs from full resolution:\n  got:      %v\n  expected: %v", result.ImportMap, initial.ImportMap)
+	}
+}
+
+func TestResolveIncrementalDetectCycles(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "resolve/dependency-cycle", "/test")
+
+	logger := &mockLogger{}
+	resolver := local.New(mfs, logger).WithDetectCycles(true)
+	initial, err := resolver.ResolveWithGraph("/test")
+	if err != nil {
+		t.Fatalf("Initial ResolveWithGraph failed: %v", err)
+	}
+
+	logger.warnings = nil
+	_, err = resolver.ResolveIncremental("/test", resolve.IncrementalUpdate{
+		ChangedPackages: []string{"pkg-b"},
+		PreviousMap:     initial.ImportMap,
+		PreviousGraph:   initial.DependencyGraph,
+	})
+	if err != nil {
+		t.Fatalf("ResolveIncremental failed: %v", err)
+	}
+
+	expected := []string{"cycle: pkg-a -> pkg-b -> pkg-a"}
+	if !reflect.DeepEqual(logger.warnings, expected) {
+		t.Errorf("Expected warnings %v, got %v", expected, logger.warnings)
+	}
+}
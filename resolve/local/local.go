@@ -18,17 +18,42 @@ along with this program. If not, see <http://www.gnu.org/licenses/>.
 package local
 
 import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	iofs "io/fs"
 	"maps"
+	"path"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 	"sync"
 
 	"bennypowers.dev/mappa/fs"
 	"bennypowers.dev/mappa/importmap"
+	"bennypowers.dev/mappa/internal/semver"
 	"bennypowers.dev/mappa/packagejson"
 	"bennypowers.dev/mappa/resolve"
 )
 
+// ErrMissingVersion is returned when the URL template uses {version} but a
+// package's package.json has no version field.
+var ErrMissingVersion = errors.New("package.json has no version field")
+
+// ErrPathEscapesPackage is returned in strict mode when an export target
+// resolves outside its package directory.
+var ErrPathEscapesPackage = errors.New("export target escapes the package directory")
+
+// ErrUnresolvedSpecifier is returned by ResolveSpecifiersStrict for a bare
+// specifier that doesn't resolve to an existing file in its installed package.
+var ErrUnresolvedSpecifier = errors.New("unresolvable bare specifier")
+
+// isFatal reports whether err should abort resolution rather than skip a package.
+func isFatal(err error) bool {
+	return errors.Is(err, ErrMissingVersion) || errors.Is(err, ErrPathEscapesPackage)
+}
+
 // Resolver generates import maps pointing to local node_modules paths.
 type Resolver struct {
 	fs                 fs.FileSystem
@@ -40,8 +65,18 @@ type Resolver struct {
 	includeRootExports bool
 	cache              packagejson.Cache
 	conditions         []string // export condition priority
+	inlineBelow        int      // inline modules smaller than this many bytes as data: URLs
+	detectCycles       bool     // report dependency cycles as warnings
+	dedupeReport       bool     // report packages installed at more than one version
+	allowSubpathOnly   bool     // don't warn about packages that only export subpaths
+	concurrency        int      // maximum packages resolved in parallel (0 = DefaultConcurrency)
+	strictPaths        bool     // fail on export targets that escape the package directory
+	engineTarget       string   // Node.js version to check packages' engines.node against, if set
 }
 
+// DefaultConcurrency is the default number of packages resolved in parallel.
+const DefaultConcurrency = 10
+
 // New creates a new local Resolver.
 func New(fs fs.FileSystem, logger resolve.Logger) *Resolver {
 	// DefaultLocalTemplate is a known-valid constant; error is impossible
@@ -66,6 +101,13 @@ func (r *Resolver) WithPackages(packages []string) *Resolver {
 		includeRootExports: r.includeRootExports,
 		cache:              r.cache,
 		conditions:         r.conditions,
+		inlineBelow:        r.inlineBelow,
+		detectCycles:       r.detectCycles,
+		dedupeReport:       r.dedupeReport,
+		allowSubpathOnly:   r.allowSubpathOnly,
+		concurrency:        r.concurrency,
+		strictPaths:        r.strictPaths,
+		engineTarget:       r.engineTarget,
 	}
 }
 
@@ -85,6 +127,13 @@ func (r *Resolver) WithTemplate(pattern string) (*Resolver, error) {
 		includeRootExports: r.includeRootExports,
 		cache:              r.cache,
 		conditions:         r.conditions,
+		inlineBelow:        r.inlineBelow,
+		detectCycles:       r.detectCycles,
+		dedupeReport:       r.dedupeReport,
+		allowSubpathOnly:   r.allowSubpathOnly,
+		concurrency:        r.concurrency,
+		strictPaths:        r.strictPaths,
+		engineTarget:       r.engineTarget,
 	}, nil
 }
 
@@ -101,6 +150,13 @@ func (r *Resolver) WithInputMap(im *importmap.ImportMap) *Resolver {
 		includeRootExports: r.includeRootExports,
 		cache:              r.cache,
 		conditions:         r.conditions,
+		inlineBelow:        r.inlineBelow,
+		detectCycles:       r.detectCycles,
+		dedupeReport:       r.dedupeReport,
+		allowSubpathOnly:   r.allowSubpathOnly,
+		concurrency:        r.concurrency,
+		strictPaths:        r.strictPaths,
+		engineTarget:       r.engineTarget,
 	}
 }
 
@@ -118,6 +174,13 @@ func (r *Resolver) WithWorkspacePackages(packages []resolve.WorkspacePackage) *R
 		includeRootExports: r.includeRootExports,
 		cache:              r.cache,
 		conditions:         r.conditions,
+		inlineBelow:        r.inlineBelow,
+		detectCycles:       r.detectCycles,
+		dedupeReport:       r.dedupeReport,
+		allowSubpathOnly:   r.allowSubpathOnly,
+		concurrency:        r.concurrency,
+		strictPaths:        r.strictPaths,
+		engineTarget:       r.engineTarget,
 	}
 }
 
@@ -135,6 +198,13 @@ func (r *Resolver) WithIncludeRootExports() *Resolver {
 		includeRootExports: true,
 		cache:              r.cache,
 		conditions:         r.conditions,
+		inlineBelow:        r.inlineBelow,
+		detectCycles:       r.detectCycles,
+		dedupeReport:       r.dedupeReport,
+		allowSubpathOnly:   r.allowSubpathOnly,
+		concurrency:        r.concurrency,
+		strictPaths:        r.strictPaths,
+		engineTarget:       r.engineTarget,
 	}
 }
 
@@ -153,6 +223,13 @@ func (r *Resolver) WithPackageCache(cache packagejson.Cache) *Resolver {
 		includeRootExports: r.includeRootExports,
 		cache:              cache,
 		conditions:         r.conditions,
+		inlineBelow:        r.inlineBelow,
+		detectCycles:       r.detectCycles,
+		dedupeReport:       r.dedupeReport,
+		allowSubpathOnly:   r.allowSubpathOnly,
+		concurrency:        r.concurrency,
+		strictPaths:        r.strictPaths,
+		engineTarget:       r.engineTarget,
 	}
 }
 
@@ -170,9 +247,287 @@ func (r *Resolver) WithConditions(conditions []string) *Resolver {
 		includeRootExports: r.includeRootExports,
 		cache:              r.cache,
 		conditions:         conditions,
+		inlineBelow:        r.inlineBelow,
+		detectCycles:       r.detectCycles,
+		dedupeReport:       r.dedupeReport,
+		allowSubpathOnly:   r.allowSubpathOnly,
+		concurrency:        r.concurrency,
+		strictPaths:        r.strictPaths,
+		engineTarget:       r.engineTarget,
 	}
 }
 
+// WithInlineBelow returns a new Resolver that inlines JavaScript modules smaller
+// than size bytes as base64 data: URLs instead of mapping them to paths, saving
+// a request per module. Only entries that map to a single file are inlined.
+// Inlined modules have no base URL, so they must be self-contained: relative
+// imports inside them will not resolve, and scopes do not apply to their bare
+// imports. A size of 0 disables inlining.
+func (r *Resolver) WithInlineBelow(size int) *Resolver {
+	return &Resolver{
+		fs:                 r.fs,
+		logger:             r.logger,
+		additionalPackages: r.additionalPackages,
+		template:           r.template,
+		inputMap:           r.inputMap,
+		workspacePackages:  r.workspacePackages,
+		includeRootExports: r.includeRootExports,
+		cache:              r.cache,
+		conditions:         r.conditions,
+		inlineBelow:        size,
+		detectCycles:       r.detectCycles,
+		dedupeReport:       r.dedupeReport,
+		allowSubpathOnly:   r.allowSubpathOnly,
+		concurrency:        r.concurrency,
+		strictPaths:        r.strictPaths,
+		engineTarget:       r.engineTarget,
+	}
+}
+
+// WithDetectCycles returns a new Resolver that, when detect is true, reports
+// cycles among node_modules dependencies as logger warnings such as
+// "cycle: a -> b -> a". Cycles don't affect the import map; detection costs an
+// extra traversal of the dependency tree, so it is off by default.
+func (r *Resolver) WithDetectCycles(detect bool) *Resolver {
+	return &Resolver{
+		fs:                 r.fs,
+		logger:             r.logger,
+		additionalPackages: r.additionalPackages,
+		template:           r.template,
+		inputMap:           r.inputMap,
+		workspacePackages:  r.workspacePackages,
+		includeRootExports: r.includeRootExports,
+		cache:              r.cache,
+		conditions:         r.conditions,
+		inlineBelow:        r.inlineBelow,
+		detectCycles:       detect,
+		dedupeReport:       r.dedupeReport,
+		allowSubpathOnly:   r.allowSubpathOnly,
+		concurrency:        r.concurrency,
+		strictPaths:        r.strictPaths,
+		engineTarget:       r.engineTarget,
+	}
+}
+
+// WithDedupeReport returns a new Resolver that, when report is true, reports
+// each package installed at more than one version anywhere in node_modules,
+// including nested node_modules directories, as a logger warning listing the
+// paths of each version. Like cycle detection, this costs an extra traversal.
+func (r *Resolver) WithDedupeReport(report bool) *Resolver {
+	return &Resolver{
+		fs:                 r.fs,
+		logger:             r.logger,
+		additionalPackages: r.additionalPackages,
+		template:           r.template,
+		inputMap:           r.inputMap,
+		workspacePackages:  r.workspacePackages,
+		includeRootExports: r.includeRootExports,
+		cache:              r.cache,
+		conditions:         r.conditions,
+		inlineBelow:        r.inlineBelow,
+		detectCycles:       r.detectCycles,
+		dedupeReport:       report,
+		allowSubpathOnly:   r.allowSubpathOnly,
+		concurrency:        r.concurrency,
+		strictPaths:        r.strictPaths,
+		engineTarget:       r.engineTarget,
+	}
+}
+
+// WithAllowSubpathOnly returns a new Resolver that doesn't warn about packages
+// whose exports define subpaths but no "." entry. Such packages have no main
+// module by design, so their subpath entries are mapped without a bare entry.
+// Packages with neither exports nor a main field are still reported.
+func (r *Resolver) WithAllowSubpathOnly(allow bool) *Resolver {
+	return &Resolver{
+		fs:                 r.fs,
+		logger:             r.logger,
+		additionalPackages: r.additionalPackages,
+		template:           r.template,
+		inputMap:           r.inputMap,
+		workspacePackages:  r.workspacePackages,
+		includeRootExports: r.includeRootExports,
+		cache:              r.cache,
+		conditions:         r.conditions,
+		inlineBelow:        r.inlineBelow,
+		detectCycles:       r.detectCycles,
+		dedupeReport:       r.dedupeReport,
+		allowSubpathOnly:   allow,
+		concurrency:        r.concurrency,
+		strictPaths:        r.strictPaths,
+		engineTarget:       r.engineTarget,
+	}
+}
+
+// WithConcurrency returns a new Resolver that resolves at most n packages in
+// parallel, e.g. more on fast disks with large trees or fewer on constrained
+// CI machines. Values below 1 use DefaultConcurrency.
+func (r *Resolver) WithConcurrency(n int) *Resolver {
+	return &Resolver{
+		fs:                 r.fs,
+		logger:             r.logger,
+		additionalPackages: r.additionalPackages,
+		template:           r.template,
+		inputMap:           r.inputMap,
+		workspacePackages:  r.workspacePackages,
+		includeRootExports: r.includeRootExports,
+		cache:              r.cache,
+		conditions:         r.conditions,
+		inlineBelow:        r.inlineBelow,
+		detectCycles:       r.detectCycles,
+		dedupeReport:       r.dedupeReport,
+		allowSubpathOnly:   r.allowSubpathOnly,
+		concurrency:        n,
+		strictPaths:        r.strictPaths,
+		engineTarget:       r.engineTarget,
+	}
+}
+
+// WithStrictPaths returns a new Resolver that fails when a package's export
+// target escapes the package directory (e.g. "../secret.js"), instead of
+// logging a warning and mapping it anyway. Resolve returns an error wrapping
+// ErrPathEscapesPackage; ResolveSpecifiers omits the offending specifiers.
+func (r *Resolver) WithStrictPaths(strict bool) *Resolver {
+	return &Resolver{
+		fs:                 r.fs,
+		logger:             r.logger,
+		additionalPackages: r.additionalPackages,
+		template:           r.template,
+		inputMap:           r.inputMap,
+		workspacePackages:  r.workspacePackages,
+		includeRootExports: r.includeRootExports,
+		cache:              r.cache,
+		conditions:         r.conditions,
+		inlineBelow:        r.inlineBelow,
+		detectCycles:       r.detectCycles,
+		dedupeReport:       r.dedupeReport,
+		allowSubpathOnly:   r.allowSubpathOnly,
+		concurrency:        r.concurrency,
+		strictPaths:        strict,
+		engineTarget:       r.engineTarget,
+	}
+}
+
+// WithEngineCheck returns a new Resolver that warns about each package whose
+// package.json engines.node range excludes the given Node.js version (e.g.
+// "18.19.0"), for builds whose modules also run under that version. Packages
+// without an engines.node range, or with one that can't be parsed, are not
+// reported. An empty version disables the check.
+func (r *Resolver) WithEngineCheck(version string) *Resolver {
+	return &Resolver{
+		fs:                 r.fs,
+		logger:             r.logger,
+		additionalPackages: r.additionalPackages,
+		template:           r.template,
+		inputMap:           r.inputMap,
+		workspacePackages:  r.workspacePackages,
+		includeRootExports: r.includeRootExports,
+		cache:              r.cache,
+		conditions:         r.conditions,
+		inlineBelow:        r.inlineBelow,
+		detectCycles:       r.detectCycles,
+		dedupeReport:       r.dedupeReport,
+		allowSubpathOnly:   r.allowSubpathOnly,
+		concurrency:        r.concurrency,
+		strictPaths:        r.strictPaths,
+		engineTarget:       version,
+	}
+}
+
+// checkEngines logs a warning if pkg declares an engines.node range that
+// excludes the target version set by WithEngineCheck.
+func (r *Resolver) checkEngines(pkgName string, pkg *packagejson.PackageJSON) {
+	nodeRange := pkg.Engines["node"]
+	if r.engineTarget == "" || nodeRange == "" || r.logger == nil {
+		return
+	}
+	if ok, err := semver.Satisfies(r.engineTarget, nodeRange); err == nil && !ok {
+		r.logger.Warning("Package '%s' requires node %s, which excludes %s", pkgName, nodeRange, r.engineTarget)
+	}
+}
+
+// checkTarget reports whether a resolved export target stays within its
+// package directory. Escaping targets are logged as warnings, or returned as
+// an error wrapping ErrPathEscapesPackage in strict mode.
+func (r *Resolver) checkTarget(pkgName, specifier, target string) error {
+	cleaned := filepath.Clean(filepath.FromSlash(target))
+	if cleaned != ".." && !strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) && !filepath.IsAbs(cleaned) {
+		return nil
+	}
+	err := fmt.Errorf("%s resolves to %q: %w", specifier, target, ErrPathEscapesPackage)
+	if r.strictPaths {
+		return err
+	}
+	if r.logger != nil {
+		r.logger.Warning("Package '%s': %v", pkgName, err)
+	}
+	return nil
+}
+
+// checkWildcard is checkTarget for a wildcard export, whose target pattern
+// must stay within the package directory for every match.
+func (r *Resolver) checkWildcard(pkgName string, w packagejson.WildcardExport) error {
+	return r.checkTarget(pkgName, pkgName+"/"+strings.TrimPrefix(w.Pattern, "./"), w.Target+"*"+w.Suffix)
+}
+
+// mainTarget returns the package-relative path of pkg's main module. The main
+// field is used if it names an existing file, trying the .js extension and an
+// index.js inside it as Node does. A stale main falls back to the "." export
+// under the default conditions, then to index.js, and is kept as a last resort.
+func (r *Resolver) mainTarget(pkgName, pkgPath string, pkg *packagejson.PackageJSON) string {
+	main := strings.TrimSuffix(strings.TrimPrefix(pkg.Main, "./"), "/")
+	for _, candidate := range []string{main, main + ".js", main + "/index.js"} {
+		if r.isFile(filepath.Join(pkgPath, filepath.FromSlash(candidate))) {
+			return candidate
+		}
+	}
+
+	fallback := main
+	if exported, err := pkg.ResolveExport(".", nil); err == nil && r.isFile(filepath.Join(pkgPath, filepath.FromSlash(exported))) {
+		fallback = exported
+	} else if r.isFile(filepath.Join(pkgPath, "index.js")) {
+		fallback = "index.js"
+	}
+	if r.logger != nil {
+		if fallback != main {
+			r.logger.Warning("Package '%s': main %q does not exist, using %s", pkgName, pkg.Main, fallback)
+		} else {
+			r.logger.Warning("Package '%s': main %q does not exist", pkgName, pkg.Main)
+		}
+	}
+	return fallback
+}
+
+// exportEntries returns pkg's export entries. For packages without an exports
+// field, the "." entry comes from the main field and is checked with mainTarget.
+func (r *Resolver) exportEntries(pkgName, pkgPath string, pkg *packagejson.PackageJSON, opts *packagejson.ResolveOptions) []packagejson.ExportEntry {
+	entries := pkg.ExportEntries(opts)
+	if pkg.Exports != nil || pkg.Main == "" {
+		return entries
+	}
+	for i, entry := range entries {
+		if entry.Subpath == "." {
+			entries[i].Target = r.mainTarget(pkgName, pkgPath, pkg)
+		}
+	}
+	return entries
+}
+
+// isFile reports whether path exists and is not a directory.
+func (r *Resolver) isFile(path string) bool {
+	info, err := r.fs.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// semaphore returns a channel limiting parallel package resolution to the
+// configured concurrency.
+func (r *Resolver) semaphore() chan struct{} {
+	if r.concurrency < 1 {
+		return make(chan struct{}, DefaultConcurrency)
+	}
+	return make(chan struct{}, r.concurrency)
+}
+
 // resolveOpts returns ResolveOptions for the configured conditions.
 func (r *Resolver) resolveOpts() *packagejson.ResolveOptions {
 	if len(r.conditions) == 0 {
@@ -181,6 +536,45 @@ func (r *Resolver) resolveOpts() *packagejson.ResolveOptions {
 	return &packagejson.ResolveOptions{Conditions: r.conditions}
 }
 
+// withPnP returns a Resolver that reads packages through Yarn Plug'n'Play
+// data when workspaceRoot has any, so node_modules paths resolve to the
+// locations Yarn recorded. Returns r unchanged for ordinary installs.
+func (r *Resolver) withPnP(workspaceRoot string) *Resolver {
+	if _, ok := r.fs.(*resolve.PnPFileSystem); ok {
+		return r
+	}
+	manifest, err := resolve.LoadPnPManifest(r.fs, workspaceRoot)
+	if err != nil {
+		if r.logger != nil {
+			r.logger.Warning("Failed to read Plug'n'Play data: %v", err)
+		}
+		return r
+	}
+	if manifest == nil {
+		return r
+	}
+	pnp := *r
+	pnp.fs = resolve.NewPnPFileSystem(r.fs, filepath.Join(workspaceRoot, "node_modules"), manifest)
+	return &pnp
+}
+
+// withPnpm returns a Resolver that reads packages missing from the top level
+// of workspaceRoot's node_modules from its pnpm virtual store, so transitive
+// dependencies resolve as if node_modules were flat. Returns r unchanged for
+// other installs.
+func (r *Resolver) withPnpm(workspaceRoot string) *Resolver {
+	if _, ok := r.fs.(*resolve.PnpmFileSystem); ok {
+		return r
+	}
+	nodeModulesPath := filepath.Join(workspaceRoot, "node_modules")
+	if !resolve.HasPnpm(r.fs, nodeModulesPath) {
+		return r
+	}
+	pnpm := *r
+	pnpm.fs = resolve.NewPnpmFileSystem(r.fs, nodeModulesPath)
+	return &pnpm
+}
+
 // parsePackageJSON parses a package.json file, using the cache if available.
 // Uses atomic GetOrLoad to ensure only one goroutine parses a given file.
 func (r *Resolver) parsePackageJSON(path string) (*packagejson.PackageJSON, error) {
@@ -205,12 +599,33 @@ func (r *Resolver) Resolve(rootDir string) (*importmap.ImportMap, error) {
 // When a package supports trailing-slash exports, uses a single trailing-slash
 // key instead of individual entries for each subpath.
 func (r *Resolver) ResolveSpecifiers(rootDir string, specifiers []string) map[string]string {
+	result, _ := r.resolveSpecifiers(rootDir, specifiers, false)
+	return result
+}
+
+// ResolveSpecifiersStrict resolves specifiers like ResolveSpecifiers and also
+// returns an error wrapping ErrUnresolvedSpecifier for each one that doesn't
+// resolve to an existing file: its package isn't installed, or neither its
+// export target nor the direct subpath it falls back to exists. Such
+// specifiers still get their best-effort entries in the result.
+func (r *Resolver) ResolveSpecifiersStrict(rootDir string, specifiers []string) (map[string]string, []error) {
+	return r.resolveSpecifiers(rootDir, specifiers, true)
+}
+
+// resolveSpecifiers implements ResolveSpecifiers, checking that each
+// specifier's file exists when strict is set.
+func (r *Resolver) resolveSpecifiers(rootDir string, specifiers []string, strict bool) (map[string]string, []error) {
 	result := make(map[string]string)
 	if len(specifiers) == 0 {
-		return result
+		return result, nil
+	}
+	var unresolved []error
+	unresolve := func(spec, reason string) {
+		unresolved = append(unresolved, fmt.Errorf("%s: %s: %w", spec, reason, ErrUnresolvedSpecifier))
 	}
 
 	workspaceRoot := resolve.FindWorkspaceRoot(r.fs, rootDir)
+	r = r.withPnP(workspaceRoot).withPnpm(workspaceRoot)
 	nodeModulesPath := filepath.Join(workspaceRoot, "node_modules")
 	opts := r.resolveOpts()
 
@@ -233,6 +648,13 @@ func (r *Resolver) ResolveSpecifiers(rootDir string, specifiers []string) map[st
 			}
 			// Fall back to individual entries without exports resolution
 			for _, spec := range specs {
+				if strict {
+					if r.fs.Exists(pkgJSONPath) {
+						unresolve(spec, fmt.Sprintf("could not parse package.json for %s", pkgName))
+					} else {
+						unresolve(spec, fmt.Sprintf("package %s is not installed", pkgName))
+					}
+				}
 				subpath := strings.TrimPrefix(spec, pkgName)
 				if subpath == "" {
 					result[spec] = r.template.Expand(pkgName, "", "index.js")
@@ -243,53 +665,91 @@ func (r *Resolver) ResolveSpecifiers(rootDir string, specifiers []string) map[st
 			continue
 		}
 
+		// Linked packages are served from their source directory; others
+		// from the template
+		var expand func(target string) string
+		if _, webPath, ok := r.linkedPackage(pkgPath, workspaceRoot); ok {
+			expand = func(target string) string {
+				return webPath + "/" + strings.TrimPrefix(target, "./")
+			}
+		} else {
+			version, err := r.packageVersion(pkgName, pkg)
+			if err != nil {
+				if r.logger != nil {
+					r.logger.Warning("%v", err)
+				}
+				if strict {
+					for _, spec := range specs {
+						unresolve(spec, err.Error())
+					}
+				}
+				continue
+			}
+			expand = func(target string) string {
+				return r.template.Expand(pkgName, version, target)
+			}
+		}
+
 		// Check for wildcard exports and create trailing-slash keys for each
 		wildcards := pkg.WildcardExports(opts)
 		trailingSlashPrefixes := make(map[string]bool) // track which prefixes have trailing-slash keys
 
 		// Add trailing-slash keys for each wildcard pattern
 		for _, w := range wildcards {
+			// Patterns with a suffix after the wildcard (e.g. "./*" -> "./*/index.js")
+			// can't be trailing-slash keys; their specifiers are resolved individually below
+			if !w.IsPrefixMapping() {
+				continue
+			}
 			// Pattern like "./*" or "./lib/*" -> key like "pkg/" or "pkg/lib/"
-			patternPrefix := strings.TrimSuffix(strings.TrimPrefix(w.Pattern, "./"), "*")
-			importKey := pkgName + "/" + patternPrefix
-			result[importKey] = r.template.Expand(pkgName, "", w.Target)
+			importKey := pkgName + "/" + w.ImportPrefix()
+			result[importKey] = expand(w.Target)
 			trailingSlashPrefixes[importKey] = true
 		}
 
 		// For packages with no exports, add trailing-slash key
 		if pkg.HasTrailingSlashExport(opts) && len(wildcards) == 0 {
-			result[pkgName+"/"] = r.template.Expand(pkgName, "", "")
+			result[pkgName+"/"] = expand("")
 			trailingSlashPrefixes[pkgName+"/"] = true
 		}
 
-		// Add entries for each specifier
+		// Collect the subpaths not covered by a trailing-slash key, so the
+		// package's exports are resolved for all of them in one pass
+		subpaths := make(map[string]string, len(specs))
 		for _, spec := range specs {
 			subpath := strings.TrimPrefix(spec, pkgName)
 			if subpath == "" {
-				subpath = "."
-			} else {
-				subpath = "." + subpath
+				subpaths[spec] = "."
+				continue
 			}
 
 			// Skip subpath entries if covered by any trailing-slash key
-			if subpath != "." {
-				covered := false
-				for prefix := range trailingSlashPrefixes {
-					// prefix ends with "/" so this checks if spec is a subpath
-					if strings.HasPrefix(spec, prefix) {
-						covered = true
-						break
-					}
-				}
-				if covered {
-					continue
+			covered := false
+			for prefix := range trailingSlashPrefixes {
+				// prefix ends with "/" so this checks if spec is a subpath
+				if strings.HasPrefix(spec, prefix) {
+					covered = true
+					break
 				}
 			}
+			if !covered {
+				subpaths[spec] = "." + subpath
+			} else if strict {
+				r.checkCoveredSpecifier(pkg, pkgPath, spec, "."+subpath, opts, unresolve)
+			}
+		}
+		exported := pkg.ResolveExports(slices.Collect(maps.Values(subpaths)), opts)
+
+		// Add entries for each specifier
+		for _, spec := range specs {
+			subpath, ok := subpaths[spec]
+			if !ok {
+				continue
+			}
 
 			// Resolve the specifier
 			var resolvedPath string
-			resolved, resolveErr := pkg.ResolveExport(subpath, opts)
-			if resolveErr == nil {
+			if resolved, ok := exported[subpath]; ok && (pkg.Exports != nil || subpath != ".") {
 				resolvedPath = resolved
 			}
 
@@ -297,7 +757,7 @@ func (r *Resolver) ResolveSpecifiers(rootDir string, specifiers []string) map[st
 			if resolvedPath == "" {
 				if subpath == "." {
 					if pkg.Main != "" {
-						resolvedPath = strings.TrimPrefix(pkg.Main, "./")
+						resolvedPath = r.mainTarget(pkgName, pkgPath, pkg)
 					} else {
 						resolvedPath = "index.js"
 					}
@@ -306,11 +766,40 @@ func (r *Resolver) ResolveSpecifiers(rootDir string, specifiers []string) map[st
 				}
 			}
 
-			result[spec] = r.template.Expand(pkgName, "", resolvedPath)
+			if err := r.checkTarget(pkgName, spec, resolvedPath); err != nil {
+				if r.logger != nil {
+					r.logger.Warning("Skipping %v", err)
+				}
+				continue
+			}
+			if strict && !r.fs.Exists(filepath.Join(pkgPath, filepath.FromSlash(resolvedPath))) {
+				unresolve(spec, fmt.Sprintf("%s does not exist in %s", resolvedPath, pkgName))
+			}
+			result[spec] = r.inlineURL(pkgPath, resolvedPath, expand(resolvedPath))
 		}
 	}
 
-	return result
+	slices.SortFunc(unresolved, func(a, b error) int {
+		return strings.Compare(a.Error(), b.Error())
+	})
+	return result, unresolved
+}
+
+// checkCoveredSpecifier reports a specifier mapped by a trailing-slash key
+// whose file doesn't exist, resolving it through the package's export
+// patterns or, for packages without exports, as a direct subpath.
+func (r *Resolver) checkCoveredSpecifier(pkg *packagejson.PackageJSON, pkgPath, spec, subpath string, opts *packagejson.ResolveOptions, unresolve func(spec, reason string)) {
+	target, err := pkg.ResolveExport(subpath, opts)
+	if err != nil {
+		if pkg.Exports != nil {
+			unresolve(spec, fmt.Sprintf("%s is not exported", subpath))
+			return
+		}
+		target = strings.TrimPrefix(subpath, "./")
+	}
+	if !r.fs.Exists(filepath.Join(pkgPath, filepath.FromSlash(target))) {
+		unresolve(spec, fmt.Sprintf("%s does not exist in %s", target, pkg.Name))
+	}
 }
 
 // ResolveWithGraph generates an ImportMap and builds a DependencyGraph.
@@ -336,6 +825,10 @@ func (r *Resolver) resolveInternal(rootDir string, graph *resolve.DependencyGrap
 	}
 	rootDir = absRoot
 
+	// Find workspace root (may be different from rootDir in monorepos)
+	workspaceRoot := resolve.FindWorkspaceRoot(r.fs, rootDir)
+	r = r.withPnP(workspaceRoot).withPnpm(workspaceRoot)
+
 	// Use workspace mode if workspace packages are explicitly configured
 	if len(r.workspacePackages) > 0 {
 		return r.resolveWorkspaceInternal(rootDir, graph)
@@ -355,24 +848,18 @@ func (r *Resolver) resolveInternal(rootDir string, graph *resolve.DependencyGrap
 		Scopes:  make(map[string]map[string]string),
 	}
 
-	// Find workspace root (may be different from rootDir in monorepos)
-	workspaceRoot := resolve.FindWorkspaceRoot(r.fs, rootDir)
-
 	// Parse root package.json
 	rootPkgPath := filepath.Join(rootDir, "package.json")
 	rootPkg, err := r.parsePackageJSON(rootPkgPath)
 	if err != nil {
 		// No package.json - still apply input map if provided
-		if r.inputMap != nil {
-			return result.Merge(r.inputMap), graph, nil
-		}
-		return result, graph, nil
+		return r.mergeInputMap(result), graph, nil
 	}
 
 	// Add root package's own exports if requested
 	// This is useful for dev servers where you want to import the package by name
 	if r.includeRootExports && rootPkg.Name != "" {
-		if err := r.addRootPackageExports(result, rootPkg); err != nil {
+		if err := r.addRootPackageExports(result, rootPkg, rootDir); err != nil {
 			if r.logger != nil {
 				r.logger.Warning("Failed to add root package exports: %v", err)
 			}
@@ -393,8 +880,10 @@ func (r *Resolver) resolveInternal(rootDir string, graph *resolve.DependencyGrap
 	// Add direct dependencies to imports (parallel)
 	var wg sync.WaitGroup
 	var mu sync.Mutex
-	sem := make(chan struct{}, 10) // limit concurrency
+	var versionErr firstError
+	sem := r.semaphore()
 	nodeModulesPath := filepath.Join(workspaceRoot, "node_modules")
+	linked := make(map[string]bool)
 
 	for depName := range packagesToProcess {
 		wg.Add(1)
@@ -411,33 +900,67 @@ func (r *Resolver) resolveInternal(rootDir string, graph *resolve.DependencyGrap
 				return
 			}
 
+			if realPath, _, ok := r.linkedPackage(depPath, workspaceRoot); ok {
+				if err := r.addLinkedPackage(result, &mu, name, realPath, workspaceRoot, graph); err != nil {
+					if r.logger != nil {
+						r.logger.Warning("Failed to add linked package %s: %v", name, err)
+					}
+					return
+				}
+				mu.Lock()
+				linked[name] = true
+				mu.Unlock()
+				return
+			}
+
 			if err := r.addPackageToImportMapWithGraph(result, &mu, name, depPath, graph); err != nil {
-				if r.logger != nil {
+				if isFatal(err) {
+					versionErr.set(err)
+				} else if r.logger != nil {
 					r.logger.Warning("Failed to add package %s: %v", name, err)
 				}
 			}
 		}(depName)
 	}
 	wg.Wait()
+	if err := versionErr.get(); err != nil {
+		return nil, graph, err
+	}
 
 	// Add scopes for transitive dependencies
 	if err := r.addTransitiveDependenciesWithGraph(result, workspaceRoot, rootPkg, graph); err != nil {
+		if errors.Is(err, ErrMissingVersion) {
+			return nil, graph, err
+		}
 		if r.logger != nil {
 			r.logger.Warning("Failed to add transitive dependencies: %v", err)
 		}
 	}
 
+	if r.detectCycles {
+		r.reportCycles(nodeModulesPath, slices.Collect(maps.Keys(rootPkg.Dependencies)))
+	}
+	if r.dedupeReport {
+		r.reportDuplicates(workspaceRoot, []string{nodeModulesPath})
+	}
+
 	// Clean up empty scopes
 	if len(result.Scopes) == 0 {
 		result.Scopes = nil
 	}
 
-	// Merge with input map if provided (input map takes precedence)
-	if r.inputMap != nil {
-		result = result.Merge(r.inputMap)
-	}
+	recordProvenance(result, func(name string) string {
+		if r.includeRootExports && name == rootPkg.Name {
+			return importmap.ProvenanceRoot
+		}
+		if linked[name] {
+			return importmap.ProvenanceWorkspace
+		}
+		return importmap.ProvenanceDependency
+	})
 
-	return result, graph, nil
+	// Merge with input map if provided (input map takes precedence)
+	return r.mergeInputMap(result), graph, nil
 }
 
 // resolveWorkspaceInternal is the core workspace resolution logic, optionally tracking dependencies.
@@ -495,7 +1018,8 @@ func (r *Resolver) resolveWorkspaceInternal(rootDir string, graph *resolve.Depen
 	nodeModulesPath := filepath.Join(rootDir, "node_modules")
 	var wg sync.WaitGroup
 	var mu sync.Mutex
-	sem := make(chan struct{}, 10)
+	var versionErr firstError
+	sem := r.semaphore()
 
 	for depName := range allDeps {
 		wg.Add(1)
@@ -511,8 +1035,22 @@ func (r *Resolver) resolveWorkspaceInternal(rootDir string, graph *resolve.Depen
 				}
 				return
 			}
+			if realPath, _, ok := r.linkedPackage(depPath, rootDir); ok {
+				if err := r.addLinkedPackage(result, &mu, name, realPath, rootDir, graph); err != nil {
+					if r.logger != nil {
+						r.logger.Warning("Failed to add linked package %s: %v", name, err)
+					}
+					return
+				}
+				mu.Lock()
+				workspaceNames[name] = true
+				mu.Unlock()
+				return
+			}
 			if err := r.addPackageToImportMapWithGraph(result, &mu, name, depPath, graph); err != nil {
-				if r.logger != nil {
+				if isFatal(err) {
+					versionErr.set(err)
+				} else if r.logger != nil {
 					r.logger.Warning("Failed to add package %s: %v", name, err)
 				}
 			}
@@ -529,22 +1067,39 @@ func (r *Resolver) resolveWorkspaceInternal(rootDir string, graph *resolve.Depen
 			sem <- struct{}{}
 			defer func() { <-sem }()
 
-			r.processPackageDependenciesParallelWithGraph(result, &mu, &visited, nodeModulesPath, name, rootDir, graph)
+			versionErr.set(r.processPackageDependenciesParallelWithGraph(result, &mu, &visited, nodeModulesPath, name, filepath.Join(nodeModulesPath, name), rootDir, graph))
 		}(depName)
 	}
 	wg.Wait()
+	if err := versionErr.get(); err != nil {
+		return nil, graph, err
+	}
+
+	if r.detectCycles {
+		r.reportCycles(nodeModulesPath, slices.Collect(maps.Keys(allDeps)))
+	}
+	if r.dedupeReport {
+		dirs := []string{nodeModulesPath}
+		for _, pkg := range r.workspacePackages {
+			dirs = append(dirs, filepath.Join(pkg.Path, "node_modules"))
+		}
+		r.reportDuplicates(rootDir, dirs)
+	}
 
 	// Clean up empty scopes
 	if len(result.Scopes) == 0 {
 		result.Scopes = nil
 	}
 
-	// 5. Merge with input map if provided (input map takes precedence)
-	if r.inputMap != nil {
-		result = result.Merge(r.inputMap)
-	}
+	recordProvenance(result, func(name string) string {
+		if workspaceNames[name] {
+			return importmap.ProvenanceWorkspace
+		}
+		return importmap.ProvenanceDependency
+	})
 
-	return result, graph, nil
+	// 5. Merge with input map if provided (input map takes precedence)
+	return r.mergeInputMap(result), graph, nil
 }
 
 // addWorkspacePackageToImportMapWithGraph adds a workspace package's exports to the import map,
@@ -560,7 +1115,7 @@ func (r *Resolver) addWorkspacePackageToImportMapWithGraph(im *importmap.ImportM
 
 	// Get all export entries
 	opts := r.resolveOpts()
-	entries := pkgJSON.ExportEntries(opts)
+	entries := r.exportEntries(pkg.Name, pkg.Path, pkgJSON, opts)
 	for _, entry := range entries {
 		var importKey string
 		if entry.Subpath == "." {
@@ -570,21 +1125,21 @@ func (r *Resolver) addWorkspacePackageToImportMapWithGraph(im *importmap.ImportM
 			importKey = pkg.Name + "/" + subpath
 		}
 		target := strings.TrimPrefix(entry.Target, "./")
-		im.Imports[importKey] = webPath + "/" + target
+		im.Imports[importKey] = r.inlineURL(pkg.Path, target, webPath+"/"+target)
 	}
 
 	// Handle wildcard exports (trailing slash imports)
 	wildcards := pkgJSON.WildcardExports(opts)
 	for _, w := range wildcards {
-		patternPrefix := strings.TrimSuffix(strings.TrimPrefix(w.Pattern, "./"), "*")
-		importKey := pkg.Name + "/" + patternPrefix
-		target := strings.TrimSuffix(w.Target, "*")
-		im.Imports[importKey] = webPath + "/" + target
+		maps.Copy(im.Imports, r.wildcardImports(pkg.Name, pkg.Path, w, func(target string) string {
+			return r.inlineURL(pkg.Path, target, webPath+"/"+target)
+		}))
 	}
 
 	// Fallback to main if no exports
 	if len(entries) == 0 && pkgJSON.Main != "" {
-		im.Imports[pkg.Name] = webPath + "/" + strings.TrimPrefix(pkgJSON.Main, "./")
+		mainPath := r.mainTarget(pkg.Name, pkg.Path, pkgJSON)
+		im.Imports[pkg.Name] = r.inlineURL(pkg.Path, mainPath, webPath+"/"+mainPath)
 	}
 
 	// Add trailing slash for packages that support it
@@ -595,12 +1150,54 @@ func (r *Resolver) addWorkspacePackageToImportMapWithGraph(im *importmap.ImportM
 	return nil
 }
 
+// linkedPackage reports whether the package installed at pkgPath is a
+// symbolic link to a directory outside node_modules, as workspace tools and
+// npm link create, and returns that directory and its web path relative to
+// rootDir. Links into a package manager's store, and links leaving rootDir,
+// are treated as regular installs.
+func (r *Resolver) linkedPackage(pkgPath, rootDir string) (realPath, webPath string, ok bool) {
+	realPath, ok = fs.LinkTarget(r.fs, pkgPath)
+	if !ok || slices.Contains(strings.Split(filepath.ToSlash(realPath), "/"), "node_modules") {
+		return "", "", false
+	}
+	webPath = resolve.ToWebPath(rootDir, realPath)
+	return realPath, webPath, webPath != ""
+}
+
+// linkedImports returns the import map entries for a linked package, which
+// are web paths into its source directory like a workspace package's.
+func (r *Resolver) linkedImports(pkgName, realPath, rootDir string) (map[string]string, error) {
+	im := &importmap.ImportMap{Imports: make(map[string]string)}
+	pkg := resolve.WorkspacePackage{Name: pkgName, Path: realPath}
+	if err := r.addWorkspacePackageToImportMapWithGraph(im, pkg, rootDir, nil); err != nil {
+		return nil, err
+	}
+	return im.Imports, nil
+}
+
+// addLinkedPackage adds a linked package's entries to the import map under
+// mu and records it as a workspace package in the graph.
+func (r *Resolver) addLinkedPackage(im *importmap.ImportMap, mu *sync.Mutex, pkgName, realPath, rootDir string, graph *resolve.DependencyGraph) error {
+	imports, err := r.linkedImports(pkgName, realPath, rootDir)
+	if err != nil {
+		return err
+	}
+	if graph != nil {
+		graph.AddWorkspacePackage(pkgName)
+		graph.SetPackagePath(pkgName, realPath)
+	}
+	mu.Lock()
+	maps.Copy(im.Imports, imports)
+	mu.Unlock()
+	return nil
+}
+
 // addRootPackageExports adds the root package's own exports to the import map.
 // This allows importing the package by name in development (e.g., import { x } from 'my-lib').
-func (r *Resolver) addRootPackageExports(im *importmap.ImportMap, pkg *packagejson.PackageJSON) error {
+func (r *Resolver) addRootPackageExports(im *importmap.ImportMap, pkg *packagejson.PackageJSON, rootDir string) error {
 	// Get all export entries
 	opts := r.resolveOpts()
-	entries := pkg.ExportEntries(opts)
+	entries := r.exportEntries(pkg.Name, rootDir, pkg, opts)
 	for _, entry := range entries {
 		var importKey string
 		if entry.Subpath == "." {
@@ -610,22 +1207,22 @@ func (r *Resolver) addRootPackageExports(im *importmap.ImportMap, pkg *packagejs
 			importKey = pkg.Name + "/" + subpath
 		}
 		// Root package exports use relative paths from root (e.g., ./lib/index.js -> /lib/index.js)
-		target := "/" + strings.TrimPrefix(entry.Target, "./")
-		im.Imports[importKey] = target
+		target := strings.TrimPrefix(entry.Target, "./")
+		im.Imports[importKey] = r.inlineURL(rootDir, target, "/"+target)
 	}
 
 	// Handle wildcard exports
 	wildcards := pkg.WildcardExports(opts)
 	for _, w := range wildcards {
-		patternPrefix := strings.TrimSuffix(strings.TrimPrefix(w.Pattern, "./"), "*")
-		importKey := pkg.Name + "/" + patternPrefix
-		target := "/" + strings.TrimSuffix(strings.TrimPrefix(w.Target, "./"), "*")
-		im.Imports[importKey] = target
+		maps.Copy(im.Imports, r.wildcardImports(pkg.Name, rootDir, w, func(target string) string {
+			return r.inlineURL(rootDir, target, "/"+target)
+		}))
 	}
 
 	// Fallback to main if no exports
 	if len(entries) == 0 && pkg.Main != "" {
-		im.Imports[pkg.Name] = "/" + strings.TrimPrefix(pkg.Main, "./")
+		mainPath := r.mainTarget(pkg.Name, rootDir, pkg)
+		im.Imports[pkg.Name] = r.inlineURL(rootDir, mainPath, "/"+mainPath)
 	}
 
 	// Add trailing slash for packages that support it
@@ -645,16 +1242,56 @@ func (r *Resolver) addPackageToImportMapWithGraph(im *importmap.ImportMap, mu *s
 		return err
 	}
 
+	version, err := r.packageVersion(pkgName, pkg)
+	if err != nil {
+		return err
+	}
+
 	// Track package path in graph
 	if graph != nil {
 		graph.SetPackagePath(pkgName, pkgPath)
 	}
 
 	// Build entries locally
-	imports := make(map[string]string)
 	opts := r.resolveOpts()
+	imports, err := r.packageImports(pkgName, pkgPath, pkg, version, opts)
+	if err != nil {
+		return err
+	}
+	wildcards := pkg.WildcardExports(opts)
 
-	entries := pkg.ExportEntries(opts)
+	// Warn if bare specifier won't work (no root export and no main fallback),
+	// unless subpath-only packages are allowed and this one exports subpaths
+	if _, ok := imports[pkgName]; !ok {
+		if r.allowSubpathOnly && exportsSubpaths(pkg, pkg.ExportEntries(opts), wildcards) {
+			if r.logger != nil {
+				r.logger.Debug("Package '%s' has no root export; mapping subpaths only", pkgName)
+			}
+		} else if r.logger != nil {
+			r.logger.Warning("Package '%s' has %s", pkgName, noRootExportMessage)
+		}
+	}
+
+	// Add trailing slash for packages that support it
+	if pkg.HasTrailingSlashExport(opts) && len(wildcards) == 0 {
+		imports[pkgName+"/"] = r.template.Expand(pkgName, version, "")
+	}
+
+	// Merge into import map under lock
+	mu.Lock()
+	maps.Copy(im.Imports, imports)
+	mu.Unlock()
+
+	return nil
+}
+
+// packageImports returns the import map entries for the exports of the
+// package installed at pkgPath, falling back to its main field, without the
+// trailing-slash entry.
+func (r *Resolver) packageImports(pkgName, pkgPath string, pkg *packagejson.PackageJSON, version string, opts *packagejson.ResolveOptions) (map[string]string, error) {
+	imports := make(map[string]string)
+
+	entries := r.exportEntries(pkgName, pkgPath, pkg, opts)
 	for _, entry := range entries {
 		var importKey string
 		if entry.Subpath == "." {
@@ -663,39 +1300,40 @@ func (r *Resolver) addPackageToImportMapWithGraph(im *importmap.ImportMap, mu *s
 			subpath := strings.TrimPrefix(entry.Subpath, "./")
 			importKey = pkgName + "/" + subpath
 		}
-		imports[importKey] = r.template.Expand(pkgName, "", entry.Target)
+		if err := r.checkTarget(pkgName, importKey, entry.Target); err != nil {
+			return nil, err
+		}
+		imports[importKey] = r.moduleURL(pkgName, version, pkgPath, entry.Target)
 	}
 
-	wildcards := pkg.WildcardExports(opts)
-	for _, w := range wildcards {
-		patternPrefix := strings.TrimSuffix(strings.TrimPrefix(w.Pattern, "./"), "*")
-		importKey := pkgName + "/" + patternPrefix
-		imports[importKey] = r.template.Expand(pkgName, "", w.Target)
+	for _, w := range pkg.WildcardExports(opts) {
+		if err := r.checkWildcard(pkgName, w); err != nil {
+			return nil, err
+		}
+		maps.Copy(imports, r.wildcardImports(pkgName, pkgPath, w, func(target string) string {
+			return r.moduleURL(pkgName, version, pkgPath, target)
+		}))
 	}
 
 	// Fallback to main if no exports
 	if len(entries) == 0 && pkg.Main != "" {
-		imports[pkgName] = r.template.Expand(pkgName, "", strings.TrimPrefix(pkg.Main, "./"))
-	}
-
-	// Warn if bare specifier won't work (no root export and no main fallback)
-	if _, ok := imports[pkgName]; !ok {
-		if r.logger != nil {
-			r.logger.Warning("Package '%s' has no root export or main field; only subpath imports will work", pkgName)
+		main := r.mainTarget(pkgName, pkgPath, pkg)
+		if err := r.checkTarget(pkgName, pkgName, main); err != nil {
+			return nil, err
 		}
+		imports[pkgName] = r.moduleURL(pkgName, version, pkgPath, main)
 	}
 
-	// Add trailing slash for packages that support it
-	if pkg.HasTrailingSlashExport(opts) && len(wildcards) == 0 {
-		imports[pkgName+"/"] = r.template.Expand(pkgName, "", "")
-	}
+	return imports, nil
+}
 
-	// Merge into import map under lock
-	mu.Lock()
-	maps.Copy(im.Imports, imports)
-	mu.Unlock()
+// noRootExportMessage describes a package whose bare specifier can't be mapped.
+const noRootExportMessage = "no root export or main field; only subpath imports will work"
 
-	return nil
+// exportsSubpaths reports whether pkg's exports field maps any subpaths, given
+// its resolved entries and wildcard exports.
+func exportsSubpaths(pkg *packagejson.PackageJSON, entries []packagejson.ExportEntry, wildcards []packagejson.WildcardExport) bool {
+	return pkg.Exports != nil && (len(entries) > 0 || len(wildcards) > 0)
 }
 
 // addTransitiveDependenciesWithGraph adds scopes for packages that have their own dependencies,
@@ -704,10 +1342,11 @@ func (r *Resolver) addTransitiveDependenciesWithGraph(im *importmap.ImportMap, r
 	nodeModulesPath := filepath.Join(rootDir, "node_modules")
 
 	var (
-		mu      sync.Mutex
-		visited sync.Map
-		wg      sync.WaitGroup
-		sem     = make(chan struct{}, 10) // limit to 10 concurrent goroutines
+		mu         sync.Mutex
+		visited    sync.Map
+		wg         sync.WaitGroup
+		versionErr firstError
+		sem        = r.semaphore()
 	)
 
 	for depName := range rootPkg.Dependencies {
@@ -717,44 +1356,60 @@ func (r *Resolver) addTransitiveDependenciesWithGraph(im *importmap.ImportMap, r
 			sem <- struct{}{}        // acquire semaphore
 			defer func() { <-sem }() // release semaphore
 
-			r.processPackageDependenciesParallelWithGraph(im, &mu, &visited, nodeModulesPath, name, rootDir, graph)
+			versionErr.set(r.processPackageDependenciesParallelWithGraph(im, &mu, &visited, nodeModulesPath, name, filepath.Join(nodeModulesPath, name), rootDir, graph))
 		}(depName)
 	}
 
 	wg.Wait()
-	return nil
+	return versionErr.get()
 }
 
-// processPackageDependenciesParallelWithGraph recursively processes a package's dependencies and adds scopes,
-// optionally tracking in the dependency graph.
+// processPackageDependenciesParallelWithGraph recursively processes the dependencies of the package
+// installed at pkgPath and adds scopes, optionally tracking in the dependency graph. Returns
+// ErrMissingVersion if the template needs a version that a package in the tree does not declare.
 func (r *Resolver) processPackageDependenciesParallelWithGraph(
 	im *importmap.ImportMap,
 	mu *sync.Mutex,
 	visited *sync.Map,
-	nodeModulesPath, pkgName, rootDir string,
+	nodeModulesPath, pkgName, pkgPath, rootDir string,
 	graph *resolve.DependencyGraph,
-) {
+) error {
 	// Check if already visited (atomic)
-	if _, loaded := visited.LoadOrStore(pkgName, true); loaded {
-		return
+	if _, loaded := visited.LoadOrStore(pkgPath, true); loaded {
+		return nil
 	}
 
-	pkgPath := filepath.Join(nodeModulesPath, pkgName)
 	pkgJSONPath := filepath.Join(pkgPath, "package.json")
 
 	pkg, err := r.parsePackageJSON(pkgJSONPath)
 	if err != nil {
-		return
+		return nil
+	}
+	r.checkEngines(pkgName, pkg)
+
+	// Track package path in graph, including for transitive-only packages
+	if graph != nil {
+		graph.SetPackagePath(pkgName, pkgPath)
 	}
 
 	if len(pkg.Dependencies) == 0 {
-		return
+		return nil
 	}
 
-	// Scope key uses the template with empty path to get the base URL
-	scopeKey := r.template.Expand(pkgName, "", "")
-	if !strings.HasSuffix(scopeKey, "/") {
-		scopeKey += "/"
+	// Scope key uses the template with empty path to get the base URL,
+	// or the source directory of a linked package
+	var scopeKey string
+	if _, webPath, ok := r.linkedPackage(pkgPath, rootDir); ok {
+		scopeKey = webPath + "/"
+	} else {
+		version, err := r.packageVersion(pkgName, pkg)
+		if err != nil {
+			return err
+		}
+		scopeKey = r.template.Expand(pkgName, version, "")
+		if !strings.HasSuffix(scopeKey, "/") {
+			scopeKey += "/"
+		}
 	}
 
 	// Track scope key in graph
@@ -772,35 +1427,57 @@ func (r *Resolver) processPackageDependenciesParallelWithGraph(
 			graph.AddDependency(pkgName, depName)
 		}
 
-		depPath := filepath.Join(nodeModulesPath, depName)
+		depPath := r.dependencyPath(nodeModulesPath, pkgPath, depName)
 		if !r.fs.Exists(depPath) {
 			continue
 		}
 
+		if realPath, _, ok := r.linkedPackage(depPath, rootDir); ok {
+			if imports, err := r.linkedImports(depName, realPath, rootDir); err != nil {
+				if r.logger != nil {
+					r.logger.Warning("Failed to add linked package %s: %v", depName, err)
+				}
+			} else {
+				maps.Copy(scopeEntries, imports)
+			}
+			if err := r.processPackageDependenciesParallelWithGraph(im, mu, visited, nodeModulesPath, depName, depPath, rootDir, graph); err != nil {
+				return err
+			}
+			continue
+		}
+
 		depPkgPath := filepath.Join(depPath, "package.json")
 		depPkg, err := r.parsePackageJSON(depPkgPath)
 		if err != nil {
 			continue
 		}
 
+		depVersion, err := r.packageVersion(depName, depPkg)
+		if err != nil {
+			return err
+		}
+
 		// Handle wildcard exports (trailing slash imports)
 		wildcards := depPkg.WildcardExports(opts)
 
 		// Add trailing-slash keys for wildcard exports
 		for _, w := range wildcards {
-			patternPrefix := strings.TrimSuffix(strings.TrimPrefix(w.Pattern, "./"), "*")
-			importKey := depName + "/" + patternPrefix
-			scopeEntries[importKey] = r.template.Expand(depName, "", w.Target)
+			if err := r.checkWildcard(depName, w); err != nil {
+				return err
+			}
+			maps.Copy(scopeEntries, r.wildcardImports(depName, depPath, w, func(target string) string {
+				return r.moduleURL(depName, depVersion, depPath, target)
+			}))
 		}
 
 		// For packages with no wildcards but trailing-slash support, add trailing-slash key
 		if len(wildcards) == 0 && depPkg.HasTrailingSlashExport(opts) {
-			scopeEntries[depName+"/"] = r.template.Expand(depName, "", "")
+			scopeEntries[depName+"/"] = r.template.Expand(depName, depVersion, "")
 		}
 
 		// Add export entries - explicit exports are never skipped since they may
 		// have different targets than wildcard patterns would provide
-		entries := depPkg.ExportEntries(opts)
+		entries := r.exportEntries(depName, depPath, depPkg, opts)
 		for _, entry := range entries {
 			var importKey string
 			if entry.Subpath == "." {
@@ -809,16 +1486,25 @@ func (r *Resolver) processPackageDependenciesParallelWithGraph(
 				subpath := strings.TrimPrefix(entry.Subpath, "./")
 				importKey = depName + "/" + subpath
 			}
-			scopeEntries[importKey] = r.template.Expand(depName, "", entry.Target)
+			if err := r.checkTarget(depName, importKey, entry.Target); err != nil {
+				return err
+			}
+			scopeEntries[importKey] = r.moduleURL(depName, depVersion, depPath, entry.Target)
 		}
 
 		// Fallback to main if no exports
 		if len(entries) == 0 && depPkg.Main != "" {
-			scopeEntries[depName] = r.template.Expand(depName, "", strings.TrimPrefix(depPkg.Main, "./"))
+			main := r.mainTarget(depName, depPath, depPkg)
+			if err := r.checkTarget(depName, depName, main); err != nil {
+				return err
+			}
+			scopeEntries[depName] = r.moduleURL(depName, depVersion, depPath, main)
 		}
 
 		// Recursively process (will be deduped by visited map)
-		r.processPackageDependenciesParallelWithGraph(im, mu, visited, nodeModulesPath, depName, rootDir, graph)
+		if err := r.processPackageDependenciesParallelWithGraph(im, mu, visited, nodeModulesPath, depName, depPath, rootDir, graph); err != nil {
+			return err
+		}
 	}
 
 	// Merge scope entries into import map (protected by mutex)
@@ -830,6 +1516,308 @@ func (r *Resolver) processPackageDependenciesParallelWithGraph(
 		maps.Copy(im.Scopes[scopeKey], scopeEntries)
 		mu.Unlock()
 	}
+	return nil
+}
+
+// reportCycles walks the node_modules dependency tree from roots depth-first,
+// tracking the current path, and logs a warning for each dependency cycle found.
+// Packages and dependencies are visited in sorted order so reports are stable.
+func (r *Resolver) reportCycles(nodeModulesPath string, roots []string) {
+	if r.logger == nil {
+		return
+	}
+
+	const (
+		inProgress = 1
+		done       = 2
+	)
+	state := make(map[string]int)
+	var stack []string
+
+	var visit func(pkgName string)
+	visit = func(pkgName string) {
+		switch state[pkgName] {
+		case inProgress:
+			start := slices.Index(stack, pkgName)
+			cycle := append(slices.Clone(stack[start:]), pkgName)
+			r.logger.Warning("cycle: %s", strings.Join(cycle, " -> "))
+			return
+		case done:
+			return
+		}
+
+		pkg, err := r.parsePackageJSON(filepath.Join(nodeModulesPath, pkgName, "package.json"))
+		if err != nil {
+			state[pkgName] = done
+			return
+		}
+
+		state[pkgName] = inProgress
+		stack = append(stack, pkgName)
+		for _, dep := range slices.Sorted(maps.Keys(pkg.Dependencies)) {
+			visit(dep)
+		}
+		stack = stack[:len(stack)-1]
+		state[pkgName] = done
+	}
+
+	slices.Sort(roots)
+	for _, root := range roots {
+		visit(root)
+	}
+}
+
+// reportDuplicates walks the given node_modules directories, descending into
+// each package's nested node_modules, and logs a warning such as
+// "duplicate: lit-html has 2 versions: 2.8.0 (node_modules/lit-html), 3.1.0 (...)"
+// for every package installed at more than one version. Install paths are
+// relative to rootDir, and packages and versions are reported in sorted order.
+func (r *Resolver) reportDuplicates(rootDir string, nodeModulesPaths []string) {
+	if r.logger == nil {
+		return
+	}
+
+	// name -> version -> install paths
+	installs := make(map[string]map[string][]string)
+	// Installs already counted, by real path, as pnpm links one store
+	// directory from every package that depends on it
+	seen := make(map[string]bool)
+
+	var walk func(nodeModulesPath string)
+	addPackage := func(pkgName, pkgPath string) {
+		realPath, linked := fs.LinkTarget(r.fs, pkgPath)
+		if linked {
+			pkgPath = realPath
+		}
+		if seen[pkgPath] {
+			return
+		}
+		seen[pkgPath] = true
+		pkg, err := r.parsePackageJSON(filepath.Join(pkgPath, "package.json"))
+		if err != nil {
+			return
+		}
+		if installs[pkgName] == nil {
+			installs[pkgName] = make(map[string][]string)
+		}
+		rel, err := filepath.Rel(rootDir, pkgPath)
+		if err != nil {
+			rel = pkgPath
+		}
+		installs[pkgName][pkg.Version] = append(installs[pkgName][pkg.Version], filepath.ToSlash(rel))
+		walk(filepath.Join(pkgPath, "node_modules"))
+		// A pnpm store package's dependencies are links beside it, in
+		// .pnpm/<name>@<version>/node_modules
+		if linked {
+			if dir := packageModulesDir(pkgName, pkgPath); filepath.Base(dir) == "node_modules" {
+				walk(dir)
+			}
+		}
+	}
+	walk = func(nodeModulesPath string) {
+		entries, err := r.fs.ReadDir(nodeModulesPath)
+		if err != nil {
+			return
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if !isPackageEntry(entry) || strings.HasPrefix(name, ".") {
+				continue
+			}
+			if !strings.HasPrefix(name, "@") {
+				addPackage(name, filepath.Join(nodeModulesPath, name))
+				continue
+			}
+			scoped, err := r.fs.ReadDir(filepath.Join(nodeModulesPath, name))
+			if err != nil {
+				continue
+			}
+			for _, s := range scoped {
+				if isPackageEntry(s) {
+					addPackage(name+"/"+s.Name(), filepath.Join(nodeModulesPath, name, s.Name()))
+				}
+			}
+		}
+	}
+
+	for _, nodeModulesPath := range nodeModulesPaths {
+		walk(nodeModulesPath)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(installs)) {
+		versions := installs[name]
+		if len(versions) < 2 {
+			continue
+		}
+		var locations []string
+		for _, version := range slices.Sorted(maps.Keys(versions)) {
+			paths := versions[version]
+			slices.Sort(paths)
+			locations = append(locations, fmt.Sprintf("%s (%s)", version, strings.Join(paths, ", ")))
+		}
+		r.logger.Warning("duplicate: %s has %d versions: %s", name, len(versions), strings.Join(locations, ", "))
+	}
+}
+
+// isPackageEntry reports whether a node_modules entry may be a package: a
+// directory, or a symbolic link to one as pnpm and workspace tools install.
+func isPackageEntry(entry iofs.DirEntry) bool {
+	return entry.IsDir() || entry.Type()&iofs.ModeSymlink != 0
+}
+
+// packageModulesDir returns the node_modules directory holding the package
+// at pkgPath, one level further up for scoped packages.
+func packageModulesDir(pkgName, pkgPath string) string {
+	dir := filepath.Dir(pkgPath)
+	if strings.HasPrefix(pkgName, "@") {
+		dir = filepath.Dir(dir)
+	}
+	return dir
+}
+
+// packageVersion returns the version of pkg for template expansion.
+// Returns ErrMissingVersion if the template uses {version} and pkg has none.
+func (r *Resolver) packageVersion(pkgName string, pkg *packagejson.PackageJSON) (string, error) {
+	if pkg.Version == "" && r.template.HasVersion() {
+		return "", fmt.Errorf("%w: %s is required by template %q", ErrMissingVersion, pkgName, r.template.Pattern())
+	}
+	return pkg.Version, nil
+}
+
+// moduleURL returns the URL for target within the package at pkgPath: the
+// template expansion, or a data: URL when the module is small enough to inline.
+func (r *Resolver) moduleURL(pkgName, version, pkgPath, target string) string {
+	return r.inlineURL(pkgPath, target, r.template.Expand(pkgName, version, target))
+}
+
+// moduleImportPattern matches anything in a module that may import or
+// re-export another module, or resolve a URL against its own: import
+// declarations, import(), import.meta and export ... from. A data: URL has no
+// base for relative or bare specifiers, so such modules are never inlined.
+// Matches in comments and strings only cost a module its inlining.
+var moduleImportPattern = regexp.MustCompile(`\bimport\b|\bexport\s*(?:\*|\{[^}]*\})\s*(?:as\s+[\w$]+\s*)?from\b`)
+
+// inlineURL returns a base64 data: URL holding the JavaScript module at
+// pkgPath/target when inlining is enabled, the file is smaller than the
+// threshold, and it imports nothing. Otherwise, including for directories
+// and other file types, it returns url.
+func (r *Resolver) inlineURL(pkgPath, target, url string) string {
+	if r.inlineBelow <= 0 {
+		return url
+	}
+	target = strings.TrimPrefix(target, "./")
+	if ext := path.Ext(target); ext != ".js" && ext != ".mjs" {
+		return url
+	}
+	data, err := r.fs.ReadFile(filepath.Join(pkgPath, filepath.FromSlash(target)))
+	if err != nil || len(data) >= r.inlineBelow || moduleImportPattern.Match(data) {
+		return url
+	}
+	return "data:text/javascript;base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+// dependencyPath returns the directory depName resolves to from the package
+// installed at pkgPath. With pnpm, that is the version linked beside the
+// package in its store directory; otherwise it is nodeModulesPath/depName.
+func (r *Resolver) dependencyPath(nodeModulesPath, pkgPath, depName string) string {
+	if pnpm, ok := r.fs.(*resolve.PnpmFileSystem); ok {
+		return pnpm.DependencyLocation(pkgPath, depName)
+	}
+	return filepath.Join(nodeModulesPath, depName)
+}
+
+// firstError records the first non-nil error reported by concurrent goroutines.
+type firstError struct {
+	mu  sync.Mutex
+	err error
+}
+
+func (e *firstError) set(err error) {
+	if err == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+func (e *firstError) get() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.err
+}
+
+// wildcardImports returns the import map entries for a wildcard export of the
+// package at pkgPath. Prefix mappings become a single trailing-slash entry;
+// other patterns, such as "./*" -> "./*/index.js", are expanded to one entry
+// per matching file in the package. expand maps a path within the package to a URL.
+func (r *Resolver) wildcardImports(pkgName, pkgPath string, w packagejson.WildcardExport, expand func(target string) string) map[string]string {
+	if w.IsPrefixMapping() {
+		return map[string]string{pkgName + "/" + w.ImportPrefix(): expand(w.Target)}
+	}
+
+	imports := make(map[string]string)
+	dir := ""
+	if i := strings.LastIndex(w.Target, "/"); i >= 0 {
+		dir = w.Target[:i]
+	}
+	for _, file := range r.packageFiles(pkgPath, dir) {
+		if subpath, ok := w.Match(file); ok {
+			imports[pkgName+"/"+subpath] = expand(file)
+		}
+	}
+	return imports
+}
+
+// packageFiles lists the files under dir within the package at pkgPath,
+// as slash-separated paths relative to pkgPath. Nested node_modules are skipped.
+func (r *Resolver) packageFiles(pkgPath, dir string) []string {
+	entries, err := r.fs.ReadDir(filepath.Join(pkgPath, dir))
+	if err != nil {
+		return nil
+	}
+
+	var files []string
+	for _, entry := range entries {
+		rel := path.Join(dir, entry.Name())
+		if !entry.IsDir() {
+			files = append(files, rel)
+		} else if entry.Name() != "node_modules" {
+			files = append(files, r.packageFiles(pkgPath, rel)...)
+		}
+	}
+	return files
+}
+
+// mergeInputMap merges the configured input map over im, attributing its
+// entries to the input map and warning about each generated entry it
+// overrides. Returns im unchanged when no input map is set.
+func (r *Resolver) mergeInputMap(im *importmap.ImportMap) *importmap.ImportMap {
+	if r.inputMap == nil {
+		return im
+	}
+	merged, conflicts := im.MergeWithConflicts(r.inputMap.WithProvenance(importmap.ProvenanceInputMap))
+	for _, c := range conflicts {
+		r.logger.Warning("Input map overrides %s", c)
+	}
+	return merged
+}
+
+// recordProvenance attributes each top-level import to a source chosen by
+// its package name. Entries that already have provenance keep it, and
+// provenance for removed imports is dropped.
+func recordProvenance(im *importmap.ImportMap, source func(pkgName string) string) {
+	provenance := make(map[string]string, len(im.Imports))
+	for key := range im.Imports {
+		if existing, ok := im.Provenance[key]; ok {
+			provenance[key] = existing
+		} else {
+			provenance[key] = source(parsePackageName(key))
+		}
+	}
+	im.Provenance = provenance
 }
 
 // parsePackageName extracts the package name from a package spec.
@@ -865,19 +1853,17 @@ func (r *Resolver) ResolveIncremental(rootDir string, update resolve.Incremental
 		absRoot = rootDir
 	}
 	rootDir = absRoot
+	workspaceRoot := resolve.FindWorkspaceRoot(r.fs, rootDir)
+	r = r.withPnP(workspaceRoot).withPnpm(workspaceRoot)
 
 	// Invalidate cache for changed packages
+	changed := update.ChangedPackages
 	if r.cache != nil {
-		for _, pkgName := range update.ChangedPackages {
-			pkgPath := update.PreviousGraph.PackagePath(pkgName)
-			if pkgPath != "" {
-				r.cache.Invalidate(filepath.Join(pkgPath, "package.json"))
-			}
-		}
+		changed = r.refreshChangedPackages(update.ChangedPackages, update.PreviousGraph)
 	}
 
 	// Compute all affected packages: changed + transitive dependents
-	affected := r.computeAffectedPackages(update.ChangedPackages, update.PreviousGraph)
+	affected := r.computeAffectedPackages(changed, update.PreviousGraph)
 
 	// Clone the previous map and graph
 	result := update.PreviousMap.Clone()
@@ -890,10 +1876,11 @@ func (r *Resolver) ResolveIncremental(rootDir string, update resolve.Incremental
 	}
 
 	// Re-resolve affected packages
-	nodeModulesPath := filepath.Join(rootDir, "node_modules")
+	nodeModulesPath := filepath.Join(workspaceRoot, "node_modules")
 	var wg sync.WaitGroup
 	var mu sync.Mutex
-	sem := make(chan struct{}, 10)
+	var versionErr firstError
+	sem := r.semaphore()
 
 	for _, pkgName := range affected {
 		wg.Add(1)
@@ -937,7 +1924,9 @@ func (r *Resolver) ResolveIncremental(rootDir string, update resolve.Incremental
 			mu.Unlock()
 
 			if err := r.addPackageToImportMapWithGraph(result, &mu, name, depPath, newGraph); err != nil {
-				if r.logger != nil {
+				if isFatal(err) {
+					versionErr.set(err)
+				} else if r.logger != nil {
 					r.logger.Warning("Failed to re-add package %s: %v", name, err)
 				}
 			}
@@ -955,21 +1944,40 @@ func (r *Resolver) ResolveIncremental(rootDir string, update resolve.Incremental
 				sem <- struct{}{}
 				defer func() { <-sem }()
 
-				r.processPackageDependenciesParallelWithGraph(result, &mu, &visited, nodeModulesPath, name, rootDir, newGraph)
+				versionErr.set(r.processPackageDependenciesParallelWithGraph(result, &mu, &visited, nodeModulesPath, name, filepath.Join(nodeModulesPath, name), rootDir, newGraph))
 			}(pkgName)
 		}
 	}
 	wg.Wait()
+	if err := versionErr.get(); err != nil {
+		return nil, err
+	}
+
+	// Cycles through the re-resolved packages may have come or gone
+	if r.detectCycles {
+		var roots []string
+		for _, pkgName := range affected {
+			if !update.PreviousGraph.IsWorkspacePackage(pkgName) {
+				roots = append(roots, pkgName)
+			}
+		}
+		r.reportCycles(nodeModulesPath, roots)
+	}
 
 	// Clean up empty scopes
 	if len(result.Scopes) == 0 {
 		result.Scopes = nil
 	}
 
+	recordProvenance(result, func(name string) string {
+		if newGraph.IsWorkspacePackage(name) {
+			return importmap.ProvenanceWorkspace
+		}
+		return importmap.ProvenanceDependency
+	})
+
 	// Merge with input map if provided (input map takes precedence)
-	if r.inputMap != nil {
-		result = result.Merge(r.inputMap)
-	}
+	result = r.mergeInputMap(result)
 
 	return &resolve.IncrementalResult{
 		ImportMap:       result,
@@ -977,6 +1985,43 @@ func (r *Resolver) ResolveIncremental(rootDir string, update resolve.Incremental
 	}, nil
 }
 
+// refreshChangedPackages drops changed packages' package.json files from the
+// cache, and returns the packages to re-resolve. With a ChangeAwareCache, the
+// files are reparsed instead, and packages whose package.json content is
+// unchanged, e.g. because an editor only touched the file, are left out.
+func (r *Resolver) refreshChangedPackages(changed []string, graph *resolve.DependencyGraph) []string {
+	aware, _ := r.cache.(packagejson.ChangeAwareCache)
+	var result []string
+	for _, pkgName := range changed {
+		pkgPath := graph.PackagePath(pkgName)
+		if pkgPath == "" {
+			result = append(result, pkgName)
+			continue
+		}
+		pkgJSONPath := filepath.Join(pkgPath, "package.json")
+		if aware != nil {
+			if info, err := r.fs.Stat(pkgJSONPath); err == nil {
+				_, reparsed, err := aware.GetOrLoadIfChanged(pkgJSONPath, info.ModTime(), func() ([]byte, error) {
+					return r.fs.ReadFile(pkgJSONPath)
+				})
+				if err == nil && !reparsed {
+					if r.logger != nil {
+						r.logger.Debug("Package '%s': package.json is unchanged, skipping", pkgName)
+					}
+					continue
+				}
+				if err == nil {
+					result = append(result, pkgName)
+					continue
+				}
+			}
+		}
+		r.cache.Invalidate(pkgJSONPath)
+		result = append(result, pkgName)
+	}
+	return result
+}
+
 // computeAffectedPackages returns all packages that need to be re-resolved:
 // the changed packages plus all their transitive dependents.
 func (r *Resolver) computeAffectedPackages(changed []string, graph *resolve.DependencyGraph) []string {
@@ -18,9 +18,11 @@ package local_test
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
 	"slices"
+	"strings"
 	"sync"
 	"testing"
 
@@ -58,6 +60,7 @@ func TestResolver(t *testing.T) {
 	}{
 		{"simple package", "simple-pkg"},
 		{"with scopes", "with-scopes"},
+		{"wildcard with suffix", "wildcard-suffix"},
 	}
 
 	for _, tt := range tests {
@@ -106,6 +109,487 @@ func TestResolverNoPackageJSON(t *testing.T) {
 	}
 }
 
+func TestResolverYarnPnP(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "resolve/yarn-pnp", "/test")
+
+	expectedData, err := mfs.ReadFile("/test/expected.json")
+	if err != nil {
+		t.Fatalf("Failed to read expected.json: %v", err)
+	}
+
+	var expected importmap.ImportMap
+	if err := json.Unmarshal(expectedData, &expected); err != nil {
+		t.Fatalf("Failed to parse expected.json: %v", err)
+	}
+
+	if mfs.Exists("/test/node_modules") {
+		t.Fatal("fixture must not have a node_modules directory")
+	}
+
+	resolver := local.New(mfs, nil)
+	result, err := resolver.Resolve("/test")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(result.Imports, expected.Imports) {
+		t.Errorf("Imports mismatch:\n  got:      %v\n  expected: %v", result.Imports, expected.Imports)
+	}
+
+	if !reflect.DeepEqual(result.Scopes, expected.Scopes) {
+		t.Errorf("Scopes mismatch:\n  got:      %v\n  expected: %v", result.Scopes, expected.Scopes)
+	}
+
+	specifiers := resolver.ResolveSpecifiers("/test", []string{"lit-html"})
+	if got, want := specifiers["lit-html"], "/node_modules/lit-html/lit-html.js"; got != want {
+		t.Errorf("ResolveSpecifiers(lit-html) = %q, want %q", got, want)
+	}
+}
+
+func TestResolverPnpm(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "resolve/pnpm", "/test")
+
+	expectedData, err := mfs.ReadFile("/test/expected.json")
+	if err != nil {
+		t.Fatalf("Failed to read expected.json: %v", err)
+	}
+
+	var expected importmap.ImportMap
+	if err := json.Unmarshal(expectedData, &expected); err != nil {
+		t.Fatalf("Failed to parse expected.json: %v", err)
+	}
+
+	if mfs.Exists("/test/node_modules/lit-html") {
+		t.Fatal("fixture must only install transitive dependencies in the .pnpm store")
+	}
+
+	resolver := local.New(mfs, nil)
+	result, err := resolver.Resolve("/test")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(result.Imports, expected.Imports) {
+		t.Errorf("Imports mismatch:\n  got:      %v\n  expected: %v", result.Imports, expected.Imports)
+	}
+
+	if !reflect.DeepEqual(result.Scopes, expected.Scopes) {
+		t.Errorf("Scopes mismatch:\n  got:      %v\n  expected: %v", result.Scopes, expected.Scopes)
+	}
+
+	// The version pnpm hoisted within the store wins over other installed versions
+	versioned, err := resolver.WithTemplate("/assets/{package}@{version}/{path}")
+	if err != nil {
+		t.Fatalf("WithTemplate failed: %v", err)
+	}
+	specifiers := versioned.ResolveSpecifiers("/test", []string{"lit-html"})
+	if got, want := specifiers["lit-html"], "/assets/lit-html@3.0.0/lit-html.js"; got != want {
+		t.Errorf("ResolveSpecifiers(lit-html) = %q, want %q", got, want)
+	}
+}
+
+func TestResolverPnpmDependencyVersions(t *testing.T) {
+	// a depends on dep@1 and b on dep@2, each linked beside it in the store
+	mfs := testutil.NewFixtureFS(t, "resolve/pnpm-versions", "/test")
+
+	expectedData, err := mfs.ReadFile("/test/expected.json")
+	if err != nil {
+		t.Fatalf("Failed to read expected.json: %v", err)
+	}
+
+	var expected importmap.ImportMap
+	if err := json.Unmarshal(expectedData, &expected); err != nil {
+		t.Fatalf("Failed to parse expected.json: %v", err)
+	}
+
+	resolver, err := local.New(mfs, nil).WithTemplate("/assets/{package}@{version}/{path}")
+	if err != nil {
+		t.Fatalf("WithTemplate failed: %v", err)
+	}
+	result, err := resolver.Resolve("/test")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(result.Imports, expected.Imports) {
+		t.Errorf("Imports mismatch:\n  got:      %v\n  expected: %v", result.Imports, expected.Imports)
+	}
+
+	if !reflect.DeepEqual(result.Scopes, expected.Scopes) {
+		t.Errorf("Scopes mismatch:\n  got:      %v\n  expected: %v", result.Scopes, expected.Scopes)
+	}
+}
+
+func TestResolverVersionTemplate(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "resolve/version-template", "/test")
+
+	expectedData, err := mfs.ReadFile("/test/expected.json")
+	if err != nil {
+		t.Fatalf("Failed to read expected.json: %v", err)
+	}
+
+	var expected importmap.ImportMap
+	if err := json.Unmarshal(expectedData, &expected); err != nil {
+		t.Fatalf("Failed to parse expected.json: %v", err)
+	}
+
+	resolver, err := local.New(mfs, nil).WithTemplate("/assets/{package}@{version}/{path}")
+	if err != nil {
+		t.Fatalf("WithTemplate failed: %v", err)
+	}
+	result, err := resolver.Resolve("/test")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(result.Imports, expected.Imports) {
+		t.Errorf("Imports mismatch:\n  got:      %v\n  expected: %v", result.Imports, expected.Imports)
+	}
+
+	if !reflect.DeepEqual(result.Scopes, expected.Scopes) {
+		t.Errorf("Scopes mismatch:\n  got:      %v\n  expected: %v", result.Scopes, expected.Scopes)
+	}
+
+	specifiers := resolver.ResolveSpecifiers("/test", []string{"lit"})
+	if got, want := specifiers["lit"], "/assets/lit@3.0.0/index.js"; got != want {
+		t.Errorf("ResolveSpecifiers lit = %q, want %q", got, want)
+	}
+}
+
+func TestResolverVersionTemplateMissingVersion(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "resolve/missing-version", "/test")
+
+	resolver, err := local.New(mfs, nil).WithTemplate("/assets/{package}@{version}/{path}")
+	if err != nil {
+		t.Fatalf("WithTemplate failed: %v", err)
+	}
+	_, err = resolver.Resolve("/test")
+	if !errors.Is(err, local.ErrMissingVersion) {
+		t.Fatalf("Expected ErrMissingVersion, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "unversioned") {
+		t.Errorf("Expected error to name the package, got %q", err)
+	}
+
+	// Templates without {version} don't need the field
+	if _, err := local.New(mfs, nil).Resolve("/test"); err != nil {
+		t.Errorf("Resolve without {version} failed: %v", err)
+	}
+}
+
+func TestResolverInlineBelow(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "resolve/inline-modules", "/test")
+
+	expectedData, err := mfs.ReadFile("/test/expected.json")
+	if err != nil {
+		t.Fatalf("Failed to read expected.json: %v", err)
+	}
+
+	var expected importmap.ImportMap
+	if err := json.Unmarshal(expectedData, &expected); err != nil {
+		t.Fatalf("Failed to parse expected.json: %v", err)
+	}
+
+	resolver := local.New(mfs, nil).WithInlineBelow(512)
+	result, err := resolver.Resolve("/test")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(result.Imports, expected.Imports) {
+		t.Errorf("Imports mismatch:\n  got:      %v\n  expected: %v", result.Imports, expected.Imports)
+	}
+
+	specifiers := resolver.ResolveSpecifiers("/test", []string{"tiny-utils", "tiny-utils/format.js", "tiny-utils/range.js", "tiny-utils/styles.css"})
+	if !reflect.DeepEqual(specifiers, expected.Imports) {
+		t.Errorf("ResolveSpecifiers mismatch:\n  got:      %v\n  expected: %v", specifiers, expected.Imports)
+	}
+
+	// Without a threshold, modules keep their path URLs
+	result, err = local.New(mfs, nil).Resolve("/test")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if got := result.Imports["tiny-utils"]; got != "/node_modules/tiny-utils/index.js" {
+		t.Errorf("Expected path URL without inlining, got %q", got)
+	}
+}
+
+func TestResolveSpecifiersWildcardSuffix(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "resolve/wildcard-suffix", "/test")
+
+	resolver := local.New(mfs, nil)
+	result := resolver.ResolveSpecifiers("/test", []string{"icons/foo"})
+
+	expected := map[string]string{"icons/foo": "/node_modules/icons/foo/index.js"}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("ResolveSpecifiers mismatch:\n  got:      %v\n  expected: %v", result, expected)
+	}
+}
+
+func TestResolveSpecifiersStrict(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "trace/strict", "/test")
+
+	resolver := local.New(mfs, nil)
+	result, errs := resolver.ResolveSpecifiersStrict("/test", []string{
+		"lit",
+		"lit/decoratorz.js",
+		"legacy/utilz.js",
+		"missing-pkg",
+	})
+
+	if got, want := result["lit"], "/node_modules/lit/index.js"; got != want {
+		t.Errorf("ResolveSpecifiersStrict(lit) = %q, want %q", got, want)
+	}
+	if len(errs) != 3 {
+		t.Fatalf("Expected 3 errors, got %d: %v", len(errs), errs)
+	}
+	for _, err := range errs {
+		if !errors.Is(err, local.ErrUnresolvedSpecifier) {
+			t.Errorf("Expected ErrUnresolvedSpecifier, got %v", err)
+		}
+	}
+	for i, want := range []string{"legacy/utilz.js", "lit/decoratorz.js", "missing-pkg"} {
+		if !strings.HasPrefix(errs[i].Error(), want+":") {
+			t.Errorf("errs[%d] = %q, want prefix %q", i, errs[i], want)
+		}
+	}
+}
+
+func TestResolverLinkedWorkspacePackage(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "resolve/linked-workspace", "/test")
+
+	expectedData, err := mfs.ReadFile("/test/expected.json")
+	if err != nil {
+		t.Fatalf("Failed to read expected.json: %v", err)
+	}
+
+	var expected importmap.ImportMap
+	if err := json.Unmarshal(expectedData, &expected); err != nil {
+		t.Fatalf("Failed to parse expected.json: %v", err)
+	}
+
+	resolver := local.New(mfs, nil)
+	result, err := resolver.Resolve("/test")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(result.Imports, expected.Imports) {
+		t.Errorf("Imports mismatch:\n  got:      %v\n  expected: %v", result.Imports, expected.Imports)
+	}
+	if !reflect.DeepEqual(result.Scopes, expected.Scopes) {
+		t.Errorf("Scopes mismatch:\n  got:      %v\n  expected: %v", result.Scopes, expected.Scopes)
+	}
+	if got := result.Provenance["@myorg/core"]; got != importmap.ProvenanceWorkspace {
+		t.Errorf("Provenance of linked package = %q, want %q", got, importmap.ProvenanceWorkspace)
+	}
+}
+
+func TestResolverLinkedTransitiveDependencyWarning(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "resolve/linked-workspace", "/test")
+	// tiny depends on a linked package whose package.json can't be parsed
+	mfs.AddFile("/test/node_modules/tiny/package.json", `{"name": "tiny", "version": "1.0.0", "main": "index.js", "dependencies": {"@myorg/broken": "workspace:*"}}`, 0644)
+	mfs.AddFile("/test/packages/broken/package.json", `{"name": `, 0644)
+	mfs.AddSymlink("/test/node_modules/@myorg/broken", "../../packages/broken")
+
+	logger := &mockLogger{}
+	if _, err := local.New(mfs, logger).Resolve("/test"); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	found := false
+	for _, warning := range logger.warnings {
+		if strings.HasPrefix(warning, "Failed to add linked package @myorg/broken:") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected a warning about @myorg/broken, got %v", logger.warnings)
+	}
+}
+
+func TestResolveSpecifiersLinkedWorkspacePackage(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "resolve/linked-workspace", "/test")
+
+	resolver := local.New(mfs, nil)
+	result := resolver.ResolveSpecifiers("/test", []string{"@myorg/core", "@myorg/core/utils.js", "tiny"})
+
+	expected := map[string]string{
+		"@myorg/core":          "/packages/core/index.js",
+		"@myorg/core/utils.js": "/packages/core/utils.js",
+		"tiny":                 "/node_modules/tiny/index.js",
+		"tiny/":                "/node_modules/tiny/",
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("ResolveSpecifiers mismatch:\n  got:      %v\n  expected: %v", result, expected)
+	}
+}
+
+// TestResolverStyleConditions verifies that sass and style export conditions
+// map stylesheet entrypoints, passing non-JavaScript targets through as-is.
+func TestResolverStyleConditions(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "resolve/sass-condition", "/test")
+
+	// Only JavaScript modules are inlined, so inlining leaves stylesheets as-is
+	tests := []struct {
+		name        string
+		conditions  []string
+		inlineBelow int
+		golden      string
+	}{
+		{name: "sass", conditions: []string{"sass", "default"}, inlineBelow: 1024, golden: "expected-sass.json"},
+		{name: "style", conditions: []string{"style", "default"}, inlineBelow: 1024, golden: "expected-style.json"},
+		{name: "default", golden: "expected.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectedData, err := mfs.ReadFile("/test/" + tt.golden)
+			if err != nil {
+				t.Fatalf("Failed to read %s: %v", tt.golden, err)
+			}
+
+			var expected importmap.ImportMap
+			if err := json.Unmarshal(expectedData, &expected); err != nil {
+				t.Fatalf("Failed to parse %s: %v", tt.golden, err)
+			}
+
+			resolver := local.New(mfs, nil).WithInlineBelow(tt.inlineBelow)
+			if tt.conditions != nil {
+				resolver = resolver.WithConditions(tt.conditions)
+			}
+			result, err := resolver.Resolve("/test")
+			if err != nil {
+				t.Fatalf("Resolve failed: %v", err)
+			}
+
+			if !reflect.DeepEqual(result.Imports, expected.Imports) {
+				t.Errorf("Imports mismatch:\n  got:      %v\n  expected: %v", result.Imports, expected.Imports)
+			}
+			if !reflect.DeepEqual(result.Scopes, expected.Scopes) {
+				t.Errorf("Scopes mismatch:\n  got:      %v\n  expected: %v", result.Scopes, expected.Scopes)
+			}
+		})
+	}
+}
+
+func TestResolveSpecifiersSassCondition(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "resolve/sass-condition", "/test")
+
+	resolver := local.New(mfs, nil).WithConditions([]string{"sass", "default"})
+	result := resolver.ResolveSpecifiers("/test", []string{
+		"@acme/button",
+		"@acme/tokens",
+		"@acme/tokens/colors",
+		"@acme/tokens/scss/_mixins.scss",
+	})
+
+	expected := map[string]string{
+		"@acme/button":        "/node_modules/@acme/button/button.scss",
+		"@acme/tokens":        "/node_modules/@acme/tokens/scss/index.scss",
+		"@acme/tokens/colors": "/node_modules/@acme/tokens/scss/colors.scss",
+		"@acme/tokens/css/":   "/node_modules/@acme/tokens/css/",
+		"@acme/tokens/scss/":  "/node_modules/@acme/tokens/scss/",
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("ResolveSpecifiers mismatch:\n  got:      %v\n  expected: %v", result, expected)
+	}
+}
+
+// countingFS counts reads of each file.
+type countingFS struct {
+	*mapfs.MapFileSystem
+	mu    sync.Mutex
+	reads map[string]int
+}
+
+func (c *countingFS) ReadFile(name string) ([]byte, error) {
+	c.mu.Lock()
+	c.reads[name]++
+	c.mu.Unlock()
+	return c.MapFileSystem.ReadFile(name)
+}
+
+func TestResolveSpecifiersParsesPackageOnce(t *testing.T) {
+	cfs := &countingFS{MapFileSystem: testutil.NewFixtureFS(t, "resolve/simple-pkg", "/test"), reads: make(map[string]int)}
+
+	result := local.New(cfs, nil).ResolveSpecifiers("/test", []string{
+		"lit",
+		"lit/decorators.js",
+		"lit/index.js",
+		"lit/html.js",
+		"lit/directives/class-map.js",
+	})
+
+	if n := cfs.reads["/test/node_modules/lit/package.json"]; n != 1 {
+		t.Errorf("Expected lit's package.json to be read once, got %d reads", n)
+	}
+	for spec, expected := range map[string]string{
+		"lit":               "/node_modules/lit/index.js",
+		"lit/decorators.js": "/node_modules/lit/decorators.js",
+		"lit/html.js":       "/node_modules/lit/html.js",
+	} {
+		if result[spec] != expected {
+			t.Errorf("Expected %s -> %s, got %q", spec, expected, result[spec])
+		}
+	}
+}
+
+func TestResolverProvenance(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "resolve/simple-pkg", "/test")
+
+	inputMap := &importmap.ImportMap{
+		Imports: map[string]string{"slidem": "/vendor/slidem.js"},
+	}
+
+	resolver := local.New(mfs, nil).WithInputMap(inputMap)
+	result, err := resolver.Resolve("/test")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	if got := result.Provenance["lit"]; got != importmap.ProvenanceDependency {
+		t.Errorf("Expected lit provenance %q, got %q", importmap.ProvenanceDependency, got)
+	}
+	if got := result.Provenance["slidem"]; got != importmap.ProvenanceInputMap {
+		t.Errorf("Expected slidem provenance %q, got %q", importmap.ProvenanceInputMap, got)
+	}
+	if result.ToJSON() == "" || strings.Contains(result.ToJSON(), "provenance") {
+		t.Errorf("Expected provenance to be omitted from JSON output:\n%s", result.ToJSON())
+	}
+}
+
+func TestResolverInputMapConflicts(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "resolve/simple-pkg", "/test")
+
+	inputMap := &importmap.ImportMap{
+		Imports: map[string]string{
+			"lit":    "/vendor/lit.js",
+			"slidem": "/vendor/slidem.js",
+		},
+	}
+
+	logger := &mockLogger{}
+	result, err := local.New(mfs, logger).WithInputMap(inputMap).Resolve("/test")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	if got := result.Imports["lit"]; got != "/vendor/lit.js" {
+		t.Errorf("Expected input map to win for lit, got %q", got)
+	}
+	want := `Input map overrides "lit" (/node_modules/lit/index.js -> /vendor/lit.js)`
+	if !slices.Contains(logger.warnings, want) {
+		t.Errorf("Expected warning %q, got %v", want, logger.warnings)
+	}
+	for _, w := range logger.warnings {
+		if strings.Contains(w, "slidem") {
+			t.Errorf("Expected no conflict for an entry only the input map has, got %q", w)
+		}
+	}
+}
+
 func TestResolverInterface(t *testing.T) {
 	var _ resolve.Resolver = (*local.Resolver)(nil)
 }
@@ -269,6 +753,290 @@ func TestResolverWarnsOnNoExportsOrMain(t *testing.T) {
 	}
 }
 
+func TestResolverAllowSubpathOnly(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "resolve/subpath-only", "/test")
+	expectedWarning := "Package 'icon-set' has no root export or main field; only subpath imports will work"
+
+	// Warns by default
+	logger := &mockLogger{}
+	if _, err := local.New(mfs, logger).Resolve("/test"); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if !slices.Contains(logger.warnings, expectedWarning) {
+		t.Errorf("Expected warning %q, got warnings: %v", expectedWarning, logger.warnings)
+	}
+
+	logger = &mockLogger{}
+	result, err := local.New(mfs, logger).WithAllowSubpathOnly(true).Resolve("/test")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if len(logger.warnings) != 0 {
+		t.Errorf("Expected no warnings for subpath-only package, got %v", logger.warnings)
+	}
+	if _, ok := result.Imports["icon-set"]; ok {
+		t.Error("Expected no bare specifier mapping for subpath-only package")
+	}
+	if result.Imports["icon-set/feature"] != "/node_modules/icon-set/feature.js" {
+		t.Errorf("Expected icon-set/feature mapping, got %v", result.Imports)
+	}
+	if result.Imports["icon-set/icons/star"] != "/node_modules/icon-set/icons/star.js" {
+		t.Errorf("Expected icon-set/icons/star mapping, got %v", result.Imports)
+	}
+
+	// Packages without any exports are still reported
+	nfs := testutil.NewFixtureFS(t, "resolve/no-exports-pkg", "/test")
+	logger = &mockLogger{}
+	if _, err := local.New(nfs, logger).WithAllowSubpathOnly(true).Resolve("/test"); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if len(logger.warnings) != 1 {
+		t.Errorf("Expected missing root warning for broken-lib, got %v", logger.warnings)
+	}
+}
+
+func TestResolverStrictPaths(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "resolve/escaping-exports", "/test")
+	expectedWarning := `Package 'evil-pkg': evil-pkg/secret resolves to "lib/../../../secret.js": export target escapes the package directory`
+
+	// Warns by default, keeping the mapping
+	logger := &mockLogger{}
+	result, err := local.New(mfs, logger).Resolve("/test")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if !slices.Contains(logger.warnings, expectedWarning) {
+		t.Errorf("Expected warning %q, got warnings: %v", expectedWarning, logger.warnings)
+	}
+	if _, ok := result.Imports["evil-pkg/secret"]; !ok {
+		t.Errorf("Expected evil-pkg/secret to be mapped outside strict mode, got %v", result.Imports)
+	}
+	if result.Imports["old-pkg"] != "/node_modules/old-pkg/lib/index.js" {
+		t.Errorf("Expected old-pkg main mapping, got %v", result.Imports)
+	}
+
+	// Strict mode fails resolution
+	_, err = local.New(mfs, nil).WithStrictPaths(true).Resolve("/test")
+	if !errors.Is(err, local.ErrPathEscapesPackage) {
+		t.Errorf("Expected ErrPathEscapesPackage, got %v", err)
+	}
+
+	// ResolveSpecifiers omits escaping specifiers
+	logger = &mockLogger{}
+	specs := local.New(mfs, logger).WithStrictPaths(true).ResolveSpecifiers("/test", []string{"evil-pkg", "evil-pkg/secret"})
+	if _, ok := specs["evil-pkg/secret"]; ok {
+		t.Errorf("Expected evil-pkg/secret to be omitted in strict mode, got %v", specs)
+	}
+	if specs["evil-pkg"] != "/node_modules/evil-pkg/index.js" {
+		t.Errorf("Expected evil-pkg mapping, got %v", specs)
+	}
+	if len(logger.warnings) != 1 {
+		t.Errorf("Expected one skip warning, got %v", logger.warnings)
+	}
+}
+
+func TestResolverStrictPathsWildcardAndScopes(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "resolve/escaping-wildcard", "/test")
+	expectedWarnings := []string{
+		`Package 'wild-pkg': wild-pkg/* resolves to "../../*": export target escapes the package directory`,
+		`Package 'deep-pkg': deep-pkg/secret resolves to "../../../secret.js": export target escapes the package directory`,
+	}
+
+	// Warns by default about the wildcard and the transitive dependency
+	logger := &mockLogger{}
+	if _, err := local.New(mfs, logger).Resolve("/test"); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	for _, expected := range expectedWarnings {
+		if !slices.Contains(logger.warnings, expected) {
+			t.Errorf("Expected warning %q, got warnings: %v", expected, logger.warnings)
+		}
+	}
+
+	// Strict mode fails resolution
+	_, err := local.New(mfs, nil).WithStrictPaths(true).Resolve("/test")
+	if !errors.Is(err, local.ErrPathEscapesPackage) {
+		t.Errorf("Expected ErrPathEscapesPackage, got %v", err)
+	}
+}
+
+func TestResolverEngineCheck(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "resolve/engines", "/test")
+
+	tests := []struct {
+		version string
+		want    []string
+	}{
+		{"", nil},
+		{"18.19.0", []string{
+			"Package 'helper' requires node >= 22.0.0, which excludes 18.19.0",
+			"Package 'legacy-pkg' requires node ^14 || ^16, which excludes 18.19.0",
+			"Package 'modern-pkg' requires node >=20, which excludes 18.19.0",
+		}},
+		{"22.1.0", []string{
+			"Package 'legacy-pkg' requires node ^14 || ^16, which excludes 22.1.0",
+		}},
+		{"16.20.2", []string{
+			"Package 'helper' requires node >= 22.0.0, which excludes 16.20.2",
+			"Package 'modern-pkg' requires node >=20, which excludes 16.20.2",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			logger := &mockLogger{}
+			result, err := local.New(mfs, logger).WithEngineCheck(tt.version).Resolve("/test")
+			if err != nil {
+				t.Fatalf("Resolve failed: %v", err)
+			}
+			got := slices.Sorted(slices.Values(logger.warnings))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Expected warnings %v, got %v", tt.want, got)
+			}
+			// Incompatible packages are still mapped, and an array-valued
+			// engines field doesn't stop array-pkg from parsing
+			for _, name := range []string{"any-pkg", "array-pkg", "legacy-pkg", "modern-pkg"} {
+				if _, ok := result.Imports[name]; !ok {
+					t.Errorf("Expected %s to be mapped, got %v", name, result.Imports)
+				}
+			}
+		})
+	}
+}
+
+func TestResolverWithConcurrency(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "resolve/with-scopes", "/test")
+
+	expected, err := local.New(mfs, nil).Resolve("/test")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	for _, n := range []int{1, 2, 64, 0} {
+		result, err := local.New(mfs, nil).WithConcurrency(n).Resolve("/test")
+		if err != nil {
+			t.Fatalf("Resolve with concurrency %d failed: %v", n, err)
+		}
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("Concurrency %d: got %v, want %v", n, result, expected)
+		}
+	}
+}
+
+func TestResolverDetectCycles(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "resolve/dependency-cycle", "/test")
+
+	logger := &mockLogger{}
+	result, err := local.New(mfs, logger).WithDetectCycles(true).Resolve("/test")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	// Cycles don't break the import map
+	if result.Imports["pkg-a"] != "/node_modules/pkg-a/index.js" {
+		t.Errorf("Expected pkg-a import, got %v", result.Imports)
+	}
+
+	expected := []string{"cycle: pkg-a -> pkg-b -> pkg-a"}
+	if !reflect.DeepEqual(logger.warnings, expected) {
+		t.Errorf("Expected warnings %v, got %v", expected, logger.warnings)
+	}
+
+	// Detection is off by default
+	logger = &mockLogger{}
+	if _, err := local.New(mfs, logger).Resolve("/test"); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if len(logger.warnings) != 0 {
+		t.Errorf("Expected no warnings without cycle detection, got %v", logger.warnings)
+	}
+}
+
+func TestResolverStaleMain(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "resolve/stale-main", "/test")
+
+	logger := &mockLogger{}
+	result, err := local.New(mfs, logger).Resolve("/test")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if got := result.Imports["stale-lib"]; got != "/node_modules/stale-lib/esm/index.js" {
+		t.Errorf("Expected stale-lib to resolve via exports, got %q", got)
+	}
+	if got := result.Imports["legacy-lib"]; got != "/node_modules/legacy-lib/index.js" {
+		t.Errorf("Expected legacy-lib to fall back to index.js, got %q", got)
+	}
+	expected := []string{`Package 'legacy-lib': main "lib/legacy-lib.js" does not exist, using index.js`}
+	if !reflect.DeepEqual(logger.warnings, expected) {
+		t.Errorf("Expected warnings %v, got %v", expected, logger.warnings)
+	}
+
+	// Conditions that don't match the "." export used to fall back to the dead main
+	logger = &mockLogger{}
+	resolver := local.New(mfs, logger).WithConditions([]string{"browser", "default"})
+	specs := resolver.ResolveSpecifiers("/test", []string{"stale-lib", "legacy-lib"})
+	if got := specs["stale-lib"]; got != "/node_modules/stale-lib/esm/index.js" {
+		t.Errorf("Expected stale-lib to resolve via exports, got %q", got)
+	}
+	if got := specs["legacy-lib"]; got != "/node_modules/legacy-lib/index.js" {
+		t.Errorf("Expected legacy-lib to fall back to index.js, got %q", got)
+	}
+	expected = []string{
+		`Package 'legacy-lib': main "lib/legacy-lib.js" does not exist, using index.js`,
+		`Package 'stale-lib': main "./dist/stale-lib.js" does not exist, using esm/index.js`,
+	}
+	slices.Sort(logger.warnings)
+	if !reflect.DeepEqual(logger.warnings, expected) {
+		t.Errorf("Expected warnings %v, got %v", expected, logger.warnings)
+	}
+}
+
+func TestResolverDedupeReport(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "resolve/duplicate-versions", "/test")
+
+	logger := &mockLogger{}
+	if _, err := local.New(mfs, logger).WithDedupeReport(true).Resolve("/test"); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	expected := []string{
+		"duplicate: lit-html has 2 versions: " +
+			"2.8.0 (node_modules/@acme/ui/node_modules/lit-html, node_modules/legacy-widget/node_modules/lit-html), " +
+			"3.1.0 (node_modules/lit-html)",
+	}
+	if !reflect.DeepEqual(logger.warnings, expected) {
+		t.Errorf("Expected warnings %v, got %v", expected, logger.warnings)
+	}
+
+	// The report is off by default
+	logger = &mockLogger{}
+	if _, err := local.New(mfs, logger).Resolve("/test"); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if len(logger.warnings) != 0 {
+		t.Errorf("Expected no warnings without the dedupe report, got %v", logger.warnings)
+	}
+}
+
+func TestResolverDedupeReportPnpm(t *testing.T) {
+	// a and b are links into the pnpm store, as are their dep dependencies
+	mfs := testutil.NewFixtureFS(t, "resolve/pnpm-versions", "/test")
+
+	logger := &mockLogger{}
+	if _, err := local.New(mfs, logger).WithDedupeReport(true).Resolve("/test"); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	expected := []string{
+		"duplicate: dep has 2 versions: " +
+			"1.0.0 (node_modules/.pnpm/dep@1.0.0/node_modules/dep), " +
+			"2.0.0 (node_modules/.pnpm/dep@2.0.0/node_modules/dep)",
+	}
+	if !reflect.DeepEqual(logger.warnings, expected) {
+		t.Errorf("Expected warnings %v, got %v", expected, logger.warnings)
+	}
+}
+
 func TestResolverAutoDiscoverWorkspaces(t *testing.T) {
 	// Use the existing workspace fixture
 	mfs := testutil.NewFixtureFS(t, "workspace", "/test")
@@ -332,3 +1100,83 @@ func TestResolverExplicitWorkspacesOverrideAutoDiscovery(t *testing.T) {
 		t.Error("Expected @myorg/components to not be auto-discovered when explicit packages provided")
 	}
 }
+
+func TestDiagnose(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "doctor/project", "/test")
+
+	tests := []struct {
+		name             string
+		allowSubpathOnly bool
+		iconSet          local.Check
+	}{
+		{"default", false, local.Check{Subject: "icon-set", Severity: local.CheckWarning, Message: "no root export or main field; only subpath imports will work; import one of its subpaths, or pass --allow-subpath-only if that is intended"}},
+		{"allow subpath only", true, local.Check{Subject: "icon-set", Severity: local.CheckOK, Message: "1.0.0, subpath exports only"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := local.New(mfs, nil).WithAllowSubpathOnly(tt.allowSubpathOnly).Diagnose("/test")
+
+			want := []local.Check{
+				{Subject: "package.json", Severity: local.CheckOK, Message: "parsed"},
+				{Subject: "node_modules", Severity: local.CheckOK, Message: "found /test/node_modules"},
+				{Subject: "broken", Severity: local.CheckError, Message: "cannot read package.json: unexpected end of JSON input; reinstall the package"},
+				tt.iconSet,
+				{Subject: "legacy", Severity: local.CheckWarning, Message: "no root export or main field; only subpath imports will work; import 'legacy/<file>' by path, or ask the package to add an exports field"},
+				{Subject: "lit", Severity: local.CheckOK, Message: "3.0.0"},
+				{Subject: "missing-pkg", Severity: local.CheckError, Message: "not installed in node_modules; install dependencies, e.g. with npm install"},
+			}
+			if !slices.Equal(d.Checks, want) {
+				t.Errorf("Expected checks:\n%v\ngot:\n%v", want, d.Checks)
+			}
+			if got := d.Count(local.CheckError); got != 2 {
+				t.Errorf("Expected 2 errors, got %d", got)
+			}
+		})
+	}
+}
+
+func TestDiagnoseStaleMain(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "resolve/stale-main", "/test")
+
+	tests := []struct {
+		name       string
+		conditions []string
+		staleLib   local.Check
+	}{
+		{"default conditions", nil, local.Check{Subject: "stale-lib", Severity: local.CheckOK, Message: "2.0.0"}},
+		{"unmatched export conditions", []string{"browser", "default"}, local.Check{Subject: "stale-lib", Severity: local.CheckWarning, Message: `main "./dist/stale-lib.js" does not exist, using esm/index.js; reinstall the package, or ask it to fix its package.json`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := local.New(mfs, nil)
+			if tt.conditions != nil {
+				resolver = resolver.WithConditions(tt.conditions)
+			}
+			d := resolver.Diagnose("/test")
+
+			want := []local.Check{
+				{Subject: "package.json", Severity: local.CheckOK, Message: "parsed"},
+				{Subject: "node_modules", Severity: local.CheckOK, Message: "found /test/node_modules"},
+				{Subject: "legacy-lib", Severity: local.CheckWarning, Message: `main "lib/legacy-lib.js" does not exist, using index.js; reinstall the package, or ask it to fix its package.json`},
+				tt.staleLib,
+			}
+			if !slices.Equal(d.Checks, want) {
+				t.Errorf("Expected checks:\n%v\ngot:\n%v", want, d.Checks)
+			}
+		})
+	}
+}
+
+func TestDiagnoseMissingPackageJSON(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "doctor/project/node_modules/lit", "/test")
+	if err := mfs.Remove("/test/package.json"); err != nil {
+		t.Fatalf("Failed to remove package.json: %v", err)
+	}
+
+	d := local.New(mfs, nil).Diagnose("/test")
+	if len(d.Checks) != 1 || d.Checks[0].Subject != "package.json" || d.Checks[0].Severity != local.CheckError {
+		t.Errorf("Expected a single package.json error, got %v", d.Checks)
+	}
+}
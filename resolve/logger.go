@@ -0,0 +1,85 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package resolve
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Severity levels recorded by CollectingLogger.
+const (
+	SeverityWarning = "warning"
+	SeverityDebug   = "debug"
+)
+
+// LogEntry is a single message recorded by a CollectingLogger.
+type LogEntry struct {
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// CollectingLogger is a Logger that accumulates messages in memory so that
+// callers can report them after resolution. It is safe for concurrent use.
+type CollectingLogger struct {
+	mu      sync.Mutex
+	entries []LogEntry
+}
+
+// NewCollectingLogger creates an empty CollectingLogger.
+func NewCollectingLogger() *CollectingLogger {
+	return &CollectingLogger{}
+}
+
+// Warning records a warning message.
+func (l *CollectingLogger) Warning(format string, args ...any) {
+	l.record(SeverityWarning, format, args...)
+}
+
+// Debug records a debug message.
+func (l *CollectingLogger) Debug(format string, args ...any) {
+	l.record(SeverityDebug, format, args...)
+}
+
+func (l *CollectingLogger) record(severity, format string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, LogEntry{
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// Entries returns a copy of all recorded entries in the order they were logged.
+func (l *CollectingLogger) Entries() []LogEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]LogEntry(nil), l.entries...)
+}
+
+// Warnings returns a copy of the recorded warning entries.
+func (l *CollectingLogger) Warnings() []LogEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var warnings []LogEntry
+	for _, entry := range l.entries {
+		if entry.Severity == SeverityWarning {
+			warnings = append(warnings, entry)
+		}
+	}
+	return warnings
+}
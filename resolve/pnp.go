@@ -0,0 +1,354 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package resolve
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	iofs "io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"bennypowers.dev/mappa/fs"
+)
+
+// Yarn Plug'n'Play writes its package locations to one of these files at
+// the project root. The data file is only written when inlining is disabled;
+// otherwise the same JSON is embedded in the loader.
+const (
+	pnpDataFile   = ".pnp.data.json"
+	pnpLoaderFile = ".pnp.cjs"
+)
+
+// pnpStateMarker precedes the JSON runtime state embedded in .pnp.cjs.
+const pnpStateMarker = "RAW_RUNTIME_STATE ="
+
+// PnPManifest maps package names to the directories Yarn Plug'n'Play
+// installed them into. Locations may point inside zip archives in the Yarn
+// cache, e.g. "/app/.yarn/cache/lit-npm-3.1.0-abc.zip/node_modules/lit".
+type PnPManifest struct {
+	// topLevel maps the project's own dependencies to their references.
+	topLevel map[string]pnpLocator
+	// locations maps package name -> reference -> absolute directory.
+	locations map[string]map[string]string
+	// first holds the first registered reference per package, used for
+	// packages the project does not depend on directly.
+	first map[string]string
+}
+
+// pnpLocator identifies one installed package.
+type pnpLocator struct {
+	name      string
+	reference string
+}
+
+// pnpPackageInfo is the subset of a packageRegistryData entry mappa uses.
+type pnpPackageInfo struct {
+	PackageLocation     string              `json:"packageLocation"`
+	PackageDependencies [][]json.RawMessage `json:"packageDependencies"`
+}
+
+// HasPnP reports whether dir contains Yarn Plug'n'Play data.
+func HasPnP(fsys fs.FileSystem, dir string) bool {
+	return fsys.Exists(filepath.Join(dir, pnpDataFile)) ||
+		fsys.Exists(filepath.Join(dir, pnpLoaderFile))
+}
+
+// LoadPnPManifest reads the Plug'n'Play data in rootDir, preferring
+// .pnp.data.json over the state embedded in .pnp.cjs.
+// Returns nil and no error when rootDir has no Plug'n'Play data.
+func LoadPnPManifest(fsys fs.FileSystem, rootDir string) (*PnPManifest, error) {
+	if data, err := fsys.ReadFile(filepath.Join(rootDir, pnpDataFile)); err == nil {
+		return ParsePnPData(data, rootDir)
+	}
+	loader, err := fsys.ReadFile(filepath.Join(rootDir, pnpLoaderFile))
+	if err != nil {
+		if errors.Is(err, iofs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	data, err := extractPnPState(loader)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", pnpLoaderFile, err)
+	}
+	return ParsePnPData(data, rootDir)
+}
+
+// ParsePnPData parses Plug'n'Play runtime state as written to .pnp.data.json.
+// Package locations are resolved relative to rootDir.
+func ParsePnPData(data []byte, rootDir string) (*PnPManifest, error) {
+	var raw struct {
+		PackageRegistryData []json.RawMessage `json:"packageRegistryData"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid Plug'n'Play data: %w", err)
+	}
+
+	m := &PnPManifest{
+		topLevel:  make(map[string]pnpLocator),
+		locations: make(map[string]map[string]string),
+		first:     make(map[string]string),
+	}
+	for _, entry := range raw.PackageRegistryData {
+		var pair []json.RawMessage
+		if err := json.Unmarshal(entry, &pair); err != nil || len(pair) != 2 {
+			return nil, fmt.Errorf("invalid Plug'n'Play registry entry: %s", entry)
+		}
+		var name *string
+		if err := json.Unmarshal(pair[0], &name); err != nil {
+			return nil, fmt.Errorf("invalid Plug'n'Play package name: %s", pair[0])
+		}
+		var versions [][]json.RawMessage
+		if err := json.Unmarshal(pair[1], &versions); err != nil {
+			return nil, fmt.Errorf("invalid Plug'n'Play package versions: %w", err)
+		}
+		for _, version := range versions {
+			if len(version) != 2 {
+				continue
+			}
+			var reference *string
+			var info pnpPackageInfo
+			if json.Unmarshal(version[0], &reference) != nil || json.Unmarshal(version[1], &info) != nil {
+				return nil, fmt.Errorf("invalid Plug'n'Play package entry: %s", version[1])
+			}
+			if name == nil {
+				// The top-level locator lists the project's own dependencies
+				for _, dep := range info.PackageDependencies {
+					if name, loc, ok := parsePnPDependency(dep); ok {
+						m.topLevel[name] = loc
+					}
+				}
+				continue
+			}
+			if reference == nil {
+				continue
+			}
+			if m.locations[*name] == nil {
+				m.locations[*name] = make(map[string]string)
+				m.first[*name] = *reference
+			}
+			m.locations[*name][*reference] = filepath.Join(rootDir, filepath.FromSlash(info.PackageLocation))
+		}
+	}
+	return m, nil
+}
+
+// parsePnPDependency decodes a [name, reference] dependency entry into the
+// name it is imported by and the package it points to. Aliased dependencies
+// use [name, [target, reference]]; missing peers use null.
+func parsePnPDependency(dep []json.RawMessage) (string, pnpLocator, bool) {
+	if len(dep) != 2 {
+		return "", pnpLocator{}, false
+	}
+	var name string
+	if json.Unmarshal(dep[0], &name) != nil {
+		return "", pnpLocator{}, false
+	}
+	var reference string
+	if json.Unmarshal(dep[1], &reference) == nil {
+		return name, pnpLocator{name: name, reference: reference}, true
+	}
+	var alias []string
+	if json.Unmarshal(dep[1], &alias) == nil && len(alias) == 2 {
+		return name, pnpLocator{name: alias[0], reference: alias[1]}, true
+	}
+	return "", pnpLocator{}, false
+}
+
+// extractPnPState returns the JSON runtime state embedded in .pnp.cjs as a
+// single-quoted JavaScript string literal.
+func extractPnPState(loader []byte) ([]byte, error) {
+	_, rest, ok := bytes.Cut(loader, []byte(pnpStateMarker))
+	if !ok {
+		return nil, errors.New("no embedded runtime state; is pnpEnableInlining disabled without .pnp.data.json?")
+	}
+	start := bytes.IndexByte(rest, '\'')
+	if start < 0 {
+		return nil, errors.New("malformed runtime state")
+	}
+
+	var out bytes.Buffer
+	for i := start + 1; i < len(rest); i++ {
+		switch c := rest[i]; c {
+		case '\'':
+			return out.Bytes(), nil
+		case '\\':
+			i++
+			if i < len(rest) && rest[i] != '\n' {
+				// A backslash before a newline is a line continuation
+				out.WriteByte(rest[i])
+			}
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return nil, errors.New("unterminated runtime state")
+}
+
+// PackageLocation returns the directory a package was installed into.
+// The project's own dependency on name decides the version; packages only
+// reachable transitively resolve to their first registered version.
+func (m *PnPManifest) PackageLocation(name string) (string, bool) {
+	if loc, ok := m.topLevel[name]; ok {
+		if dir, ok := m.locations[loc.name][loc.reference]; ok {
+			return dir, true
+		}
+	}
+	ref, ok := m.first[name]
+	if !ok {
+		return "", false
+	}
+	return m.locations[name][ref], true
+}
+
+// PnPFileSystem is an fs.FileSystem that presents a Plug'n'Play install as a
+// flat node_modules directory. Reads of nodeModulesPath/<package>/... are
+// redirected to the package's recorded location, and files inside Yarn
+// cache zip archives are read from the archive. All other paths, and all
+// writes, go to the underlying filesystem.
+type PnPFileSystem struct {
+	fs.FileSystem
+	nodeModulesPath string
+	manifest        *PnPManifest
+
+	mu       sync.Mutex
+	archives map[string]*zip.Reader
+}
+
+// NewPnPFileSystem wraps base so that packages under nodeModulesPath are
+// read from the locations recorded in manifest.
+func NewPnPFileSystem(base fs.FileSystem, nodeModulesPath string, manifest *PnPManifest) *PnPFileSystem {
+	return &PnPFileSystem{
+		FileSystem:      base,
+		nodeModulesPath: nodeModulesPath,
+		manifest:        manifest,
+		archives:        make(map[string]*zip.Reader),
+	}
+}
+
+// locate maps a virtual node_modules path to the package's real location.
+func (p *PnPFileSystem) locate(name string) string {
+	rel, err := filepath.Rel(p.nodeModulesPath, name)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return name
+	}
+	parts := strings.SplitN(filepath.ToSlash(rel), "/", 3)
+	pkgName, rest := parts[0], parts[1:]
+	if strings.HasPrefix(pkgName, "@") && len(parts) > 1 {
+		pkgName, rest = parts[0]+"/"+parts[1], parts[2:]
+	}
+	dir, ok := p.manifest.PackageLocation(pkgName)
+	if !ok {
+		return name
+	}
+	return filepath.Join(append([]string{dir}, rest...)...)
+}
+
+// archive returns the zip archive containing name and the path within it,
+// or a nil reader when name is not inside an archive.
+func (p *PnPFileSystem) archive(name string) (*zip.Reader, string, error) {
+	slashed := filepath.ToSlash(name)
+	idx := strings.Index(slashed+"/", ".zip/")
+	if idx < 0 {
+		return nil, "", nil
+	}
+	archivePath := filepath.FromSlash(slashed[:idx+len(".zip")])
+	inner := strings.Trim(slashed[idx+len(".zip"):], "/")
+	if inner == "" {
+		inner = "."
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if zr, ok := p.archives[archivePath]; ok {
+		return zr, inner, nil
+	}
+	data, err := p.FileSystem.ReadFile(archivePath)
+	if err != nil {
+		return nil, "", err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", archivePath, err)
+	}
+	p.archives[archivePath] = zr
+	return zr, inner, nil
+}
+
+// ReadFile reads a file, following Plug'n'Play package locations.
+func (p *PnPFileSystem) ReadFile(name string) ([]byte, error) {
+	target := p.locate(name)
+	zr, inner, err := p.archive(target)
+	if err != nil {
+		return nil, err
+	}
+	if zr != nil {
+		return iofs.ReadFile(zr, inner)
+	}
+	return p.FileSystem.ReadFile(target)
+}
+
+// Stat returns file information, following Plug'n'Play package locations.
+func (p *PnPFileSystem) Stat(name string) (iofs.FileInfo, error) {
+	target := p.locate(name)
+	zr, inner, err := p.archive(target)
+	if err != nil {
+		return nil, err
+	}
+	if zr != nil {
+		return iofs.Stat(zr, inner)
+	}
+	return p.FileSystem.Stat(target)
+}
+
+// Exists returns true if the path exists, following Plug'n'Play package
+// locations.
+func (p *PnPFileSystem) Exists(path string) bool {
+	_, err := p.Stat(path)
+	return err == nil
+}
+
+// ReadDir reads a directory, following Plug'n'Play package locations.
+func (p *PnPFileSystem) ReadDir(name string) ([]iofs.DirEntry, error) {
+	target := p.locate(name)
+	zr, inner, err := p.archive(target)
+	if err != nil {
+		return nil, err
+	}
+	if zr != nil {
+		return iofs.ReadDir(zr, inner)
+	}
+	return p.FileSystem.ReadDir(target)
+}
+
+// Open opens a file for reading, following Plug'n'Play package locations.
+func (p *PnPFileSystem) Open(name string) (iofs.File, error) {
+	target := p.locate(name)
+	zr, inner, err := p.archive(target)
+	if err != nil {
+		return nil, err
+	}
+	if zr != nil {
+		return zr.Open(inner)
+	}
+	return p.FileSystem.Open(target)
+}
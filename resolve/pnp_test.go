@@ -0,0 +1,167 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package resolve_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+
+	"bennypowers.dev/mappa/internal/mapfs"
+	"bennypowers.dev/mappa/resolve"
+)
+
+// pnpLoader mimics the way Yarn embeds its runtime state in .pnp.cjs.
+const pnpLoader = `#!/usr/bin/env node
+/* eslint-disable */
+"use strict";
+
+const RAW_RUNTIME_STATE =
+'{\
+  "__info": ["Don\'t touch"],\
+  "packageRegistryData": [\
+    [null, [[null, {\
+      "packageLocation": "./",\
+      "packageDependencies": [["lit", "npm:3.0.0"], ["html", ["lit-html", "npm:3.0.0"]]]\
+    }]]],\
+    ["lit", [["npm:3.0.0", {\
+      "packageLocation": "./.yarn/cache/lit-npm-3.0.0-a1b2c3.zip/node_modules/lit/",\
+      "packageDependencies": [["lit", "npm:3.0.0"]]\
+    }]]],\
+    ["lit-html", [\
+      ["npm:2.8.0", {\
+        "packageLocation": "./.yarn/cache/lit-html-npm-2.8.0-d4e5f6.zip/node_modules/lit-html/",\
+        "packageDependencies": []\
+      }],\
+      ["npm:3.0.0", {\
+        "packageLocation": "./.yarn/cache/lit-html-npm-3.0.0-0a1b2c.zip/node_modules/lit-html/",\
+        "packageDependencies": []\
+      }]\
+    ]]\
+  ]\
+}';
+
+function $$SETUP_STATE(hydrateRuntimeState, basePath) {
+  return hydrateRuntimeState(JSON.parse(RAW_RUNTIME_STATE), {basePath: basePath || __dirname});
+}
+`
+
+func TestLoadPnPManifestFromLoader(t *testing.T) {
+	mfs := mapfs.New()
+	mfs.AddFile("/app/.pnp.cjs", pnpLoader, 0644)
+
+	if !resolve.HasPnP(mfs, "/app") {
+		t.Fatal("HasPnP() = false, want true")
+	}
+	if got := resolve.FindWorkspaceRoot(mfs, "/app"); got != "/app" {
+		t.Errorf("FindWorkspaceRoot() = %q, want /app", got)
+	}
+
+	manifest, err := resolve.LoadPnPManifest(mfs, "/app")
+	if err != nil {
+		t.Fatalf("LoadPnPManifest failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"lit", "/app/.yarn/cache/lit-npm-3.0.0-a1b2c3.zip/node_modules/lit"},
+		// lit-html is only reachable through an alias; the first version wins
+		{"lit-html", "/app/.yarn/cache/lit-html-npm-2.8.0-d4e5f6.zip/node_modules/lit-html"},
+		{"html", "/app/.yarn/cache/lit-html-npm-3.0.0-0a1b2c.zip/node_modules/lit-html"},
+	}
+	for _, tt := range tests {
+		got, ok := manifest.PackageLocation(tt.name)
+		if !ok || got != tt.expected {
+			t.Errorf("PackageLocation(%q) = %q, %v; want %q", tt.name, got, ok, tt.expected)
+		}
+	}
+
+	if _, ok := manifest.PackageLocation("missing"); ok {
+		t.Error("PackageLocation(missing) should not be found")
+	}
+}
+
+func TestLoadPnPManifestAbsent(t *testing.T) {
+	mfs := mapfs.New()
+	mfs.AddDir("/app", 0755)
+
+	manifest, err := resolve.LoadPnPManifest(mfs, "/app")
+	if err != nil || manifest != nil {
+		t.Errorf("LoadPnPManifest() = %v, %v; want nil, nil", manifest, err)
+	}
+}
+
+func TestPnPFileSystemReadsZipArchives(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range map[string]string{
+		"node_modules/lit/package.json": `{"name": "lit", "version": "3.0.0"}`,
+		"node_modules/lit/index.js":     `export * from 'lit-html';`,
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	mfs := mapfs.New()
+	mfs.AddFile("/app/.pnp.cjs", pnpLoader, 0644)
+	mfs.AddFile("/app/.yarn/cache/lit-npm-3.0.0-a1b2c3.zip", buf.String(), 0644)
+	mfs.AddFile("/app/src/main.js", "import 'lit';", 0644)
+
+	manifest, err := resolve.LoadPnPManifest(mfs, "/app")
+	if err != nil {
+		t.Fatalf("LoadPnPManifest failed: %v", err)
+	}
+	pnp := resolve.NewPnPFileSystem(mfs, "/app/node_modules", manifest)
+
+	data, err := pnp.ReadFile("/app/node_modules/lit/package.json")
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if got, want := string(data), `{"name": "lit", "version": "3.0.0"}`; got != want {
+		t.Errorf("ReadFile() = %q, want %q", got, want)
+	}
+
+	if !pnp.Exists("/app/node_modules/lit/index.js") {
+		t.Error("Exists(index.js) = false, want true")
+	}
+	if pnp.Exists("/app/node_modules/lit/missing.js") {
+		t.Error("Exists(missing.js) = true, want false")
+	}
+
+	entries, err := pnp.ReadDir("/app/node_modules/lit")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("ReadDir() returned %d entries, want 2", len(entries))
+	}
+
+	// Paths outside node_modules read from the underlying filesystem
+	if _, err := pnp.ReadFile("/app/src/main.js"); err != nil {
+		t.Errorf("ReadFile(src/main.js) failed: %v", err)
+	}
+}
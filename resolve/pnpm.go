@@ -0,0 +1,219 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package resolve
+
+import (
+	"errors"
+	iofs "io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"bennypowers.dev/mappa/fs"
+	"bennypowers.dev/mappa/internal/semver"
+)
+
+// pnpmStoreDir is the virtual store pnpm installs packages into, inside the
+// project's node_modules. Each package lives at
+// .pnpm/<name>@<version>/node_modules/<name>, with scope slashes replaced by
+// "+", and node_modules/<name> is a symlink into the store for direct
+// dependencies only. Each store directory's node_modules also links the
+// package's own dependencies beside it, at the versions it depends on.
+const pnpmStoreDir = ".pnpm"
+
+// HasPnpm reports whether nodeModulesPath contains a pnpm virtual store.
+func HasPnpm(fsys fs.FileSystem, nodeModulesPath string) bool {
+	stat, err := fsys.Stat(filepath.Join(nodeModulesPath, pnpmStoreDir))
+	return err == nil && stat.IsDir()
+}
+
+// PnpmFileSystem is an fs.FileSystem that presents a pnpm install as a flat
+// node_modules directory. Reads of nodeModulesPath/<package>/... for packages
+// without a top-level entry, such as transitive dependencies, are redirected
+// into the virtual store. All other paths, and all writes, go to the
+// underlying filesystem, which follows the top-level symlinks itself.
+type PnpmFileSystem struct {
+	fs.FileSystem
+	nodeModulesPath string
+
+	mu        sync.Mutex
+	locations map[string]string // package name -> store directory, "" if not installed
+}
+
+// NewPnpmFileSystem wraps base so that packages missing from nodeModulesPath
+// are read from its pnpm virtual store.
+func NewPnpmFileSystem(base fs.FileSystem, nodeModulesPath string) *PnpmFileSystem {
+	return &PnpmFileSystem{
+		FileSystem:      base,
+		nodeModulesPath: nodeModulesPath,
+		locations:       make(map[string]string),
+	}
+}
+
+// PackageLocation returns the store directory a package was installed into.
+// The version pnpm hoisted into .pnpm/node_modules is preferred, as that is
+// the one Node finds from inside the store; otherwise the highest installed
+// version is used.
+func (p *PnpmFileSystem) PackageLocation(name string) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if dir, ok := p.locations[name]; ok {
+		return dir, dir != ""
+	}
+
+	storePath := filepath.Join(p.nodeModulesPath, pnpmStoreDir)
+	dir := ""
+	if hoisted := filepath.Join(storePath, "node_modules", filepath.FromSlash(name)); p.FileSystem.Exists(hoisted) {
+		dir = hoisted
+	} else if entries, err := p.FileSystem.ReadDir(storePath); err == nil {
+		prefix := strings.ReplaceAll(name, "/", "+") + "@"
+		highest := ""
+		for _, entry := range entries {
+			version, ok := strings.CutPrefix(entry.Name(), prefix)
+			if !ok {
+				continue
+			}
+			// Packages with peer dependencies have them appended, as in
+			// lit@3.0.0_react@18.2.0 or lit@3.0.0(react@18.2.0)
+			if i := strings.IndexAny(version, "_("); i >= 0 {
+				version = version[:i]
+			}
+			if highest != "" && semver.Compare(version, highest) <= 0 {
+				continue
+			}
+			candidate := filepath.Join(storePath, entry.Name(), "node_modules", filepath.FromSlash(name))
+			if p.FileSystem.Exists(candidate) {
+				dir, highest = candidate, version
+			}
+		}
+	}
+	p.locations[name] = dir
+	return dir, dir != ""
+}
+
+// DependencyLocation returns the directory dep resolves to from the package
+// at pkgPath, which is either under nodeModulesPath or in the store. pnpm
+// links each package's dependencies beside it in its store directory, so
+// packages that depend on different versions of dep each find their own.
+// When that is the version presented at nodeModulesPath/<dep>, or pkgPath
+// has no dep beside it, that flat path is returned.
+func (p *PnpmFileSystem) DependencyLocation(pkgPath, dep string) string {
+	flat := filepath.Join(p.nodeModulesPath, filepath.FromSlash(dep))
+	modules, ok := p.storeModulesDir(pkgPath)
+	if !ok {
+		return flat
+	}
+	dir := p.realPath(filepath.Join(modules, filepath.FromSlash(dep)))
+	if !p.FileSystem.Exists(dir) || dir == p.realPath(p.locate(flat)) {
+		return flat
+	}
+	return dir
+}
+
+// storeModulesDir returns the node_modules directory of the store entry the
+// package at pkgPath is installed in, which also holds its dependencies.
+func (p *PnpmFileSystem) storeModulesDir(pkgPath string) (string, bool) {
+	storePath := filepath.Join(p.nodeModulesPath, pnpmStoreDir)
+	rel, err := filepath.Rel(storePath, p.realPath(p.locate(pkgPath)))
+	if err != nil {
+		return "", false
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if len(parts) < 3 || parts[0] == ".." || parts[0] == "node_modules" || parts[1] != "node_modules" {
+		return "", false
+	}
+	return filepath.Join(storePath, parts[0], "node_modules"), true
+}
+
+// realPath follows a symlink at name, such as the links pnpm makes from
+// node_modules and from each store entry to the entries of its dependencies.
+func (p *PnpmFileSystem) realPath(name string) string {
+	if target, ok := fs.LinkTarget(p.FileSystem, name); ok {
+		return target
+	}
+	return name
+}
+
+// locate maps a virtual node_modules path to the package's store location
+// when the package has no top-level entry.
+func (p *PnpmFileSystem) locate(name string) string {
+	rel, err := filepath.Rel(p.nodeModulesPath, name)
+	if err != nil || rel == "." || strings.HasPrefix(rel, ".") {
+		return name
+	}
+	parts := strings.SplitN(filepath.ToSlash(rel), "/", 3)
+	pkgName, rest := parts[0], parts[1:]
+	if strings.HasPrefix(pkgName, "@") {
+		if len(parts) < 2 {
+			return name
+		}
+		pkgName, rest = parts[0]+"/"+parts[1], parts[2:]
+	}
+	if p.FileSystem.Exists(filepath.Join(p.nodeModulesPath, filepath.FromSlash(pkgName))) {
+		return name
+	}
+	dir, ok := p.PackageLocation(pkgName)
+	if !ok {
+		return name
+	}
+	return filepath.Join(append([]string{dir}, rest...)...)
+}
+
+// ReadFile reads a file, following pnpm store locations.
+func (p *PnpmFileSystem) ReadFile(name string) ([]byte, error) {
+	return p.FileSystem.ReadFile(p.locate(name))
+}
+
+// Stat returns file information, following pnpm store locations.
+func (p *PnpmFileSystem) Stat(name string) (iofs.FileInfo, error) {
+	return p.FileSystem.Stat(p.locate(name))
+}
+
+// Exists returns true if the path exists, following pnpm store locations.
+func (p *PnpmFileSystem) Exists(path string) bool {
+	return p.FileSystem.Exists(p.locate(path))
+}
+
+// ReadDir reads a directory, following pnpm store locations.
+func (p *PnpmFileSystem) ReadDir(name string) ([]iofs.DirEntry, error) {
+	return p.FileSystem.ReadDir(p.locate(name))
+}
+
+// Open opens a file for reading, following pnpm store locations.
+func (p *PnpmFileSystem) Open(name string) (iofs.File, error) {
+	return p.FileSystem.Open(p.locate(name))
+}
+
+// Lstat returns file information without following a final symbolic link,
+// so linked workspace packages can be told apart from store installs.
+func (p *PnpmFileSystem) Lstat(name string) (iofs.FileInfo, error) {
+	links, ok := p.FileSystem.(iofs.ReadLinkFS)
+	if !ok {
+		return nil, &iofs.PathError{Op: "lstat", Path: name, Err: errors.ErrUnsupported}
+	}
+	return links.Lstat(p.locate(name))
+}
+
+// ReadLink returns the destination of the named symbolic link.
+func (p *PnpmFileSystem) ReadLink(name string) (string, error) {
+	links, ok := p.FileSystem.(iofs.ReadLinkFS)
+	if !ok {
+		return "", &iofs.PathError{Op: "readlink", Path: name, Err: errors.ErrUnsupported}
+	}
+	return links.ReadLink(p.locate(name))
+}
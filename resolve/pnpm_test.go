@@ -0,0 +1,106 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package resolve_test
+
+import (
+	"testing"
+
+	"bennypowers.dev/mappa/internal/mapfs"
+	"bennypowers.dev/mappa/resolve"
+)
+
+func TestPnpmFileSystem(t *testing.T) {
+	mfs := mapfs.New()
+	store := "/app/node_modules/.pnpm"
+	mfs.AddFile("/app/node_modules/lit/package.json", `{"name": "lit", "version": "3.0.0"}`, 0644)
+	mfs.AddFile(store+"/lit-html@2.8.0/node_modules/lit-html/package.json", `{"name": "lit-html", "version": "2.8.0"}`, 0644)
+	mfs.AddFile(store+"/lit-html@3.0.0/node_modules/lit-html/package.json", `{"name": "lit-html", "version": "3.0.0"}`, 0644)
+	mfs.AddFile(store+"/lit-html@3.1.0_typescript@5.0.0/node_modules/lit-html/package.json", `{"name": "lit-html", "version": "3.1.0"}`, 0644)
+	mfs.AddFile(store+"/@lit+reactive-element@2.0.0/node_modules/@lit/reactive-element/package.json", `{"name": "@lit/reactive-element"}`, 0644)
+
+	if !resolve.HasPnpm(mfs, "/app/node_modules") {
+		t.Fatal("HasPnpm() = false, want true")
+	}
+	pnpm := resolve.NewPnpmFileSystem(mfs, "/app/node_modules")
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/app/node_modules/lit/package.json", `{"name": "lit", "version": "3.0.0"}`},
+		// The highest installed version, by semver rather than directory order
+		{"/app/node_modules/lit-html/package.json", `{"name": "lit-html", "version": "3.1.0"}`},
+		{"/app/node_modules/@lit/reactive-element/package.json", `{"name": "@lit/reactive-element"}`},
+	}
+	for _, tt := range tests {
+		data, err := pnpm.ReadFile(tt.path)
+		if err != nil {
+			t.Errorf("ReadFile(%s) failed: %v", tt.path, err)
+			continue
+		}
+		if string(data) != tt.want {
+			t.Errorf("ReadFile(%s) = %s, want %s", tt.path, data, tt.want)
+		}
+	}
+
+	// The version hoisted into the store's own node_modules is preferred
+	mfs.AddFile(store+"/node_modules/lit-html/package.json", `{"name": "lit-html", "version": "3.0.0"}`, 0644)
+	hoisted := resolve.NewPnpmFileSystem(mfs, "/app/node_modules")
+	if dir, ok := hoisted.PackageLocation("lit-html"); !ok || dir != store+"/node_modules/lit-html" {
+		t.Errorf("PackageLocation(lit-html) = %q, %v; want hoisted store entry", dir, ok)
+	}
+
+	if pnpm.Exists("/app/node_modules/missing/package.json") {
+		t.Error("Exists() = true for a package that is not installed")
+	}
+}
+
+func TestPnpmFileSystemDependencyLocation(t *testing.T) {
+	mfs := mapfs.New()
+	store := "/app/node_modules/.pnpm"
+	mfs.AddSymlink("/app/node_modules/a", ".pnpm/a@1.0.0/node_modules/a")
+	mfs.AddFile(store+"/a@1.0.0/node_modules/a/package.json", `{"name": "a"}`, 0644)
+	mfs.AddSymlink(store+"/a@1.0.0/node_modules/dep", "../../dep@1.0.0/node_modules/dep")
+	mfs.AddFile(store+"/b@1.0.0/node_modules/b/package.json", `{"name": "b"}`, 0644)
+	mfs.AddSymlink(store+"/b@1.0.0/node_modules/dep", "../../dep@2.0.0/node_modules/dep")
+	mfs.AddFile(store+"/dep@1.0.0/node_modules/dep/package.json", `{"name": "dep", "version": "1.0.0"}`, 0644)
+	mfs.AddFile(store+"/dep@2.0.0/node_modules/dep/package.json", `{"name": "dep", "version": "2.0.0"}`, 0644)
+
+	pnpm := resolve.NewPnpmFileSystem(mfs, "/app/node_modules")
+
+	tests := []struct {
+		name    string
+		pkgPath string
+		dep     string
+		want    string
+	}{
+		{"direct dependency links a store version", "/app/node_modules/a", "dep", store + "/dep@1.0.0/node_modules/dep"},
+		// dep@2.0.0 is what /app/node_modules/dep presents, so the flat path is kept
+		{"transitive dependency", "/app/node_modules/b", "dep", "/app/node_modules/dep"},
+		{"store path", store + "/b@1.0.0/node_modules/b", "dep", "/app/node_modules/dep"},
+		{"dependency not beside the package", "/app/node_modules/a", "missing", "/app/node_modules/missing"},
+		{"package outside the store", "/app/node_modules/dep", "other", "/app/node_modules/other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pnpm.DependencyLocation(tt.pkgPath, tt.dep); got != tt.want {
+				t.Errorf("DependencyLocation(%s, %s) = %s, want %s", tt.pkgPath, tt.dep, got, tt.want)
+			}
+		})
+	}
+}
@@ -45,7 +45,8 @@ type WorkspacePackage struct {
 }
 
 // FindWorkspaceRoot walks up the directory tree to find the workspace root.
-// Returns the directory containing node_modules, workspace configuration, or .git.
+// Returns the directory containing node_modules, Yarn Plug'n'Play data,
+// workspace configuration, or .git.
 func FindWorkspaceRoot(fs fs.FileSystem, startDir string) string {
 	dir := startDir
 	for {
@@ -55,15 +56,7 @@ func FindWorkspaceRoot(fs fs.FileSystem, startDir string) string {
 			return dir
 		}
 
-		// Check if there's a package.json with workspaces field
-		pkgPath := filepath.Join(dir, "package.json")
-		if pkg, err := packagejson.ParseFile(fs, pkgPath); err == nil && pkg.HasWorkspaces() {
-			return dir
-		}
-
-		// Check for .git directory (repository root is a reasonable workspace root)
-		gitDir := filepath.Join(dir, ".git")
-		if stat, err := fs.Stat(gitDir); err == nil && stat.IsDir() {
+		if isWorkspaceBoundary(fs, dir) {
 			return dir
 		}
 
@@ -76,6 +69,74 @@ func FindWorkspaceRoot(fs fs.FileSystem, startDir string) string {
 	}
 }
 
+// isWorkspaceBoundary reports whether dir is the top of a project: it holds
+// Yarn Plug'n'Play data, workspace configuration, or .git.
+func isWorkspaceBoundary(fs fs.FileSystem, dir string) bool {
+	// Yarn Plug'n'Play installs have no node_modules directory
+	if HasPnP(fs, dir) {
+		return true
+	}
+
+	// Check if there's a package.json with workspaces field
+	pkgPath := filepath.Join(dir, "package.json")
+	if pkg, err := packagejson.ParseFile(fs, pkgPath); err == nil && pkg.HasWorkspaces() {
+		return true
+	}
+
+	// pnpm declares workspaces in pnpm-workspace.yaml instead
+	if fs.Exists(filepath.Join(dir, "pnpm-workspace.yaml")) {
+		return true
+	}
+
+	// Check for .git directory (repository root is a reasonable workspace root)
+	gitDir := filepath.Join(dir, ".git")
+	stat, err := fs.Stat(gitDir)
+	return err == nil && stat.IsDir()
+}
+
+// workspaceBoundary walks up from startDir to the nearest directory that
+// isWorkspaceBoundary, or returns false when there is none.
+func workspaceBoundary(fs fs.FileSystem, startDir string) (string, bool) {
+	dir := startDir
+	for {
+		if isWorkspaceBoundary(fs, dir) {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// NodeModulesPaths returns the existing node_modules directories from startDir up
+// to the workspace root, nearest first, mirroring Node's package lookup. With
+// yarn nohoist, a package's own node_modules precedes the workspace root's.
+// The walk stops at the nearest workspace configuration, Plug'n'Play data, or
+// .git; outside any workspace, at the nearest node_modules. Unrelated
+// node_modules above the project are never searched.
+func NodeModulesPaths(fs fs.FileSystem, startDir string) []string {
+	stopDir, ok := workspaceBoundary(fs, startDir)
+	if !ok {
+		stopDir = FindWorkspaceRoot(fs, startDir)
+	}
+
+	var paths []string
+	dir := startDir
+	for {
+		nodeModulesPath := filepath.Join(dir, "node_modules")
+		if stat, err := fs.Stat(nodeModulesPath); err == nil && stat.IsDir() {
+			paths = append(paths, nodeModulesPath)
+		}
+		parent := filepath.Dir(dir)
+		if dir == stopDir || parent == dir {
+			return paths
+		}
+		dir = parent
+	}
+}
+
 // ToWebPath converts a filesystem path relative to rootDir into a web path.
 // e.g., "node_modules/lit" -> "/node_modules/lit"
 func ToWebPath(rootDir, fullPath string) string {
@@ -17,6 +17,7 @@ along with this program. If not, see <http://www.gnu.org/licenses/>.
 package resolve_test
 
 import (
+	"slices"
 	"testing"
 
 	"bennypowers.dev/mappa/internal/mapfs"
@@ -48,6 +49,15 @@ func TestFindWorkspaceRoot(t *testing.T) {
 			startDir: "/root/packages/pkg1",
 			expected: "/root",
 		},
+		{
+			name: "root with pnpm-workspace.yaml",
+			setup: func(mfs *mapfs.MapFileSystem) {
+				mfs.AddFile("/root/pnpm-workspace.yaml", "packages:\n  - packages/*\n", 0644)
+				mfs.AddDir("/root/packages/pkg1", 0755)
+			},
+			startDir: "/root/packages/pkg1",
+			expected: "/root",
+		},
 		{
 			name: "root with .git",
 			setup: func(mfs *mapfs.MapFileSystem) {
@@ -245,3 +255,59 @@ func TestDiscoverWorkspacePackages(t *testing.T) {
 		})
 	}
 }
+
+func TestCollectingLogger(t *testing.T) {
+	logger := resolve.NewCollectingLogger()
+	var _ resolve.Logger = logger
+
+	logger.Warning("Dependency %s not found in node_modules", "lit")
+	logger.Debug("Resolved %d packages", 3)
+	logger.Warning("Package %s has no exports or main field", "empty")
+
+	entries := logger.Entries()
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d: %v", len(entries), entries)
+	}
+	if entries[1].Severity != resolve.SeverityDebug || entries[1].Message != "Resolved 3 packages" {
+		t.Errorf("Unexpected debug entry: %+v", entries[1])
+	}
+
+	warnings := logger.Warnings()
+	if len(warnings) != 2 {
+		t.Fatalf("Expected 2 warnings, got %d: %v", len(warnings), warnings)
+	}
+	if warnings[0].Message != "Dependency lit not found in node_modules" {
+		t.Errorf("Unexpected first warning: %q", warnings[0].Message)
+	}
+	if warnings[1].Severity != resolve.SeverityWarning {
+		t.Errorf("Expected severity %q, got %q", resolve.SeverityWarning, warnings[1].Severity)
+	}
+}
+
+func TestNodeModulesPaths(t *testing.T) {
+	mfs := mapfs.New()
+	mfs.AddFile("/root/package.json", `{"workspaces": ["packages/*"]}`, 0644)
+	mfs.AddDir("/root/node_modules", 0755)
+	mfs.AddDir("/root/packages/app/node_modules", 0755)
+	mfs.AddDir("/root/packages/lib", 0755)
+	mfs.AddDir("/standalone/node_modules", 0755)
+	mfs.AddDir("/node_modules", 0755)
+
+	tests := []struct {
+		startDir string
+		expected []string
+	}{
+		{"/root/packages/app", []string{"/root/packages/app/node_modules", "/root/node_modules"}},
+		{"/root/packages/lib", []string{"/root/node_modules"}},
+		{"/root", []string{"/root/node_modules"}},
+		// Outside any workspace, the project's own node_modules is the last
+		{"/standalone", []string{"/standalone/node_modules"}},
+	}
+
+	for _, tt := range tests {
+		got := resolve.NodeModulesPaths(mfs, tt.startDir)
+		if !slices.Equal(got, tt.expected) {
+			t.Errorf("NodeModulesPaths(%q) = %v, want %v", tt.startDir, got, tt.expected)
+		}
+	}
+}
@@ -18,6 +18,7 @@ package resolve
 
 import (
 	"fmt"
+	"path"
 	"regexp"
 	"slices"
 	"strings"
@@ -28,27 +29,48 @@ import (
 //   - {package} - Full package name (e.g., "@scope/name" or "name")
 //   - {name} - Package name without scope
 //   - {scope} - Scope without @ prefix (empty for unscoped)
-//   - {version} - Resolved version (for CDN resolution)
+//   - {version} - Package version (resolved for CDN, from package.json for local)
 //   - {path} - Relative path within the package
+//
+// A variable may name a built-in transform after a colon, e.g. {path:lower}.
+// See TemplateTransforms.
 type Template struct {
 	pattern   string
 	variables []string
+	segments  []templateSegment
+	transform func(pkg, version, path string) string
 }
 
-var variablePattern = regexp.MustCompile(`\{(\w+)\}`)
+// templateSegment is a literal run of a template pattern, followed by the
+// variable after it, if any.
+type templateSegment struct {
+	literal   string
+	variable  string
+	transform func(string) string
+}
+
+var variablePattern = regexp.MustCompile(`\{(\w+)(?::(\w+))?\}`)
+
+// TemplateTransforms are the built-in transforms a template variable can
+// name after a colon:
+//   - lower - lowercase the value, e.g. {path:lower}
+//   - upper - uppercase the value
+//   - noext - remove the file extension, e.g. {path:noext}.mjs
+var TemplateTransforms = map[string]func(string) string{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"noext": func(value string) string {
+		return strings.TrimSuffix(value, path.Ext(value))
+	},
+}
 
-// ParseTemplate parses a URL template pattern.
+// ParseTemplate parses a URL template pattern. The pattern must use {path},
+// since otherwise every module in a package would map to the same URL.
 func ParseTemplate(pattern string) (*Template, error) {
 	if pattern == "" {
 		return nil, fmt.Errorf("template pattern cannot be empty")
 	}
 
-	matches := variablePattern.FindAllStringSubmatch(pattern, -1)
-	var variables []string
-	for _, match := range matches {
-		variables = append(variables, match[1])
-	}
-
 	// Validate variables
 	validVars := map[string]bool{
 		"package": true,
@@ -57,30 +79,96 @@ func ParseTemplate(pattern string) (*Template, error) {
 		"version": true,
 		"path":    true,
 	}
-	for _, v := range variables {
-		if !validVars[v] {
-			return nil, fmt.Errorf("unknown template variable: {%s}", v)
+
+	var variables []string
+	var segments []templateSegment
+	last := 0
+	for _, loc := range variablePattern.FindAllStringSubmatchIndex(pattern, -1) {
+		variable := pattern[loc[2]:loc[3]]
+		if !validVars[variable] {
+			return nil, fmt.Errorf("unknown template variable: {%s}", variable)
+		}
+		segment := templateSegment{literal: pattern[last:loc[0]], variable: variable}
+		if loc[4] >= 0 {
+			name := pattern[loc[4]:loc[5]]
+			if segment.transform = TemplateTransforms[name]; segment.transform == nil {
+				return nil, fmt.Errorf("unknown template transform %q in %s", name, pattern[loc[0]:loc[1]])
+			}
 		}
+		variables = append(variables, variable)
+		segments = append(segments, segment)
+		last = loc[1]
+	}
+	segments = append(segments, templateSegment{literal: pattern[last:]})
+
+	// Without {path}, every file in a package expands to the same URL.
+	if !slices.Contains(variables, "path") {
+		return nil, fmt.Errorf("template %q has no {path} variable, so every module in a package would map to the same URL", pattern)
 	}
 
 	return &Template{
 		pattern:   pattern,
 		variables: variables,
+		segments:  segments,
 	}, nil
 }
 
-// Expand substitutes variables in the template with actual values.
+// WithTransform returns a copy of the template that passes each package path
+// through transform before expanding it, so that library users can map paths
+// to their own URL scheme, e.g. hashed file names. transform receives the
+// package name, version and package-relative path, which is empty or ends
+// with "/" for directory prefixes, and returns the path to substitute for
+// {path}. Built-in transforms named in the pattern apply to its result.
+func (t *Template) WithTransform(transform func(pkg, version, path string) string) *Template {
+	return &Template{
+		pattern:   t.pattern,
+		variables: t.variables,
+		segments:  t.segments,
+		transform: transform,
+	}
+}
+
+// Expand substitutes variables in the template with actual values. A path
+// that is empty or ends with "/" is a directory prefix: the browser appends
+// the rest of the specifier to its URL, so Expand omits any literal after
+// the last variable, e.g. the ".min.js" in {path:noext}.min.js, and ends the
+// URL with "/".
 func (t *Template) Expand(pkg, version, path string) string {
 	name, scope := SplitPackageName(pkg)
+	dir := path == "" || strings.HasSuffix(path, "/")
+	if t.transform != nil {
+		path = t.transform(pkg, version, path)
+	}
 
-	result := t.pattern
-	result = strings.ReplaceAll(result, "{package}", pkg)
-	result = strings.ReplaceAll(result, "{name}", name)
-	result = strings.ReplaceAll(result, "{scope}", scope)
-	result = strings.ReplaceAll(result, "{version}", version)
-	result = strings.ReplaceAll(result, "{path}", path)
-
-	return result
+	var b strings.Builder
+	for _, segment := range t.segments {
+		if segment.variable != "" || !dir {
+			b.WriteString(segment.literal)
+		}
+		var value string
+		switch segment.variable {
+		case "":
+			if dir && !strings.HasSuffix(b.String(), "/") {
+				b.WriteString("/")
+			}
+			continue
+		case "package":
+			value = pkg
+		case "name":
+			value = name
+		case "scope":
+			value = scope
+		case "version":
+			value = version
+		case "path":
+			value = path
+		}
+		if segment.transform != nil {
+			value = segment.transform(value)
+		}
+		b.WriteString(value)
+	}
+	return b.String()
 }
 
 // Pattern returns the original template pattern.
@@ -88,13 +176,15 @@ func (t *Template) Pattern() string {
 	return t.pattern
 }
 
-// Variables returns the list of variables used in the template.
+// Variables returns the variables used in the template, in the order they
+// appear in the pattern.
 func (t *Template) Variables() []string {
-	return t.variables
+	return slices.Clone(t.variables)
 }
 
 // HasVersion returns true if the template contains a {version} variable.
-// Templates with version variables require lockfile resolution (CDN mode).
+// CDN resolution takes versions from the lockfile or registry; local
+// resolution reads them from each package's package.json.
 func (t *Template) HasVersion() bool {
 	return slices.Contains(t.variables, "version")
 }
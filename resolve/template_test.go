@@ -18,6 +18,7 @@ package resolve_test
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 
 	"bennypowers.dev/mappa/resolve"
@@ -46,11 +47,26 @@ func TestParseTemplate(t *testing.T) {
 			pattern:  "/libs/{scope}/{name}/{path}",
 			wantVars: []string{"scope", "name", "path"},
 		},
+		{
+			name:     "transformed variables",
+			pattern:  "/assets/{package:lower}/{path:noext}.mjs",
+			wantVars: []string{"package", "path"},
+		},
 		{
 			name:    "invalid variable",
 			pattern: "/foo/{invalid}/{path}",
 			wantErr: true,
 		},
+		{
+			name:    "invalid transform",
+			pattern: "/foo/{package}/{path:hash}",
+			wantErr: true,
+		},
+		{
+			name:    "missing path",
+			pattern: "https://cdn.example.com/{package}@{version}",
+			wantErr: true,
+		},
 		{
 			name:    "empty pattern",
 			pattern: "",
@@ -126,6 +142,62 @@ func TestTemplate_Expand(t *testing.T) {
 			path:     "index.js",
 			expected: "https://unpkg.com/lit@2.0.0/index.js",
 		},
+		{
+			name:     "lowercase path",
+			pattern:  "/assets/{package}/{path:lower}",
+			pkg:      "@PatternFly/Elements",
+			version:  "4.0.0",
+			path:     "pf-Card/PfCard.js",
+			expected: "/assets/@PatternFly/Elements/pf-card/pfcard.js",
+		},
+		{
+			name:     "uppercase scope and name",
+			pattern:  "/{scope:upper}/{name:upper}/{path}",
+			pkg:      "@lit/reactive-element",
+			version:  "1.0.0",
+			path:     "decorators.js",
+			expected: "/LIT/REACTIVE-ELEMENT/decorators.js",
+		},
+		{
+			name:     "path without extension",
+			pattern:  "/assets/{package}/{path:noext}.min.js",
+			pkg:      "lit",
+			version:  "3.0.0",
+			path:     "directives/class-map.js",
+			expected: "/assets/lit/directives/class-map.min.js",
+		},
+		{
+			name:     "directory prefix without extension",
+			pattern:  "/assets/{package}/{path:noext}",
+			pkg:      "lit",
+			version:  "3.0.0",
+			path:     "directives/",
+			expected: "/assets/lit/directives/",
+		},
+		{
+			name:     "directory prefix drops literal suffix",
+			pattern:  "/assets/{package}/{path:noext}.min.js",
+			pkg:      "lit",
+			version:  "3.0.0",
+			path:     "decorators/",
+			expected: "/assets/lit/decorators/",
+		},
+		{
+			name:     "package root drops literal suffix",
+			pattern:  "/assets/{package}/{path:noext}.min.js",
+			pkg:      "lit",
+			version:  "3.0.0",
+			path:     "",
+			expected: "/assets/lit/",
+		},
+		{
+			name:     "package root drops query",
+			pattern:  "https://cdn.example.com/{package}@{version}/{path}?module",
+			pkg:      "lit",
+			version:  "3.0.0",
+			path:     "",
+			expected: "https://cdn.example.com/lit@3.0.0/",
+		},
 	}
 
 	for _, tt := range tests {
@@ -143,6 +215,42 @@ func TestTemplate_Expand(t *testing.T) {
 	}
 }
 
+func TestTemplate_WithTransform(t *testing.T) {
+	tmpl, err := resolve.ParseTemplate("/assets/{package}@{version}/{path:lower}")
+	if err != nil {
+		t.Fatalf("ParseTemplate failed: %v", err)
+	}
+
+	hashed := tmpl.WithTransform(func(pkg, version, path string) string {
+		if path == "" || strings.HasSuffix(path, "/") {
+			return path
+		}
+		return strings.TrimSuffix(path, ".js") + "." + pkg + "-" + version + ".js"
+	})
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"Index.js", "/assets/lit@3.0.0/index.lit-3.0.0.js"},
+		{"directives/", "/assets/lit@3.0.0/directives/"},
+		{"", "/assets/lit@3.0.0/"},
+	}
+	for _, tt := range tests {
+		if got := hashed.Expand("lit", "3.0.0", tt.path); got != tt.expected {
+			t.Errorf("Expand(%q) = %q, want %q", tt.path, got, tt.expected)
+		}
+	}
+
+	// The original template is unchanged
+	if got := tmpl.Expand("lit", "3.0.0", "Index.js"); got != "/assets/lit@3.0.0/index.js" {
+		t.Errorf("Expand() on original template = %q, want %q", got, "/assets/lit@3.0.0/index.js")
+	}
+	if hashed.Pattern() != tmpl.Pattern() {
+		t.Errorf("Pattern() = %q, want %q", hashed.Pattern(), tmpl.Pattern())
+	}
+}
+
 func TestTemplate_HasVersion(t *testing.T) {
 	tests := []struct {
 		pattern  string
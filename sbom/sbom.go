@@ -0,0 +1,260 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+// Package sbom builds CycloneDX software bills of materials listing the
+// packages behind an import map.
+package sbom
+
+import (
+	"cmp"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"bennypowers.dev/mappa/fs"
+	"bennypowers.dev/mappa/packagejson"
+	"bennypowers.dev/mappa/resolve"
+	cdnresolver "bennypowers.dev/mappa/resolve/cdn"
+)
+
+// SpecVersion is the CycloneDX specification version of generated BOMs.
+const SpecVersion = "1.5"
+
+// BOM is a CycloneDX bill of materials.
+type BOM struct {
+	BOMFormat    string       `json:"bomFormat"`
+	SpecVersion  string       `json:"specVersion"`
+	Version      int          `json:"version"`
+	Metadata     Metadata     `json:"metadata"`
+	Components   []Component  `json:"components"`
+	Dependencies []Dependency `json:"dependencies,omitempty"`
+}
+
+// Metadata describes the BOM's subject, the root package.
+type Metadata struct {
+	Component *Component `json:"component,omitempty"`
+}
+
+// Component is a package in the BOM. Scoped npm packages keep their scope
+// in Group, as CycloneDX recommends, e.g. "@lit" and "reactive-element".
+type Component struct {
+	Type               string              `json:"type"`
+	BOMRef             string              `json:"bom-ref"`
+	Group              string              `json:"group,omitempty"`
+	Name               string              `json:"name"`
+	Version            string              `json:"version,omitempty"`
+	PURL               string              `json:"purl"`
+	ExternalReferences []ExternalReference `json:"externalReferences,omitempty"`
+}
+
+// ExternalReference links a component to where it is served from.
+type ExternalReference struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+// Dependency lists the components a component directly depends on, by bom-ref.
+type Dependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+// Package is a resolved package to list in a BOM.
+type Package struct {
+	Name    string
+	Version string
+	// URL is where the package is served from, e.g. its CDN base URL.
+	URL string
+	// Dependencies maps each direct dependency's name to its resolved version.
+	Dependencies map[string]string
+}
+
+// New builds a BOM for root and the resolved packages it depends on.
+// Components are sorted by name and version.
+func New(root Package, packages []Package) *BOM {
+	rootComponent := component("application", root)
+	bom := &BOM{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: SpecVersion,
+		Version:     1,
+		Metadata:    Metadata{Component: &rootComponent},
+		Components:  []Component{},
+	}
+
+	packages = slices.Clone(packages)
+	slices.SortFunc(packages, func(a, b Package) int {
+		return cmp.Or(strings.Compare(a.Name, b.Name), strings.Compare(a.Version, b.Version))
+	})
+
+	bom.Dependencies = append(bom.Dependencies, dependency(root))
+	for _, pkg := range packages {
+		bom.Components = append(bom.Components, component("library", pkg))
+		bom.Dependencies = append(bom.Dependencies, dependency(pkg))
+	}
+	return bom
+}
+
+// PURL returns the package URL for an npm package, e.g.
+// "pkg:npm/%40lit/reactive-element@2.0.0". The version is omitted if empty.
+func PURL(name, version string) string {
+	purl := "pkg:npm/" + strings.Replace(name, "@", "%40", 1)
+	if version != "" {
+		purl += "@" + version
+	}
+	return purl
+}
+
+// component describes pkg as a CycloneDX component of the given type.
+func component(componentType string, pkg Package) Component {
+	c := Component{
+		Type:    componentType,
+		BOMRef:  PURL(pkg.Name, pkg.Version),
+		Name:    pkg.Name,
+		Version: pkg.Version,
+		PURL:    PURL(pkg.Name, pkg.Version),
+	}
+	if scope, name, ok := strings.Cut(pkg.Name, "/"); ok && strings.HasPrefix(scope, "@") {
+		c.Group = scope
+		c.Name = name
+	}
+	if pkg.URL != "" {
+		c.ExternalReferences = []ExternalReference{{Type: "distribution", URL: pkg.URL}}
+	}
+	return c
+}
+
+// dependency lists pkg's dependencies by bom-ref, sorted.
+func dependency(pkg Package) Dependency {
+	dep := Dependency{Ref: PURL(pkg.Name, pkg.Version)}
+	for _, name := range slices.Sorted(maps.Keys(pkg.Dependencies)) {
+		dep.DependsOn = append(dep.DependsOn, PURL(name, pkg.Dependencies[name]))
+	}
+	return dep
+}
+
+// FromResolutionGraph builds a BOM from a CDN resolution graph. Each package's
+// URL is the template expanded with an empty path, e.g.
+// "https://esm.sh/lit@3.0.0/".
+func FromResolutionGraph(root *packagejson.PackageJSON, graph *cdnresolver.ResolutionGraph, tmpl *resolve.Template) *BOM {
+	rootPkg := Package{Name: root.Name, Version: root.Version, Dependencies: make(map[string]string)}
+	for _, ref := range graph.Roots {
+		// Split at the version separator, not a scope's leading @
+		at := strings.LastIndex(ref, "@")
+		rootPkg.Dependencies[ref[:at]] = ref[at+1:]
+	}
+
+	packages := make([]Package, 0, len(graph.Packages))
+	for _, p := range graph.Packages {
+		packages = append(packages, Package{
+			Name:         p.Name,
+			Version:      p.Version,
+			URL:          tmpl.Expand(p.Name, p.Version, ""),
+			Dependencies: p.Dependencies,
+		})
+	}
+	return New(rootPkg, packages)
+}
+
+// FromDependencyGraph builds a BOM from a local resolution's dependency graph.
+// The graph names each package once, so components are found by following
+// each package's dependencies from its install directory the way Node does,
+// and keyed by name and version: a package installed at several versions,
+// e.g. nested in another package's node_modules, is a component per version.
+// Each package's URL is the template expanded with an empty path, e.g.
+// "/node_modules/lit/".
+func FromDependencyGraph(fsys fs.FileSystem, rootDir string, root *packagejson.PackageJSON, graph *resolve.DependencyGraph, tmpl *resolve.Template) *BOM {
+	packages := make(map[string]*Package)
+	visited := make(map[string]string)
+
+	// visit adds the package installed at pkgPath and, recursively, its
+	// dependencies, returning its version
+	var visit func(name, pkgPath string) (string, bool)
+	visit = func(name, pkgPath string) (string, bool) {
+		if target, ok := fs.LinkTarget(fsys, pkgPath); ok {
+			pkgPath = target
+		}
+		if version, ok := visited[pkgPath]; ok {
+			return version, true
+		}
+		pkg, err := packagejson.ParseFile(fsys, filepath.Join(pkgPath, "package.json"))
+		if err != nil {
+			return "", false
+		}
+		visited[pkgPath] = pkg.Version
+
+		key := PURL(name, pkg.Version)
+		p, ok := packages[key]
+		if !ok {
+			p = &Package{Name: name, Version: pkg.Version, URL: tmpl.Expand(name, pkg.Version, "")}
+			packages[key] = p
+		}
+		for _, dep := range slices.Sorted(maps.Keys(pkg.Dependencies)) {
+			depPath, ok := dependencyPath(fsys, rootDir, pkgPath, dep)
+			if !ok {
+				continue
+			}
+			if version, ok := visit(dep, depPath); ok {
+				if p.Dependencies == nil {
+					p.Dependencies = make(map[string]string)
+				}
+				p.Dependencies[dep] = version
+			}
+		}
+		return pkg.Version, true
+	}
+
+	versions := make(map[string]string)
+	for _, name := range graph.Packages() {
+		pkgPath := graph.PackagePath(name)
+		if name == root.Name || pkgPath == "" {
+			continue
+		}
+		if version, ok := visit(name, pkgPath); ok {
+			versions[name] = version
+		}
+	}
+
+	rootPkg := Package{Name: root.Name, Version: root.Version, Dependencies: make(map[string]string)}
+	for dep := range root.Dependencies {
+		if version, ok := versions[dep]; ok {
+			rootPkg.Dependencies[dep] = version
+		}
+	}
+
+	list := make([]Package, 0, len(packages))
+	for _, p := range packages {
+		list = append(list, *p)
+	}
+	return New(rootPkg, list)
+}
+
+// dependencyPath finds the install directory of dep as required from the
+// package at pkgPath: in the nearest node_modules containing it, searching
+// from pkgPath up to rootDir.
+func dependencyPath(fsys fs.FileSystem, rootDir, pkgPath, dep string) (string, bool) {
+	for dir := pkgPath; ; dir = filepath.Dir(dir) {
+		if filepath.Base(dir) != "node_modules" {
+			candidate := filepath.Join(dir, "node_modules", dep)
+			if fsys.Exists(filepath.Join(candidate, "package.json")) {
+				return candidate, true
+			}
+		}
+		if dir == rootDir || filepath.Dir(dir) == dir {
+			return "", false
+		}
+	}
+}
@@ -0,0 +1,116 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package sbom_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"bennypowers.dev/mappa/packagejson"
+	"bennypowers.dev/mappa/resolve"
+	cdnresolver "bennypowers.dev/mappa/resolve/cdn"
+	"bennypowers.dev/mappa/resolve/local"
+	"bennypowers.dev/mappa/sbom"
+	"bennypowers.dev/mappa/testutil"
+)
+
+// compareGolden compares bom's JSON against a golden file, or updates it with -update.
+func compareGolden(t *testing.T, goldenPath string, bom *sbom.BOM) {
+	t.Helper()
+	actual, err := json.MarshalIndent(bom, "", "  ")
+	if err != nil {
+		t.Fatalf("Failed to marshal BOM: %v", err)
+	}
+	actual = append(actual, '\n')
+
+	testutil.UpdateGoldenFile(t, goldenPath, actual)
+	if expected := testutil.LoadGoldenFile(t, goldenPath); expected != nil && string(expected) != string(actual) {
+		t.Errorf("BOM mismatch for %s\nexpected:\n%s\nactual:\n%s", goldenPath, expected, actual)
+	}
+}
+
+func TestFromDependencyGraph(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "resolve/with-scopes", "/test")
+
+	pkg, err := packagejson.ParseFile(mfs, "/test/package.json")
+	if err != nil {
+		t.Fatalf("ParseFile failed: %v", err)
+	}
+	result, err := local.New(mfs, nil).ResolveWithGraph("/test")
+	if err != nil {
+		t.Fatalf("ResolveWithGraph failed: %v", err)
+	}
+	tmpl, err := resolve.ParseTemplate(resolve.DefaultLocalTemplate)
+	if err != nil {
+		t.Fatalf("ParseTemplate failed: %v", err)
+	}
+
+	compareGolden(t, "sbom/local/expected.json", sbom.FromDependencyGraph(mfs, "/test", pkg, result.DependencyGraph, tmpl))
+}
+
+func TestFromDependencyGraphDuplicateVersions(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "resolve/duplicate-versions", "/test")
+
+	pkg, err := packagejson.ParseFile(mfs, "/test/package.json")
+	if err != nil {
+		t.Fatalf("ParseFile failed: %v", err)
+	}
+	result, err := local.New(mfs, nil).ResolveWithGraph("/test")
+	if err != nil {
+		t.Fatalf("ResolveWithGraph failed: %v", err)
+	}
+	tmpl, err := resolve.ParseTemplate(resolve.DefaultLocalTemplate)
+	if err != nil {
+		t.Fatalf("ParseTemplate failed: %v", err)
+	}
+
+	compareGolden(t, "sbom/duplicate-versions/expected.json", sbom.FromDependencyGraph(mfs, "/test", pkg, result.DependencyGraph, tmpl))
+}
+
+func TestFromResolutionGraph(t *testing.T) {
+	pkg := &packagejson.PackageJSON{Name: "my-app", Version: "1.0.0"}
+	graph := &cdnresolver.ResolutionGraph{
+		Roots: []string{"@lit/context@1.1.0", "lit@3.1.0"},
+		Packages: []cdnresolver.GraphPackage{
+			{Name: "lit", Version: "3.1.0", Dependencies: map[string]string{"lit-html": "3.1.0", "@lit/reactive-element": "2.0.4"}},
+			{Name: "lit-html", Version: "3.1.0"},
+			{Name: "@lit/reactive-element", Version: "2.0.4"},
+			{Name: "@lit/context", Version: "1.1.0", Dependencies: map[string]string{"@lit/reactive-element": "2.0.4"}},
+		},
+	}
+	tmpl, err := resolve.ParseTemplate("https://esm.sh/{package}@{version}/{path}")
+	if err != nil {
+		t.Fatalf("ParseTemplate failed: %v", err)
+	}
+
+	compareGolden(t, "sbom/cdn/expected.json", sbom.FromResolutionGraph(pkg, graph, tmpl))
+}
+
+func TestPURL(t *testing.T) {
+	tests := []struct {
+		name, version, want string
+	}{
+		{"lit", "3.1.0", "pkg:npm/lit@3.1.0"},
+		{"@lit/context", "1.1.0", "pkg:npm/%40lit/context@1.1.0"},
+		{"unversioned", "", "pkg:npm/unversioned"},
+	}
+	for _, tt := range tests {
+		if got := sbom.PURL(tt.name, tt.version); got != tt.want {
+			t.Errorf("PURL(%q, %q) = %q, want %q", tt.name, tt.version, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+import { LitElement } from 'lit';
+
+console.log(LitElement);
@@ -0,0 +1 @@
+export { format } from 'tiny';
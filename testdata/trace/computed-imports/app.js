@@ -0,0 +1,7 @@
+import { setLocale } from './locale.js';
+
+const lang = navigator.language.split('-')[0];
+setLocale(await import(`./locales/${lang}.js`));
+
+// A template literal without substitutions is traced like a string
+await import(`./plain.js`);
@@ -0,0 +1,7 @@
+export function setLocale(strings) {
+  document.documentElement.lang = strings.lang;
+}
+
+export function loadPlugin(name) {
+  return import(name);
+}
@@ -0,0 +1,8 @@
+import { LitElement } from 'lit';
+import styles from './styles/app.css' with { type: 'css' };
+
+export class AppShell extends LitElement {
+  static styles = [styles];
+}
+
+customElements.define('app-shell', AppShell);
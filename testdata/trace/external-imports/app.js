@@ -0,0 +1,5 @@
+import { html } from 'lit';
+import { chart } from 'https://cdn.example.com/chart.js';
+import 'https://esm.sh/confetti@1.0.0';
+import 'data:text/javascript,export default 1';
+import('blob:https://example.com/1b4e28ba');
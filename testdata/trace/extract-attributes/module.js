@@ -0,0 +1,11 @@
+import config from './config.json' with { type: 'json' };
+import sheet from './styles.css' with { type: 'css' };
+import legacy from './legacy.json' assert { type: 'json' };
+import { LitElement } from 'lit';
+export { default as tokens } from '@example/tokens/tokens.json' with { type: 'json' };
+export * from './shared.css' with {
+  type: 'css'
+};
+import 'after-attributes';
+
+const data = await import('./lazy.json', { with: { type: 'json' } });
@@ -0,0 +1,13 @@
+import { html } from 'lit';
+
+const iconURL = new URL('./icon.svg', import.meta.url);
+console.log(import.meta.url);
+if (import.meta.hot) {
+  import.meta.hot.accept();
+}
+const resolved = import.meta.resolve('@example/icons/icon.js');
+
+import './after-meta.js';
+export { helper } from './helpers.js';
+
+const lazy = await import('./lazy.js');
@@ -0,0 +1,9 @@
+import { html } from 'lit';
+
+const worker = new Worker(new URL('./worker.js', import.meta.url), { type: 'module' });
+const shared = new SharedWorker(new URL('shared.mjs', import.meta.url), { type: 'module' });
+const iconURL = new URL('./icon.svg', import.meta.url);
+const remote = new URL('https://cdn.example.com/worker.js', import.meta.url);
+const page = new URL('./other.js', location.href);
+const processor = new URL('../audio/processor.ts', import.meta.url);
+const asset = new Asset('./not-a-url.js', import.meta.url);
@@ -0,0 +1,3 @@
+import { alpha } from 'alpha';
+
+alpha();
@@ -0,0 +1 @@
+require('legacy-polyfills');
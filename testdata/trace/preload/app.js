@@ -0,0 +1,4 @@
+import './ui.js';
+import { core } from '@acme/core';
+
+core();
@@ -0,0 +1,8 @@
+import { LitElement } from 'lit';
+import { render } from './render.js';
+
+export class MyApp extends LitElement {
+  render() {
+    return render();
+  }
+}
@@ -0,0 +1,3 @@
+import { html } from 'lit/html.js';
+
+export const render = () => html`<p>Hello</p>`;
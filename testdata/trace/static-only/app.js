@@ -0,0 +1,7 @@
+import { html } from 'lit';
+import { format } from './utils.js';
+
+export async function openDialog() {
+  const { Dialog } = await import('./lazy.js');
+  return new Dialog();
+}
@@ -0,0 +1,5 @@
+import { format } from '@app/utils/format';
+
+export class Button {
+  label = format('button');
+}
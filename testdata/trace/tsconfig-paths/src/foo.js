@@ -0,0 +1,4 @@
+import { LitElement } from 'lit';
+import { Button } from '@app/components/button.js';
+
+export const foo = [LitElement, Button];
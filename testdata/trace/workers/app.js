@@ -0,0 +1,4 @@
+import { html } from 'lit';
+
+const worker = new Worker(new URL('./workers/search.js', import.meta.url), { type: 'module' });
+worker.postMessage(html`<p>ready</p>`);
@@ -0,0 +1,3 @@
+import { openDB } from 'idb';
+
+export const db = openDB('search');
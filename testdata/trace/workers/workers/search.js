@@ -0,0 +1,6 @@
+import { expose } from 'comlink';
+import { tokenize } from './tokenize.js';
+
+const index = new SharedWorker(new URL('index.js', import.meta.url), { type: 'module' });
+
+expose({ search: (query) => tokenize(query) });
@@ -0,0 +1,3 @@
+export function tokenize(query) {
+  return query.split(/\s+/);
+}
@@ -66,17 +66,27 @@ func NewFixtureFS(t *testing.T, fixtureDir string, rootPath string) *mapfs.MapFi
 			return nil
 		}
 
-		content, err := os.ReadFile(path)
+		relPath, err := filepath.Rel(fixturePath, path)
 		if err != nil {
 			return err
 		}
+		virtualPath := filepath.Join(rootPath, relPath)
 
-		relPath, err := filepath.Rel(fixturePath, path)
+		// Keep symbolic links as links, e.g. workspace packages in node_modules
+		if d.Type()&fs.ModeSymlink != 0 {
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			mfs.AddSymlink(virtualPath, target)
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
 		if err != nil {
 			return err
 		}
 
-		virtualPath := filepath.Join(rootPath, relPath)
 		mfs.AddFile(virtualPath, string(content), 0644)
 
 		return nil
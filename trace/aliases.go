@@ -0,0 +1,62 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package trace
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"bennypowers.dev/mappa/fs"
+	"bennypowers.dev/mappa/tsconfig"
+)
+
+// resolveAliases splits bare specifiers into those matching a tsconfig path
+// alias, mapped to the root-relative URL of the file they resolve to, and the
+// remaining specifiers to resolve from node_modules. Aliases that resolve to no
+// file, or to a file outside rootDir, are left with the remaining specifiers.
+func resolveAliases(osfs fs.FileSystem, rootDir string, config *tsconfig.TSConfig, specifiers []string) (aliases map[string]string, rest []string) {
+	if config == nil || len(config.CompilerOptions.Paths) == 0 {
+		return nil, specifiers
+	}
+
+	aliases = make(map[string]string)
+	for _, spec := range specifiers {
+		target, ok := tsconfig.ResolvePath(osfs, config.PathsBaseDir(), config.CompilerOptions.Paths, spec)
+		if ok {
+			rel, err := filepath.Rel(rootDir, target)
+			if err == nil && !strings.HasPrefix(rel, "..") {
+				aliases[spec] = "/" + filepath.ToSlash(rel)
+				continue
+			}
+		}
+		rest = append(rest, spec)
+	}
+	return aliases, rest
+}
+
+// withoutAliasIssues drops validation issues for specifiers resolved as
+// tsconfig path aliases, which are local files rather than packages.
+func withoutAliasIssues(issues []ImportIssue, aliases map[string]string) []ImportIssue {
+	if len(aliases) == 0 {
+		return issues
+	}
+	return slices.DeleteFunc(issues, func(issue ImportIssue) bool {
+		_, ok := aliases[issue.Specifier]
+		return ok
+	})
+}
@@ -0,0 +1,79 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package trace
+
+import (
+	"strings"
+
+	"bennypowers.dev/mappa/packagejson"
+	"bennypowers.dev/mappa/resolve"
+)
+
+// assumeInstalledImports maps bare specifiers to URLs by template expansion alone,
+// without reading node_modules or resolving package exports. Versions are taken
+// from the declared dependencies in pkg. Package-root specifiers map to index.js,
+// the same fallback Node uses when a package declares no entry point.
+// Specifiers whose version is unknown are omitted when the template needs a version.
+func assumeInstalledImports(tmpl *resolve.Template, specifiers []string, pkg *packagejson.PackageJSON) map[string]string {
+	imports := make(map[string]string, len(specifiers))
+	for _, spec := range specifiers {
+		pkgName := getPackageName(spec)
+		version := declaredVersion(pkg, pkgName)
+		if version == "" && tmpl.HasVersion() {
+			continue
+		}
+
+		path := strings.TrimPrefix(strings.TrimPrefix(spec, pkgName), "/")
+		if path == "" {
+			path = "index.js"
+		}
+		imports[spec] = tmpl.Expand(pkgName, version, path)
+	}
+	return imports
+}
+
+// declaredVersion returns the version of a package as declared in pkg,
+// stripping range operators so "^3.1.0" yields "3.1.0".
+// Returns the package's own version for self-references, or "" if undeclared.
+func declaredVersion(pkg *packagejson.PackageJSON, pkgName string) string {
+	if pkg == nil {
+		return ""
+	}
+	if pkgName == pkg.Name {
+		return pkg.Version
+	}
+
+	declared, ok := pkg.Dependencies[pkgName]
+	if !ok {
+		declared, ok = pkg.DevDependencies[pkgName]
+	}
+	if !ok {
+		return ""
+	}
+
+	// Use the first version in the range (e.g., ">=1.2.0 <2" -> "1.2.0")
+	fields := strings.Fields(declared)
+	if len(fields) == 0 {
+		return ""
+	}
+	version := strings.TrimLeft(fields[0], "^~=>v")
+	if version == "" || strings.ContainsAny(version, "*xX:") {
+		return ""
+	}
+	return version
+}
@@ -0,0 +1,95 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package trace
+
+import (
+	"testing"
+
+	"bennypowers.dev/mappa/packagejson"
+	"bennypowers.dev/mappa/resolve"
+)
+
+func TestDeclaredVersion(t *testing.T) {
+	pkg := &packagejson.PackageJSON{
+		Name:    "my-app",
+		Version: "2.0.0",
+		Dependencies: map[string]string{
+			"caret":     "^3.1.0",
+			"tilde":     "~1.2.3",
+			"exact":     "4.0.0",
+			"range":     ">=1.5.0 <2",
+			"star":      "*",
+			"workspace": "workspace:*",
+		},
+		DevDependencies: map[string]string{
+			"dev": "^0.9.1",
+		},
+	}
+
+	tests := []struct {
+		pkgName  string
+		expected string
+	}{
+		{"caret", "3.1.0"},
+		{"tilde", "1.2.3"},
+		{"exact", "4.0.0"},
+		{"range", "1.5.0"},
+		{"star", ""},
+		{"workspace", ""},
+		{"dev", "0.9.1"},
+		{"my-app", "2.0.0"},
+		{"undeclared", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pkgName, func(t *testing.T) {
+			if got := declaredVersion(pkg, tt.pkgName); got != tt.expected {
+				t.Errorf("declaredVersion(%q) = %q, want %q", tt.pkgName, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAssumeInstalledImports(t *testing.T) {
+	pkg := &packagejson.PackageJSON{
+		Dependencies: map[string]string{"lit": "^3.1.0"},
+	}
+	specs := []string{"lit", "lit/decorators.js", "undeclared"}
+
+	t.Run("local template", func(t *testing.T) {
+		tmpl, _ := resolve.ParseTemplate(resolve.DefaultLocalTemplate)
+		imports := assumeInstalledImports(tmpl, specs, pkg)
+		if imports["lit"] != "/node_modules/lit/index.js" {
+			t.Errorf("lit = %q", imports["lit"])
+		}
+		if imports["undeclared"] != "/node_modules/undeclared/index.js" {
+			t.Errorf("undeclared = %q", imports["undeclared"])
+		}
+	})
+
+	t.Run("versioned template", func(t *testing.T) {
+		tmpl, _ := resolve.ParseTemplate("https://esm.sh/{package}@{version}/{path}")
+		imports := assumeInstalledImports(tmpl, specs, pkg)
+		if imports["lit/decorators.js"] != "https://esm.sh/lit@3.1.0/decorators.js" {
+			t.Errorf("lit/decorators.js = %q", imports["lit/decorators.js"])
+		}
+		if _, ok := imports["undeclared"]; ok {
+			t.Error("Expected undeclared package to be omitted for versioned template")
+		}
+	})
+}
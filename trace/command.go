@@ -21,17 +21,26 @@ along with this program. If not, see <http://www.gnu.org/licenses/>.
 package trace
 
 import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
+	"bennypowers.dev/mappa/cdn"
 	"bennypowers.dev/mappa/fs"
 	"bennypowers.dev/mappa/importmap"
 	"bennypowers.dev/mappa/packagejson"
 	"bennypowers.dev/mappa/resolve"
 	"bennypowers.dev/mappa/resolve/local"
+	"bennypowers.dev/mappa/tsconfig"
 )
 
 // Options configures the trace command.
@@ -44,6 +53,33 @@ type Options struct {
 	// Parallel is the number of parallel workers for batch mode.
 	// Defaults to runtime.NumCPU() if <= 0.
 	Parallel int
+	// StaticOnly skips dynamic import() specifiers during tracing.
+	StaticOnly bool
+	// SkipTemplates ignores module scripts inside <template> elements.
+	SkipTemplates bool
+	// FlattenScopes hoists scoped entries into top-level imports, producing a scope-free map.
+	FlattenScopes bool
+	// AssumeInstalled resolves specifiers by template expansion alone, trusting the
+	// versions declared in package.json instead of reading node_modules.
+	AssumeInstalled bool
+	// BaseHref prefixes root-relative URLs in the generated map, for sites deployed under a subpath.
+	BaseHref string
+	// InlineBelow inlines resolved JavaScript modules smaller than this many bytes
+	// as data: URLs. Zero disables inlining.
+	InlineBelow int
+	// Timings, if set, accumulates the time spent in each phase of the trace.
+	Timings *Timings
+	// TSConfig, if set, maps specifiers matching its compilerOptions.paths
+	// aliases to the local files they resolve to, instead of node_modules,
+	// and traces through those files.
+	TSConfig *tsconfig.TSConfig
+	// InputMap, if set, is merged into each traced import map with its entries
+	// taking precedence, seeding specifiers that tracing cannot discover.
+	InputMap *importmap.ImportMap
+	// Strict fails resolution when a traced bare specifier doesn't resolve to
+	// an existing file under node_modules, instead of mapping it best-effort.
+	// It has no effect with AssumeInstalled.
+	Strict bool
 }
 
 // SingleResult holds the result of tracing a single HTML file.
@@ -52,17 +88,34 @@ type SingleResult struct {
 	ImportMap *importmap.ImportMap
 	// Issues contains any validation warnings.
 	Issues []ImportIssue
+	// Preload lists the module URLs of the import map to preload, shallowest
+	// dependencies first.
+	Preload []string
+	// CrossOrigin is the crossorigin setting of the page's module scripts,
+	// for preload links to mirror. See ModuleGraph.CrossOrigin.
+	CrossOrigin string
 	// SpecifiersResult is populated when format is "specifiers".
 	SpecifiersResult *SpecifiersResult
 }
 
 // SpecifiersResult holds the legacy specifiers format output.
 type SpecifiersResult struct {
-	Entrypoints    []string    `json:"entrypoints"`
-	Modules        []string    `json:"modules"`
-	BareSpecifiers []string    `json:"bare_specifiers"`
-	Packages       []string    `json:"packages"`
-	Issues         []IssueJSON `json:"issues,omitempty"`
+	Entrypoints       []string    `json:"entrypoints"`
+	Modules           []string    `json:"modules"`
+	BareSpecifiers    []string    `json:"bare_specifiers"`
+	DynamicSpecifiers []string    `json:"dynamic_specifiers,omitempty"`
+	ExternalImports   []string    `json:"external_imports,omitempty"`
+	Packages          []string    `json:"packages"`
+	Issues            []IssueJSON `json:"issues,omitempty"`
+
+	UnresolvableImports []UnresolvableImportJSON `json:"unresolvable_imports,omitempty"`
+}
+
+// UnresolvableImportJSON is the JSON representation of an UnresolvableImport.
+type UnresolvableImportJSON struct {
+	File       string `json:"file"`
+	Line       int    `json:"line"`
+	Expression string `json:"expression"`
 }
 
 // IssueJSON is the JSON representation of an ImportIssue.
@@ -77,6 +130,7 @@ type IssueJSON struct {
 // BatchResult holds the result of tracing a single file in batch mode.
 type BatchResult struct {
 	File     string                       `json:"file"`
+	Variant  string                       `json:"variant,omitempty"`
 	Imports  map[string]string            `json:"imports"`
 	Scopes   map[string]map[string]string `json:"scopes,omitempty"`
 	Error    string                       `json:"error,omitempty"`
@@ -101,24 +155,35 @@ type tracerSetup struct {
 }
 
 // setupTracer creates common tracing prerequisites for a package root.
-func setupTracer(osfs fs.FileSystem, absRoot string) tracerSetup {
+func setupTracer(osfs fs.FileSystem, absRoot string, opts Options) tracerSetup {
 	workspaceRoot := resolve.FindWorkspaceRoot(osfs, absRoot)
-	nodeModulesPath := filepath.Join(workspaceRoot, "node_modules")
 
 	pkgPath := filepath.Join(absRoot, "package.json")
 	pkg, pkgErr := packagejson.ParseFile(osfs, pkgPath)
 
-	tracer := NewTracer(osfs, absRoot).WithNodeModules(nodeModulesPath)
+	tracer := NewTracer(osfs, absRoot).WithNodeModulesPaths(resolve.NodeModulesPaths(osfs, absRoot))
 	if pkgErr == nil && pkg.Name != "" {
 		tracer = tracer.WithSelfPackage(pkg, absRoot)
 	}
+	if opts.StaticOnly {
+		tracer = tracer.WithStaticOnly()
+	}
+	if opts.SkipTemplates {
+		tracer = tracer.WithSkipTemplates()
+	}
+	if opts.Timings != nil {
+		tracer = tracer.WithTimings(opts.Timings)
+	}
+	if opts.TSConfig != nil {
+		tracer = tracer.WithTSConfigPaths(opts.TSConfig.PathsBaseDir(), opts.TSConfig.CompilerOptions.Paths)
+	}
 
 	return tracerSetup{workspaceRoot, tracer, pkg, pkgErr}
 }
 
 // TraceSingle traces a single HTML file and generates an import map.
 func TraceSingle(osfs fs.FileSystem, htmlFile, absRoot string, opts Options) (*SingleResult, error) {
-	setup := setupTracer(osfs, absRoot)
+	setup := setupTracer(osfs, absRoot, opts)
 
 	graph, err := setup.tracer.TraceHTML(htmlFile)
 	if err != nil {
@@ -129,12 +194,60 @@ func TraceSingle(osfs fs.FileSystem, htmlFile, absRoot string, opts Options) (*S
 	var issues []ImportIssue
 	if setup.pkgErr == nil {
 		issues = graph.ValidateImports(osfs, absRoot, setup.pkg.Name, setup.pkg.Dependencies, setup.pkg.DevDependencies)
+		aliases, _ := resolveAliases(osfs, absRoot, opts.TSConfig, graph.BareSpecifiers())
+		issues = withoutAliasIssues(issues, aliases)
 	}
 
-	result := &SingleResult{Issues: issues}
+	im, err := resolveTracedMap(osfs, setup, graph.BareSpecifiers(), opts)
+	if err != nil {
+		return nil, err
+	}
 
-	// Get bare specifiers once for reuse
-	bareSpecs := graph.BareSpecifiers()
+	return &SingleResult{ImportMap: im, Issues: issues, Preload: PreloadURLs(im, graph), CrossOrigin: graph.CrossOrigin}, nil
+}
+
+// TraceURL fetches a deployed HTML page and traces its module graph over HTTP,
+// resolving relative module sources against the page URL. Bare specifiers are
+// resolved from the package at absRoot, as for TraceSingle. Remote modules are
+// not validated against package.json, so the result has no issues.
+func TraceURL(osfs fs.FileSystem, fetcher cdn.Fetcher, pageURL, absRoot string, opts Options) (*SingleResult, error) {
+	origin, pagePath, err := splitPageURL(pageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	remote := NewURLFileSystem(context.Background(), fetcher, origin)
+	tracer := NewTracer(remote, "/")
+	if opts.StaticOnly {
+		tracer = tracer.WithStaticOnly()
+	}
+	if opts.SkipTemplates {
+		tracer = tracer.WithSkipTemplates()
+	}
+	if opts.Timings != nil {
+		tracer = tracer.WithTimings(opts.Timings)
+	}
+
+	graph, err := tracer.TraceHTML(pagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	im, err := resolveTracedMap(osfs, setupTracer(osfs, absRoot, opts), graph.BareSpecifiers(), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SingleResult{ImportMap: im, Preload: PreloadURLs(im, graph), CrossOrigin: graph.CrossOrigin}, nil
+}
+
+// resolveTracedMap builds the import map for a page's traced bare specifiers
+// from the package described by setup.
+func resolveTracedMap(osfs fs.FileSystem, setup tracerSetup, bareSpecs []string, opts Options) (*importmap.ImportMap, error) {
+	defer opts.Timings.addResolution(time.Now())
+
+	// tsconfig path aliases point at local files rather than packages
+	aliases, bareSpecs := resolveAliases(osfs, setup.tracer.rootDir, opts.TSConfig, bareSpecs)
 
 	// Build resolver for the traced packages
 	templateArg := opts.Template
@@ -142,15 +255,28 @@ func TraceSingle(osfs fs.FileSystem, htmlFile, absRoot string, opts Options) (*S
 		templateArg = resolve.DefaultLocalTemplate
 	}
 
+	// Expand templates directly when node_modules is not available
+	if opts.AssumeInstalled {
+		tmpl, err := resolve.ParseTemplate(templateArg)
+		if err != nil {
+			return nil, err
+		}
+		imports := assumeInstalledImports(tmpl, bareSpecs, setup.pkg)
+		maps.Copy(imports, aliases)
+		return buildTracedMap(imports, nil, opts), nil
+	}
+
 	pkgCache := packagejson.NewMemoryCache()
-	resolver := local.New(osfs, nil).WithPackageCache(pkgCache).WithPackages(bareSpecs)
-	resolver, err = resolver.WithTemplate(templateArg)
+	resolver, err := local.New(osfs, nil).WithPackageCache(pkgCache).WithPackages(bareSpecs).WithTemplate(templateArg)
 	if err != nil {
 		return nil, err
 	}
 	if len(opts.Conditions) > 0 {
 		resolver = resolver.WithConditions(opts.Conditions)
 	}
+	if opts.InlineBelow > 0 {
+		resolver = resolver.WithInlineBelow(opts.InlineBelow)
+	}
 
 	// Include root package exports if traced specifiers reference the root package
 	if setup.pkgErr == nil && setup.pkg.Name != "" {
@@ -169,7 +295,10 @@ func TraceSingle(osfs fs.FileSystem, htmlFile, absRoot string, opts Options) (*S
 	}
 
 	// Resolve traced specifiers
-	tracedImports := resolver.ResolveSpecifiers(setup.workspaceRoot, bareSpecs)
+	tracedImports, err := resolveSpecifiers(resolver, setup.workspaceRoot, bareSpecs, opts)
+	if err != nil {
+		return nil, err
+	}
 
 	// Add trailing-slash keys from generated imports
 	for key, value := range generatedMap.Imports {
@@ -177,19 +306,16 @@ func TraceSingle(osfs fs.FileSystem, htmlFile, absRoot string, opts Options) (*S
 			tracedImports[key] = value
 		}
 	}
+	maps.Copy(tracedImports, aliases)
 
 	// Build and simplify the import map
-	result.ImportMap = (&importmap.ImportMap{
-		Imports: tracedImports,
-		Scopes:  generatedMap.Scopes,
-	}).Simplify()
-
-	return result, nil
+	return buildTracedMap(tracedImports, generatedMap.Scopes, opts), nil
 }
 
 // TraceSpecifiers returns the legacy specifiers format for debugging.
-func TraceSpecifiers(osfs fs.FileSystem, htmlFile, absRoot string) (*SpecifiersResult, []ImportIssue, error) {
-	setup := setupTracer(osfs, absRoot)
+// Bare specifiers only found in dynamic import() calls are listed in DynamicSpecifiers.
+func TraceSpecifiers(osfs fs.FileSystem, htmlFile, absRoot string, opts Options) (*SpecifiersResult, []ImportIssue, error) {
+	setup := setupTracer(osfs, absRoot, opts)
 
 	graph, err := setup.tracer.TraceHTML(htmlFile)
 	if err != nil {
@@ -216,9 +342,11 @@ func TraceSpecifiers(osfs fs.FileSystem, htmlFile, absRoot string) (*SpecifiersR
 	}
 
 	result := &SpecifiersResult{
-		Entrypoints:    entrypoints,
-		BareSpecifiers: graph.BareSpecifiers(),
-		Packages:       graph.PackageNames(),
+		Entrypoints:       entrypoints,
+		BareSpecifiers:    graph.BareSpecifiers(),
+		DynamicSpecifiers: graph.DynamicSpecifiers(),
+		ExternalImports:   slices.Sorted(slices.Values(graph.ExternalImports)),
+		Packages:          graph.PackageNames(),
 	}
 
 	for p := range graph.Modules {
@@ -226,6 +354,19 @@ func TraceSpecifiers(osfs fs.FileSystem, htmlFile, absRoot string) (*SpecifiersR
 	}
 	sort.Strings(result.Modules)
 
+	for _, imp := range graph.UnresolvableImports {
+		// Imports in inline scripts belong to the page
+		file := cmp.Or(imp.Module, htmlFile)
+		result.UnresolvableImports = append(result.UnresolvableImports, UnresolvableImportJSON{
+			File:       relativize(file),
+			Line:       imp.Line,
+			Expression: imp.Expression,
+		})
+	}
+	slices.SortFunc(result.UnresolvableImports, func(a, b UnresolvableImportJSON) int {
+		return cmp.Or(strings.Compare(a.File, b.File), cmp.Compare(a.Line, b.Line))
+	})
+
 	for _, issue := range issues {
 		result.Issues = append(result.Issues, IssueJSON{
 			File:      issue.File,
@@ -260,17 +401,28 @@ func TraceBatch(osfs fs.FileSystem, files []string, absRoot string, opts Options
 
 		// Find workspace root once for all files
 		workspaceRoot := resolve.FindWorkspaceRoot(osfs, absRoot)
-		nodeModulesPath := filepath.Join(workspaceRoot, "node_modules")
 
 		// Parse package.json once for self-referencing imports and dependency validation
 		pkgPath := filepath.Join(absRoot, "package.json")
 		pkg, _ := packagejson.ParseFile(osfs, pkgPath)
 
 		// Create shared tracer with self-package awareness and transitive dependency following
-		tracer := NewTracer(osfs, absRoot).WithNodeModules(nodeModulesPath)
+		tracer := NewTracer(osfs, absRoot).WithNodeModulesPaths(resolve.NodeModulesPaths(osfs, absRoot))
 		if pkg != nil && pkg.Name != "" {
 			tracer = tracer.WithSelfPackage(pkg, absRoot)
 		}
+		if opts.StaticOnly {
+			tracer = tracer.WithStaticOnly()
+		}
+		if opts.SkipTemplates {
+			tracer = tracer.WithSkipTemplates()
+		}
+		if opts.Timings != nil {
+			tracer = tracer.WithTimings(opts.Timings)
+		}
+		if opts.TSConfig != nil {
+			tracer = tracer.WithTSConfigPaths(opts.TSConfig.PathsBaseDir(), opts.TSConfig.CompilerOptions.Paths)
+		}
 
 		// Create shared base resolver with template, conditions, and package cache
 		pkgCache := packagejson.NewMemoryCache()
@@ -285,6 +437,10 @@ func TraceBatch(osfs fs.FileSystem, files []string, absRoot string, opts Options
 		if len(opts.Conditions) > 0 {
 			baseResolver = baseResolver.WithConditions(opts.Conditions)
 		}
+		if opts.InlineBelow > 0 {
+			baseResolver = baseResolver.WithInlineBelow(opts.InlineBelow)
+		}
+		tmpl, _ := resolve.ParseTemplate(templateArg)
 
 		// Create jobs channel
 		jobs := make(chan string, len(files))
@@ -294,7 +450,7 @@ func TraceBatch(osfs fs.FileSystem, files []string, absRoot string, opts Options
 		for range parallel {
 			wg.Go(func() {
 				for htmlFile := range jobs {
-					result := traceFileForBatch(tracer, osfs, htmlFile, absRoot, workspaceRoot, baseResolver, pkg)
+					result := traceFileForBatch(tracer, osfs, htmlFile, absRoot, workspaceRoot, baseResolver, tmpl, pkg, opts)
 					results <- result
 				}
 			})
@@ -313,8 +469,64 @@ func TraceBatch(osfs fs.FileSystem, files []string, absRoot string, opts Options
 	return results
 }
 
+// CollectSpecifiers traces multiple HTML files in parallel and returns the sorted
+// union of bare specifiers they use. Files that fail to trace are reported as errors
+// and do not contribute specifiers.
+func CollectSpecifiers(osfs fs.FileSystem, files []string, absRoot string, opts Options) ([]string, []error) {
+	parallel := opts.Parallel
+	if parallel <= 0 {
+		parallel = runtime.NumCPU()
+	}
+
+	setup := setupTracer(osfs, absRoot, opts)
+
+	var mu sync.Mutex
+	used := make(map[string]bool)
+	var errs []error
+
+	jobs := make(chan string, len(files))
+	var wg sync.WaitGroup
+	for range parallel {
+		wg.Go(func() {
+			for htmlFile := range jobs {
+				graph, err := setup.tracer.TraceHTML(htmlFile)
+				mu.Lock()
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", htmlFile, err))
+				} else {
+					for _, spec := range graph.BareSpecifiers() {
+						used[spec] = true
+					}
+				}
+				mu.Unlock()
+			}
+		})
+	}
+
+	for _, file := range files {
+		jobs <- file
+	}
+	close(jobs)
+	wg.Wait()
+
+	return slices.Sorted(maps.Keys(used)), errs
+}
+
+// TraceMerged traces multiple HTML files and generates a single import map
+// covering the union of their bare specifiers, for sites that share one map
+// across pages. Files that fail to trace are reported as errors and do not
+// contribute specifiers; the map is nil only if resolution itself fails.
+func TraceMerged(osfs fs.FileSystem, files []string, absRoot string, opts Options) (*importmap.ImportMap, []error, error) {
+	bareSpecs, errs := CollectSpecifiers(osfs, files, absRoot, opts)
+	im, err := resolveTracedMap(osfs, setupTracer(osfs, absRoot, opts), bareSpecs, opts)
+	if err != nil {
+		return nil, errs, err
+	}
+	return im, errs, nil
+}
+
 // traceFileForBatch traces a single file and returns a BatchResult.
-func traceFileForBatch(tracer *Tracer, osfs fs.FileSystem, htmlFile, absRoot, workspaceRoot string, baseResolver *local.Resolver, pkg *packagejson.PackageJSON) BatchResult {
+func traceFileForBatch(tracer *Tracer, osfs fs.FileSystem, htmlFile, absRoot, workspaceRoot string, baseResolver *local.Resolver, tmpl *resolve.Template, pkg *packagejson.PackageJSON, opts Options) BatchResult {
 	result := BatchResult{File: htmlFile}
 
 	graph, err := tracer.TraceHTML(htmlFile)
@@ -323,10 +535,13 @@ func traceFileForBatch(tracer *Tracer, osfs fs.FileSystem, htmlFile, absRoot, wo
 		return result
 	}
 
+	// tsconfig path aliases point at local files rather than packages
+	aliases, bareSpecs := resolveAliases(osfs, absRoot, opts.TSConfig, graph.BareSpecifiers())
+
 	// Validate imports if package.json was parsed
 	if pkg != nil {
 		issues := graph.ValidateImports(osfs, absRoot, pkg.Name, pkg.Dependencies, pkg.DevDependencies)
-		for _, issue := range issues {
+		for _, issue := range withoutAliasIssues(issues, aliases) {
 			result.Warnings = append(result.Warnings, Warning{
 				File:      issue.File,
 				Line:      issue.Line,
@@ -337,10 +552,25 @@ func traceFileForBatch(tracer *Tracer, osfs fs.FileSystem, htmlFile, absRoot, wo
 		}
 	}
 
-	// Get bare specifiers once for reuse
-	bareSpecs := graph.BareSpecifiers()
 	if len(bareSpecs) == 0 {
-		result.Imports = make(map[string]string)
+		simplified := buildTracedMap(aliases, nil, opts)
+		result.Imports = simplified.Imports
+		result.Scopes = simplified.Scopes
+		if result.Imports == nil {
+			result.Imports = make(map[string]string)
+		}
+		return result
+	}
+
+	defer opts.Timings.addResolution(time.Now())
+
+	// Expand templates directly when node_modules is not available
+	if opts.AssumeInstalled {
+		imports := assumeInstalledImports(tmpl, bareSpecs, pkg)
+		maps.Copy(imports, aliases)
+		simplified := buildTracedMap(imports, nil, opts)
+		result.Imports = simplified.Imports
+		result.Scopes = simplified.Scopes
 		return result
 	}
 
@@ -365,7 +595,11 @@ func traceFileForBatch(tracer *Tracer, osfs fs.FileSystem, htmlFile, absRoot, wo
 	}
 
 	// Resolve traced specifiers
-	tracedImports := resolver.ResolveSpecifiers(workspaceRoot, bareSpecs)
+	tracedImports, err := resolveSpecifiers(resolver, workspaceRoot, bareSpecs, opts)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
 
 	// Add trailing-slash keys from generated imports
 	for key, value := range generatedMap.Imports {
@@ -373,15 +607,48 @@ func traceFileForBatch(tracer *Tracer, osfs fs.FileSystem, htmlFile, absRoot, wo
 			tracedImports[key] = value
 		}
 	}
+	maps.Copy(tracedImports, aliases)
 
 	// Build and simplify the import map
-	simplified := (&importmap.ImportMap{
-		Imports: tracedImports,
-		Scopes:  generatedMap.Scopes,
-	}).Simplify()
+	simplified := buildTracedMap(tracedImports, generatedMap.Scopes, opts)
 
 	result.Imports = simplified.Imports
 	result.Scopes = simplified.Scopes
 
 	return result
 }
+
+// resolveSpecifiers maps traced bare specifiers to URLs. In strict mode, it
+// returns an error listing every specifier that doesn't resolve to an
+// existing file.
+func resolveSpecifiers(resolver *local.Resolver, workspaceRoot string, bareSpecs []string, opts Options) (map[string]string, error) {
+	if !opts.Strict {
+		return resolver.ResolveSpecifiers(workspaceRoot, bareSpecs), nil
+	}
+	imports, errs := resolver.ResolveSpecifiersStrict(workspaceRoot, bareSpecs)
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return imports, nil
+}
+
+// buildTracedMap assembles the simplified import map for a traced page,
+// merging the input map over it, then flattening scopes into top-level
+// imports and rebasing URLs when requested.
+func buildTracedMap(imports map[string]string, scopes map[string]map[string]string, opts Options) *importmap.ImportMap {
+	im := &importmap.ImportMap{
+		Imports: imports,
+		Scopes:  scopes,
+	}
+	im = im.WithProvenance(importmap.ProvenanceTraced)
+	if opts.InputMap != nil {
+		im = im.Merge(opts.InputMap.WithProvenance(importmap.ProvenanceInputMap))
+	}
+	if opts.FlattenScopes {
+		im = im.FlattenScopes()
+	}
+	if opts.BaseHref != "" {
+		im = im.Rebase(opts.BaseHref)
+	}
+	return im.Simplify()
+}
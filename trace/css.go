@@ -0,0 +1,75 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package trace
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// cssCommentPattern matches CSS block comments, which may hide @import rules.
+var cssCommentPattern = regexp.MustCompile(`(?s)/\*.*?\*/`)
+
+// cssImportPattern matches the URL of an @import rule, written either as a
+// quoted string or inside url(), which may itself be quoted or unquoted.
+var cssImportPattern = regexp.MustCompile(`(?i)@import\s+(?:url\(\s*(?:"([^"]*)"|'([^']*)'|([^"'()\s]*))\s*\)|"([^"]*)"|'([^']*)')`)
+
+// ExtractCSSImports returns the URLs of the @import rules in a stylesheet, in
+// source order. CSS has no bare specifiers, so a path such as 'theme/base.css'
+// is returned relative to the stylesheet, as "./theme/base.css", like the URLs
+// of new URL(spec, import.meta.url). url() references outside of @import
+// rules, such as fonts and images, are not imports and are ignored.
+func ExtractCSSImports(content []byte) []string {
+	content = cssCommentPattern.ReplaceAll(content, nil)
+
+	var specifiers []string
+	for _, m := range cssImportPattern.FindAllSubmatch(content, -1) {
+		for _, group := range m[1:] {
+			if spec := strings.TrimSpace(string(group)); spec != "" {
+				specifiers = append(specifiers, cssURLSpecifier(spec))
+				break
+			}
+		}
+	}
+	return specifiers
+}
+
+// cssURLSpecifier returns the specifier to trace for an @import URL, which
+// is relative to the stylesheet unless it is absolute or has a scheme.
+func cssURLSpecifier(spec string) string {
+	if isURLSpecifier(spec) || strings.HasPrefix(spec, "/") || strings.HasPrefix(spec, "./") || strings.HasPrefix(spec, "../") {
+		return spec
+	}
+	return "./" + spec
+}
+
+// isCSSFile reports whether the path names a stylesheet, whose dependencies
+// are @import rules rather than module imports.
+func isCSSFile(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".css")
+}
+
+// extractCSSModuleImports converts a stylesheet's @import rules into module
+// imports so they are followed like the imports of a CSS module script.
+func extractCSSModuleImports(content []byte) []ModuleImport {
+	var imports []ModuleImport
+	for _, spec := range ExtractCSSImports(content) {
+		imports = append(imports, ModuleImport{Specifier: spec, Type: "css"})
+	}
+	return imports
+}
@@ -0,0 +1,78 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package trace
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"bennypowers.dev/mappa/importmap"
+)
+
+// DedupedBatch holds batch results with each distinct import map stored
+// once, for sites where many pages produce the same map.
+type DedupedBatch struct {
+	// Maps holds each distinct import map by its ID.
+	Maps map[string]*importmap.ImportMap `json:"maps"`
+	// Pages references the map of every traced file, sorted by file.
+	Pages []PageMap `json:"pages"`
+}
+
+// PageMap references the import map traced for a file, or records why the
+// file failed to trace.
+type PageMap struct {
+	File    string `json:"file"`
+	Variant string `json:"variant,omitempty"`
+	Map     string `json:"map,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// DedupeResults collects batch results into a DedupedBatch. A map's ID is
+// derived from a hash of its sorted JSON, so identical maps share one ID
+// and IDs are stable across runs. Warnings are dropped.
+func DedupeResults(results []BatchResult) (*DedupedBatch, error) {
+	batch := &DedupedBatch{
+		Maps:  make(map[string]*importmap.ImportMap),
+		Pages: make([]PageMap, 0, len(results)),
+	}
+
+	for _, result := range results {
+		page := PageMap{File: result.File, Variant: result.Variant, Error: result.Error}
+		if result.Error == "" {
+			im := &importmap.ImportMap{Imports: result.Imports, Scopes: result.Scopes}
+			data, err := im.SortedMarshal()
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal import map for %s: %w", result.File, err)
+			}
+			sum := sha256.Sum256(data)
+			page.Map = hex.EncodeToString(sum[:6])
+			if _, seen := batch.Maps[page.Map]; !seen {
+				batch.Maps[page.Map] = im
+			}
+		}
+		batch.Pages = append(batch.Pages, page)
+	}
+
+	// Stable, so variants of one file keep the order they were traced in
+	sort.SliceStable(batch.Pages, func(i, j int) bool {
+		return batch.Pages[i].File < batch.Pages[j].File
+	})
+	return batch, nil
+}
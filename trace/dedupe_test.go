@@ -0,0 +1,77 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package trace
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDedupeResults(t *testing.T) {
+	shared := map[string]string{"lit": "/node_modules/lit/index.js"}
+	var results []BatchResult
+	for i := 99; i >= 0; i-- {
+		results = append(results, BatchResult{File: fmt.Sprintf("page%02d.html", i), Imports: shared})
+	}
+	results = append(results,
+		BatchResult{File: "other.html", Imports: map[string]string{"lit": "/node_modules/lit/index.js"}, Scopes: map[string]map[string]string{
+			"/node_modules/lit/": {"lit-html": "/node_modules/lit-html/lit-html.js"},
+		}},
+		BatchResult{File: "broken.html", Error: "parse failed"},
+	)
+
+	batch, err := DedupeResults(results)
+	if err != nil {
+		t.Fatalf("DedupeResults failed: %v", err)
+	}
+
+	if len(batch.Maps) != 2 {
+		t.Fatalf("Expected 2 distinct maps, got %d", len(batch.Maps))
+	}
+	if len(batch.Pages) != 102 {
+		t.Fatalf("Expected 102 pages, got %d", len(batch.Pages))
+	}
+
+	if batch.Pages[0].File != "broken.html" || batch.Pages[0].Error != "parse failed" || batch.Pages[0].Map != "" {
+		t.Errorf("Expected failed page first with its error and no map, got %+v", batch.Pages[0])
+	}
+	if batch.Pages[1].File != "other.html" {
+		t.Errorf("Expected pages sorted by file, got %q second", batch.Pages[1].File)
+	}
+
+	sharedID := batch.Pages[2].Map
+	for _, page := range batch.Pages[2:] {
+		if page.Map != sharedID {
+			t.Errorf("Expected %s to reference map %s, got %s", page.File, sharedID, page.Map)
+		}
+	}
+	if sharedID == batch.Pages[1].Map {
+		t.Error("Expected maps with different scopes to get different IDs")
+	}
+	if got := batch.Maps[sharedID].Imports["lit"]; got != shared["lit"] {
+		t.Errorf("Expected shared map to hold lit, got %q", got)
+	}
+
+	again, err := DedupeResults(results[:1])
+	if err != nil {
+		t.Fatalf("DedupeResults failed: %v", err)
+	}
+	if again.Pages[0].Map != sharedID {
+		t.Errorf("Expected map IDs to be stable across batches, got %s and %s", again.Pages[0].Map, sharedID)
+	}
+}
@@ -17,17 +17,22 @@ along with this program. If not, see <http://www.gnu.org/licenses/>.
 package trace
 
 import (
+	"cmp"
 	"fmt"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"bennypowers.dev/mappa/fs"
 	"bennypowers.dev/mappa/packagejson"
 	"bennypowers.dev/mappa/resolve"
+	"bennypowers.dev/mappa/tsconfig"
 )
 
 // ModuleGraph represents the complete module dependency graph.
@@ -41,8 +46,38 @@ type ModuleGraph struct {
 	// Errors collects non-fatal errors encountered during tracing
 	Errors []error
 
+	// ExternalImports collects URL specifiers (e.g., https://cdn.example.com/x.js)
+	// that bypass the import map. They are recorded but not traced.
+	ExternalImports []string
+
+	// MissingCrossOrigin lists the module scripts traced without a crossorigin
+	// attribute, by src, or "inline" for inline scripts. Integrity metadata in
+	// the import map is only checked consistently when modules set crossorigin.
+	MissingCrossOrigin []string
+
+	// CrossOrigin is the crossorigin setting shared by every traced module
+	// script, which modulepreload links must mirror for the browser to reuse
+	// the preloaded modules. It is empty when none set crossorigin or they
+	// disagree.
+	CrossOrigin string
+
+	// UnresolvableImports lists dynamic import() calls whose specifier is
+	// computed at runtime, such as import(`./locales/${lang}.js`). They can't
+	// be traced, so the modules they load need entries added by hand.
+	UnresolvableImports []UnresolvableImport
+
 	// bareSpecifiers collects all bare import specifiers (need to be resolved)
 	bareSpecifiers map[string]bool
+
+	// dynamicSpecifiers collects bare specifiers only ever seen in dynamic import() calls
+	dynamicSpecifiers map[string]bool
+
+	// edges maps each importer to the module paths it imports. The page
+	// itself, including its inline scripts, is the importer "".
+	edges map[string][]string
+
+	// bareImports maps each importer to the bare specifiers it imports.
+	bareImports map[string][]string
 }
 
 // Module represents a parsed module in the graph.
@@ -54,13 +89,18 @@ type Module struct {
 
 // Tracer builds module graphs from HTML and JavaScript entrypoints.
 type Tracer struct {
-	fs              fs.FileSystem
-	rootDir         string
-	logger          resolve.Logger
-	nodeModulesPath string // Path to node_modules for resolving bare specifiers
-	followBare      bool   // Whether to follow bare specifier imports into node_modules
-	selfPkg         *packagejson.PackageJSON // Current package for self-referencing imports
-	selfPkgPath     string                   // Path to current package root
+	fs               fs.FileSystem
+	rootDir          string
+	logger           resolve.Logger
+	nodeModulesPaths []string                 // node_modules directories searched in order for bare specifiers
+	followBare       bool                     // Whether to follow bare specifier imports into node_modules
+	selfPkg          *packagejson.PackageJSON // Current package for self-referencing imports
+	selfPkgPath      string                   // Path to current package root
+	staticOnly       bool                     // Whether to skip dynamic import() specifiers
+	skipTemplates    bool                     // Whether to skip scripts inside <template> elements
+	timings          *Timings                 // Per-phase timing accumulator, if any
+	aliasBaseDir     string                   // Directory tsconfig path alias targets are relative to
+	aliasPaths       map[string][]string      // tsconfig compilerOptions.paths aliases
 
 	// pkgCache caches parsed package.json files by path (thread-safe).
 	// Pointer is used so caches can be shared across builder method calls.
@@ -83,31 +123,49 @@ func NewTracer(fs fs.FileSystem, rootDir string) *Tracer {
 // WithLogger returns a new Tracer that logs warnings to the given logger.
 func (t *Tracer) WithLogger(logger resolve.Logger) *Tracer {
 	return &Tracer{
-		fs:              t.fs,
-		rootDir:         t.rootDir,
-		logger:          logger,
-		nodeModulesPath: t.nodeModulesPath,
-		followBare:      t.followBare,
-		selfPkg:         t.selfPkg,
-		selfPkgPath:     t.selfPkgPath,
-		pkgCache:        t.pkgCache,
-		moduleCache:     t.moduleCache,
+		fs:               t.fs,
+		rootDir:          t.rootDir,
+		logger:           logger,
+		nodeModulesPaths: t.nodeModulesPaths,
+		followBare:       t.followBare,
+		selfPkg:          t.selfPkg,
+		selfPkgPath:      t.selfPkgPath,
+		staticOnly:       t.staticOnly,
+		skipTemplates:    t.skipTemplates,
+		timings:          t.timings,
+		aliasBaseDir:     t.aliasBaseDir,
+		aliasPaths:       t.aliasPaths,
+		pkgCache:         t.pkgCache,
+		moduleCache:      t.moduleCache,
 	}
 }
 
 // WithNodeModules returns a new Tracer that resolves bare specifiers from the given
 // node_modules path. When set, the tracer will follow transitive dependencies.
 func (t *Tracer) WithNodeModules(nodeModulesPath string) *Tracer {
+	return t.WithNodeModulesPaths([]string{nodeModulesPath})
+}
+
+// WithNodeModulesPaths returns a new Tracer that resolves bare specifiers from the
+// first of the given node_modules paths containing the package, e.g. a package's
+// own node_modules before the workspace root's, for non-hoisted (nohoist) installs.
+// When set, the tracer will follow transitive dependencies.
+func (t *Tracer) WithNodeModulesPaths(paths []string) *Tracer {
 	return &Tracer{
-		fs:              t.fs,
-		rootDir:         t.rootDir,
-		logger:          t.logger,
-		nodeModulesPath: nodeModulesPath,
-		followBare:      true,
-		selfPkg:         t.selfPkg,
-		selfPkgPath:     t.selfPkgPath,
-		pkgCache:        t.pkgCache,
-		moduleCache:     t.moduleCache,
+		fs:               t.fs,
+		rootDir:          t.rootDir,
+		logger:           t.logger,
+		nodeModulesPaths: paths,
+		followBare:       true,
+		selfPkg:          t.selfPkg,
+		selfPkgPath:      t.selfPkgPath,
+		staticOnly:       t.staticOnly,
+		skipTemplates:    t.skipTemplates,
+		timings:          t.timings,
+		aliasBaseDir:     t.aliasBaseDir,
+		aliasPaths:       t.aliasPaths,
+		pkgCache:         t.pkgCache,
+		moduleCache:      t.moduleCache,
 	}
 }
 
@@ -116,81 +174,177 @@ func (t *Tracer) WithNodeModules(nodeModulesPath string) *Tracer {
 // that imports itself (e.g., @rhds/elements importing @rhds/elements/rh-button/rh-button.js).
 func (t *Tracer) WithSelfPackage(pkg *packagejson.PackageJSON, pkgPath string) *Tracer {
 	return &Tracer{
-		fs:              t.fs,
-		rootDir:         t.rootDir,
-		logger:          t.logger,
-		nodeModulesPath: t.nodeModulesPath,
-		followBare:      t.followBare,
-		selfPkg:         pkg,
-		selfPkgPath:     pkgPath,
-		pkgCache:        t.pkgCache,
-		moduleCache:     t.moduleCache,
+		fs:               t.fs,
+		rootDir:          t.rootDir,
+		logger:           t.logger,
+		nodeModulesPaths: t.nodeModulesPaths,
+		followBare:       t.followBare,
+		selfPkg:          pkg,
+		selfPkgPath:      pkgPath,
+		staticOnly:       t.staticOnly,
+		skipTemplates:    t.skipTemplates,
+		timings:          t.timings,
+		aliasBaseDir:     t.aliasBaseDir,
+		aliasPaths:       t.aliasPaths,
+		pkgCache:         t.pkgCache,
+		moduleCache:      t.moduleCache,
+	}
+}
+
+// WithStaticOnly returns a new Tracer that skips dynamic import() specifiers.
+// Dynamically-imported modules are neither followed nor collected as bare specifiers,
+// which keeps lazily-loaded code out of the generated import map.
+func (t *Tracer) WithStaticOnly() *Tracer {
+	return &Tracer{
+		fs:               t.fs,
+		rootDir:          t.rootDir,
+		logger:           t.logger,
+		nodeModulesPaths: t.nodeModulesPaths,
+		followBare:       t.followBare,
+		selfPkg:          t.selfPkg,
+		selfPkgPath:      t.selfPkgPath,
+		staticOnly:       true,
+		skipTemplates:    t.skipTemplates,
+		timings:          t.timings,
+		aliasBaseDir:     t.aliasBaseDir,
+		aliasPaths:       t.aliasPaths,
+		pkgCache:         t.pkgCache,
+		moduleCache:      t.moduleCache,
+	}
+}
+
+// WithSkipTemplates returns a new Tracer that ignores scripts inside <template>
+// elements. Such scripts only run once the template is cloned into the document,
+// so lazily-defined components can be left out of the generated import map.
+func (t *Tracer) WithSkipTemplates() *Tracer {
+	return &Tracer{
+		fs:               t.fs,
+		rootDir:          t.rootDir,
+		logger:           t.logger,
+		nodeModulesPaths: t.nodeModulesPaths,
+		followBare:       t.followBare,
+		selfPkg:          t.selfPkg,
+		selfPkgPath:      t.selfPkgPath,
+		staticOnly:       t.staticOnly,
+		skipTemplates:    true,
+		timings:          t.timings,
+		aliasBaseDir:     t.aliasBaseDir,
+		aliasPaths:       t.aliasPaths,
+		pkgCache:         t.pkgCache,
+		moduleCache:      t.moduleCache,
+	}
+}
+
+// WithTimings returns a new Tracer that records time spent parsing HTML and
+// extracting module imports into timings.
+func (t *Tracer) WithTimings(timings *Timings) *Tracer {
+	return &Tracer{
+		fs:               t.fs,
+		rootDir:          t.rootDir,
+		logger:           t.logger,
+		nodeModulesPaths: t.nodeModulesPaths,
+		followBare:       t.followBare,
+		selfPkg:          t.selfPkg,
+		selfPkgPath:      t.selfPkgPath,
+		staticOnly:       t.staticOnly,
+		skipTemplates:    t.skipTemplates,
+		timings:          timings,
+		aliasBaseDir:     t.aliasBaseDir,
+		aliasPaths:       t.aliasPaths,
+		pkgCache:         t.pkgCache,
+		moduleCache:      t.moduleCache,
+	}
+}
+
+// WithTSConfigPaths returns a new Tracer that resolves specifiers matching
+// tsconfig compilerOptions.paths aliases (e.g. "@app/*" -> "src/*") to local
+// files before trying node_modules, and traces through them. baseURL is the
+// directory alias targets are relative to; a relative baseURL is resolved
+// against the root directory. Aliased specifiers are still collected as bare
+// specifiers, since the browser needs import map entries for them.
+func (t *Tracer) WithTSConfigPaths(baseURL string, paths map[string][]string) *Tracer {
+	if !filepath.IsAbs(baseURL) {
+		baseURL = filepath.Join(t.rootDir, baseURL)
+	}
+	return &Tracer{
+		fs:               t.fs,
+		rootDir:          t.rootDir,
+		logger:           t.logger,
+		nodeModulesPaths: t.nodeModulesPaths,
+		followBare:       t.followBare,
+		selfPkg:          t.selfPkg,
+		selfPkgPath:      t.selfPkgPath,
+		staticOnly:       t.staticOnly,
+		skipTemplates:    t.skipTemplates,
+		timings:          t.timings,
+		aliasBaseDir:     baseURL,
+		aliasPaths:       paths,
+		pkgCache:         t.pkgCache,
+		moduleCache:      t.moduleCache,
 	}
 }
 
 // TraceHTML parses an HTML file and traces all module scripts.
 func (t *Tracer) TraceHTML(htmlPath string) (*ModuleGraph, error) {
+	start := time.Now()
 	content, err := t.fs.ReadFile(htmlPath)
 	if err != nil {
 		return nil, err
 	}
 
 	scripts, err := ExtractScripts(content)
+	t.timings.addHTMLParse(start)
 	if err != nil {
 		return nil, err
 	}
 
 	graph := &ModuleGraph{
-		Modules:        make(map[string]*Module),
-		bareSpecifiers: make(map[string]bool),
+		Modules:           make(map[string]*Module),
+		bareSpecifiers:    make(map[string]bool),
+		dynamicSpecifiers: make(map[string]bool),
 	}
 
 	htmlDir := filepath.Dir(htmlPath)
 
+	var crossOrigins []string
 	for _, script := range scripts {
-		if script.Type != "module" {
+		// Browsers that support import maps skip classic nomodule scripts;
+		// nomodule has no effect on module scripts
+		if !isModuleScript(script.Type) || (script.InTemplate && t.skipTemplates) {
 			continue
 		}
 
+		if script.CrossOrigin == "" {
+			graph.MissingCrossOrigin = append(graph.MissingCrossOrigin, cmp.Or(script.Src, "inline"))
+		}
+		if !slices.Contains(crossOrigins, script.CrossOrigin) {
+			crossOrigins = append(crossOrigins, script.CrossOrigin)
+		}
+
 		if script.Src != "" {
+			// Scripts loaded from a URL bypass the import map - record them
+			if isURLSpecifier(script.Src) {
+				graph.addExternalImport(script.Src)
+				continue
+			}
 			// External module script - trace it
 			modulePath := t.resolvePath(htmlDir, script.Src)
 			graph.Entrypoints = append(graph.Entrypoints, modulePath)
+			graph.addEdge("", modulePath)
 			if err := t.traceModule(graph, modulePath); err != nil {
 				graph.Errors = append(graph.Errors, fmt.Errorf("tracing %s: %w", modulePath, err))
 				continue
 			}
 		} else if script.Inline {
 			// Inline module - collect its imports
-			for _, imp := range script.Imports {
-				if isBareSpecifier(imp) {
-					graph.bareSpecifiers[imp] = true
-
-					// Follow bare specifiers into node_modules if configured
-					if t.followBare {
-						depPath, err := t.resolveBareSpecifier(imp)
-						if err != nil {
-							graph.Errors = append(graph.Errors, fmt.Errorf("resolving %s: %w", imp, err))
-							continue
-						}
-						if depPath != "" {
-							if err := t.traceModule(graph, depPath); err != nil {
-								graph.Errors = append(graph.Errors, fmt.Errorf("tracing %s: %w", depPath, err))
-								continue
-							}
-						}
-					}
-				} else {
-					// Relative import from inline script
-					modulePath := t.resolvePath(htmlDir, imp)
-					if err := t.traceModule(graph, modulePath); err != nil {
-						graph.Errors = append(graph.Errors, fmt.Errorf("tracing %s: %w", modulePath, err))
-						continue
-					}
-				}
+			for _, imp := range script.ModuleImports {
+				t.followImport(graph, "", htmlDir, imp)
 			}
 		}
 	}
+	if len(crossOrigins) == 1 {
+		graph.CrossOrigin = crossOrigins[0]
+	}
 
 	return graph, nil
 }
@@ -198,9 +352,10 @@ func (t *Tracer) TraceHTML(htmlPath string) (*ModuleGraph, error) {
 // TraceModule traces a single module and all its dependencies.
 func (t *Tracer) TraceModule(modulePath string) (*ModuleGraph, error) {
 	graph := &ModuleGraph{
-		Entrypoints:    []string{modulePath},
-		Modules:        make(map[string]*Module),
-		bareSpecifiers: make(map[string]bool),
+		Entrypoints:       []string{modulePath},
+		Modules:           make(map[string]*Module),
+		bareSpecifiers:    make(map[string]bool),
+		dynamicSpecifiers: make(map[string]bool),
 	}
 
 	if err := t.traceModule(graph, modulePath); err != nil {
@@ -210,7 +365,8 @@ func (t *Tracer) TraceModule(modulePath string) (*ModuleGraph, error) {
 	return graph, nil
 }
 
-// traceModule recursively traces a module and its dependencies.
+// traceModule recursively traces a module and its dependencies. Stylesheets
+// are traced through their @import rules.
 func (t *Tracer) traceModule(graph *ModuleGraph, modulePath string) error {
 	// Already traced in this graph?
 	if mod, exists := graph.Modules[modulePath]; exists && mod.Traced {
@@ -229,12 +385,19 @@ func (t *Tracer) traceModule(graph *ModuleGraph, modulePath string) error {
 		}
 	} else {
 		// Read and parse the module
+		start := time.Now()
 		content, err := t.fs.ReadFile(modulePath)
 		if err != nil {
 			return err
 		}
 
-		imports, err := ExtractImports(content)
+		var imports []ModuleImport
+		if isCSSFile(modulePath) {
+			imports = extractCSSModuleImports(content)
+		} else {
+			imports, err = ExtractImports(content)
+		}
+		t.timings.addImportExtraction(start)
 		if err != nil {
 			return err
 		}
@@ -255,36 +418,132 @@ func (t *Tracer) traceModule(graph *ModuleGraph, modulePath string) error {
 	// Process imports
 	moduleDir := filepath.Dir(modulePath)
 	for _, imp := range mod.Imports {
-		if isBareSpecifier(imp.Specifier) {
-			graph.bareSpecifiers[imp.Specifier] = true
-
-			// Follow bare specifiers into node_modules if configured
-			if t.followBare {
-				depPath, err := t.resolveBareSpecifier(imp.Specifier)
-				if err != nil {
-					graph.Errors = append(graph.Errors, fmt.Errorf("resolving %s: %w", imp.Specifier, err))
-					continue
-				}
-				if depPath != "" {
-					if err := t.traceModule(graph, depPath); err != nil {
-						graph.Errors = append(graph.Errors, fmt.Errorf("tracing %s: %w", depPath, err))
-						continue
-					}
-				}
-			}
-		} else {
-			// Relative or absolute path - resolve and trace
-			depPath := t.resolvePath(moduleDir, imp.Specifier)
-			if err := t.traceModule(graph, depPath); err != nil {
-				graph.Errors = append(graph.Errors, fmt.Errorf("tracing %s: %w", depPath, err))
-				continue
-			}
-		}
+		t.followImport(graph, modulePath, moduleDir, imp)
 	}
 
 	return nil
 }
 
+// UnresolvableImport is a dynamic import() of a computed specifier.
+type UnresolvableImport struct {
+	// Module is the path of the importing module, or "" for an inline script.
+	Module string
+	// Line is the 1-indexed line of the import() argument.
+	Line int
+	// Expression is the source text of the import() argument.
+	Expression string
+}
+
+// followImport records an import by the module at from in the graph and traces
+// the module it refers to. Bare specifiers are followed into node_modules when
+// configured; relative and absolute paths are resolved against baseDir. Errors
+// are collected on the graph.
+func (t *Tracer) followImport(graph *ModuleGraph, from, baseDir string, imp ModuleImport) {
+	if imp.Unresolvable {
+		if !t.staticOnly {
+			graph.UnresolvableImports = append(graph.UnresolvableImports, UnresolvableImport{
+				Module:     from,
+				Line:       imp.Line,
+				Expression: imp.Specifier,
+			})
+		}
+		return
+	}
+
+	// URL imports bypass the import map - record them without tracing
+	if isURLSpecifier(imp.Specifier) {
+		graph.addExternalImport(imp.Specifier)
+		return
+	}
+
+	if !isBareSpecifier(imp.Specifier) {
+		if imp.IsDynamic && t.staticOnly {
+			return
+		}
+		// Relative or absolute path - resolve and trace
+		depPath := t.resolvePath(baseDir, imp.Specifier)
+		graph.addEdge(from, depPath)
+		if err := t.traceModule(graph, depPath); err != nil {
+			graph.Errors = append(graph.Errors, fmt.Errorf("tracing %s: %w", depPath, err))
+		}
+		return
+	}
+
+	if !graph.recordBareSpecifier(imp.Specifier, imp.IsDynamic, t.staticOnly) {
+		return
+	}
+	graph.addBareImport(from, imp.Specifier)
+
+	// Follow tsconfig path aliases to local files
+	if aliasPath, ok := tsconfig.ResolvePath(t.fs, t.aliasBaseDir, t.aliasPaths, imp.Specifier); ok {
+		graph.addEdge(from, aliasPath)
+		if err := t.traceModule(graph, aliasPath); err != nil {
+			graph.Errors = append(graph.Errors, fmt.Errorf("tracing %s: %w", aliasPath, err))
+		}
+		return
+	}
+
+	// Follow bare specifiers into node_modules if configured
+	if !t.followBare {
+		return
+	}
+	depPath, err := t.resolveBareSpecifier(imp.Specifier)
+	if err != nil {
+		graph.Errors = append(graph.Errors, fmt.Errorf("resolving %s: %w", imp.Specifier, err))
+		return
+	}
+	if depPath != "" {
+		graph.addEdge(from, depPath)
+		if err := t.traceModule(graph, depPath); err != nil {
+			graph.Errors = append(graph.Errors, fmt.Errorf("tracing %s: %w", depPath, err))
+		}
+	}
+}
+
+// addEdge records that the module at from imports the module at to.
+func (g *ModuleGraph) addEdge(from, to string) {
+	if g.edges == nil {
+		g.edges = make(map[string][]string)
+	}
+	g.edges[from] = append(g.edges[from], to)
+}
+
+// addBareImport records that the module at from imports a bare specifier.
+func (g *ModuleGraph) addBareImport(from, specifier string) {
+	if g.bareImports == nil {
+		g.bareImports = make(map[string][]string)
+	}
+	g.bareImports[from] = append(g.bareImports[from], specifier)
+}
+
+// addExternalImport records a URL specifier, ignoring duplicates.
+func (g *ModuleGraph) addExternalImport(specifier string) {
+	if !slices.Contains(g.ExternalImports, specifier) {
+		g.ExternalImports = append(g.ExternalImports, specifier)
+	}
+}
+
+// recordBareSpecifier records a bare specifier in the graph, tracking whether it
+// has only been seen in dynamic imports. Returns false if the specifier was
+// excluded because it is dynamic and staticOnly is set.
+func (g *ModuleGraph) recordBareSpecifier(specifier string, dynamic, staticOnly bool) bool {
+	if !dynamic {
+		delete(g.dynamicSpecifiers, specifier)
+		g.bareSpecifiers[specifier] = true
+		return true
+	}
+
+	// Only mark as dynamic if not already imported statically
+	if !g.bareSpecifiers[specifier] || g.dynamicSpecifiers[specifier] {
+		g.dynamicSpecifiers[specifier] = true
+	}
+	if staticOnly {
+		return false
+	}
+	g.bareSpecifiers[specifier] = true
+	return true
+}
+
 // getPackageJSON returns a cached package.json, parsing and caching it if needed.
 // Returns nil if the package.json doesn't exist or can't be parsed.
 // Parse errors (as opposed to missing files) are logged for debugging.
@@ -330,21 +589,18 @@ func (t *Tracer) resolveBareSpecifier(specifier string) (string, error) {
 		return t.resolveSelfImport(subpath)
 	}
 
-	// Fall back to node_modules resolution
-	if t.nodeModulesPath == "" {
-		return "", nil
-	}
-
-	// Load package.json from node_modules (cached)
-	pkgPath := filepath.Join(t.nodeModulesPath, pkgName)
-	pkgJSONPath := filepath.Join(pkgPath, "package.json")
-	pkg := t.getPackageJSON(pkgJSONPath)
-	if pkg == nil {
-		// Package not found - can't follow
-		return "", nil
+	// Fall back to node_modules resolution, nearest directory first
+	for _, nodeModulesPath := range t.nodeModulesPaths {
+		// Load package.json from node_modules (cached)
+		pkgPath := filepath.Join(nodeModulesPath, pkgName)
+		pkg := t.getPackageJSON(filepath.Join(pkgPath, "package.json"))
+		if pkg != nil {
+			return resolvePackageSubpath(pkg, pkgPath, subpath)
+		}
 	}
 
-	return resolvePackageSubpath(pkg, pkgPath, subpath)
+	// Package not found - can't follow
+	return "", nil
 }
 
 // resolveSelfImport resolves an import of the current package (self-reference).
@@ -409,12 +665,21 @@ func isBareSpecifier(specifier string) bool {
 		return false
 	}
 	// Check for URL schemes
-	if strings.Contains(specifier, "://") {
+	if isURLSpecifier(specifier) {
 		return false
 	}
 	return true
 }
 
+// isURLSpecifier returns true if the specifier is an absolute URL
+// (e.g., https://cdn.example.com/x.js, data:text/javascript,... or blob:...)
+// rather than a path or bare specifier. As in browsers, any specifier that
+// parses with a scheme is a URL.
+func isURLSpecifier(specifier string) bool {
+	u, err := url.Parse(specifier)
+	return err == nil && u.Scheme != ""
+}
+
 // BareSpecifiers returns a sorted slice of all bare specifiers found.
 func (g *ModuleGraph) BareSpecifiers() []string {
 	specifiers := make([]string, 0, len(g.bareSpecifiers))
@@ -425,6 +690,17 @@ func (g *ModuleGraph) BareSpecifiers() []string {
 	return specifiers
 }
 
+// DynamicSpecifiers returns a sorted slice of bare specifiers that were only
+// found in dynamic import() calls.
+func (g *ModuleGraph) DynamicSpecifiers() []string {
+	specifiers := make([]string, 0, len(g.dynamicSpecifiers))
+	for spec := range g.dynamicSpecifiers {
+		specifiers = append(specifiers, spec)
+	}
+	sort.Strings(specifiers)
+	return specifiers
+}
+
 // PackageNames extracts sorted package names from bare specifiers.
 // e.g., "lit/decorators.js" -> "lit"
 func (g *ModuleGraph) PackageNames() []string {
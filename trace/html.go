@@ -23,26 +23,85 @@ import (
 	"golang.org/x/net/html"
 )
 
+// Script types used by es-module-shims in shim mode, for browsers without
+// native import map support.
+const (
+	ModuleShimType    = "module-shim"
+	ImportMapShimType = "importmap-shim"
+)
+
+// isModuleScript reports whether a script type is loaded as an ES module,
+// either natively or through es-module-shims.
+func isModuleScript(scriptType string) bool {
+	return scriptType == "module" || scriptType == ModuleShimType
+}
+
+// corsSetting returns the CORS setting of a crossorigin attribute value. As in
+// browsers, any value other than use-credentials, including none, is
+// anonymous.
+func corsSetting(value string) string {
+	if strings.EqualFold(value, "use-credentials") {
+		return "use-credentials"
+	}
+	return "anonymous"
+}
+
 // ImportMapLocation describes an existing import map script tag in HTML.
 type ImportMapLocation struct {
-	Found        bool // True if an import map tag was found
-	TagStart     int  // Byte offset of opening <script
-	TagEnd       int  // Byte offset after closing </script>
-	ContentStart int  // Byte offset of JSON content start
-	ContentEnd   int  // Byte offset of JSON content end
-	Line         int  // 1-indexed line number for warnings
+	Found        bool   // True if an import map tag was found
+	Type         string // The tag's type attribute ("importmap" or "importmap-shim")
+	TagStart     int    // Byte offset of opening <script
+	TagEnd       int    // Byte offset after closing </script>
+	ContentStart int    // Byte offset of JSON content start
+	ContentEnd   int    // Byte offset of JSON content end
+	Line         int    // 1-indexed line number for warnings
 }
 
 // InsertPoint describes where to insert a new import map in HTML.
 type InsertPoint struct {
 	Found  bool   // True if a valid insertion point was found
 	Offset int    // Byte offset for insertion
+	Length int    // Bytes at Offset replaced by the insertion, e.g. a marker comment
 	Indent string // Whitespace to use for indentation
 }
 
 // FindImportMapTag locates the first <script type="importmap"> tag in HTML content.
 // Returns byte positions for the tag and its content.
 func FindImportMapTag(content []byte) ImportMapLocation {
+	return firstLocation(findImportMapTags(content, false, true))
+}
+
+// FindShimImportMapTag is like FindImportMapTag, but also matches
+// <script type="importmap-shim"> tags read by es-module-shims.
+func FindShimImportMapTag(content []byte) ImportMapLocation {
+	return firstLocation(findImportMapTags(content, true, true))
+}
+
+// FindImportMapTags locates every <script type="importmap"> tag in HTML
+// content, in document order. A page should have at most one, but pages
+// with several exist, so callers can decide how to handle the extras.
+func FindImportMapTags(content []byte) []ImportMapLocation {
+	return findImportMapTags(content, false, false)
+}
+
+// FindShimImportMapTags is like FindImportMapTags, but also matches
+// <script type="importmap-shim"> tags read by es-module-shims.
+func FindShimImportMapTags(content []byte) []ImportMapLocation {
+	return findImportMapTags(content, true, false)
+}
+
+// firstLocation returns the first of locs, or a location with Found unset.
+func firstLocation(locs []ImportMapLocation) ImportMapLocation {
+	if len(locs) == 0 {
+		return ImportMapLocation{Found: false}
+	}
+	return locs[0]
+}
+
+// findImportMapTags locates import map tags, optionally including
+// importmap-shim tags, stopping after the first if first is set.
+func findImportMapTags(content []byte, shim, first bool) []ImportMapLocation {
+	var locs []ImportMapLocation
 	tokenizer := html.NewTokenizer(bytes.NewReader(content))
 	offset := 0
 	line := 1
@@ -62,19 +121,19 @@ func FindImportMapTag(content []byte) ImportMapLocation {
 		if tt == html.StartTagToken {
 			tagName, hasAttr := tokenizer.TagName()
 			if string(tagName) == "script" && hasAttr {
-				// Check if type="importmap"
-				isImportMap := false
+				// Check if type="importmap" (or "importmap-shim")
+				var tagType string
 				for {
 					key, val, more := tokenizer.TagAttr()
-					if string(key) == "type" && string(val) == "importmap" {
-						isImportMap = true
+					if string(key) == "type" && (string(val) == "importmap" || shim && string(val) == ImportMapShimType) {
+						tagType = string(val)
 					}
 					if !more {
 						break
 					}
 				}
 
-				if isImportMap {
+				if tagType != "" {
 					tagStart := offset
 					tagLine := line + linesBefore
 
@@ -82,43 +141,32 @@ func FindImportMapTag(content []byte) ImportMapLocation {
 					offset += rawLen
 					line += linesBefore
 
-					// Get text content
-					switch tt = tokenizer.Next(); tt {
-					case html.TextToken:
+					// Get text content, then the closing tag
+					loc := ImportMapLocation{Found: true, Type: tagType, TagStart: tagStart, Line: tagLine}
+					tt = tokenizer.Next()
+					if tt == html.TextToken {
 						textRaw := tokenizer.Raw()
-						contentStart := offset
-						contentEnd := offset + len(textRaw)
+						loc.ContentStart = offset
+						loc.ContentEnd = offset + len(textRaw)
 						offset += len(textRaw)
 						line += bytes.Count(textRaw, []byte("\n"))
-
-						// Get closing tag
-						if tt = tokenizer.Next(); tt == html.EndTagToken {
-							endRaw := tokenizer.Raw()
-							tagEnd := offset + len(endRaw)
-
-							return ImportMapLocation{
-								Found:        true,
-								TagStart:     tagStart,
-								TagEnd:       tagEnd,
-								ContentStart: contentStart,
-								ContentEnd:   contentEnd,
-								Line:         tagLine,
-							}
-						}
-					case html.EndTagToken:
+						tt = tokenizer.Next()
+					} else {
 						// Empty import map: <script type="importmap"></script>
-						endRaw := tokenizer.Raw()
-						tagEnd := offset + len(endRaw)
-
-						return ImportMapLocation{
-							Found:        true,
-							TagStart:     tagStart,
-							TagEnd:       tagEnd,
-							ContentStart: offset,
-							ContentEnd:   offset,
-							Line:         tagLine,
+						loc.ContentStart = offset
+						loc.ContentEnd = offset
+					}
+					endRaw := tokenizer.Raw()
+					offset += len(endRaw)
+					line += bytes.Count(endRaw, []byte("\n"))
+					if tt == html.EndTagToken {
+						loc.TagEnd = offset
+						locs = append(locs, loc)
+						if first {
+							return locs
 						}
 					}
+					continue
 				}
 			}
 		}
@@ -127,7 +175,7 @@ func FindImportMapTag(content []byte) ImportMapLocation {
 		line += linesBefore
 	}
 
-	return ImportMapLocation{Found: false}
+	return locs
 }
 
 // FindInsertPoint locates where to insert a new import map in HTML content.
@@ -198,6 +246,77 @@ func FindInsertPoint(content []byte) InsertPoint {
 	return InsertPoint{Found: false}
 }
 
+// FindMarker locates a marker comment such as <!-- importmap --> in HTML
+// content, so that a new import map replaces it. The marker matches a comment
+// either verbatim or by its trimmed text, so "importmap" also finds
+// <!-- importmap -->. Returns Found=false if no comment matches.
+func FindMarker(content []byte, marker string) InsertPoint {
+	marker = strings.TrimSpace(marker)
+	if marker == "" {
+		return InsertPoint{Found: false}
+	}
+
+	tokenizer := html.NewTokenizer(bytes.NewReader(content))
+	offset := 0
+	for {
+		tt := tokenizer.Next()
+		if tt == html.ErrorToken {
+			return InsertPoint{Found: false}
+		}
+
+		raw := tokenizer.Raw()
+		rawLen := len(raw)
+		if tt == html.CommentToken {
+			if string(raw) == marker || strings.TrimSpace(string(tokenizer.Text())) == marker {
+				return InsertPoint{
+					Found:  true,
+					Offset: offset,
+					Length: rawLen,
+					Indent: extractIndent(content, offset),
+				}
+			}
+		}
+
+		offset += rawLen
+	}
+}
+
+// FindModulePreloads returns the href of every <link rel="modulepreload"> tag
+// in HTML content, in document order.
+func FindModulePreloads(content []byte) []string {
+	tokenizer := html.NewTokenizer(bytes.NewReader(content))
+	var hrefs []string
+	for {
+		tt := tokenizer.Next()
+		if tt == html.ErrorToken {
+			return hrefs
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+		tagName, hasAttr := tokenizer.TagName()
+		if string(tagName) != "link" || !hasAttr {
+			continue
+		}
+		var rel, href string
+		for {
+			key, val, more := tokenizer.TagAttr()
+			switch string(key) {
+			case "rel":
+				rel = string(val)
+			case "href":
+				href = string(val)
+			}
+			if !more {
+				break
+			}
+		}
+		if strings.EqualFold(rel, "modulepreload") && href != "" {
+			hrefs = append(hrefs, href)
+		}
+	}
+}
+
 // extractIndent extracts leading whitespace from the line containing the given offset.
 func extractIndent(content []byte, offset int) string {
 	// Find start of line
@@ -228,15 +347,46 @@ func ExtractScripts(content []byte) ([]ScriptTag, error) {
 	}
 
 	var scripts []ScriptTag
-	extractScriptsFromNode(doc, &scripts)
+	extractScriptsFromNode(doc, false, &scripts)
+	locateInlineScripts(content, scripts)
 
 	return scripts, nil
 }
 
+// locateInlineScripts sets the page line of each inline script and shifts
+// the line numbers of its imports, which are relative to the script content,
+// to lines of the page. The parse tree carries no positions, so each
+// script's content is found in the raw HTML, searching in document order.
+// Script text is not entity-decoded, so it appears verbatim in the source.
+func locateInlineScripts(content []byte, scripts []ScriptTag) {
+	offset := 0
+	for i := range scripts {
+		script := &scripts[i]
+		if !script.Inline {
+			continue
+		}
+		idx := bytes.Index(content[offset:], []byte(script.Content))
+		if idx < 0 {
+			continue
+		}
+		offset += idx
+		script.Line = bytes.Count(content[:offset], []byte("\n")) + 1
+		offset += len(script.Content)
+		for j := range script.ModuleImports {
+			script.ModuleImports[j].Line += script.Line - 1
+		}
+	}
+}
+
 // extractScriptsFromNode recursively walks the HTML tree to find script elements.
-func extractScriptsFromNode(n *html.Node, scripts *[]ScriptTag) {
+// inTemplate reports whether n has a <template> ancestor.
+func extractScriptsFromNode(n *html.Node, inTemplate bool, scripts *[]ScriptTag) {
+	if n.Type == html.ElementNode && n.Data == "template" {
+		inTemplate = true
+	}
+
 	if n.Type == html.ElementNode && n.Data == "script" {
-		script := ScriptTag{}
+		script := ScriptTag{InTemplate: inTemplate}
 
 		// Extract attributes
 		for _, attr := range n.Attr {
@@ -245,6 +395,10 @@ func extractScriptsFromNode(n *html.Node, scripts *[]ScriptTag) {
 				script.Type = attr.Val
 			case "src":
 				script.Src = attr.Val
+			case "nomodule":
+				script.NoModule = true
+			case "crossorigin":
+				script.CrossOrigin = corsSetting(attr.Val)
 			}
 		}
 
@@ -258,13 +412,18 @@ func extractScriptsFromNode(n *html.Node, scripts *[]ScriptTag) {
 		}
 
 		// Parse imports from inline content (best-effort; syntax errors are ignored)
-		// Handle both type="module" (static + dynamic) and regular scripts (dynamic only)
-		if script.Inline && script.Content != "" {
+		// Handle both module scripts (static + dynamic) and regular scripts (dynamic only).
+		// type="module-shim" scripts are modules loaded by es-module-shims.
+		// Classic nomodule scripts never run in browsers that support import maps.
+		if script.Inline && script.Content != "" && (!script.NoModule || isModuleScript(script.Type)) {
 			imports, _ := ExtractImports([]byte(script.Content))
 			for _, imp := range imports {
 				// For non-module scripts, only include dynamic imports
-				if script.Type == "module" || imp.IsDynamic {
-					script.Imports = append(script.Imports, imp.Specifier)
+				if isModuleScript(script.Type) || imp.IsDynamic {
+					if !imp.Unresolvable {
+						script.Imports = append(script.Imports, imp.Specifier)
+					}
+					script.ModuleImports = append(script.ModuleImports, imp)
 				}
 			}
 		}
@@ -274,6 +433,6 @@ func extractScriptsFromNode(n *html.Node, scripts *[]ScriptTag) {
 
 	// Recurse into children
 	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		extractScriptsFromNode(c, scripts)
+		extractScriptsFromNode(c, inTemplate, scripts)
 	}
 }
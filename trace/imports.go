@@ -18,17 +18,62 @@ package trace
 
 import (
 	"fmt"
+	"path"
+	"regexp"
+	"strings"
 
 	ts "github.com/tree-sitter/go-tree-sitter"
 )
 
+// importAttributesPattern matches a static import or re-export source followed by
+// an import attributes clause, e.g. `from './data.json' with { type: 'json' }`.
+// The legacy `assert` keyword is matched too.
+var importAttributesPattern = regexp.MustCompile(`\b(?:from|import)\s*["']([^"'\r\n]*)["']\s*((?:with|assert)\s*\{[^{}]*\})`)
+
+// attributeTypePattern extracts the type attribute from an attributes clause.
+var attributeTypePattern = regexp.MustCompile(`\btype\s*:\s*["']([^"']*)["']`)
+
+// maskImportAttributes blanks out import attributes clauses, which the TypeScript
+// grammar cannot parse on re-exports, so that they don't hide the statement's
+// source. Byte offsets and line numbers are preserved. Returns the masked content
+// and the type attribute of each masked clause, keyed by the end offset of the
+// specifier it belongs to.
+func maskImportAttributes(content []byte) ([]byte, map[uint]string) {
+	matches := importAttributesPattern.FindAllSubmatchIndex(content, -1)
+	if matches == nil {
+		return content, nil
+	}
+
+	masked := make([]byte, len(content))
+	copy(masked, content)
+	types := make(map[uint]string)
+	for _, m := range matches {
+		specEnd, attrStart, attrEnd := m[3], m[4], m[5]
+		if t := attributeTypePattern.FindSubmatch(content[attrStart:attrEnd]); t != nil {
+			types[uint(specEnd)] = string(t[1])
+		}
+		for i := attrStart; i < attrEnd; i++ {
+			if masked[i] != '\n' && masked[i] != '\r' {
+				masked[i] = ' '
+			}
+		}
+	}
+	return masked, types
+}
+
 // ExtractImports parses JavaScript/TypeScript content and extracts all import specifiers.
+// Import attributes (`with { type: 'json' }`) are supported on static imports and
+// re-exports, and their type is recorded on the import. import.meta is not an import.
+// Module URLs built with new URL(spec, import.meta.url), as used to start workers,
+// are recorded as dynamic imports when they name a JavaScript or TypeScript file.
 func ExtractImports(content []byte) ([]ModuleImport, error) {
 	qm, err := GetQueryManager()
 	if err != nil {
 		return nil, err
 	}
 
+	content, attributeTypes := maskImportAttributes(content)
+
 	parser := getTSParser()
 	defer putTSParser(parser)
 
@@ -67,6 +112,7 @@ func ExtractImports(content []byte) ([]ModuleImport, error) {
 					Specifier: text,
 					IsDynamic: false,
 					Line:      line,
+					Type:      attributeTypes[capture.Node.EndByte()],
 				})
 			case "dynamicImport.spec":
 				imports = append(imports, ModuleImport{
@@ -74,11 +120,45 @@ func ExtractImports(content []byte) ([]ModuleImport, error) {
 					IsDynamic: true,
 					Line:      line,
 				})
+			case "dynamicImport.expr":
+				switch capture.Node.Kind() {
+				case "string":
+					// Captured as dynamicImport.spec
+					continue
+				case "template_string":
+					// A template literal without substitutions is a plain string
+					if !hasTemplateSubstitution(&capture.Node) {
+						imports = append(imports, ModuleImport{
+							Specifier: strings.Trim(text, "`"),
+							IsDynamic: true,
+							Line:      line,
+						})
+						continue
+					}
+				}
+				imports = append(imports, ModuleImport{
+					Specifier:    text,
+					IsDynamic:    true,
+					Line:         line,
+					Unresolvable: true,
+				})
 			case "reexport.spec":
 				imports = append(imports, ModuleImport{
 					Specifier: text,
 					IsDynamic: false,
 					Line:      line,
+					Type:      attributeTypes[capture.Node.EndByte()],
+				})
+			case "url.spec":
+				spec, ok := moduleURLSpecifier(text)
+				if !ok {
+					continue
+				}
+				imports = append(imports, ModuleImport{
+					Specifier: spec,
+					IsDynamic: true,
+					IsURL:     true,
+					Line:      line,
 				})
 			}
 		}
@@ -86,3 +166,37 @@ func ExtractImports(content []byte) ([]ModuleImport, error) {
 
 	return imports, nil
 }
+
+// hasTemplateSubstitution reports whether a template_string node contains a
+// ${...} substitution.
+func hasTemplateSubstitution(node *ts.Node) bool {
+	for i := range node.NamedChildCount() {
+		if child := node.NamedChild(i); child != nil && child.Kind() == "template_substitution" {
+			return true
+		}
+	}
+	return false
+}
+
+// moduleURLExtensions are the file extensions of module URLs worth tracing.
+// Other URLs built from import.meta.url usually point at assets.
+var moduleURLExtensions = map[string]bool{
+	".js": true, ".mjs": true, ".ts": true, ".mts": true, ".jsx": true, ".tsx": true,
+}
+
+// moduleURLSpecifier returns the specifier to trace for the first argument of
+// new URL(spec, import.meta.url). URL resolution has no bare specifiers, so
+// "worker.js" is relative to the module like "./worker.js". Returns false for
+// URLs with a scheme and for non-module files.
+func moduleURLSpecifier(spec string) (string, bool) {
+	if spec == "" || strings.Contains(spec, ":") || strings.ContainsAny(spec, "?#") {
+		return "", false
+	}
+	if !moduleURLExtensions[path.Ext(spec)] {
+		return "", false
+	}
+	if !strings.HasPrefix(spec, "/") && !strings.HasPrefix(spec, "./") && !strings.HasPrefix(spec, "../") {
+		spec = "./" + spec
+	}
+	return spec, true
+}
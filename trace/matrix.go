@@ -0,0 +1,87 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package trace
+
+import (
+	"fmt"
+	"strings"
+
+	"bennypowers.dev/mappa/fs"
+)
+
+// Variant is a named set of export conditions to trace under.
+type Variant struct {
+	// Name tags the variant's results (e.g., "dev" or "prod").
+	Name string
+	// Conditions is the export condition priority for the variant.
+	Conditions []string
+}
+
+// ParseConditionsMatrix parses variant definitions of the form
+// "name=cond1,cond2". Each entry may hold several whitespace-separated
+// definitions, so "dev=development,default prod=production,default"
+// yields two variants.
+func ParseConditionsMatrix(entries []string) ([]Variant, error) {
+	var variants []Variant
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		for def := range strings.FieldsSeq(entry) {
+			name, list, ok := strings.Cut(def, "=")
+			if !ok || name == "" {
+				return nil, fmt.Errorf("invalid conditions matrix entry %q: expected name=condition,...", def)
+			}
+			if seen[name] {
+				return nil, fmt.Errorf("duplicate conditions matrix variant %q", name)
+			}
+			seen[name] = true
+
+			var conditions []string
+			for cond := range strings.SplitSeq(list, ",") {
+				if cond = strings.TrimSpace(cond); cond != "" {
+					conditions = append(conditions, cond)
+				}
+			}
+			if len(conditions) == 0 {
+				return nil, fmt.Errorf("conditions matrix variant %q has no conditions", name)
+			}
+			variants = append(variants, Variant{Name: name, Conditions: conditions})
+		}
+	}
+	return variants, nil
+}
+
+// TraceMatrix traces files once per variant, resolving each with the
+// variant's conditions in place of opts.Conditions. Results are tagged with
+// the variant name and arrive grouped by variant, in the order given.
+func TraceMatrix(osfs fs.FileSystem, files []string, absRoot string, opts Options, variants []Variant) <-chan BatchResult {
+	results := make(chan BatchResult, len(files))
+
+	go func() {
+		defer close(results)
+		for _, variant := range variants {
+			variantOpts := opts
+			variantOpts.Conditions = variant.Conditions
+			for result := range TraceBatch(osfs, files, absRoot, variantOpts) {
+				result.Variant = variant.Name
+				results <- result
+			}
+		}
+	}()
+
+	return results
+}
@@ -0,0 +1,71 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package trace
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseConditionsMatrix(t *testing.T) {
+	tests := []struct {
+		name     string
+		entries  []string
+		expected []Variant
+		wantErr  bool
+	}{
+		{
+			name:    "space-separated",
+			entries: []string{"dev=development,default prod=production,default"},
+			expected: []Variant{
+				{Name: "dev", Conditions: []string{"development", "default"}},
+				{Name: "prod", Conditions: []string{"production", "default"}},
+			},
+		},
+		{
+			name:    "repeated",
+			entries: []string{"dev=development,default", "prod=production,!development"},
+			expected: []Variant{
+				{Name: "dev", Conditions: []string{"development", "default"}},
+				{Name: "prod", Conditions: []string{"production", "!development"}},
+			},
+		},
+		{name: "missing name", entries: []string{"=production"}, wantErr: true},
+		{name: "missing conditions", entries: []string{"prod="}, wantErr: true},
+		{name: "no equals", entries: []string{"production"}, wantErr: true},
+		{name: "duplicate", entries: []string{"dev=development dev=default"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			variants, err := ParseConditionsMatrix(tt.entries)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error, got %v", variants)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseConditionsMatrix failed: %v", err)
+			}
+			if !reflect.DeepEqual(variants, tt.expected) {
+				t.Errorf("got %v, want %v", variants, tt.expected)
+			}
+		})
+	}
+}
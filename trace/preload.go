@@ -0,0 +1,114 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package trace
+
+import (
+	"cmp"
+	"fmt"
+	"html"
+	"maps"
+	"slices"
+	"strings"
+
+	"bennypowers.dev/mappa/importmap"
+)
+
+// SpecifierDepths returns the dependency depth of each bare specifier in the
+// graph: the fewest modules between the page and an import of the specifier.
+// Specifiers imported by the page's inline scripts have depth 1, those
+// imported by its entrypoint modules depth 2, and so on.
+func (g *ModuleGraph) SpecifierDepths() map[string]int {
+	moduleDepths := map[string]int{"": 0}
+	queue := []string{""}
+	for _, entry := range g.Entrypoints {
+		if _, seen := moduleDepths[entry]; !seen {
+			moduleDepths[entry] = 1
+			queue = append(queue, entry)
+		}
+	}
+
+	depths := make(map[string]int)
+	for len(queue) > 0 {
+		from := queue[0]
+		queue = queue[1:]
+		depth := moduleDepths[from]
+		for _, spec := range g.bareImports[from] {
+			if _, seen := depths[spec]; !seen {
+				depths[spec] = depth + 1
+			}
+		}
+		for _, to := range g.edges[from] {
+			if _, seen := moduleDepths[to]; !seen {
+				moduleDepths[to] = depth + 1
+				queue = append(queue, to)
+			}
+		}
+	}
+	return depths
+}
+
+// PreloadURLs returns the module URLs in im, for <link rel="modulepreload">
+// hints, ordered by the dependency depth at which graph imports each
+// specifier so the browser fetches the modules the page needs first.
+// Entries for specifiers the graph never imports are skipped, as are
+// trailing-slash prefix entries and inlined data: URLs. With a nil graph,
+// every module URL is returned in lexical order.
+func PreloadURLs(im *importmap.ImportMap, graph *ModuleGraph) []string {
+	if im == nil {
+		return nil
+	}
+
+	var specDepths map[string]int
+	if graph != nil {
+		specDepths = graph.SpecifierDepths()
+	}
+
+	urlDepths := make(map[string]int)
+	add := func(specifiers map[string]string) {
+		for spec, url := range specifiers {
+			if strings.HasSuffix(spec, "/") || strings.HasPrefix(url, "data:") {
+				continue
+			}
+			depth, ok := specDepths[spec]
+			if !ok && graph != nil {
+				continue
+			}
+			if current, seen := urlDepths[url]; !seen || depth < current {
+				urlDepths[url] = depth
+			}
+		}
+	}
+	add(im.Imports)
+	for _, scope := range im.Scopes {
+		add(scope)
+	}
+
+	return slices.SortedFunc(maps.Keys(urlDepths), func(a, b string) int {
+		return cmp.Or(cmp.Compare(urlDepths[a], urlDepths[b]), strings.Compare(a, b))
+	})
+}
+
+// PreloadLink formats a <link rel="modulepreload"> tag for url, with a
+// crossorigin attribute when crossOrigin is set, such as a page's
+// ModuleGraph.CrossOrigin.
+func PreloadLink(url, crossOrigin string) string {
+	if crossOrigin != "" {
+		return fmt.Sprintf(`<link rel="modulepreload" href="%s" crossorigin="%s">`, html.EscapeString(url), html.EscapeString(crossOrigin))
+	}
+	return fmt.Sprintf(`<link rel="modulepreload" href="%s">`, html.EscapeString(url))
+}
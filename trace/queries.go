@@ -136,16 +136,27 @@ func GetQueryManager() (*QueryManager, error) {
 
 // ScriptTag represents a <script> tag found in HTML.
 type ScriptTag struct {
-	Type    string   // The type attribute (e.g., "module")
-	Src     string   // The src attribute (external script)
-	Inline  bool     // True if script has inline content
-	Content string   // The inline script content
-	Imports []string // Import specifiers found in inline content
+	Type          string         // The type attribute (e.g., "module")
+	Src           string         // The src attribute (external script)
+	Inline        bool           // True if script has inline content
+	Content       string         // The inline script content
+	Line          int            // 1-indexed line of the page on which the inline content starts
+	Imports       []string       // Import specifiers found in inline content, except computed ones
+	ModuleImports []ModuleImport // Imports found in inline content, with dynamic info and page line numbers
+	InTemplate    bool           // True if the script is inside a <template> element
+	NoModule      bool           // True if the script has a nomodule attribute, marking a classic script as a legacy fallback
+	CrossOrigin   string         // The crossorigin CORS setting, "anonymous" or "use-credentials"; empty if absent
 }
 
 // ModuleImport represents an import statement in a module.
 type ModuleImport struct {
 	Specifier string // The import specifier (e.g., "lit", "./foo.js")
-	IsDynamic bool   // True if this is a dynamic import()
+	IsDynamic bool   // True if this is a dynamic import() or a module URL
+	IsURL     bool   // True if referenced by new URL(spec, import.meta.url), e.g. a worker script
 	Line      int    // 1-indexed line number of the specifier
+	Type      string // The type import attribute (e.g., "json", "css"), if any
+
+	// Unresolvable is true for a dynamic import() of a computed specifier,
+	// e.g. import(`./locales/${lang}.js`), whose Specifier is the source text
+	Unresolvable bool
 }
@@ -6,22 +6,39 @@
   source: (string
     (string_fragment) @import.spec)) @import
 
-; Dynamic imports: import('path')
+; Dynamic imports: import('path'), including await import('path')
+; and import('path', { with: { type: 'json' } })
 (call_expression
   function: (import)
   arguments: (arguments
+    .
     (string
       (string_fragment) @dynamicImport.spec))) @dynamicImport
 
-; Async dynamic imports: await import('path')
-(await_expression
-  (call_expression
-    function: (import)
-    arguments: (arguments
-      (string
-        (string_fragment) @dynamicImport.spec)))) @dynamicImport
+; Dynamic imports of computed specifiers: import(`./locales/${lang}.js`),
+; import(name). String arguments are also captured here and skipped.
+(call_expression
+  function: (import)
+  arguments: (arguments
+    .
+    (_) @dynamicImport.expr))
 
 ; Re-exports: export { foo } from 'bar';
 (export_statement
   source: (string
     (string_fragment) @reexport.spec)) @reexport
+
+; Module URLs: new URL('./worker.js', import.meta.url), as passed to
+; new Worker() or new SharedWorker()
+(new_expression
+  constructor: (identifier) @_ctor
+  arguments: (arguments
+    .
+    (string
+      (string_fragment) @url.spec)
+    .
+    (member_expression
+      object: (meta_property)
+      property: (property_identifier) @_prop))
+  (#eq? @_ctor "URL")
+  (#eq? @_prop "url")) @url
@@ -0,0 +1,82 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package trace
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Timings accumulates the time spent in each phase of a trace. Durations are
+// summed across parallel workers, so in batch mode they can exceed wall time.
+// A nil *Timings records nothing.
+type Timings struct {
+	htmlParse        atomic.Int64
+	importExtraction atomic.Int64
+	resolution       atomic.Int64
+}
+
+// HTMLParse returns the time spent reading and parsing HTML files, including
+// extracting the imports of inline module scripts.
+func (t *Timings) HTMLParse() time.Duration {
+	return time.Duration(t.htmlParse.Load())
+}
+
+// ImportExtraction returns the time spent reading module files and extracting
+// their imports.
+func (t *Timings) ImportExtraction() time.Duration {
+	return time.Duration(t.importExtraction.Load())
+}
+
+// Resolution returns the time spent resolving traced specifiers into import maps.
+func (t *Timings) Resolution() time.Duration {
+	return time.Duration(t.resolution.Load())
+}
+
+// Summary returns a readable per-phase breakdown alongside the given wall time.
+func (t *Timings) Summary(wall time.Duration) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "html parse:        %v\n", t.HTMLParse().Round(time.Microsecond))
+	fmt.Fprintf(&b, "import extraction: %v\n", t.ImportExtraction().Round(time.Microsecond))
+	fmt.Fprintf(&b, "resolution:        %v\n", t.Resolution().Round(time.Microsecond))
+	fmt.Fprintf(&b, "wall:              %v\n", wall.Round(time.Microsecond))
+	return b.String()
+}
+
+// addHTMLParse records time spent parsing an HTML file since start.
+func (t *Timings) addHTMLParse(start time.Time) {
+	if t != nil {
+		t.htmlParse.Add(int64(time.Since(start)))
+	}
+}
+
+// addImportExtraction records time spent extracting a module's imports since start.
+func (t *Timings) addImportExtraction(start time.Time) {
+	if t != nil {
+		t.importExtraction.Add(int64(time.Since(start)))
+	}
+}
+
+// addResolution records time spent resolving an import map since start.
+func (t *Timings) addResolution(start time.Time) {
+	if t != nil {
+		t.resolution.Add(int64(time.Since(start)))
+	}
+}
@@ -18,11 +18,15 @@ package trace
 
 import (
 	"encoding/json"
+	"maps"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"testing"
 
+	"bennypowers.dev/mappa/importmap"
+	"bennypowers.dev/mappa/resolve"
 	"bennypowers.dev/mappa/testutil"
 )
 
@@ -46,10 +50,13 @@ func TestExtractScripts(t *testing.T) {
 
 	var expected struct {
 		Scripts []struct {
-			Type    string   `json:"type"`
-			Src     string   `json:"src"`
-			Inline  bool     `json:"inline"`
-			Imports []string `json:"imports"`
+			Type        string   `json:"type"`
+			Src         string   `json:"src"`
+			Inline      bool     `json:"inline"`
+			CrossOrigin string   `json:"crossorigin"`
+			Line        int      `json:"line"`
+			NoModule    bool     `json:"nomodule"`
+			Imports     []string `json:"imports"`
 		} `json:"scripts"`
 	}
 	if err := json.Unmarshal(expectedBytes, &expected); err != nil {
@@ -70,6 +77,15 @@ func TestExtractScripts(t *testing.T) {
 		if scripts[i].Inline != exp.Inline {
 			t.Errorf("Script %d: expected Inline=%v, got %v", i, exp.Inline, scripts[i].Inline)
 		}
+		if scripts[i].CrossOrigin != exp.CrossOrigin {
+			t.Errorf("Script %d: expected CrossOrigin=%q, got %q", i, exp.CrossOrigin, scripts[i].CrossOrigin)
+		}
+		if scripts[i].Line != exp.Line {
+			t.Errorf("Script %d: expected Line=%d, got %d", i, exp.Line, scripts[i].Line)
+		}
+		if scripts[i].NoModule != exp.NoModule {
+			t.Errorf("Script %d: expected NoModule=%v, got %v", i, exp.NoModule, scripts[i].NoModule)
+		}
 		if len(scripts[i].Imports) != len(exp.Imports) {
 			t.Errorf("Script %d: expected %d imports, got %d", i, len(exp.Imports), len(scripts[i].Imports))
 		} else {
@@ -164,6 +180,86 @@ func TestExtractImports_ReExports(t *testing.T) {
 	}
 }
 
+// checkExtractFixture extracts imports from a fixture module and compares them,
+// in order, with the fixture's expected.json.
+func checkExtractFixture(t *testing.T, fixtureDir, module string) {
+	t.Helper()
+	mfs := testutil.NewFixtureFS(t, fixtureDir, "/test")
+	js, err := mfs.ReadFile("/test/" + module)
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	imports, err := ExtractImports(js)
+	if err != nil {
+		t.Fatalf("ExtractImports failed: %v", err)
+	}
+
+	expectedBytes, err := mfs.ReadFile("/test/expected.json")
+	if err != nil {
+		t.Fatalf("Failed to read expected.json: %v", err)
+	}
+
+	var expected struct {
+		Imports []struct {
+			Specifier string `json:"specifier"`
+			Dynamic   bool   `json:"dynamic"`
+			Type      string `json:"type"`
+			Line      int    `json:"line"`
+		} `json:"imports"`
+	}
+	if err := json.Unmarshal(expectedBytes, &expected); err != nil {
+		t.Fatalf("Failed to parse expected.json: %v", err)
+	}
+
+	if len(imports) != len(expected.Imports) {
+		t.Fatalf("Expected %d imports, got %d: %+v", len(expected.Imports), len(imports), imports)
+	}
+
+	for i, exp := range expected.Imports {
+		got := imports[i]
+		if got.Specifier != exp.Specifier || got.IsDynamic != exp.Dynamic || got.Type != exp.Type || got.Line != exp.Line {
+			t.Errorf("Import %d: expected %+v, got %+v", i, exp, got)
+		}
+	}
+}
+
+func TestExtractImports_Attributes(t *testing.T) {
+	checkExtractFixture(t, "trace/extract-attributes", "module.js")
+}
+
+func TestExtractImports_ImportMeta(t *testing.T) {
+	checkExtractFixture(t, "trace/extract-import-meta", "module.js")
+}
+
+func TestExtractImports_WorkerURLs(t *testing.T) {
+	checkExtractFixture(t, "trace/extract-worker-urls", "module.js")
+}
+
+func TestExtractCSSImports(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "trace/extract-css-imports", "/test")
+	css, err := mfs.ReadFile("/test/styles.css")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	expectedBytes, err := mfs.ReadFile("/test/expected.json")
+	if err != nil {
+		t.Fatalf("Failed to read expected.json: %v", err)
+	}
+	var expected struct {
+		Imports []string `json:"imports"`
+	}
+	if err := json.Unmarshal(expectedBytes, &expected); err != nil {
+		t.Fatalf("Failed to parse expected.json: %v", err)
+	}
+
+	got := ExtractCSSImports(css)
+	if strings.Join(got, ",") != strings.Join(expected.Imports, ",") {
+		t.Errorf("Expected %v, got %v", expected.Imports, got)
+	}
+}
+
 func TestExtractImports_TypeScript(t *testing.T) {
 	mfs := testutil.NewFixtureFS(t, "trace/extract-typescript", "/test")
 	ts, err := mfs.ReadFile("/test/module.ts")
@@ -257,6 +353,250 @@ func TestTraceHTML(t *testing.T) {
 	}
 }
 
+func TestTraceHTMLStaticOnly(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "trace/static-only", "/test")
+
+	expectedBytes, err := mfs.ReadFile("/test/expected.json")
+	if err != nil {
+		t.Fatalf("Failed to read expected.json: %v", err)
+	}
+
+	type graphExpectation struct {
+		Modules           []string `json:"modules"`
+		BareSpecifiers    []string `json:"bare_specifiers"`
+		DynamicSpecifiers []string `json:"dynamic_specifiers"`
+	}
+	var expected struct {
+		All        graphExpectation `json:"all"`
+		StaticOnly graphExpectation `json:"static_only"`
+	}
+	if err := json.Unmarshal(expectedBytes, &expected); err != nil {
+		t.Fatalf("Failed to parse expected.json: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		tracer   *Tracer
+		expected graphExpectation
+	}{
+		{"all imports", NewTracer(mfs, "/test"), expected.All},
+		{"static only", NewTracer(mfs, "/test").WithStaticOnly(), expected.StaticOnly},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			graph, err := tt.tracer.TraceHTML("/test/index.html")
+			if err != nil {
+				t.Fatalf("TraceHTML failed: %v", err)
+			}
+
+			var modules []string
+			for p := range graph.Modules {
+				modules = append(modules, strings.TrimPrefix(p, "/test/"))
+			}
+			sort.Strings(modules)
+			sort.Strings(tt.expected.Modules)
+			if strings.Join(modules, ",") != strings.Join(tt.expected.Modules, ",") {
+				t.Errorf("Modules: expected %v, got %v", tt.expected.Modules, modules)
+			}
+
+			if got := graph.BareSpecifiers(); strings.Join(got, ",") != strings.Join(tt.expected.BareSpecifiers, ",") {
+				t.Errorf("BareSpecifiers: expected %v, got %v", tt.expected.BareSpecifiers, got)
+			}
+
+			if got := graph.DynamicSpecifiers(); strings.Join(got, ",") != strings.Join(tt.expected.DynamicSpecifiers, ",") {
+				t.Errorf("DynamicSpecifiers: expected %v, got %v", tt.expected.DynamicSpecifiers, got)
+			}
+		})
+	}
+}
+
+// TestTraceHTMLFollowsNonImportDependencies traces fixtures whose modules
+// depend on files other than through import declarations: worker scripts
+// and stylesheets' @import rules.
+func TestTraceHTMLFollowsNonImportDependencies(t *testing.T) {
+	for _, fixture := range []string{"workers", "css-imports"} {
+		t.Run(fixture, func(t *testing.T) {
+			mfs := testutil.NewFixtureFS(t, "trace/"+fixture, "/test")
+
+			expectedBytes, err := mfs.ReadFile("/test/expected.json")
+			if err != nil {
+				t.Fatalf("Failed to read expected.json: %v", err)
+			}
+
+			var expected struct {
+				Modules        []string `json:"modules"`
+				BareSpecifiers []string `json:"bare_specifiers"`
+			}
+			if err := json.Unmarshal(expectedBytes, &expected); err != nil {
+				t.Fatalf("Failed to parse expected.json: %v", err)
+			}
+
+			graph, err := NewTracer(mfs, "/test").TraceHTML("/test/index.html")
+			if err != nil {
+				t.Fatalf("TraceHTML failed: %v", err)
+			}
+			if len(graph.Errors) > 0 {
+				t.Fatalf("Unexpected trace errors: %v", graph.Errors)
+			}
+
+			var modules []string
+			for p := range graph.Modules {
+				modules = append(modules, strings.TrimPrefix(p, "/test/"))
+			}
+			sort.Strings(modules)
+			if strings.Join(modules, ",") != strings.Join(expected.Modules, ",") {
+				t.Errorf("Modules: expected %v, got %v", expected.Modules, modules)
+			}
+
+			if got := graph.BareSpecifiers(); strings.Join(got, ",") != strings.Join(expected.BareSpecifiers, ",") {
+				t.Errorf("BareSpecifiers: expected %v, got %v", expected.BareSpecifiers, got)
+			}
+		})
+	}
+}
+
+func TestTraceHTMLSkipTemplates(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "trace/skip-templates", "/test")
+
+	expectedBytes, err := mfs.ReadFile("/test/expected.json")
+	if err != nil {
+		t.Fatalf("Failed to read expected.json: %v", err)
+	}
+
+	type graphExpectation struct {
+		Modules        []string `json:"modules"`
+		BareSpecifiers []string `json:"bare_specifiers"`
+	}
+	var expected struct {
+		All           graphExpectation `json:"all"`
+		SkipTemplates graphExpectation `json:"skip_templates"`
+	}
+	if err := json.Unmarshal(expectedBytes, &expected); err != nil {
+		t.Fatalf("Failed to parse expected.json: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		tracer   *Tracer
+		expected graphExpectation
+	}{
+		{"template scripts included", NewTracer(mfs, "/test"), expected.All},
+		{"skip templates", NewTracer(mfs, "/test").WithSkipTemplates(), expected.SkipTemplates},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			graph, err := tt.tracer.TraceHTML("/test/index.html")
+			if err != nil {
+				t.Fatalf("TraceHTML failed: %v", err)
+			}
+
+			var modules []string
+			for p := range graph.Modules {
+				modules = append(modules, strings.TrimPrefix(p, "/test/"))
+			}
+			sort.Strings(modules)
+			sort.Strings(tt.expected.Modules)
+			if strings.Join(modules, ",") != strings.Join(tt.expected.Modules, ",") {
+				t.Errorf("Modules: expected %v, got %v", tt.expected.Modules, modules)
+			}
+
+			if got := graph.BareSpecifiers(); strings.Join(got, ",") != strings.Join(tt.expected.BareSpecifiers, ",") {
+				t.Errorf("BareSpecifiers: expected %v, got %v", tt.expected.BareSpecifiers, got)
+			}
+		})
+	}
+}
+
+func TestPreloadURLs(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "trace/preload", "/test")
+
+	expectedBytes, err := mfs.ReadFile("/test/expected.json")
+	if err != nil {
+		t.Fatalf("Failed to read expected.json: %v", err)
+	}
+	var expected struct {
+		Depths  map[string]int `json:"depths"`
+		Preload []string       `json:"preload"`
+	}
+	if err := json.Unmarshal(expectedBytes, &expected); err != nil {
+		t.Fatalf("Failed to parse expected.json: %v", err)
+	}
+
+	graph, err := NewTracer(mfs, "/test").WithNodeModules("/test/node_modules").TraceHTML("/test/index.html")
+	if err != nil {
+		t.Fatalf("TraceHTML failed: %v", err)
+	}
+
+	if depths := graph.SpecifierDepths(); !maps.Equal(depths, expected.Depths) {
+		t.Errorf("SpecifierDepths() = %v, want %v", depths, expected.Depths)
+	}
+
+	im := &importmap.ImportMap{
+		Imports: map[string]string{
+			"@acme/core": "/node_modules/@acme/core/index.js",
+			"alpha":      "/node_modules/alpha/index.js",
+			"zed":        "/node_modules/zed/index.js",
+			"zed/":       "/node_modules/zed/",
+			"unused":     "/node_modules/unused/index.js",
+		},
+	}
+	if got := PreloadURLs(im, graph); !slices.Equal(got, expected.Preload) {
+		t.Errorf("PreloadURLs() = %v, want %v", got, expected.Preload)
+	}
+}
+
+func TestFindModulePreloads(t *testing.T) {
+	content := []byte(`<head>
+  <link rel="stylesheet" href="/style.css">
+  <link rel="modulepreload" href="/a.js">
+  <link rel=modulepreload href="/b.js" />
+</head>`)
+
+	if got, want := FindModulePreloads(content), []string{"/a.js", "/b.js"}; !slices.Equal(got, want) {
+		t.Errorf("FindModulePreloads() = %v, want %v", got, want)
+	}
+}
+
+func TestTraceHTMLExternalImports(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "trace/external-imports", "/test")
+
+	tracer := NewTracer(mfs, "/test")
+	graph, err := tracer.TraceHTML("/test/index.html")
+	if err != nil {
+		t.Fatalf("TraceHTML failed: %v", err)
+	}
+
+	expectedBytes, err := mfs.ReadFile("/test/expected.json")
+	if err != nil {
+		t.Fatalf("Failed to read expected.json: %v", err)
+	}
+
+	var expected struct {
+		BareSpecifiers  []string `json:"bare_specifiers"`
+		ExternalImports []string `json:"external_imports"`
+	}
+	if err := json.Unmarshal(expectedBytes, &expected); err != nil {
+		t.Fatalf("Failed to parse expected.json: %v", err)
+	}
+
+	external := graph.ExternalImports
+	sort.Strings(external)
+	if strings.Join(external, ",") != strings.Join(expected.ExternalImports, ",") {
+		t.Errorf("ExternalImports: expected %v, got %v", expected.ExternalImports, external)
+	}
+
+	if got := graph.BareSpecifiers(); strings.Join(got, ",") != strings.Join(expected.BareSpecifiers, ",") {
+		t.Errorf("BareSpecifiers: expected %v, got %v", expected.BareSpecifiers, got)
+	}
+
+	// URL imports are recorded, not traced
+	if len(graph.Errors) > 0 {
+		t.Errorf("Expected no tracing errors, got: %v", graph.Errors)
+	}
+}
+
 func TestPackageNames(t *testing.T) {
 	graph := &ModuleGraph{
 		bareSpecifiers: map[string]bool{
@@ -300,6 +640,9 @@ func TestIsBareSpecifier(t *testing.T) {
 		{"../parent.js", false},
 		{"/absolute.js", false},
 		{"https://example.com/module.js", false},
+		{"data:text/javascript,export default 1", false},
+		{"blob:https://example.com/1b4e28ba", false},
+		{"node:fs", false},
 		{"", false},
 	}
 
@@ -450,6 +793,181 @@ func TestFindImportMapTag_Empty(t *testing.T) {
 	}
 }
 
+func TestFindImportMapTags(t *testing.T) {
+	html := []byte(`<!DOCTYPE html>
+<html>
+<head>
+  <script type="importmap">{"imports": {"a": "/a.js"}}</script>
+  <script type="importmap-shim">{"imports": {"b": "/b.js"}}</script>
+  <script type="module">import 'a';</script>
+</head>
+<body>
+  <script type="importmap"></script>
+  <script type="importmap">
+{"imports": {"c": "/c.js"}}
+  </script>
+</body>
+</html>`)
+
+	locs := FindImportMapTags(html)
+	if len(locs) != 3 {
+		t.Fatalf("Expected 3 import map tags, got %d", len(locs))
+	}
+	for i, want := range []struct {
+		line    int
+		content string
+	}{
+		{4, `{"imports": {"a": "/a.js"}}`},
+		{9, ""},
+		{10, "\n{\"imports\": {\"c\": \"/c.js\"}}\n  "},
+	} {
+		loc := locs[i]
+		if loc.Line != want.line {
+			t.Errorf("Tag %d: expected line %d, got %d", i, want.line, loc.Line)
+		}
+		if got := string(html[loc.ContentStart:loc.ContentEnd]); got != want.content {
+			t.Errorf("Tag %d: expected content %q, got %q", i, want.content, got)
+		}
+		if got := string(html[loc.TagEnd-len("</script>") : loc.TagEnd]); got != "</script>" {
+			t.Errorf("Tag %d: expected TagEnd after </script>, got %q", i, got)
+		}
+	}
+
+	if first := FindImportMapTag(html); first != locs[0] {
+		t.Errorf("FindImportMapTag = %+v, want the first of FindImportMapTags %+v", first, locs[0])
+	}
+
+	shimLocs := FindShimImportMapTags(html)
+	if len(shimLocs) != 4 || shimLocs[1].Type != ImportMapShimType || shimLocs[1].Line != 5 {
+		t.Errorf("Expected 4 tags with the importmap-shim tag second, got %+v", shimLocs)
+	}
+
+	if locs := FindImportMapTags([]byte(`<html><head></head></html>`)); len(locs) != 0 {
+		t.Errorf("Expected no tags, got %+v", locs)
+	}
+}
+
+func TestTraceNohoist(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "trace/nohoist", "/test")
+
+	appDir := "/test/packages/app"
+	tracer := NewTracer(mfs, appDir).WithNodeModulesPaths(resolve.NodeModulesPaths(mfs, appDir))
+
+	for specifier, expected := range map[string]string{
+		"@example/widget": "/test/packages/app/node_modules/@example/widget/widget.js",
+		"lit":             "/test/node_modules/lit/index.js",
+	} {
+		got, err := tracer.resolveBareSpecifier(specifier)
+		if err != nil || got != expected {
+			t.Errorf("resolveBareSpecifier(%q) = %q, %v; want %q", specifier, got, err, expected)
+		}
+	}
+
+	graph, err := tracer.TraceHTML(filepath.Join(appDir, "index.html"))
+	if err != nil {
+		t.Fatalf("TraceHTML failed: %v", err)
+	}
+
+	// The nohoisted widget resolves from the app's own node_modules, and the
+	// widget's hoisted lit dependency from the workspace root
+	for _, path := range []string{
+		"/test/packages/app/node_modules/@example/widget/widget.js",
+		"/test/node_modules/lit/index.js",
+	} {
+		if _, ok := graph.Modules[path]; !ok {
+			t.Errorf("Expected %s in traced modules, got %v", path, slices.Sorted(maps.Keys(graph.Modules)))
+		}
+	}
+	if _, ok := graph.Modules["/test/node_modules/@example/widget/old-widget.js"]; ok {
+		t.Error("Root node_modules should not shadow the app's own node_modules")
+	}
+}
+
+func TestTraceTSConfigPaths(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "trace/tsconfig-paths", "/test")
+
+	tracer := NewTracer(mfs, "/test").
+		WithNodeModules("/test/node_modules").
+		WithTSConfigPaths(".", map[string][]string{"@app/*": {"src/*"}})
+
+	graph, err := tracer.TraceHTML("/test/index.html")
+	if err != nil {
+		t.Fatalf("TraceHTML failed: %v", err)
+	}
+	if len(graph.Errors) > 0 {
+		t.Errorf("Expected no trace errors, got %v", graph.Errors)
+	}
+
+	// Aliases are followed transitively, through to their own bare imports
+	for _, path := range []string{
+		"/test/src/foo.js",
+		"/test/src/components/button.js",
+		"/test/src/utils/format.js",
+		"/test/node_modules/lit/index.js",
+	} {
+		if _, ok := graph.Modules[path]; !ok {
+			t.Errorf("Expected %s in traced modules, got %v", path, slices.Sorted(maps.Keys(graph.Modules)))
+		}
+	}
+
+	expected := []string{"@app/components/button.js", "@app/foo", "@app/utils/format", "lit"}
+	if got := graph.BareSpecifiers(); !slices.Equal(got, expected) {
+		t.Errorf("BareSpecifiers() = %v, want %v", got, expected)
+	}
+}
+
+func TestFindShimImportMapTag(t *testing.T) {
+	html := []byte(`<!DOCTYPE html>
+<html>
+<head>
+  <script type="importmap-shim">{"imports": {}}</script>
+</head>
+</html>`)
+
+	if loc := FindImportMapTag(html); loc.Found {
+		t.Errorf("FindImportMapTag should not match importmap-shim tags")
+	}
+
+	loc := FindShimImportMapTag(html)
+	if !loc.Found {
+		t.Fatalf("Expected to find importmap-shim tag")
+	}
+	if loc.Type != ImportMapShimType {
+		t.Errorf("Type: expected %q, got %q", ImportMapShimType, loc.Type)
+	}
+	if got := string(html[loc.ContentStart:loc.ContentEnd]); got != `{"imports": {}}` {
+		t.Errorf("Unexpected content: %q", got)
+	}
+
+	// Native import maps are still found in shim mode
+	native := FindShimImportMapTag([]byte(`<head><script type="importmap">{}</script></head>`))
+	if !native.Found || native.Type != "importmap" {
+		t.Errorf("Expected native importmap in shim mode, got %+v", native)
+	}
+}
+
+func TestExtractScriptsModuleShim(t *testing.T) {
+	html := []byte(`<!DOCTYPE html>
+<html>
+<head>
+  <script type="module-shim">
+    import { LitElement } from 'lit';
+  </script>
+</head>
+</html>`)
+
+	scripts, err := ExtractScripts(html)
+	if err != nil {
+		t.Fatalf("ExtractScripts failed: %v", err)
+	}
+	if len(scripts) != 1 {
+		t.Fatalf("Expected 1 script, got %d", len(scripts))
+	}
+	if !slices.Equal(scripts[0].Imports, []string{"lit"}) {
+		t.Errorf("module-shim static imports should be extracted, got %v", scripts[0].Imports)
+	}
+}
+
 func TestFindInsertPoint(t *testing.T) {
 	mfs := testutil.NewFixtureFS(t, "trace/find-insertpoint", "/test")
 	html, err := mfs.ReadFile("/test/index.html")
@@ -514,6 +1032,34 @@ func TestFindInsertPoint_NoScripts(t *testing.T) {
 	}
 }
 
+func TestFindMarker(t *testing.T) {
+	html := []byte(`<!DOCTYPE html>
+<html>
+<head>
+  <!-- preconnect -->
+  <!-- importmap -->
+  <script type="module" src="./app.js"></script>
+</head>
+</html>`)
+
+	for _, marker := range []string{"<!-- importmap -->", "importmap"} {
+		pt := FindMarker(html, marker)
+		if !pt.Found {
+			t.Fatalf("Expected to find marker %q", marker)
+		}
+		if got := string(html[pt.Offset : pt.Offset+pt.Length]); got != "<!-- importmap -->" {
+			t.Errorf("Marker %q: expected the comment to be replaced, got %q", marker, got)
+		}
+		if pt.Indent != "  " {
+			t.Errorf("Marker %q: expected indent %q, got %q", marker, "  ", pt.Indent)
+		}
+	}
+
+	if pt := FindMarker(html, "<!-- missing -->"); pt.Found {
+		t.Errorf("Expected Found=false for a missing marker")
+	}
+}
+
 func TestFindInsertPoint_NoHead(t *testing.T) {
 	html := []byte(`<!DOCTYPE html>
 <html>
@@ -525,3 +1071,123 @@ func TestFindInsertPoint_NoHead(t *testing.T) {
 		t.Errorf("Expected Found=false for HTML without head")
 	}
 }
+
+func TestExtractImports_ComputedSpecifiers(t *testing.T) {
+	imports, err := ExtractImports([]byte("const lang = 'en';\n" +
+		"await import(`./locales/${lang}.js`);\n" +
+		"await import(`./plain.js`);\n" +
+		"await import('./quoted.js');\n" +
+		"await import(name);\n"))
+	if err != nil {
+		t.Fatalf("ExtractImports failed: %v", err)
+	}
+
+	expected := []ModuleImport{
+		{Specifier: "`./locales/${lang}.js`", IsDynamic: true, Line: 2, Unresolvable: true},
+		{Specifier: "./plain.js", IsDynamic: true, Line: 3},
+		{Specifier: "./quoted.js", IsDynamic: true, Line: 4},
+		{Specifier: "name", IsDynamic: true, Line: 5, Unresolvable: true},
+	}
+	if !slices.Equal(imports, expected) {
+		t.Errorf("ExtractImports mismatch:\n  got:      %+v\n  expected: %+v", imports, expected)
+	}
+}
+
+func TestTraceHTMLComputedImports(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "trace/computed-imports", "/test")
+
+	expectedBytes, err := mfs.ReadFile("/test/expected.json")
+	if err != nil {
+		t.Fatalf("Failed to read expected.json: %v", err)
+	}
+
+	var expected struct {
+		Modules             []string `json:"modules"`
+		UnresolvableImports []struct {
+			Module     string `json:"module"`
+			Line       int    `json:"line"`
+			Expression string `json:"expression"`
+		} `json:"unresolvable_imports"`
+	}
+	if err := json.Unmarshal(expectedBytes, &expected); err != nil {
+		t.Fatalf("Failed to parse expected.json: %v", err)
+	}
+
+	graph, err := NewTracer(mfs, "/test").TraceHTML("/test/index.html")
+	if err != nil {
+		t.Fatalf("TraceHTML failed: %v", err)
+	}
+	if len(graph.Errors) > 0 {
+		t.Fatalf("Unexpected trace errors: %v", graph.Errors)
+	}
+
+	var modules []string
+	for p := range graph.Modules {
+		modules = append(modules, strings.TrimPrefix(p, "/test/"))
+	}
+	sort.Strings(modules)
+	if !slices.Equal(modules, expected.Modules) {
+		t.Errorf("Modules: expected %v, got %v", expected.Modules, modules)
+	}
+
+	if len(graph.UnresolvableImports) != len(expected.UnresolvableImports) {
+		t.Fatalf("Expected %d unresolvable imports, got %+v", len(expected.UnresolvableImports), graph.UnresolvableImports)
+	}
+	for i, want := range expected.UnresolvableImports {
+		got := graph.UnresolvableImports[i]
+		if strings.TrimPrefix(got.Module, "/test/") != want.Module || got.Line != want.Line || got.Expression != want.Expression {
+			t.Errorf("UnresolvableImports[%d] = %+v, want %+v", i, got, want)
+		}
+	}
+
+	// Computed specifiers are dropped when only static imports are traced
+	graph, err = NewTracer(mfs, "/test").WithStaticOnly().TraceHTML("/test/index.html")
+	if err != nil {
+		t.Fatalf("TraceHTML failed: %v", err)
+	}
+	if len(graph.UnresolvableImports) != 0 {
+		t.Errorf("Expected no unresolvable imports with static-only, got %+v", graph.UnresolvableImports)
+	}
+}
+
+func TestTraceHTMLNoModule(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "trace/nomodule", "/test")
+
+	expectedBytes, err := mfs.ReadFile("/test/expected.json")
+	if err != nil {
+		t.Fatalf("Failed to read expected.json: %v", err)
+	}
+
+	var expected struct {
+		Entrypoints []string `json:"entrypoints"`
+		CrossOrigin string   `json:"crossorigin"`
+	}
+	if err := json.Unmarshal(expectedBytes, &expected); err != nil {
+		t.Fatalf("Failed to parse expected.json: %v", err)
+	}
+
+	graph, err := NewTracer(mfs, "/test").TraceHTML("/test/index.html")
+	if err != nil {
+		t.Fatalf("TraceHTML failed: %v", err)
+	}
+	if len(graph.Errors) > 0 {
+		t.Fatalf("Unexpected trace errors: %v", graph.Errors)
+	}
+
+	// Classic nomodule scripts are legacy fallbacks, never traced, while
+	// browsers ignore nomodule on module scripts
+	var entrypoints []string
+	for _, entry := range graph.Entrypoints {
+		entrypoints = append(entrypoints, strings.TrimPrefix(entry, "/test/"))
+	}
+	if !slices.Equal(entrypoints, expected.Entrypoints) {
+		t.Errorf("Entrypoints: expected %v, got %v", expected.Entrypoints, entrypoints)
+	}
+	if specs := graph.BareSpecifiers(); !slices.Equal(specs, []string{"alpha", "legacy-polyfills"}) {
+		t.Errorf("BareSpecifiers: expected [alpha legacy-polyfills], got %v", specs)
+	}
+
+	if graph.CrossOrigin != expected.CrossOrigin {
+		t.Errorf("CrossOrigin: expected %q, got %q", expected.CrossOrigin, graph.CrossOrigin)
+	}
+}
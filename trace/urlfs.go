@@ -0,0 +1,187 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package trace
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	iofs "io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"sync"
+	"time"
+
+	"bennypowers.dev/mappa/cdn"
+)
+
+// errReadOnly is returned by write operations on a URLFileSystem.
+var errReadOnly = errors.New("remote filesystem is read-only")
+
+// URLFileSystem is a read-only fs.FileSystem that maps absolute paths onto
+// URLs on a single origin, so the tracer can follow a deployed page's module
+// graph as if it were on disk. Paths such as "/js/app.js" are fetched from
+// origin + path. Fetched content is cached for the lifetime of the filesystem.
+type URLFileSystem struct {
+	ctx     context.Context
+	fetcher cdn.Fetcher
+	origin  string
+
+	mu    sync.Mutex
+	cache map[string][]byte
+}
+
+// NewURLFileSystem creates a URLFileSystem serving paths from origin
+// (e.g., "https://example.com") using fetcher.
+func NewURLFileSystem(ctx context.Context, fetcher cdn.Fetcher, origin string) *URLFileSystem {
+	return &URLFileSystem{
+		ctx:     ctx,
+		fetcher: fetcher,
+		origin:  origin,
+		cache:   make(map[string][]byte),
+	}
+}
+
+// URL returns the URL a path is fetched from.
+func (u *URLFileSystem) URL(name string) string {
+	return u.origin + name
+}
+
+// ReadFile fetches the URL for name. HTTP 404 responses are reported as
+// fs.ErrNotExist so that callers can treat them like missing files.
+func (u *URLFileSystem) ReadFile(name string) ([]byte, error) {
+	u.mu.Lock()
+	data, ok := u.cache[name]
+	u.mu.Unlock()
+	if ok {
+		return data, nil
+	}
+
+	data, err := u.fetcher.Fetch(u.ctx, u.URL(name))
+	if err != nil {
+		var fetchErr *cdn.FetchError
+		if errors.As(err, &fetchErr) && fetchErr.StatusCode == http.StatusNotFound {
+			return nil, &iofs.PathError{Op: "read", Path: name, Err: iofs.ErrNotExist}
+		}
+		return nil, err
+	}
+
+	u.mu.Lock()
+	u.cache[name] = data
+	u.mu.Unlock()
+	return data, nil
+}
+
+// WriteFile always fails; the remote filesystem is read-only.
+func (u *URLFileSystem) WriteFile(name string, data []byte, perm iofs.FileMode) error {
+	return &iofs.PathError{Op: "write", Path: name, Err: errReadOnly}
+}
+
+// Remove always fails; the remote filesystem is read-only.
+func (u *URLFileSystem) Remove(name string) error {
+	return &iofs.PathError{Op: "remove", Path: name, Err: errReadOnly}
+}
+
+// MkdirAll always fails; the remote filesystem is read-only.
+func (u *URLFileSystem) MkdirAll(name string, perm iofs.FileMode) error {
+	return &iofs.PathError{Op: "mkdir", Path: name, Err: errReadOnly}
+}
+
+// ReadDir always fails; remote directories cannot be listed.
+func (u *URLFileSystem) ReadDir(name string) ([]iofs.DirEntry, error) {
+	return nil, &iofs.PathError{Op: "readdir", Path: name, Err: errors.ErrUnsupported}
+}
+
+// TempDir returns an empty string; the remote filesystem has no temp directory.
+func (u *URLFileSystem) TempDir() string {
+	return ""
+}
+
+// Stat fetches the URL for name and describes it as a regular file.
+func (u *URLFileSystem) Stat(name string) (iofs.FileInfo, error) {
+	data, err := u.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return urlFileInfo{name: path.Base(name), size: int64(len(data))}, nil
+}
+
+// Exists reports whether the URL for path can be fetched.
+func (u *URLFileSystem) Exists(path string) bool {
+	_, err := u.ReadFile(path)
+	return err == nil
+}
+
+// Open fetches the URL for name and returns it as a read-only file.
+func (u *URLFileSystem) Open(name string) (iofs.File, error) {
+	data, err := u.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return &urlFile{
+		Reader: bytes.NewReader(data),
+		info:   urlFileInfo{name: path.Base(name), size: int64(len(data))},
+	}, nil
+}
+
+// urlFile is an open file backed by fetched content.
+type urlFile struct {
+	*bytes.Reader
+	info urlFileInfo
+}
+
+func (f *urlFile) Stat() (iofs.FileInfo, error) { return f.info, nil }
+func (f *urlFile) Close() error                 { return nil }
+
+// urlFileInfo describes fetched content as a regular, read-only file.
+type urlFileInfo struct {
+	name string
+	size int64
+}
+
+func (i urlFileInfo) Name() string        { return i.name }
+func (i urlFileInfo) Size() int64         { return i.size }
+func (i urlFileInfo) Mode() iofs.FileMode { return 0444 }
+func (i urlFileInfo) ModTime() time.Time  { return time.Time{} }
+func (i urlFileInfo) IsDir() bool         { return false }
+func (i urlFileInfo) Sys() any            { return nil }
+
+// IsPageURL reports whether arg is an http(s) URL rather than a file path.
+func IsPageURL(arg string) bool {
+	u, err := url.Parse(arg)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// splitPageURL splits an http(s) page URL into its origin and path. The query
+// and fragment are dropped, since the path stands for the page in the
+// tracer's filesystem, where relative imports resolve against its directory.
+func splitPageURL(pageURL string) (origin, pagePath string, err error) {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid URL %q: %w", pageURL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", "", fmt.Errorf("invalid URL %q: must be an absolute http or https URL", pageURL)
+	}
+	pagePath = u.EscapedPath()
+	if pagePath == "" {
+		pagePath = "/"
+	}
+	return u.Scheme + "://" + u.Host, pagePath, nil
+}
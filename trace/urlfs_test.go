@@ -0,0 +1,149 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package trace
+
+import (
+	"encoding/json"
+	"errors"
+	iofs "io/fs"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"bennypowers.dev/mappa/cdn"
+	"bennypowers.dev/mappa/fs"
+	"bennypowers.dev/mappa/testutil"
+)
+
+// newSiteServer serves the files under root in fsys over HTTP.
+func newSiteServer(t *testing.T, fsys fs.FileSystem, root string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := fsys.ReadFile(root + r.URL.Path)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestURLFileSystem(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "trace/remote-page", "/test")
+	server := newSiteServer(t, mfs, "/test/site")
+
+	ufs := NewURLFileSystem(t.Context(), cdn.NewHTTPFetcher(), server.URL)
+
+	data, err := ufs.ReadFile("/js/render.js")
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	expected, err := mfs.ReadFile("/test/site/js/render.js")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	if string(data) != string(expected) {
+		t.Errorf("ReadFile content mismatch:\n  got:      %q\n  expected: %q", data, expected)
+	}
+
+	if _, err := ufs.ReadFile("/missing.js"); !errors.Is(err, iofs.ErrNotExist) {
+		t.Errorf("Expected not-exist error for missing file, got %v", err)
+	}
+	if ufs.Exists("/missing.js") {
+		t.Error("Expected missing file not to exist")
+	}
+	if !ufs.Exists("/page.html") {
+		t.Error("Expected page.html to exist")
+	}
+
+	info, err := ufs.Stat("/js/app.js")
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Name() != "app.js" || info.IsDir() {
+		t.Errorf("Unexpected file info: name=%q dir=%v", info.Name(), info.IsDir())
+	}
+
+	if err := ufs.WriteFile("/page.html", nil, 0644); err == nil {
+		t.Error("Expected WriteFile to fail on a read-only filesystem")
+	}
+}
+
+func TestSplitPageURL(t *testing.T) {
+	tests := []struct {
+		url        string
+		wantOrigin string
+		wantPath   string
+		wantErr    bool
+	}{
+		{"https://example.com/docs/page.html", "https://example.com", "/docs/page.html", false},
+		{"http://localhost:8080", "http://localhost:8080", "/", false},
+		{"https://example.com/search?q=lit", "https://example.com", "/search", false},
+		{"https://example.com/docs/page.html?v=2#top", "https://example.com", "/docs/page.html", false},
+		{"ftp://example.com/page.html", "", "", true},
+		{"page.html", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			origin, pagePath, err := splitPageURL(tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("splitPageURL(%q) error = %v, wantErr %v", tt.url, err, tt.wantErr)
+			}
+			if origin != tt.wantOrigin || pagePath != tt.wantPath {
+				t.Errorf("splitPageURL(%q) = (%q, %q), want (%q, %q)", tt.url, origin, pagePath, tt.wantOrigin, tt.wantPath)
+			}
+			if IsPageURL(tt.url) == tt.wantErr {
+				t.Errorf("IsPageURL(%q) = %v", tt.url, !tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTraceURL(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "trace/remote-page", "/test")
+	server := newSiteServer(t, mfs, "/test/site")
+
+	opts := Options{
+		Template:        "https://esm.sh/{package}@{version}/{path}",
+		AssumeInstalled: true,
+	}
+
+	// The query string only matters to the server
+	result, err := TraceURL(mfs, cdn.NewHTTPFetcher(), server.URL+"/page.html?lang=en", "/test", opts)
+	if err != nil {
+		t.Fatalf("TraceURL failed: %v", err)
+	}
+
+	expectedBytes, err := mfs.ReadFile("/test/expected.json")
+	if err != nil {
+		t.Fatalf("Failed to read expected.json: %v", err)
+	}
+	var expected struct {
+		Imports map[string]string `json:"imports"`
+	}
+	if err := json.Unmarshal(expectedBytes, &expected); err != nil {
+		t.Fatalf("Failed to parse expected.json: %v", err)
+	}
+
+	if !reflect.DeepEqual(result.ImportMap.Imports, expected.Imports) {
+		t.Errorf("Imports mismatch:\n  got:      %v\n  expected: %v", result.ImportMap.Imports, expected.Imports)
+	}
+}
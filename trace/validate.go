@@ -92,7 +92,7 @@ func (g *ModuleGraph) ValidateImports(
 		}
 
 		for _, imp := range mod.Imports {
-			if !isBareSpecifier(imp.Specifier) {
+			if imp.Unresolvable || !isBareSpecifier(imp.Specifier) {
 				continue
 			}
 
@@ -0,0 +1,179 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+// Package tsconfig reads module path aliases from tsconfig.json files.
+package tsconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"bennypowers.dev/mappa/fs"
+)
+
+// TSConfig represents the subset of tsconfig.json relevant for import maps.
+// The "extends" field is not followed.
+type TSConfig struct {
+	// CompilerOptions holds the module resolution options.
+	CompilerOptions CompilerOptions `json:"compilerOptions"`
+
+	// dir is the directory containing the tsconfig.json, if parsed from a file.
+	dir string
+}
+
+// CompilerOptions is the subset of compilerOptions used for path aliases.
+type CompilerOptions struct {
+	// BaseURL is the directory non-relative paths are resolved from,
+	// relative to the tsconfig.json.
+	BaseURL string `json:"baseUrl,omitempty"`
+	// Paths maps specifier patterns (e.g., "@app/*") to target patterns
+	// (e.g., "src/*"), tried in order.
+	Paths map[string][]string `json:"paths,omitempty"`
+}
+
+// Extensions are appended, in order, to alias targets that don't name an
+// existing file. Index files are tried last.
+var Extensions = []string{".js", ".mjs"}
+
+// Parse parses tsconfig.json data. Comments and trailing commas are allowed,
+// as in TypeScript.
+func Parse(data []byte) (*TSConfig, error) {
+	var config TSConfig
+	if err := json.Unmarshal(stripJSONC(data), &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// ParseFile reads and parses a tsconfig.json file.
+func ParseFile(fs fs.FileSystem, path string) (*TSConfig, error) {
+	data, err := fs.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	config.dir = filepath.Dir(path)
+	return config, nil
+}
+
+// PathsBaseDir returns the directory that path alias targets are relative to:
+// baseUrl if set, else the directory containing the tsconfig.json.
+func (c *TSConfig) PathsBaseDir() string {
+	return filepath.Join(c.dir, c.CompilerOptions.BaseURL)
+}
+
+// MatchPaths returns the candidate targets for specifier from a paths map,
+// with any "*" substituted, or nil if no pattern matches. As in TypeScript, an
+// exact key wins, then the wildcard pattern with the longest prefix.
+func MatchPaths(paths map[string][]string, specifier string) []string {
+	if targets, ok := paths[specifier]; ok {
+		return targets
+	}
+
+	var best, match string
+	for pattern := range paths {
+		prefix, suffix, ok := strings.Cut(pattern, "*")
+		if !ok || len(specifier) < len(prefix)+len(suffix) {
+			continue
+		}
+		if !strings.HasPrefix(specifier, prefix) || !strings.HasSuffix(specifier, suffix) {
+			continue
+		}
+		if best == "" || len(prefix) > len(strings.Split(best, "*")[0]) {
+			best = pattern
+			match = specifier[len(prefix) : len(specifier)-len(suffix)]
+		}
+	}
+	if best == "" {
+		return nil
+	}
+
+	targets := make([]string, len(paths[best]))
+	for i, target := range paths[best] {
+		targets[i] = strings.Replace(target, "*", match, 1)
+	}
+	return targets
+}
+
+// ResolvePath resolves specifier through a paths map to the first existing file,
+// trying each target as given, with each of Extensions, and as a directory
+// containing an index file. Targets are relative to baseDir. Returns false if
+// no pattern matches or no target exists.
+func ResolvePath(fsys fs.FileSystem, baseDir string, paths map[string][]string, specifier string) (string, bool) {
+	for _, target := range MatchPaths(paths, specifier) {
+		base := filepath.Join(baseDir, target)
+		candidates := []string{base}
+		for _, ext := range Extensions {
+			candidates = append(candidates, base+ext)
+		}
+		for _, ext := range Extensions {
+			candidates = append(candidates, filepath.Join(base, "index"+ext))
+		}
+		for _, candidate := range candidates {
+			if info, err := fsys.Stat(candidate); err == nil && !info.IsDir() {
+				return candidate, true
+			}
+		}
+	}
+	return "", false
+}
+
+// stripJSONC removes comments and trailing commas from JSON with comments,
+// leaving string contents untouched.
+func stripJSONC(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	inString := false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case inString:
+			if c == '\\' && i+1 < len(data) {
+				out = append(out, c)
+				i++
+				c = data[i]
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i+1 < len(data) && data[i+1] != '\n' {
+				i++
+			}
+			continue
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			end := bytes.Index(data[i+2:], []byte("*/"))
+			if end < 0 {
+				return out
+			}
+			i += end + 3
+			continue
+		case c == '}' || c == ']':
+			// Drop a trailing comma before the closing bracket
+			trimmed := bytes.TrimRight(out, " \t\r\n")
+			if len(trimmed) > 0 && trimmed[len(trimmed)-1] == ',' {
+				out = append(trimmed[:len(trimmed)-1], out[len(trimmed):]...)
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
@@ -0,0 +1,116 @@
+/*
+Copyright © 2026 Benny Powers <web@bennypowers.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package tsconfig_test
+
+import (
+	"slices"
+	"testing"
+
+	"bennypowers.dev/mappa/testutil"
+	"bennypowers.dev/mappa/tsconfig"
+)
+
+func TestParseFile(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "tsconfig/paths", "/test")
+
+	config, err := tsconfig.ParseFile(mfs, "/test/tsconfig.json")
+	if err != nil {
+		t.Fatalf("ParseFile failed: %v", err)
+	}
+
+	if got := config.PathsBaseDir(); got != "/test" {
+		t.Errorf("PathsBaseDir() = %q, want %q", got, "/test")
+	}
+	if got := config.CompilerOptions.Paths["@app/components/*"]; !slices.Equal(got, []string{"src/components/*", "src/legacy/*"}) {
+		t.Errorf("Paths[@app/components/*] = %v", got)
+	}
+	if len(config.CompilerOptions.Paths) != 3 {
+		t.Errorf("Expected 3 path patterns, got %v", config.CompilerOptions.Paths)
+	}
+}
+
+func TestParseComments(t *testing.T) {
+	config, err := tsconfig.Parse([]byte(`{"compilerOptions": {"baseUrl": "./src", /* c */ "paths": {"//not-a-comment/*": ["a/*",],},},}`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if config.CompilerOptions.BaseURL != "./src" {
+		t.Errorf("BaseURL = %q", config.CompilerOptions.BaseURL)
+	}
+	if _, ok := config.CompilerOptions.Paths["//not-a-comment/*"]; !ok {
+		t.Errorf("Expected comment-like string key to survive, got %v", config.CompilerOptions.Paths)
+	}
+}
+
+func TestMatchPaths(t *testing.T) {
+	paths := map[string][]string{
+		"@app/*":            {"src/*"},
+		"@app/components/*": {"src/components/*", "src/legacy/*"},
+		"~config":           {"src/config.js"},
+	}
+
+	tests := []struct {
+		specifier string
+		want      []string
+	}{
+		{"@app/foo", []string{"src/foo"}},
+		{"@app/utils/format.js", []string{"src/utils/format.js"}},
+		{"@app/components/button", []string{"src/components/button", "src/legacy/button"}},
+		{"~config", []string{"src/config.js"}},
+		{"~config/other", nil},
+		{"lit", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.specifier, func(t *testing.T) {
+			if got := tsconfig.MatchPaths(paths, tt.specifier); !slices.Equal(got, tt.want) {
+				t.Errorf("MatchPaths(%q) = %v, want %v", tt.specifier, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolvePath(t *testing.T) {
+	mfs := testutil.NewFixtureFS(t, "tsconfig/paths", "/test")
+
+	config, err := tsconfig.ParseFile(mfs, "/test/tsconfig.json")
+	if err != nil {
+		t.Fatalf("ParseFile failed: %v", err)
+	}
+
+	tests := []struct {
+		specifier string
+		want      string
+	}{
+		{"@app/foo", "/test/src/foo.js"},
+		{"@app/foo.js", "/test/src/foo.js"},
+		{"@app/utils", "/test/src/utils/index.js"},
+		{"@app/components/button", "/test/src/components/button.js"},
+		{"~config", "/test/src/config.js"},
+		{"@app/missing", ""},
+		{"lit", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.specifier, func(t *testing.T) {
+			got, ok := tsconfig.ResolvePath(mfs, config.PathsBaseDir(), config.CompilerOptions.Paths, tt.specifier)
+			if ok != (tt.want != "") || got != tt.want {
+				t.Errorf("ResolvePath(%q) = %q, %v; want %q", tt.specifier, got, ok, tt.want)
+			}
+		})
+	}
+}